@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"analyzer/pkg/analyzer"
+
+	"redis-learning/pkg/cli"
+	"redis-learning/pkg/redisclient"
+)
+
+// progressInterval 输出分析进度的间隔
+const progressInterval = 5 * time.Second
+
+// Run 分析Redis数据库的key，向标准输出打印报告，args为命令行参数
+// 中断时打印已经分析的部分的报告
+func Run(ctx context.Context, args []string) error {
+	defaults := analyzer.DefaultConfig
+	fs := cli.NewFlagSet("analyzer")
+	redisAddr := cli.RedisFlag(fs)
+	db := fs.Int("db", 0, "Redis database to analyze")
+	match := fs.String("match", "", "only analyze keys matching this glob pattern")
+	keyType := fs.String("type", "", "only analyze keys of this type (string, list, hash, set, zset, stream)")
+	batch := fs.Int64("count", defaults.BatchSize, "SCAN COUNT hint")
+	rate := fs.Int("rate", defaults.KeysPerSecond, "maximum keys analyzed per second, 0 for no limit")
+	maxKeys := fs.Int64("max-keys", 0, "stop after analyzing this many keys, 0 to scan the whole database")
+	sample := fs.Float64("sample", defaults.SampleRate, "fraction of keys measured with MEMORY USAGE, (0, 1]")
+	memorySamples := fs.Int("memory-samples", defaults.MemorySamples, "MEMORY USAGE SAMPLES, 0 to count every element")
+	bigBytes := fs.Int64("big-bytes", defaults.BigKeyBytes, "memory in bytes at which a key is big")
+	bigLength := fs.Int64("big-length", defaults.BigKeyLength, "number of elements at which a collection key is big")
+	top := fs.Int("top", defaults.TopPatterns, "number of big keys, patterns and hot prefixes to report")
+	separators := fs.String("separators", defaults.Separators, "characters separating key segments")
+	prefixDepth := fs.Int("prefix-depth", defaults.PrefixDepth, "number of pattern segments in a prefix")
+	format := fs.String("format", "table", "report format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown report format %q", *format)
+	}
+
+	client := redisclient.NewClient(redisclient.Config{Addr: *redisAddr, DB: *db})
+	defer client.Close()
+	if err := redisclient.Ping(ctx, client); err != nil {
+		return fmt.Errorf("failed to connect to Redis at %s: %w", *redisAddr, err)
+	}
+
+	config := analyzer.Config{
+		Match:         *match,
+		Type:          *keyType,
+		BatchSize:     *batch,
+		KeysPerSecond: *rate,
+		MaxKeys:       *maxKeys,
+		SampleRate:    *sample,
+		MemorySamples: *memorySamples,
+		BigKeyBytes:   *bigBytes,
+		BigKeyLength:  *bigLength,
+		TopKeys:       *top,
+		TopPatterns:   *top,
+		Separators:    *separators,
+		PrefixDepth:   *prefixDepth,
+	}
+	// 报告输出到标准输出，进度输出到日志，重定向报告时仍能看到进度
+	last := time.Now()
+	config.Progress = func(scanned int64) {
+		if time.Since(last) >= progressInterval {
+			last = time.Now()
+			log.Printf("Analyzed %d keys", scanned)
+		}
+	}
+	a, err := analyzer.NewAnalyzer(client, config)
+	if err != nil {
+		return err
+	}
+
+	report, err := a.Analyze(ctx)
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		log.Printf("Interrupted, reporting the %d keys analyzed so far", report.Scanned)
+	} else if err != nil {
+		return fmt.Errorf("failed to analyze keys: %w", err)
+	}
+
+	if *format == "json" {
+		return report.WriteJSON(os.Stdout)
+	}
+	return report.WriteTable(os.Stdout)
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"analyzer/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("analyzer", app.Run)
+}
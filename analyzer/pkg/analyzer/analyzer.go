@@ -0,0 +1,407 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 访问热度的统计方式，即 Report.HotBy 的取值
+const (
+	// HotByFreq 淘汰策略为LFU时，按 OBJECT FREQ 返回的访问频率计数
+	HotByFreq = "freq"
+	// HotByIdle 其他淘汰策略下，按 OBJECT IDLETIME 返回的空闲时间
+	HotByIdle = "idle"
+	// HotByKeys 无法读取访问信息（如不允许 CONFIG、OBJECT 命令）时按key数量
+	HotByKeys = "keys"
+)
+
+// defaultMemorySamples Redis中 MEMORY USAGE 默认的SAMPLES
+const defaultMemorySamples = 5
+
+// OtherPattern 模式数量达到 Config.MaxPatterns 后，新出现的模式归入这一组
+const OtherPattern = "(other)"
+
+var (
+	// ErrInvalidSampleRate 抽样比例不在(0, 1]范围内
+	ErrInvalidSampleRate = errors.New("analyzer: sample rate must be in (0, 1]")
+	// errStopScan 分析的key数量达到上限，提前结束遍历
+	errStopScan = errors.New("analyzer: stop scan")
+)
+
+// Config 分析的配置，数值字段为0时使用 DefaultConfig 中的值（KeysPerSecond、MemorySamples、MaxKeys除外）
+type Config struct {
+	// SCAN的MATCH参数，为空时遍历所有key
+	Match string
+	// SCAN的TYPE参数，只分析指定类型的key，为空时分析所有类型
+	Type string
+	// 每次SCAN返回的key数量的提示值（COUNT）
+	BatchSize int64
+	// 每秒最多分析的key数量，0表示不限速；分析线上的库时用来避免占用太多Redis的CPU
+	KeysPerSecond int
+	// 最多分析的key数量，0表示遍历整个库
+	MaxKeys int64
+	// 用 MEMORY USAGE 统计内存的key的比例，(0, 1]；按key的哈希值抽样，多次分析抽到的key相同
+	// 没有抽到的key按同一模式中抽到的key的平均值估计内存
+	SampleRate float64
+	// MEMORY USAGE 的SAMPLES参数：集合类型只抽样这么多个元素估计整个key的内存，0表示计算所有元素
+	MemorySamples int
+	// 内存达到这个字节数的key为大key；没有抽到的字符串长度达到这个值时也会统计内存
+	BigKeyBytes int64
+	// 元素数达到这个值的集合类型（list、hash、set、zset、stream）为大key，不论是否抽到都会统计内存
+	BigKeyLength int64
+	// 报告中大key的数量
+	TopKeys int
+	// 报告中模式和热点前缀的数量
+	TopPatterns int
+	// 最多统计的模式数量，超过后新的模式归入 OtherPattern，避免key没有规律时占用太多内存
+	MaxPatterns int
+	// key中分隔各段的字符
+	Separators string
+	// 前缀取模式的前几段
+	PrefixDepth int
+	// 每分析完一批key调用一次，参数为已经分析的key数量，可以用于显示进度；集群模式下会被并发调用
+	Progress func(scanned int64)
+}
+
+// DefaultConfig 默认的分析配置
+var DefaultConfig = Config{
+	BatchSize:     100,
+	KeysPerSecond: 10000,
+	SampleRate:    0.1,
+	MemorySamples: defaultMemorySamples,
+	BigKeyBytes:   1 << 20,
+	BigKeyLength:  5000,
+	TopKeys:       20,
+	TopPatterns:   20,
+	MaxPatterns:   10000,
+	Separators:    ":",
+	PrefixDepth:   1,
+}
+
+// Analyzer 通过SCAN遍历Redis数据库，统计key的类型、内存、元素数、过期时间和访问热度
+// 只执行只读命令，集群模式下遍历每个主节点
+type Analyzer struct {
+	client redis.UniversalClient
+	config Config
+}
+
+// NewAnalyzer 创建分析器，抽样比例无效时返回 ErrInvalidSampleRate
+func NewAnalyzer(client redis.UniversalClient, config Config) (*Analyzer, error) {
+	if config.SampleRate == 0 {
+		config.SampleRate = DefaultConfig.SampleRate
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, ErrInvalidSampleRate
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultConfig.BatchSize
+	}
+	if config.BigKeyBytes <= 0 {
+		config.BigKeyBytes = DefaultConfig.BigKeyBytes
+	}
+	if config.BigKeyLength <= 0 {
+		config.BigKeyLength = DefaultConfig.BigKeyLength
+	}
+	if config.TopKeys <= 0 {
+		config.TopKeys = DefaultConfig.TopKeys
+	}
+	if config.TopPatterns <= 0 {
+		config.TopPatterns = DefaultConfig.TopPatterns
+	}
+	if config.MaxPatterns <= 0 {
+		config.MaxPatterns = DefaultConfig.MaxPatterns
+	}
+	if config.Separators == "" {
+		config.Separators = DefaultConfig.Separators
+	}
+	if config.PrefixDepth <= 0 {
+		config.PrefixDepth = DefaultConfig.PrefixDepth
+	}
+	return &Analyzer{client: client, config: config}, nil
+}
+
+// NewDefaultAnalyzer 使用默认配置创建分析器
+func NewDefaultAnalyzer(client redis.UniversalClient) *Analyzer {
+	a, _ := NewAnalyzer(client, DefaultConfig)
+	return a
+}
+
+// Analyze 遍历数据库并生成报告
+// ctx取消或Redis出错时返回已经分析的部分的报告和错误
+func (a *Analyzer) Analyze(ctx context.Context) (*Report, error) {
+	started := time.Now()
+	c := newCollector(a.config, a.hotBy(ctx))
+	t := newThrottle(a.config.KeysPerSecond)
+
+	var err error
+	if cluster, ok := a.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return a.scanNode(ctx, node, c, t)
+		})
+	} else {
+		err = a.scanNode(ctx, a.client, c, t)
+	}
+	if errors.Is(err, errStopScan) {
+		err = nil
+	}
+	return c.report(started, time.Since(started)), err
+}
+
+// hotBy 根据淘汰策略决定如何读取访问热度：LFU策略下 OBJECT IDLETIME 不可用，其他策略下 OBJECT FREQ 不可用
+func (a *Analyzer) hotBy(ctx context.Context) string {
+	policy, err := a.client.ConfigGet(ctx, "maxmemory-policy").Result()
+	if err != nil {
+		return HotByKeys
+	}
+	if strings.Contains(policy["maxmemory-policy"], "lfu") {
+		return HotByFreq
+	}
+	return HotByIdle
+}
+
+// scanNode 在单个节点上按批遍历key，每批先等待限速再分析
+func (a *Analyzer) scanNode(ctx context.Context, client redis.Cmdable, c *collector, t *throttle) error {
+	var cursor uint64
+	for {
+		var keys []string
+		var err error
+		if a.config.Type != "" {
+			keys, cursor, err = client.ScanType(ctx, cursor, a.config.Match, a.config.BatchSize, a.config.Type).Result()
+		} else {
+			keys, cursor, err = client.Scan(ctx, cursor, a.config.Match, a.config.BatchSize).Result()
+		}
+		if err != nil {
+			return err
+		}
+
+		if keys = c.take(keys); len(keys) > 0 {
+			if err := t.wait(ctx, len(keys)); err != nil {
+				return err
+			}
+			infos, err := a.inspect(ctx, client, keys, c.hotBy)
+			if err != nil {
+				return err
+			}
+			scanned := c.add(infos)
+			if a.config.Progress != nil {
+				a.config.Progress(scanned)
+			}
+		}
+		if c.full() {
+			return errStopScan
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// keyInfo 单个key的检查结果
+type keyInfo struct {
+	key string
+	typ string // "none"表示SCAN之后key已经被删除
+	ttl time.Duration
+	// 元素数，字符串为字节数，-1表示类型不支持
+	length int64
+	// MEMORY USAGE 的结果，-1表示没有统计
+	bytes   int64
+	sampled bool
+	// 访问频率计数或空闲秒数，hasAccess为false时没有读取
+	access    float64
+	hasAccess bool
+}
+
+// inspect 用三次管道检查一批key：
+//  1. TYPE、PTTL，抽到的key读取访问热度（OBJECT 不会更新key的访问时间，须在读取长度之前执行）
+//  2. 按类型读取长度，抽到的key执行 MEMORY USAGE
+//  3. 没有抽到但长度达到大key阈值的key执行 MEMORY USAGE
+func (a *Analyzer) inspect(ctx context.Context, client redis.Cmdable, keys []string, hotBy string) ([]keyInfo, error) {
+	infos := make([]keyInfo, len(keys))
+	types := make([]*redis.StatusCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	freqs := make([]*redis.IntCmd, len(keys))
+	idles := make([]*redis.DurationCmd, len(keys))
+	pipe := client.Pipeline()
+	for i, key := range keys {
+		infos[i] = keyInfo{key: key, length: -1, bytes: -1, sampled: a.sampled(key)}
+		types[i] = pipe.Type(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+		if infos[i].sampled {
+			switch hotBy {
+			case HotByFreq:
+				freqs[i] = pipe.ObjectFreq(ctx, key)
+			case HotByIdle:
+				idles[i] = pipe.ObjectIdleTime(ctx, key)
+			}
+		}
+	}
+	// OBJECT 出错（如托管的Redis禁用了该命令）时只是没有访问热度
+	if err := exec(ctx, pipe); err != nil {
+		return nil, err
+	}
+	for i := range infos {
+		if err := types[i].Err(); err != nil {
+			return nil, err
+		}
+		infos[i].typ = types[i].Val()
+		infos[i].ttl = ttls[i].Val()
+		if freqs[i] != nil && freqs[i].Err() == nil {
+			infos[i].access, infos[i].hasAccess = float64(freqs[i].Val()), true
+		}
+		if idles[i] != nil && idles[i].Err() == nil {
+			infos[i].access, infos[i].hasAccess = idles[i].Val().Seconds(), true
+		}
+	}
+
+	lengths := make([]*redis.IntCmd, len(keys))
+	memory := make([]*redis.IntCmd, len(keys))
+	pipe = client.Pipeline()
+	for i, info := range infos {
+		lengths[i] = lengthCmd(ctx, pipe, info.typ, info.key)
+		if info.sampled && info.typ != "none" {
+			memory[i] = a.memoryUsage(ctx, pipe, info.key)
+		}
+	}
+	if err := exec(ctx, pipe); err != nil {
+		return nil, err
+	}
+	var big []int
+	for i := range infos {
+		if lengths[i] != nil && lengths[i].Err() == nil {
+			infos[i].length = lengths[i].Val()
+		}
+		if memory[i] != nil && memory[i].Err() == nil {
+			infos[i].bytes = memory[i].Val()
+		}
+		if !infos[i].sampled && a.maybeBig(infos[i]) {
+			big = append(big, i)
+		}
+	}
+
+	if len(big) > 0 {
+		pipe = client.Pipeline()
+		for _, i := range big {
+			memory[i] = a.memoryUsage(ctx, pipe, infos[i].key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		for _, i := range big {
+			if memory[i].Err() == nil {
+				infos[i].bytes = memory[i].Val()
+			}
+		}
+	}
+	return infos, nil
+}
+
+// exec 执行管道，Redis返回的错误只影响对应的命令，由调用方逐个检查；连接出错、超时时返回错误
+func exec(ctx context.Context, pipe redis.Pipeliner) error {
+	_, err := pipe.Exec(ctx)
+	var redisErr redis.Error
+	if err == nil || errors.As(err, &redisErr) {
+		return nil
+	}
+	return err
+}
+
+// lengthCmd 在管道中添加读取key长度的命令，类型没有对应的命令时返回nil
+// 这些命令会更新key的访问时间，在LRU、LFU策略下影响之后读取的访问热度，分析线上的库时建议连接从库
+func lengthCmd(ctx context.Context, pipe redis.Pipeliner, typ, key string) *redis.IntCmd {
+	switch typ {
+	case "string":
+		return pipe.StrLen(ctx, key)
+	case "list":
+		return pipe.LLen(ctx, key)
+	case "hash":
+		return pipe.HLen(ctx, key)
+	case "set":
+		return pipe.SCard(ctx, key)
+	case "zset":
+		return pipe.ZCard(ctx, key)
+	case "stream":
+		return pipe.XLen(ctx, key)
+	default:
+		return nil
+	}
+}
+
+// memoryUsage 在管道中添加 MEMORY USAGE 命令
+// SAMPLES与Redis的默认值相同时省略，兼容不支持该参数的实现（如测试用的miniredis）
+func (a *Analyzer) memoryUsage(ctx context.Context, pipe redis.Pipeliner, key string) *redis.IntCmd {
+	if a.config.MemorySamples == defaultMemorySamples {
+		return pipe.MemoryUsage(ctx, key)
+	}
+	return pipe.MemoryUsage(ctx, key, a.config.MemorySamples)
+}
+
+// maybeBig 根据长度判断key可能是大key，需要统计内存
+func (a *Analyzer) maybeBig(info keyInfo) bool {
+	if info.length < 0 {
+		return false
+	}
+	if info.typ == "string" {
+		return info.length >= a.config.BigKeyBytes
+	}
+	return info.length >= a.config.BigKeyLength
+}
+
+// sampled 按key的哈希值决定是否抽到，同一个key每次的结果相同
+func (a *Analyzer) sampled(key string) bool {
+	if a.config.SampleRate >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return float64(h.Sum32()) < a.config.SampleRate*math.MaxUint32
+}
+
+// throttle 按每秒的key数量限速，集群模式下多个节点的遍历共用一个限速
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration // 每个key占用的时间
+	next     time.Time     // 下一批可以开始的时间
+}
+
+// newThrottle 创建限速，keysPerSecond不大于0时返回nil，表示不限速
+func newThrottle(keysPerSecond int) *throttle {
+	if keysPerSecond <= 0 {
+		return nil
+	}
+	return &throttle{interval: time.Second / time.Duration(keysPerSecond)}
+}
+
+// wait 为n个key预留时间，等到这一批可以开始时返回
+func (t *throttle) wait(ctx context.Context, n int) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	at := t.next
+	t.next = t.next.Add(time.Duration(n) * t.interval)
+	t.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
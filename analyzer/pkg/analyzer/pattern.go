@@ -0,0 +1,73 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Wildcard 模式中代替ID等变化部分的占位符
+const Wildcard = "*"
+
+// maxLiteralSegment 超过这个长度的段视为令牌、哈希等随机值
+const maxLiteralSegment = 24
+
+// Pattern 把key归纳为模式：按separators中的字符分段，看起来像ID的段替换为 Wildcard
+// 包含数字或超过24个字符的段视为ID，如 "user:1001:profile" 归纳为 "user:*:profile"；
+// hash tag（{...}）整体作为一段，只归纳其中的内容，如 "rank:{demo}:item:5" 归纳为 "rank:{demo}:item:*"
+func Pattern(key, separators string) string {
+	segments, seps := split(key, separators)
+	var b strings.Builder
+	for i, segment := range segments {
+		b.WriteString(normalize(segment, separators))
+		if i < len(seps) {
+			b.WriteByte(seps[i])
+		}
+	}
+	return b.String()
+}
+
+// Prefix 返回模式的前depth段作为前缀，最后一段不作为前缀，只有一段的模式返回自身
+func Prefix(pattern, separators string, depth int) string {
+	segments, seps := split(pattern, separators)
+	n := min(depth, len(segments)-1)
+	if n <= 0 {
+		return pattern
+	}
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString(segments[i])
+		b.WriteByte(seps[i])
+	}
+	return b.String()
+}
+
+// split 按分隔符分段，返回各段和段之间的分隔符，hash tag 中的分隔符不分段
+func split(key, separators string) ([]string, []byte) {
+	var segments []string
+	var seps []byte
+	start, depth := 0, 0
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case depth == 0 && strings.IndexByte(separators, c) >= 0:
+			segments = append(segments, key[start:i])
+			seps = append(seps, c)
+			start = i + 1
+		}
+	}
+	return append(segments, key[start:]), seps
+}
+
+// normalize 把像ID的段替换为 Wildcard，hash tag 保留花括号
+func normalize(segment, separators string) string {
+	if len(segment) >= 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return "{" + Pattern(segment[1:len(segment)-1], separators) + "}"
+	}
+	if len(segment) > maxLiteralSegment || strings.IndexFunc(segment, unicode.IsDigit) >= 0 {
+		return Wildcard
+	}
+	return segment
+}
@@ -0,0 +1,482 @@
+package analyzer
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ttlBucket 剩余过期时间分布的一个区间，upper为0表示没有上界
+type ttlBucket struct {
+	label string
+	upper time.Duration
+}
+
+// ttlBuckets 剩余过期时间分布的区间
+var ttlBuckets = []ttlBucket{
+	{"1m", time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"+Inf", 0},
+}
+
+// Report 分析报告，内存均为估计值：抽到的key按 MEMORY USAGE，其他key按同一模式的平均值
+type Report struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	// 分析的key数量，包括SCAN之后被删除的key
+	Scanned int64 `json:"scanned"`
+	// 分析时仍然存在的key数量
+	Keys int64 `json:"keys"`
+	// 统计了内存的key数量
+	Measured int64 `json:"measured"`
+	// 估计的总内存，单位为字节
+	Bytes int64 `json:"bytes"`
+	// 达到 Config.MaxKeys 提前结束
+	Truncated bool        `json:"truncated"`
+	Types     []TypeStats `json:"types"`
+	TTL       TTLStats    `json:"ttl"`
+	BigKeys   []KeyStats  `json:"big_keys"`
+	// 模式总数，Patterns只包含内存最多的 Config.TopPatterns 个
+	PatternCount int            `json:"pattern_count"`
+	Patterns     []PatternStats `json:"patterns"`
+	// 热点前缀的排序方式：HotByFreq、HotByIdle 或 HotByKeys
+	HotBy       string        `json:"hot_by"`
+	HotPrefixes []PrefixStats `json:"hot_prefixes"`
+}
+
+// TypeStats 一种类型的key的统计
+type TypeStats struct {
+	Type  string `json:"type"`
+	Keys  int64  `json:"keys"`
+	Bytes int64  `json:"bytes"`
+	// 元素总数，字符串为字节数
+	Length int64 `json:"length"`
+}
+
+// TTLStats 过期时间的统计
+type TTLStats struct {
+	// 设置了过期时间的key数量
+	WithTTL int64 `json:"with_ttl"`
+	// 永不过期的key数量
+	WithoutTTL int64 `json:"without_ttl"`
+	// 设置了过期时间的key的比例
+	Coverage float64 `json:"coverage"`
+	// 剩余过期时间的分布，每个区间的key数量
+	Buckets []TTLBucket `json:"buckets"`
+}
+
+// TTLBucket 剩余过期时间不超过Upper的key数量，不含前面的区间
+type TTLBucket struct {
+	Upper string `json:"le"`
+	Keys  int64  `json:"keys"`
+}
+
+// KeyStats 单个key的统计，用于大key列表
+type KeyStats struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	// 内存，-1表示没有统计到（如不支持 MEMORY USAGE）
+	Bytes  int64 `json:"bytes"`
+	Length int64 `json:"length"`
+	// 剩余过期时间，单位为毫秒，-1表示永不过期
+	TTLMs int64 `json:"ttl_ms"`
+}
+
+// PatternStats 一个模式的统计
+type PatternStats struct {
+	Pattern string `json:"pattern"`
+	// 模式中key的类型，类型不止一种时为"mixed"
+	Type     string `json:"type"`
+	Keys     int64  `json:"keys"`
+	Measured int64  `json:"measured"`
+	Bytes    int64  `json:"bytes"`
+	Length   int64  `json:"length"`
+	// 设置了过期时间的key的比例
+	TTLCoverage float64 `json:"ttl_coverage"`
+}
+
+// PrefixStats 一个前缀的统计
+type PrefixStats struct {
+	Prefix   string `json:"prefix"`
+	Keys     int64  `json:"keys"`
+	Bytes    int64  `json:"bytes"`
+	Patterns int    `json:"patterns"`
+	// 读取到访问热度的key数量
+	Accessed int64 `json:"accessed"`
+	// 平均的 OBJECT FREQ 访问频率计数，HotBy为 HotByFreq 时有效
+	AvgFreq float64 `json:"avg_freq,omitempty"`
+	// 平均的 OBJECT IDLETIME 空闲秒数，HotBy为 HotByIdle 时有效
+	AvgIdleSeconds float64 `json:"avg_idle_seconds,omitempty"`
+}
+
+// WriteJSON 以缩进的JSON格式输出报告
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteTable 以控制台表格输出报告
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Scanned %d keys (%d still present) in %v, measured %d, estimated memory %s\n",
+		r.Scanned, r.Keys, time.Duration(r.DurationMs)*time.Millisecond, r.Measured, formatBytes(r.Bytes))
+	if r.Truncated {
+		fmt.Fprintln(tw, "Stopped early after reaching the key limit")
+	}
+	fmt.Fprintf(tw, "TTL: %.1f%% of keys expire (%d), %d never expire\n", r.TTL.Coverage*100, r.TTL.WithTTL, r.TTL.WithoutTTL)
+	for _, b := range r.TTL.Buckets {
+		fmt.Fprintf(tw, "  <= %s\t%d\n", b.Upper, b.Keys)
+	}
+
+	fmt.Fprintln(tw, "\nTYPE\tKEYS\tMEMORY\tLENGTH")
+	for _, t := range r.Types {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\n", t.Type, t.Keys, formatBytes(t.Bytes), t.Length)
+	}
+
+	fmt.Fprintf(tw, "\nBIG KEY\tTYPE\tMEMORY\tLENGTH\tTTL\n")
+	for _, k := range r.BigKeys {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", k.Key, k.Type, formatBytes(k.Bytes), k.Length, formatTTL(k.TTLMs))
+	}
+
+	fmt.Fprintf(tw, "\nPATTERN (%d total)\tTYPE\tKEYS\tMEMORY\tAVG\tTTL%%\n", r.PatternCount)
+	for _, p := range r.Patterns {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%.0f\n",
+			p.Pattern, p.Type, p.Keys, formatBytes(p.Bytes), formatBytes(p.Bytes/max(p.Keys, 1)), p.TTLCoverage*100)
+	}
+
+	fmt.Fprintf(tw, "\nHOT PREFIX (by %s)\tKEYS\tMEMORY\tPATTERNS\tACCESS\n", r.HotBy)
+	for _, p := range r.HotPrefixes {
+		access := "-"
+		switch {
+		case p.Accessed == 0:
+		case r.HotBy == HotByFreq:
+			access = fmt.Sprintf("freq %.1f", p.AvgFreq)
+		case r.HotBy == HotByIdle:
+			access = fmt.Sprintf("idle %v", time.Duration(p.AvgIdleSeconds)*time.Second)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\n", p.Prefix, p.Keys, formatBytes(p.Bytes), p.Patterns, access)
+	}
+	return tw.Flush()
+}
+
+// formatBytes 以KiB、MiB等单位显示字节数，负数表示没有统计
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < 0 {
+		return "-"
+	}
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, suffix := float64(n)/unit, "KMGTPE"
+	i := 0
+	for value >= unit && i < len(suffix)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, suffix[i])
+}
+
+// formatTTL 显示剩余过期时间
+func formatTTL(ms int64) string {
+	if ms < 0 {
+		return "none"
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
+
+// accumulator 一组key的累计值，用于类型和模式
+type accumulator struct {
+	typ           string
+	keys          int64
+	measured      int64
+	measuredBytes int64
+	// 抽到的key，用于估计没有统计内存的key；因为长度达到阈值而统计的大key不计入平均值
+	sampled      int64
+	sampledBytes int64
+	length       int64
+	withTTL      int64
+	accessed     int64
+	accessSum    float64
+}
+
+// add 累计一个key
+func (acc *accumulator) add(info keyInfo) {
+	switch acc.typ {
+	case "":
+		acc.typ = info.typ
+	case info.typ, "mixed":
+	default:
+		acc.typ = "mixed"
+	}
+	acc.keys++
+	if info.bytes >= 0 {
+		acc.measured++
+		acc.measuredBytes += info.bytes
+		if info.sampled {
+			acc.sampled++
+			acc.sampledBytes += info.bytes
+		}
+	}
+	if info.length > 0 {
+		acc.length += info.length
+	}
+	if info.ttl >= 0 {
+		acc.withTTL++
+	}
+	if info.hasAccess {
+		acc.accessed++
+		acc.accessSum += info.access
+	}
+}
+
+// avgBytes 抽到的key的平均内存，没有抽到的key时返回false
+func (acc *accumulator) avgBytes() (float64, bool) {
+	if acc.sampled == 0 {
+		return 0, false
+	}
+	return float64(acc.sampledBytes) / float64(acc.sampled), true
+}
+
+// collector 汇总各批key的检查结果，集群模式下多个节点并发写入
+type collector struct {
+	mu       sync.Mutex
+	config   Config
+	hotBy    string
+	taken    int64 // 已经分配给各节点分析的key数量，用于 Config.MaxKeys
+	scanned  int64
+	measured int64
+	types    map[string]*accumulator
+	patterns map[string]*accumulator
+	ttls     []int64 // 各个 ttlBuckets 区间的key数量
+	bigKeys  []KeyStats
+}
+
+// newCollector 创建汇总
+func newCollector(config Config, hotBy string) *collector {
+	return &collector{
+		config:   config,
+		hotBy:    hotBy,
+		types:    make(map[string]*accumulator),
+		patterns: make(map[string]*accumulator),
+		ttls:     make([]int64, len(ttlBuckets)),
+	}
+}
+
+// take 按 Config.MaxKeys 截取这一批中还可以分析的key
+func (c *collector) take(keys []string) []string {
+	if c.config.MaxKeys <= 0 {
+		return keys
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := min(int64(len(keys)), c.config.MaxKeys-c.taken)
+	c.taken += n
+	return keys[:n]
+}
+
+// full 分析的key数量是否达到 Config.MaxKeys
+func (c *collector) full() bool {
+	if c.config.MaxKeys <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.taken >= c.config.MaxKeys
+}
+
+// add 累计一批key，返回已经分析的key数量
+func (c *collector) add(infos []keyInfo) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, info := range infos {
+		c.scanned++
+		if info.typ == "none" {
+			continue
+		}
+		if info.bytes >= 0 {
+			c.measured++
+		}
+		c.accumulator(c.types, info.typ).add(info)
+
+		pattern := Pattern(info.key, c.config.Separators)
+		if _, ok := c.patterns[pattern]; !ok && len(c.patterns) >= c.config.MaxPatterns {
+			pattern = OtherPattern
+		}
+		c.accumulator(c.patterns, pattern).add(info)
+
+		if info.ttl >= 0 {
+			i := slices.IndexFunc(ttlBuckets, func(b ttlBucket) bool {
+				return b.upper == 0 || info.ttl <= b.upper
+			})
+			c.ttls[i]++
+		}
+
+		if c.big(info) {
+			c.addBigKey(info)
+		}
+	}
+	return c.scanned
+}
+
+// big 内存或长度达到阈值的key为大key，没有统计到内存（如不支持 MEMORY USAGE）时只按长度判断
+func (c *collector) big(info keyInfo) bool {
+	if info.bytes >= c.config.BigKeyBytes {
+		return true
+	}
+	if info.typ == "string" {
+		return info.length >= c.config.BigKeyBytes
+	}
+	return info.length >= c.config.BigKeyLength
+}
+
+// accumulator 返回名称对应的累计值，不存在时创建
+func (c *collector) accumulator(m map[string]*accumulator, name string) *accumulator {
+	acc, ok := m[name]
+	if !ok {
+		acc = &accumulator{}
+		m[name] = acc
+	}
+	return acc
+}
+
+// addBigKey 记录大key，只保留内存最多的 Config.TopKeys 个
+func (c *collector) addBigKey(info keyInfo) {
+	ttl := int64(-1)
+	if info.ttl >= 0 {
+		ttl = info.ttl.Milliseconds()
+	}
+	c.bigKeys = append(c.bigKeys, KeyStats{Key: info.key, Type: info.typ, Bytes: info.bytes, Length: info.length, TTLMs: ttl})
+	// 积累到两倍时再排序截断，避免每个大key都排序一次
+	if len(c.bigKeys) >= 2*c.config.TopKeys {
+		sortBigKeys(c.bigKeys)
+		c.bigKeys = c.bigKeys[:c.config.TopKeys]
+	}
+}
+
+// sortBigKeys 按内存、长度从大到小排序
+func sortBigKeys(keys []KeyStats) {
+	slices.SortFunc(keys, func(a, b KeyStats) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(b.Length, a.Length), strings.Compare(a.Key, b.Key))
+	})
+}
+
+// report 生成报告：没有统计内存的key按同一模式中抽到的key的平均内存估计，模式中没有抽到的key时按同一类型的平均内存估计
+func (c *collector) report(started time.Time, elapsed time.Duration) *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := &Report{
+		StartedAt:    started,
+		DurationMs:   elapsed.Milliseconds(),
+		Scanned:      c.scanned,
+		Measured:     c.measured,
+		Truncated:    c.config.MaxKeys > 0 && c.taken >= c.config.MaxKeys,
+		PatternCount: len(c.patterns),
+		HotBy:        c.hotBy,
+	}
+
+	prefixes := make(map[string]*PrefixStats)
+	accessed := int64(0)
+	for pattern, acc := range c.patterns {
+		avg, ok := acc.avgBytes()
+		if !ok {
+			if t, found := c.types[acc.typ]; found {
+				avg, _ = t.avgBytes()
+			}
+		}
+		bytes := acc.measuredBytes + int64(avg*float64(acc.keys-acc.measured))
+		r.Bytes += bytes
+		r.Patterns = append(r.Patterns, PatternStats{
+			Pattern:     pattern,
+			Type:        acc.typ,
+			Keys:        acc.keys,
+			Measured:    acc.measured,
+			Bytes:       bytes,
+			Length:      acc.length,
+			TTLCoverage: float64(acc.withTTL) / float64(acc.keys),
+		})
+
+		name := Prefix(pattern, c.config.Separators, c.config.PrefixDepth)
+		prefix, ok := prefixes[name]
+		if !ok {
+			prefix = &PrefixStats{Prefix: name}
+			prefixes[name] = prefix
+		}
+		prefix.Keys += acc.keys
+		prefix.Bytes += bytes
+		prefix.Patterns++
+		prefix.Accessed += acc.accessed
+		// 先累计总和，最后再除以数量
+		switch c.hotBy {
+		case HotByFreq:
+			prefix.AvgFreq += acc.accessSum
+		case HotByIdle:
+			prefix.AvgIdleSeconds += acc.accessSum
+		}
+		accessed += acc.accessed
+		r.Keys += acc.keys
+		r.TTL.WithTTL += acc.withTTL
+	}
+
+	for name, acc := range c.types {
+		avg, _ := acc.avgBytes()
+		bytes := acc.measuredBytes + int64(avg*float64(acc.keys-acc.measured))
+		r.Types = append(r.Types, TypeStats{Type: name, Keys: acc.keys, Bytes: bytes, Length: acc.length})
+	}
+	slices.SortFunc(r.Types, func(a, b TypeStats) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(b.Keys, a.Keys), strings.Compare(a.Type, b.Type))
+	})
+
+	r.TTL.WithoutTTL = r.Keys - r.TTL.WithTTL
+	if r.Keys > 0 {
+		r.TTL.Coverage = float64(r.TTL.WithTTL) / float64(r.Keys)
+	}
+	for i, b := range ttlBuckets {
+		r.TTL.Buckets = append(r.TTL.Buckets, TTLBucket{Upper: b.label, Keys: c.ttls[i]})
+	}
+
+	r.BigKeys = slices.Clone(c.bigKeys)
+	sortBigKeys(r.BigKeys)
+	r.BigKeys = r.BigKeys[:min(len(r.BigKeys), c.config.TopKeys)]
+
+	slices.SortFunc(r.Patterns, func(a, b PatternStats) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(b.Keys, a.Keys), strings.Compare(a.Pattern, b.Pattern))
+	})
+	r.Patterns = r.Patterns[:min(len(r.Patterns), c.config.TopPatterns)]
+
+	// 没有读取到任何访问热度时按key数量排序
+	if accessed == 0 {
+		r.HotBy = HotByKeys
+	}
+	for _, prefix := range prefixes {
+		if prefix.Accessed > 0 {
+			prefix.AvgFreq /= float64(prefix.Accessed)
+			prefix.AvgIdleSeconds /= float64(prefix.Accessed)
+		}
+		if r.HotBy == HotByKeys || prefix.Accessed > 0 {
+			r.HotPrefixes = append(r.HotPrefixes, *prefix)
+		}
+	}
+	slices.SortFunc(r.HotPrefixes, func(a, b PrefixStats) int {
+		var hotter int
+		switch r.HotBy {
+		case HotByFreq:
+			hotter = cmp.Compare(b.AvgFreq, a.AvgFreq)
+		case HotByIdle:
+			hotter = cmp.Compare(a.AvgIdleSeconds, b.AvgIdleSeconds)
+		}
+		return cmp.Or(hotter, cmp.Compare(b.Keys, a.Keys), strings.Compare(a.Prefix, b.Prefix))
+	})
+	r.HotPrefixes = r.HotPrefixes[:min(len(r.HotPrefixes), c.config.TopPatterns)]
+	return r
+}
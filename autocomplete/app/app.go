@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"autocomplete/internal/handlers"
+	"autocomplete/pkg/autocomplete"
+
+	"redis-learning/pkg/cli"
+)
+
+// sampleTerms 演示用的词条及其初始权重
+var sampleTerms = map[string]float64{
+	"redis":                  100,
+	"redis cluster":          60,
+	"redis sentinel":         40,
+	"redis stream":           35,
+	"redis lua script":       20,
+	"redis distributed lock": 80,
+	"rate limiter":           50,
+	"read write splitting":   15,
+	"golang":                 90,
+	"go redis":               70,
+	"gin":                    45,
+	"缓存穿透":                   30,
+	"缓存雪崩":                   25,
+	"缓存击穿":                   20,
+}
+
+// Run 运行搜索自动补全服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("autocomplete")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "index name")
+	seed := fs.Bool("seed", true, "add sample terms on startup")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	index := autocomplete.NewDefaultIndex(client, *name)
+	if *seed {
+		for term, weight := range sampleTerms {
+			// 只在词条不存在时添加，重启时不重复累加权重
+			if score, err := index.Score(ctx, term); err != nil || score > 0 {
+				continue
+			}
+			if _, err := index.Add(ctx, term, weight); err != nil {
+				return fmt.Errorf("failed to add sample term: %w", err)
+			}
+		}
+		log.Printf("Added %d sample terms", len(sampleTerms))
+	}
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	handlers.NewAutocompleteHandler(index).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"autocomplete/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("autocomplete", app.Run)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"autocomplete/pkg/autocomplete"
+)
+
+// maxLimit 一次最多返回的补全建议数
+const maxLimit = 50
+
+// AutocompleteHandler 处理自动补全相关的HTTP请求
+type AutocompleteHandler struct {
+	index *autocomplete.Index
+}
+
+// NewAutocompleteHandler 创建一个新的自动补全处理器
+func NewAutocompleteHandler(index *autocomplete.Index) *AutocompleteHandler {
+	return &AutocompleteHandler{index: index}
+}
+
+// Setup 设置所有路由
+func (h *AutocompleteHandler) Setup(router *gin.Engine) {
+	// 按权重补全
+	router.GET("/suggest", h.Suggest)
+	// 按字典序补全
+	router.GET("/complete", h.Complete)
+	// 搜索，词条的权重加1
+	router.POST("/search", h.Search)
+
+	api := router.Group("/terms")
+	{
+		// 添加词条或累加权重
+		api.POST("", h.AddTerm)
+		// 删除词条
+		api.DELETE("/:term", h.RemoveTerm)
+	}
+}
+
+// Suggest 返回以q开头、权重最高的词条，参数limit默认10
+func (h *AutocompleteHandler) Suggest(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	suggestions, err := h.index.Suggest(c.Request.Context(), c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get suggestions: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+// Complete 返回以q开头的词条，按字典序排列，参数limit默认10
+func (h *AutocompleteHandler) Complete(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	terms, err := h.index.Complete(c.Request.Context(), c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"terms": terms})
+}
+
+// Search 模拟一次搜索，参数q，把搜索词的权重加1，热门搜索词在补全结果中排在前面
+func (h *AutocompleteHandler) Search(c *gin.Context) {
+	var req struct {
+		Q string `json:"q" form:"q" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+	h.add(c, req.Q, 1)
+}
+
+// AddTerm 添加词条或累加权重，参数term、weight（默认1）
+func (h *AutocompleteHandler) AddTerm(c *gin.Context) {
+	var req struct {
+		Term   string   `json:"term" form:"term" binding:"required"`
+		Weight *float64 `json:"weight" form:"weight"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+	weight := 1.0
+	if req.Weight != nil {
+		weight = *req.Weight
+	}
+	h.add(c, req.Term, weight)
+}
+
+// add 累加词条的权重并返回新的权重
+func (h *AutocompleteHandler) add(c *gin.Context, term string, weight float64) {
+	score, err := h.index.Add(c.Request.Context(), term, weight)
+	if errors.Is(err, autocomplete.ErrInvalidTerm) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid term"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add term: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"term": term, "score": score})
+}
+
+// RemoveTerm 删除词条
+func (h *AutocompleteHandler) RemoveTerm(c *gin.Context) {
+	if err := h.index.Remove(c.Request.Context(), c.Param("term")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove term: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Term removed"})
+}
+
+// parseLimit 读取limit参数，默认10，不能超过 maxLimit
+func parseLimit(c *gin.Context) (int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > maxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit", "max": maxLimit})
+		return 0, false
+	}
+	return limit, true
+}
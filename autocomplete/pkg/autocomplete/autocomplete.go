@@ -0,0 +1,217 @@
+package autocomplete
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrInvalidTerm 词条为空或过长
+var ErrInvalidTerm = errors.New("autocomplete: invalid term")
+
+// Config 自动补全索引配置
+type Config struct {
+	// Redis中各个key的前缀，完整的前缀为 KeyPrefix + "{索引名}:"
+	KeyPrefix string
+	// 建立索引的最长前缀（字符数），更长的前缀在最长前缀的候选中过滤
+	MaxPrefixLength int
+	// 每个前缀保留的候选词条数，只保留权重最高的，决定了更长前缀过滤时能找到的词条数
+	MaxPerPrefix int
+	// 词条的最大长度（字符数）
+	MaxTermLength int
+}
+
+// DefaultConfig 默认自动补全索引配置
+var DefaultConfig = Config{
+	KeyPrefix:       "ac:",
+	MaxPrefixLength: 10,
+	MaxPerPrefix:    50,
+	MaxTermLength:   64,
+}
+
+// Suggestion 一个补全建议
+type Suggestion struct {
+	Term  string  `json:"term"`
+	Score float64 `json:"score"`
+}
+
+// addScript 累加词条的权重，并把新的权重写入每个前缀的候选集合
+// KEYS[1]: 词条权重集合, KEYS[2]: 字典序集合, KEYS[3..]: 各个前缀的候选集合
+// ARGV: 词条, 权重增量, 每个前缀保留的候选数
+// 返回新的权重
+var addScript = redis.NewScript(`
+local score = redis.call('ZINCRBY', KEYS[1], ARGV[2], ARGV[1])
+redis.call('ZADD', KEYS[2], 0, ARGV[1])
+local keep = tonumber(ARGV[3])
+for i = 3, #KEYS do
+	redis.call('ZADD', KEYS[i], score, ARGV[1])
+	redis.call('ZREMRANGEBYRANK', KEYS[i], 0, -keep - 1)
+end
+return score
+`)
+
+// Index 自动补全索引
+//
+// 每个前缀对应一个ZSET，保存以该前缀开头、权重最高的 MaxPerPrefix 个词条，查询时一次ZREVRANGE取出前n个，
+// 与词条总数无关。词条的权重保存在单独的ZSET中，添加时先累加权重，再把新权重写入各个前缀的候选集合，
+// 被挤出候选集合的词条权重增加后还能以正确的权重重新进入。
+// 另外所有词条以相同的分值保存在一个字典序ZSET中，用ZRANGEBYLEX按字母顺序补全
+type Index struct {
+	client redis.UniversalClient
+	config Config
+	prefix string
+}
+
+// NewIndex 创建名为name的自动补全索引
+func NewIndex(client redis.UniversalClient, name string, config Config) *Index {
+	return &Index{
+		client: client,
+		config: config,
+		prefix: config.KeyPrefix + "{" + name + "}:",
+	}
+}
+
+// NewDefaultIndex 使用默认配置创建自动补全索引
+func NewDefaultIndex(client redis.UniversalClient, name string) *Index {
+	return NewIndex(client, name, DefaultConfig)
+}
+
+// termsKey 所有词条及其权重
+func (idx *Index) termsKey() string {
+	return idx.prefix + "terms"
+}
+
+// lexKey 所有词条，分值都为0，按字典序排列
+func (idx *Index) lexKey() string {
+	return idx.prefix + "lex"
+}
+
+// prefixKey 前缀的候选集合
+func (idx *Index) prefixKey(prefix string) string {
+	return idx.prefix + "p:" + prefix
+}
+
+// prefixKeys 词条所有前缀的候选集合，前缀按字符而不是字节截取
+func (idx *Index) prefixKeys(term string) []string {
+	var keys []string
+	for i := range term {
+		if i == 0 {
+			continue
+		}
+		if len(keys) == idx.config.MaxPrefixLength {
+			return keys
+		}
+		keys = append(keys, idx.prefixKey(term[:i]))
+	}
+	if len(keys) < idx.config.MaxPrefixLength {
+		keys = append(keys, idx.prefixKey(term))
+	}
+	return keys
+}
+
+// Add 为词条累加权重，词条不存在时添加，返回新的权重
+// 可以在每次搜索时调用 Add(term, 1) 按搜索次数排序
+func (idx *Index) Add(ctx context.Context, term string, weight float64) (float64, error) {
+	term = normalize(term)
+	if term == "" || utf8.RuneCountInString(term) > idx.config.MaxTermLength {
+		return 0, ErrInvalidTerm
+	}
+	keys := append([]string{idx.termsKey(), idx.lexKey()}, idx.prefixKeys(term)...)
+	return addScript.Run(ctx, idx.client, keys, term, weight, idx.config.MaxPerPrefix).Float64()
+}
+
+// Remove 删除词条
+// 被挤出候选集合的其他词条不会因此重新进入，前缀的候选数可能暂时少于 MaxPerPrefix，直到这些词条的权重再次增加
+func (idx *Index) Remove(ctx context.Context, term string) error {
+	term = normalize(term)
+	if term == "" {
+		return ErrInvalidTerm
+	}
+	_, err := idx.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, idx.termsKey(), term)
+		pipe.ZRem(ctx, idx.lexKey(), term)
+		for _, key := range idx.prefixKeys(term) {
+			pipe.ZRem(ctx, key, term)
+		}
+		return nil
+	})
+	return err
+}
+
+// Score 返回词条的权重，词条不存在时返回0
+func (idx *Index) Score(ctx context.Context, term string) (float64, error) {
+	score, err := idx.client.ZScore(ctx, idx.termsKey(), normalize(term)).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return score, err
+}
+
+// Suggest 返回以prefix开头、权重最高的limit个词条，按权重从高到低排列
+// prefix超过 MaxPrefixLength 时，从最长前缀的候选中过滤，最多只能找到 MaxPerPrefix 个候选中匹配的词条
+func (idx *Index) Suggest(ctx context.Context, prefix string, limit int) ([]Suggestion, error) {
+	prefix = normalize(prefix)
+	if prefix == "" || limit <= 0 {
+		return []Suggestion{}, nil
+	}
+
+	indexed := prefix
+	if utf8.RuneCountInString(prefix) > idx.config.MaxPrefixLength {
+		indexed = truncate(prefix, idx.config.MaxPrefixLength)
+	}
+	stop := int64(limit - 1)
+	if indexed != prefix {
+		stop = -1
+	}
+
+	members, err := idx.client.ZRevRangeWithScores(ctx, idx.prefixKey(indexed), 0, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]Suggestion, 0, min(len(members), limit))
+	for _, m := range members {
+		term := m.Member.(string)
+		if !strings.HasPrefix(term, prefix) {
+			continue
+		}
+		suggestions = append(suggestions, Suggestion{Term: term, Score: m.Score})
+		if len(suggestions) == limit {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+// Complete 返回以prefix开头的前limit个词条，按字典序排列，不考虑权重
+// 使用ZRANGEBYLEX在所有词条中查找，不受前缀候选数的限制
+func (idx *Index) Complete(ctx context.Context, prefix string, limit int) ([]string, error) {
+	prefix = normalize(prefix)
+	if prefix == "" || limit <= 0 {
+		return []string{}, nil
+	}
+	// UTF-8编码中不会出现0xff，prefix + "\xff" 大于所有以prefix开头的词条
+	return idx.client.ZRangeByLex(ctx, idx.lexKey(), &redis.ZRangeBy{
+		Min:   "[" + prefix,
+		Max:   "[" + prefix + "\xff",
+		Count: int64(limit),
+	}).Result()
+}
+
+// normalize 去掉首尾空白并转换为小写，查询时不区分大小写
+func normalize(term string) string {
+	return strings.ToLower(strings.TrimSpace(term))
+}
+
+// truncate 截取前n个字符
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
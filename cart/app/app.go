@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"cart/internal/handlers"
+	"cart/pkg/cart"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 运行购物车服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("cart")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "cart store name")
+	guestTTL := fs.Duration("guest-ttl", cart.DefaultConfig.GuestTTL, "expiration of guest carts after their last change")
+	userTTL := fs.Duration("user-ttl", cart.DefaultConfig.UserTTL, "expiration of user carts after their last change, 0 to keep forever")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	config := cart.DefaultConfig
+	config.GuestTTL = *guestTTL
+	config.UserTTL = *userTTL
+	store := cart.NewStore(client, *name, config)
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	handlers.NewCartHandler(store).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"cart/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("cart", app.Run)
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"cart/pkg/cart"
+)
+
+// CartHandler 处理购物车相关的HTTP请求
+type CartHandler struct {
+	store *cart.Store
+}
+
+// NewCartHandler 创建一个新的购物车处理器
+func NewCartHandler(store *cart.Store) *CartHandler {
+	return &CartHandler{store: store}
+}
+
+// Setup 设置所有路由，:kind为guest（游客，:id为会话ID）或user（登录用户，:id为用户ID）
+func (h *CartHandler) Setup(router *gin.Engine) {
+	carts := router.Group("/carts/:kind/:id")
+	{
+		// 购物车的内容
+		carts.GET("", h.Get)
+		// 清空购物车
+		carts.DELETE("", h.Clear)
+		// 加购或减少数量
+		carts.POST("/items", h.Add)
+		// 设置数量，可以带上预期的当前数量
+		carts.PUT("/items/:sku", h.SetQuantity)
+		// 删除商品
+		carts.DELETE("/items/:sku", h.Remove)
+		// 登录后合并游客购物车
+		carts.POST("/merge", h.Merge)
+	}
+}
+
+// Get 返回购物车的内容
+func (h *CartHandler) Get(c *gin.Context) {
+	owner, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+	result, err := h.store.Get(c.Request.Context(), owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Clear 清空购物车
+func (h *CartHandler) Clear(c *gin.Context) {
+	owner, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+	if err := h.store.Clear(c.Request.Context(), owner); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear cart: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Add 把商品的数量增加quantity，quantity为负时减少
+func (h *CartHandler) Add(c *gin.Context) {
+	owner, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		SKU      string `json:"sku" form:"sku" binding:"required"`
+		Quantity int64  `json:"quantity" form:"quantity" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	quantity, err := h.store.Add(c.Request.Context(), owner, req.SKU, req.Quantity)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart.Item{SKU: req.SKU, Quantity: quantity})
+}
+
+// SetQuantity 设置商品的数量，0表示删除
+// 带上expected（读取购物车时看到的数量）时，数量已经被其他请求修改则返回409
+func (h *CartHandler) SetQuantity(c *gin.Context) {
+	owner, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Quantity *int64 `json:"quantity" form:"quantity" binding:"required"`
+		Expected *int64 `json:"expected" form:"expected"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	sku := c.Param("sku")
+	quantity, err := h.store.SetQuantity(c.Request.Context(), owner, sku, *req.Quantity, req.Expected)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart.Item{SKU: sku, Quantity: quantity})
+}
+
+// Remove 删除商品
+func (h *CartHandler) Remove(c *gin.Context) {
+	owner, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+	if err := h.store.Remove(c.Request.Context(), owner, c.Param("sku")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Merge 把参数guest指定的游客购物车合并进用户购物车，只能用于 /carts/user/:id
+func (h *CartHandler) Merge(c *gin.Context) {
+	owner, ok := ownerParam(c)
+	if !ok {
+		return
+	}
+	if owner.Guest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Guest carts can only be merged into user carts"})
+		return
+	}
+	var req struct {
+		Guest string `json:"guest" form:"guest" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	result, err := h.store.Merge(c.Request.Context(), req.Guest, owner.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge carts: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ownerParam 从路径参数中解析购物车的所有者，格式错误时返回400
+func ownerParam(c *gin.Context) (cart.Owner, bool) {
+	switch c.Param("kind") {
+	case "guest":
+		return cart.Guest(c.Param("id")), true
+	case "user":
+		return cart.User(c.Param("id")), true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart kind must be guest or user"})
+		return cart.Owner{}, false
+	}
+}
+
+// writeError 把修改数量的错误转换为HTTP响应
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, cart.ErrInvalidQuantity), errors.Is(err, cart.ErrQuantityLimit), errors.Is(err, cart.ErrTooManyItems):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, cart.ErrStale), errors.Is(err, cart.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart: " + err.Error()})
+	}
+}
@@ -0,0 +1,403 @@
+package cart
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrInvalidQuantity 数量为负数，或加购的数量为0
+	ErrInvalidQuantity = errors.New("cart: invalid quantity")
+	// ErrQuantityLimit 商品的数量超过 MaxQuantity
+	ErrQuantityLimit = errors.New("cart: quantity limit exceeded")
+	// ErrTooManyItems 购物车中的商品种类达到 MaxItems
+	ErrTooManyItems = errors.New("cart: too many items")
+	// ErrStale 商品的当前数量与调用方预期的不一致，购物车在调用方读取之后被修改过
+	ErrStale = errors.New("cart: quantity changed since read")
+	// ErrConflict 并发修改过多，乐观锁重试 MaxRetries 次仍然失败
+	ErrConflict = errors.New("cart: too many concurrent updates")
+)
+
+// Config 购物车配置
+type Config struct {
+	// Redis中各个key的前缀，完整的前缀为 KeyPrefix + "名称:"
+	KeyPrefix string
+	// 游客购物车的过期时间，每次修改后重新计算
+	GuestTTL time.Duration
+	// 登录用户购物车的过期时间，每次修改后重新计算，0表示不过期
+	UserTTL time.Duration
+	// 每种商品的最大数量
+	MaxQuantity int64
+	// 购物车中最多的商品种类
+	MaxItems int64
+	// WATCH 事务因为并发修改失败时的最多尝试次数
+	MaxRetries int
+}
+
+// DefaultConfig 默认购物车配置
+var DefaultConfig = Config{
+	KeyPrefix:   "cart:",
+	GuestTTL:    7 * 24 * time.Hour,
+	UserTTL:     90 * 24 * time.Hour,
+	MaxQuantity: 99,
+	MaxItems:    100,
+	MaxRetries:  10,
+}
+
+// Owner 购物车的所有者：未登录的游客以会话ID标识，登录用户以用户ID标识
+type Owner struct {
+	ID    string `json:"id"`
+	Guest bool   `json:"guest"`
+}
+
+// Guest 返回游客的购物车所有者
+func Guest(sessionID string) Owner {
+	return Owner{ID: sessionID, Guest: true}
+}
+
+// User 返回登录用户的购物车所有者
+func User(userID string) Owner {
+	return Owner{ID: userID}
+}
+
+// Item 购物车中的一种商品
+type Item struct {
+	SKU      string `json:"sku"`
+	Quantity int64  `json:"quantity"`
+}
+
+// Cart 购物车的内容
+type Cart struct {
+	Owner Owner  `json:"owner"`
+	Items []Item `json:"items"`
+	// 所有商品的数量之和
+	TotalQuantity int64 `json:"total_quantity"`
+	// 距离过期的秒数，不过期时为0
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// MergeResult 合并游客购物车的结果
+type MergeResult struct {
+	// 合并进用户购物车的商品种类数
+	Merged int64 `json:"merged"`
+	// 用户购物车的商品种类已满而丢弃的商品种类数
+	Dropped int64 `json:"dropped"`
+}
+
+// addScript 原子地增减商品的数量，减到0时删除商品，并刷新购物车的过期时间
+// KEYS: 购物车
+// ARGV: 商品, 增加的数量（可以为负）, 最大数量, 最多商品种类, 过期时间（毫秒，0表示不过期）
+// 返回修改后的数量，超出限制时返回错误
+var addScript = scripts.Register("add", `
+local current = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+local quantity = current + tonumber(ARGV[2])
+if quantity > tonumber(ARGV[3]) then
+	return redis.error_reply('quantity limit exceeded')
+end
+if current == 0 and quantity > 0 and redis.call('HLEN', KEYS[1]) >= tonumber(ARGV[4]) then
+	return redis.error_reply('too many items')
+end
+if quantity <= 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+	quantity = 0
+else
+	redis.call('HSET', KEYS[1], ARGV[1], quantity)
+end
+if tonumber(ARGV[5]) > 0 and redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+end
+return quantity
+`)
+
+// takeGuestScript 把游客购物车转为待合并状态，返回合并ID和待合并的商品
+// 没有进行中的合并时把游客购物车RENAME为待合并的key并记录新的合并ID，之后加购的商品写入新的游客购物车，不会丢失；
+// 已有进行中的合并（上次合并在删除前失败）时返回原来的合并ID和商品，不会再取一次
+// KEYS: 游客购物车, 待合并的商品, 合并ID
+// ARGV: 新的合并ID
+// 返回 {合并ID, 商品, 数量, ...}，没有需要合并的商品时返回空
+var takeGuestScript = scripts.Register("take-guest", `
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	if redis.call('EXISTS', KEYS[1]) == 0 then
+		return {}
+	end
+	redis.call('RENAME', KEYS[1], KEYS[2])
+	redis.call('SET', KEYS[3], ARGV[1])
+	local ttl = redis.call('PTTL', KEYS[2])
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[3], ttl)
+	end
+end
+local id = redis.call('GET', KEYS[3])
+if not id then
+	return {}
+end
+local result = {id}
+for _, v in ipairs(redis.call('HGETALL', KEYS[2])) do
+	result[#result + 1] = v
+end
+return result
+`)
+
+// mergeScript 把游客购物车中的商品合并进用户购物车
+// 同一种商品的数量相加，不超过最大数量；用户购物车的商品种类已满时丢弃游客购物车中的新商品
+// 合并标记与用户购物车在同一个slot，同一个合并ID只合并一次，重试时直接返回第一次的结果
+// KEYS: 用户购物车, 合并标记
+// ARGV: 最大数量, 最多商品种类, 用户购物车的过期时间（毫秒，0表示不过期）, 合并标记的过期时间（毫秒）,
+// 之后依次为游客购物车中的商品和数量
+// 返回 {合并的商品种类数, 丢弃的商品种类数}
+var mergeScript = scripts.Register("merge", `
+local done = redis.call('GET', KEYS[2])
+if done then
+	local merged, dropped = string.match(done, '(%d+):(%d+)')
+	return {tonumber(merged), tonumber(dropped)}
+end
+local maxQuantity = tonumber(ARGV[1])
+local maxItems = tonumber(ARGV[2])
+local merged, dropped = 0, 0
+for i = 5, #ARGV, 2 do
+	local current = tonumber(redis.call('HGET', KEYS[1], ARGV[i]) or '0')
+	if current == 0 and redis.call('HLEN', KEYS[1]) >= maxItems then
+		dropped = dropped + 1
+	else
+		redis.call('HSET', KEYS[1], ARGV[i], math.min(current + tonumber(ARGV[i + 1]), maxQuantity))
+		merged = merged + 1
+	end
+end
+if merged > 0 and tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+redis.call('SET', KEYS[2], merged .. ':' .. dropped, 'PX', ARGV[4])
+return {merged, dropped}
+`)
+
+// Store 基于Redis哈希的购物车存储
+//
+// 每个购物车是一个HASH，字段为商品SKU，值为数量：加购、修改、删除单个商品都是O(1)，读取整个购物车一次 HGETALL。
+// 游客和用户的购物车设置不同的过期时间，每次修改后重新计算，长期不用的购物车由Redis自动删除。
+// 每个购物车以所有者ID作为hash tag，在Redis集群中分散到各个slot；每个脚本和事务都只访问一个购物车
+type Store struct {
+	client redis.UniversalClient
+	config Config
+	prefix string
+}
+
+// NewStore 创建名为name的购物车存储
+func NewStore(client redis.UniversalClient, name string, config Config) *Store {
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = DefaultConfig.MaxRetries
+	}
+	return &Store{
+		client: client,
+		config: config,
+		prefix: config.KeyPrefix + name + ":",
+	}
+}
+
+// NewDefaultStore 使用默认配置创建购物车存储
+func NewDefaultStore(client redis.UniversalClient, name string) *Store {
+	return NewStore(client, name, DefaultConfig)
+}
+
+// key 返回购物车的key，所有者ID作为hash tag
+func (s *Store) key(owner Owner) string {
+	if owner.Guest {
+		return s.prefix + "guest:{" + owner.ID + "}"
+	}
+	return s.prefix + "user:{" + owner.ID + "}"
+}
+
+// ttl 返回购物车的过期时间
+func (s *Store) ttl(owner Owner) time.Duration {
+	if owner.Guest {
+		return s.config.GuestTTL
+	}
+	return s.config.UserTTL
+}
+
+// Add 把商品的数量增加delta并返回新的数量，delta为负时减少，减到0时从购物车中删除
+// 读取、计算和写入在Lua脚本中原子完成，并发加购不会丢失
+func (s *Store) Add(ctx context.Context, owner Owner, sku string, delta int64) (int64, error) {
+	if delta == 0 {
+		return 0, ErrInvalidQuantity
+	}
+	quantity, err := addScript.Run(ctx, s.client, []string{s.key(owner)},
+		sku, delta, s.config.MaxQuantity, s.config.MaxItems, s.ttl(owner).Milliseconds()).Int64()
+	switch {
+	case err == nil:
+		return quantity, nil
+	case strings.Contains(err.Error(), "quantity limit exceeded"):
+		return 0, ErrQuantityLimit
+	case strings.Contains(err.Error(), "too many items"):
+		return 0, ErrTooManyItems
+	default:
+		return 0, err
+	}
+}
+
+// Update 用乐观锁修改商品的数量：WATCH 购物车后读取当前数量，由fn计算新的数量，在 MULTI/EXEC 中写入
+// 读取之后购物车被其他请求修改时 EXEC 失败，重新读取并调用fn，最多尝试 MaxRetries 次，仍然失败时返回 ErrConflict。
+// fn返回错误时不修改购物车并返回该错误；新的数量为0时删除商品
+func (s *Store) Update(ctx context.Context, owner Owner, sku string, fn func(current int64) (int64, error)) (int64, error) {
+	key := s.key(owner)
+	var quantity int64
+	txf := func(tx *redis.Tx) error {
+		current, err := tx.HGet(ctx, key, sku).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		quantity, err = fn(current)
+		if err != nil {
+			return err
+		}
+		if quantity < 0 {
+			return ErrInvalidQuantity
+		}
+		if quantity > s.config.MaxQuantity {
+			return ErrQuantityLimit
+		}
+		if current == 0 && quantity > 0 {
+			n, err := tx.HLen(ctx, key).Result()
+			if err != nil {
+				return err
+			}
+			if n >= s.config.MaxItems {
+				return ErrTooManyItems
+			}
+		}
+
+		// 只有WATCH之后没有被修改过时，事务中的命令才会执行
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if quantity == 0 {
+				pipe.HDel(ctx, key, sku)
+			} else {
+				pipe.HSet(ctx, key, sku, quantity)
+			}
+			if ttl := s.ttl(owner); ttl > 0 {
+				pipe.PExpire(ctx, key, ttl)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < s.config.MaxRetries; i++ {
+		err := s.client.Watch(ctx, txf, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			if err != nil {
+				return 0, err
+			}
+			return quantity, nil
+		}
+	}
+	return 0, ErrConflict
+}
+
+// SetQuantity 把商品的数量设置为quantity，0表示删除
+// expected不为nil时只有当前数量等于*expected才修改，否则返回 ErrStale：
+// 调用方读取购物车后把数量从3改成5，期间另一个页面已经把它改成了4，直接覆盖会丢失对方的修改
+func (s *Store) SetQuantity(ctx context.Context, owner Owner, sku string, quantity int64, expected *int64) (int64, error) {
+	return s.Update(ctx, owner, sku, func(current int64) (int64, error) {
+		if expected != nil && current != *expected {
+			return 0, ErrStale
+		}
+		return quantity, nil
+	})
+}
+
+// Remove 从购物车中删除商品
+func (s *Store) Remove(ctx context.Context, owner Owner, sku string) error {
+	return s.client.HDel(ctx, s.key(owner), sku).Err()
+}
+
+// Clear 清空购物车
+func (s *Store) Clear(ctx context.Context, owner Owner) error {
+	return s.client.Del(ctx, s.key(owner)).Err()
+}
+
+// Get 返回购物车的内容，商品按SKU排序；购物车不存在时返回空的购物车
+func (s *Store) Get(ctx context.Context, owner Owner) (Cart, error) {
+	key := s.key(owner)
+	var fields *redis.MapStringStringCmd
+	var ttl *redis.DurationCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		fields = pipe.HGetAll(ctx, key)
+		ttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return Cart{}, err
+	}
+
+	cart := Cart{Owner: owner, Items: make([]Item, 0, len(fields.Val()))}
+	for sku, value := range fields.Val() {
+		quantity, _ := strconv.ParseInt(value, 10, 64)
+		cart.Items = append(cart.Items, Item{SKU: sku, Quantity: quantity})
+		cart.TotalQuantity += quantity
+	}
+	sort.Slice(cart.Items, func(i, j int) bool { return cart.Items[i].SKU < cart.Items[j].SKU })
+	// 没有过期时间时PTTL返回-1，key不存在时返回-2
+	if ttl.Val() > 0 {
+		cart.ExpiresIn = int64(ttl.Val().Seconds())
+	}
+	return cart, nil
+}
+
+// Merge 用户登录后把游客购物车合并进用户的购物车，并删除游客购物车
+// 两个购物车在集群中可能位于不同的slot，合并分三步，每一步只访问一个slot：
+// 先把游客购物车原子地转为待合并状态并分配合并ID，再用只访问用户slot的脚本合并并写入合并标记，最后删除待合并的商品。
+// 转为待合并之后加购的商品留在新的游客购物车中；任何一步失败后重试都使用同一个合并ID，合并标记保证商品只加一次
+func (s *Store) Merge(ctx context.Context, sessionID, userID string) (MergeResult, error) {
+	guestKey := s.key(Guest(sessionID))
+	pendingKey, idKey := guestKey+":merging", guestKey+":merge-id"
+	id, err := newMergeID()
+	if err != nil {
+		return MergeResult{}, err
+	}
+	taken, err := takeGuestScript.Run(ctx, s.client, []string{guestKey, pendingKey, idKey}, id).StringSlice()
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if len(taken) == 0 {
+		return MergeResult{}, nil
+	}
+
+	id = taken[0]
+	args := []interface{}{s.config.MaxQuantity, s.config.MaxItems, s.config.UserTTL.Milliseconds(), s.mergeMarkerTTL().Milliseconds()}
+	for _, v := range taken[1:] {
+		args = append(args, v)
+	}
+	markerKey := s.key(User(userID)) + ":merged:" + id
+	counts, err := mergeScript.Run(ctx, s.client, []string{s.key(User(userID)), markerKey}, args...).Int64Slice()
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if err := s.client.Del(ctx, pendingKey, idKey).Err(); err != nil {
+		return MergeResult{}, err
+	}
+	return MergeResult{Merged: counts[0], Dropped: counts[1]}, nil
+}
+
+// mergeMarkerTTL 返回合并标记的过期时间，与游客购物车相同，待合并的商品过期之前重试时标记仍然存在
+func (s *Store) mergeMarkerTTL() time.Duration {
+	if s.config.GuestTTL > 0 {
+		return s.config.GuestTTL
+	}
+	return DefaultConfig.GuestTTL
+}
+
+// newMergeID 生成随机的合并ID
+func newMergeID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"checkin/internal/handlers"
+	"checkin/pkg/checkin"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 运行签到服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("checkin")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "tracker name")
+	seed := fs.Bool("seed", true, "generate check-in history for user \"demo\" over the last 60 days")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	tracker := checkin.NewDefaultTracker(client, *name)
+	if *seed {
+		// 最近7天连续签到，更早的日期随机签到
+		now := time.Now()
+		for i := 1; i <= 60; i++ {
+			if i <= 7 || rand.IntN(3) > 0 {
+				if _, err := tracker.CheckIn(ctx, "demo", now.AddDate(0, 0, -i)); err != nil {
+					return fmt.Errorf("failed to generate check-in history: %w", err)
+				}
+			}
+		}
+		log.Println("Generated check-in history for user demo")
+	}
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	handlers.NewCheckInHandler(tracker).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"checkin/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("checkin", app.Run)
+}
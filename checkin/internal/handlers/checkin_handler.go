@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"checkin/pkg/checkin"
+)
+
+// CheckInHandler 处理签到相关的HTTP请求
+type CheckInHandler struct {
+	tracker  *checkin.Tracker
+	location *time.Location
+}
+
+// NewCheckInHandler 创建一个新的签到处理器，请求中的日期按签到记录的时区解析
+func NewCheckInHandler(tracker *checkin.Tracker) *CheckInHandler {
+	return &CheckInHandler{tracker: tracker, location: tracker.Location()}
+}
+
+// Setup 设置所有路由
+func (h *CheckInHandler) Setup(router *gin.Engine) {
+	api := router.Group("/checkin/:user")
+	{
+		// 今天签到
+		api.POST("", h.CheckIn)
+		// 某一天是否签到
+		api.GET("/status", h.GetStatus)
+		// 连续签到天数
+		api.GET("/streak", h.GetStreak)
+		// 签到日历
+		api.GET("/calendar", h.GetCalendar)
+	}
+}
+
+// CheckIn 今天签到，返回是否重复签到、连续签到天数和本月签到天数
+func (h *CheckInHandler) CheckIn(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := c.Param("user")
+	now := time.Now().In(h.location)
+
+	already, err := h.tracker.CheckIn(ctx, user, now)
+	if errors.Is(err, checkin.ErrInvalidUser) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in: " + err.Error()})
+		return
+	}
+	streak, err := h.tracker.Streak(ctx, user, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get streak: " + err.Error()})
+		return
+	}
+	total, err := h.tracker.MonthCount(ctx, user, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get month count: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":               now.Format(time.DateOnly),
+		"already_checked_in": already,
+		"streak":             streak,
+		"month_total":        total,
+	})
+}
+
+// GetStatus 某一天是否签到，参数date格式为2006-01-02，默认今天
+func (h *CheckInHandler) GetStatus(c *gin.Context) {
+	day, ok := h.parseDate(c, "date", time.DateOnly)
+	if !ok {
+		return
+	}
+	checked, err := h.tracker.IsCheckedIn(c.Request.Context(), c.Param("user"), day)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get status: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"date": day.Format(time.DateOnly), "checked_in": checked})
+}
+
+// GetStreak 截止到今天的连续签到天数
+func (h *CheckInHandler) GetStreak(c *gin.Context) {
+	streak, err := h.tracker.Streak(c.Request.Context(), c.Param("user"), time.Now().In(h.location))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get streak: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"streak": streak})
+}
+
+// GetCalendar 签到日历，参数month格式为2006-01，默认本月
+func (h *CheckInHandler) GetCalendar(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := c.Param("user")
+	month, ok := h.parseDate(c, "month", "2006-01")
+	if !ok {
+		return
+	}
+
+	calendar, err := h.tracker.Calendar(ctx, user, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get calendar: " + err.Error()})
+		return
+	}
+	first, err := h.tracker.FirstCheckIn(ctx, user, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get first check-in: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"month":     calendar.Month,
+		"days":      calendar.Days,
+		"total":     calendar.Total,
+		"first_day": first,
+	})
+}
+
+// parseDate 按layout解析查询参数，参数为空时返回当前时间，格式错误时返回400
+func (h *CheckInHandler) parseDate(c *gin.Context, param, layout string) (time.Time, bool) {
+	value := c.Query(param)
+	if value == "" {
+		return time.Now().In(h.location), true
+	}
+	t, err := time.ParseInLocation(layout, value, h.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected format " + layout})
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,197 @@
+package checkin
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrInvalidUser 用户ID为空
+var ErrInvalidUser = errors.New("checkin: empty user id")
+
+// Config 签到配置
+type Config struct {
+	// Redis中key的前缀，完整的key为 KeyPrefix + 名称 + ":{用户ID}:" + 年月
+	KeyPrefix string
+	// 计算日期使用的时区，决定了一天从什么时候开始
+	Location *time.Location
+	// 计算连续签到天数时最多向前查询的月数
+	MaxStreakMonths int
+}
+
+// DefaultConfig 默认签到配置
+var DefaultConfig = Config{
+	KeyPrefix:       "checkin:",
+	Location:        time.Local,
+	MaxStreakMonths: 12,
+}
+
+// Day 日历中的一天
+type Day struct {
+	Day       int  `json:"day"`
+	CheckedIn bool `json:"checked_in"`
+}
+
+// Calendar 用户一个月的签到日历
+type Calendar struct {
+	// 年月，格式为2006-01
+	Month string `json:"month"`
+	Days  []Day  `json:"days"`
+	// 本月签到天数
+	Total int `json:"total"`
+}
+
+// Tracker 基于位图的签到记录
+//
+// 每个用户每个月一个位图，第n天签到时把第n-1位置为1，一个月的记录最多占用4个字节。
+// 用户的key使用 {用户ID} 作为hash tag，同一个用户各个月份的位图在Redis集群中位于同一个slot
+type Tracker struct {
+	client redis.UniversalClient
+	config Config
+	prefix string
+}
+
+// NewTracker 创建名为name的签到记录
+func NewTracker(client redis.UniversalClient, name string, config Config) *Tracker {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.MaxStreakMonths <= 0 {
+		config.MaxStreakMonths = DefaultConfig.MaxStreakMonths
+	}
+	return &Tracker{
+		client: client,
+		config: config,
+		prefix: config.KeyPrefix + name + ":",
+	}
+}
+
+// NewDefaultTracker 使用默认配置创建签到记录
+func NewDefaultTracker(client redis.UniversalClient, name string) *Tracker {
+	return NewTracker(client, name, DefaultConfig)
+}
+
+// Location 计算日期使用的时区
+func (t *Tracker) Location() *time.Location {
+	return t.config.Location
+}
+
+// key 用户某个月的签到位图
+func (t *Tracker) key(user string, month time.Time) string {
+	return t.prefix + "{" + user + "}:" + month.Format("200601")
+}
+
+// CheckIn 用户在day这一天签到，返回这一天之前是否已经签到过
+func (t *Tracker) CheckIn(ctx context.Context, user string, day time.Time) (bool, error) {
+	if user == "" {
+		return false, ErrInvalidUser
+	}
+	day = day.In(t.config.Location)
+	old, err := t.client.SetBit(ctx, t.key(user, day), int64(day.Day()-1), 1).Result()
+	if err != nil {
+		return false, err
+	}
+	return old == 1, nil
+}
+
+// IsCheckedIn 用户在day这一天是否签到
+func (t *Tracker) IsCheckedIn(ctx context.Context, user string, day time.Time) (bool, error) {
+	day = day.In(t.config.Location)
+	bit, err := t.client.GetBit(ctx, t.key(user, day), int64(day.Day()-1)).Result()
+	if err != nil {
+		return false, err
+	}
+	return bit == 1, nil
+}
+
+// MonthCount 用户在month所在月份的签到天数（BITCOUNT）
+func (t *Tracker) MonthCount(ctx context.Context, user string, month time.Time) (int64, error) {
+	return t.client.BitCount(ctx, t.key(user, month.In(t.config.Location)), nil).Result()
+}
+
+// FirstCheckIn 用户在month所在月份第一次签到是几号（BITPOS），本月没有签到时返回0
+func (t *Tracker) FirstCheckIn(ctx context.Context, user string, month time.Time) (int, error) {
+	pos, err := t.client.BitPos(ctx, t.key(user, month.In(t.config.Location)), 1).Result()
+	if err != nil {
+		return 0, err
+	}
+	if pos < 0 {
+		return 0, nil
+	}
+	return int(pos) + 1, nil
+}
+
+// Calendar 用户在month所在月份的签到日历
+func (t *Tracker) Calendar(ctx context.Context, user string, month time.Time) (Calendar, error) {
+	month = month.In(t.config.Location)
+	n := daysIn(month)
+	v, err := t.monthBits(ctx, user, month, n)
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	cal := Calendar{
+		Month: month.Format("2006-01"),
+		Days:  make([]Day, n),
+		Total: bits.OnesCount64(v),
+	}
+	for i := range cal.Days {
+		cal.Days[i] = Day{Day: i + 1, CheckedIn: v>>(n-1-i)&1 == 1}
+	}
+	return cal, nil
+}
+
+// Streak 截止到day的连续签到天数
+// day当天还没有签到时从前一天开始计算，当天没有签到不算中断；最多向前查询 MaxStreakMonths 个月
+func (t *Tracker) Streak(ctx context.Context, user string, day time.Time) (int, error) {
+	day = day.In(t.config.Location)
+	streak := 0
+	today := true
+	for i := 0; i < t.config.MaxStreakMonths; i++ {
+		n := day.Day()
+		v, err := t.monthBits(ctx, user, day, n)
+		if err != nil {
+			return 0, err
+		}
+		if today {
+			today = false
+			if v&1 == 0 {
+				// 当天还没有签到，从前一天开始
+				if n == 1 {
+					day = day.AddDate(0, 0, -1)
+					continue
+				}
+				v >>= 1
+				n--
+			}
+		}
+
+		// 从最后一天往前数连续的1
+		ones := min(bits.TrailingZeros64(^v), n)
+		streak += ones
+		if ones < n {
+			return streak, nil
+		}
+		// 整个月都签到了，继续查询上个月的最后一天
+		day = time.Date(day.Year(), day.Month(), 0, 0, 0, 0, 0, t.config.Location)
+	}
+	return streak, nil
+}
+
+// monthBits 用BITFIELD读取month所在月份前n天的签到记录，第1天在最高位，第n天在最低位
+func (t *Tracker) monthBits(ctx context.Context, user string, month time.Time, n int) (uint64, error) {
+	values, err := t.client.BitField(ctx, t.key(user, month), "GET", "u"+strconv.Itoa(n), 0).Result()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(values[0]), nil
+}
+
+// daysIn 返回t所在月份的天数
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
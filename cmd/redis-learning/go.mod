@@ -0,0 +1,98 @@
+module redis-learning/cmd/redis-learning
+
+go 1.23.5
+
+require (
+	analyzer v0.0.0
+	autocomplete v0.0.0
+	cart v0.0.0
+	checkin v0.0.0
+	delay-queue v0.0.0
+	distributed-lock v0.0.0
+	feed v0.0.0
+	geo v0.0.0
+	idempotency v0.0.0
+	idgen v0.0.0
+	leaderboard v0.0.0
+	message-queue v0.0.0
+	multi-level-cache v0.0.0
+	ranking v0.0.0
+	rate-limit v0.0.0
+	read-write-splitting v0.0.0
+	redis-learning v0.0.0
+	scheduler v0.0.0
+	session v0.0.0
+	social v0.0.0
+	uv-pv-collector v0.0.0
+)
+
+require (
+	github.com/alicebob/miniredis/v2 v2.35.0 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/bytedance/sonic v1.11.6 // indirect
+	github.com/bytedance/sonic/loader v0.1.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/cloudwego/base64x v0.1.4 // indirect
+	github.com/cloudwego/iasm v0.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
+	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/gin-gonic/gin v1.10.0 // indirect
+	github.com/go-playground/locales v0.14.1 // indirect
+	github.com/go-playground/universal-translator v0.18.1 // indirect
+	github.com/go-playground/validator/v10 v10.20.0 // indirect
+	github.com/goccy/go-json v0.10.2 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.7 // indirect
+	github.com/leodido/go-urn v1.4.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
+	github.com/pelletier/go-toml/v2 v2.2.2 // indirect
+	github.com/prometheus/client_golang v1.20.5 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	github.com/redis/go-redis/v9 v9.7.3 // indirect
+	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
+	github.com/ugorji/go/codec v1.2.12 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/arch v0.8.0 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sync v0.9.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	golang.org/x/time v0.11.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace (
+	analyzer => ../../analyzer
+	autocomplete => ../../autocomplete
+	cart => ../../cart
+	checkin => ../../checkin
+	delay-queue => ../../delay-queue
+	distributed-lock => ../../distributed-lock
+	feed => ../../feed
+	geo => ../../geo
+	idempotency => ../../idempotency
+	idgen => ../../idgen
+	leaderboard => ../../leaderboard
+	message-queue => ../../message-queue
+	multi-level-cache => ../../multi-level-cache
+	ranking => ../../ranking
+	rate-limit => ../../rate-limit
+	read-write-splitting => ../../read-write-splitting
+	redis-learning => ../../
+	scheduler => ../../scheduler
+	session => ../../session
+	social => ../../social
+	uv-pv-collector => ../../uv-pv-collector
+)
@@ -0,0 +1,56 @@
+package main
+
+import (
+	analyzer "analyzer/app"
+	autocomplete "autocomplete/app"
+	cart "cart/app"
+	checkin "checkin/app"
+	delayqueue "delay-queue/app"
+	lock "distributed-lock/app"
+	feed "feed/app"
+	geo "geo/app"
+	idempotency "idempotency/app"
+	idgen "idgen/app"
+	leaderboard "leaderboard/app"
+	mq "message-queue/app"
+	cache "multi-level-cache/app"
+	ranking "ranking/app"
+	ratelimit "rate-limit/app"
+	proxy "read-write-splitting/app"
+	scheduler "scheduler/app"
+	session "session/app"
+	social "social/app"
+	uvpv "uv-pv-collector/app"
+
+	"redis-learning/pkg/cli"
+)
+
+// commands 各模块的程序，参数与模块自己的 cmd/main.go 相同
+var commands = []cli.Command{
+	{Name: "proxy", Summary: "read/write splitting proxy demo", Run: proxy.Run},
+	{Name: "uvpv", Summary: "UV/PV collector HTTP server", Run: uvpv.Run},
+	{Name: "cache-demo", Summary: "multi-level cache demo", Run: cache.Run},
+	{Name: "ratelimit", Summary: "hot key detection and rate limiting server, 'ratelimit loadgen' generates load", Run: ratelimit.Run},
+	{Name: "lock-demo", Summary: "distributed lock demo with competing processes", Run: lock.Run},
+	{Name: "queue-demo", Summary: "delay queue demo with retries and dead letters", Run: delayqueue.Run},
+	{Name: "mq-demo", Summary: "stream consumer group demo", Run: mq.Run},
+	{Name: "scheduler", Summary: "distributed cron scheduler demo", Run: scheduler.Run},
+	{Name: "leaderboard", Summary: "leaderboard HTTP server", Run: leaderboard.Run},
+	{Name: "session", Summary: "session store HTTP server", Run: session.Run},
+	{Name: "geo", Summary: "nearby places HTTP server", Run: geo.Run},
+	{Name: "idgen", Summary: "segment and snowflake id generator benchmark", Run: idgen.Run},
+	{Name: "social", Summary: "follow relationship HTTP server", Run: social.Run},
+	{Name: "autocomplete", Summary: "search autocomplete HTTP server", Run: autocomplete.Run},
+	{Name: "checkin", Summary: "daily check-in HTTP server", Run: checkin.Run},
+	{Name: "idempotency", Summary: "idempotent order HTTP server", Run: idempotency.Run},
+	{Name: "ranking", Summary: "time-decayed hot ranking HTTP server", Run: ranking.Run},
+	{Name: "feed", Summary: "push/pull/hybrid timeline HTTP server", Run: feed.Run},
+	{Name: "cart", Summary: "shopping cart HTTP server", Run: cart.Run},
+	{Name: "analyzer", Summary: "keyspace analyzer and big key scanner", Run: analyzer.Run},
+}
+
+// 统一的命令行入口：redis-learning <command> [flags]
+// 所有程序共用参数解析、日志格式和收到中断信号后的优雅退出
+func main() {
+	cli.Dispatch("redis-learning", commands)
+}
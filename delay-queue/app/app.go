@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"delay-queue/pkg/delayqueue"
+
+	"github.com/redis/go-redis/v9"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 演示延迟队列：生产者加入若干在随机时间后到期的任务，一个轮询协程把到期任务移到就绪列表，
+// 多个消费者竞争领取任务，处理时按一定概率失败以展示重试和死信列表
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("queue-demo")
+	addr := fs.String("addr", "localhost:6379", "Redis address")
+	queueName := fs.String("queue", "demo", "queue name")
+	jobs := fs.Int("jobs", 10, "number of jobs to push")
+	maxDelay := fs.Duration("max-delay", 5*time.Second, "jobs are delayed by a random duration up to this value")
+	consumers := fs.Int("consumers", 2, "number of competing consumers")
+	failRate := fs.Float64("fail-rate", 0.3, "probability that handling a job fails")
+	timeout := fs.Duration("timeout", time.Minute, "give up if jobs are not finished within this duration")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: *addr})
+	defer client.Close()
+
+	config := delayqueue.DefaultConfig
+	config.PollInterval = 100 * time.Millisecond
+	config.RetryDelay = time.Second
+	config.VisibilityTimeout = 5 * time.Second
+	queue := delayqueue.NewQueue(client, *queueName, config)
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	// 生产者：记录每个任务预期的到期时间
+	start := time.Now()
+	dueAt := make(map[string]time.Duration)
+	fmt.Printf("===== Pushing %d jobs =====\n", *jobs)
+	for i := 0; i < *jobs; i++ {
+		delay := rand.N(*maxDelay)
+		payload := fmt.Sprintf("order-%d", i+1)
+		id, err := queue.Push(ctx, []byte(payload), delay)
+		if err != nil {
+			return fmt.Errorf("failed to push job: %w", err)
+		}
+		dueAt[id] = delay
+		fmt.Printf("pushed %s (%s) delay=%v\n", payload, id, delay.Round(time.Millisecond))
+	}
+
+	go queue.RunPoller(ctx)
+
+	// 消费者：每个任务确认或进入死信列表后计数，全部结束时停止
+	var finished atomic.Int64
+	consumeCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var wg sync.WaitGroup
+	fmt.Printf("\n===== Consuming with %d consumers =====\n", *consumers)
+	for c := 1; c <= *consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queue.Consume(consumeCtx, func(ctx context.Context, job *delayqueue.Job) error {
+				elapsed := time.Since(start)
+				if rand.Float64() < *failRate {
+					fmt.Printf("[consumer %d] %s attempt %d at %v (due %v): failed\n", c, job.Payload, job.Attempts,
+						elapsed.Round(time.Millisecond), dueAt[job.ID].Round(time.Millisecond))
+					if job.Attempts >= config.MaxAttempts {
+						finished.Add(1)
+					}
+					return errors.New("simulated failure")
+				}
+				fmt.Printf("[consumer %d] %s attempt %d at %v (due %v): done\n", c, job.Payload, job.Attempts,
+					elapsed.Round(time.Millisecond), dueAt[job.ID].Round(time.Millisecond))
+				finished.Add(1)
+				return nil
+			})
+		}()
+	}
+
+	for finished.Load() < int64(*jobs) && ctx.Err() == nil {
+		time.Sleep(100 * time.Millisecond)
+	}
+	stop()
+	wg.Wait()
+
+	stats, err := queue.Stats(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read stats: %w", err)
+	}
+	fmt.Printf("\n===== Result =====\ndelayed=%d ready=%d processing=%d dead=%d\n",
+		stats.Delayed, stats.Ready, stats.Processing, stats.Dead)
+
+	dead, err := queue.DeadLetters(context.Background(), 100)
+	if err != nil {
+		return fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	payloads := make([]string, len(dead))
+	for i, job := range dead {
+		payloads[i] = string(job.Payload)
+	}
+	fmt.Printf("dead letters: [%s]\n", strings.Join(payloads, ","))
+	return nil
+}
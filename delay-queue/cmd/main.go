@@ -0,0 +1,11 @@
+package main
+
+import (
+	"delay-queue/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("queue-demo", app.Run)
+}
@@ -0,0 +1,121 @@
+package delayqueue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Handler 处理一个任务，返回nil时确认任务，返回错误时按重试次数重新投递或移入死信列表
+type Handler func(ctx context.Context, job *Job) error
+
+// Claim 领取一个就绪的任务，没有就绪任务时返回nil
+// 领取后任务在 VisibilityTimeout 内对其他消费者不可见，处理完必须调用 Ack 或 Retry，超时后任务会被重新投递
+func (q *Queue) Claim(ctx context.Context) (*Job, error) {
+	token, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(q.config.VisibilityTimeout).UnixMilli()
+	result, err := claimScript.Run(ctx, q.client,
+		[]string{q.readyKey, q.processingKey, q.attemptsKey, q.claimsKey, q.payloadsKey},
+		deadline, token).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{
+		ID:       result[0].(string),
+		Payload:  []byte(result[1].(string)),
+		Attempts: int(result[2].(int64)),
+		token:    token,
+	}, nil
+}
+
+// Ack 确认任务处理完成并删除它，任务已经被重新投递时返回 ErrJobLost
+func (q *Queue) Ack(ctx context.Context, job *Job) error {
+	acked, err := ackScript.Run(ctx, q.client,
+		[]string{q.processingKey, q.payloadsKey, q.attemptsKey, q.claimsKey},
+		job.ID, job.token).Int()
+	if err != nil {
+		return err
+	}
+	if acked == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// Retry 放弃这次处理，在delay后重新投递；投递次数达到 MaxAttempts 时移入死信列表，返回值为false
+// 任务已经被重新投递时返回 ErrJobLost
+func (q *Queue) Retry(ctx context.Context, job *Job, delay time.Duration) (bool, error) {
+	retried, err := retryScript.Run(ctx, q.client,
+		[]string{q.processingKey, q.delayedKey, q.deadKey, q.attemptsKey, q.claimsKey},
+		job.ID, job.token, time.Now().UnixMilli(), delay.Milliseconds(), q.config.MaxAttempts).Int()
+	if err != nil {
+		return false, err
+	}
+	if retried < 0 {
+		return false, ErrJobLost
+	}
+	return retried == 1, nil
+}
+
+// Touch 把任务的可见性超时重新设置为 VisibilityTimeout，用于处理时间较长的任务
+// 任务已经被重新投递时返回 ErrJobLost
+func (q *Queue) Touch(ctx context.Context, job *Job) error {
+	deadline := time.Now().Add(q.config.VisibilityTimeout).UnixMilli()
+	touched, err := touchScript.Run(ctx, q.client,
+		[]string{q.processingKey, q.claimsKey}, job.ID, job.token, deadline).Int()
+	if err != nil {
+		return err
+	}
+	if touched == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// Consume 循环领取并处理任务，直到ctx结束；没有就绪任务时等待 PollInterval
+// handler返回nil时确认任务，返回错误时在 RetryDelay 后重新投递；可以在多个协程或进程中同时运行
+func (q *Queue) Consume(ctx context.Context, handler Handler) error {
+	for {
+		job, err := q.Claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error claiming job from delay queue %s: %v", q.name, err)
+		}
+		if job == nil {
+			select {
+			case <-time.After(q.config.PollInterval):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		// 确认和重试不受ctx结束的影响，避免处理完的任务因为退出而被重复投递
+		finishCtx := context.WithoutCancel(ctx)
+		if err := handler(ctx, job); err != nil {
+			retried, retryErr := q.Retry(finishCtx, job, q.config.RetryDelay)
+			switch {
+			case retryErr != nil:
+				log.Printf("Error retrying job %s: %v", job.ID, retryErr)
+			case retried:
+				log.Printf("Job %s failed (attempt %d), will retry: %v", job.ID, job.Attempts, err)
+			default:
+				log.Printf("Job %s failed after %d attempts, moved to dead letters: %v", job.ID, job.Attempts, err)
+			}
+			continue
+		}
+		if err := q.Ack(finishCtx, job); err != nil {
+			log.Printf("Error acknowledging job %s: %v", job.ID, err)
+		}
+	}
+}
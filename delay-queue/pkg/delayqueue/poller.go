@@ -0,0 +1,49 @@
+package delayqueue
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Poll 执行一次轮询：把到期的延迟任务移到就绪列表，并重新投递可见性超时的任务
+// 返回移动的到期任务数和超时任务数，每类最多 BatchSize 个
+func (q *Queue) Poll(ctx context.Context) (due, expired int, err error) {
+	result, err := pollScript.Run(ctx, q.client,
+		[]string{q.delayedKey, q.readyKey, q.processingKey, q.deadKey, q.attemptsKey, q.claimsKey},
+		time.Now().UnixMilli(), q.config.BatchSize, q.config.MaxAttempts, q.config.RetryDelay.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(result[0]), int(result[1]), nil
+}
+
+// RunPoller 每隔 PollInterval 轮询一次，直到ctx结束
+// 一次移动的任务数达到 BatchSize 时立即再次轮询，积压的任务不必等下一个间隔；多个实例可以同时运行
+func (q *Queue) RunPoller(ctx context.Context) {
+	ticker := time.NewTicker(q.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		due, expired, err := q.Poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Error polling delay queue %s: %v", q.name, err)
+		}
+		if expired > 0 {
+			log.Printf("Delay queue %s: %d jobs exceeded visibility timeout", q.name, expired)
+		}
+		if due >= q.config.BatchSize || expired >= q.config.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
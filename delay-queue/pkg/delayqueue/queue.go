@@ -0,0 +1,207 @@
+package delayqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrJobLost 任务不再由这次领取持有：可见性超时后已经被重新投递，或者已经被确认
+var ErrJobLost = errors.New("delayqueue: job lost")
+
+// Config 延迟队列配置
+type Config struct {
+	// Redis中队列各个key的前缀，完整的前缀为 KeyPrefix + "{队列名}:"，同一个队列的key在集群中位于同一个slot
+	KeyPrefix string
+	// 领取任务后的可见性超时，超时没有确认的任务视为消费者崩溃，重新投递
+	VisibilityTimeout time.Duration
+	// 每个任务最多投递的次数，达到后失败的任务移入死信列表
+	MaxAttempts int
+	// 任务处理失败或可见性超时后，重新投递前等待的时间
+	RetryDelay time.Duration
+	// 轮询到期任务、消费者在没有就绪任务时等待的间隔
+	PollInterval time.Duration
+	// 每次轮询最多移动的任务数
+	BatchSize int
+}
+
+// DefaultConfig 默认延迟队列配置
+var DefaultConfig = Config{
+	KeyPrefix:         "dq:",
+	VisibilityTimeout: 30 * time.Second,
+	MaxAttempts:       3,
+	RetryDelay:        5 * time.Second,
+	PollInterval:      time.Second,
+	BatchSize:         100,
+}
+
+// Job 队列中的任务
+type Job struct {
+	ID      string
+	Payload []byte
+	// 第几次投递，第一次领取时为1
+	Attempts int
+	// 这次领取的token，确认和重试时用于判断任务是否仍由这次领取持有
+	token string
+}
+
+// Stats 队列中各状态的任务数
+type Stats struct {
+	Delayed    int64 `json:"delayed"`
+	Ready      int64 `json:"ready"`
+	Processing int64 `json:"processing"`
+	Dead       int64 `json:"dead"`
+}
+
+// Queue 基于有序集合的延迟队列
+//
+// Redis中的数据结构（省略前缀）：
+//   - delayed：ZSET，成员为任务ID，分数为到期时间（毫秒）
+//   - ready：LIST，已经到期、等待领取的任务ID
+//   - processing：ZSET，已经被领取的任务ID，分数为可见性超时的时间（毫秒）
+//   - dead：LIST，投递次数用完仍然失败的任务ID
+//   - payloads / attempts / claims：HASH，任务的内容、投递次数和当前领取的token
+//
+// 任务在这些结构之间的移动都由Lua脚本完成，多个生产者、消费者和轮询者可以同时运行
+type Queue struct {
+	client redis.UniversalClient
+	config Config
+	name   string
+
+	delayedKey    string
+	readyKey      string
+	processingKey string
+	deadKey       string
+	payloadsKey   string
+	attemptsKey   string
+	claimsKey     string
+}
+
+// NewQueue 创建名为name的延迟队列
+func NewQueue(client redis.UniversalClient, name string, config Config) *Queue {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultConfig.PollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultConfig.BatchSize
+	}
+	prefix := config.KeyPrefix + "{" + name + "}:"
+	return &Queue{
+		client:        client,
+		config:        config,
+		name:          name,
+		delayedKey:    prefix + "delayed",
+		readyKey:      prefix + "ready",
+		processingKey: prefix + "processing",
+		deadKey:       prefix + "dead",
+		payloadsKey:   prefix + "payloads",
+		attemptsKey:   prefix + "attempts",
+		claimsKey:     prefix + "claims",
+	}
+}
+
+// NewDefaultQueue 使用默认配置创建延迟队列
+func NewDefaultQueue(client redis.UniversalClient, name string) *Queue {
+	return NewQueue(client, name, DefaultConfig)
+}
+
+// Name 返回队列名
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Push 加入一个在delay之后到期的任务，返回任务ID；delay不大于0时任务立即就绪
+func (q *Queue) Push(ctx context.Context, payload []byte, delay time.Duration) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.payloadsKey, id, payload)
+		if delay <= 0 {
+			pipe.RPush(ctx, q.readyKey, id)
+		} else {
+			pipe.ZAdd(ctx, q.delayedKey, redis.Z{Score: float64(time.Now().Add(delay).UnixMilli()), Member: id})
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Stats 返回队列中各状态的任务数
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	var delayed, ready, processing, dead *redis.IntCmd
+	_, err := q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		delayed = pipe.ZCard(ctx, q.delayedKey)
+		ready = pipe.LLen(ctx, q.readyKey)
+		processing = pipe.ZCard(ctx, q.processingKey)
+		dead = pipe.LLen(ctx, q.deadKey)
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	return Stats{
+		Delayed:    delayed.Val(),
+		Ready:      ready.Val(),
+		Processing: processing.Val(),
+		Dead:       dead.Val(),
+	}, nil
+}
+
+// DeadLetters 返回死信列表中最早的n个任务，Attempts为已经投递的次数
+func (q *Queue) DeadLetters(ctx context.Context, n int64) ([]Job, error) {
+	ids, err := q.client.LRange(ctx, q.deadKey, 0, n-1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var payloads, attempts *redis.SliceCmd
+	_, err = q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		payloads = pipe.HMGet(ctx, q.payloadsKey, ids...)
+		attempts = pipe.HMGet(ctx, q.attemptsKey, ids...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, len(ids))
+	for i, id := range ids {
+		jobs[i].ID = id
+		if payload, ok := payloads.Val()[i].(string); ok {
+			jobs[i].Payload = []byte(payload)
+		}
+		if n, ok := attempts.Val()[i].(string); ok {
+			jobs[i].Attempts, _ = strconv.Atoi(n)
+		}
+	}
+	return jobs, nil
+}
+
+// RequeueDead 把死信列表中的任务重新放回就绪列表并清零投递次数，返回移动的任务数
+func (q *Queue) RequeueDead(ctx context.Context) (int, error) {
+	return requeueDeadScript.Run(ctx, q.client,
+		[]string{q.deadKey, q.readyKey, q.attemptsKey}).Int()
+}
+
+// newID 生成随机的任务ID
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
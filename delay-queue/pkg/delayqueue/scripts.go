@@ -0,0 +1,102 @@
+package delayqueue
+
+import "github.com/redis/go-redis/v9"
+
+// pollScript 把到期的延迟任务移到就绪列表，并处理可见性超时的任务：
+// 投递次数用完的移入死信列表，否则在RetryDelay后重新投递
+// KEYS: delayed, ready, processing, dead, attempts, claims
+// ARGV: 当前时间（毫秒）, 每次最多移动的任务数, 最多投递次数, 重试等待时间（毫秒）
+// 返回 {到期的任务数, 超时的任务数}
+var pollScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(due) do
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('RPUSH', KEYS[2], id)
+end
+
+local expired = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', now, 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[3], id)
+	redis.call('HDEL', KEYS[6], id)
+	if tonumber(redis.call('HGET', KEYS[5], id) or '0') >= tonumber(ARGV[3]) then
+		redis.call('RPUSH', KEYS[4], id)
+	else
+		redis.call('ZADD', KEYS[1], now + tonumber(ARGV[4]), id)
+	end
+end
+return {#due, #expired}
+`)
+
+// claimScript 从就绪列表领取一个任务，记录可见性超时的时间和这次领取的token，投递次数加一
+// KEYS: ready, processing, attempts, claims, payloads
+// ARGV: 可见性超时的时间（毫秒）, token
+// 返回 {任务ID, 内容, 投递次数}，没有就绪任务时返回nil
+var claimScript = redis.NewScript(`
+local id = redis.call('LPOP', KEYS[1])
+if not id then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[1], id)
+redis.call('HSET', KEYS[4], id, ARGV[2])
+local attempts = redis.call('HINCRBY', KEYS[3], id, 1)
+return {id, redis.call('HGET', KEYS[5], id) or '', attempts}
+`)
+
+// ackScript token匹配时删除任务
+// KEYS: processing, payloads, attempts, claims
+// ARGV: 任务ID, token
+// 返回1表示删除成功，0表示任务不再由这次领取持有
+var ackScript = redis.NewScript(`
+if redis.call('HGET', KEYS[4], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('HDEL', KEYS[4], ARGV[1])
+return 1
+`)
+
+// retryScript token匹配时结束这次领取：投递次数用完的移入死信列表，否则在delay后重新投递
+// KEYS: processing, delayed, dead, attempts, claims
+// ARGV: 任务ID, token, 当前时间（毫秒）, 重试等待时间（毫秒）, 最多投递次数
+// 返回1表示重新投递，0表示移入死信列表，-1表示任务不再由这次领取持有
+var retryScript = redis.NewScript(`
+if redis.call('HGET', KEYS[5], ARGV[1]) ~= ARGV[2] then
+	return -1
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[5], ARGV[1])
+if tonumber(redis.call('HGET', KEYS[4], ARGV[1]) or '0') >= tonumber(ARGV[5]) then
+	redis.call('RPUSH', KEYS[3], ARGV[1])
+	return 0
+end
+redis.call('ZADD', KEYS[2], tonumber(ARGV[3]) + tonumber(ARGV[4]), ARGV[1])
+return 1
+`)
+
+// touchScript token匹配时延长任务的可见性超时
+// KEYS: processing, claims
+// ARGV: 任务ID, token, 新的可见性超时的时间（毫秒）
+// 返回1表示延长成功，0表示任务不再由这次领取持有
+var touchScript = redis.NewScript(`
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+redis.call('ZADD', KEYS[1], 'XX', ARGV[3], ARGV[1])
+return 1
+`)
+
+// requeueDeadScript 把死信列表中的任务放回就绪列表并清零投递次数
+// KEYS: dead, ready, attempts
+// 返回移动的任务数
+var requeueDeadScript = redis.NewScript(`
+local ids = redis.call('LRANGE', KEYS[1], 0, -1)
+for _, id in ipairs(ids) do
+	redis.call('HDEL', KEYS[3], id)
+	redis.call('RPUSH', KEYS[2], id)
+end
+redis.call('DEL', KEYS[1])
+return #ids
+`)
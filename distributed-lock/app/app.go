@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-lock/pkg/lock"
+
+	"github.com/redis/go-redis/v9"
+
+	"redis-learning/pkg/cli"
+)
+
+// demoConfig 演示程序的配置
+type demoConfig struct {
+	// Redis地址，一个地址时使用单节点锁，多个地址时使用Redlock
+	Addrs []string
+	// 锁的key和共享计数器的key，计数器保存在第一个节点上
+	LockKey    string
+	CounterKey string
+	// 进程数和每个进程进入临界区的次数
+	Processes  int
+	Iterations int
+	// 临界区中读取计数器后等待的时间，放大并发修改的窗口
+	Hold time.Duration
+	// 不加锁，用于对比丢失的更新
+	NoLock bool
+	// 非0时作为编号为worker的子进程运行
+	Worker int
+}
+
+// Run 演示两个进程通过分布式锁互斥地修改同一个计数器：
+// 主进程清空计数器后启动多个子进程，每个子进程反复加锁、读取计数器、等待一段时间、写入加一后的值、解锁；
+// 加锁时计数器最终等于 进程数×次数，使用 -no-lock 时并发的读改写会丢失更新
+func Run(ctx context.Context, args []string) error {
+	var config demoConfig
+	fs := cli.NewFlagSet("lock-demo")
+	addrs := fs.String("addrs", "localhost:6379", "comma separated Redis addresses, more than one uses Redlock")
+	fs.StringVar(&config.LockKey, "key", "demo:lock", "Redis key of the lock")
+	fs.StringVar(&config.CounterKey, "counter", "demo:counter", "Redis key of the shared counter")
+	fs.IntVar(&config.Processes, "processes", 2, "number of processes competing for the lock")
+	fs.IntVar(&config.Iterations, "iterations", 20, "critical sections entered by each process")
+	fs.DurationVar(&config.Hold, "hold", 10*time.Millisecond, "time spent inside the critical section")
+	fs.BoolVar(&config.NoLock, "no-lock", false, "modify the counter without locking to show lost updates")
+	fs.IntVar(&config.Worker, "worker", 0, "run as worker process with this id (used internally)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config.Addrs = strings.Split(*addrs, ",")
+
+	clients := make([]redis.UniversalClient, len(config.Addrs))
+	for i, addr := range config.Addrs {
+		clients[i] = redis.NewClient(&redis.Options{Addr: addr})
+		defer clients[i].Close()
+	}
+
+	if config.Worker > 0 {
+		if err := runWorker(ctx, clients, config); err != nil {
+			return fmt.Errorf("worker %d failed: %w", config.Worker, err)
+		}
+		return nil
+	}
+	if err := runDemo(ctx, clients[0], config); err != nil {
+		return fmt.Errorf("demo failed: %w", err)
+	}
+	return nil
+}
+
+// runDemo 清空计数器，以子进程的方式启动所有worker，等待它们结束后检查计数器
+func runDemo(ctx context.Context, counter redis.UniversalClient, config demoConfig) error {
+	if err := counter.Del(ctx, config.CounterKey).Err(); err != nil {
+		return fmt.Errorf("failed to reset counter: %w", err)
+	}
+
+	mode := "single-instance lock"
+	if config.NoLock {
+		mode = "no lock"
+	} else if len(config.Addrs) > 1 {
+		mode = fmt.Sprintf("Redlock on %d nodes", len(config.Addrs))
+	}
+	fmt.Printf("===== %d processes x %d iterations, %s =====\n", config.Processes, config.Iterations, mode)
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, config.Processes)
+	for i := 0; i < config.Processes; i++ {
+		// 子进程使用相同的命令行参数，从统一的命令行启动时其中包含子命令名
+		args := append(slices.Clone(os.Args[1:]), "-worker", strconv.Itoa(i+1))
+		cmd := exec.CommandContext(ctx, executable, args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start worker %d: %w", i+1, err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = cmd.Wait()
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	value, err := counter.Get(ctx, config.CounterKey).Int()
+	if err != nil {
+		return fmt.Errorf("failed to read counter: %w", err)
+	}
+	expected := config.Processes * config.Iterations
+	fmt.Printf("\n===== Result =====\ncounter=%d expected=%d lost updates=%d\n", value, expected, expected-value)
+	return nil
+}
+
+// runWorker 反复进入临界区，对计数器做一次非原子的读改写
+func runWorker(ctx context.Context, clients []redis.UniversalClient, config demoConfig) error {
+	var mutex *lock.Mutex
+	if len(clients) == 1 {
+		mutex = lock.NewDefaultMutex(clients[0], config.LockKey)
+	} else {
+		mutex = lock.NewDefaultRedlock(clients, config.LockKey)
+	}
+
+	for i := 0; i < config.Iterations; i++ {
+		if !config.NoLock {
+			if err := mutex.Lock(ctx); err != nil {
+				return fmt.Errorf("failed to acquire lock: %w", err)
+			}
+		}
+
+		value, err := clients[0].Get(ctx, config.CounterKey).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("failed to read counter: %w", err)
+		}
+		time.Sleep(config.Hold)
+		if err := clients[0].Set(ctx, config.CounterKey, value+1, 0).Err(); err != nil {
+			return fmt.Errorf("failed to write counter: %w", err)
+		}
+		fmt.Printf("[worker %d] counter %d -> %d\n", config.Worker, value, value+1)
+
+		if !config.NoLock {
+			if err := mutex.Unlock(ctx); err != nil {
+				return fmt.Errorf("failed to release lock: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"distributed-lock/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("lock-demo", app.Run)
+}
@@ -0,0 +1,332 @@
+package lock
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrNotObtained 锁被其他持有者占用，或者没有在足够多的节点上加锁成功
+	ErrNotObtained = errors.New("lock: not obtained")
+	// ErrNotHeld 没有持有锁，或者锁已经过期、被其他持有者取得
+	ErrNotHeld = errors.New("lock: not held")
+)
+
+// Locker 分布式锁接口，由 Mutex 实现
+type Locker interface {
+	// TryLock 尝试加锁一次，锁被占用时立即返回 ErrNotObtained
+	TryLock(ctx context.Context) error
+	// Lock 加锁，锁被占用时按间隔重试，直到成功或ctx结束
+	Lock(ctx context.Context) error
+	// Unlock 释放锁，只会删除自己加的锁；锁已经过期或被其他持有者取得时返回 ErrNotHeld
+	Unlock(ctx context.Context) error
+}
+
+// 编译期检查 Mutex 实现了 Locker 接口
+var _ Locker = (*Mutex)(nil)
+
+// Config 分布式锁配置
+type Config struct {
+	// 锁的过期时间，持有者崩溃时锁最多在TTL后自动释放
+	TTL time.Duration
+	// Lock 重试的间隔，每次再加上不超过一半间隔的随机时间，避免多个竞争者同时重试
+	RetryDelay time.Duration
+	// 是否在持有锁期间自动续期，启用后临界区可以超过TTL，持有者崩溃时锁仍在TTL后释放
+	Watchdog bool
+	// 自动续期的间隔，为0时使用TTL的1/3
+	RenewInterval time.Duration
+	// 访问单个节点的超时时间，应远小于TTL，避免在一个不可用的节点上等待过久
+	NodeTimeout time.Duration
+	// 节点间时钟漂移占TTL的比例，计算锁的有效时间时扣除
+	DriftFactor float64
+}
+
+// DefaultConfig 默认分布式锁配置
+var DefaultConfig = Config{
+	TTL:         10 * time.Second,
+	RetryDelay:  50 * time.Millisecond,
+	Watchdog:    true,
+	NodeTimeout: 100 * time.Millisecond,
+	DriftFactor: 0.01,
+}
+
+// unlockScript 值等于自己的token时删除锁，避免删除过期后被其他持有者取得的锁
+var unlockScript = scripts.Register("unlock", `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// extendScript 值等于自己的token时重新设置过期时间
+var extendScript = scripts.Register("extend", `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// hold 一次成功加锁的状态
+type hold struct {
+	token string
+	// 锁的有效期，扣除了加锁耗时和时钟漂移
+	validUntil time.Time
+	// 锁丢失时关闭
+	lost chan struct{}
+	// 停止自动续期，未启用自动续期时为nil
+	stopWatchdog context.CancelFunc
+	watchdogDone chan struct{}
+}
+
+// Mutex 基于Redis的分布式互斥锁
+// 加锁使用 SET key token NX PX ttl，token是每次加锁随机生成的值，解锁和续期通过Lua脚本比较token，只操作自己加的锁
+// 单节点时就是常见的Redis锁；由 NewRedlock 创建时在多个独立节点上加锁，多数节点成功才算取得锁
+type Mutex struct {
+	nodes  []redis.UniversalClient
+	key    string
+	config Config
+	// 取得锁需要成功的节点数
+	quorum int
+
+	mu   sync.Mutex
+	hold *hold
+}
+
+// NewMutex 创建单节点的分布式锁，key为Redis中锁的key
+func NewMutex(client redis.UniversalClient, key string, config Config) *Mutex {
+	return newMutex([]redis.UniversalClient{client}, key, config)
+}
+
+// NewDefaultMutex 使用默认配置创建单节点的分布式锁
+func NewDefaultMutex(client redis.UniversalClient, key string) *Mutex {
+	return NewMutex(client, key, DefaultConfig)
+}
+
+// newMutex 创建在nodes上加锁的分布式锁，需要多数节点成功
+func newMutex(nodes []redis.UniversalClient, key string, config Config) *Mutex {
+	if config.TTL <= 0 {
+		config.TTL = DefaultConfig.TTL
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = DefaultConfig.RetryDelay
+	}
+	if config.RenewInterval <= 0 {
+		config.RenewInterval = config.TTL / 3
+	}
+	if config.NodeTimeout <= 0 {
+		config.NodeTimeout = DefaultConfig.NodeTimeout
+	}
+	return &Mutex{
+		nodes:  nodes,
+		key:    key,
+		config: config,
+		quorum: len(nodes)/2 + 1,
+	}
+}
+
+// Key 返回Redis中锁的key
+func (m *Mutex) Key() string {
+	return m.key
+}
+
+// TryLock 尝试加锁一次，锁被占用或已经由这个 Mutex 持有时返回 ErrNotObtained
+// 节点出错时返回的错误同时包装了 ErrNotObtained 和节点的错误
+func (m *Mutex) TryLock(ctx context.Context) error {
+	m.mu.Lock()
+	held := m.hold != nil
+	m.mu.Unlock()
+	if held {
+		return ErrNotObtained
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	acquired, errs := m.onNodes(ctx, func(ctx context.Context, node redis.UniversalClient) (bool, error) {
+		return node.SetNX(ctx, m.key, token, m.config.TTL).Result()
+	})
+	validUntil := start.Add(m.config.TTL - m.drift())
+	if acquired < m.quorum || !time.Now().Before(validUntil) {
+		// 释放已经加上的部分锁，不等它们过期
+		m.release(context.WithoutCancel(ctx), token)
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("%w: %w", ErrNotObtained, err)
+		}
+		return ErrNotObtained
+	}
+
+	h := &hold{token: token, validUntil: validUntil, lost: make(chan struct{})}
+	m.mu.Lock()
+	m.hold = h
+	if m.config.Watchdog {
+		m.startWatchdog(h)
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// Lock 加锁，锁被占用时每隔 RetryDelay 重试，直到成功或ctx结束，ctx结束时返回ctx的错误
+func (m *Mutex) Lock(ctx context.Context) error {
+	for {
+		err := m.TryLock(ctx)
+		if !errors.Is(err, ErrNotObtained) {
+			return err
+		}
+
+		delay := m.config.RetryDelay + rand.N(m.config.RetryDelay/2+1)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Unlock 释放锁并停止自动续期
+// 没有持有锁，或者锁在释放前已经过期、被其他持有者取得时返回 ErrNotHeld，此时临界区可能没有受到保护
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.mu.Lock()
+	h := m.hold
+	m.hold = nil
+	m.mu.Unlock()
+	if h == nil {
+		return ErrNotHeld
+	}
+	if h.stopWatchdog != nil {
+		h.stopWatchdog()
+		<-h.watchdogDone
+	}
+
+	released, errs := m.release(ctx, h.token)
+	if released < m.quorum {
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("%w: %w", ErrNotHeld, err)
+		}
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Extend 把锁的过期时间重新设置为TTL，锁已经丢失时返回 ErrNotHeld
+// 启用自动续期时由后台协程定期调用，不需要手动调用
+func (m *Mutex) Extend(ctx context.Context) error {
+	m.mu.Lock()
+	h := m.hold
+	m.mu.Unlock()
+	if h == nil {
+		return ErrNotHeld
+	}
+
+	validUntil, _, err := m.extend(ctx, h.token)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	h.validUntil = validUntil
+	m.mu.Unlock()
+	return nil
+}
+
+// Lost 返回一个在锁丢失时关闭的channel，锁丢失指自动续期失败、锁已经过期或被其他持有者取得
+// 锁丢失后临界区应尽快停止，并仍然调用 Unlock 才能再次加锁；没有持有锁时返回nil，从nil channel读取会一直阻塞
+func (m *Mutex) Lost() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.hold == nil {
+		return nil
+	}
+	return m.hold.lost
+}
+
+// ValidUntil 返回锁的有效期，过了这个时间锁可能已经被其他持有者取得；没有持有锁时返回false
+func (m *Mutex) ValidUntil() (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.hold == nil {
+		return time.Time{}, false
+	}
+	return m.hold.validUntil, true
+}
+
+// extend 在所有节点上为token续期，多数节点成功时返回新的有效期
+// 失败时返回的bool表示之后重试是否可能成功：出错的节点加上成功的节点仍能达到多数，或者只是续期耗时过长
+func (m *Mutex) extend(ctx context.Context, token string) (time.Time, bool, error) {
+	start := time.Now()
+	extended, errs := m.onNodes(ctx, func(ctx context.Context, node redis.UniversalClient) (bool, error) {
+		n, err := extendScript.Run(ctx, node, []string{m.key}, token, m.config.TTL.Milliseconds()).Int()
+		return n == 1, err
+	})
+	validUntil := start.Add(m.config.TTL - m.drift())
+	if extended < m.quorum || !time.Now().Before(validUntil) {
+		retryable := extended+len(errs) >= m.quorum
+		if err := errors.Join(errs...); err != nil {
+			return time.Time{}, retryable, fmt.Errorf("%w: %w", ErrNotHeld, err)
+		}
+		return time.Time{}, retryable, ErrNotHeld
+	}
+	return validUntil, false, nil
+}
+
+// release 在所有节点上删除token对应的锁，包括加锁失败的节点，返回删除成功的节点数
+func (m *Mutex) release(ctx context.Context, token string) (int, []error) {
+	return m.onNodes(ctx, func(ctx context.Context, node redis.UniversalClient) (bool, error) {
+		n, err := unlockScript.Run(ctx, node, []string{m.key}, token).Int()
+		return n == 1, err
+	})
+}
+
+// onNodes 在所有节点上并发执行fn，每个节点使用 NodeTimeout 的超时，返回fn成功的节点数和各节点的错误
+func (m *Mutex) onNodes(ctx context.Context, fn func(ctx context.Context, node redis.UniversalClient) (bool, error)) (int, []error) {
+	type result struct {
+		ok  bool
+		err error
+	}
+	results := make(chan result, len(m.nodes))
+	for _, node := range m.nodes {
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, m.config.NodeTimeout)
+			defer cancel()
+			ok, err := fn(ctx, node)
+			results <- result{ok: ok, err: err}
+		}()
+	}
+
+	succeeded := 0
+	var errs []error
+	for range m.nodes {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+		} else if r.ok {
+			succeeded++
+		}
+	}
+	return succeeded, errs
+}
+
+// drift 返回计算有效期时扣除的时钟漂移：TTL乘以 DriftFactor 再加2毫秒
+func (m *Mutex) drift() time.Duration {
+	return time.Duration(float64(m.config.TTL)*m.config.DriftFactor) + 2*time.Millisecond
+}
+
+// newToken 生成随机的锁token，区分不同的持有者
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,18 @@
+package lock
+
+import "github.com/redis/go-redis/v9"
+
+// NewRedlock 创建Redlock算法的分布式锁，clients是N个相互独立的Redis主节点（不是同一个集群的主从）
+//
+// 加锁时记录开始时间，并发地在所有节点上执行 SET NX PX，每个节点使用 NodeTimeout 的超时；
+// 至少 N/2+1 个节点成功，且加锁耗时加上时钟漂移小于TTL时才算取得锁，否则立即在所有节点上释放。
+// 锁的有效期 = TTL - 加锁耗时 - 时钟漂移，续期和解锁同样需要多数节点成功。
+// 少数节点宕机或网络分区时锁仍然可用，单个主节点故障切换导致的锁丢失也只影响少数节点
+func NewRedlock(clients []redis.UniversalClient, key string, config Config) *Mutex {
+	return newMutex(clients, key, config)
+}
+
+// NewDefaultRedlock 使用默认配置创建Redlock算法的分布式锁
+func NewDefaultRedlock(clients []redis.UniversalClient, key string) *Mutex {
+	return NewRedlock(clients, key, DefaultConfig)
+}
@@ -0,0 +1,6 @@
+package lock
+
+import "redis-learning/pkg/script"
+
+// scripts 分布式锁使用的Lua脚本，以 EVALSHA 执行，Redis中没有脚本时自动加载
+var scripts = script.NewBundle("distributed-lock")
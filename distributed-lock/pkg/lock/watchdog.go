@@ -0,0 +1,52 @@
+package lock
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// startWatchdog 启动自动续期的后台协程，调用时必须持有 m.mu
+func (m *Mutex) startWatchdog(h *hold) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.stopWatchdog = cancel
+	h.watchdogDone = make(chan struct{})
+	go m.watchdog(ctx, h)
+}
+
+// watchdog 每隔 RenewInterval 为锁续期，直到解锁或锁丢失
+// 节点出错时在下一个间隔重试，直到锁的有效期结束；锁已经被删除或被其他持有者取得时立即认为锁丢失
+func (m *Mutex) watchdog(ctx context.Context, h *hold) {
+	defer close(h.watchdogDone)
+
+	ticker := time.NewTicker(m.config.RenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			validUntil, retryable, err := m.extend(ctx, h.token)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				m.mu.Lock()
+				h.validUntil = validUntil
+				m.mu.Unlock()
+				continue
+			}
+
+			m.mu.Lock()
+			expired := !time.Now().Before(h.validUntil)
+			m.mu.Unlock()
+			if !retryable || expired {
+				log.Printf("Lock %s lost: %v", m.key, err)
+				close(h.lost)
+				return
+			}
+			log.Printf("Error renewing lock %s, will retry: %v", m.key, err)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"feed/internal/handlers"
+	"feed/pkg/feed"
+	"social/pkg/social"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 运行关注时间线服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("feed")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "feed name, also used as the name of the social follow graph")
+	mode := fs.String("mode", feed.DefaultConfig.Mode, "timeline mode: push, pull or hybrid")
+	threshold := fs.Int64("celebrity-threshold", feed.DefaultConfig.CelebrityThreshold, "followers at which hybrid mode stops fanning out an author's posts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	config := feed.DefaultConfig
+	config.Mode = *mode
+	config.CelebrityThreshold = *threshold
+	f, err := feed.NewFeed(client, social.NewDefaultService(client, *name), *name, config)
+	if err != nil {
+		return fmt.Errorf("%w %q, supported: %v", err, *mode, feed.Modes)
+	}
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	handlers.NewFeedHandler(f).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"feed/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("feed", app.Run)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"feed/pkg/feed"
+	"social/pkg/social"
+)
+
+// FeedHandler 处理时间线相关的HTTP请求
+type FeedHandler struct {
+	feed *feed.Feed
+}
+
+// NewFeedHandler 创建一个新的时间线处理器
+func NewFeedHandler(f *feed.Feed) *FeedHandler {
+	return &FeedHandler{feed: f}
+}
+
+// Setup 设置所有路由
+func (h *FeedHandler) Setup(router *gin.Engine) {
+	users := router.Group("/feed/users")
+	{
+		// 发布帖子
+		users.POST("/:id/posts", h.Publish)
+		// 用户自己发布的帖子
+		users.GET("/:id/posts", h.GetPosts)
+		// 用户的关注时间线
+		users.GET("/:id/timeline", h.GetTimeline)
+		// 关注，写扩散时补齐对方最近的帖子
+		users.POST("/:id/following", h.Follow)
+		// 取消关注，并从收件箱中移除对方的帖子
+		users.DELETE("/:id/following/:target", h.Unfollow)
+	}
+}
+
+// Publish 发布帖子，参数content为帖子内容
+func (h *FeedHandler) Publish(c *gin.Context) {
+	var req struct {
+		Content string `json:"content" form:"content" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	result, err := h.feed.Publish(c.Request.Context(), c.Param("id"), req.Content)
+	if errors.Is(err, feed.ErrInvalidPost) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Author and content are required"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// GetPosts 分页查询用户自己发布的帖子，参数cursor（默认0）、limit（默认20）
+func (h *FeedHandler) GetPosts(c *gin.Context) {
+	h.page(c, h.feed.Posts)
+}
+
+// GetTimeline 分页查询用户的关注时间线，参数cursor（默认0）、limit（默认20）
+func (h *FeedHandler) GetTimeline(c *gin.Context) {
+	h.page(c, h.feed.Timeline)
+}
+
+// Follow 关注，参数target为被关注的用户
+func (h *FeedHandler) Follow(c *gin.Context) {
+	var req struct {
+		Target string `json:"target" form:"target" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	changed, err := h.feed.Follow(c.Request.Context(), c.Param("id"), req.Target)
+	if errors.Is(err, social.ErrSelfFollow) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot follow yourself"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changed": changed})
+}
+
+// Unfollow 取消关注
+func (h *FeedHandler) Unfollow(c *gin.Context) {
+	changed, err := h.feed.Unfollow(c.Request.Context(), c.Param("id"), c.Param("target"))
+	if errors.Is(err, social.ErrSelfFollow) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot unfollow yourself"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changed": changed})
+}
+
+// page 按cursor和limit参数分页查询帖子
+func (h *FeedHandler) page(c *gin.Context, list func(ctx context.Context, userID string, cursor, limit int64) (feed.Page, error)) {
+	cursor, err := strconv.ParseInt(c.DefaultQuery("cursor", "0"), 10, 64)
+	if err != nil || cursor < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, must be between 1 and 100"})
+		return
+	}
+
+	page, err := list(c.Request.Context(), c.Param("id"), cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list posts: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,430 @@
+package feed
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"social/pkg/social"
+)
+
+const (
+	// ModePush 写扩散：发布时把帖子写入每个粉丝的收件箱，读时间线只读自己的收件箱
+	ModePush = "push"
+	// ModePull 读扩散：发布时只写自己的发件箱，读时间线时合并所有关注的人的发件箱
+	ModePull = "pull"
+	// ModeHybrid 混合：粉丝数低于 CelebrityThreshold 的作者写扩散，大V的帖子在读时间线时拉取
+	ModeHybrid = "hybrid"
+)
+
+// Modes 支持的全部时间线模式
+var Modes = []string{ModePush, ModePull, ModeHybrid}
+
+var (
+	// ErrInvalidPost 作者或内容为空
+	ErrInvalidPost = errors.New("feed: invalid post")
+	// ErrUnknownMode 不支持的时间线模式
+	ErrUnknownMode = errors.New("feed: unknown mode")
+)
+
+// Config 时间线配置
+type Config struct {
+	// Redis中各个key的前缀，完整的前缀为 KeyPrefix + "{名称}:"
+	KeyPrefix string
+	// 时间线模式，ModePush、ModePull 或 ModeHybrid
+	Mode string
+	// 收件箱只保留最新的这么多条帖子，更早的帖子无法从时间线翻到
+	InboxSize int64
+	// 发件箱只保留最新的这么多条帖子
+	OutboxSize int64
+	// 混合模式下粉丝数达到该值的作者视为大V，发布时不写扩散
+	CelebrityThreshold int64
+	// 写扩散时每个管道写入的收件箱数
+	FanoutBatch int64
+}
+
+// DefaultConfig 默认时间线配置
+var DefaultConfig = Config{
+	KeyPrefix:          "feed:",
+	Mode:               ModeHybrid,
+	InboxSize:          800,
+	OutboxSize:         800,
+	CelebrityThreshold: 5000,
+	FanoutBatch:        500,
+}
+
+// Post 一条帖子
+type Post struct {
+	// 帖子ID，按发布顺序递增，同时作为时间线中的分数
+	ID        int64     `json:"id"`
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Page 一页时间线，把Cursor传给下一次请求取得更早的帖子，Cursor为0表示已经没有更早的帖子
+type Page struct {
+	Posts  []Post `json:"posts"`
+	Cursor int64  `json:"cursor"`
+}
+
+// PublishResult 发布的结果
+type PublishResult struct {
+	Post Post `json:"post"`
+	// 写入的收件箱数，包括作者自己的；读扩散或大V发布时为0
+	Fanout int64 `json:"fanout"`
+}
+
+// Feed 基于有序集合的关注时间线，关注关系来自 social 模块
+//
+// 每个用户有一个发件箱（自己发布的帖子）和一个收件箱（写扩散收到的帖子），都是以帖子ID为分数的ZSET。
+// 帖子ID由INCR分配，按发布顺序递增且唯一，用作分页游标时不会因为同一时刻发布的帖子而跳过或重复
+type Feed struct {
+	client redis.UniversalClient
+	graph  *social.Service
+	config Config
+	prefix string
+}
+
+// NewFeed 创建名为name的时间线，graph提供关注关系
+func NewFeed(client redis.UniversalClient, graph *social.Service, name string, config Config) (*Feed, error) {
+	switch config.Mode {
+	case ModePush, ModePull, ModeHybrid:
+	default:
+		return nil, ErrUnknownMode
+	}
+	return &Feed{
+		client: client,
+		graph:  graph,
+		config: config,
+		prefix: config.KeyPrefix + "{" + name + "}:",
+	}, nil
+}
+
+// NewDefaultFeed 使用默认配置创建时间线
+func NewDefaultFeed(client redis.UniversalClient, graph *social.Service, name string) (*Feed, error) {
+	return NewFeed(client, graph, name, DefaultConfig)
+}
+
+// Mode 返回时间线模式
+func (f *Feed) Mode() string {
+	return f.config.Mode
+}
+
+// seqKey 帖子ID计数器
+func (f *Feed) seqKey() string {
+	return f.prefix + "seq"
+}
+
+// postKey 帖子内容
+func (f *Feed) postKey(id int64) string {
+	return f.prefix + "post:" + strconv.FormatInt(id, 10)
+}
+
+// outboxKey 用户发布的帖子
+func (f *Feed) outboxKey(userID string) string {
+	return f.prefix + "outbox:" + userID
+}
+
+// inboxKey 用户收到的帖子
+func (f *Feed) inboxKey(userID string) string {
+	return f.prefix + "inbox:" + userID
+}
+
+// Publish 发布帖子：保存帖子并写入作者的发件箱，按模式写扩散到粉丝的收件箱
+// 写扩散在发布请求中同步完成，耗时与粉丝数成正比
+func (f *Feed) Publish(ctx context.Context, author, content string) (PublishResult, error) {
+	if author == "" || content == "" {
+		return PublishResult{}, ErrInvalidPost
+	}
+	id, err := f.client.Incr(ctx, f.seqKey()).Result()
+	if err != nil {
+		return PublishResult{}, err
+	}
+	post := Post{ID: id, Author: author, Content: content, CreatedAt: time.Now()}
+
+	_, err = f.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, f.postKey(id), "author", author, "content", content, "created_at", post.CreatedAt.UnixMilli())
+		pipe.ZAdd(ctx, f.outboxKey(author), redis.Z{Score: float64(id), Member: id})
+		pipe.ZRemRangeByRank(ctx, f.outboxKey(author), 0, -f.config.OutboxSize-1)
+		return nil
+	})
+	if err != nil {
+		return PublishResult{}, err
+	}
+
+	result := PublishResult{Post: post}
+	push, err := f.shouldPush(ctx, author)
+	if err != nil || !push {
+		return result, err
+	}
+	result.Fanout, err = f.fanout(ctx, author, id)
+	return result, err
+}
+
+// shouldPush 作者发布的帖子是否写扩散
+func (f *Feed) shouldPush(ctx context.Context, author string) (bool, error) {
+	switch f.config.Mode {
+	case ModePush:
+		return true, nil
+	case ModePull:
+		return false, nil
+	}
+	celebrity, err := f.isCelebrity(ctx, author)
+	return !celebrity, err
+}
+
+// isCelebrity 用户的粉丝数是否达到大V的阈值
+func (f *Feed) isCelebrity(ctx context.Context, userID string) (bool, error) {
+	counts, err := f.graph.FollowCounts(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return counts[userID].Followers >= f.config.CelebrityThreshold, nil
+}
+
+// fanout 把帖子写入作者自己和所有粉丝的收件箱，返回写入的收件箱数
+// 粉丝用 SSCAN 分批遍历，每批在一个管道中写入并裁剪收件箱
+func (f *Feed) fanout(ctx context.Context, author string, id int64) (int64, error) {
+	if err := f.deliver(ctx, []string{author}, redis.Z{Score: float64(id), Member: id}); err != nil {
+		return 0, err
+	}
+	written := int64(1)
+
+	var cursor uint64
+	for {
+		page, err := f.graph.Followers(ctx, author, cursor, f.config.FanoutBatch)
+		if err != nil {
+			return written, err
+		}
+		if err := f.deliver(ctx, page.Members, redis.Z{Score: float64(id), Member: id}); err != nil {
+			return written, err
+		}
+		written += int64(len(page.Members))
+
+		cursor = page.Cursor
+		if cursor == 0 {
+			return written, nil
+		}
+	}
+}
+
+// deliver 在一个管道中把帖子写入多个用户的收件箱，并只保留最新的 InboxSize 条
+func (f *Feed) deliver(ctx context.Context, userIDs []string, posts ...redis.Z) error {
+	if len(userIDs) == 0 || len(posts) == 0 {
+		return nil
+	}
+	_, err := f.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range userIDs {
+			pipe.ZAdd(ctx, f.inboxKey(userID), posts...)
+			pipe.ZRemRangeByRank(ctx, f.inboxKey(userID), 0, -f.config.InboxSize-1)
+		}
+		return nil
+	})
+	return err
+}
+
+// Follow 关注用户；写扩散时把对方最近的帖子补进自己的收件箱，关注后立即能在时间线上看到
+// 返回是否新关注
+func (f *Feed) Follow(ctx context.Context, userID, target string) (bool, error) {
+	followed, err := f.graph.Follow(ctx, userID, target)
+	if err != nil || !followed {
+		return followed, err
+	}
+	push, err := f.shouldPush(ctx, target)
+	if err != nil || !push {
+		return true, err
+	}
+
+	posts, err := f.client.ZRevRangeWithScores(ctx, f.outboxKey(target), 0, f.config.InboxSize-1).Result()
+	if err != nil {
+		return true, err
+	}
+	return true, f.deliver(ctx, []string{userID}, posts...)
+}
+
+// Unfollow 取消关注，并从自己的收件箱中移除对方的帖子
+// 返回是否取消了关注
+func (f *Feed) Unfollow(ctx context.Context, userID, target string) (bool, error) {
+	unfollowed, err := f.graph.Unfollow(ctx, userID, target)
+	if err != nil || !unfollowed {
+		return unfollowed, err
+	}
+
+	ids, err := f.client.ZRange(ctx, f.outboxKey(target), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return true, err
+	}
+	members := make([]any, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+	return true, f.client.ZRem(ctx, f.inboxKey(userID), members...).Err()
+}
+
+// Posts 分页返回用户自己发布的帖子，cursor为0时从最新的开始
+func (f *Feed) Posts(ctx context.Context, userID string, cursor, limit int64) (Page, error) {
+	ids, err := f.mergeIDs(ctx, []string{f.outboxKey(userID)}, cursor, limit)
+	if err != nil {
+		return Page{}, err
+	}
+	return f.page(ctx, ids, limit)
+}
+
+// Timeline 分页返回用户关注的人和自己发布的帖子，按发布顺序从新到旧，cursor为0时从最新的开始
+//
+//   - 写扩散：只读自己的收件箱，一次 ZREVRANGEBYSCORE
+//   - 读扩散：读取关注的每个人和自己的发件箱后合并，请求数与关注数成正比
+//   - 混合：读取收件箱，再合并关注的大V的发件箱
+func (f *Feed) Timeline(ctx context.Context, userID string, cursor, limit int64) (Page, error) {
+	var sources []string
+	switch f.config.Mode {
+	case ModePush:
+		sources = []string{f.inboxKey(userID)}
+	case ModePull:
+		following, err := f.following(ctx, userID)
+		if err != nil {
+			return Page{}, err
+		}
+		sources = append(sources, f.outboxKey(userID))
+		for _, followee := range following {
+			sources = append(sources, f.outboxKey(followee))
+		}
+	case ModeHybrid:
+		celebrities, err := f.followedCelebrities(ctx, userID)
+		if err != nil {
+			return Page{}, err
+		}
+		sources = append(sources, f.inboxKey(userID))
+		for _, celebrity := range celebrities {
+			sources = append(sources, f.outboxKey(celebrity))
+		}
+	}
+
+	ids, err := f.mergeIDs(ctx, sources, cursor, limit)
+	if err != nil {
+		return Page{}, err
+	}
+	return f.page(ctx, ids, limit)
+}
+
+// following 返回用户关注的所有人
+func (f *Feed) following(ctx context.Context, userID string) ([]string, error) {
+	var following []string
+	var cursor uint64
+	for {
+		page, err := f.graph.Following(ctx, userID, cursor, f.config.FanoutBatch)
+		if err != nil {
+			return nil, err
+		}
+		following = append(following, page.Members...)
+
+		cursor = page.Cursor
+		if cursor == 0 {
+			return following, nil
+		}
+	}
+}
+
+// followedCelebrities 返回用户关注的人中的大V
+func (f *Feed) followedCelebrities(ctx context.Context, userID string) ([]string, error) {
+	following, err := f.following(ctx, userID)
+	if err != nil || len(following) == 0 {
+		return nil, err
+	}
+	counts, err := f.graph.FollowCounts(ctx, following...)
+	if err != nil {
+		return nil, err
+	}
+	var celebrities []string
+	for _, followee := range following {
+		if counts[followee].Followers >= f.config.CelebrityThreshold {
+			celebrities = append(celebrities, followee)
+		}
+	}
+	return celebrities, nil
+}
+
+// mergeIDs 在一个管道中从每个ZSET取出分数小于cursor的最新limit个帖子ID，合并去重后取最新的limit个
+// 每个来源最多贡献limit个ID，合并后的前limit个一定在其中
+func (f *Feed) mergeIDs(ctx context.Context, keys []string, cursor, limit int64) ([]int64, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	maxScore := "+inf"
+	if cursor > 0 {
+		maxScore = "(" + strconv.FormatInt(cursor, 10)
+	}
+
+	cmds := make([]*redis.StringSliceCmd, len(keys))
+	_, err := f.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{Min: "-inf", Max: maxScore, Count: limit})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, cmd := range cmds {
+		for _, member := range cmd.Val() {
+			id, err := strconv.ParseInt(member, 10, 64)
+			if err != nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	// 帖子ID越大越新
+	slices.SortFunc(ids, func(a, b int64) int { return cmp.Compare(b, a) })
+	if int64(len(ids)) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// page 读取帖子内容组成一页，不存在的帖子跳过；取满limit个ID时以最后一个ID作为下一页的游标
+func (f *Feed) page(ctx context.Context, ids []int64, limit int64) (Page, error) {
+	page := Page{Posts: []Post{}}
+	if len(ids) == 0 {
+		return page, nil
+	}
+	if int64(len(ids)) == limit {
+		page.Cursor = ids[len(ids)-1]
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err := f.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(ctx, f.postKey(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return Page{}, err
+	}
+
+	for i, id := range ids {
+		fields := cmds[i].Val()
+		if len(fields) == 0 {
+			continue
+		}
+		created, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+		page.Posts = append(page.Posts, Post{
+			ID:        id,
+			Author:    fields["author"],
+			Content:   fields["content"],
+			CreatedAt: time.UnixMilli(created),
+		})
+	}
+	return page, nil
+}
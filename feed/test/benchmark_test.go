@@ -0,0 +1,118 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"feed/pkg/feed"
+	"social/pkg/social"
+)
+
+// celebrityThreshold 基准测试中混合模式的大V阈值
+const celebrityThreshold = 500
+
+// benchmarkClient 返回基准测试使用的Redis客户端：设置了REDIS_ADDR时连接该地址的Redis，
+// 否则使用进程内的miniredis。miniredis没有网络延迟，真实Redis下读扩散的多次读取代价更明显
+func benchmarkClient(b *testing.B) *redis.Client {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(b).Addr()
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	b.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// newFeed 创建指定模式的时间线，每次使用不同的名称，避免连接真实Redis时互相影响
+func newFeed(b *testing.B, client *redis.Client, mode string) (*feed.Feed, *social.Service) {
+	name := fmt.Sprintf("bench-%s-%s", b.Name(), mode)
+	graph := social.NewDefaultService(client, name)
+	config := feed.DefaultConfig
+	config.Mode = mode
+	config.CelebrityThreshold = celebrityThreshold
+	f, err := feed.NewFeed(client, graph, name, config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		keys, _ := client.Keys(context.Background(), "*{"+name+"}*").Result()
+		if len(keys) > 0 {
+			client.Del(context.Background(), keys...)
+		}
+	})
+	return f, graph
+}
+
+// BenchmarkPublish 测试不同粉丝数下发布一条帖子的耗时：
+// 写扩散的耗时与粉丝数成正比，读扩散与粉丝数无关；混合模式下粉丝数达到阈值的作者不再写扩散
+func BenchmarkPublish(b *testing.B) {
+	for _, followers := range []int{10, 100, 1000} {
+		for _, mode := range feed.Modes {
+			b.Run(fmt.Sprintf("mode=%s/followers=%d", mode, followers), func(b *testing.B) {
+				ctx := context.Background()
+				f, graph := newFeed(b, benchmarkClient(b), mode)
+				for i := 0; i < followers; i++ {
+					if _, err := graph.Follow(ctx, fmt.Sprintf("user%d", i), "author"); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := f.Publish(ctx, "author", "hello"); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkTimeline 测试关注不同人数时读取一页时间线的耗时：
+// 写扩散只读自己的收件箱，读扩散要读取每个关注的人的发件箱；
+// 混合模式下读者关注的人中有一个大V，读取收件箱后还要合并大V的发件箱，并查询关注的人的粉丝数
+func BenchmarkTimeline(b *testing.B) {
+	for _, following := range []int{10, 100, 500} {
+		for _, mode := range feed.Modes {
+			b.Run(fmt.Sprintf("mode=%s/following=%d", mode, following), func(b *testing.B) {
+				ctx := context.Background()
+				f, graph := newFeed(b, benchmarkClient(b), mode)
+
+				// 第一个作者是大V
+				for i := 0; i < celebrityThreshold; i++ {
+					if _, err := graph.Follow(ctx, fmt.Sprintf("fan%d", i), "author0"); err != nil {
+						b.Fatal(err)
+					}
+				}
+				for i := 0; i < following; i++ {
+					if _, err := f.Follow(ctx, "reader", fmt.Sprintf("author%d", i)); err != nil {
+						b.Fatal(err)
+					}
+				}
+				for round := 0; round < 5; round++ {
+					for i := 0; i < following; i++ {
+						if _, err := f.Publish(ctx, fmt.Sprintf("author%d", i), "hello"); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					page, err := f.Timeline(ctx, "reader", 0, 20)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if len(page.Posts) != 20 {
+						b.Fatalf("got %d posts, want 20", len(page.Posts))
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"geo/internal/handlers"
+	"geo/pkg/geo"
+
+	"redis-learning/pkg/cli"
+)
+
+// samplePlaces 演示用的北京地点
+var samplePlaces = []geo.Place{
+	{ID: "tiananmen", Name: "天安门", Category: "landmark", Longitude: 116.397477, Latitude: 39.908692},
+	{ID: "forbidden-city", Name: "故宫博物院", Category: "museum", Longitude: 116.403414, Latitude: 39.924091},
+	{ID: "national-museum", Name: "中国国家博物馆", Category: "museum", Longitude: 116.401280, Latitude: 39.905237},
+	{ID: "jingshan", Name: "景山公园", Category: "park", Longitude: 116.396938, Latitude: 39.928376},
+	{ID: "beihai", Name: "北海公园", Category: "park", Longitude: 116.389463, Latitude: 39.925827},
+	{ID: "wangfujing", Name: "王府井步行街", Category: "shopping", Longitude: 116.410886, Latitude: 39.913375},
+	{ID: "qianmen", Name: "前门大街", Category: "shopping", Longitude: 116.398056, Latitude: 39.895833},
+	{ID: "temple-of-heaven", Name: "天坛公园", Category: "park", Longitude: 116.410829, Latitude: 39.881913},
+	{ID: "lama-temple", Name: "雍和宫", Category: "temple", Longitude: 116.417366, Latitude: 39.947486},
+	{ID: "summer-palace", Name: "颐和园", Category: "park", Longitude: 116.275179, Latitude: 39.999617},
+}
+
+// Run 运行附近地点查询服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("geo")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "index name")
+	seed := fs.Bool("seed", true, "add sample places in Beijing on startup")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	index := geo.NewDefaultIndex(client, *name)
+	if *seed {
+		if err := index.Add(ctx, samplePlaces...); err != nil {
+			return fmt.Errorf("failed to add sample places: %w", err)
+		}
+		log.Printf("Added %d sample places", len(samplePlaces))
+	}
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	handlers.NewGeoHandler(index).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
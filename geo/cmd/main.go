@@ -0,0 +1,11 @@
+package main
+
+import (
+	"geo/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("geo", app.Run)
+}
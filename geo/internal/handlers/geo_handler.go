@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"geo/pkg/geo"
+)
+
+// GeoHandler 处理地点相关的HTTP请求
+type GeoHandler struct {
+	index *geo.Index
+}
+
+// NewGeoHandler 创建一个新的地点处理器
+func NewGeoHandler(index *geo.Index) *GeoHandler {
+	return &GeoHandler{index: index}
+}
+
+// Setup 设置所有路由
+func (h *GeoHandler) Setup(router *gin.Engine) {
+	api := router.Group("/places")
+	{
+		// 添加或更新地点
+		api.POST("", h.AddPlace)
+		// 指定经纬度附近的地点
+		api.GET("/nearby", h.Nearby)
+		// 两个地点之间的距离
+		api.GET("/distance", h.Distance)
+		// 地点详情
+		api.GET("/:id", h.GetPlace)
+		// 删除地点
+		api.DELETE("/:id", h.RemovePlace)
+		// 地点附近的其他地点
+		api.GET("/:id/nearby", h.NearbyPlace)
+	}
+}
+
+// AddPlace 添加或更新地点
+func (h *GeoHandler) AddPlace(c *gin.Context) {
+	var req struct {
+		ID        string   `json:"id" binding:"required"`
+		Name      string   `json:"name" binding:"required"`
+		Category  string   `json:"category"`
+		Longitude *float64 `json:"longitude" binding:"required"`
+		Latitude  *float64 `json:"latitude" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	place := geo.Place{
+		ID:        req.ID,
+		Name:      req.Name,
+		Category:  req.Category,
+		Longitude: *req.Longitude,
+		Latitude:  *req.Latitude,
+	}
+	err := h.index.Add(c.Request.Context(), place)
+	if errors.Is(err, geo.ErrInvalidCoordinates) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coordinates"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add place: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, place)
+}
+
+// GetPlace 返回地点详情
+func (h *GeoHandler) GetPlace(c *gin.Context) {
+	place, err := h.index.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, geo.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Place not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get place: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, place)
+}
+
+// RemovePlace 删除地点
+func (h *GeoHandler) RemovePlace(c *gin.Context) {
+	if err := h.index.Remove(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove place: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Place removed"})
+}
+
+// Nearby 返回指定经纬度附近的地点，参数lon、lat必填，radius（米，默认1000）、offset、limit（默认10）可选
+func (h *GeoHandler) Nearby(c *gin.Context) {
+	lon, err := strconv.ParseFloat(c.Query("lon"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lon"})
+		return
+	}
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lat"})
+		return
+	}
+	query, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+	query.Longitude, query.Latitude = lon, lat
+	h.nearby(c, query)
+}
+
+// NearbyPlace 返回地点附近的其他地点，参数同 Nearby，结果不包括该地点自己
+func (h *GeoHandler) NearbyPlace(c *gin.Context) {
+	query, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	query.Member = id
+	// 查询中心的地点自己距离为0，排在最前面，多跳过一个
+	query.Offset++
+	h.nearby(c, query)
+}
+
+// nearby 执行附近地点查询并返回结果
+func (h *GeoHandler) nearby(c *gin.Context, query geo.NearbyQuery) {
+	result, err := h.index.Nearby(c.Request.Context(), query)
+	if errors.Is(err, geo.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Place not found"})
+		return
+	}
+	if errors.Is(err, geo.ErrInvalidQuery) || errors.Is(err, geo.ErrInvalidCoordinates) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query: " + err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search nearby places: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Distance 返回两个地点之间的距离（米），参数from、to为地点ID
+func (h *GeoHandler) Distance(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "From and to parameters are required"})
+		return
+	}
+	dist, err := h.index.Distance(c.Request.Context(), from, to)
+	if errors.Is(err, geo.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Place not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get distance: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "distance": dist})
+}
+
+// pageQuery 读取radius、offset、limit查询参数，格式错误时返回400
+func pageQuery(c *gin.Context) (geo.NearbyQuery, bool) {
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius", "1000"), 64)
+	if err != nil || radius <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid radius"})
+		return geo.NearbyQuery{}, false
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return geo.NearbyQuery{}, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return geo.NearbyQuery{}, false
+	}
+	return geo.NearbyQuery{Radius: radius, Offset: offset, Limit: limit}, true
+}
@@ -0,0 +1,283 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MaxLatitude Redis能够索引的最大纬度，更靠近两极的位置无法用GEOADD保存
+	MaxLatitude = 85.05112878
+	// MaxLongitude 最大经度
+	MaxLongitude = 180.0
+)
+
+var (
+	// ErrNotFound 地点不存在
+	ErrNotFound = errors.New("geo: place not found")
+	// ErrInvalidCoordinates 经纬度超出范围
+	ErrInvalidCoordinates = errors.New("geo: invalid coordinates")
+	// ErrInvalidQuery 查询参数不合法
+	ErrInvalidQuery = errors.New("geo: invalid query")
+)
+
+// Config 地点索引配置
+type Config struct {
+	// Redis中各个key的前缀，完整的前缀为 KeyPrefix + "{索引名}:"
+	KeyPrefix string
+	// 单次查询允许的最大半径（米）
+	MaxRadius float64
+	// 分页查询时 offset + limit 的上限，GEOSEARCH没有偏移参数，翻页越深需要取回的结果越多
+	MaxResults int
+}
+
+// DefaultConfig 默认地点索引配置
+var DefaultConfig = Config{
+	KeyPrefix:  "geo:",
+	MaxRadius:  50_000,
+	MaxResults: 1000,
+}
+
+// Place 一个地点
+type Place struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Category  string  `json:"category,omitempty"`
+	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
+}
+
+// NearbyPlace 附近的地点及其到查询中心的距离
+type NearbyPlace struct {
+	Place
+	// 到查询中心的距离（米）
+	Distance float64 `json:"distance"`
+}
+
+// NearbyQuery 附近地点查询
+type NearbyQuery struct {
+	// 查询中心的经纬度，Member不为空时忽略
+	Longitude float64
+	Latitude  float64
+	// 以已有地点为查询中心，结果中包括该地点自己
+	Member string
+	// 查询半径（米）
+	Radius float64
+	// 按距离从近到远排序后跳过的地点数
+	Offset int
+	// 返回的最大地点数
+	Limit int
+}
+
+// NearbyResult 附近地点查询结果
+type NearbyResult struct {
+	Places []NearbyPlace `json:"places"`
+	// 是否还有更远的地点
+	HasMore bool `json:"has_more"`
+}
+
+// Index 基于Redis GEO命令的地点索引
+//
+// 地点的坐标保存在GEO集合 geo:{索引名}:points 中（底层是以geohash为分值的ZSET），
+// 名称等属性以JSON保存在哈希 geo:{索引名}:places 中。两个key使用相同的hash tag，在集群中位于同一个slot。
+// 附近查询使用GEOSEARCH（需要Redis 6.2及以上版本）
+type Index struct {
+	client redis.UniversalClient
+	config Config
+	name   string
+
+	pointsKey string
+	placesKey string
+}
+
+// NewIndex 创建名为name的地点索引
+func NewIndex(client redis.UniversalClient, name string, config Config) *Index {
+	prefix := config.KeyPrefix + "{" + name + "}:"
+	return &Index{
+		client:    client,
+		config:    config,
+		name:      name,
+		pointsKey: prefix + "points",
+		placesKey: prefix + "places",
+	}
+}
+
+// NewDefaultIndex 使用默认配置创建地点索引
+func NewDefaultIndex(client redis.UniversalClient, name string) *Index {
+	return NewIndex(client, name, DefaultConfig)
+}
+
+// Add 添加或更新地点，坐标和属性在同一个事务中写入
+func (idx *Index) Add(ctx context.Context, places ...Place) error {
+	if len(places) == 0 {
+		return nil
+	}
+
+	locations := make([]*redis.GeoLocation, len(places))
+	values := make([]any, 0, len(places)*2)
+	for i, place := range places {
+		if err := validate(place.Longitude, place.Latitude); err != nil {
+			return fmt.Errorf("place %s: %w", place.ID, err)
+		}
+		data, err := json.Marshal(place)
+		if err != nil {
+			return err
+		}
+		locations[i] = &redis.GeoLocation{Name: place.ID, Longitude: place.Longitude, Latitude: place.Latitude}
+		values = append(values, place.ID, data)
+	}
+
+	_, err := idx.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.GeoAdd(ctx, idx.pointsKey, locations...)
+		pipe.HSet(ctx, idx.placesKey, values...)
+		return nil
+	})
+	return err
+}
+
+// Remove 删除地点
+func (idx *Index) Remove(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]any, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+
+	_, err := idx.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, idx.pointsKey, members...)
+		pipe.HDel(ctx, idx.placesKey, ids...)
+		return nil
+	})
+	return err
+}
+
+// Get 返回地点，不存在时返回 ErrNotFound
+// 返回的坐标来自GEOPOS，是geohash解码后的值，与添加时的坐标有微小的误差
+func (idx *Index) Get(ctx context.Context, id string) (Place, error) {
+	var pos *redis.GeoPosCmd
+	var data *redis.StringCmd
+	_, err := idx.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pos = pipe.GeoPos(ctx, idx.pointsKey, id)
+		data = pipe.HGet(ctx, idx.placesKey, id)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Place{}, err
+	}
+	positions := pos.Val()
+	if len(positions) == 0 || positions[0] == nil {
+		return Place{}, ErrNotFound
+	}
+
+	place := decodePlace(id, data.Val())
+	place.Longitude, place.Latitude = positions[0].Longitude, positions[0].Latitude
+	return place, nil
+}
+
+// Count 返回地点数
+func (idx *Index) Count(ctx context.Context) (int64, error) {
+	return idx.client.ZCard(ctx, idx.pointsKey).Result()
+}
+
+// Distance 返回两个地点之间的距离（米），任意一个地点不存在时返回 ErrNotFound
+func (idx *Index) Distance(ctx context.Context, from, to string) (float64, error) {
+	dist, err := idx.client.GeoDist(ctx, idx.pointsKey, from, to, "m").Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotFound
+	}
+	return dist, err
+}
+
+// Nearby 按距离从近到远返回半径内的地点，支持offset/limit分页
+//
+// GEOSEARCH只支持COUNT，不支持跳过前面的结果，因此取回 offset+limit+1 个地点后在客户端丢弃前offset个，
+// 多取的一个用于判断是否还有下一页。翻页越深代价越大，offset+limit不能超过 MaxResults
+func (idx *Index) Nearby(ctx context.Context, q NearbyQuery) (NearbyResult, error) {
+	if q.Radius <= 0 || q.Radius > idx.config.MaxRadius || q.Offset < 0 || q.Limit <= 0 ||
+		q.Offset+q.Limit > idx.config.MaxResults {
+		return NearbyResult{}, ErrInvalidQuery
+	}
+	if q.Member == "" {
+		if err := validate(q.Longitude, q.Latitude); err != nil {
+			return NearbyResult{}, err
+		}
+	}
+
+	locations, err := idx.client.GeoSearchLocation(ctx, idx.pointsKey, &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Member:     q.Member,
+			Longitude:  q.Longitude,
+			Latitude:   q.Latitude,
+			Radius:     q.Radius,
+			RadiusUnit: "m",
+			Sort:       "ASC",
+			Count:      q.Offset + q.Limit + 1,
+		},
+		WithCoord: true,
+		WithDist:  true,
+	}).Result()
+	if err != nil {
+		if q.Member != "" && isMemberMissing(err) {
+			return NearbyResult{}, ErrNotFound
+		}
+		return NearbyResult{}, err
+	}
+
+	result := NearbyResult{Places: []NearbyPlace{}}
+	if len(locations) > q.Offset+q.Limit {
+		result.HasMore = true
+		locations = locations[:q.Offset+q.Limit]
+	}
+	if q.Offset >= len(locations) {
+		return result, nil
+	}
+	locations = locations[q.Offset:]
+
+	ids := make([]string, len(locations))
+	for i, location := range locations {
+		ids[i] = location.Name
+	}
+	data, err := idx.client.HMGet(ctx, idx.placesKey, ids...).Result()
+	if err != nil {
+		return NearbyResult{}, err
+	}
+
+	result.Places = make([]NearbyPlace, len(locations))
+	for i, location := range locations {
+		raw, _ := data[i].(string)
+		place := decodePlace(location.Name, raw)
+		place.Longitude, place.Latitude = location.Longitude, location.Latitude
+		result.Places[i] = NearbyPlace{Place: place, Distance: location.Dist}
+	}
+	return result, nil
+}
+
+// decodePlace 解析地点属性，属性缺失或损坏时只保留ID
+func decodePlace(id, data string) Place {
+	var place Place
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &place)
+	}
+	place.ID = id
+	return place
+}
+
+// validate 检查经纬度是否在Redis能够索引的范围内
+func validate(longitude, latitude float64) error {
+	if longitude < -MaxLongitude || longitude > MaxLongitude || latitude < -MaxLatitude || latitude > MaxLatitude {
+		return ErrInvalidCoordinates
+	}
+	return nil
+}
+
+// isMemberMissing 判断GEOSEARCH FROMMEMBER的错误是否是成员不存在
+func isMemberMissing(err error) bool {
+	return strings.Contains(err.Error(), "could not decode requested zset member")
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"idempotency/internal/handlers"
+	"idempotency/pkg/idempotency"
+
+	"redis-learning/pkg/cli"
+	"redis-learning/pkg/script"
+)
+
+// Run 运行幂等下单服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("idempotency")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	delay := fs.Duration("delay", 2*time.Second, "simulated order processing time")
+	issued := fs.Bool("issued", true, "only accept tokens issued by POST /tokens")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	// 启动时预先加载Lua脚本包，之后的调用只发送SHA1
+	if err := script.LoadAll(ctx, client); err != nil {
+		return err
+	}
+
+	config := idempotency.DefaultConfig
+	config.RequireIssued = *issued
+	store := idempotency.NewStore(client, config)
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	handlers.NewOrderHandler(client, store, idempotency.DefaultOptions, *delay).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"idempotency/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("idempotency", app.Run)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"idempotency/pkg/idempotency"
+)
+
+// OrderHandler 演示用的下单接口，下单请求需要携带幂等令牌
+type OrderHandler struct {
+	client  redis.UniversalClient
+	store   *idempotency.Store
+	options idempotency.Options
+	// 模拟下单的处理时间
+	delay time.Duration
+}
+
+// NewOrderHandler 创建一个新的下单处理器
+func NewOrderHandler(client redis.UniversalClient, store *idempotency.Store, options idempotency.Options, delay time.Duration) *OrderHandler {
+	return &OrderHandler{client: client, store: store, options: options, delay: delay}
+}
+
+// Setup 设置所有路由
+func (h *OrderHandler) Setup(router *gin.Engine) {
+	// 获取幂等令牌
+	router.POST("/tokens", h.IssueToken)
+
+	api := router.Group("/orders")
+	api.Use(idempotency.Middleware(h.store, h.options))
+	{
+		// 下单
+		api.POST("", h.CreateOrder)
+		// 订单详情
+		api.GET("/:id", h.GetOrder)
+	}
+}
+
+// IssueToken 发放一个幂等令牌，客户端在提交订单前获取，重试时使用同一个令牌
+func (h *OrderHandler) IssueToken(c *gin.Context) {
+	token, err := h.store.Issue(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// CreateOrder 下单，参数item、amount；fail为true时模拟下单失败
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	var req struct {
+		Item   string `json:"item" binding:"required"`
+		Amount int64  `json:"amount" binding:"required,gt=0"`
+		Fail   bool   `json:"fail"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	time.Sleep(h.delay)
+	if req.Fail {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: simulated failure"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	id, err := h.client.Incr(ctx, "orders:id").Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
+		return
+	}
+	order := map[string]interface{}{
+		"id":         id,
+		"item":       req.Item,
+		"amount":     req.Amount,
+		"created_at": time.Now().Format(time.RFC3339),
+	}
+	if err := h.client.HSet(ctx, "orders:"+strconv.FormatInt(id, 10), order).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, order)
+}
+
+// GetOrder 返回订单详情
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	order, err := h.client.HGetAll(c.Request.Context(), "orders:"+c.Param("id")).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order: " + err.Error()})
+		return
+	}
+	if len(order) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
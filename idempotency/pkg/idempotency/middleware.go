@@ -0,0 +1,161 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReplayedHeader 返回保存的响应时设置的响应头
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Options 幂等中间件配置
+type Options struct {
+	// 携带令牌的请求头
+	Header string
+	// 需要检查令牌的请求方法，GET等本身幂等的方法不需要检查
+	Methods []string
+	// 请求没有携带令牌时是否拒绝，为false时直接执行
+	Required bool
+	// 计算指纹时读取的请求体的最大字节数，超过时返回413，小于等于0表示不限制
+	MaxBodyBytes int64
+}
+
+// DefaultOptions 默认幂等中间件配置
+var DefaultOptions = Options{
+	Header:       "Idempotency-Key",
+	Methods:      []string{http.MethodPost, http.MethodPatch},
+	Required:     true,
+	MaxBodyBytes: 1 << 20,
+}
+
+// responseRecorder 在写出响应的同时保存响应体
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Fingerprint 返回请求的指纹：请求方法、路径和请求体的SHA-256
+// 同一个令牌只能用于指纹相同的请求，客户端修改了参数却复用令牌时不会拿到上一次请求的响应
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Middleware 返回幂等中间件
+// 第一个请求正常执行，响应状态码小于500时保存响应，之后携带同一个令牌的请求直接返回保存的响应，
+// 并设置 Idempotent-Replayed: true；5xx响应和处理函数panic时不保存，令牌可以用于重试。
+// 第一个请求还在处理时，重复请求返回409；令牌无效、过期或已经被请求方法、路径、请求体不同的请求使用时返回422；
+// 请求体超过 MaxBodyBytes 时返回413
+func Middleware(store *Store, options Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(options.Methods, c.Request.Method) {
+			c.Next()
+			return
+		}
+		token := c.GetHeader(options.Header)
+		if token == "" {
+			if options.Required {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Missing " + options.Header + " header"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// 请求体需要整个读入内存计算指纹，限制大小防止过大的请求体占满内存
+		reader := c.Request.Body
+		if options.MaxBodyBytes > 0 {
+			reader = http.MaxBytesReader(c.Writer, c.Request.Body, options.MaxBodyBytes)
+		}
+		body, err := io.ReadAll(reader)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body: " + err.Error()})
+			return
+		}
+		// 处理函数还需要读取请求体
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := Fingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		claim, result, err := store.CheckAndConsume(c.Request.Context(), token, fingerprint)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with the same idempotency key is in progress"})
+			return
+		case errors.Is(err, ErrFingerprintMismatch):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency key was used for a different request"})
+			return
+		case errors.Is(err, ErrTokenNotFound), errors.Is(err, ErrInvalidKey):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid or expired idempotency key"})
+			return
+		default:
+			log.Printf("Error checking idempotency key: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check idempotency key: " + err.Error()})
+			return
+		}
+
+		if result != nil {
+			c.Header(ReplayedHeader, "true")
+			c.Data(result.StatusCode, result.ContentType, result.Body)
+			c.Abort()
+			return
+		}
+
+		// 请求的context可能已经取消，保存结果时使用独立的context
+		ctx := context.WithoutCancel(c.Request.Context())
+		// 处理函数panic时放弃处理，令牌可以用于重试，而不是在 LockTTL 内一直处于处理中；panic继续交给外层的Recovery
+		defer func() {
+			if p := recover(); p != nil {
+				if err := claim.Release(ctx); err != nil {
+					log.Printf("Error releasing idempotency key: %v", err)
+				}
+				panic(p)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := claim.Release(ctx); err != nil {
+				log.Printf("Error releasing idempotency key: %v", err)
+			}
+			return
+		}
+		err = claim.Complete(ctx, Result{
+			StatusCode:  recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			log.Printf("Error saving idempotent response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package idempotency
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// tokenBytes 发放的令牌的随机字节数
+	tokenBytes = 16
+	// maxKeyLength 令牌的最大长度
+	maxKeyLength = 128
+)
+
+// stateIssued 已发放、还没有使用的令牌在Redis中的值
+// 处理中的令牌的值为 "processing:" + 持有者标识 + ":" + 请求指纹，已完成的为 "done:" + 保存的响应
+const stateIssued = "issued"
+
+var (
+	// ErrTokenNotFound 令牌没有发放过或已经过期
+	ErrTokenNotFound = errors.New("idempotency: token not found")
+	// ErrInProgress 使用同一个令牌的第一个请求还在处理中
+	ErrInProgress = errors.New("idempotency: request in progress")
+	// ErrLockLost 处理时间超过了 LockTTL，处理中状态已经过期，结果没有保存
+	ErrLockLost = errors.New("idempotency: processing lock lost")
+	// ErrInvalidKey 令牌为空或过长
+	ErrInvalidKey = errors.New("idempotency: invalid key")
+	// ErrFingerprintMismatch 令牌已经被另一个不同的请求使用
+	ErrFingerprintMismatch = errors.New("idempotency: key reused with a different request")
+)
+
+// Config 幂等令牌存储配置
+type Config struct {
+	// Redis中key的前缀，完整的key为 KeyPrefix + 令牌
+	KeyPrefix string
+	// 发放的令牌在使用前的有效期
+	TokenTTL time.Duration
+	// 处理中状态的有效期，应大于请求的最长处理时间，防止进程崩溃后令牌永远处于处理中
+	LockTTL time.Duration
+	// 第一次请求的结果保存的时间，在此期间的重复请求直接返回该结果
+	ResultTTL time.Duration
+	// 是否只接受 Issue 发放的令牌；为false时接受客户端生成的任意令牌（例如UUID）
+	RequireIssued bool
+}
+
+// DefaultConfig 默认幂等令牌存储配置
+var DefaultConfig = Config{
+	KeyPrefix:     "idem:",
+	TokenTTL:      10 * time.Minute,
+	LockTTL:       30 * time.Second,
+	ResultTTL:     24 * time.Hour,
+	RequireIssued: true,
+}
+
+// Result 第一次请求的响应，重复请求时原样返回
+type Result struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body"`
+	// 第一次请求的指纹，由 Claim.Complete 填写
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// beginScript 检查令牌并开始处理
+// KEYS: 令牌key
+// ARGV: 持有者标识, 处理中状态的有效期（毫秒）, 是否只接受发放的令牌(1/0)
+// 返回 {'acquired'}、{'missing'}、{'processing', 处理中的持有者标识} 或 {'done', 保存的结果}
+var beginScript = scripts.Register("begin", `
+local v = redis.call('GET', KEYS[1])
+if not v then
+	if ARGV[3] == '1' then
+		return {'missing'}
+	end
+	redis.call('SET', KEYS[1], 'processing:' .. ARGV[1], 'NX', 'PX', ARGV[2])
+	return {'acquired'}
+end
+if v == 'issued' then
+	redis.call('SET', KEYS[1], 'processing:' .. ARGV[1], 'PX', ARGV[2])
+	return {'acquired'}
+end
+if string.sub(v, 1, 11) == 'processing:' then
+	return {'processing', string.sub(v, 12)}
+end
+return {'done', string.sub(v, 6)}
+`)
+
+// completeScript 保存结果，只有仍然持有处理中状态时才保存
+// KEYS: 令牌key
+// ARGV: 持有者标识, 结果, 结果保存时间（毫秒）
+var completeScript = scripts.Register("complete", `
+if redis.call('GET', KEYS[1]) ~= 'processing:' .. ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], 'done:' .. ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// releaseScript 放弃处理，令牌恢复为已发放状态或被删除，之后可以用同一个令牌重试
+// KEYS: 令牌key
+// ARGV: 持有者标识, 是否恢复为已发放状态(1/0), 令牌有效期（毫秒）
+var releaseScript = scripts.Register("release", `
+if redis.call('GET', KEYS[1]) ~= 'processing:' .. ARGV[1] then
+	return 0
+end
+if ARGV[2] == '1' then
+	redis.call('SET', KEYS[1], 'issued', 'PX', ARGV[3])
+else
+	redis.call('DEL', KEYS[1])
+end
+return 1
+`)
+
+// Store 基于Redis的幂等令牌存储
+//
+// 客户端在提交前获取一个令牌（或自己生成），提交时携带令牌。第一个请求把令牌从已发放改为处理中并执行业务，
+// 执行完成后保存响应；之后携带同一个令牌的请求不再执行业务，直接返回保存的响应。
+// 第一个请求还在处理时，重复请求返回 ErrInProgress
+type Store struct {
+	client redis.UniversalClient
+	config Config
+}
+
+// NewStore 创建幂等令牌存储
+func NewStore(client redis.UniversalClient, config Config) *Store {
+	return &Store{client: client, config: config}
+}
+
+// NewDefaultStore 使用默认配置创建幂等令牌存储
+func NewDefaultStore(client redis.UniversalClient) *Store {
+	return NewStore(client, DefaultConfig)
+}
+
+// key 令牌在Redis中的key
+func (s *Store) key(token string) string {
+	return s.config.KeyPrefix + token
+}
+
+// Issue 发放一个新令牌，令牌在 TokenTTL 内有效
+func (s *Store) Issue(ctx context.Context) (string, error) {
+	token := newToken()
+	if err := s.client.Set(ctx, s.key(token), stateIssued, s.config.TokenTTL).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Claim 第一个请求对令牌的处理权
+type Claim struct {
+	store       *Store
+	token       string
+	owner       string
+	fingerprint string
+}
+
+// CheckAndConsume 检查令牌并消费，fingerprint标识请求的内容（见 Fingerprint），为空时不检查
+//
+//   - 第一个请求返回 Claim，执行业务后调用 Claim.Complete 保存响应，失败时调用 Claim.Release 允许重试
+//   - 令牌已经用过时返回第一次请求保存的响应
+//   - 第一个请求还在处理时返回 ErrInProgress
+//   - 令牌已经被指纹不同的请求使用时返回 ErrFingerprintMismatch
+//   - 令牌没有发放过或已经过期时返回 ErrTokenNotFound
+func (s *Store) CheckAndConsume(ctx context.Context, token, fingerprint string) (*Claim, *Result, error) {
+	if token == "" || len(token) > maxKeyLength {
+		return nil, nil, ErrInvalidKey
+	}
+
+	// 持有者标识是随机令牌加请求指纹，随机令牌中没有":"
+	owner := newToken() + ":" + fingerprint
+	requireIssued := 0
+	if s.config.RequireIssued {
+		requireIssued = 1
+	}
+	reply, err := beginScript.Run(ctx, s.client, []string{s.key(token)},
+		owner, s.config.LockTTL.Milliseconds(), requireIssued).StringSlice()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch reply[0] {
+	case "acquired":
+		return &Claim{store: s, token: token, owner: owner, fingerprint: fingerprint}, nil, nil
+	case "missing":
+		return nil, nil, ErrTokenNotFound
+	case "processing":
+		_, processing, _ := strings.Cut(reply[1], ":")
+		if !sameFingerprint(processing, fingerprint) {
+			return nil, nil, ErrFingerprintMismatch
+		}
+		return nil, nil, ErrInProgress
+	}
+	var result Result
+	if err := json.Unmarshal([]byte(reply[1]), &result); err != nil {
+		return nil, nil, err
+	}
+	if !sameFingerprint(result.Fingerprint, fingerprint) {
+		return nil, nil, ErrFingerprintMismatch
+	}
+	return nil, &result, nil
+}
+
+// sameFingerprint 两个请求的指纹是否一致，任意一方没有指纹时不检查
+func sameFingerprint(stored, fingerprint string) bool {
+	return stored == "" || fingerprint == "" || stored == fingerprint
+}
+
+// Token 返回令牌
+func (c *Claim) Token() string {
+	return c.token
+}
+
+// Complete 保存第一次请求的响应，之后的重复请求直接返回该响应
+// 处理时间超过 LockTTL 时返回 ErrLockLost
+func (c *Claim) Complete(ctx context.Context, result Result) error {
+	result.Fingerprint = c.fingerprint
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	ok, err := completeScript.Run(ctx, c.store.client, []string{c.store.key(c.token)},
+		c.owner, data, c.store.config.ResultTTL.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Release 放弃处理，例如业务执行失败时，之后可以用同一个令牌重试
+func (c *Claim) Release(ctx context.Context) error {
+	restore := 0
+	if c.store.config.RequireIssued {
+		restore = 1
+	}
+	return releaseScript.Run(ctx, c.store.client, []string{c.store.key(c.token)},
+		c.owner, restore, c.store.config.TokenTTL.Milliseconds()).Err()
+}
+
+// newToken 生成随机令牌
+func newToken() string {
+	b := make([]byte, tokenBytes)
+	if _, err := crand.Read(b); err != nil {
+		panic("idempotency: failed to generate token: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
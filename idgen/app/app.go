@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"idgen/pkg/idgen"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 比较号段分配器和雪花算法生成ID的速度并检查唯一性，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("idgen")
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "generator name")
+	count := fs.Int("n", 100000, "number of ids to generate with each generator")
+	goroutines := fs.Int("goroutines", 8, "number of concurrent goroutines")
+	step := fs.Int64("step", idgen.DefaultSegmentConfig.Step, "segment length")
+	nodes := fs.Int("nodes", 3, "number of snowflake generators sharing the worker id pool")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// 号段分配器
+	config := idgen.DefaultSegmentConfig
+	config.Step = *step
+	segment := idgen.NewSegment(client, *name, config)
+	ids, elapsed := generate(*count, *goroutines, func() (int64, error) {
+		return segment.Next(ctx)
+	})
+	fmt.Printf("segment:   %d ids in %v (%.0f ids/s), unique=%v, range [%d, %d], ~%d INCRBY calls\n",
+		len(ids), elapsed.Round(time.Millisecond), float64(len(ids))/elapsed.Seconds(),
+		unique(ids), minOf(ids), maxOf(ids), (int64(len(ids))+*step-1)/(*step))
+
+	// 雪花算法：多个生成器从同一个池中租用不同的工作节点ID
+	generators := make([]*idgen.Snowflake, *nodes)
+	for i := range generators {
+		gen, err := idgen.NewDefaultSnowflake(ctx, client, *name)
+		if err != nil {
+			return fmt.Errorf("failed to create snowflake generator: %w", err)
+		}
+		// 中断后同样归还工作节点ID
+		defer gen.Close(context.WithoutCancel(ctx))
+		generators[i] = gen
+		fmt.Printf("snowflake node %d leased worker id %d\n", i, gen.WorkerID())
+	}
+
+	var next sync.Mutex
+	turn := 0
+	ids, elapsed = generate(*count, *goroutines, func() (int64, error) {
+		// 轮流使用各个生成器，模拟多个节点同时生成ID
+		next.Lock()
+		gen := generators[turn%len(generators)]
+		turn++
+		next.Unlock()
+		return gen.Next()
+	})
+	fmt.Printf("snowflake: %d ids in %v (%.0f ids/s), unique=%v\n",
+		len(ids), elapsed.Round(time.Millisecond), float64(len(ids))/elapsed.Seconds(), unique(ids))
+
+	sample := ids[len(ids)-1]
+	t, worker, seq := generators[0].Parse(sample)
+	fmt.Printf("snowflake sample id %d: time=%s worker=%d sequence=%d\n", sample, t.Format(time.RFC3339Nano), worker, seq)
+	return nil
+}
+
+// generate 在多个goroutine中共生成n个ID
+func generate(n, goroutines int, next func() (int64, error)) ([]int64, time.Duration) {
+	ids := make([]int64, n)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < n; i += goroutines {
+				id, err := next()
+				if err != nil {
+					log.Fatalf("Failed to generate id: %v", err)
+				}
+				ids[i] = id
+			}
+		}(g)
+	}
+	wg.Wait()
+	return ids, time.Since(start)
+}
+
+// unique 检查ID是否没有重复
+func unique(ids []int64) bool {
+	seen := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			return false
+		}
+		seen[id] = struct{}{}
+	}
+	return true
+}
+
+func minOf(ids []int64) int64 {
+	m := ids[0]
+	for _, id := range ids {
+		m = min(m, id)
+	}
+	return m
+}
+
+func maxOf(ids []int64) int64 {
+	m := ids[0]
+	for _, id := range ids {
+		m = max(m, id)
+	}
+	return m
+}
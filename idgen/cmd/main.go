@@ -0,0 +1,11 @@
+package main
+
+import (
+	"idgen/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("idgen", app.Run)
+}
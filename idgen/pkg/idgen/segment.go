@@ -0,0 +1,152 @@
+package idgen
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SegmentConfig 号段分配器配置
+type SegmentConfig struct {
+	// Redis中计数器key的前缀，完整的key为 KeyPrefix + "segment:" + 名称
+	KeyPrefix string
+	// 每次从Redis申请的号段长度，越大访问Redis越少，进程重启时浪费的ID越多
+	Step int64
+	// 当前号段剩余的比例低于该值时，在后台预先申请下一个号段，0表示用完后再同步申请
+	PrefetchRatio float64
+	// 申请号段的超时时间
+	FetchTimeout time.Duration
+}
+
+// DefaultSegmentConfig 默认号段分配器配置
+var DefaultSegmentConfig = SegmentConfig{
+	KeyPrefix:     "id:",
+	Step:          1000,
+	PrefetchRatio: 0.2,
+	FetchTimeout:  time.Second,
+}
+
+// segment 一个号段 [next, end)
+type segment struct {
+	next, end int64
+}
+
+func (s *segment) remaining() int64 {
+	return s.end - s.next
+}
+
+// Segment 号段分配器：每次用INCRBY从Redis申请一段连续的ID，在本地逐个分配
+//
+// 大部分ID在本地分配，不需要访问Redis。当前号段快用完时在后台预先申请下一个号段（双缓冲），
+// 号段切换时不会阻塞。ID全局唯一，同一个进程内递增；多个进程交替使用不同的号段，整体只是大致递增。
+// 进程退出时未用完的号段会被丢弃，ID中会出现空洞
+type Segment struct {
+	client redis.UniversalClient
+	config SegmentConfig
+	key    string
+
+	mu      sync.Mutex
+	current segment
+	// 预先申请好的下一个号段
+	next *segment
+	// 正在申请号段时不为nil，申请结束时关闭
+	loading chan struct{}
+	// 最近一次申请失败的错误
+	loadErr error
+}
+
+// NewSegment 创建名为name的号段分配器，同名的分配器共享同一个计数器
+func NewSegment(client redis.UniversalClient, name string, config SegmentConfig) *Segment {
+	if config.Step <= 0 {
+		config.Step = DefaultSegmentConfig.Step
+	}
+	if config.FetchTimeout <= 0 {
+		config.FetchTimeout = DefaultSegmentConfig.FetchTimeout
+	}
+	return &Segment{
+		client: client,
+		config: config,
+		key:    config.KeyPrefix + "segment:" + name,
+	}
+}
+
+// NewDefaultSegment 使用默认配置创建号段分配器
+func NewDefaultSegment(client redis.UniversalClient, name string) *Segment {
+	return NewSegment(client, name, DefaultSegmentConfig)
+}
+
+// Next 返回下一个ID，ID从1开始
+// 当前号段用完且下一个号段还没有申请到时等待申请完成，ctx结束时返回ctx的错误
+func (s *Segment) Next(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	for {
+		if s.current.remaining() > 0 {
+			id := s.current.next
+			s.current.next++
+			if s.shouldPrefetch() {
+				s.fetch()
+			}
+			s.mu.Unlock()
+			return id, nil
+		}
+
+		if s.next != nil {
+			s.current, s.next = *s.next, nil
+			continue
+		}
+
+		if s.loading == nil {
+			s.fetch()
+		}
+		loading := s.loading
+		s.mu.Unlock()
+
+		select {
+		case <-loading:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+
+		s.mu.Lock()
+		if s.next == nil && s.loadErr != nil {
+			err := s.loadErr
+			s.loadErr = nil
+			s.mu.Unlock()
+			return 0, err
+		}
+	}
+}
+
+// shouldPrefetch 当前号段剩余不足时是否需要预先申请下一个号段，调用时持有锁
+func (s *Segment) shouldPrefetch() bool {
+	if s.next != nil || s.loading != nil || s.config.PrefetchRatio <= 0 {
+		return false
+	}
+	return float64(s.current.remaining()) < float64(s.config.Step)*s.config.PrefetchRatio
+}
+
+// fetch 在后台申请下一个号段，调用时持有锁
+// 申请不使用调用者的ctx：号段可能在调用者返回后才申请到，供之后的调用使用
+func (s *Segment) fetch() {
+	loading := make(chan struct{})
+	s.loading = loading
+	s.loadErr = nil
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.FetchTimeout)
+		defer cancel()
+		end, err := s.client.IncrBy(ctx, s.key, s.config.Step).Result()
+
+		s.mu.Lock()
+		if err != nil {
+			s.loadErr = err
+		} else {
+			s.next = &segment{next: end - s.config.Step + 1, end: end + 1}
+		}
+		s.loading = nil
+		s.mu.Unlock()
+		close(loading)
+	}()
+}
@@ -0,0 +1,264 @@
+package idgen
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// WorkerBits 工作节点ID占用的位数，最多同时运行1024个节点
+	WorkerBits = 10
+	// SequenceBits 毫秒内序号占用的位数，每个节点每毫秒最多生成4096个ID
+	SequenceBits = 12
+	// MaxWorkerID 最大的工作节点ID
+	MaxWorkerID = 1<<WorkerBits - 1
+
+	maxSequence = 1<<SequenceBits - 1
+)
+
+var (
+	// ErrNoWorkerID 所有工作节点ID都已被占用
+	ErrNoWorkerID = errors.New("idgen: no worker id available")
+	// ErrLeaseLost 工作节点ID的租约已经丢失或过期，该ID可能已被其他节点使用，需要重新创建生成器
+	ErrLeaseLost = errors.New("idgen: worker id lease lost")
+	// ErrClockBackwards 系统时钟回拨超过了允许等待的范围
+	ErrClockBackwards = errors.New("idgen: clock moved backwards")
+)
+
+// SnowflakeConfig 雪花算法生成器配置
+type SnowflakeConfig struct {
+	// Redis中工作节点ID租约key的前缀，完整的前缀为 KeyPrefix + "{名称}:worker:"
+	KeyPrefix string
+	// 时间戳的起始时间，41位毫秒时间戳可以使用约69年
+	Epoch time.Time
+	// 工作节点ID租约的有效期，进程异常退出后，其ID在租约过期后才能被其他节点使用
+	LeaseTTL time.Duration
+	// 续约间隔，0表示 LeaseTTL/3
+	RenewInterval time.Duration
+	// 允许等待的最大时钟回拨，回拨不超过该值时等待时钟追上，超过时返回 ErrClockBackwards
+	MaxClockBackward time.Duration
+}
+
+// DefaultSnowflakeConfig 默认雪花算法生成器配置
+var DefaultSnowflakeConfig = SnowflakeConfig{
+	KeyPrefix:        "id:",
+	Epoch:            time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	LeaseTTL:         30 * time.Second,
+	MaxClockBackward: 5 * time.Millisecond,
+}
+
+// acquireWorkerScript 申请一个空闲的工作节点ID
+// ARGV: key前缀, 租约令牌, 租约有效期（毫秒）, 最大ID
+// key由前缀和ID拼接而成，前缀带有hash tag，所有ID的key在集群中位于同一个slot
+// 返回申请到的ID，没有空闲ID时返回-1
+var acquireWorkerScript = redis.NewScript(`
+for id = 0, tonumber(ARGV[4]) do
+	if redis.call('SET', ARGV[1] .. id, ARGV[2], 'NX', 'PX', ARGV[3]) then
+		return id
+	end
+end
+return -1
+`)
+
+// renewWorkerScript 续约，租约已经属于其他节点时返回0
+// KEYS: 租约key
+// ARGV: 租约令牌, 租约有效期（毫秒）
+var renewWorkerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseWorkerScript 释放租约，只删除自己持有的租约
+// KEYS: 租约key
+// ARGV: 租约令牌
+var releaseWorkerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Snowflake 雪花算法ID生成器：41位毫秒时间戳 | 10位工作节点ID | 12位毫秒内序号
+//
+// ID在本地生成，不需要访问Redis，按时间大致递增。工作节点ID必须全局唯一，生成器启动时从Redis租用一个空闲的ID，
+// 在后台定期续约。续约失败（租约被其他节点占用）或租约过期（长时间无法访问Redis）后，
+// 该ID可能已分配给其他节点，生成器停止生成ID并返回 ErrLeaseLost
+type Snowflake struct {
+	client   redis.UniversalClient
+	config   SnowflakeConfig
+	key      string
+	token    string
+	workerID int64
+	epochMs  int64
+
+	mu       sync.Mutex
+	lastMs   int64
+	sequence int64
+
+	// 本地认为租约有效的截止时间（UnixNano），按续约请求发出的时间计算，不会晚于Redis中的过期时间
+	leaseUntil atomic.Int64
+	lost       atomic.Bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSnowflake 从名为name的工作节点池中租用一个ID，创建雪花算法生成器，并启动后台续约
+// 同名的生成器共享工作节点池；使用完毕后调用 Close 释放ID
+func NewSnowflake(ctx context.Context, client redis.UniversalClient, name string, config SnowflakeConfig) (*Snowflake, error) {
+	if config.RenewInterval <= 0 {
+		config.RenewInterval = config.LeaseTTL / 3
+	}
+	prefix := config.KeyPrefix + "{" + name + "}:worker:"
+	token := newToken()
+
+	start := time.Now()
+	id, err := acquireWorkerScript.Run(ctx, client, nil,
+		prefix, token, config.LeaseTTL.Milliseconds(), MaxWorkerID).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if id < 0 {
+		return nil, ErrNoWorkerID
+	}
+
+	s := &Snowflake{
+		client:   client,
+		config:   config,
+		key:      prefix + strconv.FormatInt(id, 10),
+		token:    token,
+		workerID: id,
+		epochMs:  config.Epoch.UnixMilli(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.leaseUntil.Store(start.Add(config.LeaseTTL).UnixNano())
+	go s.renew()
+	return s, nil
+}
+
+// NewDefaultSnowflake 使用默认配置创建雪花算法生成器
+func NewDefaultSnowflake(ctx context.Context, client redis.UniversalClient, name string) (*Snowflake, error) {
+	return NewSnowflake(ctx, client, name, DefaultSnowflakeConfig)
+}
+
+// WorkerID 返回租用的工作节点ID
+func (s *Snowflake) WorkerID() int64 {
+	return s.workerID
+}
+
+// Next 生成下一个ID
+// 同一毫秒内的序号用完时等待下一毫秒；时钟回拨不超过 MaxClockBackward 时等待时钟追上
+func (s *Snowflake) Next() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lost.Load() || time.Now().UnixNano() >= s.leaseUntil.Load() {
+		return 0, ErrLeaseLost
+	}
+
+	now := s.millis()
+	if now < s.lastMs {
+		backward := time.Duration(s.lastMs-now) * time.Millisecond
+		if backward > s.config.MaxClockBackward {
+			return 0, ErrClockBackwards
+		}
+		time.Sleep(backward)
+		if now = s.millis(); now < s.lastMs {
+			return 0, ErrClockBackwards
+		}
+	}
+
+	if now == s.lastMs {
+		s.sequence = (s.sequence + 1) & maxSequence
+		if s.sequence == 0 {
+			// 这一毫秒的序号已经用完，等待下一毫秒
+			for now <= s.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				now = s.millis()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastMs = now
+
+	return now<<(WorkerBits+SequenceBits) | s.workerID<<SequenceBits | s.sequence, nil
+}
+
+// Parse 把ID拆分为生成时间、工作节点ID和毫秒内序号
+func (s *Snowflake) Parse(id int64) (t time.Time, workerID, sequence int64) {
+	ms := id >> (WorkerBits + SequenceBits)
+	workerID = id >> SequenceBits & MaxWorkerID
+	sequence = id & maxSequence
+	return time.UnixMilli(ms + s.epochMs), workerID, sequence
+}
+
+// Close 停止续约并释放工作节点ID，之后 Next 返回 ErrLeaseLost
+func (s *Snowflake) Close(ctx context.Context) error {
+	select {
+	case <-s.stop:
+		return nil
+	default:
+	}
+	close(s.stop)
+	<-s.done
+	s.lost.Store(true)
+	return releaseWorkerScript.Run(ctx, s.client, []string{s.key}, s.token).Err()
+}
+
+// renew 定期续约，直到 Close 或租约丢失
+func (s *Snowflake) renew() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.RenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.RenewInterval)
+		ok, err := renewWorkerScript.Run(ctx, s.client, []string{s.key}, s.token, s.config.LeaseTTL.Milliseconds()).Int64()
+		cancel()
+		if err != nil {
+			// 暂时无法访问Redis，租约在Redis中仍然有效，下次继续续约；租约过期后 Next 会停止生成ID
+			log.Printf("Error renewing worker id %d: %v", s.workerID, err)
+			continue
+		}
+		if ok == 0 {
+			log.Printf("Worker id %d lease lost", s.workerID)
+			s.lost.Store(true)
+			return
+		}
+		s.leaseUntil.Store(start.Add(s.config.LeaseTTL).UnixNano())
+	}
+}
+
+// millis 返回从起始时间开始的毫秒数
+func (s *Snowflake) millis() int64 {
+	return time.Now().UnixMilli() - s.epochMs
+}
+
+// newToken 生成随机的租约令牌，区分不同进程持有的租约
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		panic("idgen: failed to generate token: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
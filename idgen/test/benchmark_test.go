@@ -0,0 +1,108 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"idgen/pkg/idgen"
+)
+
+// benchmarkClient 返回基准测试使用的Redis客户端：设置了REDIS_ADDR时连接该地址的Redis，
+// 否则使用进程内的miniredis。miniredis没有网络延迟，真实Redis下每次访问Redis的代价更明显
+func benchmarkClient(b *testing.B) *redis.Client {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = miniredis.RunT(b).Addr()
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	b.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// BenchmarkRedisIncr 每个ID都执行一次INCR，作为对比的基准
+func BenchmarkRedisIncr(b *testing.B) {
+	ctx := context.Background()
+	client := benchmarkClient(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.Incr(ctx, "bench:incr").Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSegment_Next 测试不同号段长度下号段分配器的性能，号段越长访问Redis越少
+func BenchmarkSegment_Next(b *testing.B) {
+	for _, step := range []int64{1, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("step=%d", step), func(b *testing.B) {
+			ctx := context.Background()
+			config := idgen.DefaultSegmentConfig
+			config.Step = step
+			gen := idgen.NewSegment(benchmarkClient(b), "bench", config)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := gen.Next(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSegment_ParallelNext 并发测试号段分配器
+func BenchmarkSegment_ParallelNext(b *testing.B) {
+	ctx := context.Background()
+	gen := idgen.NewDefaultSegment(benchmarkClient(b), "bench")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := gen.Next(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkSnowflake_Next 测试雪花算法生成器，ID完全在本地生成，每毫秒最多4096个
+func BenchmarkSnowflake_Next(b *testing.B) {
+	ctx := context.Background()
+	gen, err := idgen.NewDefaultSnowflake(ctx, benchmarkClient(b), "bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer gen.Close(ctx)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := gen.Next(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSnowflake_ParallelNext 并发测试雪花算法生成器
+func BenchmarkSnowflake_ParallelNext(b *testing.B) {
+	ctx := context.Background()
+	gen, err := idgen.NewDefaultSnowflake(ctx, benchmarkClient(b), "bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer gen.Close(ctx)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := gen.Next(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"leaderboard/internal/handlers"
+	"leaderboard/pkg/leaderboard"
+
+	"redis-learning/pkg/cli"
+	"redis-learning/pkg/script"
+)
+
+// Run 运行排行榜服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("leaderboard")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "leaderboard name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	// 启动时预先加载Lua脚本包，之后的调用只发送SHA1
+	if err := script.LoadAll(ctx, client); err != nil {
+		return err
+	}
+
+	board := leaderboard.NewDefaultLeaderboard(client, *name)
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	handlers.NewLeaderboardHandler(board).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
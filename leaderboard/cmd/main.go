@@ -0,0 +1,11 @@
+package main
+
+import (
+	"leaderboard/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("leaderboard", app.Run)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"leaderboard/pkg/leaderboard"
+)
+
+// LeaderboardHandler 处理排行榜相关的HTTP请求
+type LeaderboardHandler struct {
+	board *leaderboard.Leaderboard
+}
+
+// NewLeaderboardHandler 创建一个新的排行榜处理器
+func NewLeaderboardHandler(board *leaderboard.Leaderboard) *LeaderboardHandler {
+	return &LeaderboardHandler{board: board}
+}
+
+// Setup 设置所有路由
+func (h *LeaderboardHandler) Setup(router *gin.Engine) {
+	api := router.Group("/leaderboard")
+	{
+		// 前n名
+		api.GET("/top", h.GetTopN)
+		// 当前赛季
+		api.GET("/season", h.GetSeason)
+		// 开始新赛季
+		api.POST("/season/reset", h.ResetSeason)
+		// 历史赛季的前n名
+		api.GET("/seasons/:season/top", h.GetSeasonTopN)
+		// 成员的名次
+		api.GET("/members/:member", h.GetRank)
+		// 成员前后的名次
+		api.GET("/members/:member/around", h.GetAroundMe)
+		// 为成员加分
+		api.POST("/members/:member/score", h.AddScore)
+	}
+}
+
+// AddScore 为成员加分，delta可以为负
+func (h *LeaderboardHandler) AddScore(c *gin.Context) {
+	var req struct {
+		Delta int64 `json:"delta" form:"delta" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	member := c.Param("member")
+	score, err := h.board.AddScore(c.Request.Context(), member, req.Delta)
+	if errors.Is(err, leaderboard.ErrScoreOutOfRange) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Score out of range", "max_score": leaderboard.MaxScore})
+		return
+	}
+	if errors.Is(err, leaderboard.ErrSeasonTooLong) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Season is too long, start a new season first"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add score: " + err.Error()})
+		return
+	}
+
+	entry, err := h.board.GetRank(c.Request.Context(), member)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"member": member, "score": score})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetRank 返回成员的名次和分数
+func (h *LeaderboardHandler) GetRank(c *gin.Context) {
+	entry, err := h.board.GetRank(c.Request.Context(), c.Param("member"))
+	if errors.Is(err, leaderboard.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rank: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetTopN 返回前n名，默认10名
+func (h *LeaderboardHandler) GetTopN(c *gin.Context) {
+	n, ok := positiveQuery(c, "n", 10)
+	if !ok {
+		return
+	}
+	entries, err := h.board.GetTopN(c.Request.Context(), n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top members: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// GetAroundMe 返回成员前后各radius名，默认5名
+func (h *LeaderboardHandler) GetAroundMe(c *gin.Context) {
+	radius, ok := positiveQuery(c, "radius", 5)
+	if !ok {
+		return
+	}
+	entries, err := h.board.GetAroundMe(c.Request.Context(), c.Param("member"), radius)
+	if errors.Is(err, leaderboard.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get nearby members: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// GetSeason 返回当前赛季
+func (h *LeaderboardHandler) GetSeason(c *gin.Context) {
+	season, err := h.board.CurrentSeason(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get season: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, season)
+}
+
+// ResetSeason 结束当前赛季并开始新赛季
+func (h *LeaderboardHandler) ResetSeason(c *gin.Context) {
+	season, err := h.board.ResetSeason(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset season: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, season)
+}
+
+// GetSeasonTopN 返回历史赛季的前n名，默认10名
+func (h *LeaderboardHandler) GetSeasonTopN(c *gin.Context) {
+	season, err := strconv.ParseInt(c.Param("season"), 10, 64)
+	if err != nil || season <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid season"})
+		return
+	}
+	n, ok := positiveQuery(c, "n", 10)
+	if !ok {
+		return
+	}
+	entries, err := h.board.GetSeasonTopN(c.Request.Context(), season, n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top members: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"season": season, "entries": entries})
+}
+
+// positiveQuery 读取正整数查询参数，不存在时返回默认值，格式错误时返回400
+func positiveQuery(c *gin.Context, name string, defaultValue int64) (int64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, true
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,256 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// timeBits 组合分数中时间部分占用的位数，以秒为单位可以表示约194天
+	timeBits = 24
+	// timeScale 组合分数 = 分数 × timeScale + (timeScale - 1 - 赛季开始后的秒数)
+	timeScale = 1 << timeBits
+	// MaxScore 可以保存的最大分数，组合分数必须能被float64精确表示（不超过2^53）
+	MaxScore = 1<<(53-timeBits) - 1
+	// MaxSeasonLength 赛季的最长时间，超过后时间部分无法再区分先后，加分返回 ErrSeasonTooLong
+	MaxSeasonLength = (timeScale - 1) * time.Second
+	// maxSeasonRetries 脚本执行前赛季被轮换时重新读取赛季的最大次数
+	maxSeasonRetries = 3
+)
+
+var (
+	// ErrScoreOutOfRange 加分后的分数小于0或大于 MaxScore
+	ErrScoreOutOfRange = errors.New("leaderboard: score out of range")
+	// ErrNotFound 成员不在排行榜中
+	ErrNotFound = errors.New("leaderboard: member not found")
+	// ErrSeasonTooLong 当前赛季开始已经超过 MaxSeasonLength，需要调用 ResetSeason 开始新赛季
+	ErrSeasonTooLong = errors.New("leaderboard: season too long")
+)
+
+// Config 排行榜配置
+// 同分时按达到分数的先后排序只在赛季开始后的 MaxSeasonLength（约194天）内有效，应该在此之前调用 ResetSeason
+type Config struct {
+	// Redis中排行榜各个key的前缀，完整的前缀为 KeyPrefix + "{排行榜名}:"
+	KeyPrefix string
+	// 赛季结束后旧排行榜的保留时间，过期后自动删除，0表示一直保留
+	Retention time.Duration
+}
+
+// DefaultConfig 默认排行榜配置
+var DefaultConfig = Config{
+	KeyPrefix: "lb:",
+	Retention: 30 * 24 * time.Hour,
+}
+
+// Entry 排行榜中的一项
+type Entry struct {
+	Member string `json:"member"`
+	Score  int64  `json:"score"`
+	// 名次，从1开始
+	Rank int64 `json:"rank"`
+}
+
+// addScoreScript 原子地为成员加分，并把达到新分数的时间编码进组合分数
+// KEYS: 赛季信息, 调用方读取到的赛季的排行榜
+// ARGV: 成员, 加的分数, 当前时间（秒）, 最大分数, 调用方读取到的赛季编号
+// 排行榜key由调用方按赛季编号拼接后通过KEYS传入，集群可以据此路由；
+// 赛季在读取之后已经轮换时返回 season changed 错误，由调用方重新读取赛季后重试
+// 赛季开始后的秒数超出组合分数中时间部分的范围时返回 season too long 错误，不再静默地按同一时间排序
+// 返回新的分数，超出范围时返回错误
+var addScoreScript = scripts.Register("add-score", `
+local season = redis.call('HGET', KEYS[1], 'id') or '1'
+if season ~= ARGV[5] then
+	return redis.error_reply('season changed')
+end
+local started = tonumber(redis.call('HGET', KEYS[1], 'started_at'))
+if not started then
+	-- 第一次加分时开始第一个赛季
+	started = tonumber(ARGV[3])
+	redis.call('HSET', KEYS[1], 'id', season, 'started_at', started)
+end
+local board = KEYS[2]
+local scale = `+strconv.Itoa(timeScale)+`
+local elapsed = math.max(tonumber(ARGV[3]) - started, 0)
+if elapsed > scale - 1 then
+	return redis.error_reply('season too long')
+end
+
+local current = redis.call('ZSCORE', board, ARGV[1])
+local score = 0
+if current then
+	score = math.floor(tonumber(current) / scale)
+end
+score = score + tonumber(ARGV[2])
+if score < 0 or score > tonumber(ARGV[4]) then
+	return redis.error_reply('score out of range')
+end
+
+redis.call('ZADD', board, score * scale + (scale - 1 - elapsed), ARGV[1])
+return score
+`)
+
+// Leaderboard 基于有序集合的排行榜，分数相同时先达到该分数的成员排在前面
+//
+// ZSET只按分数排序，分数相同时按成员名的字典序，与达到分数的先后无关。
+// 排行榜把分数和时间组合成一个分数保存：组合分数 = 分数 × 2^24 + (2^24 - 1 - 赛季开始后的秒数)，
+// 分数高的组合分数一定更高，分数相同时越早达到的组合分数越高。分数必须是0到 MaxScore 之间的整数
+type Leaderboard struct {
+	client redis.UniversalClient
+	config Config
+	name   string
+
+	seasonKey string
+	// 各赛季排行榜key的前缀，后面加上赛季编号
+	boardPrefix string
+}
+
+// NewLeaderboard 创建名为name的排行榜
+func NewLeaderboard(client redis.UniversalClient, name string, config Config) *Leaderboard {
+	prefix := config.KeyPrefix + "{" + name + "}:"
+	return &Leaderboard{
+		client:      client,
+		config:      config,
+		name:        name,
+		seasonKey:   prefix + "season",
+		boardPrefix: prefix + "board:",
+	}
+}
+
+// NewDefaultLeaderboard 使用默认配置创建排行榜
+func NewDefaultLeaderboard(client redis.UniversalClient, name string) *Leaderboard {
+	return NewLeaderboard(client, name, DefaultConfig)
+}
+
+// Name 返回排行榜名
+func (lb *Leaderboard) Name() string {
+	return lb.name
+}
+
+// AddScore 为当前赛季的成员加分，delta可以为负，返回新的分数
+// 分数相同时按达到分数的时间排序，因此每次加分都会更新成员的时间
+// 加分期间赛季被 ResetSeason 轮换时重新读取赛季，分数总是加在加分时的当前赛季上
+// 赛季开始超过 MaxSeasonLength 后返回 ErrSeasonTooLong
+func (lb *Leaderboard) AddScore(ctx context.Context, member string, delta int64) (int64, error) {
+	for attempt := 0; ; attempt++ {
+		season, err := lb.currentSeasonID(ctx)
+		if err != nil {
+			return 0, err
+		}
+		score, err := addScoreScript.Run(ctx, lb.client, []string{lb.seasonKey, lb.boardKey(season)},
+			member, delta, time.Now().Unix(), MaxScore, season).Int64()
+		switch {
+		case err != nil && strings.Contains(err.Error(), "score out of range"):
+			return 0, ErrScoreOutOfRange
+		case err != nil && strings.Contains(err.Error(), "season too long"):
+			return 0, ErrSeasonTooLong
+		case err != nil && strings.Contains(err.Error(), "season changed") && attempt < maxSeasonRetries:
+			continue
+		}
+		return score, err
+	}
+}
+
+// GetRank 返回成员在当前赛季的名次和分数，成员不在排行榜中时返回 ErrNotFound
+func (lb *Leaderboard) GetRank(ctx context.Context, member string) (Entry, error) {
+	board, err := lb.currentBoard(ctx)
+	if err != nil {
+		return Entry{}, err
+	}
+	return lb.rank(ctx, board, member)
+}
+
+// GetTopN 返回当前赛季前n名
+func (lb *Leaderboard) GetTopN(ctx context.Context, n int64) ([]Entry, error) {
+	board, err := lb.currentBoard(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lb.rangeByRank(ctx, board, 0, n-1)
+}
+
+// GetAroundMe 返回当前赛季中成员前后各radius名，包括成员自己；成员不在排行榜中时返回 ErrNotFound
+func (lb *Leaderboard) GetAroundMe(ctx context.Context, member string, radius int64) ([]Entry, error) {
+	board, err := lb.currentBoard(ctx)
+	if err != nil {
+		return nil, err
+	}
+	me, err := lb.rank(ctx, board, member)
+	if err != nil {
+		return nil, err
+	}
+	start := max(me.Rank-1-radius, 0)
+	return lb.rangeByRank(ctx, board, start, me.Rank-1+radius)
+}
+
+// Remove 从当前赛季的排行榜中删除成员
+func (lb *Leaderboard) Remove(ctx context.Context, member string) error {
+	board, err := lb.currentBoard(ctx)
+	if err != nil {
+		return err
+	}
+	return lb.client.ZRem(ctx, board, member).Err()
+}
+
+// Count 返回当前赛季排行榜中的成员数
+func (lb *Leaderboard) Count(ctx context.Context) (int64, error) {
+	board, err := lb.currentBoard(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return lb.client.ZCard(ctx, board).Result()
+}
+
+// rank 返回成员在board中的名次和分数
+func (lb *Leaderboard) rank(ctx context.Context, board, member string) (Entry, error) {
+	var rank *redis.IntCmd
+	var score *redis.FloatCmd
+	_, err := lb.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		rank = pipe.ZRevRank(ctx, board, member)
+		score = pipe.ZScore(ctx, board, member)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Member: member, Score: decodeScore(score.Val()), Rank: rank.Val() + 1}, nil
+}
+
+// rangeByRank 返回board中名次在[start+1, stop+1]之间的成员
+func (lb *Leaderboard) rangeByRank(ctx context.Context, board string, start, stop int64) ([]Entry, error) {
+	if stop < start {
+		return []Entry{}, nil
+	}
+	members, err := lb.client.ZRevRangeWithScores(ctx, board, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(members))
+	for i, member := range members {
+		entries[i] = Entry{
+			Member: member.Member.(string),
+			Score:  decodeScore(member.Score),
+			Rank:   start + int64(i) + 1,
+		}
+	}
+	return entries, nil
+}
+
+// boardKey 返回赛季的排行榜key
+func (lb *Leaderboard) boardKey(season int64) string {
+	return fmt.Sprintf("%s%d", lb.boardPrefix, season)
+}
+
+// decodeScore 从组合分数中取出分数
+func decodeScore(composite float64) int64 {
+	return int64(math.Floor(composite / timeScale))
+}
@@ -0,0 +1,98 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Season 赛季信息
+type Season struct {
+	// 赛季编号，从1开始
+	ID        int64     `json:"id"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// resetSeasonScript 开始新赛季：赛季编号加一，记录开始时间，旧赛季的排行榜在保留时间后过期
+// KEYS: 赛季信息, 调用方读取到的赛季的排行榜
+// ARGV: 当前时间（秒）, 旧排行榜的保留时间（毫秒）, 调用方读取到的赛季编号
+// 赛季在读取之后已经轮换时返回 season changed 错误，与 addScoreScript 相同
+// 返回新的赛季编号
+var resetSeasonScript = scripts.Register("reset-season", `
+local old = redis.call('HGET', KEYS[1], 'id') or '1'
+if old ~= ARGV[3] then
+	return redis.error_reply('season changed')
+end
+local new = tonumber(old) + 1
+redis.call('HSET', KEYS[1], 'id', new, 'started_at', ARGV[1])
+if tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[2], ARGV[2])
+end
+return new
+`)
+
+// CurrentSeason 返回当前赛季，还没有任何成员加分时为第1赛季，开始时间为零值
+func (lb *Leaderboard) CurrentSeason(ctx context.Context) (Season, error) {
+	values, err := lb.client.HGetAll(ctx, lb.seasonKey).Result()
+	if err != nil {
+		return Season{}, err
+	}
+	season := Season{ID: 1}
+	if id, err := strconv.ParseInt(values["id"], 10, 64); err == nil {
+		season.ID = id
+	}
+	if started, err := strconv.ParseInt(values["started_at"], 10, 64); err == nil {
+		season.StartedAt = time.Unix(started, 0)
+	}
+	return season, nil
+}
+
+// ResetSeason 结束当前赛季并开始新赛季，返回新赛季
+// 赛季按key轮换：每个赛季使用独立的排行榜key，新赛季从空排行榜开始，不需要删除大的ZSET；
+// 旧赛季的排行榜仍可以通过 GetSeasonTopN 查询，在 Retention 后自动过期
+// 赛季长度不能超过 MaxSeasonLength（约194天），否则 AddScore 返回 ErrSeasonTooLong，应按更短的周期定时调用
+func (lb *Leaderboard) ResetSeason(ctx context.Context) (Season, error) {
+	for attempt := 0; ; attempt++ {
+		old, err := lb.currentSeasonID(ctx)
+		if err != nil {
+			return Season{}, err
+		}
+		now := time.Now()
+		id, err := resetSeasonScript.Run(ctx, lb.client, []string{lb.seasonKey, lb.boardKey(old)},
+			now.Unix(), lb.config.Retention.Milliseconds(), old).Int64()
+		if err != nil && strings.Contains(err.Error(), "season changed") && attempt < maxSeasonRetries {
+			continue
+		}
+		if err != nil {
+			return Season{}, err
+		}
+		return Season{ID: id, StartedAt: time.Unix(now.Unix(), 0)}, nil
+	}
+}
+
+// GetSeasonTopN 返回指定赛季的前n名，赛季已经过期或不存在时返回空列表
+func (lb *Leaderboard) GetSeasonTopN(ctx context.Context, season, n int64) ([]Entry, error) {
+	return lb.rangeByRank(ctx, lb.boardKey(season), 0, n-1)
+}
+
+// currentBoard 返回当前赛季的排行榜key
+func (lb *Leaderboard) currentBoard(ctx context.Context) (string, error) {
+	id, err := lb.currentSeasonID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return lb.boardKey(id), nil
+}
+
+// currentSeasonID 返回当前赛季的编号，还没有任何成员加分时为1
+func (lb *Leaderboard) currentSeasonID(ctx context.Context) (int64, error) {
+	id, err := lb.client.HGet(ctx, lb.seasonKey, "id").Int64()
+	if errors.Is(err, redis.Nil) {
+		return 1, nil
+	}
+	return id, err
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"leaderboard/pkg/leaderboard"
+)
+
+// newTestLeaderboard 创建连接进程内miniredis的排行榜
+func newTestLeaderboard(t *testing.T) (*leaderboard.Leaderboard, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return leaderboard.NewDefaultLeaderboard(client, "game"), client
+}
+
+// TestLeaderboard_TieBreakByTime 分数相同时先达到该分数的成员排在前面
+func TestLeaderboard_TieBreakByTime(t *testing.T) {
+	ctx := context.Background()
+	board, client := newTestLeaderboard(t)
+
+	if _, err := board.AddScore(ctx, "alice", 10); err != nil {
+		t.Fatalf("AddScore(alice) error = %v", err)
+	}
+	// 把赛季开始时间提前，bob比alice晚一小时达到相同的分数
+	started := time.Now().Add(-time.Hour).Unix()
+	if err := client.HSet(ctx, "lb:{game}:season", "started_at", started).Err(); err != nil {
+		t.Fatalf("HSet() error = %v", err)
+	}
+	if _, err := board.AddScore(ctx, "bob", 10); err != nil {
+		t.Fatalf("AddScore(bob) error = %v", err)
+	}
+
+	top, err := board.GetTopN(ctx, 2)
+	if err != nil || len(top) != 2 || top[0].Member != "alice" || top[1].Member != "bob" || top[1].Score != 10 {
+		t.Errorf("GetTopN(2) = %+v, %v, want alice before bob with score 10", top, err)
+	}
+}
+
+// TestLeaderboard_SeasonTooLong 赛季开始超过 MaxSeasonLength 后加分返回 ErrSeasonTooLong，开始新赛季后恢复
+func TestLeaderboard_SeasonTooLong(t *testing.T) {
+	ctx := context.Background()
+	board, client := newTestLeaderboard(t)
+
+	if _, err := board.AddScore(ctx, "alice", 1); err != nil {
+		t.Fatalf("AddScore() error = %v", err)
+	}
+	started := time.Now().Add(-leaderboard.MaxSeasonLength - time.Minute).Unix()
+	if err := client.HSet(ctx, "lb:{game}:season", "started_at", started).Err(); err != nil {
+		t.Fatalf("HSet() error = %v", err)
+	}
+	if _, err := board.AddScore(ctx, "alice", 1); !errors.Is(err, leaderboard.ErrSeasonTooLong) {
+		t.Fatalf("AddScore() after MaxSeasonLength error = %v, want ErrSeasonTooLong", err)
+	}
+	if entry, err := board.GetRank(ctx, "alice"); err != nil || entry.Score != 1 {
+		t.Errorf("GetRank() = %+v, %v, want the score before the rejected add", entry, err)
+	}
+
+	if _, err := board.ResetSeason(ctx); err != nil {
+		t.Fatalf("ResetSeason() error = %v", err)
+	}
+	if score, err := board.AddScore(ctx, "alice", 1); err != nil || score != 1 {
+		t.Errorf("AddScore() in the new season = %d, %v, want 1", score, err)
+	}
+}
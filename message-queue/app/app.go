@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"message-queue/pkg/mq"
+
+	"github.com/redis/go-redis/v9"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 演示消费者组中的竞争消费：
+// 生产者发布若干订单消息，一个消费者读取一批消息后"崩溃"（不确认），
+// 其余消费者竞争消费新消息，并用 XAUTOCLAIM 接管崩溃消费者的消息；
+// 无法处理的消息在投递次数用完后进入死信流
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("mq-demo")
+	addr := fs.String("addr", "localhost:6379", "Redis address")
+	stream := fs.String("stream", "demo:orders", "stream name, it is deleted before the demo starts")
+	messages := fs.Int("messages", 20, "number of messages to publish")
+	consumers := fs.Int("consumers", 2, "number of healthy competing consumers")
+	poison := fs.Int("poison", 7, "order number whose message always fails and ends in the dead letter stream, 0 disables")
+	timeout := fs.Duration("timeout", 30*time.Second, "give up if messages are not all handled within this duration")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: *addr})
+	defer client.Close()
+
+	config := mq.DefaultConfig
+	config.Count = 3
+	config.Block = 200 * time.Millisecond
+	config.ClaimIdle = time.Second
+	config.ReclaimInterval = 500 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	group := mq.NewGroup(client, *stream, "workers", config)
+	if err := client.Del(ctx, *stream, group.DeadLetterStream()).Err(); err != nil {
+		return fmt.Errorf("failed to reset streams: %w", err)
+	}
+	if err := group.Create(ctx, "0"); err != nil {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	producer := mq.NewProducer(client, *stream, config)
+	fmt.Printf("===== Publishing %d messages to %s =====\n", *messages, *stream)
+	for i := 1; i <= *messages; i++ {
+		if _, err := producer.Publish(ctx, map[string]any{"order": i, "amount": i * 10}); err != nil {
+			return fmt.Errorf("failed to publish: %w", err)
+		}
+	}
+
+	// 崩溃的消费者：读到第一批消息后停止，不确认任何消息
+	fmt.Println("\n===== Consumer crashy reads a batch and crashes =====")
+	crashCtx, crash := context.WithCancel(ctx)
+	group.Consumer("crashy").Consume(crashCtx, func(ctx context.Context, msg mq.Message) error {
+		fmt.Printf("[crashy] got order %v, crashing before ack\n", msg.Values["order"])
+		// 同一批中剩下的消息同样不确认
+		crash()
+		return errors.New("crashed")
+	})
+
+	var mu sync.Mutex
+	handled := make(map[string]int)
+	consumeCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var wg sync.WaitGroup
+	fmt.Printf("\n===== %d consumers competing =====\n", *consumers)
+	for i := 1; i <= *consumers; i++ {
+		name := fmt.Sprintf("worker-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			group.Consumer(name).Consume(consumeCtx, func(ctx context.Context, msg mq.Message) error {
+				order := msg.Values["order"]
+				if *poison > 0 && order == fmt.Sprint(*poison) {
+					fmt.Printf("[%s] order %v delivery %d: failed\n", name, order, msg.Deliveries)
+					return errors.New("poison message")
+				}
+				fmt.Printf("[%s] order %v delivery %d: done\n", name, order, msg.Deliveries)
+				mu.Lock()
+				handled[name]++
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+
+	// 所有消息都被处理或进入死信流时结束
+	for ctx.Err() == nil {
+		time.Sleep(200 * time.Millisecond)
+		dead, err := client.XLen(ctx, group.DeadLetterStream()).Result()
+		if err != nil {
+			continue
+		}
+		mu.Lock()
+		finished := int(dead)
+		for _, n := range handled {
+			finished += n
+		}
+		mu.Unlock()
+		if finished >= *messages {
+			break
+		}
+	}
+	stop()
+	wg.Wait()
+
+	fmt.Println("\n===== Result =====")
+	for i := 1; i <= *consumers; i++ {
+		name := fmt.Sprintf("worker-%d", i)
+		fmt.Printf("%s handled %d messages\n", name, handled[name])
+	}
+	consumerInfos, err := group.Consumers(context.Background())
+	if err == nil {
+		for _, consumer := range consumerInfos {
+			fmt.Printf("consumer %s pending=%d\n", consumer.Name, consumer.Pending)
+		}
+	}
+	dead, err := group.DeadLetters(context.Background(), 100)
+	if err != nil {
+		return fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	for _, msg := range dead {
+		fmt.Printf("dead letter: order %v (source %v, %v deliveries)\n",
+			msg.Values["order"], msg.Values[mq.DeadLetterSourceID], msg.Values[mq.DeadLetterDeliveries])
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"message-queue/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("mq-demo", app.Run)
+}
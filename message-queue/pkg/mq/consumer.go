@@ -0,0 +1,228 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Consumer 消费者组中的一个消费者
+type Consumer struct {
+	group *Group
+	name  string
+}
+
+// Name 返回消费者的名称
+func (c *Consumer) Name() string {
+	return c.name
+}
+
+// Consume 循环读取并处理消息，直到ctx结束
+//
+// 开始时先处理自己之前领取但没有确认的消息（进程崩溃前的消息），之后用 XREADGROUP 读取新消息；
+// 每隔 ReclaimInterval 用 XAUTOCLAIM 接管组内空闲超过 ClaimIdle 的消息，它们的消费者可能已经崩溃。
+// 所有消息在同一个协程中依次处理，handler不会被并发调用；需要并发时在多个协程或进程中使用不同名称的消费者
+func (c *Consumer) Consume(ctx context.Context, handler Handler) error {
+	if err := c.recoverPending(ctx, handler); err != nil && ctx.Err() == nil {
+		log.Printf("Error recovering pending messages of consumer %s: %v", c.name, err)
+	}
+
+	config := c.group.config
+	lastReclaim := time.Now()
+	for {
+		if time.Since(lastReclaim) >= config.ReclaimInterval {
+			if _, err := c.Reclaim(ctx, handler); err != nil && ctx.Err() == nil {
+				log.Printf("Error reclaiming messages for consumer %s: %v", c.name, err)
+			}
+			lastReclaim = time.Now()
+		}
+
+		streams, err := c.group.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.group.name,
+			Consumer: c.name,
+			Streams:  []string{c.group.stream, ">"},
+			Count:    config.Count,
+			Block:    config.Block,
+		}).Result()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error reading from stream %s: %v", c.group.stream, err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		for _, msg := range streams[0].Messages {
+			c.handle(ctx, Message{ID: msg.ID, Values: msg.Values, Deliveries: 1}, handler)
+		}
+	}
+}
+
+// Reclaim 用 XAUTOCLAIM 接管组内空闲超过 ClaimIdle 的消息并处理，返回接管的消息数
+// 投递次数超过 MaxDeliveries 的消息不再处理，移入死信流
+func (c *Consumer) Reclaim(ctx context.Context, handler Handler) (int, error) {
+	claimed := 0
+	start := "0-0"
+	for {
+		msgs, next, err := c.group.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.group.stream,
+			Group:    c.group.name,
+			MinIdle:  c.group.config.ClaimIdle,
+			Start:    start,
+			Count:    c.group.config.Count,
+			Consumer: c.name,
+		}).Result()
+		if err != nil {
+			return claimed, err
+		}
+		if len(msgs) > 0 {
+			claimed += len(msgs)
+			log.Printf("Consumer %s reclaimed %d idle messages from stream %s", c.name, len(msgs), c.group.stream)
+			if err := c.handleAll(ctx, msgs, handler); err != nil {
+				return claimed, err
+			}
+		}
+		// 扫描完整个待确认列表时返回的游标为0-0
+		if next == "0-0" || next == "" {
+			return claimed, nil
+		}
+		start = next
+	}
+}
+
+// Ack 确认消息，确认后消息从待确认列表中删除，不再投递
+func (c *Consumer) Ack(ctx context.Context, ids ...string) error {
+	return c.group.client.XAck(ctx, c.group.stream, c.group.name, ids...).Err()
+}
+
+// recoverPending 处理自己待确认列表中的消息，读取ID "0" 起的历史消息直到读完
+func (c *Consumer) recoverPending(ctx context.Context, handler Handler) error {
+	start := "0"
+	for {
+		streams, err := c.group.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.group.name,
+			Consumer: c.name,
+			Streams:  []string{c.group.stream, start},
+			Count:    c.group.config.Count,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil
+			}
+			return err
+		}
+		msgs := streams[0].Messages
+		if len(msgs) == 0 {
+			return nil
+		}
+		log.Printf("Consumer %s recovering %d pending messages from stream %s", c.name, len(msgs), c.group.stream)
+		if err := c.handleAll(ctx, msgs, handler); err != nil {
+			return err
+		}
+		start = msgs[len(msgs)-1].ID
+	}
+}
+
+// handleAll 查询消息的投递次数后依次处理
+func (c *Consumer) handleAll(ctx context.Context, msgs []redis.XMessage, handler Handler) error {
+	deliveries, err := c.deliveries(ctx, msgs)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		c.handle(ctx, Message{ID: msg.ID, Values: msg.Values, Deliveries: deliveries[msg.ID]}, handler)
+	}
+	return nil
+}
+
+// handle 处理一条消息：成功时确认，失败时留在待确认列表中；投递次数超过上限时移入死信流
+func (c *Consumer) handle(ctx context.Context, msg Message, handler Handler) {
+	// 消息已经被裁剪出流，只剩待确认列表中的ID
+	if len(msg.Values) == 0 {
+		if err := c.Ack(ctx, msg.ID); err != nil {
+			log.Printf("Error acknowledging trimmed message %s: %v", msg.ID, err)
+		}
+		return
+	}
+	if msg.Deliveries > c.group.config.MaxDeliveries {
+		if err := c.deadLetter(ctx, msg); err != nil {
+			log.Printf("Error moving message %s to dead letters: %v", msg.ID, err)
+		}
+		return
+	}
+
+	if err := handler(ctx, msg); err != nil {
+		log.Printf("Consumer %s failed to handle message %s (delivery %d), left pending: %v",
+			c.name, msg.ID, msg.Deliveries, err)
+		return
+	}
+	if err := c.Ack(ctx, msg.ID); err != nil {
+		log.Printf("Error acknowledging message %s: %v", msg.ID, err)
+	}
+}
+
+// deadLetter 把消息复制到死信流并确认原消息，两步在一个事务中执行
+func (c *Consumer) deadLetter(ctx context.Context, msg Message) error {
+	values := make(map[string]any, len(msg.Values)+3)
+	for field, value := range msg.Values {
+		values[field] = value
+	}
+	values[DeadLetterSourceID] = msg.ID
+	values[DeadLetterGroup] = c.group.name
+	values[DeadLetterDeliveries] = msg.Deliveries - 1
+
+	_, err := c.group.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: c.group.DeadLetterStream(),
+			MaxLen: c.group.config.MaxLen,
+			Approx: c.group.config.MaxLen > 0,
+			Values: values,
+		})
+		pipe.XAck(ctx, c.group.stream, c.group.name, msg.ID)
+		return nil
+	})
+	if err == nil {
+		log.Printf("Message %s moved to dead letter stream %s after %d deliveries",
+			msg.ID, c.group.DeadLetterStream(), msg.Deliveries-1)
+	}
+	return err
+}
+
+// deliveries 从待确认列表中查询消息的投递次数
+func (c *Consumer) deliveries(ctx context.Context, msgs []redis.XMessage) (map[string]int64, error) {
+	cmds := make([]*redis.XPendingExtCmd, len(msgs))
+	_, err := c.group.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, msg := range msgs {
+			cmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: c.group.stream,
+				Group:  c.group.name,
+				Start:  msg.ID,
+				End:    msg.ID,
+				Count:  1,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make(map[string]int64, len(msgs))
+	for i, msg := range msgs {
+		if pending := cmds[i].Val(); len(pending) > 0 {
+			deliveries[msg.ID] = pending[0].RetryCount
+		}
+	}
+	return deliveries, nil
+}
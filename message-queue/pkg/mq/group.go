@@ -0,0 +1,143 @@
+package mq
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Group 流上的消费者组，组内的消费者竞争消费，每条消息只投递给其中一个消费者
+// 不同的组各自独立地消费完整的流
+type Group struct {
+	client redis.UniversalClient
+	stream string
+	name   string
+	config Config
+}
+
+// GroupInfo 消费者组的状态
+type GroupInfo struct {
+	Name string `json:"name"`
+	// 组内的消费者数
+	Consumers int64 `json:"consumers"`
+	// 已经投递但没有确认的消息数
+	Pending int64 `json:"pending"`
+	// 最后投递给组内消费者的消息ID
+	LastDeliveredID string `json:"last_delivered_id"`
+	// 还没有投递给组的消息数（Redis 7.0以上）
+	Lag int64 `json:"lag"`
+}
+
+// ConsumerInfo 消费者的状态
+type ConsumerInfo struct {
+	Name string `json:"name"`
+	// 投递给这个消费者但没有确认的消息数
+	Pending int64 `json:"pending"`
+	// 距离上一次读取或接管消息的时间（毫秒）
+	IdleMs int64 `json:"idle_ms"`
+}
+
+// NewGroup 返回stream上名为name的消费者组，需要先调用 Create 在Redis中创建
+func NewGroup(client redis.UniversalClient, stream, name string, config Config) *Group {
+	return &Group{client: client, stream: stream, name: name, config: config}
+}
+
+// NewDefaultGroup 使用默认配置返回消费者组
+func NewDefaultGroup(client redis.UniversalClient, stream, name string) *Group {
+	return NewGroup(client, stream, name, DefaultConfig)
+}
+
+// Name 返回消费者组的名称
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Stream 返回流的名称
+func (g *Group) Stream() string {
+	return g.stream
+}
+
+// DeadLetterStream 返回死信流的名称
+func (g *Group) DeadLetterStream() string {
+	return g.stream + g.config.DeadLetterSuffix
+}
+
+// Create 创建消费者组，流不存在时一并创建；组已经存在时不做任何事
+// start为组开始消费的位置："$"只消费之后发布的消息，"0"从流的第一条消息开始
+func (g *Group) Create(ctx context.Context, start string) error {
+	err := g.client.XGroupCreateMkStream(ctx, g.stream, g.name, start).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// Destroy 删除消费者组及其待确认列表，流中的消息不受影响
+func (g *Group) Destroy(ctx context.Context) error {
+	return g.client.XGroupDestroy(ctx, g.stream, g.name).Err()
+}
+
+// Info 返回消费者组的状态，组不存在时返回nil
+func (g *Group) Info(ctx context.Context) (*GroupInfo, error) {
+	groups, err := g.client.XInfoGroups(ctx, g.stream).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if group.Name == g.name {
+			return &GroupInfo{
+				Name:            group.Name,
+				Consumers:       group.Consumers,
+				Pending:         group.Pending,
+				LastDeliveredID: group.LastDeliveredID,
+				Lag:             group.Lag,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Consumers 返回组内的消费者
+func (g *Group) Consumers(ctx context.Context) ([]ConsumerInfo, error) {
+	consumers, err := g.client.XInfoConsumers(ctx, g.stream, g.name).Result()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ConsumerInfo, len(consumers))
+	for i, consumer := range consumers {
+		infos[i] = ConsumerInfo{
+			Name:    consumer.Name,
+			Pending: consumer.Pending,
+			IdleMs:  consumer.Idle.Milliseconds(),
+		}
+	}
+	return infos, nil
+}
+
+// DeleteConsumer 从组中删除消费者，返回它待确认的消息数
+// 消费者的待确认消息会随之删除、不再投递，应在它们被其他消费者接管之后再删除
+func (g *Group) DeleteConsumer(ctx context.Context, consumer string) (int64, error) {
+	return g.client.XGroupDelConsumer(ctx, g.stream, g.name, consumer).Result()
+}
+
+// Pending 返回组内待确认的消息，按ID从小到大最多count条
+func (g *Group) Pending(ctx context.Context, count int64) ([]redis.XPendingExt, error) {
+	return g.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: g.stream,
+		Group:  g.name,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+}
+
+// DeadLetters 返回死信流中最早的count条消息，消息中附加了原消息的ID、消费者组和投递次数
+func (g *Group) DeadLetters(ctx context.Context, count int64) ([]redis.XMessage, error) {
+	return g.client.XRangeN(ctx, g.DeadLetterStream(), "-", "+", count).Result()
+}
+
+// Consumer 返回组内名为name的消费者，同一个名称的消费者共享待确认列表，进程重启后应使用相同的名称
+func (g *Group) Consumer(name string) *Consumer {
+	return &Consumer{group: g, name: name}
+}
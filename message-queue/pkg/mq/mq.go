@@ -0,0 +1,58 @@
+package mq
+
+import (
+	"context"
+	"time"
+)
+
+// Config 消息队列配置
+type Config struct {
+	// 流的最大长度，发布时以 MAXLEN ~ 近似裁剪旧消息，0表示不裁剪
+	// 裁剪不区分消息是否已经确认，应远大于消费者积压的消息数
+	MaxLen int64
+	// 每次读取的最多消息数
+	Count int64
+	// XREADGROUP 没有新消息时阻塞等待的时间
+	Block time.Duration
+	// 消息在待确认列表中空闲超过该时间时，视为领取它的消费者已经崩溃，由其他消费者通过 XAUTOCLAIM 接管
+	ClaimIdle time.Duration
+	// 检查可以接管的消息的间隔
+	ReclaimInterval time.Duration
+	// 每条消息最多投递的次数，超过时移入死信流并确认
+	MaxDeliveries int64
+	// 死信流名称的后缀，死信流为 流名称 + 后缀
+	DeadLetterSuffix string
+}
+
+// DefaultConfig 默认消息队列配置
+var DefaultConfig = Config{
+	MaxLen:           100000,
+	Count:            10,
+	Block:            2 * time.Second,
+	ClaimIdle:        30 * time.Second,
+	ReclaimInterval:  10 * time.Second,
+	MaxDeliveries:    3,
+	DeadLetterSuffix: ":dead",
+}
+
+// Message 从流中读取的消息
+type Message struct {
+	ID     string
+	Values map[string]any
+	// 已经投递的次数，包括这一次
+	Deliveries int64
+}
+
+// Handler 处理一条消息，返回nil时确认消息；返回错误时消息留在待确认列表中，
+// 空闲超过 ClaimIdle 后被重新投递，投递次数超过 MaxDeliveries 时移入死信流
+type Handler func(ctx context.Context, msg Message) error
+
+// 死信消息中附加的字段
+const (
+	// DeadLetterSourceID 原消息的ID
+	DeadLetterSourceID = "dead_source_id"
+	// DeadLetterGroup 放弃这条消息的消费者组
+	DeadLetterGroup = "dead_group"
+	// DeadLetterDeliveries 放弃前已经投递的次数
+	DeadLetterDeliveries = "dead_deliveries"
+)
@@ -0,0 +1,34 @@
+package mq
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Producer 向流中发布消息
+type Producer struct {
+	client redis.UniversalClient
+	stream string
+	config Config
+}
+
+// NewProducer 创建向stream发布消息的生产者
+func NewProducer(client redis.UniversalClient, stream string, config Config) *Producer {
+	return &Producer{client: client, stream: stream, config: config}
+}
+
+// NewDefaultProducer 使用默认配置创建生产者
+func NewDefaultProducer(client redis.UniversalClient, stream string) *Producer {
+	return NewProducer(client, stream, DefaultConfig)
+}
+
+// Publish 以 XADD 发布一条消息，返回Redis生成的消息ID；设置了 MaxLen 时同时近似裁剪旧消息
+func (p *Producer) Publish(ctx context.Context, values map[string]any) (string, error) {
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		MaxLen: p.config.MaxLen,
+		Approx: p.config.MaxLen > 0,
+		Values: values,
+	}).Result()
+}
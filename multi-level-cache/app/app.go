@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"multi-level-cache/internal/admin"
+	"multi-level-cache/internal/cache"
+	"multi-level-cache/internal/config"
+
+	"redis-learning/pkg/bloom"
+	"redis-learning/pkg/cli"
+	"redis-learning/pkg/metrics"
+)
+
+// Run 运行多级缓存的演示，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	// 加载配置，优先级：环境变量 > 配置文件 > 默认值
+	fs := cli.NewFlagSet("cache-demo")
+	configPath := fs.String("config", "", "path of the YAML config file")
+	metricsAddr := fs.String("metrics-addr", "", "keep running after the demo and serve Prometheus metrics and the admin API on this address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// 创建本地缓存和Redis缓存
+	local, err := cache.NewLocalCache(&cfg.LocalCache)
+	if err != nil {
+		return fmt.Errorf("failed to init local cache: %w", err)
+	}
+	registry := metrics.NewRegistry()
+	redis, err := cache.NewRedisCache(&cfg.Redis, cache.WithRedisHooks(registry.RedisHook("multi-level-cache")))
+	if err != nil {
+		return fmt.Errorf("failed to init redis cache: %w", err)
+	}
+
+	// 创建多级缓存
+	opts := []cache.Option{cache.WithConfig(&cfg.MultiLevelCache)}
+	if cfg.MultiLevelCache.EnableBloomFilter {
+		opts = append(opts, cache.WithFilter(bloom.NewFilter(redis.Client(), cfg.MultiLevelCache.BloomFilterKey, bloom.Config{
+			ExpectedItems:     cfg.MultiLevelCache.BloomFilterExpectedItems,
+			FalsePositiveRate: cfg.MultiLevelCache.BloomFilterFalsePositiveRate,
+		})))
+	}
+	mc := cache.NewMultiLevelCache(local, redis, opts...)
+
+	key := "demo_key"
+	value := []byte("hello multi-level cache")
+
+	// 写入缓存
+	if err := mc.Set(ctx, key, value, 30*time.Second); err != nil {
+		return fmt.Errorf("failed to set key: %w", err)
+	}
+	fmt.Println("Set success")
+
+	// 读取缓存
+	val, err := mc.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+	fmt.Printf("Get success, value: %s\n", string(val))
+
+	// 检查key是否存在
+	exists, _ := mc.Exists(ctx, key)
+	fmt.Printf("Exists: %v\n", exists)
+
+	// 删除缓存
+	if err := mc.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+	fmt.Println("Delete success")
+
+	// 再次读取，应该未命中
+	_, err = mc.Get(ctx, key)
+	if err != nil {
+		fmt.Printf("Get after delete (should miss): %v\n", err)
+	}
+
+	// 打印缓存指标
+	mc.PrintMetrics()
+
+	// 设置了 -metrics-addr 时继续运行，输出Prometheus指标和调试接口，直到收到中断信号
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(metrics.Path, registry.Handler())
+		mux.Handle("/admin/", http.StripPrefix("/admin", admin.NewHandler(mc)))
+		if err := cli.ServeHTTP(ctx, &http.Server{Addr: *metricsAddr, Handler: mux}); err != nil {
+			mc.Close()
+			return err
+		}
+	}
+
+	// 关闭缓存资源
+	return mc.Close()
+}
@@ -0,0 +1,246 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"multi-level-cache/internal/cache"
+	"multi-level-cache/pkg/metrics"
+	"multi-level-cache/pkg/utils"
+)
+
+// Handler 多级缓存的调试接口，提供指标查询、单个key的状态查询和手动失效
+// 接口不做鉴权，只应在内网或调试端口上暴露
+type Handler struct {
+	cache *cache.MultiLevelCache
+	mux   *http.ServeMux
+}
+
+// NewHandler 创建调试接口，可以通过http.StripPrefix挂载到任意路径下
+func NewHandler(mc *cache.MultiLevelCache) *Handler {
+	h := &Handler{cache: mc, mux: http.NewServeMux()}
+	// 获取指标快照
+	h.mux.HandleFunc("GET /stats", h.GetStats)
+	// 查询key在各层缓存中的状态
+	h.mux.HandleFunc("GET /keys/{key...}", h.GetKey)
+	// 删除key，scope=local时只删除本地缓存
+	h.mux.HandleFunc("DELETE /keys/{key...}", h.DeleteKey)
+	// 命中和未命中次数最多的key，n指定数量
+	h.mux.HandleFunc("GET /top-keys", h.GetTopKeys)
+	return h
+}
+
+// ServeHTTP 实现http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// levelStatsResponse 单个层级的指标
+type levelStatsResponse struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+	Sets     int64   `json:"sets"`
+	Errors   int64   `json:"errors"`
+	Bytes    int64   `json:"bytes"`
+	// 各操作的延迟，单位为微秒
+	Latencies map[string]latencyResponse `json:"latencies"`
+}
+
+// latencyResponse 单个操作的延迟分布，单位为微秒
+type latencyResponse struct {
+	Count int64 `json:"count"`
+	Avg   int64 `json:"avg_us"`
+	P50   int64 `json:"p50_us"`
+	P99   int64 `json:"p99_us"`
+	Max   int64 `json:"max_us"`
+}
+
+// statsResponse 指标快照
+type statsResponse struct {
+	Hits            int64                                `json:"hits"`
+	Misses          int64                                `json:"misses"`
+	HitRatio        float64                              `json:"hit_ratio"`
+	NegativeHits    int64                                `json:"negative_hits"`
+	Sets            int64                                `json:"sets"`
+	CoalescedWrites int64                                `json:"coalesced_writes"`
+	Deletes         int64                                `json:"deletes"`
+	Levels          map[metrics.Level]levelStatsResponse `json:"levels"`
+	Degradation     *metrics.DegradationStats            `json:"degradation,omitempty"`
+}
+
+// keyResponse key在各层缓存中的状态，过期时间单位为毫秒
+type keyResponse struct {
+	Key        string `json:"key"`
+	InLocal    bool   `json:"in_local"`
+	InRedis    bool   `json:"in_redis"`
+	Size       int    `json:"size"`
+	Chunks     int    `json:"chunks,omitempty"`
+	NullValue  bool   `json:"null_value"`
+	Mismatch   bool   `json:"mismatch"`
+	LocalTTLMs int64  `json:"local_ttl_ms"`
+	RedisTTLMs int64  `json:"redis_ttl_ms"`
+	Stale      bool   `json:"stale"`
+	// 本地缓存条目的元数据，不在本地缓存中时省略
+	Local *localEntryResponse `json:"local,omitempty"`
+}
+
+// localEntryResponse 本地缓存条目的元数据
+type localEntryResponse struct {
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source"`
+	Hits      int64     `json:"hits"`
+	Size      int64     `json:"size"`
+}
+
+// GetStats 返回指标快照
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats := h.cache.Stats()
+	resp := statsResponse{
+		Hits:            stats.Hits,
+		Misses:          stats.Misses,
+		HitRatio:        stats.HitRatio,
+		NegativeHits:    stats.NegativeHits,
+		Sets:            stats.Sets,
+		CoalescedWrites: stats.CoalescedWrites,
+		Deletes:         stats.Deletes,
+		Levels:          make(map[metrics.Level]levelStatsResponse, len(stats.Levels)),
+		Degradation:     stats.Degradation,
+	}
+	for level, ls := range stats.Levels {
+		latencies := make(map[string]latencyResponse)
+		for op, hs := range stats.Latencies[level] {
+			latencies[op] = latencyResponse{
+				Count: hs.Count,
+				Avg:   hs.Mean().Microseconds(),
+				P50:   hs.Quantile(0.5).Microseconds(),
+				P99:   hs.Quantile(0.99).Microseconds(),
+				Max:   hs.Max.Microseconds(),
+			}
+		}
+		resp.Levels[level] = levelStatsResponse{
+			Hits:      ls.Hits,
+			Misses:    ls.Misses,
+			HitRatio:  ls.HitRatio(),
+			Sets:      ls.Sets,
+			Errors:    ls.Errors,
+			Bytes:     ls.Bytes,
+			Latencies: latencies,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetKey 返回key在各层缓存中的状态，两层都不存在时返回404
+func (h *Handler) GetKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	info, err := h.cache.Lookup(r.Context(), key)
+	if err != nil {
+		utils.LogError("Admin lookup error, key: %s, error: %v", key, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := keyResponse{
+		Key:        info.Key,
+		InLocal:    info.InLocal,
+		InRedis:    info.InRedis,
+		Size:       info.Size,
+		Chunks:     info.Chunks,
+		NullValue:  info.NullValue,
+		Mismatch:   info.Mismatch,
+		LocalTTLMs: info.LocalTTL.Milliseconds(),
+		RedisTTLMs: info.RedisTTL.Milliseconds(),
+		Stale:      info.Stale,
+	}
+	if meta := info.LocalMeta; meta != nil {
+		resp.Local = &localEntryResponse{
+			CreatedAt: meta.CreatedAt,
+			Source:    string(meta.Source),
+			Hits:      meta.Hits,
+			Size:      meta.Size,
+		}
+	}
+	if !info.InLocal && !info.InRedis {
+		writeJSON(w, http.StatusNotFound, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DeleteKey 删除key，scope=local时只删除本地缓存，否则同时删除两层缓存
+func (h *Handler) DeleteKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	var err error
+	switch scope := r.URL.Query().Get("scope"); scope {
+	case "local":
+		err = h.cache.InvalidateLocal(r.Context(), key)
+	case "", "all":
+		err = h.cache.Delete(r.Context(), key)
+	default:
+		writeError(w, http.StatusBadRequest, "invalid scope: "+scope)
+		return
+	}
+	if err != nil {
+		utils.LogError("Admin delete error, key: %s, error: %v", key, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// topKeysResponse 命中和未命中次数最多的key
+type topKeysResponse struct {
+	Hits   []keyCountResponse `json:"hits"`
+	Misses []keyCountResponse `json:"misses"`
+}
+
+// keyCountResponse key及其估计的访问次数
+type keyCountResponse struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// defaultTopKeys 未指定n时返回的key数量
+const defaultTopKeys = 20
+
+// GetTopKeys 返回命中和未命中次数最多的key，未启用EnableKeyStats时返回空列表
+func (h *Handler) GetTopKeys(w http.ResponseWriter, r *http.Request) {
+	n := defaultTopKeys
+	if v := r.URL.Query().Get("n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid n: "+v)
+			return
+		}
+		n = parsed
+	}
+	writeJSON(w, http.StatusOK, topKeysResponse{
+		Hits:   toKeyCountResponse(h.cache.TopHitKeys(n)),
+		Misses: toKeyCountResponse(h.cache.TopMissedKeys(n)),
+	})
+}
+
+// toKeyCountResponse 转换为响应格式，nil转换为空列表
+func toKeyCountResponse(keys []cache.KeyCount) []keyCountResponse {
+	resp := make([]keyCountResponse, 0, len(keys))
+	for _, k := range keys {
+		resp = append(resp, keyCountResponse{Key: k.Key, Count: k.Count})
+	}
+	return resp
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		utils.LogError("Failed to write admin response: %v", err)
+	}
+}
+
+// writeError 写入错误响应
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backfillEntry 排队等待写入本地缓存的值
+type backfillEntry struct {
+	value      []byte
+	expiration time.Duration
+}
+
+// backfillCache 包装本地缓存，Redis命中后的本地回填交给一个后台协程异步执行，不占用请求的处理时间
+// 同一个key排队期间只保留最后一次回填；其他写操作会先取消该key排队中的回填，避免旧值覆盖新写入的值
+type backfillCache struct {
+	Cache
+	// mu 保护pending，后台协程持有mu执行回填，取消回填返回时该key不会再被回填
+	mu      sync.Mutex
+	pending map[string]backfillEntry
+	queue   chan string
+	closed  bool
+	done    chan struct{}
+}
+
+// newBackfillCache 创建异步回填包装并启动后台协程，size为最多排队的key数量
+func newBackfillCache(c Cache, size int) *backfillCache {
+	b := &backfillCache{
+		Cache:   c,
+		pending: make(map[string]backfillEntry),
+		queue:   make(chan string, size),
+		done:    make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// enqueue 把回填加入队列，key已在排队时只更新要写入的值
+// 队列已满时放弃本次回填，下次读取仍会从Redis回填
+func (c *backfillCache) enqueue(key string, value []byte, expiration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	entry := backfillEntry{value: value, expiration: expiration}
+	if _, ok := c.pending[key]; ok {
+		c.pending[key] = entry
+		return
+	}
+	select {
+	case c.queue <- key:
+		c.pending[key] = entry
+	default:
+	}
+}
+
+// run 依次执行排队的回填，队列关闭后处理完剩余的回填再退出
+func (c *backfillCache) run() {
+	defer close(c.done)
+	ctx := withEntrySource(context.Background(), SourceRedis)
+	for key := range c.queue {
+		c.mu.Lock()
+		if entry, ok := c.pending[key]; ok {
+			delete(c.pending, key)
+			_ = c.Cache.Set(ctx, key, entry.value, entry.expiration)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// cancel 取消指定key排队中的回填
+func (c *backfillCache) cancel(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.pending, key)
+	}
+}
+
+// cancelPrefix 取消以prefix开头的key排队中的回填，prefix为空时取消所有回填
+func (c *backfillCache) cancelPrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.pending {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.pending, key)
+		}
+	}
+}
+
+func (c *backfillCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	c.cancel(key)
+	return c.Cache.Set(ctx, key, value, expiration)
+}
+
+func (c *backfillCache) Delete(ctx context.Context, key string) error {
+	c.cancel(key)
+	return c.Cache.Delete(ctx, key)
+}
+
+func (c *backfillCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	c.mu.Lock()
+	for key := range items {
+		delete(c.pending, key)
+	}
+	c.mu.Unlock()
+	return c.Cache.MSet(ctx, items, expiration)
+}
+
+func (c *backfillCache) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	c.cancel(key)
+	return c.Cache.SetNX(ctx, key, value, expiration)
+}
+
+func (c *backfillCache) GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) ([]byte, error) {
+	c.cancel(key)
+	return c.Cache.GetSet(ctx, key, value, expiration)
+}
+
+func (c *backfillCache) CompareAndSwap(ctx context.Context, key string, old, new []byte, expiration time.Duration) (bool, error) {
+	c.cancel(key)
+	return c.Cache.CompareAndSwap(ctx, key, old, new, expiration)
+}
+
+// Close 执行完排队中的回填后关闭本地缓存
+func (c *backfillCache) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+	<-c.done
+	return c.Cache.Close()
+}
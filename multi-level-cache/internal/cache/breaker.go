@@ -0,0 +1,364 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"multi-level-cache/pkg/metrics"
+)
+
+// breakerState 熔断器状态
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // 正常访问Redis
+	breakerOpen                         // 熔断，直接拒绝访问Redis
+	breakerHalfOpen                     // 熔断超时后放行一个探测请求
+)
+
+// circuitBreaker 连续失败达到阈值后打开，经过openTimeout后放行一个探测请求，
+// 探测成功则关闭，失败则重新打开
+type circuitBreaker struct {
+	mu          sync.Mutex
+	state       breakerState
+	failures    int
+	threshold   int
+	openTimeout time.Duration
+	openedAt    time.Time
+	probing     bool
+	// 状态变化时调用，在释放锁之后执行
+	onStateChange func(from, to breakerState)
+}
+
+// newCircuitBreaker 创建熔断器
+func newCircuitBreaker(threshold int, openTimeout time.Duration, onStateChange func(from, to breakerState)) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &circuitBreaker{threshold: threshold, openTimeout: openTimeout, onStateChange: onStateChange}
+}
+
+// allow 判断本次请求是否可以访问Redis
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			b.mu.Unlock()
+			return false
+		}
+		b.probing = true
+		b.transition(breakerHalfOpen)
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			return false
+		}
+		b.probing = true
+		b.mu.Unlock()
+		return true
+	default:
+		b.mu.Unlock()
+		return true
+	}
+}
+
+// record 记录一次请求的结果，只有Redis内部错误和超时算作失败，调用方取消不计入
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	if errors.Is(err, context.Canceled) {
+		if b.state == breakerHalfOpen {
+			b.probing = false
+		}
+		b.mu.Unlock()
+		return
+	}
+	failed := errors.Is(err, ErrCacheInternal) || errors.Is(err, context.DeadlineExceeded)
+	switch b.state {
+	case breakerClosed:
+		if !failed {
+			b.failures = 0
+			break
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = time.Now()
+			b.transition(breakerOpen)
+			return
+		}
+	case breakerHalfOpen:
+		b.probing = false
+		b.failures = 0
+		if failed {
+			b.openedAt = time.Now()
+			b.transition(breakerOpen)
+		} else {
+			b.transition(breakerClosed)
+		}
+		return
+	}
+	b.mu.Unlock()
+}
+
+// transition 切换状态并释放锁，然后调用状态变化回调，调用方需持有锁
+func (b *circuitBreaker) transition(to breakerState) {
+	from := b.state
+	b.state = to
+	b.mu.Unlock()
+	if b.onStateChange != nil && from != to {
+		b.onStateChange(from, to)
+	}
+}
+
+// isOpen 判断当前是否处于熔断（含半开）状态
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != breakerClosed
+}
+
+// breakerCache 包装Redis缓存，熔断时直接返回 ErrCircuitOpen，不再等待Redis超时
+type breakerCache struct {
+	Cache
+	breaker *circuitBreaker
+	metrics *metrics.CacheMetrics
+}
+
+// newBreakerCache 创建带熔断的缓存包装
+func newBreakerCache(c Cache, b *circuitBreaker, m *metrics.CacheMetrics) *breakerCache {
+	return &breakerCache{Cache: c, breaker: b, metrics: m}
+}
+
+// do 在熔断器允许时执行fn并记录结果
+func (c *breakerCache) do(fn func() error) error {
+	if !c.breaker.allow() {
+		c.metrics.IncRejected()
+		return ErrCircuitOpen
+	}
+	err := fn()
+	c.breaker.record(err)
+	return err
+}
+
+func (c *breakerCache) Get(ctx context.Context, key string) (val []byte, err error) {
+	err = c.do(func() error {
+		val, err = c.Cache.Get(ctx, key)
+		return err
+	})
+	return val, err
+}
+
+// GetWithTTL 转发到底层缓存，仅在底层缓存实现了 ValueTTLReader 时使用
+func (c *breakerCache) GetWithTTL(ctx context.Context, key string) (val []byte, ttl time.Duration, err error) {
+	err = c.do(func() error {
+		val, ttl, err = c.Cache.(ValueTTLReader).GetWithTTL(ctx, key)
+		return err
+	})
+	return val, ttl, err
+}
+
+func (c *breakerCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	return c.do(func() error {
+		return c.Cache.Set(ctx, key, value, expiration)
+	})
+}
+
+func (c *breakerCache) Delete(ctx context.Context, key string) error {
+	return c.do(func() error {
+		return c.Cache.Delete(ctx, key)
+	})
+}
+
+func (c *breakerCache) Exists(ctx context.Context, key string) (ok bool, err error) {
+	err = c.do(func() error {
+		ok, err = c.Cache.Exists(ctx, key)
+		return err
+	})
+	return ok, err
+}
+
+func (c *breakerCache) MGet(ctx context.Context, keys []string) (found map[string][]byte, missing []string, err error) {
+	err = c.do(func() error {
+		found, missing, err = c.Cache.MGet(ctx, keys)
+		return err
+	})
+	return found, missing, err
+}
+
+func (c *breakerCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	return c.do(func() error {
+		return c.Cache.MSet(ctx, items, expiration)
+	})
+}
+
+func (c *breakerCache) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (ok bool, err error) {
+	err = c.do(func() error {
+		ok, err = c.Cache.SetNX(ctx, key, value, expiration)
+		return err
+	})
+	return ok, err
+}
+
+func (c *breakerCache) GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) (old []byte, err error) {
+	err = c.do(func() error {
+		old, err = c.Cache.GetSet(ctx, key, value, expiration)
+		return err
+	})
+	return old, err
+}
+
+func (c *breakerCache) CompareAndSwap(ctx context.Context, key string, old, new []byte, expiration time.Duration) (swapped bool, err error) {
+	err = c.do(func() error {
+		swapped, err = c.Cache.CompareAndSwap(ctx, key, old, new, expiration)
+		return err
+	})
+	return swapped, err
+}
+
+func (c *breakerCache) Keys(ctx context.Context, pattern string) (keys []string, err error) {
+	err = c.do(func() error {
+		keys, err = c.Cache.Keys(ctx, pattern)
+		return err
+	})
+	return keys, err
+}
+
+func (c *breakerCache) Scan(ctx context.Context, pattern string, fn func(key string) bool) error {
+	return c.do(func() error {
+		return c.Cache.Scan(ctx, pattern, fn)
+	})
+}
+
+// TTL 转发到底层缓存，仅在底层缓存实现了 TTLReader 时使用
+func (c *breakerCache) TTL(ctx context.Context, key string) (ttl time.Duration, err error) {
+	err = c.do(func() error {
+		ttl, err = c.Cache.(TTLReader).TTL(ctx, key)
+		return err
+	})
+	return ttl, err
+}
+
+// MTTL 转发到底层缓存，仅在底层缓存实现了 TTLReader 时使用
+func (c *breakerCache) MTTL(ctx context.Context, keys []string) (ttls map[string]time.Duration, err error) {
+	err = c.do(func() error {
+		ttls, err = c.Cache.(TTLReader).MTTL(ctx, keys)
+		return err
+	})
+	return ttls, err
+}
+
+// IncrBy 转发到最底层的缓存，底层缓存不支持计数时返回 ErrCacheInternal
+func (c *breakerCache) IncrBy(ctx context.Context, key string, delta int64) (val int64, err error) {
+	counter, ok := unwrap(c.Cache).(Counter)
+	if !ok {
+		return 0, ErrCacheInternal
+	}
+	err = c.do(func() error {
+		val, err = counter.IncrBy(ctx, key, delta)
+		return err
+	})
+	return val, err
+}
+
+// pendingWrite 熔断期间排队等待重放的写操作
+type pendingWrite struct {
+	key    string
+	value  []byte
+	delete bool
+	// 过期时刻，零值表示使用Redis缓存的默认过期时间
+	expireAt time.Time
+}
+
+// writeQueue 熔断期间的写操作队列，同一个key只保留最后一次写操作，超过容量时丢弃最早的写操作
+type writeQueue struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	max     int
+}
+
+// newWriteQueue 创建写操作队列
+func newWriteQueue(max int) *writeQueue {
+	return &writeQueue{order: list.New(), entries: make(map[string]*list.Element), max: max}
+}
+
+// push 加入一个写操作，返回因队列已满被丢弃的写操作数量
+func (q *writeQueue) push(w pendingWrite) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if e, ok := q.entries[w.key]; ok {
+		q.order.Remove(e)
+	}
+	q.entries[w.key] = q.order.PushBack(w)
+
+	dropped := 0
+	for q.max > 0 && q.order.Len() > q.max {
+		front := q.order.Front()
+		q.order.Remove(front)
+		delete(q.entries, front.Value.(pendingWrite).key)
+		dropped++
+	}
+	return dropped
+}
+
+// drain 取出所有写操作，按加入顺序返回
+func (q *writeQueue) drain() []pendingWrite {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	writes := make([]pendingWrite, 0, q.order.Len())
+	for e := q.order.Front(); e != nil; e = e.Next() {
+		writes = append(writes, e.Value.(pendingWrite))
+	}
+	q.order.Init()
+	q.entries = make(map[string]*list.Element)
+	return writes
+}
+
+// requeue 把重放失败的写操作放回队列头部，重放期间同一个key已有更新的写操作时丢弃旧的
+func (q *writeQueue) requeue(writes []pendingWrite) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := len(writes) - 1; i >= 0; i-- {
+		w := writes[i]
+		if _, ok := q.entries[w.key]; ok {
+			continue
+		}
+		q.entries[w.key] = q.order.PushFront(w)
+	}
+}
+
+// remove 删除指定key的写操作
+func (q *writeQueue) remove(keys ...string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, key := range keys {
+		if e, ok := q.entries[key]; ok {
+			q.order.Remove(e)
+			delete(q.entries, key)
+		}
+	}
+}
+
+// removePrefix 删除key以prefix开头的写操作
+func (q *writeQueue) removePrefix(prefix string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key, e := range q.entries {
+		if strings.HasPrefix(key, prefix) {
+			q.order.Remove(e)
+			delete(q.entries, key)
+		}
+	}
+}
+
+// len 返回队列中的写操作数量
+func (q *writeQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.order.Len()
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -12,6 +13,17 @@ var (
 	ErrInvalidKey    = errors.New("invalid key")
 	ErrInvalidValue  = errors.New("invalid value")
 	ErrCacheInternal = errors.New("internal cache error")
+	// ErrCachedNotFound 表示缓存中记录了该key对应的数据不存在（空值缓存），
+	// 与 ErrKeyNotFound 不同，调用方无需再回源查询
+	ErrCachedNotFound = errors.New("key cached as not found")
+	// ErrKeyFiltered 表示布隆过滤器判定该key一定不存在
+	ErrKeyFiltered = errors.New("key rejected by bloom filter")
+	// ErrNamespaceNotSet 表示未设置命名空间，无法按命名空间清理
+	ErrNamespaceNotSet = errors.New("namespace not set")
+	// ErrCircuitOpen 表示Redis熔断器处于打开状态，本次操作没有访问Redis
+	ErrCircuitOpen = errors.New("redis circuit breaker is open")
+	// ErrValueTooLarge 表示值超过了MaxValueSize，具体的key和大小见 ValueTooLargeError
+	ErrValueTooLarge = errors.New("value too large")
 )
 
 // Cache 定义缓存的基本操作接口
@@ -28,6 +40,27 @@ type Cache interface {
 	// Exists 检查key是否存在
 	Exists(ctx context.Context, key string) (bool, error)
 
+	// MGet 批量获取缓存的值，返回命中的键值对以及未命中的key列表
+	MGet(ctx context.Context, keys []string) (map[string][]byte, []string, error)
+
+	// MSet 批量设置缓存的值，所有key使用相同的过期时间
+	MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error
+
+	// SetNX 仅在key不存在时设置值，返回是否设置成功
+	SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error)
+
+	// GetSet 设置新值并返回旧值，key不存在时旧值为nil
+	GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) ([]byte, error)
+
+	// CompareAndSwap 仅在当前值等于old时设置为new，返回是否替换成功，key不存在时返回false
+	CompareAndSwap(ctx context.Context, key string, old, new []byte, expiration time.Duration) (bool, error)
+
+	// Keys 返回匹配pattern的所有key，pattern使用Redis通配符语法（*、?、[abc]、\转义）
+	Keys(ctx context.Context, pattern string) ([]string, error)
+
+	// Scan 遍历匹配pattern的key并依次调用fn，fn返回false时停止遍历
+	Scan(ctx context.Context, pattern string, fn func(key string) bool) error
+
 	// Name 返回缓存实现的名称，用于日志和指标
 	Name() string
 
@@ -35,11 +68,207 @@ type Cache interface {
 	Close() error
 }
 
-// Options 定义缓存的配置选项
-type Options struct {
-	// 缓存的名称
-	Name string
+// TTLReader 由支持查询剩余过期时间的缓存实现
+type TTLReader interface {
+	// TTL 返回key的剩余过期时间，key不存在时返回 ErrKeyNotFound，永不过期时返回0
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
+	// MTTL 批量返回key的剩余过期时间，不存在或永不过期的key不包含在结果中
+	MTTL(ctx context.Context, keys []string) (map[string]time.Duration, error)
+}
+
+// ValueTTLReader 由支持在一次往返中同时读取值和剩余过期时间的缓存实现
+type ValueTTLReader interface {
+	// GetWithTTL 返回key的值和剩余过期时间，key不存在时返回 ErrKeyNotFound，永不过期时剩余过期时间为0
+	GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error)
+}
+
+// EvictionNotifier 由支持淘汰与过期回调的缓存实现
+type EvictionNotifier interface {
+	// OnEvicted 注册淘汰回调，key被删除或过期清理时调用
+	OnEvicted(fn func(key string, value []byte))
+
+	// OnExpired 注册过期回调，仅在key因过期被清理时调用
+	OnExpired(fn func(key string, value []byte))
+}
+
+// NamespaceClearer 由支持按命名空间清理的缓存实现
+type NamespaceClearer interface {
+	// ClearNamespace 删除当前命名空间下的所有key，返回删除的数量
+	ClearNamespace(ctx context.Context) (int64, error)
+}
+
+// EntryInspector 由支持查询条目元数据的缓存实现
+type EntryInspector interface {
+	// Inspect 返回key的值和元数据，不计入命中次数，key不存在时返回 ErrKeyNotFound
+	Inspect(ctx context.Context, key string) ([]byte, EntryMeta, error)
+}
+
+// PrefixDeleter 由支持按前缀批量删除key的缓存实现
+type PrefixDeleter interface {
+	// DeleteByPrefix 删除以prefix开头的所有key，返回删除的数量
+	DeleteByPrefix(ctx context.Context, prefix string) (int64, error)
+}
+
+// InvalidationBroadcaster 由支持在多个实例之间广播失效消息的缓存实现
+type InvalidationBroadcaster interface {
+	// PublishInvalidation 向订阅channel的所有实例发布失效消息
+	PublishInvalidation(ctx context.Context, channel, payload string) error
+
+	// SubscribeInvalidations 订阅channel上的失效消息，一直阻塞到ctx被取消或订阅失败
+	SubscribeInvalidations(ctx context.Context, channel string, fn func(payload string)) error
+}
+
+// Locker 由支持分布式锁的缓存实现
+type Locker interface {
+	// TryLock 尝试获取锁，token用于释放时校验锁的持有者
+	TryLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+
+	// Unlock 释放锁，只有token与加锁时一致才会删除
+	Unlock(ctx context.Context, key, token string) error
+}
+
+// Sizer 由能够统计数据占用字节数的缓存实现
+type Sizer interface {
+	// Bytes 返回缓存中数据占用的大致字节数
+	Bytes() int64
+}
+
+// Counter 由支持原子计数的缓存实现
+type Counter interface {
+	// IncrBy 将key的整数值原子地增加delta并返回增加后的值，key不存在时从0开始
+	IncrBy(ctx context.Context, key string, delta int64) (int64, error)
+}
+
+// KeyEventSubscriber 由支持订阅key变更事件的缓存实现
+type KeyEventSubscriber interface {
+	// SubscribeKeyEvents 订阅key的写入、删除和过期事件，一直阻塞到ctx被取消或订阅失败
+	SubscribeKeyEvents(ctx context.Context, fn func(event, key string)) error
+}
+
+// KeyFilter 在查询Redis之前判断key是否可能存在，通常由布隆过滤器实现
+type KeyFilter interface {
+	// MightContain 返回false表示key一定不存在
+	MightContain(ctx context.Context, key string) (bool, error)
+
+	// Add 将key加入过滤器
+	Add(ctx context.Context, keys ...string) error
+}
+
+// Codec 对写入Redis的值编码、读取后解码，例如压缩，启用加密时先编码再加密
+type Codec interface {
+	Encode(value []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+// Encryptor 对写入Redis的值加密、读取后解密，用于在共享的Redis中缓存敏感数据
+// key作为附加认证数据传入，实现应保证密文不能挪到其他key下使用
+type Encryptor interface {
+	Encrypt(key string, plaintext []byte) ([]byte, error)
+	Decrypt(key string, ciphertext []byte) ([]byte, error)
+}
+
+// namespacePrefix 返回命名空间对应的key前缀，命名空间为空时不加前缀
+func namespacePrefix(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	return namespace + ":"
+}
+
+// escapeGlob 转义Redis匹配模式中的特殊字符，使前缀按字面匹配
+func escapeGlob(s string) string {
+	return globReplacer.Replace(s)
+}
+
+var globReplacer = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// matchPattern 判断key是否匹配Redis通配符语法的pattern
+func matchPattern(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			// 合并连续的*，pattern剩余部分为空时匹配任意后缀
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if matchPattern(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if key == "" {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		case '[':
+			if key == "" {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				// 没有闭合的]，按普通字符处理
+				if key[0] != '[' {
+					return false
+				}
+				pattern, key = pattern[1:], key[1:]
+				continue
+			}
+			class := pattern[1 : end+1]
+			if !matchClass(class, key[0]) {
+				return false
+			}
+			pattern, key = pattern[end+2:], key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if key == "" || pattern[0] != key[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+	return key == ""
+}
 
-	// 默认过期时间，如果为0则表示不过期
-	DefaultExpiration time.Duration
+// matchClass 判断字符是否属于[...]中的字符集合，支持^取反和a-z范围
+func matchClass(class string, c byte) bool {
+	negate := false
+	if strings.HasPrefix(class, "^") {
+		negate = true
+		class = class[1:]
+	}
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if class[i] == '\\' && i+1 < len(class) {
+			i++
+			if class[i] == c {
+				matched = true
+			}
+			continue
+		}
+		if i+2 < len(class) && class[i+1] == '-' {
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			matched = true
+		}
+	}
+	return matched != negate
 }
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coalescedWrite 一个key在合并窗口内的写入状态
+type coalescedWrite struct {
+	// 窗口内最后一次写入的值，dirty为false表示窗口内没有需要写入Redis的新值
+	value    []byte
+	expireAt time.Time
+	dirty    bool
+	timer    *time.Timer
+	// writing 在写入Redis期间持有，保证延迟的写入和之后对该key的其他操作按顺序到达Redis
+	writing sync.Mutex
+}
+
+// writeCoalescer 合并同一个key的频繁写入：窗口内的第一次写入立即写入Redis，
+// 之后的写入只记录最新的值，窗口结束时再写入一次，持续写入时每个窗口最多写入Redis一次
+type writeCoalescer struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*coalescedWrite
+	// flush 把合并后的值写入Redis，expiration为0时使用Redis缓存的默认过期时间
+	flush func(key string, value []byte, expiration time.Duration)
+}
+
+// newWriteCoalescer 创建写入合并器
+func newWriteCoalescer(window time.Duration, flush func(key string, value []byte, expiration time.Duration)) *writeCoalescer {
+	return &writeCoalescer{window: window, entries: make(map[string]*coalescedWrite), flush: flush}
+}
+
+// absorb 记录一次写入，key在合并窗口内已写入过Redis时返回true，由窗口结束时统一写入
+// 否则开启新的窗口并返回false和done，调用方应立即写入Redis，写入完成后调用done
+func (c *writeCoalescer) absorb(key string, value []byte, expiration time.Duration) (coalesced bool, done func()) {
+	var expireAt time.Time
+	if expiration > 0 {
+		expireAt = time.Now().Add(expiration)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.value, e.expireAt, e.dirty = value, expireAt, true
+		return true, nil
+	}
+	e := &coalescedWrite{}
+	e.writing.Lock()
+	e.timer = time.AfterFunc(c.window, func() { c.fire(key, e) })
+	c.entries[key] = e
+	return false, e.writing.Unlock
+}
+
+// fire 窗口结束时写入窗口内最后一次写入的值，并开启下一个窗口；窗口内没有新的写入时结束合并
+func (c *writeCoalescer) fire(key string, e *coalescedWrite) {
+	e.writing.Lock()
+	defer e.writing.Unlock()
+
+	c.mu.Lock()
+	if current, ok := c.entries[key]; !ok || current != e {
+		c.mu.Unlock()
+		return
+	}
+	if !e.dirty {
+		delete(c.entries, key)
+		c.mu.Unlock()
+		return
+	}
+	value, expireAt := e.value, e.expireAt
+	e.value, e.dirty = nil, false
+	e.timer.Reset(c.window)
+	c.mu.Unlock()
+
+	c.flush(key, value, remainingExpiration(expireAt))
+}
+
+// cancel 丢弃指定key尚未写入的合并值，等待正在进行的写入完成后返回，
+// 调用方随后对这些key的写入或删除不会被合并的旧值覆盖
+func (c *writeCoalescer) cancel(keys ...string) {
+	c.mu.Lock()
+	removed := make([]*coalescedWrite, 0, len(keys))
+	for _, key := range keys {
+		if e, ok := c.entries[key]; ok {
+			e.timer.Stop()
+			delete(c.entries, key)
+			removed = append(removed, e)
+		}
+	}
+	c.mu.Unlock()
+	waitWrites(removed)
+}
+
+// cancelPrefix 丢弃以prefix开头的key尚未写入的合并值，prefix为空时丢弃所有合并值
+func (c *writeCoalescer) cancelPrefix(prefix string) {
+	c.mu.Lock()
+	var removed []*coalescedWrite
+	for key, e := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			e.timer.Stop()
+			delete(c.entries, key)
+			removed = append(removed, e)
+		}
+	}
+	c.mu.Unlock()
+	waitWrites(removed)
+}
+
+// flushAll 立即写入所有尚未写入的合并值并结束合并，在关闭时调用
+func (c *writeCoalescer) flushAll() {
+	c.mu.Lock()
+	entries := c.entries
+	c.entries = make(map[string]*coalescedWrite)
+	for _, e := range entries {
+		e.timer.Stop()
+	}
+	c.mu.Unlock()
+
+	for key, e := range entries {
+		e.writing.Lock()
+		if e.dirty {
+			c.flush(key, e.value, remainingExpiration(e.expireAt))
+		}
+		e.writing.Unlock()
+	}
+}
+
+// waitWrites 等待正在进行的写入完成
+func waitWrites(entries []*coalescedWrite) {
+	for _, e := range entries {
+		// 加锁后立即释放，只用于等待持有者写入完成
+		e.writing.Lock()
+		e.writing.Unlock()
+	}
+}
+
+// remainingExpiration 返回距离过期时刻的剩余时间，零值表示使用默认过期时间，已过期时返回最小的正值
+func remainingExpiration(expireAt time.Time) time.Duration {
+	if expireAt.IsZero() {
+		return 0
+	}
+	if d := time.Until(expireAt); d > 0 {
+		return d
+	}
+	return time.Millisecond
+}
+
+// flushCoalesced 把合并后的值写入Redis，写入失败时删除本地副本，避免本实例长期读到Redis中没有的值
+func (m *MultiLevelCache) flushCoalesced(key string, value []byte, expiration time.Duration) {
+	ctx := context.Background()
+	if err := m.writeRedis(ctx, key, value, expiration); err != nil {
+		m.logger.Errorf("Coalesced redis write error, key: %s, error: %v", key, err)
+		_ = m.local.Delete(ctx, key)
+	}
+}
+
+// cancelCoalesced 丢弃指定key尚未写入Redis的合并值，未启用写入合并时不做任何事
+func (m *MultiLevelCache) cancelCoalesced(keys ...string) {
+	if m.coalescer != nil {
+		m.coalescer.cancel(keys...)
+	}
+}
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"multi-level-cache/pkg/utils"
+)
+
+// codecCache 包装Redis缓存，写入前对值编码（压缩、加密等）、读取后解码，本地缓存中保存的仍是原始值
+type codecCache struct {
+	Cache
+	// 编码、解码操作的名称，用于错误信息
+	encodeOp string
+	decodeOp string
+	encode   func(key string, value []byte) ([]byte, error)
+	decode   func(key string, data []byte) ([]byte, error)
+	logger   utils.Logger
+}
+
+// newCodecCache 创建按 Codec 编码的包装
+func newCodecCache(c Cache, codec Codec, logger utils.Logger) *codecCache {
+	return &codecCache{
+		Cache:    c,
+		encodeOp: "encode",
+		decodeOp: "decode",
+		encode:   func(_ string, value []byte) ([]byte, error) { return codec.Encode(value) },
+		decode:   func(_ string, data []byte) ([]byte, error) { return codec.Decode(data) },
+		logger:   logger,
+	}
+}
+
+// newEncryptedCache 创建按 Encryptor 加密的包装
+func newEncryptedCache(c Cache, encryptor Encryptor, logger utils.Logger) *codecCache {
+	return &codecCache{
+		Cache:    c,
+		encodeOp: "encrypt",
+		decodeOp: "decrypt",
+		encode:   encryptor.Encrypt,
+		decode:   encryptor.Decrypt,
+		logger:   logger,
+	}
+}
+
+func (c *codecCache) encodeValue(key string, value []byte) ([]byte, error) {
+	if value == nil {
+		return nil, ErrInvalidValue
+	}
+	data, err := c.encode(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s value of key %s: %w", c.encodeOp, key, err)
+	}
+	return data, nil
+}
+
+func (c *codecCache) decodeValue(key string, data []byte) ([]byte, error) {
+	value, err := c.decode(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s value of key %s: %w", c.decodeOp, key, err)
+	}
+	return value, nil
+}
+
+func (c *codecCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decodeValue(key, data)
+}
+
+// GetWithTTL 转发到底层缓存并解码值
+func (c *codecCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	data, ttl, err := c.Cache.(ValueTTLReader).GetWithTTL(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	value, err := c.decodeValue(key, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, ttl, nil
+}
+
+func (c *codecCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	data, err := c.encodeValue(key, value)
+	if err != nil {
+		return err
+	}
+	return c.Cache.Set(ctx, key, data, expiration)
+}
+
+// MGet 批量获取并解码，解码失败的key视为未命中
+func (c *codecCache) MGet(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	found, missing, err := c.Cache.MGet(ctx, keys)
+	if err != nil {
+		return found, missing, err
+	}
+	for key, data := range found {
+		value, err := c.decodeValue(key, data)
+		if err != nil {
+			c.logger.Errorf("Redis MGET %v", err)
+			delete(found, key)
+			missing = append(missing, key)
+			continue
+		}
+		found[key] = value
+	}
+	return found, missing, nil
+}
+
+func (c *codecCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	encoded := make(map[string][]byte, len(items))
+	for key, value := range items {
+		data, err := c.encodeValue(key, value)
+		if err != nil {
+			return err
+		}
+		encoded[key] = data
+	}
+	return c.Cache.MSet(ctx, encoded, expiration)
+}
+
+func (c *codecCache) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	data, err := c.encodeValue(key, value)
+	if err != nil {
+		return false, err
+	}
+	return c.Cache.SetNX(ctx, key, data, expiration)
+}
+
+// GetSet 写入新值并返回解码后的旧值，旧值无法解码时返回nil
+func (c *codecCache) GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) ([]byte, error) {
+	data, err := c.encodeValue(key, value)
+	if err != nil {
+		return nil, err
+	}
+	old, err := c.Cache.GetSet(ctx, key, data, expiration)
+	if err != nil || old == nil {
+		return old, err
+	}
+	old, err = c.decodeValue(key, old)
+	if err != nil {
+		c.logger.Errorf("Redis GETSET %v", err)
+		return nil, nil
+	}
+	return old, nil
+}
+
+// CompareAndSwap 编码结果不一定唯一（例如每次加密使用随机nonce），无法直接比较编码后的值；
+// 先读取当前值并解码比较，再以读到的原始数据为旧值执行底层的CAS，期间被其他写入修改时替换失败
+func (c *codecCache) CompareAndSwap(ctx context.Context, key string, old, new []byte, expiration time.Duration) (bool, error) {
+	current, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	value, err := c.decodeValue(key, current)
+	if err != nil || !bytes.Equal(value, old) {
+		return false, nil
+	}
+	data, err := c.encodeValue(key, new)
+	if err != nil {
+		return false, err
+	}
+	return c.Cache.CompareAndSwap(ctx, key, current, data, expiration)
+}
+
+// TTL 转发到底层缓存
+func (c *codecCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.Cache.(TTLReader).TTL(ctx, key)
+}
+
+// MTTL 转发到底层缓存
+func (c *codecCache) MTTL(ctx context.Context, keys []string) (map[string]time.Duration, error) {
+	return c.Cache.(TTLReader).MTTL(ctx, keys)
+}
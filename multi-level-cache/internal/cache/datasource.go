@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// DataSource 缓存背后的数据源，通常是数据库
+type DataSource interface {
+	// Load 加载单个key，数据不存在时返回 ErrKeyNotFound 或 NotFound
+	Load(ctx context.Context, key string) ([]byte, error)
+
+	// LoadBatch 批量加载，返回结果中没有的key视为数据不存在
+	LoadBatch(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// ReadThroughCache 把多级缓存与数据源绑定在一起，应用代码只需要调用Get，
+// 未命中时由它从数据源加载并回填两级缓存。单个key的并发加载合并为一次（singleflight），
+// 数据不存在的结果按NullValueTTL（或数据源返回的 NotFound 指定的时间）缓存空值标记
+type ReadThroughCache struct {
+	cache      *MultiLevelCache
+	source     DataSource
+	expiration time.Duration
+}
+
+// NewReadThroughCache 创建读穿透缓存，expiration为加载的数据写入缓存的过期时间，为0时按过期策略或Redis的默认过期时间
+func NewReadThroughCache(mc *MultiLevelCache, source DataSource, expiration time.Duration) *ReadThroughCache {
+	return &ReadThroughCache{cache: mc, source: source, expiration: expiration}
+}
+
+// Get 获取key的值，两级缓存都未命中时从数据源加载，数据不存在时返回 ErrKeyNotFound
+func (c *ReadThroughCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return c.cache.GetOrLoad(ctx, key, c.expiration, c.source.Load)
+}
+
+// MGet 批量获取，两级缓存都未命中的key通过一次LoadBatch加载，返回数据源中也不存在的key列表
+// 批量加载不与其他调用合并，同一批key的并发请求可能各自访问一次数据源
+func (c *ReadThroughCache) MGet(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	return c.cache.GetMultiOrLoad(ctx, keys, c.expiration, c.source.LoadBatch)
+}
+
+// Invalidate 在数据源中的数据修改后删除缓存，下次Get时重新加载
+func (c *ReadThroughCache) Invalidate(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := c.cache.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cache 返回底层的多级缓存
+func (c *ReadThroughCache) Cache() *MultiLevelCache {
+	return c.cache
+}
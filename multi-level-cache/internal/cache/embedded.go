@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"fmt"
+
+	"github.com/alicebob/miniredis/v2"
+	"multi-level-cache/internal/config"
+)
+
+// startEmbeddedRedis 启动进程内的miniredis，返回连接它使用的standalone配置
+// miniredis不支持键事件通知和CONFIG命令，EnableKeyspaceInvalidation在该模式下不生效
+func startEmbeddedRedis(cfg *config.RedisConfig) (*miniredis.Miniredis, *config.RedisConfig, error) {
+	server, err := miniredis.Run()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start embedded redis: %w", err)
+	}
+	standalone := *cfg
+	standalone.Mode = config.RedisModeStandalone
+	standalone.Addr = server.Addr()
+	standalone.Password = ""
+	standalone.DB = 0
+	return server, &standalone, nil
+}
+
+// Embedded 返回embedded模式下进程内的miniredis，其他模式返回nil
+// 测试中可以用它直接检查数据，或通过FastForward推进时间使key确定地过期
+func (r *RedisCache) Embedded() *miniredis.Miniredis {
+	return r.embedded
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EntrySource 本地缓存条目的来源
+type EntrySource string
+
+const (
+	// SourceWrite 通过Set等写操作写入
+	SourceWrite EntrySource = "write"
+	// SourceRedis 读取时从Redis回填
+	SourceRedis EntrySource = "redis"
+	// SourceLoader 由加载函数或预热从数据源加载
+	SourceLoader EntrySource = "loader"
+
+	// sourceKeep 只更新值和过期时间，保留条目原有的元数据，用于延长热点key的本地过期时间
+	sourceKeep EntrySource = "\x00keep"
+)
+
+// EntryMeta 本地缓存条目的元数据，用于排查"为什么读到的是旧值"等问题
+type EntryMeta struct {
+	// 条目写入本地缓存的时间
+	CreatedAt time.Time
+	// 条目的来源
+	Source EntrySource
+	// 写入后在本地缓存中的命中次数
+	Hits int64
+	// 条目的大致字节数（key与value的长度之和）
+	Size int64
+}
+
+// entrySourceKey context中条目来源的键
+type entrySourceKey struct{}
+
+// withEntrySource 返回带有条目来源的context，本地缓存写入时记录该来源
+func withEntrySource(ctx context.Context, source EntrySource) context.Context {
+	return context.WithValue(ctx, entrySourceKey{}, source)
+}
+
+// entrySourceFrom 返回context中的条目来源，未设置时为 SourceWrite
+func entrySourceFrom(ctx context.Context) EntrySource {
+	if source, ok := ctx.Value(entrySourceKey{}).(EntrySource); ok {
+		return source
+	}
+	return SourceWrite
+}
+
+// Inspect 返回key的值和元数据，不计入命中次数，key不存在时返回 ErrKeyNotFound
+func (c *LocalCache) Inspect(ctx context.Context, key string) ([]byte, EntryMeta, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, EntryMeta{}, err
+	}
+	if key == "" {
+		return nil, EntryMeta{}, ErrInvalidKey
+	}
+
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, found := s.cache.Get(k)
+	if !found {
+		return nil, EntryMeta{}, ErrKeyNotFound
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil, EntryMeta{}, ErrCacheInternal
+	}
+	meta, _ := s.sizes.meta(k)
+	return bytes, meta, nil
+}
+
+// EntryInfo 本地缓存条目的元数据以及两级缓存中的剩余过期时间
+type EntryInfo struct {
+	Key string
+	EntryMeta
+	// 缓存的是空值标记（数据源中不存在）
+	NullValue bool
+	// 本地缓存的剩余过期时间
+	LocalTTL time.Duration
+	// key是否在Redis中
+	InRedis bool
+	// Redis中的逻辑剩余过期时间，不含过期宽限期
+	RedisTTL time.Duration
+	// Redis中的值已过逻辑过期时间，处于过期宽限期内
+	Stale bool
+}
+
+// Inspect 返回本地缓存条目的写入时间、来源、命中次数和大小，以及两级缓存中的剩余过期时间
+// 直接访问底层缓存，不计入指标和命中次数，也不读取Redis中的值；key不在本地缓存中时返回 ErrKeyNotFound
+func (m *MultiLevelCache) Inspect(ctx context.Context, key string) (EntryInfo, error) {
+	info := EntryInfo{Key: key}
+	local, redis := unwrap(m.local), unwrap(m.redis)
+	inspector, ok := local.(EntryInspector)
+	if !ok {
+		return info, fmt.Errorf("local cache %s does not support entry inspection: %w", local.Name(), ErrCacheInternal)
+	}
+
+	val, meta, err := inspector.Inspect(ctx, key)
+	if err != nil {
+		return info, err
+	}
+	info.EntryMeta = meta
+	info.NullValue = isNullValue(val)
+	if reader, ok := local.(TTLReader); ok {
+		info.LocalTTL, _ = reader.TTL(ctx, key)
+	}
+
+	if reader, ok := redis.(TTLReader); ok {
+		physical, err := reader.TTL(ctx, key)
+		if err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return info, fmt.Errorf("failed to get redis ttl: %w", err)
+		}
+		info.InRedis = err == nil
+		if info.InRedis {
+			info.RedisTTL, info.Stale = m.logicalTTL(physical)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HotKey 热点key及其在统计窗口内的访问次数
+type HotKey struct {
+	Key   string
+	Count int64
+}
+
+// hotKeyDetector 按固定时间窗口统计key的访问次数，访问次数达到阈值的key视为热点key
+type hotKeyDetector struct {
+	mu          sync.Mutex
+	threshold   int64
+	window      time.Duration
+	windowStart time.Time
+	// 当前窗口内各key的访问次数
+	counts map[string]int64
+	// 上一个窗口的热点key，窗口切换后继续视为热点，避免热点状态在窗口边界上抖动
+	prevHot map[string]int64
+}
+
+// newHotKeyDetector 创建热点key检测器
+func newHotKeyDetector(threshold int64, window time.Duration) *hotKeyDetector {
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &hotKeyDetector{
+		threshold:   threshold,
+		window:      window,
+		windowStart: time.Now(),
+		counts:      make(map[string]int64),
+		prevHot:     make(map[string]int64),
+	}
+}
+
+// record 记录一次访问，返回该key是否在本次访问时刚好成为热点
+func (d *hotKeyDetector) record(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotate()
+	d.counts[key]++
+	return d.counts[key] == d.threshold
+}
+
+// isHot 判断key当前是否为热点key
+func (d *hotKeyDetector) isHot(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotate()
+	if d.counts[key] >= d.threshold {
+		return true
+	}
+	_, ok := d.prevHot[key]
+	return ok
+}
+
+// hotKeys 返回当前的热点key，按访问次数从高到低排序
+func (d *hotKeyDetector) hotKeys() []HotKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotate()
+	merged := make(map[string]int64, len(d.prevHot))
+	for key, count := range d.prevHot {
+		merged[key] = count
+	}
+	for key, count := range d.counts {
+		if count >= d.threshold && count > merged[key] {
+			merged[key] = count
+		}
+	}
+
+	result := make([]HotKey, 0, len(merged))
+	for key, count := range merged {
+		result = append(result, HotKey{Key: key, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// rotate 当前窗口结束时切换到新窗口，调用方需持有锁
+func (d *hotKeyDetector) rotate() {
+	now := time.Now()
+	if now.Sub(d.windowStart) < d.window {
+		return
+	}
+
+	prevHot := make(map[string]int64)
+	// 超过两个窗口没有访问时，上一个窗口的统计已经过时
+	if now.Sub(d.windowStart) < 2*d.window {
+		for key, count := range d.counts {
+			if count >= d.threshold {
+				prevHot[key] = count
+			}
+		}
+	}
+	d.prevHot = prevHot
+	d.counts = make(map[string]int64)
+	d.windowStart = now
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"multi-level-cache/pkg/metrics"
+)
+
+// instrumentedCache 包装某一层缓存，统计该层的命中、未命中、写入、错误次数以及各操作的耗时
+type instrumentedCache struct {
+	Cache
+	level   metrics.Level
+	metrics *metrics.CacheMetrics
+}
+
+// newInstrumentedCache 创建带指标统计的缓存包装
+func newInstrumentedCache(c Cache, level metrics.Level, m *metrics.CacheMetrics) *instrumentedCache {
+	return &instrumentedCache{Cache: c, level: level, metrics: m}
+}
+
+// unwrap 去掉指标统计、熔断等包装，返回最底层的缓存
+func unwrap(c Cache) Cache {
+	for {
+		switch w := c.(type) {
+		case *instrumentedCache:
+			c = w.Cache
+		case *breakerCache:
+			c = w.Cache
+		case *sizeLimitedCache:
+			c = w.Cache
+		case *chunkedCache:
+			c = w.Cache
+		case *codecCache:
+			c = w.Cache
+		case *backfillCache:
+			c = w.Cache
+		default:
+			return c
+		}
+	}
+}
+
+// observe 记录一次操作的耗时，非"key不存在"的错误计入错误次数
+func (c *instrumentedCache) observe(op string, start time.Time, err error) {
+	c.metrics.ObserveLatency(c.level, op, time.Since(start))
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		c.metrics.IncLevelError(c.level)
+	}
+}
+
+func (c *instrumentedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	start := time.Now()
+	val, err := c.Cache.Get(ctx, key)
+	c.observe(metrics.OpGet, start, err)
+	switch {
+	case err == nil:
+		c.metrics.AddLevelHits(c.level, 1)
+	case errors.Is(err, ErrKeyNotFound):
+		c.metrics.AddLevelMisses(c.level, 1)
+	}
+	return val, err
+}
+
+// GetWithTTL 转发到底层缓存，仅在底层缓存实现了 ValueTTLReader 时使用，按一次Get统计
+func (c *instrumentedCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	start := time.Now()
+	val, ttl, err := c.Cache.(ValueTTLReader).GetWithTTL(ctx, key)
+	c.observe(metrics.OpGet, start, err)
+	switch {
+	case err == nil:
+		c.metrics.AddLevelHits(c.level, 1)
+	case errors.Is(err, ErrKeyNotFound):
+		c.metrics.AddLevelMisses(c.level, 1)
+	}
+	return val, ttl, err
+}
+
+func (c *instrumentedCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	start := time.Now()
+	err := c.Cache.Set(ctx, key, value, expiration)
+	c.observe(metrics.OpSet, start, err)
+	if err == nil {
+		c.metrics.AddLevelSets(c.level, 1)
+	}
+	return err
+}
+
+func (c *instrumentedCache) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := c.Cache.Delete(ctx, key)
+	c.observe(metrics.OpDelete, start, err)
+	return err
+}
+
+func (c *instrumentedCache) Exists(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	ok, err := c.Cache.Exists(ctx, key)
+	c.observe(metrics.OpExists, start, err)
+	return ok, err
+}
+
+func (c *instrumentedCache) MGet(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	start := time.Now()
+	found, missing, err := c.Cache.MGet(ctx, keys)
+	c.observe(metrics.OpMGet, start, err)
+	if err == nil {
+		c.metrics.AddLevelHits(c.level, int64(len(found)))
+		c.metrics.AddLevelMisses(c.level, int64(len(missing)))
+	}
+	return found, missing, err
+}
+
+func (c *instrumentedCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	start := time.Now()
+	err := c.Cache.MSet(ctx, items, expiration)
+	c.observe(metrics.OpMSet, start, err)
+	if err == nil {
+		c.metrics.AddLevelSets(c.level, int64(len(items)))
+	}
+	return err
+}
+
+// TTL 转发到底层缓存，仅在底层缓存实现了 TTLReader 时使用
+func (c *instrumentedCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := c.Cache.(TTLReader).TTL(ctx, key)
+	c.observe(metrics.OpTTL, start, err)
+	return ttl, err
+}
+
+// MTTL 转发到底层缓存，仅在底层缓存实现了 TTLReader 时使用
+func (c *instrumentedCache) MTTL(ctx context.Context, keys []string) (map[string]time.Duration, error) {
+	start := time.Now()
+	ttls, err := c.Cache.(TTLReader).MTTL(ctx, keys)
+	c.observe(metrics.OpMTTL, start, err)
+	return ttls, err
+}
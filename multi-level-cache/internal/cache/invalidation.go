@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// invalidationRetryInterval 失效消息订阅断开后重新订阅的间隔
+const invalidationRetryInterval = time.Second
+
+// invalidationMessage 实例之间广播的失效消息
+type invalidationMessage struct {
+	// 发送消息的实例，实例忽略自己发送的消息
+	Source string `json:"source"`
+	// 需要删除的本地缓存key的前缀
+	Prefix string `json:"prefix"`
+}
+
+// PublishInvalidation 通过PUBLISH在channel上发布失效消息，channel会加上命名空间前缀
+func (r *RedisCache) PublishInvalidation(ctx context.Context, channel, payload string) error {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if err := r.client.Publish(ctx, r.key(channel), payload).Err(); err != nil {
+		r.logger.Errorf("Redis PUBLISH error: %v", err)
+		return redisError(err)
+	}
+	return nil
+}
+
+// SubscribeInvalidations 订阅channel上的失效消息，对每条消息调用fn
+// 一直阻塞到ctx被取消（返回nil）或订阅失败
+func (r *RedisCache) SubscribeInvalidations(ctx context.Context, channel string, fn func(payload string)) error {
+	ps := r.client.Subscribe(ctx, r.key(channel))
+	defer ps.Close()
+
+	opCtx, cancel := r.opContext(ctx)
+	_, err := ps.Receive(opCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to subscribe invalidation channel: %w", err)
+	}
+
+	ch := ps.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return fmt.Errorf("invalidation channel closed")
+			}
+			fn(msg.Payload)
+		}
+	}
+}
+
+// DeleteByPrefix 删除以prefix开头的所有key，返回Redis中删除的数量，用于清理某个租户、某类数据的全部缓存
+// 先通过SCAN分批UNLINK Redis中的key，再删除本地缓存中匹配的副本；启用失效广播时通知其他实例删除各自的本地副本
+// prefix为空时返回 ErrInvalidKey，清空整个命名空间应使用 ClearNamespace
+func (m *MultiLevelCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	if prefix == "" {
+		return 0, ErrInvalidKey
+	}
+	deleter, ok := unwrap(m.redis).(PrefixDeleter)
+	if !ok {
+		return 0, fmt.Errorf("redis cache %s does not support prefix deletion: %w", m.redis.Name(), ErrCacheInternal)
+	}
+	if m.pending != nil {
+		m.pending.removePrefix(prefix)
+	}
+	if m.coalescer != nil {
+		m.coalescer.cancelPrefix(prefix)
+	}
+
+	// 先删除Redis再删除本地缓存，避免并发的读取把刚删除的本地副本从Redis中回填
+	removed, err := deleter.DeleteByPrefix(ctx, prefix)
+	m.evictLocalPrefix(ctx, prefix)
+	if err != nil {
+		return removed, err
+	}
+	m.logger.Infof("Deleted %d keys with prefix %q", removed, prefix)
+
+	if m.broadcaster != nil {
+		m.publishInvalidation(ctx, prefix)
+	}
+	return removed, nil
+}
+
+// evictLocalPrefix 删除本地缓存中以prefix开头的key，并取消固定
+func (m *MultiLevelCache) evictLocalPrefix(ctx context.Context, prefix string) {
+	m.unpinPrefix(prefix)
+	if m.backfill != nil {
+		m.backfill.cancelPrefix(prefix)
+	}
+	deleter, ok := unwrap(m.local).(PrefixDeleter)
+	if !ok {
+		m.logger.Errorf("Local cache %s does not support prefix deletion", m.local.Name())
+		return
+	}
+	if _, err := deleter.DeleteByPrefix(ctx, prefix); err != nil {
+		m.logger.Errorf("Local cache delete by prefix error: %v", err)
+	}
+}
+
+// publishInvalidation 通知其他实例删除本地缓存中以prefix开头的key，发布失败只记录日志
+func (m *MultiLevelCache) publishInvalidation(ctx context.Context, prefix string) {
+	payload, err := json.Marshal(invalidationMessage{Source: m.instanceID, Prefix: prefix})
+	if err != nil {
+		m.logger.Errorf("Failed to encode invalidation message: %v", err)
+		return
+	}
+	if err := m.broadcaster.PublishInvalidation(ctx, m.config.InvalidationChannel, string(payload)); err != nil {
+		m.logger.Errorf("Failed to publish invalidation, prefix: %s, error: %v", prefix, err)
+	}
+}
+
+// watchInvalidations 持续订阅其他实例的失效消息，订阅断开时自动重试，直到ctx被取消
+func (m *MultiLevelCache) watchInvalidations(ctx context.Context) {
+	defer close(m.invalidationsDone)
+	for {
+		err := m.broadcaster.SubscribeInvalidations(ctx, m.config.InvalidationChannel, m.handleInvalidation)
+		if ctx.Err() != nil {
+			return
+		}
+		m.logger.Errorf("Invalidation subscription error: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(invalidationRetryInterval):
+		}
+	}
+}
+
+// handleInvalidation 收到其他实例的失效消息时删除本地缓存中匹配的副本
+func (m *MultiLevelCache) handleInvalidation(payload string) {
+	var msg invalidationMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		m.logger.Errorf("Malformed invalidation message: %v", err)
+		return
+	}
+	if msg.Source == m.instanceID || msg.Prefix == "" {
+		return
+	}
+	m.evictLocalPrefix(context.Background(), msg.Prefix)
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyEventFlags 失效本地缓存需要的notify-keyspace-events标志：
+// E 键事件通知，g 通用命令（del等），$ 字符串命令（set、incrby等），x 过期，e 内存淘汰
+const keyEventFlags = "Eg$xe"
+
+// keyEventPatterns 订阅的键事件频道，会改变或删除key的事件都需要使本地缓存失效
+var keyEventPatterns = []string{
+	"__keyevent@*__:set",
+	"__keyevent@*__:del",
+	"__keyevent@*__:expired",
+	"__keyevent@*__:evicted",
+	"__keyevent@*__:incrby",
+	"__keyevent@*__:rename_from",
+}
+
+// keyEventRetryInterval 订阅断开后重新订阅的间隔
+const keyEventRetryInterval = time.Second
+
+// ConfigureKeyEvents 在Redis服务器上开启键事件通知，保留服务器已有的其他标志
+// 托管的Redis服务通常禁止CONFIG命令，此时需要在服务端配置notify-keyspace-events
+func (r *RedisCache) ConfigureKeyEvents(ctx context.Context) error {
+	configure := func(ctx context.Context, client redis.Cmdable) error {
+		ctx, cancel := r.opContext(ctx)
+		defer cancel()
+
+		current, err := client.ConfigGet(ctx, "notify-keyspace-events").Result()
+		if err != nil {
+			return fmt.Errorf("failed to get notify-keyspace-events: %w", err)
+		}
+		flags := current["notify-keyspace-events"]
+		for _, f := range keyEventFlags {
+			if !strings.ContainsRune(flags, f) {
+				flags += string(f)
+			}
+		}
+		if err := client.ConfigSet(ctx, "notify-keyspace-events", flags).Err(); err != nil {
+			return fmt.Errorf("failed to set notify-keyspace-events: %w", err)
+		}
+		return nil
+	}
+
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return configure(ctx, node)
+		})
+	}
+	return configure(ctx, r.client)
+}
+
+// SubscribeKeyEvents 订阅键事件通知，对当前命名空间下被写入、删除、过期或淘汰的key调用fn，
+// key已去掉命名空间前缀。集群模式下键事件只在key所在的节点上发布，因此分别订阅每个主节点
+// 一直阻塞到ctx被取消（返回nil）或订阅失败
+func (r *RedisCache) SubscribeKeyEvents(ctx context.Context, fn func(event, key string)) error {
+	var mu sync.Mutex
+	var pubsubs []*redis.PubSub
+	subscribe := func(ctx context.Context, client redis.UniversalClient) error {
+		ps := client.PSubscribe(ctx, keyEventPatterns...)
+		mu.Lock()
+		pubsubs = append(pubsubs, ps)
+		mu.Unlock()
+
+		opCtx, cancel := r.opContext(ctx)
+		defer cancel()
+		// 等待订阅确认，尽早发现连接问题
+		if _, err := ps.Receive(opCtx); err != nil {
+			return fmt.Errorf("failed to subscribe keyspace events: %w", err)
+		}
+		return nil
+	}
+
+	var err error
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return subscribe(ctx, node)
+		})
+	} else {
+		err = subscribe(ctx, r.client)
+	}
+	defer func() {
+		for _, ps := range pubsubs {
+			_ = ps.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	prefix := namespacePrefix(r.namespace)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, ps := range pubsubs {
+		wg.Add(1)
+		go func(ch <-chan *redis.Message) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				case msg, ok := <-ch:
+					if !ok {
+						return
+					}
+					if !strings.HasPrefix(msg.Payload, prefix) {
+						continue
+					}
+					event := msg.Channel[strings.LastIndex(msg.Channel, ":")+1:]
+					fn(event, strings.TrimPrefix(msg.Payload, prefix))
+				}
+			}
+		}(ps.Channel())
+	}
+
+	<-ctx.Done()
+	close(done)
+	wg.Wait()
+	return nil
+}
+
+// watchKeyEvents 持续订阅Redis键事件并删除对应的本地缓存，订阅断开时自动重试，直到ctx被取消
+func (m *MultiLevelCache) watchKeyEvents(ctx context.Context, subscriber KeyEventSubscriber) {
+	defer close(m.keyEventsDone)
+	for {
+		err := subscriber.SubscribeKeyEvents(ctx, m.handleKeyEvent)
+		if ctx.Err() != nil {
+			return
+		}
+		m.logger.Errorf("Keyspace notification subscription error: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(keyEventRetryInterval):
+		}
+	}
+}
+
+// handleKeyEvent 收到键事件时删除本地副本，下次读取时从Redis回填
+func (m *MultiLevelCache) handleKeyEvent(event, key string) {
+	if err := m.local.Delete(context.Background(), key); err != nil {
+		m.logger.Errorf("Local cache invalidate on %s event error: %v", event, err)
+	}
+}
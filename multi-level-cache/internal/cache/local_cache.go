@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,58 +12,117 @@ import (
 	"multi-level-cache/pkg/utils"
 )
 
+// defaultLocalShards 未配置分片数时使用的分片数
+const defaultLocalShards = 16
+
+// localShard 本地缓存的一个分片，每个分片有独立的go-cache实例、锁和字节数记录，
+// 不同分片上的key并发读写时互不竞争
+type localShard struct {
+	// 内部缓存实例
+	cache *cache.Cache
+	// 读写锁，保证写入与字节数记录、淘汰的一致性
+	mu sync.RWMutex
+	// 条目大小记录，用于限制本地缓存占用的内存
+	sizes *sizeTracker
+}
+
 // LocalCache 实现基于内存的本地缓存
 type LocalCache struct {
 	// 缓存名称
 	name string
-	// 内部缓存实例
-	cache *cache.Cache
+	// 按key的哈希值划分的分片
+	shards []*localShard
 	// 默认过期时间
 	defaultExpiration time.Duration
-	// 互斥锁，用于一些需要同步的操作
-	mu sync.RWMutex
+	// key的命名空间前缀，为空表示不加前缀
+	namespace string
+	// 正在被Delete删除的key，用于区分主动删除和过期清理
+	deleting sync.Map
+	// 淘汰与过期回调
+	callbackMu sync.RWMutex
+	onEvicted  func(key string, value []byte)
+	onExpired  func(key string, value []byte)
+	logger     utils.Logger
 }
 
-// NewLocalCache 创建一个新的本地缓存
-func NewLocalCache(cfg *config.LocalCacheConfig, opts ...Options) (*LocalCache, error) {
-	options := Options{
-		Name:              "local_cache",
-		DefaultExpiration: 5 * time.Minute,
-	}
-
-	// 应用自定义选项
-	if len(opts) > 0 {
-		options = opts[0]
-	}
+// NewLocalCache 创建一个新的本地缓存，可选配置见 WithName、WithDefaultExpiration、WithLogger
+func NewLocalCache(cfg *config.LocalCacheConfig, opts ...Option) (*LocalCache, error) {
+	options := newOptions("local_cache", 5*time.Minute, opts)
 
 	// 如果提供了配置，则使用配置的值
+	cleanupInterval := options.defaultExpiration * 2
+	var maxBytes int64
+	shards := defaultLocalShards
 	if cfg != nil {
+		maxBytes = cfg.MaxBytes
 		if cfg.DefaultExpiration > 0 {
-			options.DefaultExpiration = cfg.DefaultExpiration
+			options.defaultExpiration = cfg.DefaultExpiration
+			cleanupInterval = options.defaultExpiration * 2
+		}
+		if cfg.CleanupInterval > 0 {
+			cleanupInterval = cfg.CleanupInterval
+		}
+		if cfg.Shards > 0 {
+			shards = cfg.Shards
 		}
 	}
 
 	c := &LocalCache{
-		name:              options.Name,
-		cache:             cache.New(options.DefaultExpiration, options.DefaultExpiration*2),
-		defaultExpiration: options.DefaultExpiration,
+		name:              options.name,
+		shards:            make([]*localShard, shards),
+		defaultExpiration: options.defaultExpiration,
+		logger:            options.logger,
+	}
+	if cfg != nil {
+		c.namespace = cfg.Namespace
+	}
+	// 字节上限平均分配给各个分片，每个分片独立按LRU淘汰
+	shardMaxBytes := maxBytes / int64(shards)
+	if maxBytes > 0 && shardMaxBytes == 0 {
+		shardMaxBytes = 1
+	}
+	for i := range c.shards {
+		s := &localShard{
+			cache: cache.New(options.defaultExpiration, cleanupInterval),
+			sizes: newSizeTracker(shardMaxBytes),
+		}
+		s.cache.OnEvicted(c.handleEvicted)
+		c.shards[i] = s
 	}
 
-	utils.LogInfo("Local cache initialized: %s with default expiration: %v", options.Name, options.DefaultExpiration)
+	c.logger.Infof("Local cache initialized: %s with default expiration: %v, shards: %d", options.name, options.defaultExpiration, shards)
 	return c, nil
 }
 
+// shard 返回内部key（含命名空间前缀）所在的分片，使用FNV-1a哈希
+func (c *LocalCache) shard(key string) *localShard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
 // Get 从本地缓存获取值
 func (c *LocalCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if key == "" {
 		return nil, ErrInvalidKey
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	// 从缓存获取值
-	value, found := c.cache.Get(key)
+	value, found := s.cache.Get(k)
 	if !found {
 		return nil, ErrKeyNotFound
 	}
@@ -69,15 +130,19 @@ func (c *LocalCache) Get(ctx context.Context, key string) ([]byte, error) {
 	// 将值转换为字节数组
 	bytes, ok := value.([]byte)
 	if !ok {
-		utils.LogError("Invalid type in cache for key: %s", key)
+		c.logger.Errorf("Invalid type in cache for key: %s", key)
 		return nil, ErrCacheInternal
 	}
 
+	s.sizes.touch(k)
 	return bytes, nil
 }
 
 // Set 设置缓存值
 func (c *LocalCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if key == "" {
 		return ErrInvalidKey
 	}
@@ -91,39 +156,472 @@ func (c *LocalCache) Set(ctx context.Context, key string, value []byte, expirati
 		expiration = c.defaultExpiration
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.Lock()
+	evicted := c.setLocked(s, k, value, expiration, entrySourceFrom(ctx))
+	s.mu.Unlock()
 
-	c.cache.Set(key, value, expiration)
+	c.notifyEvicted(evicted)
 	return nil
 }
 
-// Delete 从缓存中删除键
+// setLocked 写入条目并按字节上限淘汰，返回被淘汰的条目，调用方需持有分片的写锁
+func (c *LocalCache) setLocked(s *localShard, key string, value []byte, expiration time.Duration, source EntrySource) []evictedEntry {
+	size := entrySize(key, value)
+	if s.sizes.tooLarge(size) {
+		// 单个值超过字节上限时不放入本地缓存，同时删除旧值以免读到过期数据
+		c.removeLocked(s, key)
+		return nil
+	}
+	if source == sourceKeep {
+		// sourceKeep只用于以Redis中的值延长过期时间，值已经变化时按从Redis回填记录
+		old, _ := s.cache.Get(key)
+		if oldBytes, ok := old.([]byte); !ok || !bytes.Equal(oldBytes, value) {
+			source = SourceRedis
+		}
+	}
+	s.cache.Set(key, value, expiration)
+	return c.evictLocked(s, s.sizes.add(key, size, source))
+}
+
+// SetNX 仅在key不存在时设置值
+func (c *LocalCache) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if key == "" {
+		return false, ErrInvalidKey
+	}
+	if value == nil {
+		return false, ErrInvalidValue
+	}
+	if expiration <= 0 {
+		expiration = c.defaultExpiration
+	}
+
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.Lock()
+	if _, found := s.cache.Get(k); found {
+		s.mu.Unlock()
+		return false, nil
+	}
+	evicted := c.setLocked(s, k, value, expiration, entrySourceFrom(ctx))
+	s.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+	return true, nil
+}
+
+// GetSet 设置新值并返回旧值
+func (c *LocalCache) GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, ErrInvalidKey
+	}
+	if value == nil {
+		return nil, ErrInvalidValue
+	}
+	if expiration <= 0 {
+		expiration = c.defaultExpiration
+	}
+
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.Lock()
+	old, _ := s.cache.Get(k)
+	evicted := c.setLocked(s, k, value, expiration, entrySourceFrom(ctx))
+	s.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+	oldBytes, _ := old.([]byte)
+	return oldBytes, nil
+}
+
+// CompareAndSwap 仅在当前值等于old时设置为new
+func (c *LocalCache) CompareAndSwap(ctx context.Context, key string, old, new []byte, expiration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if key == "" {
+		return false, ErrInvalidKey
+	}
+	if new == nil {
+		return false, ErrInvalidValue
+	}
+	if expiration <= 0 {
+		expiration = c.defaultExpiration
+	}
+
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.Lock()
+	current, found := s.cache.Get(k)
+	currentBytes, ok := current.([]byte)
+	if !found || !ok || !bytes.Equal(currentBytes, old) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	evicted := c.setLocked(s, k, new, expiration, entrySourceFrom(ctx))
+	s.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+	return true, nil
+}
+
+// evictedEntry 因超过字节上限被淘汰的条目
+type evictedEntry struct {
+	key   string
+	value []byte
+}
+
+// evictLocked 从go-cache中删除被淘汰的key，调用方需持有分片的写锁
+func (c *LocalCache) evictLocked(s *localShard, keys []string) []evictedEntry {
+	var evicted []evictedEntry
+	for _, key := range keys {
+		value, found := s.cache.Get(key)
+		c.deleting.Store(key, struct{}{})
+		s.cache.Delete(key)
+		c.deleting.Delete(key)
+		if bytes, ok := value.([]byte); found && ok {
+			evicted = append(evicted, evictedEntry{key: key, value: bytes})
+		}
+	}
+	return evicted
+}
+
+// removeLocked 删除条目但不触发回调，调用方需持有分片的写锁
+func (c *LocalCache) removeLocked(s *localShard, key string) {
+	c.deleting.Store(key, struct{}{})
+	s.cache.Delete(key)
+	c.deleting.Delete(key)
+	s.sizes.remove(key)
+}
+
+// notifyEvicted 对被淘汰的条目调用OnEvicted回调，需在释放锁之后调用
+func (c *LocalCache) notifyEvicted(evicted []evictedEntry) {
+	prefix := namespacePrefix(c.namespace)
+	for _, e := range evicted {
+		c.notify(strings.TrimPrefix(e.key, prefix), e.value, false)
+	}
+}
+
+// Delete 从缓存中删除键，删除已存在的键时触发OnEvicted回调
 func (c *LocalCache) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if key == "" {
 		return ErrInvalidKey
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.Lock()
+	value, found := s.cache.Get(k)
+	c.deleting.Store(k, struct{}{})
+	s.cache.Delete(k)
+	c.deleting.Delete(k)
+	s.sizes.remove(k)
+	s.mu.Unlock()
 
-	c.cache.Delete(key)
+	// 回调在释放锁之后执行，允许回调中再次访问缓存
+	if found {
+		if bytes, ok := value.([]byte); ok {
+			c.notify(key, bytes, false)
+		}
+	}
 	return nil
 }
 
+// OnEvicted 注册淘汰回调，key被删除、过期清理或因超过字节上限被淘汰时调用，覆盖写入不会触发；传入nil取消注册
+func (c *LocalCache) OnEvicted(fn func(key string, value []byte)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.onEvicted = fn
+}
+
+// OnExpired 注册过期回调，仅在key因过期被清理时调用；传入nil取消注册
+// 过期的key在定期清理（CleanupInterval）时才会被移除，因此回调可能晚于实际过期时间
+func (c *LocalCache) OnExpired(fn func(key string, value []byte)) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.onExpired = fn
+}
+
+// handleEvicted 处理go-cache的淘汰通知，主动删除的回调由Delete负责，这里只处理过期清理
+func (c *LocalCache) handleEvicted(key string, value interface{}) {
+	if _, ok := c.deleting.Load(key); ok {
+		return
+	}
+	// 过期清理的回调在go-cache释放锁之后执行，期间key可能已被重新写入，此时保留新条目的记录
+	s := c.shard(key)
+	if _, found := s.cache.Get(key); !found {
+		s.sizes.remove(key)
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return
+	}
+	c.notify(strings.TrimPrefix(key, namespacePrefix(c.namespace)), bytes, true)
+}
+
+// notify 调用已注册的回调
+func (c *LocalCache) notify(key string, value []byte, expired bool) {
+	c.callbackMu.RLock()
+	onEvicted, onExpired := c.onEvicted, c.onExpired
+	c.callbackMu.RUnlock()
+
+	if onEvicted != nil {
+		onEvicted(key, value)
+	}
+	if expired && onExpired != nil {
+		onExpired(key, value)
+	}
+}
+
 // Exists 检查键是否存在于缓存中
 func (c *LocalCache) Exists(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	if key == "" {
 		return false, ErrInvalidKey
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	_, found := c.cache.Get(key)
+	_, found := s.cache.Get(k)
 	return found, nil
 }
 
+// MGet 批量获取缓存值，每个key只锁定所在的分片
+func (c *LocalCache) MGet(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	for _, key := range keys {
+		if key == "" {
+			return nil, nil, ErrInvalidKey
+		}
+	}
+
+	found := make(map[string][]byte, len(keys))
+	var missing []string
+	for _, key := range keys {
+		k := c.key(key)
+		s := c.shard(k)
+		s.mu.RLock()
+		value, ok := s.cache.Get(k)
+		if ok {
+			s.sizes.touch(k)
+		}
+		s.mu.RUnlock()
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		bytes, ok := value.([]byte)
+		if !ok {
+			c.logger.Errorf("Invalid type in cache for key: %s", key)
+			missing = append(missing, key)
+			continue
+		}
+		found[key] = bytes
+	}
+	return found, missing, nil
+}
+
+// MSet 批量设置缓存值
+func (c *LocalCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for key, value := range items {
+		if key == "" {
+			return ErrInvalidKey
+		}
+		if value == nil {
+			return ErrInvalidValue
+		}
+	}
+
+	if expiration <= 0 {
+		expiration = c.defaultExpiration
+	}
+
+	var evicted []evictedEntry
+	for key, value := range items {
+		k := c.key(key)
+		s := c.shard(k)
+		s.mu.Lock()
+		evicted = append(evicted, c.setLocked(s, k, value, expiration, entrySourceFrom(ctx))...)
+		s.mu.Unlock()
+	}
+
+	c.notifyEvicted(evicted)
+	return nil
+}
+
+// TTL 返回本地缓存中key的剩余过期时间，key不存在时返回 ErrKeyNotFound，永不过期时返回0
+func (c *LocalCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if key == "" {
+		return 0, ErrInvalidKey
+	}
+
+	k := c.key(key)
+	s := c.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, expiration, found := s.cache.GetWithExpiration(k)
+	if !found {
+		return 0, ErrKeyNotFound
+	}
+	return remaining(expiration), nil
+}
+
+// MTTL 批量返回key的剩余过期时间，不存在或永不过期的key不包含在结果中
+func (c *LocalCache) MTTL(ctx context.Context, keys []string) (map[string]time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ttls := make(map[string]time.Duration, len(keys))
+	for _, key := range keys {
+		k := c.key(key)
+		s := c.shard(k)
+		s.mu.RLock()
+		_, expiration, found := s.cache.GetWithExpiration(k)
+		s.mu.RUnlock()
+		if !found {
+			continue
+		}
+		if ttl := remaining(expiration); ttl > 0 {
+			ttls[key] = ttl
+		}
+	}
+	return ttls, nil
+}
+
+// remaining 计算距离过期时间点的剩余时间，零值表示永不过期
+func remaining(expiration time.Time) time.Duration {
+	if expiration.IsZero() {
+		return 0
+	}
+	ttl := time.Until(expiration)
+	if ttl <= 0 {
+		// 已过期但尚未被清理，返回最小的正值而不是"永不过期"
+		return time.Nanosecond
+	}
+	return ttl
+}
+
+// ClearNamespace 删除当前命名空间下的所有key，未设置命名空间时返回 ErrNamespaceNotSet
+// 不会触发淘汰回调
+func (c *LocalCache) ClearNamespace(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if c.namespace == "" {
+		return 0, ErrNamespaceNotSet
+	}
+	return c.removePrefix(namespacePrefix(c.namespace)), nil
+}
+
+// DeleteByPrefix 删除当前命名空间下以prefix开头的所有key，返回删除的数量，不触发淘汰回调
+// prefix为空时返回 ErrInvalidKey
+func (c *LocalCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if prefix == "" {
+		return 0, ErrInvalidKey
+	}
+	return c.removePrefix(c.key(prefix)), nil
+}
+
+// removePrefix 删除以rawPrefix（已包含命名空间前缀）开头的所有条目
+func (c *LocalCache) removePrefix(prefix string) int64 {
+	var removed int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for key := range s.cache.Items() {
+			if strings.HasPrefix(key, prefix) {
+				c.removeLocked(s, key)
+				removed++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+// Keys 返回匹配pattern的所有未过期的key，不包含命名空间前缀
+func (c *LocalCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	err := c.Scan(ctx, pattern, func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys, err
+}
+
+// Scan 遍历匹配pattern的所有未过期的key，fn返回false时停止遍历
+// 先按命名空间前缀过滤，再按pattern匹配；逐个分片遍历调用时的快照，fn中可以访问缓存
+func (c *LocalCache) Scan(ctx context.Context, pattern string, fn func(key string) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	prefix := namespacePrefix(c.namespace)
+	for _, s := range c.shards {
+		s.mu.RLock()
+		items := s.cache.Items()
+		s.mu.RUnlock()
+
+		for key := range items {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			key = strings.TrimPrefix(key, prefix)
+			if !matchPattern(pattern, key) {
+				continue
+			}
+			if !fn(key) {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// key 返回加上命名空间前缀后的key
+func (c *LocalCache) key(key string) string {
+	return namespacePrefix(c.namespace) + key
+}
+
+// Bytes 返回本地缓存中数据占用的大致字节数（key与value的长度之和）
+func (c *LocalCache) Bytes() int64 {
+	var total int64
+	for _, s := range c.shards {
+		total += s.sizes.total()
+	}
+	return total
+}
+
 // Name 返回缓存名称
 func (c *LocalCache) Name() string {
 	return c.name
@@ -131,10 +629,12 @@ func (c *LocalCache) Name() string {
 
 // Close 清理缓存资源
 func (c *LocalCache) Close() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// 清空缓存
-	c.cache.Flush()
+	for _, s := range c.shards {
+		s.mu.Lock()
+		// 清空缓存
+		s.cache.Flush()
+		s.sizes.reset()
+		s.mu.Unlock()
+	}
 	return nil
 }
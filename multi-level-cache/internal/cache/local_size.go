@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// sizeEntry 本地缓存中一个条目的大小记录以及用于排查问题的元数据
+type sizeEntry struct {
+	key       string
+	size      int64
+	createdAt time.Time
+	source    EntrySource
+	hits      int64
+}
+
+// sizeTracker 记录本地缓存中每个条目的大致字节数，并按最近使用的顺序维护条目，
+// 总字节数超过上限时从最久未使用的条目开始淘汰
+type sizeTracker struct {
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[string]*list.Element
+	bytes    int64
+	maxBytes int64
+}
+
+// newSizeTracker 创建条目大小记录，maxBytes不大于0表示不限制
+func newSizeTracker(maxBytes int64) *sizeTracker {
+	return &sizeTracker{
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		maxBytes: maxBytes,
+	}
+}
+
+// entrySize 估算一个条目占用的字节数
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
+
+// tooLarge 判断单个条目是否超过了字节上限
+func (t *sizeTracker) tooLarge(size int64) bool {
+	return t.maxBytes > 0 && size > t.maxBytes
+}
+
+// add 记录写入的条目，返回为了满足字节上限需要淘汰的key（不包括刚写入的key）
+// 覆盖已有条目时重新开始记录写入时间、来源和命中次数，source为sourceKeep时保留原有的元数据
+func (t *sizeTracker) add(key string, size int64, source EntrySource) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, ok := t.entries[key]; ok {
+		entry := elem.Value.(*sizeEntry)
+		t.bytes += size - entry.size
+		entry.size = size
+		if source != sourceKeep {
+			entry.createdAt, entry.source, entry.hits = time.Now(), source, 0
+		}
+		t.lru.MoveToFront(elem)
+	} else {
+		t.entries[key] = t.lru.PushFront(&sizeEntry{key: key, size: size, createdAt: time.Now(), source: source})
+		t.bytes += size
+	}
+
+	if t.maxBytes <= 0 {
+		return nil
+	}
+	var victims []string
+	for t.bytes > t.maxBytes {
+		back := t.lru.Back()
+		if back == nil || back.Value.(*sizeEntry).key == key {
+			break
+		}
+		entry := back.Value.(*sizeEntry)
+		t.removeElement(back)
+		victims = append(victims, entry.key)
+	}
+	return victims
+}
+
+// touch 记录一次命中并把条目标记为最近使用，未限制字节数时不需要维护顺序
+func (t *sizeTracker) touch(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, ok := t.entries[key]; ok {
+		elem.Value.(*sizeEntry).hits++
+		if t.maxBytes > 0 {
+			t.lru.MoveToFront(elem)
+		}
+	}
+}
+
+// meta 返回条目的元数据
+func (t *sizeTracker) meta(key string) (EntryMeta, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elem, ok := t.entries[key]
+	if !ok {
+		return EntryMeta{}, false
+	}
+	entry := elem.Value.(*sizeEntry)
+	return EntryMeta{CreatedAt: entry.createdAt, Source: entry.source, Hits: entry.hits, Size: entry.size}, true
+}
+
+// remove 删除条目的记录
+func (t *sizeTracker) remove(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, ok := t.entries[key]; ok {
+		t.removeElement(elem)
+	}
+}
+
+// removeElement 删除链表中的条目，调用方需持有锁
+func (t *sizeTracker) removeElement(elem *list.Element) {
+	entry := elem.Value.(*sizeEntry)
+	t.lru.Remove(elem)
+	delete(t.entries, entry.key)
+	t.bytes -= entry.size
+}
+
+// reset 清空所有记录
+func (t *sizeTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lru.Init()
+	t.entries = make(map[string]*list.Element)
+	t.bytes = 0
+}
+
+// total 返回当前记录的总字节数
+func (t *sizeTracker) total() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.bytes
+}
@@ -1,79 +1,581 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+	"multi-level-cache/internal/config"
 	"multi-level-cache/pkg/metrics"
 	"multi-level-cache/pkg/utils"
 )
 
+// nullValue 空值标记，表示key对应的数据在数据源中不存在
+var nullValue = []byte("\x00mlc:null\x00")
+
+// isNullValue 判断缓存的值是否为空值标记
+func isNullValue(val []byte) bool {
+	return bytes.Equal(val, nullValue)
+}
+
+// LoaderFunc 缓存完全未命中时用于加载数据的函数，通常从数据库读取
+type LoaderFunc func(ctx context.Context, key string) ([]byte, error)
+
+// NotFoundError 加载函数返回它表示数据不存在，同时指定空值标记的缓存时间，errors.Is(err, ErrKeyNotFound)为true
+// 加载函数直接返回 ErrKeyNotFound 时空值标记按NullValueTTL缓存
+type NotFoundError struct {
+	// 空值标记的缓存时间，为0时使用NullValueTTL
+	TTL time.Duration
+}
+
+func (e *NotFoundError) Error() string {
+	return "key not found in data source"
+}
+
+// Unwrap 返回 ErrKeyNotFound
+func (e *NotFoundError) Unwrap() error {
+	return ErrKeyNotFound
+}
+
+// NotFound 返回指定空值缓存时间的 NotFoundError，通常比正常数据的过期时间短，
+// 避免刚写入数据源的key在较长时间内仍被当作不存在
+func NotFound(ttl time.Duration) error {
+	return &NotFoundError{TTL: ttl}
+}
+
+// MultiLoaderFunc 批量加载函数，返回结果中没有的key视为数据不存在
+type MultiLoaderFunc func(ctx context.Context, keys []string) (map[string][]byte, error)
+
 // MultiLevelCache 实现简单的多级缓存（本地缓存 + Redis缓存）
 type MultiLevelCache struct {
-	name    string
-	local   Cache // 本地缓存
-	redis   Cache // Redis缓存
+	name  string
+	local Cache // 本地缓存
+	redis Cache // Redis缓存
+	// 两级缓存的剩余过期时间查询，底层缓存不支持时为nil
+	localTTLReader TTLReader
+	redisTTLReader TTLReader
+	// 在一次往返中读取Redis中的值和剩余过期时间，Redis缓存不支持时为nil
+	redisValueTTLReader ValueTTLReader
+	// 本地缓存的淘汰通知，底层缓存不支持时为nil
+	localNotifier EvictionNotifier
+	// 分布式重建锁，未启用或Redis缓存不支持时为nil
+	locker  Locker
 	metrics *metrics.CacheMetrics
+	config  config.MultiLevelCacheConfig
+	// 布隆过滤器，为nil表示不启用
+	filter KeyFilter
+	// 热点key检测器，为nil表示不启用
+	hotKeys *hotKeyDetector
+	// 命中和未命中次数最多的key的统计，为nil表示不启用
+	hitKeys    *keyTracker
+	missedKeys *keyTracker
+	// 固定在本地缓存中的key
+	pins pinner
+	// 合并同一个key的并发加载请求
+	loadGroup singleflight.Group
+	// Get使用的加载函数，用于提前刷新，为nil表示Get不触发提前刷新
+	loader LoaderFunc
+	// GetMulti使用的批量加载函数，为nil表示GetMulti不回源
+	multiLoader MultiLoaderFunc
+	// 正在后台刷新的key，保证同一个key同时只有一个刷新任务
+	refreshing sync.Map
+	// 停止键事件订阅，未启用键事件失效时为nil
+	stopKeyEvents context.CancelFunc
+	keyEventsDone chan struct{}
+	// 实例标识，用于忽略自己广播的失效消息
+	instanceID string
+	// 失效消息的广播，未启用时为nil
+	broadcaster       InvalidationBroadcaster
+	stopInvalidations context.CancelFunc
+	invalidationsDone chan struct{}
+	// Redis熔断器和熔断期间的写操作队列，未启用熔断时为nil
+	breaker   *circuitBreaker
+	pending   *writeQueue
+	replaying atomic.Bool
+	// 定期上报指标，未配置时为nil
+	reporter *metrics.Reporter
+	// 同一个key频繁写入时的Redis写入合并，未启用时为nil
+	coalescer *writeCoalescer
+	// 本地缓存的异步回填，未启用时为nil
+	backfill *backfillCache
+	// Redis中值的编码包装（版本信封、编码、加密），按写入时的应用顺序排列，未启用时为空
+	redisCodecs []*codecCache
+	logger      utils.Logger
 }
 
-// MultiLevelCacheOptions 多级缓存配置选项
-type MultiLevelCacheOptions struct {
-	Name string
-}
-
-// NewMultiLevelCache 创建多级缓存实例
-func NewMultiLevelCache(local, redis Cache, opts ...MultiLevelCacheOptions) *MultiLevelCache {
-	name := "multi_level_cache"
-	if len(opts) > 0 && opts[0].Name != "" {
-		name = opts[0].Name
+// NewMultiLevelCache 创建多级缓存实例，可选配置见 WithConfig、WithFilter、WithLoader 等
+func NewMultiLevelCache(local, redis Cache, opts ...Option) *MultiLevelCache {
+	o := newOptions("multi_level_cache", 0, opts)
+	cfg := config.DefaultConfig().MultiLevelCache
+	if o.config != nil {
+		cfg = *o.config
+	}
+	var hotKeys *hotKeyDetector
+	if cfg.EnableHotKeyDetection && cfg.HotKeyThreshold > 0 {
+		hotKeys = newHotKeyDetector(cfg.HotKeyThreshold, cfg.HotKeyWindow)
+	}
+	// 两级缓存包装一层指标统计，分别记录各层的命中情况和操作耗时
+	cacheMetrics := o.metrics
+	if cacheMetrics == nil {
+		cacheMetrics = metrics.NewCacheMetrics()
+	}
+	instrumentedLocal := newInstrumentedCache(local, metrics.LevelLocal, cacheMetrics)
+	redisStore := redis
+	if cfg.MaxValueSize > 0 && cfg.ValueSizePolicy == config.ValueSizeChunk {
+		// 分块包装在指标统计之内，一次分块写入或读取只计为一次Redis操作
+		redisStore = newChunkedCache(redis, cfg.MaxValueSize, cfg.ValueChunkSize)
+	}
+	var redisCodecs []*codecCache
+	if o.encryptor != nil {
+		// 先加密再分块，分块中保存的是密文
+		encrypted := newEncryptedCache(redisStore, o.encryptor, o.logger)
+		redisCodecs = append(redisCodecs, encrypted)
+		redisStore = encrypted
+	}
+	if o.codec != nil {
+		// 先编码再加密，压缩等编码对明文更有效
+		encoded := newCodecCache(redisStore, o.codec, o.logger)
+		redisCodecs = append([]*codecCache{encoded}, redisCodecs...)
+		redisStore = encoded
+	}
+	if o.schema != nil {
+		// 信封在最外层，记录的是应用写入的原始值的格式和版本
+		enveloped := newSchemaCache(redisStore, *o.schema, o.migrate, o.logger)
+		redisCodecs = append([]*codecCache{enveloped}, redisCodecs...)
+		redisStore = enveloped
 	}
-	utils.LogInfo("MultiLevelCache initialized: %s", name)
-	return &MultiLevelCache{
-		name:    name,
-		local:   local,
-		redis:   redis,
-		metrics: metrics.NewCacheMetrics(),
+	instrumentedRedis := newInstrumentedCache(redisStore, metrics.LevelRedis, cacheMetrics)
+	var localTTLReader, redisTTLReader TTLReader
+	if _, ok := local.(TTLReader); ok {
+		localTTLReader = instrumentedLocal
 	}
+	if _, ok := redis.(TTLReader); ok {
+		redisTTLReader = instrumentedRedis
+	}
+	var redisValueTTLReader ValueTTLReader
+	if _, ok := redis.(ValueTTLReader); ok {
+		redisValueTTLReader = instrumentedRedis
+	}
+	localNotifier, _ := local.(EvictionNotifier)
+	if sizer, ok := local.(Sizer); ok {
+		cacheMetrics.RegisterLevelBytes(metrics.LevelLocal, sizer.Bytes)
+	}
+	var locker Locker
+	if cfg.EnableRebuildLock {
+		locker, _ = redis.(Locker)
+	}
+	o.logger.Infof("MultiLevelCache initialized: %s", o.name)
+	m := &MultiLevelCache{
+		name:                o.name,
+		local:               instrumentedLocal,
+		redis:               instrumentedRedis,
+		localTTLReader:      localTTLReader,
+		redisTTLReader:      redisTTLReader,
+		redisValueTTLReader: redisValueTTLReader,
+		localNotifier:       localNotifier,
+		locker:              locker,
+		metrics:             cacheMetrics,
+		config:              cfg,
+		filter:              o.filter,
+		hotKeys:             hotKeys,
+		pins:                pinner{entries: make(map[string]*pinnedEntry)},
+		loader:              o.loader,
+		multiLoader:         o.multiLoader,
+		redisCodecs:         redisCodecs,
+		instanceID:          newLockToken(),
+		logger:              o.logger,
+	}
+	if cfg.EnableCircuitBreaker {
+		// 熔断包装在指标统计之外，被拒绝的操作不计入Redis层的延迟和错误
+		m.breaker = newCircuitBreaker(cfg.CircuitBreakerThreshold, cfg.CircuitBreakerOpenTimeout, m.onBreakerStateChange)
+		m.pending = newWriteQueue(cfg.DegradedWriteQueueSize)
+		breakerRedis := newBreakerCache(instrumentedRedis, m.breaker, cacheMetrics)
+		m.redis = breakerRedis
+		if redisTTLReader != nil {
+			m.redisTTLReader = breakerRedis
+		}
+		if redisValueTTLReader != nil {
+			m.redisValueTTLReader = breakerRedis
+		}
+		cacheMetrics.RegisterDegraded(m.breaker.isOpen)
+	}
+	if cfg.MaxValueSize > 0 {
+		// 大小限制包装在本地缓存的最外层，超限的值不写入本地缓存，也不计入本地缓存的写入指标
+		m.local = newSizeLimitedCache(instrumentedLocal, cfg.MaxValueSize)
+	}
+	if cfg.EnableKeyStats && cfg.KeyStatsTopK > 0 {
+		m.hitKeys = newKeyTracker(cfg.KeyStatsTopK, cfg.KeyStatsDecay)
+		m.missedKeys = newKeyTracker(cfg.KeyStatsTopK, cfg.KeyStatsDecay)
+	}
+	if cfg.WriteCoalesceWindow > 0 {
+		m.coalescer = newWriteCoalescer(cfg.WriteCoalesceWindow, m.flushCoalesced)
+	}
+	if cfg.EnableAsyncBackfill {
+		// 异步回填包装在最外层，后台协程的写入同样经过大小限制
+		m.backfill = newBackfillCache(m.local, cfg.BackfillQueueSize)
+		m.local = m.backfill
+	}
+	if cfg.StatsReportInterval > 0 && o.statsSink != nil {
+		reporter, err := cacheMetrics.StartReporter(cfg.StatsReportInterval, o.statsSink)
+		if err != nil {
+			m.logger.Errorf("Failed to start stats reporter: %v", err)
+		}
+		m.reporter = reporter
+	}
+	if cfg.EnableKeyspaceInvalidation {
+		if subscriber, ok := redis.(KeyEventSubscriber); ok {
+			ctx, cancel := context.WithCancel(context.Background())
+			m.stopKeyEvents = cancel
+			m.keyEventsDone = make(chan struct{})
+			go m.watchKeyEvents(ctx, subscriber)
+		} else {
+			m.logger.Errorf("Redis cache %s does not support keyspace notifications", redis.Name())
+		}
+	}
+	if cfg.EnableInvalidationBroadcast {
+		if broadcaster, ok := redis.(InvalidationBroadcaster); ok {
+			ctx, cancel := context.WithCancel(context.Background())
+			m.broadcaster = broadcaster
+			m.stopInvalidations = cancel
+			m.invalidationsDone = make(chan struct{})
+			go m.watchInvalidations(ctx)
+		} else {
+			m.logger.Errorf("Redis cache %s does not support invalidation broadcast", redis.Name())
+		}
+	}
+	return m
 }
 
 // Get 先查本地缓存，再查Redis，最后返回
+// 若缓存中记录了数据不存在（空值缓存），返回 ErrCachedNotFound
+// 配置了Loader并启用提前刷新时，即将过期的key会在后台刷新
+// 启用过期宽限期时，宽限期内的旧值只有在配置了Loader时才会返回，同时在后台重新加载；否则视为未命中
+// 通过 WithReadThrough 标记的context跳过本地缓存直接读取Redis
 func (m *MultiLevelCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return m.get(ctx, key, m.loader, m.config.RefreshExpiration)
+}
+
+// get 是Get和GetOrLoad共用的查询逻辑，loader不为nil时用于提前刷新，expiration为刷新后写回的过期时间
+func (m *MultiLevelCache) get(ctx context.Context, key string, loader LoaderFunc, expiration time.Duration) ([]byte, error) {
+	// 强一致读跳过本地缓存，只有要求更新本地缓存时才回写
+	readThrough, refreshLocal := readThroughFrom(ctx)
+	fillLocal := !readThrough || refreshLocal
+
 	// 先查本地缓存
-	val, err := m.local.Get(ctx, key)
+	if !readThrough {
+		val, err := m.local.Get(ctx, key)
+		if err == nil {
+			m.recordHit(key)
+			if isNullValue(val) {
+				m.metrics.IncNegativeHit()
+				return nil, ErrCachedNotFound
+			}
+			// key刚成为热点时，把本地缓存的过期时间延长到与Redis一致，启用固定时固定该key
+			if m.hotKeys != nil && m.hotKeys.record(key) {
+				m.onHotKey(ctx, key, val, unknownTTL)
+			}
+			m.checkRefreshAhead(ctx, key, loader, expiration)
+			return val, nil
+		}
+		if !errors.Is(err, ErrKeyNotFound) {
+			m.recordMiss(key)
+			return nil, err
+		}
+	}
+
+	// 本地未命中，先用布隆过滤器拦截一定不存在的key，布隆过滤器同样存放在Redis中，熔断时跳过
+	if m.filter != nil && !m.degraded() {
+		ok, err := m.filter.MightContain(ctx, key)
+		if err != nil {
+			// 过滤器不可用时不拦截，继续查询Redis
+			m.logger.Errorf("Bloom filter check error: %v", err)
+		} else if !ok {
+			m.recordMiss(key)
+			return nil, ErrKeyFiltered
+		}
+	}
+
+	// 查Redis，值和剩余过期时间在同一次往返中读取
+	val, physicalTTL, err := m.redisGet(ctx, key)
 	if err == nil {
-		m.metrics.IncHit()
+		if isNullValue(val) {
+			m.recordHit(key)
+			m.metrics.IncNegativeHit()
+			if fillLocal {
+				m.backfillLocal(ctx, key, val, m.scaleExpiration(key, physicalTTL))
+			}
+			return nil, ErrCachedNotFound
+		}
+		redisTTL, stale := m.logicalTTL(physicalTTL)
+		if stale {
+			// 已过逻辑过期时间：有加载函数时返回旧值并在后台重新加载，旧值不回写本地缓存
+			if loader == nil {
+				m.recordMiss(key)
+				return nil, ErrKeyNotFound
+			}
+			m.recordHit(key)
+			m.refreshAsync(ctx, key, loader, expiration)
+			return val, nil
+		}
+		m.recordHit(key)
+		if m.shouldRefresh(redisTTL, loader, expiration) {
+			m.refreshAsync(ctx, key, loader, expiration)
+		}
+		if !fillLocal {
+			return val, nil
+		}
+		// 回写本地缓存，本地过期时间按Redis剩余过期时间乘以系数计算，热点key和固定的key直接使用Redis剩余过期时间
+		if m.hotKeys != nil {
+			m.hotKeys.record(key)
+			if m.hotKeys.isHot(key) {
+				m.onHotKey(ctx, key, val, redisTTL)
+				return val, nil
+			}
+		}
+		if m.isPinned(key) {
+			m.extendLocalExpiration(ctx, key, val, redisTTL)
+			return val, nil
+		}
+		m.backfillLocal(ctx, key, val, m.scaleExpiration(key, redisTTL))
 		return val, nil
 	}
-	if !errors.Is(err, ErrKeyNotFound) {
-		m.metrics.IncMiss()
-		return nil, err
+	if errors.Is(err, ErrKeyNotFound) {
+		m.recordMiss(key)
+		if readThrough && refreshLocal {
+			_ = m.local.Delete(ctx, key)
+		}
+	}
+	return nil, err
+}
+
+// backfillLocal 把从Redis读到的值回填到本地缓存，启用异步回填时交给后台协程执行
+func (m *MultiLevelCache) backfillLocal(ctx context.Context, key string, val []byte, expiration time.Duration) {
+	if m.backfill != nil {
+		m.backfill.enqueue(key, val, expiration)
+		return
 	}
+	_ = m.local.Set(withEntrySource(ctx, SourceRedis), key, val, expiration)
+}
 
-	// 本地未命中，查Redis
-	val, err = m.redis.Get(ctx, key)
+// checkRefreshAhead 本地缓存命中时判断是否需要提前刷新
+// 先用本地剩余过期时间粗略判断，只有本地也接近过期时才查询Redis的剩余过期时间，避免每次命中都访问Redis
+func (m *MultiLevelCache) checkRefreshAhead(ctx context.Context, key string, loader LoaderFunc, expiration time.Duration) {
+	if loader == nil || m.config.RefreshAheadFactor <= 0 {
+		return
+	}
+	if reader := m.localTTLReader; reader != nil {
+		ttl, err := reader.TTL(ctx, key)
+		if err != nil || !m.shouldRefresh(ttl, loader, expiration) {
+			return
+		}
+	}
+	if ttl, stale := m.logicalTTL(m.redisTTL(ctx, key)); stale || m.shouldRefresh(ttl, loader, expiration) {
+		m.refreshAsync(ctx, key, loader, expiration)
+	}
+}
+
+// shouldRefresh 剩余过期时间不足 RefreshAheadFactor × 完整过期时间 时需要提前刷新
+func (m *MultiLevelCache) shouldRefresh(remaining time.Duration, loader LoaderFunc, expiration time.Duration) bool {
+	if loader == nil || m.config.RefreshAheadFactor <= 0 || expiration <= 0 || remaining <= 0 {
+		return false
+	}
+	return remaining < time.Duration(float64(expiration)*m.config.RefreshAheadFactor)
+}
+
+// refreshAsync 在后台调用loader刷新key（提前刷新或宽限期内重新加载），同一个key同时只会有一个刷新任务
+func (m *MultiLevelCache) refreshAsync(ctx context.Context, key string, loader LoaderFunc, expiration time.Duration) {
+	if _, loaded := m.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	// 刷新在请求返回后继续进行，不能随请求的ctx一起取消
+	refreshCtx := context.WithoutCancel(ctx)
+	go func() {
+		defer m.refreshing.Delete(key)
+
+		val, err := loader(refreshCtx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			m.setNullValue(refreshCtx, key, m.notFoundTTL(err))
+			return
+		}
+		if err != nil {
+			m.logger.Errorf("Background refresh load error, key: %s, error: %v", key, err)
+			return
+		}
+		if err := m.Set(refreshCtx, key, val, expiration); err != nil {
+			m.logger.Errorf("Background refresh set error, key: %s, error: %v", key, err)
+		}
+	}()
+}
+
+// GetOrLoad 获取缓存的值，两级缓存都未命中时调用loader加载并回填两级缓存
+// 同一个key的并发调用只会触发一次loader，其余调用者等待并共享同一个结果（singleflight）
+// loader返回 ErrKeyNotFound 表示数据不存在，此时按NullValueTTL缓存空值标记；
+// 数据不存在时（刚加载的、命中空值缓存或被布隆过滤器拦截）GetOrLoad都返回 ErrKeyNotFound
+// 返回的字节切片可能被多个调用者共享，调用方不应修改其内容
+func (m *MultiLevelCache) GetOrLoad(ctx context.Context, key string, expiration time.Duration, loader LoaderFunc) ([]byte, error) {
+	expiration = m.resolveExpiration(key, expiration)
+	val, err := m.get(ctx, key, loader, expiration)
 	if err == nil {
-		m.metrics.IncHit()
-		// 回写本地缓存，过期时间可自定义，这里简单用默认
-		_ = m.local.Set(ctx, key, val, 0)
 		return val, nil
 	}
+	if errors.Is(err, ErrCachedNotFound) || errors.Is(err, ErrKeyFiltered) {
+		return nil, ErrKeyNotFound
+	}
+	// 熔断时本地未命中直接回源，重建锁存放在Redis中，此时无法使用
+	degraded := errors.Is(err, ErrCircuitOpen)
+	if !errors.Is(err, ErrKeyNotFound) && !degraded {
+		return nil, err
+	}
+
+	v, err, _ := m.loadGroup.Do(key, func() (interface{}, error) {
+		// 加载由第一个调用者发起，不能因为它的请求被取消而让其他等待者一起失败
+		loadCtx := context.WithoutCancel(ctx)
+		if m.locker != nil && !degraded {
+			return m.loadWithLock(loadCtx, key, expiration, loader)
+		}
+		return m.load(loadCtx, key, expiration, loader)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]byte), nil
+}
+
+// load 调用loader加载数据并回填两级缓存，数据不存在时写入空值标记
+func (m *MultiLevelCache) load(ctx context.Context, key string, expiration time.Duration, loader LoaderFunc) ([]byte, error) {
+	val, err := loader(ctx, key)
+	ctx = withEntrySource(ctx, SourceLoader)
 	if errors.Is(err, ErrKeyNotFound) {
-		m.metrics.IncMiss()
+		m.setNullValue(ctx, key, m.notFoundTTL(err))
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 回填失败不影响本次返回，下次访问会重新加载
+	if err := m.Set(ctx, key, val, expiration); err != nil {
+		m.logger.Errorf("Failed to populate cache after load, key: %s, error: %v", key, err)
+	}
+	return val, nil
+}
+
+// loadWithLock 获取该key的分布式重建锁后再加载，保证多个实例中只有一个回源
+// 未获取到锁的实例轮询Redis等待重建结果，超过RebuildWaitTimeout仍未等到时自行加载；锁服务出错时直接加载
+func (m *MultiLevelCache) loadWithLock(ctx context.Context, key string, expiration time.Duration, loader LoaderFunc) ([]byte, error) {
+	lockKey := "lock:" + key
+	token := newLockToken()
+	ok, err := m.locker.TryLock(ctx, lockKey, token, m.config.RebuildLockTTL)
+	if err != nil {
+		m.logger.Errorf("Rebuild lock error, key: %s, error: %v", key, err)
+		return m.load(ctx, key, expiration, loader)
+	}
+
+	if ok {
+		defer func() {
+			if err := m.locker.Unlock(ctx, lockKey, token); err != nil {
+				m.logger.Errorf("Rebuild unlock error, key: %s, error: %v", key, err)
+			}
+		}()
+		// 获取锁之前其他实例可能刚完成重建，再检查一次Redis
+		if val, done, err := m.pollRedis(ctx, key); done {
+			return val, err
+		}
+		return m.load(ctx, key, expiration, loader)
+	}
+
+	deadline := time.Now().Add(m.config.RebuildWaitTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(m.config.RebuildPollInterval)
+		if val, done, err := m.pollRedis(ctx, key); done {
+			return val, err
+		}
+	}
+	m.logger.Infof("Rebuild wait timeout, loading directly, key: %s", key)
+	return m.load(ctx, key, expiration, loader)
+}
+
+// pollRedis 检查Redis中是否已有重建结果，done为true表示已得到结果（包括空值标记）
+func (m *MultiLevelCache) pollRedis(ctx context.Context, key string) ([]byte, bool, error) {
+	val, physical, err := m.redisGet(ctx, key)
+	if err != nil {
+		return nil, false, nil
+	}
+	if isNullValue(val) {
+		return nil, true, ErrKeyNotFound
+	}
+	ttl, stale := m.logicalTTL(physical)
+	if stale {
+		return nil, false, nil
+	}
+	_ = m.local.Set(withEntrySource(ctx, SourceRedis), key, val, m.scaleExpiration(key, ttl))
+	return val, true, nil
+}
+
+// newLockToken 生成随机的锁持有者标识
+func newLockToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// notFoundTTL 返回加载函数报告数据不存在时空值标记的缓存时间，NotFoundError指定的时间优先
+func (m *MultiLevelCache) notFoundTTL(err error) time.Duration {
+	var notFound *NotFoundError
+	if errors.As(err, &notFound) && notFound.TTL > 0 {
+		return notFound.TTL
+	}
+	return m.config.NullValueTTL
+}
+
+// setNullValue 在两级缓存中写入空值标记，ttl为0时不缓存
+func (m *MultiLevelCache) setNullValue(ctx context.Context, key string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	if err := m.local.Set(ctx, key, nullValue, ttl); err != nil {
+		m.logger.Errorf("Local cache set null value error: %v", err)
+	}
+	if err := m.redis.Set(ctx, key, nullValue, ttl); err != nil {
+		m.logger.Errorf("Redis cache set null value error: %v", err)
 	}
-	return nil, err
 }
 
 // Set 同时写入本地缓存和Redis
+// 启用布隆过滤器时会同时把key加入过滤器，保证新写入的key不会被拦截
 func (m *MultiLevelCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
-	err1 := m.local.Set(ctx, key, value, expiration)
-	err2 := m.redis.Set(ctx, key, value, expiration)
+	if err := m.checkValueSize(key, value, false); err != nil {
+		return err
+	}
+	expiration = m.resolveExpiration(key, expiration)
+	m.addToFilter(ctx, key)
+	// 先写Redis，未指定过期时间时本地过期时间需要根据Redis中实际的过期时间计算
+	// 启用写入合并时，合并窗口内的重复写入只记录最新的值，由窗口结束时统一写入Redis
+	var err2 error
+	if m.coalescer == nil {
+		err2 = m.writeRedis(ctx, key, value, m.physicalExpiration(expiration))
+	} else if coalesced, done := m.coalescer.absorb(key, value, m.physicalExpiration(expiration)); coalesced {
+		m.metrics.IncCoalescedWrite()
+	} else {
+		err2 = m.writeRedis(ctx, key, value, m.physicalExpiration(expiration))
+		done()
+	}
+	err1 := m.local.Set(ctx, key, value, m.localExpiration(ctx, key, expiration))
 	m.metrics.IncSet()
 	if err1 != nil {
-		utils.LogError("Local cache set error: %v", err1)
+		m.logger.Errorf("Local cache set error: %v", err1)
 	}
 	if err2 != nil {
-		utils.LogError("Redis cache set error: %v", err2)
+		m.logger.Errorf("Redis cache set error: %v", err2)
 	}
 	if err1 != nil {
 		return err1
@@ -81,16 +583,534 @@ func (m *MultiLevelCache) Set(ctx context.Context, key string, value []byte, exp
 	return err2
 }
 
+// writeRedis 把值写入Redis，熔断时加入写操作队列
+func (m *MultiLevelCache) writeRedis(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	err := m.redis.Set(ctx, key, value, expiration)
+	if errors.Is(err, ErrCircuitOpen) {
+		m.queueWrite(pendingWrite{key: key, value: value}, expiration)
+		return nil
+	}
+	if err == nil {
+		m.dropQueuedWrites(key)
+	}
+	return err
+}
+
+// SetNX 仅在key不存在时设置值，以Redis为准，写入成功后再写本地缓存
+func (m *MultiLevelCache) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	if err := m.checkValueSize(key, value, true); err != nil {
+		return false, err
+	}
+	expiration = m.resolveExpiration(key, expiration)
+	m.addToFilter(ctx, key)
+	m.cancelCoalesced(key)
+	ok, err := m.redis.SetNX(ctx, key, value, m.physicalExpiration(expiration))
+	if err != nil || !ok {
+		return false, err
+	}
+	m.metrics.IncSet()
+	if err := m.local.Set(ctx, key, value, m.localExpiration(ctx, key, expiration)); err != nil {
+		m.logger.Errorf("Local cache set error: %v", err)
+	}
+	return true, nil
+}
+
+// GetSet 在Redis中设置新值并返回旧值，key不存在或缓存的是空值标记时旧值为nil
+func (m *MultiLevelCache) GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) ([]byte, error) {
+	if err := m.checkValueSize(key, value, true); err != nil {
+		return nil, err
+	}
+	expiration = m.resolveExpiration(key, expiration)
+	m.addToFilter(ctx, key)
+	m.cancelCoalesced(key)
+	old, err := m.redis.GetSet(ctx, key, value, m.physicalExpiration(expiration))
+	if err != nil {
+		return nil, err
+	}
+	m.metrics.IncSet()
+	if err := m.local.Set(ctx, key, value, m.localExpiration(ctx, key, expiration)); err != nil {
+		m.logger.Errorf("Local cache set error: %v", err)
+	}
+	if isNullValue(old) {
+		return nil, nil
+	}
+	return old, nil
+}
+
+// CompareAndSwap 在Redis中原子地比较并替换值，替换失败时删除本地副本，
+// 因为调用方拿到的旧值可能来自已经过时的本地缓存
+func (m *MultiLevelCache) CompareAndSwap(ctx context.Context, key string, old, new []byte, expiration time.Duration) (bool, error) {
+	if err := m.checkValueSize(key, new, true); err != nil {
+		return false, err
+	}
+	expiration = m.resolveExpiration(key, expiration)
+	m.cancelCoalesced(key)
+	swapped, err := m.redis.CompareAndSwap(ctx, key, old, new, m.physicalExpiration(expiration))
+	if err != nil {
+		return false, err
+	}
+	if !swapped {
+		if err := m.local.Delete(ctx, key); err != nil {
+			m.logger.Errorf("Local cache invalidate error: %v", err)
+		}
+		return false, nil
+	}
+	m.metrics.IncSet()
+	if err := m.local.Set(ctx, key, new, m.localExpiration(ctx, key, expiration)); err != nil {
+		m.logger.Errorf("Local cache set error: %v", err)
+	}
+	return true, nil
+}
+
+// localExpiration 计算本地缓存的过期时间：LocalExpirationFactor × Redis过期时间
+// redisTTL不大于0时通过TTL命令读取Redis中的剩余过期时间；
+// 无法得到Redis过期时间（永不过期或Redis不支持查询）时返回0，即使用本地缓存的默认过期时间
+func (m *MultiLevelCache) localExpiration(ctx context.Context, key string, redisTTL time.Duration) time.Duration {
+	if redisTTL <= 0 {
+		redisTTL, _ = m.logicalTTL(m.redisTTL(ctx, key))
+	}
+	return m.scaleExpiration(key, redisTTL)
+}
+
+// physicalExpiration 返回写入Redis的过期时间：启用过期宽限期时在逻辑过期时间的基础上加上宽限期
+func (m *MultiLevelCache) physicalExpiration(expiration time.Duration) time.Duration {
+	if expiration <= 0 || m.config.StaleGracePeriod <= 0 {
+		return expiration
+	}
+	return expiration + m.config.StaleGracePeriod
+}
+
+// logicalTTL 把Redis中的剩余过期时间换算为逻辑剩余过期时间，stale表示已过逻辑过期时间、处于宽限期内
+func (m *MultiLevelCache) logicalTTL(physical time.Duration) (ttl time.Duration, stale bool) {
+	if physical <= 0 || m.config.StaleGracePeriod <= 0 {
+		return physical, false
+	}
+	ttl = physical - m.config.StaleGracePeriod
+	if ttl <= 0 {
+		return 0, true
+	}
+	return ttl, false
+}
+
+// unknownTTL 表示调用方还没有读取Redis中的剩余过期时间，与表示永不过期的0区分，
+// 已经读取过的调用方直接传入读到的值，不会再查询一次
+const unknownTTL time.Duration = -1
+
+// redisGet 读取Redis中的值及其剩余过期时间，Redis缓存支持时通过一个管道完成，否则再执行一次TTL查询
+// 剩余过期时间无法得到或永不过期时为0
+func (m *MultiLevelCache) redisGet(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	if reader := m.redisValueTTLReader; reader != nil {
+		return reader.GetWithTTL(ctx, key)
+	}
+	val, err := m.redis.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return val, m.redisTTL(ctx, key), nil
+}
+
+// redisTTL 通过TTL命令读取key在Redis中的剩余过期时间，无法得到时返回0
+func (m *MultiLevelCache) redisTTL(ctx context.Context, key string) time.Duration {
+	reader := m.redisTTLReader
+	if reader == nil {
+		return 0
+	}
+	ttl, err := reader.TTL(ctx, key)
+	if err != nil {
+		return 0
+	}
+	return ttl
+}
+
+// scaleExpiration 把Redis过期时间乘以本地缓存过期时间系数得到本地缓存过期时间，
+// key匹配的过期策略指定了LocalFactor时使用它，否则使用LocalExpirationFactor；固定的key不缩短
+func (m *MultiLevelCache) scaleExpiration(key string, redisTTL time.Duration) time.Duration {
+	if m.isPinned(key) {
+		return redisTTL
+	}
+	factor := m.config.LocalExpirationFactor
+	if policy := m.ttlPolicy(key); policy != nil && policy.LocalFactor > 0 {
+		factor = policy.LocalFactor
+	}
+	if redisTTL <= 0 || factor <= 0 {
+		return redisTTL
+	}
+
+	local := time.Duration(float64(redisTTL) * factor)
+	if local <= 0 {
+		return redisTTL
+	}
+	return local
+}
+
+// MGet 批量获取缓存的值：先从本地缓存获取，剩余的key通过一次MGET从Redis获取并回填本地缓存
+// 返回命中的键值对以及未命中的key列表，缓存为空值标记的key视为未命中
+func (m *MultiLevelCache) MGet(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	found, missing, err := m.mget(ctx, keys)
+	var nulls []string
+	m.dropNullValues(found, &nulls)
+	if err != nil {
+		return found, append(missing, nulls...), err
+	}
+	for key := range found {
+		m.recordHit(key)
+	}
+	for _, key := range missing {
+		m.recordMiss(key)
+	}
+	for _, key := range nulls {
+		m.recordMiss(key)
+		m.metrics.IncNegativeHit()
+	}
+	return found, append(missing, nulls...), nil
+}
+
+// GetMulti 与MGet相同，本地缓存部分命中的结果直接使用，其余的key通过一次MGET从Redis获取并回填本地缓存；
+// 配置了MultiLoader时，两级缓存都未命中的key再通过一次批量加载获取并回填两级缓存，
+// 加载结果中没有的key按NullValueTTL缓存空值标记。缓存为空值标记的key不会重新加载
+func (m *MultiLevelCache) GetMulti(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	if m.multiLoader == nil {
+		return m.MGet(ctx, keys)
+	}
+	return m.GetMultiOrLoad(ctx, keys, 0, m.multiLoader)
+}
+
+// GetMultiOrLoad 与GetMulti相同，使用调用方传入的批量加载函数，加载的数据按expiration回填两级缓存
+func (m *MultiLevelCache) GetMultiOrLoad(ctx context.Context, keys []string, expiration time.Duration, loader MultiLoaderFunc) (map[string][]byte, []string, error) {
+	found, missing, err := m.mget(ctx, keys)
+	var nulls []string
+	m.dropNullValues(found, &nulls)
+	if err != nil {
+		return found, append(missing, nulls...), err
+	}
+	for key := range found {
+		m.recordHit(key)
+	}
+	for _, key := range missing {
+		m.recordMiss(key)
+	}
+	for _, key := range nulls {
+		m.recordMiss(key)
+		m.metrics.IncNegativeHit()
+	}
+	if len(missing) == 0 {
+		return found, nulls, nil
+	}
+
+	loadKeys := make([]string, 0, len(missing))
+	seen := make(map[string]struct{}, len(missing))
+	for _, key := range missing {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			loadKeys = append(loadKeys, key)
+		}
+	}
+	loaded, err := loader(ctx, loadKeys)
+	if err != nil {
+		return found, append(missing, nulls...), err
+	}
+
+	// 回填失败不影响本次返回，下次访问会重新加载
+	items := make(map[string][]byte, len(loaded))
+	for _, key := range loadKeys {
+		val, ok := loaded[key]
+		if !ok {
+			m.setNullValue(ctx, key, m.config.NullValueTTL)
+			nulls = append(nulls, key)
+			continue
+		}
+		items[key] = val
+		found[key] = val
+	}
+	if len(items) > 0 {
+		if err := m.MSet(withEntrySource(ctx, SourceLoader), items, expiration); err != nil {
+			m.logger.Errorf("Failed to populate cache after bulk load: %v", err)
+		}
+	}
+	return found, nulls, nil
+}
+
+// mget 从两级缓存批量获取，结果中包含空值标记，不计入整体的命中指标
+func (m *MultiLevelCache) mget(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	readThrough, refreshLocal := readThroughFrom(ctx)
+	fillLocal := !readThrough || refreshLocal
+
+	found, missing := make(map[string][]byte, len(keys)), keys
+	if !readThrough {
+		var err error
+		found, missing, err = m.local.MGet(ctx, keys)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(missing) > 0 {
+		redisFound, redisMissing, err := m.redis.MGet(ctx, missing)
+		// 强一致读无法只返回本地缓存中的结果，熔断时同样返回错误
+		if err != nil && (!errors.Is(err, ErrCircuitOpen) || readThrough) {
+			return found, missing, err
+		}
+		if errors.Is(err, ErrCircuitOpen) {
+			// 熔断时只返回本地缓存中的结果
+			redisFound, redisMissing = nil, missing
+		}
+
+		// 回填本地缓存，已过逻辑过期时间的旧值视为未命中
+		keysToFill := make([]string, 0, len(redisFound))
+		for key := range redisFound {
+			keysToFill = append(keysToFill, key)
+		}
+		ttls := m.redisTTLs(ctx, keysToFill)
+		for key, val := range redisFound {
+			ttl, stale := m.logicalTTL(ttls[key])
+			if isNullValue(val) {
+				ttl, stale = ttls[key], false
+			}
+			if stale {
+				redisMissing = append(redisMissing, key)
+				continue
+			}
+			if fillLocal {
+				m.backfillLocal(ctx, key, val, m.scaleExpiration(key, ttl))
+			}
+			found[key] = val
+		}
+		missing = redisMissing
+		if readThrough && refreshLocal {
+			for _, key := range missing {
+				_ = m.local.Delete(ctx, key)
+			}
+		}
+	}
+	return found, missing, nil
+}
+
+// dropNullValues 把结果中的空值标记移到未命中列表
+func (m *MultiLevelCache) dropNullValues(found map[string][]byte, missing *[]string) {
+	for key, val := range found {
+		if isNullValue(val) {
+			delete(found, key)
+			*missing = append(*missing, key)
+		}
+	}
+}
+
+// MSet 批量写入本地缓存和Redis，Redis写入通过管道一次完成
+// 值大小策略为reject时，只要有一个值超过MaxValueSize整批都不写入
+func (m *MultiLevelCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	for key, value := range items {
+		if err := m.checkValueSize(key, value, false); err != nil {
+			return err
+		}
+	}
+	if expiration > 0 || len(m.config.TTLPolicies) == 0 {
+		return m.mset(ctx, items, expiration)
+	}
+	// 未指定过期时间时按过期策略分组写入
+	var firstErr error
+	for exp, group := range m.groupByExpiration(items, expiration) {
+		if err := m.mset(ctx, group, exp); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// mset 以相同的过期时间批量写入两级缓存
+func (m *MultiLevelCache) mset(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	m.addToFilter(ctx, keys...)
+	m.cancelCoalesced(keys...)
+
+	err2 := m.redis.MSet(ctx, items, m.physicalExpiration(expiration))
+	if errors.Is(err2, ErrCircuitOpen) {
+		for key, value := range items {
+			m.queueWrite(pendingWrite{key: key, value: value}, m.physicalExpiration(expiration))
+		}
+		err2 = nil
+	} else if err2 == nil {
+		m.dropQueuedWrites(keys...)
+	}
+	var err1 error
+	expirations := m.localExpirations(ctx, keys, expiration)
+	for key, value := range items {
+		if err := m.local.Set(ctx, key, value, expirations[key]); err != nil && err1 == nil {
+			err1 = err
+		}
+	}
+	for range items {
+		m.metrics.IncSet()
+	}
+	if err1 != nil {
+		m.logger.Errorf("Local cache mset error: %v", err1)
+	}
+	if err2 != nil {
+		m.logger.Errorf("Redis cache mset error: %v", err2)
+	}
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// localExpirations 批量计算本地缓存的过期时间，redisTTL不大于0时通过一次管道读取各key在Redis中的剩余过期时间
+func (m *MultiLevelCache) localExpirations(ctx context.Context, keys []string, redisTTL time.Duration) map[string]time.Duration {
+	expirations := make(map[string]time.Duration, len(keys))
+	if len(keys) == 0 {
+		return expirations
+	}
+
+	if redisTTL > 0 {
+		for _, key := range keys {
+			expirations[key] = m.scaleExpiration(key, redisTTL)
+		}
+		return expirations
+	}
+
+	ttls := m.redisTTLs(ctx, keys)
+	for _, key := range keys {
+		ttl, _ := m.logicalTTL(ttls[key])
+		expirations[key] = m.scaleExpiration(key, ttl)
+	}
+	return expirations
+}
+
+// redisTTLs 通过一次管道读取各key在Redis中的剩余过期时间
+func (m *MultiLevelCache) redisTTLs(ctx context.Context, keys []string) map[string]time.Duration {
+	reader := m.redisTTLReader
+	if reader == nil || len(keys) == 0 {
+		return map[string]time.Duration{}
+	}
+	ttls, err := reader.MTTL(ctx, keys)
+	if err != nil {
+		return map[string]time.Duration{}
+	}
+	return ttls
+}
+
+// GetWithTTL 获取缓存的值及其剩余过期时间，剩余过期时间取自实际提供该值的缓存层，
+// 可用于实现提前刷新或展示过期信息；永不过期时剩余过期时间为0
+func (m *MultiLevelCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	val, err := m.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	ttl, err := m.TTL(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return val, ttl, nil
+}
+
+// TTL 返回key的剩余过期时间：key在本地缓存中时返回本地缓存的剩余过期时间，否则通过TTL命令查询Redis，
+// 启用过期宽限期时返回的是逻辑剩余过期时间
+// key不存在时返回 ErrKeyNotFound，永不过期时返回0
+func (m *MultiLevelCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if reader := m.localTTLReader; reader != nil {
+		ttl, err := reader.TTL(ctx, key)
+		if err == nil {
+			return ttl, nil
+		}
+		if !errors.Is(err, ErrKeyNotFound) {
+			return 0, err
+		}
+	}
+
+	reader := m.redisTTLReader
+	if reader == nil {
+		return 0, ErrCacheInternal
+	}
+	physical, err := reader.TTL(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	ttl, stale := m.logicalTTL(physical)
+	if stale {
+		// 已过逻辑过期时间，返回最小的正值而不是"永不过期"
+		return time.Nanosecond, nil
+	}
+	return ttl, nil
+}
+
+// extendLocalExpiration 把热点key的本地缓存过期时间延长为Redis中的剩余过期时间，
+// 使热点key在本地停留更久，同时不会比Redis中的数据存活得更长；redisTTL为 unknownTTL 时通过TTL命令读取
+// Redis中永不过期或无法查询剩余过期时间时按本地缓存的默认过期时间回填，热点key同样会写入本地缓存
+func (m *MultiLevelCache) extendLocalExpiration(ctx context.Context, key string, val []byte, redisTTL time.Duration) {
+	if redisTTL == unknownTTL {
+		redisTTL, _ = m.logicalTTL(m.redisTTL(ctx, key))
+	}
+	if err := m.local.Set(withEntrySource(ctx, sourceKeep), key, val, redisTTL); err != nil {
+		m.logger.Errorf("Local cache extend hot key error: %v", err)
+	}
+}
+
+// GetHotKeys 返回当前统计窗口内的热点key，按访问次数从高到低排序；未启用热点检测时返回nil
+func (m *MultiLevelCache) GetHotKeys() []HotKey {
+	if m.hotKeys == nil {
+		return nil
+	}
+	return m.hotKeys.hotKeys()
+}
+
+// Incr 将计数加一并返回新值
+func (m *MultiLevelCache) Incr(ctx context.Context, key string) (int64, error) {
+	return m.IncrBy(ctx, key, 1)
+}
+
+// Decr 将计数减一并返回新值
+func (m *MultiLevelCache) Decr(ctx context.Context, key string) (int64, error) {
+	return m.IncrBy(ctx, key, -1)
+}
+
+// IncrBy 在Redis上原子地增加计数并返回新值，计数以十进制字符串保存，可以通过Get读取
+// 本地副本直接删除而不是写入新值，避免并发增加时较旧的值覆盖较新的值
+// 启用编码、加密或版本信封时计数无法在Redis中原子地增加，返回 ErrCacheInternal
+func (m *MultiLevelCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	if len(m.redisCodecs) > 0 {
+		return 0, fmt.Errorf("counters are not supported with value encoding: %w", ErrCacheInternal)
+	}
+	// 启用熔断时由熔断包装转发，否则直接使用底层的Redis缓存
+	counter, ok := m.redis.(Counter)
+	if !ok {
+		counter, ok = unwrap(m.redis).(Counter)
+	}
+	if !ok {
+		return 0, ErrCacheInternal
+	}
+	m.cancelCoalesced(key)
+	m.addToFilter(ctx, key)
+
+	val, err := counter.IncrBy(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.local.Delete(ctx, key); err != nil {
+		m.logger.Errorf("Local cache invalidate counter error: %v", err)
+	}
+	return val, nil
+}
+
 // Delete 同时删除本地缓存和Redis
 func (m *MultiLevelCache) Delete(ctx context.Context, key string) error {
+	m.Unpin(key)
+	m.cancelCoalesced(key)
 	err1 := m.local.Delete(ctx, key)
 	err2 := m.redis.Delete(ctx, key)
+	if errors.Is(err2, ErrCircuitOpen) {
+		m.queueWrite(pendingWrite{key: key, delete: true}, 0)
+		err2 = nil
+	} else if err2 == nil {
+		m.dropQueuedWrites(key)
+	}
 	m.metrics.IncDel()
 	if err1 != nil {
-		utils.LogError("Local cache delete error: %v", err1)
+		m.logger.Errorf("Local cache delete error: %v", err1)
 	}
 	if err2 != nil {
-		utils.LogError("Redis cache delete error: %v", err2)
+		m.logger.Errorf("Redis cache delete error: %v", err2)
 	}
 	if err1 != nil {
 		return err1
@@ -99,13 +1119,179 @@ func (m *MultiLevelCache) Delete(ctx context.Context, key string) error {
 }
 
 // Exists 检查本地缓存和Redis是否存在
+// 空值缓存表示数据不存在，因此命中空值标记时返回false
 func (m *MultiLevelCache) Exists(ctx context.Context, key string) (bool, error) {
-	ok, err := m.local.Exists(ctx, key)
-	if err == nil && ok {
-		return true, nil
+	val, err := m.local.Get(ctx, key)
+	if err == nil {
+		return !isNullValue(val), nil
+	}
+	val, err = m.redis.Get(ctx, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !isNullValue(val), nil
+}
+
+// addToFilter 把key加入布隆过滤器，熔断时跳过，失败只记录日志
+func (m *MultiLevelCache) addToFilter(ctx context.Context, keys ...string) {
+	if m.filter == nil || len(keys) == 0 || m.degraded() {
+		return
+	}
+	if err := m.filter.Add(ctx, keys...); err != nil {
+		m.logger.Errorf("Bloom filter add error: %v", err)
+	}
+}
+
+// AddToFilter 将key预先加入布隆过滤器，用于启用过滤器前导入数据源中已有的key
+func (m *MultiLevelCache) AddToFilter(ctx context.Context, keys ...string) error {
+	if m.filter == nil {
+		return nil
+	}
+	return m.filter.Add(ctx, keys...)
+}
+
+// Keys 返回Redis中匹配pattern的所有key
+// Redis是数据的权威来源，通过多级缓存写入的key都在Redis中，因此只遍历Redis
+func (m *MultiLevelCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	return m.redis.Keys(ctx, pattern)
+}
+
+// Scan 遍历Redis中匹配pattern的key，fn返回false时停止遍历
+func (m *MultiLevelCache) Scan(ctx context.Context, pattern string, fn func(key string) bool) error {
+	return m.redis.Scan(ctx, pattern, fn)
+}
+
+// ClearNamespace 删除Redis中当前命名空间下的所有key，返回删除的数量
+// 本地缓存也设置了命名空间时一并清理，否则本地缓存中的数据会在各自过期后失效
+func (m *MultiLevelCache) ClearNamespace(ctx context.Context) (int64, error) {
+	if m.backfill != nil {
+		m.backfill.cancelPrefix("")
+	}
+	if m.coalescer != nil {
+		m.coalescer.cancelPrefix("")
+	}
+	if clearer, ok := unwrap(m.local).(NamespaceClearer); ok {
+		if _, err := clearer.ClearNamespace(ctx); err != nil && !errors.Is(err, ErrNamespaceNotSet) {
+			m.logger.Errorf("Local cache clear namespace error: %v", err)
+		}
+	}
+
+	clearer, ok := unwrap(m.redis).(NamespaceClearer)
+	if !ok {
+		return 0, ErrNamespaceNotSet
+	}
+	return clearer.ClearNamespace(ctx)
+}
+
+// OnEvicted 注册本地缓存的淘汰回调，key从本地缓存中被删除或过期清理时调用，可用于记录日志、重新预热或向其他节点传播
+// 空值标记的淘汰不会触发回调
+func (m *MultiLevelCache) OnEvicted(fn func(key string, value []byte)) {
+	if m.localNotifier == nil {
+		m.logger.Errorf("Local cache %s does not support eviction callbacks", m.local.Name())
+		return
+	}
+	m.localNotifier.OnEvicted(skipNullValues(fn))
+}
+
+// OnExpired 注册本地缓存的过期回调，仅在key因过期从本地缓存中被清理时调用
+// 空值标记的过期不会触发回调
+func (m *MultiLevelCache) OnExpired(fn func(key string, value []byte)) {
+	if m.localNotifier == nil {
+		m.logger.Errorf("Local cache %s does not support eviction callbacks", m.local.Name())
+		return
+	}
+	m.localNotifier.OnExpired(skipNullValues(fn))
+}
+
+// skipNullValues 包装回调，忽略空值标记
+func skipNullValues(fn func(key string, value []byte)) func(key string, value []byte) {
+	if fn == nil {
+		return nil
+	}
+	return func(key string, value []byte) {
+		if isNullValue(value) {
+			return
+		}
+		fn(key, value)
+	}
+}
+
+// degraded 判断当前是否因Redis熔断处于降级状态
+func (m *MultiLevelCache) degraded() bool {
+	return m.breaker != nil && m.breaker.isOpen()
+}
+
+// queueWrite 熔断期间把Redis写操作加入队列，expiration为0时使用Redis缓存的默认过期时间
+func (m *MultiLevelCache) queueWrite(w pendingWrite, expiration time.Duration) {
+	if expiration > 0 {
+		w.expireAt = time.Now().Add(expiration)
+	}
+	m.metrics.IncQueued()
+	if dropped := m.pending.push(w); dropped > 0 {
+		m.metrics.AddDropped(int64(dropped))
+		m.logger.Errorf("Degraded write queue full, dropped %d writes", dropped)
+	}
+}
+
+// dropQueuedWrites Redis恢复后直接写入成功的key不再需要重放旧的写操作
+func (m *MultiLevelCache) dropQueuedWrites(keys ...string) {
+	if m.pending == nil {
+		return
+	}
+	m.pending.remove(keys...)
+}
+
+// onBreakerStateChange 熔断器状态变化时记录日志，恢复后重放排队的写操作
+func (m *MultiLevelCache) onBreakerStateChange(from, to breakerState) {
+	switch to {
+	case breakerOpen:
+		if from == breakerClosed {
+			m.metrics.IncCircuitOpen()
+			m.logger.Errorf("Redis circuit breaker opened, serving from local cache only")
+		}
+	case breakerClosed:
+		m.logger.Infof("Redis circuit breaker closed, replaying %d queued writes", m.pending.len())
+		go m.replayWrites()
+	}
+}
+
+// replayWrites 按顺序重放熔断期间排队的写操作，重放失败时把剩余的写操作放回队列
+// 排队期间已经过期的写入改为删除，避免Redis中保留熔断前的旧值
+func (m *MultiLevelCache) replayWrites() {
+	if !m.replaying.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.replaying.Store(false)
+
+	ctx := context.Background()
+	writes := m.pending.drain()
+	// 熔断期间写入的key没有加入布隆过滤器，重放成功后补上，否则之后的读取会被过滤器拦截
+	var written []string
+	defer func() { m.addToFilter(ctx, written...) }()
+	for i, w := range writes {
+		var err error
+		expiration := time.Duration(0)
+		if !w.expireAt.IsZero() {
+			expiration = time.Until(w.expireAt)
+		}
+		if w.delete || !w.expireAt.IsZero() && expiration <= 0 {
+			err = m.redis.Delete(ctx, w.key)
+		} else {
+			err = m.redis.Set(ctx, w.key, w.value, expiration)
+		}
+		if err != nil {
+			m.pending.requeue(writes[i:])
+			m.logger.Errorf("Replay queued writes error, %d writes remain queued: %v", len(writes)-i, err)
+			return
+		}
+		if !w.delete {
+			written = append(written, w.key)
+		}
+		m.metrics.IncReplayed()
 	}
-	ok, err = m.redis.Exists(ctx, key)
-	return ok, err
 }
 
 // Name 返回缓存名称
@@ -115,6 +1301,22 @@ func (m *MultiLevelCache) Name() string {
 
 // Close 关闭所有缓存资源
 func (m *MultiLevelCache) Close() error {
+	m.unpinAll()
+	if m.coalescer != nil {
+		// 关闭Redis之前写入尚未写入的合并值
+		m.coalescer.flushAll()
+	}
+	if m.reporter != nil {
+		m.reporter.Stop()
+	}
+	if m.stopKeyEvents != nil {
+		m.stopKeyEvents()
+		<-m.keyEventsDone
+	}
+	if m.stopInvalidations != nil {
+		m.stopInvalidations()
+		<-m.invalidationsDone
+	}
 	err1 := m.local.Close()
 	err2 := m.redis.Close()
 	if err1 != nil {
@@ -123,6 +1325,102 @@ func (m *MultiLevelCache) Close() error {
 	return err2
 }
 
+// Metrics 返回多级缓存的指标，包括整体计数以及各层级的计数和延迟分布
+func (m *MultiLevelCache) Metrics() *metrics.CacheMetrics {
+	return m.metrics
+}
+
+// KeyInfo key在各层缓存中的状态，用于排查数据不一致等问题
+type KeyInfo struct {
+	Key     string
+	InLocal bool
+	InRedis bool
+	// 值的字节数，两层都有时取Redis中的值
+	Size int
+	// Redis中分块存储时的分块数，未分块时为0
+	Chunks int
+	// 缓存的是空值标记（数据源中不存在）
+	NullValue bool
+	// 本地与Redis中的值不一致
+	Mismatch bool
+	// 本地缓存的剩余过期时间
+	LocalTTL time.Duration
+	// Redis中的逻辑剩余过期时间，不含过期宽限期
+	RedisTTL time.Duration
+	// 已过逻辑过期时间，处于过期宽限期内
+	Stale bool
+	// 本地缓存条目的元数据，不在本地缓存中或本地缓存不支持时为nil
+	LocalMeta *EntryMeta
+}
+
+// Lookup 查询key在各层缓存中的状态，直接访问底层缓存，不计入指标也不影响热点key统计
+func (m *MultiLevelCache) Lookup(ctx context.Context, key string) (KeyInfo, error) {
+	info := KeyInfo{Key: key}
+	local, redis := unwrap(m.local), unwrap(m.redis)
+
+	var localVal []byte
+	var err error
+	if inspector, ok := local.(EntryInspector); ok {
+		// 不计入本地条目的命中次数
+		var meta EntryMeta
+		if localVal, meta, err = inspector.Inspect(ctx, key); err == nil {
+			info.LocalMeta = &meta
+		}
+	} else {
+		localVal, err = local.Get(ctx, key)
+	}
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return info, fmt.Errorf("failed to get local entry: %w", err)
+	}
+	info.InLocal = err == nil
+	if info.InLocal {
+		info.Size = len(localVal)
+		info.NullValue = isNullValue(localVal)
+		if reader, ok := local.(TTLReader); ok {
+			info.LocalTTL, _ = reader.TTL(ctx, key)
+		}
+	}
+
+	redisVal, err := redis.Get(ctx, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return info, fmt.Errorf("failed to get redis entry: %w", err)
+	}
+	info.InRedis = err == nil
+	if info.InRedis && !isChunkManifest(redisVal) {
+		// 按写入时相反的顺序解码
+		for i := len(m.redisCodecs) - 1; i >= 0; i-- {
+			if redisVal, err = m.redisCodecs[i].decodeValue(key, redisVal); err != nil {
+				return info, fmt.Errorf("failed to decode redis entry: %w", err)
+			}
+		}
+	}
+	if info.InRedis {
+		info.Size = len(redisVal)
+		info.NullValue = isNullValue(redisVal)
+		info.Mismatch = info.InLocal && !bytes.Equal(localVal, redisVal)
+		if manifest, ok := parseChunkManifest(redisVal); ok {
+			// 分块存储的值不会写入本地缓存，只报告清单中记录的大小，不读取分块
+			info.Size, info.Chunks = manifest.size, manifest.count
+			info.Mismatch = info.InLocal
+		}
+		if reader, ok := redis.(TTLReader); ok {
+			physical, _ := reader.TTL(ctx, key)
+			info.RedisTTL, info.Stale = m.logicalTTL(physical)
+		}
+	}
+	return info, nil
+}
+
+// InvalidateLocal 只删除本地缓存中的key，下次读取时从Redis回填
+func (m *MultiLevelCache) InvalidateLocal(ctx context.Context, key string) error {
+	return m.local.Delete(ctx, key)
+}
+
+// Stats 返回当前的指标快照，包括整体和各层级的命中率以及延迟分布
+func (m *MultiLevelCache) Stats() metrics.Stats {
+	return m.metrics.Stats()
+}
+
 // PrintMetrics 打印缓存命中等指标
 func (m *MultiLevelCache) PrintMetrics() {
 	m.metrics.PrintMetrics()
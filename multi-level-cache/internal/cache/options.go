@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"multi-level-cache/internal/config"
+	"multi-level-cache/pkg/metrics"
+	"multi-level-cache/pkg/utils"
+)
+
+// Option 构造缓存时的可选配置，通过 WithName、WithLoader 等函数创建，后面的选项覆盖前面的选项
+// 各个选项只对说明中列出的构造函数生效，传给其他构造函数时忽略
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc 以函数实现的 Option
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// options 汇总所有构造函数的可选配置
+type options struct {
+	name              string
+	defaultExpiration time.Duration
+	logger            utils.Logger
+	metrics           *metrics.CacheMetrics
+	codec             Codec
+	encryptor         Encryptor
+	schema            *SchemaVersion
+	migrate           MigrateFunc
+	config            *config.MultiLevelCacheConfig
+	filter            KeyFilter
+	loader            LoaderFunc
+	multiLoader       MultiLoaderFunc
+	statsSink         metrics.Sink
+	redisHooks        []redis.Hook
+}
+
+// newOptions 以给定的默认名称和过期时间应用所有选项
+func newOptions(name string, defaultExpiration time.Duration, opts []Option) options {
+	o := options{
+		name:              name,
+		defaultExpiration: defaultExpiration,
+		logger:            utils.DefaultLogger,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&o)
+		}
+	}
+	return o
+}
+
+// WithName 设置缓存名称，用于日志和指标，适用于所有构造函数
+func WithName(name string) Option {
+	return optionFunc(func(o *options) {
+		o.name = name
+	})
+}
+
+// WithDefaultExpiration 设置未指定过期时间时使用的默认过期时间，适用于 NewLocalCache 和 NewRedisCache，
+// 本地缓存配置中的DefaultExpiration优先
+func WithDefaultExpiration(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		o.defaultExpiration = d
+	})
+}
+
+// WithLogger 设置输出日志使用的Logger，默认为 utils.DefaultLogger，适用于所有构造函数
+func WithLogger(logger utils.Logger) Option {
+	return optionFunc(func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	})
+}
+
+// WithMetrics 使用已有的指标实例，便于多个缓存共享或由调用方统一导出，适用于 NewMultiLevelCache
+func WithMetrics(m *metrics.CacheMetrics) Option {
+	return optionFunc(func(o *options) {
+		o.metrics = m
+	})
+}
+
+// WithCodec 设置写入Redis前的值编码方式（如压缩），适用于 NewMultiLevelCache
+func WithCodec(codec Codec) Option {
+	return optionFunc(func(o *options) {
+		o.codec = codec
+	})
+}
+
+// WithEncryptor 设置写入Redis前的值加密方式，适用于 NewMultiLevelCache
+func WithEncryptor(encryptor Encryptor) Option {
+	return optionFunc(func(o *options) {
+		o.encryptor = encryptor
+	})
+}
+
+// WithSchema 在Redis中的值前面加上版本信封，记录值的编码格式和结构版本，适用于 NewMultiLevelCache
+// 读取到其他版本的值时调用migrate转换为当前版本，migrate为nil时按未命中处理，缓存的结构变化时不需要清空缓存
+func WithSchema(current SchemaVersion, migrate MigrateFunc) Option {
+	return optionFunc(func(o *options) {
+		o.schema = &current
+		o.migrate = migrate
+	})
+}
+
+// WithConfig 设置多级缓存配置，未设置时使用默认配置，适用于 NewMultiLevelCache
+func WithConfig(cfg *config.MultiLevelCacheConfig) Option {
+	return optionFunc(func(o *options) {
+		o.config = cfg
+	})
+}
+
+// WithFilter 设置布隆过滤器，本地缓存未命中时用于拦截一定不存在的key，适用于 NewMultiLevelCache
+func WithFilter(filter KeyFilter) Option {
+	return optionFunc(func(o *options) {
+		o.filter = filter
+	})
+}
+
+// WithLoader 设置加载函数，启用提前刷新时Get使用它在后台刷新即将过期的key，适用于 NewMultiLevelCache
+func WithLoader(loader LoaderFunc) Option {
+	return optionFunc(func(o *options) {
+		o.loader = loader
+	})
+}
+
+// WithMultiLoader 设置批量加载函数，GetMulti在两级缓存都未命中时用它一次加载剩余的key，适用于 NewMultiLevelCache
+func WithMultiLoader(loader MultiLoaderFunc) Option {
+	return optionFunc(func(o *options) {
+		o.multiLoader = loader
+	})
+}
+
+// WithStatsSink 设置指标接收方，配置了StatsReportInterval时每隔该间隔收到一次指标快照，适用于 NewMultiLevelCache
+func WithStatsSink(sink metrics.Sink) Option {
+	return optionFunc(func(o *options) {
+		o.statsSink = sink
+	})
+}
+
+// Options 本地缓存和Redis缓存的配置选项
+//
+// Deprecated: 使用 WithName 和 WithDefaultExpiration，零值字段不再覆盖默认值
+type Options struct {
+	// 缓存的名称
+	Name string
+
+	// 默认过期时间
+	DefaultExpiration time.Duration
+}
+
+func (opt Options) apply(o *options) {
+	if opt.Name != "" {
+		o.name = opt.Name
+	}
+	if opt.DefaultExpiration > 0 {
+		o.defaultExpiration = opt.DefaultExpiration
+	}
+}
+
+// MultiLevelCacheOptions 多级缓存配置选项
+//
+// Deprecated: 使用 WithName、WithConfig、WithFilter 等函数式选项，零值字段不会覆盖之前的选项
+type MultiLevelCacheOptions struct {
+	Name string
+
+	// 多级缓存配置，为nil时使用默认配置
+	Config *config.MultiLevelCacheConfig
+
+	// 布隆过滤器，本地缓存未命中时用于拦截一定不存在的key
+	Filter KeyFilter
+
+	// 加载函数，启用提前刷新时Get使用它在后台刷新即将过期的key
+	Loader LoaderFunc
+
+	// 批量加载函数，GetMulti在两级缓存都未命中时用它一次加载剩余的key
+	MultiLoader MultiLoaderFunc
+
+	// 指标接收方，配置了StatsReportInterval时每隔该间隔收到一次指标快照
+	StatsSink metrics.Sink
+
+	// 加密器，不为nil时写入Redis的值先加密，读取后解密，本地缓存中保存明文
+	Encryptor Encryptor
+}
+
+func (opt MultiLevelCacheOptions) apply(o *options) {
+	if opt.Name != "" {
+		o.name = opt.Name
+	}
+	if opt.Config != nil {
+		o.config = opt.Config
+	}
+	if opt.Filter != nil {
+		o.filter = opt.Filter
+	}
+	if opt.Loader != nil {
+		o.loader = opt.Loader
+	}
+	if opt.MultiLoader != nil {
+		o.multiLoader = opt.MultiLoader
+	}
+	if opt.StatsSink != nil {
+		o.statsSink = opt.StatsSink
+	}
+	if opt.Encryptor != nil {
+		o.encryptor = opt.Encryptor
+	}
+}
+
+// WithRedisHooks 把hooks添加到Redis客户端上，例如统计命令的指标，适用于 NewRedisCache
+func WithRedisHooks(hooks ...redis.Hook) Option {
+	return optionFunc(func(o *options) {
+		o.redisHooks = append(o.redisHooks, hooks...)
+	})
+}
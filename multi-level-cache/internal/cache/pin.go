@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PinnedKey 固定在本地缓存中的key
+type PinnedKey struct {
+	Key string
+	// 是否通过Pin手动固定，手动固定的key不会因为不再是热点而自动取消
+	Manual bool
+}
+
+// pinnedEntry 固定key的状态
+type pinnedEntry struct {
+	manual bool
+	// 在Redis过期前触发刷新
+	timer *time.Timer
+}
+
+// pinner 管理固定在本地缓存中的key
+type pinner struct {
+	mu      sync.Mutex
+	entries map[string]*pinnedEntry
+}
+
+// onHotKey key成为热点或仍是热点时调用：启用固定时固定该key，否则只把本地过期时间延长到与Redis一致
+// 调用方还没有读取Redis中的剩余过期时间时redisTTL传入 unknownTTL
+func (m *MultiLevelCache) onHotKey(ctx context.Context, key string, val []byte, redisTTL time.Duration) {
+	if m.config.EnableHotKeyPinning {
+		m.pin(ctx, key, val, redisTTL, false)
+		return
+	}
+	m.extendLocalExpiration(ctx, key, val, redisTTL)
+}
+
+// isPinned 判断key是否固定在本地缓存中
+func (m *MultiLevelCache) isPinned(key string) bool {
+	m.pins.mu.Lock()
+	defer m.pins.mu.Unlock()
+	_, ok := m.pins.entries[key]
+	return ok
+}
+
+// pin 固定key：本地过期时间延长到Redis中的剩余过期时间，并安排在Redis过期前刷新
+// 固定的key数量达到MaxPinnedKeys时不再固定，但同样按Redis中的剩余过期时间写入本地缓存
+func (m *MultiLevelCache) pin(ctx context.Context, key string, val []byte, redisTTL time.Duration, manual bool) {
+	if redisTTL == unknownTTL {
+		redisTTL, _ = m.logicalTTL(m.redisTTL(ctx, key))
+	}
+
+	m.pins.mu.Lock()
+	entry, ok := m.pins.entries[key]
+	if !ok && (m.config.MaxPinnedKeys <= 0 || len(m.pins.entries) < m.config.MaxPinnedKeys) {
+		entry = &pinnedEntry{}
+		entry.timer = time.AfterFunc(m.pinRefreshDelay(redisTTL), func() {
+			m.refreshPinned(key, entry)
+		})
+		m.pins.entries[key] = entry
+		m.logger.Infof("Pinned key in local cache: %s", key)
+	}
+	if entry != nil {
+		entry.manual = entry.manual || manual
+	}
+	m.pins.mu.Unlock()
+
+	// 直接写入本地缓存，key此前不在本地缓存中（如从Redis命中、手动固定）时同样生效；
+	// Redis中永不过期时使用本地缓存的默认过期时间
+	if err := m.local.Set(withEntrySource(ctx, sourceKeep), key, val, redisTTL); err != nil {
+		m.logger.Errorf("Local cache pin key error: %v", err)
+	}
+}
+
+// pinRefreshDelay 根据Redis中的剩余过期时间计算下一次刷新的延迟
+// 剩余时间不足PinRefreshLead时等到过期之后再刷新，届时key已不存在，会被取消固定
+func (m *MultiLevelCache) pinRefreshDelay(redisTTL time.Duration) time.Duration {
+	if redisTTL <= 0 {
+		// Redis中永不过期，按热点统计窗口定期检查是否仍是热点
+		if m.config.HotKeyWindow > 0 {
+			return m.config.HotKeyWindow
+		}
+		return time.Minute
+	}
+	if redisTTL > m.config.PinRefreshLead {
+		return redisTTL - m.config.PinRefreshLead
+	}
+	return redisTTL
+}
+
+// refreshPinned 刷新固定的key：配置了Loader时先重新加载并写回两级缓存，再从Redis读取最新的值和过期时间更新本地缓存
+// key不再是热点（手动固定的除外）或已从Redis中删除时取消固定
+func (m *MultiLevelCache) refreshPinned(key string, entry *pinnedEntry) {
+	m.pins.mu.Lock()
+	if current, ok := m.pins.entries[key]; !ok || current != entry {
+		m.pins.mu.Unlock()
+		return
+	}
+	if !entry.manual && (m.hotKeys == nil || !m.hotKeys.isHot(key)) {
+		delete(m.pins.entries, key)
+		m.pins.mu.Unlock()
+		m.logger.Infof("Unpinned key no longer hot: %s", key)
+		return
+	}
+	m.pins.mu.Unlock()
+
+	ctx := context.Background()
+	if m.loader != nil {
+		if _, err := m.load(ctx, key, m.config.RefreshExpiration, m.loader); err != nil && !errors.Is(err, ErrKeyNotFound) {
+			m.logger.Errorf("Failed to reload pinned key, key: %s, error: %v", key, err)
+		}
+	}
+
+	val, physical, err := m.redisGet(ctx, key)
+	if errors.Is(err, ErrKeyNotFound) || err == nil && isNullValue(val) {
+		m.Unpin(key)
+		return
+	}
+	delay := m.config.PinRefreshLead
+	if err != nil {
+		// Redis暂时不可用时保留本地副本，稍后重试
+		m.logger.Errorf("Failed to refresh pinned key, key: %s, error: %v", key, err)
+	} else {
+		redisTTL, _ := m.logicalTTL(physical)
+		m.extendLocalExpiration(ctx, key, val, redisTTL)
+		delay = m.pinRefreshDelay(redisTTL)
+	}
+
+	m.pins.mu.Lock()
+	if current, ok := m.pins.entries[key]; ok && current == entry {
+		entry.timer.Reset(delay)
+	}
+	m.pins.mu.Unlock()
+}
+
+// Pin 手动把key固定在本地缓存中，直到调用Unpin或key从Redis中删除
+// key不存在时返回 ErrKeyNotFound，缓存的是空值时返回 ErrCachedNotFound
+func (m *MultiLevelCache) Pin(ctx context.Context, key string) error {
+	val, physical, err := m.redisGet(ctx, key)
+	if err != nil {
+		return err
+	}
+	if isNullValue(val) {
+		return ErrCachedNotFound
+	}
+	redisTTL, _ := m.logicalTTL(physical)
+	m.pin(ctx, key, val, redisTTL, true)
+	return nil
+}
+
+// Unpin 取消固定key，本地副本保留到当前的过期时间
+func (m *MultiLevelCache) Unpin(key string) {
+	m.pins.mu.Lock()
+	defer m.pins.mu.Unlock()
+	if entry, ok := m.pins.entries[key]; ok {
+		entry.timer.Stop()
+		delete(m.pins.entries, key)
+	}
+}
+
+// unpinPrefix 取消固定以prefix开头的key
+func (m *MultiLevelCache) unpinPrefix(prefix string) {
+	m.pins.mu.Lock()
+	defer m.pins.mu.Unlock()
+	for key, entry := range m.pins.entries {
+		if strings.HasPrefix(key, prefix) {
+			entry.timer.Stop()
+			delete(m.pins.entries, key)
+		}
+	}
+}
+
+// PinnedKeys 返回当前固定在本地缓存中的key，按key排序
+func (m *MultiLevelCache) PinnedKeys() []PinnedKey {
+	m.pins.mu.Lock()
+	defer m.pins.mu.Unlock()
+	keys := make([]PinnedKey, 0, len(m.pins.entries))
+	for key, entry := range m.pins.entries {
+		keys = append(keys, PinnedKey{Key: key, Manual: entry.manual})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
+
+// unpinAll 取消所有固定的key，在关闭时调用
+func (m *MultiLevelCache) unpinAll() {
+	m.pins.mu.Lock()
+	defer m.pins.mu.Unlock()
+	for key, entry := range m.pins.entries {
+		entry.timer.Stop()
+		delete(m.pins.entries, key)
+	}
+}
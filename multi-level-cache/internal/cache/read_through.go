@@ -0,0 +1,24 @@
+package cache
+
+import "context"
+
+// readThroughKey context中强一致读标记的键
+type readThroughKey struct{}
+
+// readThroughMode 强一致读的选项
+type readThroughMode struct {
+	refreshLocal bool
+}
+
+// WithReadThrough 返回带有强一致读标记的context，使用该context的Get、GetOrLoad、MGet和GetMulti跳过本地缓存，直接读取Redis。
+// 适用于刚在其他实例上写入、需要读到最新值的调用方，不需要为此全局关闭本地缓存。
+// refreshLocal为true时用读到的结果更新本地缓存（Redis中不存在时删除本地副本），为false时不修改本地缓存
+func WithReadThrough(ctx context.Context, refreshLocal bool) context.Context {
+	return context.WithValue(ctx, readThroughKey{}, readThroughMode{refreshLocal: refreshLocal})
+}
+
+// readThroughFrom 返回context是否带有强一致读标记，以及是否需要更新本地缓存
+func readThroughFrom(ctx context.Context) (readThrough, refreshLocal bool) {
+	mode, ok := ctx.Value(readThroughKey{}).(readThroughMode)
+	return ok, mode.refreshLocal
+}
@@ -3,67 +3,133 @@ package cache
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"multi-level-cache/internal/config"
 	"multi-level-cache/pkg/utils"
+
+	"redis-learning/pkg/redisclient"
 )
 
 // RedisCache 实现基于Redis的缓存
 type RedisCache struct {
 	name              string
-	client            *redis.Client
+	client            redis.UniversalClient
 	defaultExpiration time.Duration
+	// key的命名空间前缀，为空表示不加前缀
+	namespace string
+	// 单次操作的超时时间，为0表示只受调用方ctx的限制
+	opTimeout time.Duration
+	logger    utils.Logger
+	// embedded模式下进程内的Redis，关闭缓存时一起关闭
+	embedded *miniredis.Miniredis
 }
 
 // NewRedisCache 创建一个新的Redis缓存实例
-func NewRedisCache(cfg *config.RedisConfig, opts ...Options) (*RedisCache, error) {
-	options := Options{
-		Name:              "redis_cache",
-		DefaultExpiration: 5 * time.Minute,
-	}
-	if len(opts) > 0 {
-		options = opts[0]
-	}
+func NewRedisCache(cfg *config.RedisConfig, opts ...Option) (*RedisCache, error) {
+	options := newOptions("redis_cache", 5*time.Minute, opts)
 	if cfg == nil {
 		return nil, ErrCacheInternal
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:         cfg.Addr,
-		Password:     cfg.Password,
-		DB:           cfg.DB,
-		PoolSize:     cfg.PoolSize,
-		DialTimeout:  cfg.DialTimeout,
-		ReadTimeout:  cfg.ReadTimeout,
-		WriteTimeout: cfg.WriteTimeout,
-	})
-	utils.LogInfo("Redis cache initialized: %s at %s", options.Name, cfg.Addr)
+	var embedded *miniredis.Miniredis
+	namespace := cfg.Namespace
+	if cfg.Mode == config.RedisModeEmbedded {
+		var err error
+		embedded, cfg, err = startEmbeddedRedis(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, addr, err := newRedisClient(cfg, options.redisHooks)
+	if err != nil {
+		if embedded != nil {
+			embedded.Close()
+		}
+		return nil, err
+	}
+	if embedded != nil {
+		addr = "embedded " + addr
+	}
+	options.logger.Infof("Redis cache initialized: %s at %s", options.name, addr)
 	return &RedisCache{
-		name:              options.Name,
+		name:              options.name,
 		client:            client,
-		defaultExpiration: options.DefaultExpiration,
+		defaultExpiration: options.defaultExpiration,
+		namespace:         namespace,
+		opTimeout:         cfg.OperationTimeout,
+		logger:            options.logger,
+		embedded:          embedded,
 	}, nil
 }
 
+// newRedisClient 根据部署模式创建对应的客户端并添加hooks，同时返回用于日志的地址描述
+func newRedisClient(cfg *config.RedisConfig, hooks []redis.Hook) (redis.UniversalClient, string, error) {
+	client, err := redisclient.New(cfg.RedisOptions, hooks...)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, cfg.Describe(), nil
+}
+
 // Get 从Redis获取缓存值
 func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
 	if key == "" {
 		return nil, ErrInvalidKey
 	}
-	val, err := r.client.Get(ctx, key).Bytes()
+	val, err := r.client.Get(ctx, r.key(key)).Bytes()
 	if errors.Is(err, redis.Nil) {
 		return nil, ErrKeyNotFound
 	}
 	if err != nil {
-		utils.LogError("Redis GET error: %v", err)
-		return nil, ErrCacheInternal
+		r.logger.Errorf("Redis GET error: %v", err)
+		return nil, redisError(err)
 	}
 	return val, nil
 }
 
+// GetWithTTL 通过一个管道执行GET和PTTL，同时返回值和剩余过期时间
+func (r *RedisCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if key == "" {
+		return nil, 0, ErrInvalidKey
+	}
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, r.key(key))
+		pttl = pipe.PTTL(ctx, r.key(key))
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, 0, ErrKeyNotFound
+	}
+	if err != nil {
+		r.logger.Errorf("Redis GET pipeline error: %v", err)
+		return nil, 0, redisError(err)
+	}
+	val, err := get.Bytes()
+	if err != nil {
+		return nil, 0, redisError(err)
+	}
+	// 不存在（GET之后恰好过期）或永不过期时返回0，与TTL一致
+	return val, max(pttl.Val(), 0), nil
+}
+
 // Set 设置Redis缓存值
 func (r *RedisCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
 	if key == "" {
 		return ErrInvalidKey
 	}
@@ -73,46 +139,497 @@ func (r *RedisCache) Set(ctx context.Context, key string, value []byte, expirati
 	if expiration <= 0 {
 		expiration = r.defaultExpiration
 	}
-	err := r.client.Set(ctx, key, value, expiration).Err()
+	err := r.client.Set(ctx, r.key(key), value, expiration).Err()
 	if err != nil {
-		utils.LogError("Redis SET error: %v", err)
-		return ErrCacheInternal
+		r.logger.Errorf("Redis SET error: %v", err)
+		return redisError(err)
 	}
 	return nil
 }
 
 // Delete 删除Redis缓存
 func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
 	if key == "" {
 		return ErrInvalidKey
 	}
-	err := r.client.Del(ctx, key).Err()
+	err := r.client.Del(ctx, r.key(key)).Err()
 	if err != nil {
-		utils.LogError("Redis DEL error: %v", err)
-		return ErrCacheInternal
+		r.logger.Errorf("Redis DEL error: %v", err)
+		return redisError(err)
 	}
 	return nil
 }
 
 // Exists 检查Redis中是否存在指定key
 func (r *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
 	if key == "" {
 		return false, ErrInvalidKey
 	}
-	res, err := r.client.Exists(ctx, key).Result()
+	res, err := r.client.Exists(ctx, r.key(key)).Result()
 	if err != nil {
-		utils.LogError("Redis EXISTS error: %v", err)
-		return false, ErrCacheInternal
+		r.logger.Errorf("Redis EXISTS error: %v", err)
+		return false, redisError(err)
 	}
 	return res > 0, nil
 }
 
+// TTL 通过PTTL命令返回Redis中key的剩余过期时间（毫秒精度），key不存在时返回 ErrKeyNotFound，永不过期时返回0
+func (r *RedisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if key == "" {
+		return 0, ErrInvalidKey
+	}
+	ttl, err := r.client.PTTL(ctx, r.key(key)).Result()
+	if err != nil {
+		r.logger.Errorf("Redis PTTL error: %v", err)
+		return 0, redisError(err)
+	}
+	// go-redis对不存在的key返回-2，对未设置过期时间的key返回-1
+	switch ttl {
+	case -2:
+		return 0, ErrKeyNotFound
+	case -1:
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// MTTL 通过管道批量执行PTTL，不存在或永不过期的key不包含在结果中
+func (r *RedisCache) MTTL(ctx context.Context, keys []string) (map[string]time.Duration, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	cmds := make([]*redis.DurationCmd, len(keys))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.PTTL(ctx, r.key(key))
+		}
+		return nil
+	})
+	if err != nil {
+		r.logger.Errorf("Redis PTTL pipeline error: %v", err)
+		return nil, redisError(err)
+	}
+
+	ttls := make(map[string]time.Duration, len(keys))
+	for i, cmd := range cmds {
+		if ttl := cmd.Val(); ttl > 0 {
+			ttls[keys[i]] = ttl
+		}
+	}
+	return ttls, nil
+}
+
+// MGet 通过一次MGET批量获取缓存值
+func (r *RedisCache) MGet(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	for _, key := range keys {
+		if key == "" {
+			return nil, nil, ErrInvalidKey
+		}
+	}
+	if len(keys) == 0 {
+		return map[string][]byte{}, nil, nil
+	}
+
+	namespaced := make([]string, len(keys))
+	for i, key := range keys {
+		namespaced[i] = r.key(key)
+	}
+	vals, err := r.mget(ctx, namespaced)
+	if err != nil {
+		r.logger.Errorf("Redis MGET error: %v", err)
+		return nil, nil, redisError(err)
+	}
+
+	found := make(map[string][]byte, len(keys))
+	var missing []string
+	for i, val := range vals {
+		str, ok := val.(string)
+		if !ok {
+			missing = append(missing, keys[i])
+			continue
+		}
+		found[keys[i]] = []byte(str)
+	}
+	return found, missing, nil
+}
+
+// mget 执行批量读取，集群模式下MGET的key可能分布在不同的槽位，改为通过管道逐个GET
+func (r *RedisCache) mget(ctx context.Context, keys []string) ([]interface{}, error) {
+	if _, ok := r.client.(*redis.ClusterClient); !ok {
+		return r.client.MGet(ctx, keys...).Result()
+	}
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	vals := make([]interface{}, len(keys))
+	for i, cmd := range cmds {
+		if val, err := cmd.Result(); err == nil {
+			vals[i] = val
+		}
+	}
+	return vals, nil
+}
+
+// MSet 批量设置缓存值，MSET不支持过期时间，因此通过管道为每个key执行SET
+func (r *RedisCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	for key, value := range items {
+		if key == "" {
+			return ErrInvalidKey
+		}
+		if value == nil {
+			return ErrInvalidValue
+		}
+	}
+	if expiration <= 0 {
+		expiration = r.defaultExpiration
+	}
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range items {
+			pipe.Set(ctx, r.key(key), value, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		r.logger.Errorf("Redis MSET pipeline error: %v", err)
+		return redisError(err)
+	}
+	return nil
+}
+
+// ClearNamespace 通过SCAN遍历并删除当前命名空间下的所有key，返回删除的数量
+// 未设置命名空间时返回 ErrNamespaceNotSet，避免误删整个数据库
+func (r *RedisCache) ClearNamespace(ctx context.Context) (int64, error) {
+	if r.namespace == "" {
+		return 0, ErrNamespaceNotSet
+	}
+	return r.unlinkPrefix(ctx, namespacePrefix(r.namespace))
+}
+
+// DeleteByPrefix 通过SCAN分批遍历并UNLINK当前命名空间下以prefix开头的key，返回删除的数量
+// prefix为空时返回 ErrInvalidKey，清空整个命名空间应使用 ClearNamespace
+func (r *RedisCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	if prefix == "" {
+		return 0, ErrInvalidKey
+	}
+	return r.unlinkPrefix(ctx, r.key(prefix))
+}
+
+// unlinkPrefix 删除以rawPrefix（已包含命名空间前缀）开头的所有key
+func (r *RedisCache) unlinkPrefix(ctx context.Context, rawPrefix string) (int64, error) {
+	if r.embedded == nil {
+		return r.unlinkMatching(ctx, escapeGlob(rawPrefix)+"*")
+	}
+	// miniredis的SCAN游标是有序key列表的下标，遍历中删除key会跳过后面的key，因此重复遍历直到没有可删除的key
+	var total int64
+	for {
+		removed, err := r.unlinkMatching(ctx, escapeGlob(rawPrefix)+"*")
+		total += removed
+		if err != nil || removed == 0 {
+			return total, err
+		}
+	}
+}
+
+// unlinkMatching 遍历一次匹配pattern的key并分批UNLINK，返回删除的数量
+func (r *RedisCache) unlinkMatching(ctx context.Context, pattern string) (int64, error) {
+	var removed atomic.Int64
+	err := r.scanRaw(ctx, pattern, func(keys []string) error {
+		// 同一批key在集群中可能属于不同槽位，因此通过管道逐个UNLINK
+		opCtx, cancel := r.opContext(ctx)
+		defer cancel()
+		cmds, err := r.client.Pipelined(opCtx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Unlink(opCtx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			r.logger.Errorf("Redis UNLINK error: %v", err)
+			return redisError(err)
+		}
+		for _, cmd := range cmds {
+			removed.Add(cmd.(*redis.IntCmd).Val())
+		}
+		return nil
+	})
+	return removed.Load(), err
+}
+
+// Keys 返回匹配pattern（Redis通配符语法）的所有key，不包含命名空间前缀
+// key数量很多时应使用 Scan 逐个处理
+func (r *RedisCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	var mu sync.Mutex
+	err := r.Scan(ctx, pattern, func(key string) bool {
+		mu.Lock()
+		keys = append(keys, key)
+		mu.Unlock()
+		return true
+	})
+	return keys, err
+}
+
+// Scan 通过SCAN遍历匹配pattern的key并依次调用fn，fn返回false时停止遍历
+// 集群模式下各主节点并发遍历，fn可能被并发调用
+func (r *RedisCache) Scan(ctx context.Context, pattern string, fn func(key string) bool) error {
+	prefix := namespacePrefix(r.namespace)
+	err := r.scanRaw(ctx, escapeGlob(prefix)+pattern, func(keys []string) error {
+		for _, key := range keys {
+			if !fn(strings.TrimPrefix(key, prefix)) {
+				return errStopScan
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errStopScan) {
+		return nil
+	}
+	return err
+}
+
+// errStopScan 用于提前结束遍历
+var errStopScan = errors.New("stop scan")
+
+// scanRaw 通过SCAN按批遍历匹配的原始key，集群模式下会遍历每个主节点
+func (r *RedisCache) scanRaw(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return r.scanNode(ctx, r.client, pattern, fn)
+	}
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		return r.scanNode(ctx, node, pattern, fn)
+	})
+}
+
+// scanNode 在单个节点上通过SCAN遍历匹配的key
+func (r *RedisCache) scanNode(ctx context.Context, client redis.Cmdable, pattern string, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		opCtx, cancel := r.opContext(ctx)
+		keys, next, err := client.Scan(opCtx, cursor, pattern, 500).Result()
+		cancel()
+		if err != nil {
+			r.logger.Errorf("Redis SCAN error: %v", err)
+			return redisError(err)
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// SetNX 通过SET NX仅在key不存在时设置值
+func (r *RedisCache) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if key == "" {
+		return false, ErrInvalidKey
+	}
+	if value == nil {
+		return false, ErrInvalidValue
+	}
+	if expiration <= 0 {
+		expiration = r.defaultExpiration
+	}
+	ok, err := r.client.SetNX(ctx, r.key(key), value, expiration).Result()
+	if err != nil {
+		r.logger.Errorf("Redis SETNX error: %v", err)
+		return false, redisError(err)
+	}
+	return ok, nil
+}
+
+// GetSet 通过SET GET设置新值并返回旧值，同时设置过期时间
+func (r *RedisCache) GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) ([]byte, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if key == "" {
+		return nil, ErrInvalidKey
+	}
+	if value == nil {
+		return nil, ErrInvalidValue
+	}
+	if expiration <= 0 {
+		expiration = r.defaultExpiration
+	}
+	old, err := r.client.SetArgs(ctx, r.key(key), value, redis.SetArgs{Get: true, TTL: expiration}).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Errorf("Redis SET GET error: %v", err)
+		return nil, redisError(err)
+	}
+	return old, nil
+}
+
+// casScript 当前值等于ARGV[1]时设置为ARGV[2]，比较和写入在脚本中原子执行
+var casScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// CompareAndSwap 通过Lua脚本原子地比较并替换值
+func (r *RedisCache) CompareAndSwap(ctx context.Context, key string, old, new []byte, expiration time.Duration) (bool, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if key == "" {
+		return false, ErrInvalidKey
+	}
+	if new == nil {
+		return false, ErrInvalidValue
+	}
+	if expiration <= 0 {
+		expiration = r.defaultExpiration
+	}
+	swapped, err := casScript.Run(ctx, r.client, []string{r.key(key)}, old, new, expiration.Milliseconds()).Int()
+	if err != nil {
+		r.logger.Errorf("Redis CAS error: %v", err)
+		return false, redisError(err)
+	}
+	return swapped == 1, nil
+}
+
+// incrByScript 原子地增加计数，新建的计数器（没有过期时间）设置默认过期时间
+var incrByScript = redis.NewScript(`
+local value = redis.call("INCRBY", KEYS[1], ARGV[1])
+if redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return value
+`)
+
+// IncrBy 通过INCRBY原子地增加计数并返回增加后的值，key中保存的不是整数时返回 ErrInvalidValue
+func (r *RedisCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if key == "" {
+		return 0, ErrInvalidKey
+	}
+	val, err := incrByScript.Run(ctx, r.client, []string{r.key(key)}, delta, r.defaultExpiration.Milliseconds()).Int64()
+	if err != nil {
+		if strings.Contains(err.Error(), "not an integer") {
+			return 0, ErrInvalidValue
+		}
+		r.logger.Errorf("Redis INCRBY error: %v", err)
+		return 0, redisError(err)
+	}
+	return val, nil
+}
+
+// unlockScript 只有锁的值与token一致时才删除，避免误删其他持有者的锁
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// TryLock 通过SET NX PX尝试获取锁
+func (r *RedisCache) TryLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if key == "" {
+		return false, ErrInvalidKey
+	}
+	ok, err := r.client.SetNX(ctx, r.key(key), token, ttl).Result()
+	if err != nil {
+		r.logger.Errorf("Redis SETNX error: %v", err)
+		return false, redisError(err)
+	}
+	return ok, nil
+}
+
+// Unlock 释放锁
+func (r *RedisCache) Unlock(ctx context.Context, key, token string) error {
+	ctx, cancel := r.opContext(ctx)
+	defer cancel()
+
+	if key == "" {
+		return ErrInvalidKey
+	}
+	if err := unlockScript.Run(ctx, r.client, []string{r.key(key)}, token).Err(); err != nil {
+		r.logger.Errorf("Redis unlock error: %v", err)
+		return redisError(err)
+	}
+	return nil
+}
+
+// opContext 为单次Redis操作设置超时，调用方的ctx截止时间更早时以调用方为准
+func (r *RedisCache) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.opTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.opTimeout)
+}
+
+// redisError 把Redis操作的错误转换为缓存错误：超时或被取消时返回对应的ctx错误，便于调用方区分，其余返回 ErrCacheInternal
+func redisError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return ErrCacheInternal
+}
+
+// key 返回加上命名空间前缀后的key
+func (r *RedisCache) key(key string) string {
+	return namespacePrefix(r.namespace) + key
+}
+
+// Client 返回底层的Redis客户端，供布隆过滤器等组件复用连接
+func (r *RedisCache) Client() redis.UniversalClient {
+	return r.client
+}
+
 // Name 返回缓存名称
 func (r *RedisCache) Name() string {
 	return r.name
 }
 
-// Close 关闭Redis连接
+// Close 关闭Redis连接，embedded模式下同时关闭进程内的Redis
 func (r *RedisCache) Close() error {
-	return r.client.Close()
+	err := r.client.Close()
+	if r.embedded != nil {
+		r.embedded.Close()
+	}
+	return err
 }
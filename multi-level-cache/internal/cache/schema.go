@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"multi-level-cache/pkg/utils"
+)
+
+// envelopeMagic 版本信封的前缀，没有该前缀的值视为启用信封之前写入的旧值
+var envelopeMagic = []byte("\x00mlcv")
+
+// SchemaVersion 值的编码格式和结构版本，由应用定义，例如 {Codec: "json", Version: 2}
+type SchemaVersion struct {
+	// 值的编码格式，如json、msgpack、protobuf，最长255字节
+	Codec string
+	// 值的结构版本，结构变化时递增
+	Version uint32
+}
+
+func (v SchemaVersion) String() string {
+	return fmt.Sprintf("%s/v%d", v.Codec, v.Version)
+}
+
+// MigrateFunc 读取到格式或版本与当前不同的值时调用，把value从from转换为当前的格式和版本
+// 启用信封之前写入的值from为零值；返回 ErrKeyNotFound 表示丢弃旧值，按未命中处理
+type MigrateFunc func(key string, from SchemaVersion, value []byte) ([]byte, error)
+
+// SchemaVersionError 值的格式或版本与当前不同且无法迁移时返回，errors.Is(err, ErrKeyNotFound)为true，
+// 读取时按未命中处理，由加载函数用当前版本重新写入
+type SchemaVersionError struct {
+	Key     string
+	Stored  SchemaVersion
+	Current SchemaVersion
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("value of key %s has schema %s, current schema is %s", e.Key, e.Stored, e.Current)
+}
+
+// Unwrap 返回 ErrKeyNotFound
+func (e *SchemaVersionError) Unwrap() error {
+	return ErrKeyNotFound
+}
+
+// newSchemaCache 创建版本信封包装：写入时在值前面加上编码格式和结构版本，
+// 读取到旧版本的值时调用migrate转换，migrate为nil或值的版本比当前新时返回 SchemaVersionError
+func newSchemaCache(c Cache, current SchemaVersion, migrate MigrateFunc, logger utils.Logger) *codecCache {
+	if len(current.Codec) > 255 {
+		current.Codec = current.Codec[:255]
+	}
+	header := make([]byte, 0, len(envelopeMagic)+1+len(current.Codec)+binary.MaxVarintLen32)
+	header = append(header, envelopeMagic...)
+	header = append(header, byte(len(current.Codec)))
+	header = append(header, current.Codec...)
+	header = binary.AppendUvarint(header, uint64(current.Version))
+
+	return &codecCache{
+		Cache:    c,
+		encodeOp: "wrap",
+		decodeOp: "unwrap",
+		encode: func(_ string, value []byte) ([]byte, error) {
+			data := make([]byte, 0, len(header)+len(value))
+			data = append(data, header...)
+			return append(data, value...), nil
+		},
+		decode: func(key string, data []byte) ([]byte, error) {
+			stored, value, err := parseEnvelope(data)
+			if err != nil {
+				return nil, err
+			}
+			if stored == current || isNullValue(value) {
+				return value, nil
+			}
+			// 比当前新的版本来自滚动发布中已升级的实例，旧代码无法理解，按未命中处理
+			if migrate == nil || stored.Codec == current.Codec && stored.Version > current.Version {
+				return nil, &SchemaVersionError{Key: key, Stored: stored, Current: current}
+			}
+			migrated, err := migrate(key, stored, value)
+			if err != nil {
+				if errors.Is(err, ErrKeyNotFound) {
+					return nil, &SchemaVersionError{Key: key, Stored: stored, Current: current}
+				}
+				return nil, fmt.Errorf("failed to migrate from %s: %w", stored, err)
+			}
+			return migrated, nil
+		},
+		logger: logger,
+	}
+}
+
+// parseEnvelope 解析版本信封，返回值的格式、版本和原始值，没有信封的旧值返回零值版本
+func parseEnvelope(data []byte) (SchemaVersion, []byte, error) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return SchemaVersion{}, data, nil
+	}
+	rest := data[len(envelopeMagic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return SchemaVersion{}, nil, errors.New("malformed value envelope")
+	}
+	codec := string(rest[1 : 1+rest[0]])
+	rest = rest[1+rest[0]:]
+	version, n := binary.Uvarint(rest)
+	if n <= 0 || version > 1<<32-1 {
+		return SchemaVersion{}, nil, errors.New("malformed value envelope")
+	}
+	return SchemaVersion{Codec: codec, Version: uint32(version)}, rest[n:], nil
+}
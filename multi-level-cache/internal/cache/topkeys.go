@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// count-min sketch的大小：4行、每行4096个计数器，共64KB，估计误差约为总访问次数的0.07%
+const (
+	sketchDepth = 4
+	sketchWidth = 4096
+)
+
+// KeyCount key及其估计的访问次数
+type KeyCount struct {
+	Key   string
+	Count int64
+}
+
+// countMinSketch 以固定的内存估计每个key的访问次数，估计值只会偏大不会偏小
+type countMinSketch struct {
+	rows [sketchDepth][sketchWidth]uint32
+}
+
+// add 把key的计数加一，返回加一后的估计值
+func (s *countMinSketch) add(key string) int64 {
+	// FNV-1a，内联计算避免每次访问分配哈希对象
+	sum := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		sum ^= uint64(key[i])
+		sum *= 1099511628211
+	}
+	// 由一个64位哈希值派生出各行的下标（Kirsch-Mitzenmacher）
+	h1, h2 := uint32(sum), uint32(sum>>32)
+	estimate := uint32(0)
+	for i := range s.rows {
+		idx := (h1 + uint32(i)*h2) % sketchWidth
+		if s.rows[i][idx] < ^uint32(0) {
+			s.rows[i][idx]++
+		}
+		if i == 0 || s.rows[i][idx] < estimate {
+			estimate = s.rows[i][idx]
+		}
+	}
+	return int64(estimate)
+}
+
+// halve 所有计数减半，使统计偏向最近的访问
+func (s *countMinSketch) halve() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+}
+
+// keyHeap 按访问次数排序的最小堆，index记录每个key在堆中的位置
+type keyHeap struct {
+	items []KeyCount
+	index map[string]int
+}
+
+func (h *keyHeap) Len() int           { return len(h.items) }
+func (h *keyHeap) Less(i, j int) bool { return h.items[i].Count < h.items[j].Count }
+func (h *keyHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i].Key] = i
+	h.index[h.items[j].Key] = j
+}
+func (h *keyHeap) Push(x any) {
+	item := x.(KeyCount)
+	h.index[item.Key] = len(h.items)
+	h.items = append(h.items, item)
+}
+func (h *keyHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, item.Key)
+	return item
+}
+
+// keyTracker 统计一类访问（命中或未命中）中访问次数最多的key：
+// count-min sketch估计所有key的访问次数，最小堆只保留估计值最大的capacity个key，内存占用与key的数量无关
+type keyTracker struct {
+	mu          sync.Mutex
+	sketch      countMinSketch
+	top         keyHeap
+	capacity    int
+	decay       time.Duration
+	windowStart time.Time
+}
+
+// newKeyTracker 创建访问统计，每经过decay所有计数减半，decay为0时不衰减
+func newKeyTracker(capacity int, decay time.Duration) *keyTracker {
+	return &keyTracker{
+		top:         keyHeap{index: make(map[string]int)},
+		capacity:    capacity,
+		decay:       decay,
+		windowStart: time.Now(),
+	}
+}
+
+// record 记录一次访问
+func (t *keyTracker) record(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.decayLocked()
+	count := t.sketch.add(key)
+	if i, ok := t.top.index[key]; ok {
+		t.top.items[i].Count = count
+		heap.Fix(&t.top, i)
+		return
+	}
+	if t.top.Len() < t.capacity {
+		heap.Push(&t.top, KeyCount{Key: key, Count: count})
+		return
+	}
+	if t.top.Len() > 0 && count > t.top.items[0].Count {
+		delete(t.top.index, t.top.items[0].Key)
+		t.top.items[0] = KeyCount{Key: key, Count: count}
+		t.top.index[key] = 0
+		heap.Fix(&t.top, 0)
+	}
+}
+
+// topN 返回访问次数最多的n个key，按访问次数从高到低排序，n不大于0时返回全部
+func (t *keyTracker) topN(n int) []KeyCount {
+	t.mu.Lock()
+	t.decayLocked()
+	result := make([]KeyCount, len(t.top.items))
+	copy(result, t.top.items)
+	t.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// decayLocked 每经过一个衰减周期把所有计数减半，调用方需持有锁
+// 堆中的计数同时减半，相对顺序不变，不需要重新调整堆
+func (t *keyTracker) decayLocked() {
+	if t.decay <= 0 {
+		return
+	}
+	periods := int64(time.Since(t.windowStart) / t.decay)
+	if periods == 0 {
+		return
+	}
+	t.windowStart = t.windowStart.Add(time.Duration(periods) * t.decay)
+	// 计数是32位的，减半32次后全部为0
+	for i := int64(0); i < min(periods, 32); i++ {
+		t.sketch.halve()
+		for j := range t.top.items {
+			t.top.items[j].Count >>= 1
+		}
+	}
+}
+
+// TopHitKeys 返回命中次数最多的n个key及其估计的命中次数，可用于决定哪些key值得更长的过期时间
+// 未启用EnableKeyStats时返回nil
+func (m *MultiLevelCache) TopHitKeys(n int) []KeyCount {
+	if m.hitKeys == nil {
+		return nil
+	}
+	return m.hitKeys.topN(n)
+}
+
+// TopMissedKeys 返回未命中次数最多的n个key及其估计的未命中次数，可用于决定哪些key需要预热
+// 未启用EnableKeyStats时返回nil
+func (m *MultiLevelCache) TopMissedKeys(n int) []KeyCount {
+	if m.missedKeys == nil {
+		return nil
+	}
+	return m.missedKeys.topN(n)
+}
+
+// recordHit 记录一次命中
+func (m *MultiLevelCache) recordHit(key string) {
+	m.metrics.IncHit()
+	if m.hitKeys != nil {
+		m.hitKeys.record(key)
+	}
+}
+
+// recordMiss 记录一次未命中
+func (m *MultiLevelCache) recordMiss(key string) {
+	m.metrics.IncMiss()
+	if m.missedKeys != nil {
+		m.missedKeys.record(key)
+	}
+}
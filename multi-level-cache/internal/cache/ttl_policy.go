@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"math/rand"
+	"time"
+
+	"multi-level-cache/internal/config"
+)
+
+// ttlPolicy 返回key匹配的第一个过期策略，没有匹配时返回nil
+func (m *MultiLevelCache) ttlPolicy(key string) *config.TTLPolicy {
+	for i := range m.config.TTLPolicies {
+		if matchPattern(m.config.TTLPolicies[i].Pattern, key) {
+			return &m.config.TTLPolicies[i]
+		}
+	}
+	return nil
+}
+
+// resolveExpiration 调用方未指定过期时间时按key匹配的过期策略确定过期时间，
+// 没有匹配的策略时仍返回0，由Redis缓存使用默认过期时间
+func (m *MultiLevelCache) resolveExpiration(key string, expiration time.Duration) time.Duration {
+	if expiration > 0 {
+		return expiration
+	}
+	policy := m.ttlPolicy(key)
+	if policy == nil || policy.Expiration <= 0 {
+		return expiration
+	}
+	return policy.Expiration + jitter(policy.Jitter)
+}
+
+// jitter 返回[0, max)内的随机时长
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// groupByExpiration 按过期策略对批量写入分组，同一个策略的key使用相同的过期时间（抖动只计算一次），
+// 这样每组可以一次写入Redis
+func (m *MultiLevelCache) groupByExpiration(items map[string][]byte, expiration time.Duration) map[time.Duration]map[string][]byte {
+	resolved := make(map[*config.TTLPolicy]time.Duration)
+	groups := make(map[time.Duration]map[string][]byte)
+	for key, value := range items {
+		exp := expiration
+		if policy := m.ttlPolicy(key); policy != nil && policy.Expiration > 0 {
+			var ok bool
+			if exp, ok = resolved[policy]; !ok {
+				exp = policy.Expiration + jitter(policy.Jitter)
+				resolved[policy] = exp
+			}
+		}
+		group, ok := groups[exp]
+		if !ok {
+			group = make(map[string][]byte)
+			groups[exp] = group
+		}
+		group[key] = value
+	}
+	return groups
+}
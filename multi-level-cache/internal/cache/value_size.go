@@ -0,0 +1,395 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-level-cache/internal/config"
+)
+
+// ValueTooLargeError 值超过MaxValueSize且策略为拒绝时返回，可以通过errors.Is判断是否为 ErrValueTooLarge
+type ValueTooLargeError struct {
+	Key  string
+	Size int
+	Max  int
+}
+
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("value of key %s is %d bytes, exceeds max value size %d", e.Key, e.Size, e.Max)
+}
+
+// Unwrap 返回 ErrValueTooLarge
+func (e *ValueTooLargeError) Unwrap() error {
+	return ErrValueTooLarge
+}
+
+// sizeLimitedCache 包装本地缓存，超过maxSize的值不写入本地缓存，同时删除旧值以免读到过期数据
+type sizeLimitedCache struct {
+	Cache
+	maxSize int
+}
+
+// newSizeLimitedCache 创建限制单个值大小的缓存包装
+func newSizeLimitedCache(c Cache, maxSize int) *sizeLimitedCache {
+	return &sizeLimitedCache{Cache: c, maxSize: maxSize}
+}
+
+func (c *sizeLimitedCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	if len(value) > c.maxSize {
+		return c.Cache.Delete(ctx, key)
+	}
+	return c.Cache.Set(ctx, key, value, expiration)
+}
+
+func (c *sizeLimitedCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	fit := make(map[string][]byte, len(items))
+	for key, value := range items {
+		if len(value) > c.maxSize {
+			if err := c.Cache.Delete(ctx, key); err != nil {
+				return err
+			}
+			continue
+		}
+		fit[key] = value
+	}
+	return c.Cache.MSet(ctx, fit, expiration)
+}
+
+func (c *sizeLimitedCache) SetNX(ctx context.Context, key string, value []byte, expiration time.Duration) (bool, error) {
+	if len(value) > c.maxSize {
+		return false, nil
+	}
+	return c.Cache.SetNX(ctx, key, value, expiration)
+}
+
+func (c *sizeLimitedCache) GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) ([]byte, error) {
+	if len(value) > c.maxSize {
+		old, err := c.Cache.Get(ctx, key)
+		if err != nil {
+			return nil, nil
+		}
+		return old, c.Cache.Delete(ctx, key)
+	}
+	return c.Cache.GetSet(ctx, key, value, expiration)
+}
+
+func (c *sizeLimitedCache) CompareAndSwap(ctx context.Context, key string, old, new []byte, expiration time.Duration) (bool, error) {
+	if len(new) > c.maxSize {
+		return false, c.Cache.Delete(ctx, key)
+	}
+	return c.Cache.CompareAndSwap(ctx, key, old, new, expiration)
+}
+
+// TTL 转发到底层缓存，仅在底层缓存实现了 TTLReader 时使用
+func (c *sizeLimitedCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.Cache.(TTLReader).TTL(ctx, key)
+}
+
+// MTTL 转发到底层缓存，仅在底层缓存实现了 TTLReader 时使用
+func (c *sizeLimitedCache) MTTL(ctx context.Context, keys []string) (map[string]time.Duration, error) {
+	return c.Cache.(TTLReader).MTTL(ctx, keys)
+}
+
+// chunkManifestPrefix 分块存储时写在原key上的清单前缀，清单格式为 前缀 + 写入ID:块数:总字节数
+var chunkManifestPrefix = []byte("\x00mlc:chunks\x00")
+
+// chunkKeyMarker 分块key中的标记，分块key为 原key + 标记 + 写入ID:序号，遍历key时会被过滤
+const chunkKeyMarker = "\x00chunk:"
+
+// chunkTTLMargin 分块比清单多保留的时间，避免读到清单时分块已经过期
+const chunkTTLMargin = 10 * time.Second
+
+// chunkedCache 包装Redis缓存，超过maxSize的值按chunkSize拆成多个分块写入，原key上只保存清单，读取时再拼接
+// 每次写入使用新的写入ID，并发写入同一个key时不会读到不同版本拼在一起的值；
+// 覆盖或删除key时同时删除旧版本的分块，不依赖分块的过期时间（Redis中永不过期时分块也不会过期）
+type chunkedCache struct {
+	Cache
+	maxSize   int
+	chunkSize int
+}
+
+// newChunkedCache 创建分块存储的缓存包装，chunkSize不大于0时使用maxSize
+func newChunkedCache(c Cache, maxSize, chunkSize int) *chunkedCache {
+	if chunkSize <= 0 {
+		chunkSize = maxSize
+	}
+	return &chunkedCache{Cache: c, maxSize: maxSize, chunkSize: chunkSize}
+}
+
+// chunkManifest 分块清单
+type chunkManifest struct {
+	id    string
+	count int
+	size  int
+}
+
+// parseChunkManifest 解析分块清单，值不是清单时返回false
+func parseChunkManifest(val []byte) (chunkManifest, bool) {
+	if !isChunkManifest(val) {
+		return chunkManifest{}, false
+	}
+	parts := strings.Split(string(val[len(chunkManifestPrefix):]), ":")
+	if len(parts) != 3 {
+		return chunkManifest{}, false
+	}
+	count, err1 := strconv.Atoi(parts[1])
+	size, err2 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil {
+		return chunkManifest{}, false
+	}
+	return chunkManifest{id: parts[0], count: count, size: size}, true
+}
+
+// isChunkManifest 判断值是否为分块清单
+func isChunkManifest(val []byte) bool {
+	return bytes.HasPrefix(val, chunkManifestPrefix)
+}
+
+// chunkKeys 返回清单对应的所有分块key
+func (m chunkManifest) chunkKeys(key string) []string {
+	keys := make([]string, m.count)
+	for i := range keys {
+		keys[i] = key + chunkKeyMarker + m.id + ":" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+// setChunked 先写入所有分块，再写入清单，读取方看到清单时分块一定已经存在
+func (c *chunkedCache) setChunked(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate chunk id: %w", err)
+	}
+	manifest := chunkManifest{
+		id:    hex.EncodeToString(id),
+		count: (len(value) + c.chunkSize - 1) / c.chunkSize,
+		size:  len(value),
+	}
+
+	chunks := make(map[string][]byte, manifest.count)
+	for i, chunkKey := range manifest.chunkKeys(key) {
+		end := min((i+1)*c.chunkSize, len(value))
+		chunks[chunkKey] = value[i*c.chunkSize : end]
+	}
+	chunkExpiration := expiration
+	if chunkExpiration > 0 {
+		chunkExpiration += chunkTTLMargin
+	}
+	if err := c.Cache.MSet(ctx, chunks, chunkExpiration); err != nil {
+		return err
+	}
+
+	header := fmt.Sprintf("%s%s:%d:%d", chunkManifestPrefix, manifest.id, manifest.count, manifest.size)
+	return c.replace(ctx, key, []byte(header), expiration)
+}
+
+// replace 写入key并删除被覆盖的值的分块，旧值不是清单时只多返回一次旧值，不增加往返次数
+func (c *chunkedCache) replace(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	old, err := c.Cache.GetSet(ctx, key, value, expiration)
+	if err != nil {
+		return err
+	}
+	c.dropChunks(ctx, key, old)
+	return nil
+}
+
+// dropChunks 值为分块清单时删除它的分块，删除失败的分块在过期后清理
+func (c *chunkedCache) dropChunks(ctx context.Context, key string, val []byte) {
+	manifest, ok := parseChunkManifest(val)
+	if !ok {
+		return
+	}
+	for _, chunkKey := range manifest.chunkKeys(key) {
+		if err := c.Cache.Delete(ctx, chunkKey); err != nil {
+			return
+		}
+	}
+}
+
+// assemble 读取清单对应的分块并拼接，任一分块缺失时返回 ErrKeyNotFound
+func (c *chunkedCache) assemble(ctx context.Context, key string, manifest chunkManifest) ([]byte, error) {
+	keys := manifest.chunkKeys(key)
+	found, missing, err := c.Cache.MGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, ErrKeyNotFound
+	}
+	value := make([]byte, 0, manifest.size)
+	for _, chunkKey := range keys {
+		value = append(value, found[chunkKey]...)
+	}
+	return value, nil
+}
+
+// resolve 值为分块清单时读取并拼接分块，否则原样返回
+func (c *chunkedCache) resolve(ctx context.Context, key string, val []byte) ([]byte, error) {
+	manifest, ok := parseChunkManifest(val)
+	if !ok {
+		return val, nil
+	}
+	return c.assemble(ctx, key, manifest)
+}
+
+func (c *chunkedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.resolve(ctx, key, val)
+}
+
+// GetWithTTL 转发到底层缓存，分块存储的key拼接分块并返回清单的剩余过期时间
+func (c *chunkedCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	val, ttl, err := c.Cache.(ValueTTLReader).GetWithTTL(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	val, err = c.resolve(ctx, key, val)
+	if err != nil {
+		return nil, 0, err
+	}
+	return val, ttl, nil
+}
+
+func (c *chunkedCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	if len(value) > c.maxSize {
+		return c.setChunked(ctx, key, value, expiration)
+	}
+	return c.replace(ctx, key, value, expiration)
+}
+
+// Delete 删除key，值为分块清单时同时删除分块
+func (c *chunkedCache) Delete(ctx context.Context, key string) error {
+	old, err := c.Cache.Get(ctx, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	if err := c.Cache.Delete(ctx, key); err != nil {
+		return err
+	}
+	c.dropChunks(ctx, key, old)
+	return nil
+}
+
+func (c *chunkedCache) MGet(ctx context.Context, keys []string) (map[string][]byte, []string, error) {
+	found, missing, err := c.Cache.MGet(ctx, keys)
+	if err != nil {
+		return found, missing, err
+	}
+	for key, val := range found {
+		value, err := c.resolve(ctx, key, val)
+		if err != nil {
+			delete(found, key)
+			missing = append(missing, key)
+			continue
+		}
+		found[key] = value
+	}
+	return found, missing, nil
+}
+
+func (c *chunkedCache) MSet(ctx context.Context, items map[string][]byte, expiration time.Duration) error {
+	small := make(map[string][]byte, len(items))
+	for key, value := range items {
+		if len(value) <= c.maxSize {
+			small[key] = value
+			continue
+		}
+		if err := c.setChunked(ctx, key, value, expiration); err != nil {
+			return err
+		}
+	}
+	if len(small) == 0 {
+		return nil
+	}
+	// 批量写入无法同时返回旧值，先读取旧值，写入成功后删除被覆盖的清单的分块
+	keys := make([]string, 0, len(small))
+	for key := range small {
+		keys = append(keys, key)
+	}
+	old, _, err := c.Cache.MGet(ctx, keys)
+	if err != nil {
+		return err
+	}
+	if err := c.Cache.MSet(ctx, small, expiration); err != nil {
+		return err
+	}
+	for key, val := range old {
+		c.dropChunks(ctx, key, val)
+	}
+	return nil
+}
+
+func (c *chunkedCache) GetSet(ctx context.Context, key string, value []byte, expiration time.Duration) ([]byte, error) {
+	old, err := c.Cache.GetSet(ctx, key, value, expiration)
+	if err != nil || old == nil {
+		return old, err
+	}
+	// 先拼接完整的旧值再删除旧值的分块
+	resolved, err := c.resolve(ctx, key, old)
+	c.dropChunks(ctx, key, old)
+	if err != nil {
+		return nil, nil
+	}
+	return resolved, nil
+}
+
+func (c *chunkedCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	keys, err := c.Cache.Keys(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	filtered := keys[:0]
+	for _, key := range keys {
+		if !strings.Contains(key, chunkKeyMarker) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered, nil
+}
+
+func (c *chunkedCache) Scan(ctx context.Context, pattern string, fn func(key string) bool) error {
+	return c.Cache.Scan(ctx, pattern, func(key string) bool {
+		if strings.Contains(key, chunkKeyMarker) {
+			return true
+		}
+		return fn(key)
+	})
+}
+
+// TTL 转发到底层缓存，分块存储的key返回清单的剩余过期时间
+func (c *chunkedCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.Cache.(TTLReader).TTL(ctx, key)
+}
+
+// MTTL 转发到底层缓存
+func (c *chunkedCache) MTTL(ctx context.Context, keys []string) (map[string]time.Duration, error) {
+	return c.Cache.(TTLReader).MTTL(ctx, keys)
+}
+
+// checkValueSize 检查值是否超过MaxValueSize：reject策略下超限时返回 ValueTooLargeError；
+// chunk策略下SetNX、GetSet等原子操作无法分块写入，超限时同样返回错误
+func (m *MultiLevelCache) checkValueSize(key string, value []byte, atomic bool) error {
+	if m.config.MaxValueSize <= 0 || len(value) <= m.config.MaxValueSize {
+		return nil
+	}
+	switch m.config.ValueSizePolicy {
+	case config.ValueSizeReject:
+	case config.ValueSizeChunk:
+		if !atomic {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return &ValueTooLargeError{Key: key, Size: len(value), Max: m.config.MaxValueSize}
+}
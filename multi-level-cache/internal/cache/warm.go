@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultWarmBatchSize 预热时每批加载的key数量
+const DefaultWarmBatchSize = 500
+
+// BulkLoader 缓存预热的数据来源，按批返回需要写入缓存的键值对
+type BulkLoader interface {
+	// Next 返回下一批键值对，没有更多数据时返回 io.EOF
+	Next(ctx context.Context) (map[string][]byte, error)
+}
+
+// BatchFetchFunc 根据一批key从数据源（通常是数据库）读取对应的值，数据源中不存在的key不包含在结果中
+type BatchFetchFunc func(ctx context.Context, keys []string) (map[string][]byte, error)
+
+// WarmProgress 预热进度
+type WarmProgress struct {
+	// 已处理的批次数
+	Batches int
+	// 已写入缓存的key数量
+	Loaded int
+	// 写入失败的key数量
+	Failed int
+	// 需要预热的key总数，数据来源无法预知总数时为0
+	Total int
+	// 已耗费的时间
+	Elapsed time.Duration
+}
+
+// WarmOptions 预热选项
+type WarmOptions struct {
+	// 写入缓存的过期时间，为0时使用缓存的默认过期时间
+	Expiration time.Duration
+
+	// 每处理完一批调用一次，用于报告进度
+	Progress func(WarmProgress)
+}
+
+// totaler 由能够预知key总数的数据来源实现
+type totaler interface {
+	Total() int
+}
+
+// Warm 在流量到来之前批量加载数据并写入两级缓存，每批通过管道一次写入Redis
+// 单批写入失败只计入Failed并继续，数据来源出错或ctx取消时停止并返回已完成的进度
+func (m *MultiLevelCache) Warm(ctx context.Context, loader BulkLoader, opts ...WarmOptions) (WarmProgress, error) {
+	var options WarmOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	start := time.Now()
+	progress := WarmProgress{}
+	if t, ok := loader.(totaler); ok {
+		progress.Total = t.Total()
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		batch, err := loader.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return progress, fmt.Errorf("failed to load warm-up batch: %w", err)
+		}
+
+		if len(batch) > 0 {
+			if err := m.MSet(withEntrySource(ctx, SourceLoader), batch, options.Expiration); err != nil {
+				progress.Failed += len(batch)
+			} else {
+				progress.Loaded += len(batch)
+			}
+		}
+		progress.Batches++
+		progress.Elapsed = time.Since(start)
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
+	}
+
+	progress.Elapsed = time.Since(start)
+	m.logger.Infof("Cache warm-up finished: %d loaded, %d failed, %d batches in %v",
+		progress.Loaded, progress.Failed, progress.Batches, progress.Elapsed)
+	return progress, nil
+}
+
+// KeyListLoader 按批从给定的key列表中读取数据
+type KeyListLoader struct {
+	keys      []string
+	batchSize int
+	fetch     BatchFetchFunc
+	offset    int
+}
+
+// NewKeyListLoader 创建基于key列表的数据来源，batchSize不大于0时使用 DefaultWarmBatchSize
+func NewKeyListLoader(keys []string, batchSize int, fetch BatchFetchFunc) *KeyListLoader {
+	if batchSize <= 0 {
+		batchSize = DefaultWarmBatchSize
+	}
+	return &KeyListLoader{keys: keys, batchSize: batchSize, fetch: fetch}
+}
+
+// Next 返回下一批键值对
+func (l *KeyListLoader) Next(ctx context.Context) (map[string][]byte, error) {
+	if l.offset >= len(l.keys) {
+		return nil, io.EOF
+	}
+	end := l.offset + l.batchSize
+	if end > len(l.keys) {
+		end = len(l.keys)
+	}
+	keys := l.keys[l.offset:end]
+	l.offset = end
+	return l.fetch(ctx, keys)
+}
+
+// Total 返回key总数
+func (l *KeyListLoader) Total() int {
+	return len(l.keys)
+}
+
+// KeyFileLoader 从key文件中按行读取key并按批读取数据，空行和以#开头的行会被忽略
+type KeyFileLoader struct {
+	scanner   *bufio.Scanner
+	batchSize int
+	fetch     BatchFetchFunc
+}
+
+// NewKeyFileLoader 创建基于key文件的数据来源，batchSize不大于0时使用 DefaultWarmBatchSize
+func NewKeyFileLoader(r io.Reader, batchSize int, fetch BatchFetchFunc) *KeyFileLoader {
+	if batchSize <= 0 {
+		batchSize = DefaultWarmBatchSize
+	}
+	return &KeyFileLoader{scanner: bufio.NewScanner(r), batchSize: batchSize, fetch: fetch}
+}
+
+// Next 返回下一批键值对
+func (l *KeyFileLoader) Next(ctx context.Context) (map[string][]byte, error) {
+	keys := make([]string, 0, l.batchSize)
+	for len(keys) < l.batchSize && l.scanner.Scan() {
+		line := strings.TrimSpace(l.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := l.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, io.EOF
+	}
+	return l.fetch(ctx, keys)
+}
@@ -1,95 +1,363 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"slices"
 	"time"
+
+	sharedconfig "redis-learning/pkg/config"
+	"redis-learning/pkg/redisclient"
 )
 
+// EnvPrefix 环境变量的前缀，变量名由前缀和配置项的YAML路径组成，如 MLC_REDIS_ADDR、MLC_LOCAL_CACHE_MAX_ENTRIES
+const EnvPrefix = "MLC"
+
 // Config 系统总体配置
 type Config struct {
 	// Redis相关配置
-	Redis RedisConfig
+	Redis RedisConfig `yaml:"redis"`
 
 	// 本地缓存相关配置
-	LocalCache LocalCacheConfig
+	LocalCache LocalCacheConfig `yaml:"local_cache"`
 
 	// 多级缓存配置
-	MultiLevelCache MultiLevelCacheConfig
+	MultiLevelCache MultiLevelCacheConfig `yaml:"multi_level_cache"`
 }
 
+// Redis部署模式
+const (
+	RedisModeStandalone = redisclient.ModeStandalone
+	RedisModeSentinel   = redisclient.ModeSentinel
+	RedisModeCluster    = redisclient.ModeCluster
+	// 进程内的miniredis，不需要真实的Redis服务器，用于单元测试、基准测试和没有Redis的环境
+	RedisModeEmbedded = "embedded"
+)
+
 // RedisConfig Redis配置
 type RedisConfig struct {
-	// Redis服务器地址
-	Addr string
-
-	// Redis密码，可为空
-	Password string
+	// 部署模式、地址、密码、数据库、连接池和超时重试配置，与其他模块共用
+	// 部署模式除standalone（默认）、sentinel、cluster外还支持embedded
+	sharedconfig.RedisOptions `yaml:",inline"`
 
-	// Redis数据库索引
-	DB int
+	// 单次缓存操作的超时时间（包括排队等待连接的时间），防止Redis变慢时拖住调用方，为0表示不限制
+	OperationTimeout time.Duration `yaml:"operation_timeout"`
 
-	// 连接池大小
-	PoolSize int
-
-	// 连接超时时间
-	DialTimeout time.Duration
-
-	// 读超时
-	ReadTimeout time.Duration
-
-	// 写超时
-	WriteTimeout time.Duration
+	// key的命名空间，非空时所有key加上"命名空间:"前缀，使多个逻辑缓存可以共用一个Redis数据库
+	Namespace string `yaml:"namespace"`
 }
 
+// 值大小超过MaxValueSize时的处理策略
+const (
+	ValueSizeRedisOnly = "redis_only"
+	ValueSizeReject    = "reject"
+	ValueSizeChunk     = "chunk"
+)
+
 // LocalCacheConfig 本地缓存配置
 type LocalCacheConfig struct {
 	// 缓存最大条目数
-	MaxEntries int
+	MaxEntries int `yaml:"max_entries"`
+
+	// 缓存数据占用的最大字节数（按key与value的长度估算），超过时淘汰最久未使用的条目，为0表示不限制
+	MaxBytes int64 `yaml:"max_bytes"`
 
 	// 默认过期时间
-	DefaultExpiration time.Duration
+	DefaultExpiration time.Duration `yaml:"default_expiration"`
 
 	// 清除过期数据的检查周期
-	CleanupInterval time.Duration
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+
+	// key的命名空间，非空时所有key加上"命名空间:"前缀
+	Namespace string `yaml:"namespace"`
+
+	// 分片数，key按哈希值分布到各个分片，不同分片上的读写互不竞争，为0时使用默认值16
+	// MaxBytes平均分配给各个分片，每个分片独立淘汰
+	Shards int `yaml:"shards"`
+}
+
+// TTLPolicy 按key匹配的过期策略，调用方未指定过期时间（为0）时使用
+type TTLPolicy struct {
+	// key的通配符模式（Redis通配符语法），如"session:*"，按前缀匹配时写作"前缀*"
+	Pattern string `yaml:"pattern"`
+
+	// 匹配的key使用的过期时间
+	Expiration time.Duration `yaml:"expiration"`
+
+	// 本地缓存过期时间系数，为0时使用LocalExpirationFactor
+	LocalFactor float64 `yaml:"local_factor"`
+
+	// 过期时间的随机抖动上限，实际过期时间为Expiration加上[0, Jitter)内的随机值，避免同一批key同时过期
+	Jitter time.Duration `yaml:"jitter"`
 }
 
 // MultiLevelCacheConfig 多级缓存配置
 type MultiLevelCacheConfig struct {
 	// 本地缓存的过期时间系数（相对于Redis中的过期时间）
 	// 例如：0.5表示本地缓存过期时间为Redis过期时间的一半
-	LocalExpirationFactor float64
+	LocalExpirationFactor float64 `yaml:"local_expiration_factor"`
 
 	// 是否启用热点key检测
-	EnableHotKeyDetection bool
+	EnableHotKeyDetection bool `yaml:"enable_hot_key_detection"`
 
 	// 访问频率阈值，超过此值视为热点key
-	HotKeyThreshold int64
+	HotKeyThreshold int64 `yaml:"hot_key_threshold"`
 
 	// 热点key统计时间窗口
-	HotKeyWindow time.Duration
+	HotKeyWindow time.Duration `yaml:"hot_key_window"`
+
+	// 是否把热点key固定在本地缓存中：key成为热点后本地过期时间延长到与Redis一致，
+	// 并在Redis过期前由后台任务刷新，热点key的读取在稳定状态下不再访问Redis。key不再是热点时自动取消固定
+	EnableHotKeyPinning bool `yaml:"enable_hot_key_pinning"`
+
+	// 固定的key在Redis过期前多久刷新
+	PinRefreshLead time.Duration `yaml:"pin_refresh_lead"`
+
+	// 最多固定的key数量，包括手动固定的key
+	MaxPinnedKeys int `yaml:"max_pinned_keys"`
+
+	// 空值缓存的过期时间：加载函数报告数据不存在时，在两级缓存中写入空值标记，
+	// 防止不存在的key反复穿透到Redis和数据库。为0表示不缓存空值
+	NullValueTTL time.Duration `yaml:"null_value_ttl"`
+
+	// 是否启用布隆过滤器：本地缓存未命中时先查询布隆过滤器，一定不存在的key直接返回，不再访问Redis
+	// 启用前需要把已有的key预先加入过滤器，否则这些key会被误拒
+	EnableBloomFilter bool `yaml:"enable_bloom_filter"`
+
+	// 布隆过滤器位图在Redis中的键名
+	BloomFilterKey string `yaml:"bloom_filter_key"`
+
+	// 布隆过滤器预计容纳的key数，超出后误判率会升高
+	BloomFilterExpectedItems uint64 `yaml:"bloom_filter_expected_items"`
+
+	// 布隆过滤器的误判率，即不存在的key被判断为"可能存在"而继续查询Redis的概率
+	// 位数组大小和哈希函数个数由预计key数和误判率计算得到，修改这两项后需要重建过滤器
+	BloomFilterFalsePositiveRate float64 `yaml:"bloom_filter_false_positive_rate"`
+
+	// 提前刷新系数（相对于完整过期时间），为0表示不启用
+	// 例如：0.2表示Redis中剩余过期时间不足完整过期时间的20%时，先返回缓存值，再在后台调用加载函数刷新
+	RefreshAheadFactor float64 `yaml:"refresh_ahead_factor"`
+
+	// 通过Get触发提前刷新时写回缓存使用的完整过期时间，GetOrLoad使用调用方传入的过期时间
+	RefreshExpiration time.Duration `yaml:"refresh_expiration"`
+
+	// 过期宽限期，为0表示不启用
+	// 启用后Redis中的数据会比逻辑过期时间多保留一个宽限期，宽限期内Get先返回旧值，再由一个后台任务调用加载函数重新加载，
+	// 避免Redis或数据库短暂故障直接表现为缓存未命中
+	StaleGracePeriod time.Duration `yaml:"stale_grace_period"`
+
+	// 是否启用分布式重建锁：两级缓存都未命中时，先获取该key的Redis锁再调用加载函数，
+	// 其他实例在等待期间轮询Redis，防止热点key过期时多台服务器同时回源（缓存击穿）
+	EnableRebuildLock bool `yaml:"enable_rebuild_lock"`
+
+	// 重建锁的过期时间，应大于加载函数的最长耗时
+	RebuildLockTTL time.Duration `yaml:"rebuild_lock_ttl"`
+
+	// 未获取到锁时等待其他实例重建完成的最长时间，超时后自行加载
+	RebuildWaitTimeout time.Duration `yaml:"rebuild_wait_timeout"`
+
+	// 等待期间轮询Redis的间隔
+	RebuildPollInterval time.Duration `yaml:"rebuild_poll_interval"`
+
+	// 是否通过Redis键事件通知使本地缓存失效：订阅当前命名空间下key的写入、删除和过期事件，
+	// 删除对应的本地副本，绕过本库直接修改Redis时本地缓存也能保持一致。
+	// 需要Redis开启notify-keyspace-events（至少包含Eg$xe）
+	EnableKeyspaceInvalidation bool `yaml:"enable_keyspace_invalidation"`
+
+	// 是否在实例之间广播失效消息：DeleteByPrefix删除Redis中的key后通过Redis发布订阅通知其他实例，
+	// 其他实例删除本地缓存中匹配的副本。不依赖notify-keyspace-events，按前缀删除大量key时也只发送一条消息
+	EnableInvalidationBroadcast bool `yaml:"enable_invalidation_broadcast"`
+
+	// 失效消息的Redis频道，会加上Redis的命名空间前缀，共用同一个频道的实例互相接收消息
+	InvalidationChannel string `yaml:"invalidation_channel"`
+
+	// 是否启用Redis熔断：连续失败达到阈值后不再访问Redis，Get只读本地缓存，
+	// 写操作写入本地缓存并排队，Redis恢复后再重放
+	EnableCircuitBreaker bool `yaml:"enable_circuit_breaker"`
+
+	// 连续失败多少次后打开熔断器
+	CircuitBreakerThreshold int `yaml:"circuit_breaker_threshold"`
+
+	// 熔断器打开后经过多长时间放行一个探测请求
+	CircuitBreakerOpenTimeout time.Duration `yaml:"circuit_breaker_open_timeout"`
+
+	// 熔断期间排队的写操作数量上限（按key去重），超过时丢弃最早的写操作
+	DegradedWriteQueueSize int `yaml:"degraded_write_queue_size"`
+
+	// 按key匹配的过期策略，按顺序匹配，第一个匹配的策略生效
+	// Set、GetOrLoad等传入的过期时间为0时使用匹配策略的过期时间，没有匹配的策略时使用Redis的默认过期时间
+	TTLPolicies []TTLPolicy `yaml:"ttl_policies"`
+
+	// 定期上报指标的间隔，为0表示不上报；需要同时通过MultiLevelCacheOptions.StatsSink指定接收方
+	StatsReportInterval time.Duration `yaml:"stats_report_interval"`
+
+	// 是否异步回填本地缓存：Redis命中后不在请求中写入本地缓存，而是交给后台协程执行，
+	// 同一个key排队期间只回填一次，高并发下可以降低Redis命中的延迟
+	EnableAsyncBackfill bool `yaml:"enable_async_backfill"`
+
+	// 异步回填队列最多排队的key数量，队列已满时放弃回填
+	BackfillQueueSize int `yaml:"backfill_queue_size"`
+
+	// 写入合并窗口，为0表示不启用：同一个key在窗口内被多次Set时，第一次立即写入Redis，
+	// 之后只更新本地缓存并记录最新的值，窗口结束时再写入Redis一次，降低频繁更新的key（计数、在线状态等）对Redis的写入量。
+	// 其他实例在窗口内可能读到Redis中较旧的值
+	WriteCoalesceWindow time.Duration `yaml:"write_coalesce_window"`
+
+	// 是否统计命中和未命中次数最多的key，通过TopHitKeys、TopMissedKeys查询，
+	// 用于发现值得更长过期时间或需要预热的key。使用count-min sketch估计次数，内存占用固定
+	EnableKeyStats bool `yaml:"enable_key_stats"`
+
+	// 命中和未命中各保留访问次数最多的多少个key
+	KeyStatsTopK int `yaml:"key_stats_top_k"`
+
+	// 访问次数的衰减周期，每经过一个周期所有计数减半，使统计偏向最近的访问，为0表示不衰减
+	KeyStatsDecay time.Duration `yaml:"key_stats_decay"`
+
+	// 单个值的最大字节数，为0表示不限制；超过时按ValueSizePolicy处理，防止少数几个大值占满本地缓存
+	MaxValueSize int `yaml:"max_value_size"`
+
+	// 值超过MaxValueSize时的处理策略：
+	// redis_only（默认）只写入Redis，不写入本地缓存；reject 返回 ErrValueTooLarge，两级缓存都不写入；
+	// chunk 不写入本地缓存，在Redis中按ValueChunkSize拆成多个分块存储，读取时自动拼接
+	ValueSizePolicy string `yaml:"value_size_policy"`
+
+	// chunk策略下每个分块的字节数
+	ValueChunkSize int `yaml:"value_chunk_size"`
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
 		Redis: RedisConfig{
-			Addr:         "localhost:6379",
-			Password:     "",
-			DB:           0,
-			PoolSize:     10,
-			DialTimeout:  5 * time.Second,
-			ReadTimeout:  3 * time.Second,
-			WriteTimeout: 3 * time.Second,
+			RedisOptions: sharedconfig.RedisOptions{
+				Mode:         RedisModeStandalone,
+				Addr:         "localhost:6379",
+				Password:     "",
+				DB:           0,
+				PoolSize:     10,
+				DialTimeout:  5 * time.Second,
+				ReadTimeout:  3 * time.Second,
+				WriteTimeout: 3 * time.Second,
+			},
+			OperationTimeout: time.Second,
 		},
 		LocalCache: LocalCacheConfig{
 			MaxEntries:        1000,
+			MaxBytes:          64 << 20,
 			DefaultExpiration: 5 * time.Minute,
 			CleanupInterval:   10 * time.Minute,
+			Shards:            16,
 		},
 		MultiLevelCache: MultiLevelCacheConfig{
-			LocalExpirationFactor: 0.5,
-			EnableHotKeyDetection: true,
-			HotKeyThreshold:       100,
-			HotKeyWindow:          1 * time.Minute,
+			LocalExpirationFactor:        0.5,
+			EnableHotKeyDetection:        true,
+			HotKeyThreshold:              100,
+			HotKeyWindow:                 1 * time.Minute,
+			EnableHotKeyPinning:          false,
+			PinRefreshLead:               2 * time.Second,
+			MaxPinnedKeys:                1000,
+			NullValueTTL:                 30 * time.Second,
+			EnableBloomFilter:            false,
+			BloomFilterKey:               "mlc:bloom",
+			BloomFilterExpectedItems:     1_000_000,
+			BloomFilterFalsePositiveRate: 0.01,
+			RefreshAheadFactor:           0,
+			RefreshExpiration:            5 * time.Minute,
+			StaleGracePeriod:             0,
+			EnableRebuildLock:            false,
+			RebuildLockTTL:               5 * time.Second,
+			RebuildWaitTimeout:           2 * time.Second,
+			RebuildPollInterval:          50 * time.Millisecond,
+			EnableKeyspaceInvalidation:   false,
+			EnableInvalidationBroadcast:  false,
+			InvalidationChannel:          "mlc:invalidation",
+			EnableCircuitBreaker:         false,
+			CircuitBreakerThreshold:      5,
+			CircuitBreakerOpenTimeout:    10 * time.Second,
+			DegradedWriteQueueSize:       10000,
+			StatsReportInterval:          0,
+			TTLPolicies:                  nil,
+			EnableAsyncBackfill:          false,
+			BackfillQueueSize:            1024,
+			WriteCoalesceWindow:          0,
+			EnableKeyStats:               false,
+			KeyStatsTopK:                 100,
+			KeyStatsDecay:                10 * time.Minute,
+			MaxValueSize:                 0,
+			ValueSizePolicy:              ValueSizeRedisOnly,
+			ValueChunkSize:               256 << 10,
 		},
 	}
 }
+
+// LoadConfig 加载配置：以 DefaultConfig 为基础，依次应用YAML配置文件和环境变量，后者优先，最后检查配置
+// path为空时不读取配置文件；按key匹配的过期策略（TTLPolicies）只能在配置文件中设置
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if err := sharedconfig.Load(path, EnvPrefix, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 检查配置是否有效，返回所有无效的配置项
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if c.Redis.Mode != RedisModeEmbedded {
+		if err := c.Redis.RedisOptions.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+	check(c.Redis.OperationTimeout >= 0, "redis.operation_timeout must not be negative: %v", c.Redis.OperationTimeout)
+
+	local := c.LocalCache
+	check(local.MaxEntries >= 0, "local_cache.max_entries must not be negative: %d", local.MaxEntries)
+	check(local.MaxBytes >= 0, "local_cache.max_bytes must not be negative: %d", local.MaxBytes)
+	check(local.DefaultExpiration >= 0, "local_cache.default_expiration must not be negative: %v", local.DefaultExpiration)
+	check(local.Shards >= 0, "local_cache.shards must not be negative: %d", local.Shards)
+
+	mlc := c.MultiLevelCache
+	check(mlc.LocalExpirationFactor >= 0, "multi_level_cache.local_expiration_factor must not be negative: %v", mlc.LocalExpirationFactor)
+	if mlc.EnableHotKeyDetection {
+		check(mlc.HotKeyThreshold > 0, "multi_level_cache.hot_key_threshold must be positive: %d", mlc.HotKeyThreshold)
+		check(mlc.HotKeyWindow > 0, "multi_level_cache.hot_key_window must be positive: %v", mlc.HotKeyWindow)
+	}
+	check(mlc.NullValueTTL >= 0, "multi_level_cache.null_value_ttl must not be negative: %v", mlc.NullValueTTL)
+	if mlc.EnableBloomFilter {
+		check(mlc.BloomFilterKey != "", "multi_level_cache.bloom_filter_key is required when enable_bloom_filter is set")
+		check(mlc.BloomFilterExpectedItems > 0, "multi_level_cache.bloom_filter_expected_items must be positive: %d", mlc.BloomFilterExpectedItems)
+		check(mlc.BloomFilterFalsePositiveRate > 0 && mlc.BloomFilterFalsePositiveRate < 1,
+			"multi_level_cache.bloom_filter_false_positive_rate must be between 0 and 1: %v", mlc.BloomFilterFalsePositiveRate)
+	}
+	check(mlc.RefreshAheadFactor >= 0 && mlc.RefreshAheadFactor < 1,
+		"multi_level_cache.refresh_ahead_factor must be at least 0 and less than 1: %v", mlc.RefreshAheadFactor)
+	check(mlc.StaleGracePeriod >= 0, "multi_level_cache.stale_grace_period must not be negative: %v", mlc.StaleGracePeriod)
+	if mlc.EnableRebuildLock {
+		check(mlc.RebuildLockTTL > 0, "multi_level_cache.rebuild_lock_ttl must be positive: %v", mlc.RebuildLockTTL)
+		check(mlc.RebuildPollInterval > 0, "multi_level_cache.rebuild_poll_interval must be positive: %v", mlc.RebuildPollInterval)
+	}
+	if mlc.EnableInvalidationBroadcast {
+		check(mlc.InvalidationChannel != "", "multi_level_cache.invalidation_channel is required when enable_invalidation_broadcast is set")
+	}
+	if mlc.EnableCircuitBreaker {
+		check(mlc.CircuitBreakerThreshold > 0, "multi_level_cache.circuit_breaker_threshold must be positive: %d", mlc.CircuitBreakerThreshold)
+		check(mlc.CircuitBreakerOpenTimeout > 0, "multi_level_cache.circuit_breaker_open_timeout must be positive: %v", mlc.CircuitBreakerOpenTimeout)
+	}
+	for i, policy := range mlc.TTLPolicies {
+		check(policy.Pattern != "", "multi_level_cache.ttl_policies[%d].pattern is required", i)
+		check(policy.Expiration >= 0, "multi_level_cache.ttl_policies[%d].expiration must not be negative: %v", i, policy.Expiration)
+	}
+	check(mlc.MaxValueSize >= 0, "multi_level_cache.max_value_size must not be negative: %d", mlc.MaxValueSize)
+	check(mlc.ValueSizePolicy == "" || slices.Contains([]string{ValueSizeRedisOnly, ValueSizeReject, ValueSizeChunk}, mlc.ValueSizePolicy),
+		"multi_level_cache.value_size_policy must be one of %s, %s, %s: %q", ValueSizeRedisOnly, ValueSizeReject, ValueSizeChunk, mlc.ValueSizePolicy)
+	check(mlc.ValueSizePolicy != ValueSizeChunk || mlc.ValueChunkSize > 0,
+		"multi_level_cache.value_chunk_size must be positive: %d", mlc.ValueChunkSize)
+
+	return errors.Join(errs...)
+}
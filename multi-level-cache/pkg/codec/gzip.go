@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 编码结果的第一个字节标记数据格式
+const (
+	formatRaw  byte = 0
+	formatGzip byte = 1
+)
+
+// ErrMalformed 表示数据不是本包编码的结果
+var ErrMalformed = errors.New("malformed encoded value")
+
+// Gzip 使用gzip压缩值，小于MinSize的值不压缩，只加上一个字节的格式标记
+type Gzip struct {
+	// 压缩级别，为0时使用 gzip.DefaultCompression
+	Level int
+	// 小于该字节数的值不压缩，压缩很小的值得不偿失
+	MinSize int
+}
+
+// Encode 压缩值，压缩后没有变小时保存原始值
+func (g Gzip) Encode(value []byte) ([]byte, error) {
+	if len(value) >= g.MinSize {
+		level := g.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		var buf bytes.Buffer
+		buf.WriteByte(formatGzip)
+		w, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+		if _, err := w.Write(value); err != nil {
+			return nil, fmt.Errorf("failed to compress value: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress value: %w", err)
+		}
+		if buf.Len() < len(value)+1 {
+			return buf.Bytes(), nil
+		}
+	}
+	out := make([]byte, len(value)+1)
+	out[0] = formatRaw
+	copy(out[1:], value)
+	return out, nil
+}
+
+// Decode 按格式标记解压
+func (g Gzip) Decode(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrMalformed
+	}
+	switch data[0] {
+	case formatRaw:
+		return data[1:], nil
+	case formatGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress value: %w", err)
+		}
+		defer r.Close()
+		value, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress value: %w", err)
+		}
+		return value, nil
+	default:
+		return nil, ErrMalformed
+	}
+}
@@ -0,0 +1,105 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// 密文格式：版本(1字节) | 密钥ID长度(1字节) | 密钥ID | nonce | 密文与认证标签
+const formatVersion byte = 1
+
+var (
+	// ErrMalformed 表示数据不是本包生成的密文，或者已被截断
+	ErrMalformed = errors.New("malformed ciphertext")
+	// ErrUnknownKeyID 表示密文使用的密钥不在密钥列表中
+	ErrUnknownKeyID = errors.New("unknown key id")
+)
+
+// AESGCM 基于AES-GCM的加密器，密文头部记录加密使用的密钥ID，支持密钥轮换：
+// 新写入的值使用当前密钥加密，旧密钥保留在密钥列表中时，之前写入的值仍然可以解密
+// 缓存的key作为附加认证数据，密文被挪到其他key下时解密失败
+type AESGCM struct {
+	activeID string
+	aeads    map[string]cipher.AEAD
+}
+
+// NewAESGCM 创建AES-GCM加密器，keys为密钥ID到密钥的映射，密钥长度为16、24或32字节，
+// activeID为加密新值使用的密钥ID，密钥ID的长度为1到255字节
+func NewAESGCM(activeID string, keys map[string][]byte) (*AESGCM, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("active key %q not found: %w", activeID, ErrUnknownKeyID)
+	}
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("invalid key id length %d", len(id))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher for key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gcm for key %q: %w", id, err)
+		}
+		aeads[id] = aead
+	}
+	return &AESGCM{activeID: activeID, aeads: aeads}, nil
+}
+
+// Encrypt 使用当前密钥加密
+func (e *AESGCM) Encrypt(key string, plaintext []byte) ([]byte, error) {
+	aead := e.aeads[e.activeID]
+	header := 2 + len(e.activeID)
+	out := make([]byte, header+aead.NonceSize(), header+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0] = formatVersion
+	out[1] = byte(len(e.activeID))
+	copy(out[2:], e.activeID)
+	nonce := out[header:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(out, nonce, plaintext, []byte(key)), nil
+}
+
+// Decrypt 按密文头部的密钥ID选择密钥解密
+func (e *AESGCM) Decrypt(key string, ciphertext []byte) ([]byte, error) {
+	id, body, err := parseHeader(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := e.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("key %q: %w", id, ErrUnknownKeyID)
+	}
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrMalformed
+	}
+	nonce, sealed := body[:aead.NonceSize()], body[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with key %q: %w", id, err)
+	}
+	return plaintext, nil
+}
+
+// KeyID 返回密文使用的密钥ID，可用于统计还有多少值使用旧密钥
+func KeyID(ciphertext []byte) (string, error) {
+	id, _, err := parseHeader(ciphertext)
+	return id, err
+}
+
+// parseHeader 解析密文头部，返回密钥ID和剩余部分
+func parseHeader(data []byte) (string, []byte, error) {
+	if len(data) < 2 || data[0] != formatVersion {
+		return "", nil, ErrMalformed
+	}
+	n := int(data[1])
+	if n == 0 || len(data) < 2+n {
+		return "", nil, ErrMalformed
+	}
+	return string(data[2 : 2+n]), data[2+n:], nil
+}
@@ -2,22 +2,224 @@ package metrics
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
+// Level 缓存层级
+type Level string
+
+const (
+	LevelLocal Level = "local"
+	LevelRedis Level = "redis"
+)
+
+// 常用的操作名称，用于按操作统计延迟
+const (
+	OpGet    = "get"
+	OpSet    = "set"
+	OpDelete = "delete"
+	OpExists = "exists"
+	OpMGet   = "mget"
+	OpMSet   = "mset"
+	OpTTL    = "ttl"
+	OpMTTL   = "mttl"
+)
+
+// latencyBuckets 延迟直方图的桶上界，超过最后一个上界的样本计入溢出桶
+// 本地缓存的操作通常在微秒级，Redis操作通常在毫秒级，桶的范围同时覆盖两者
+var latencyBuckets = []time.Duration{
+	time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	25 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// LevelStats 单个缓存层级的计数指标
+type LevelStats struct {
+	Hits   int64
+	Misses int64
+	Sets   int64
+	Errors int64
+	// 该层缓存数据占用的字节数，未注册统计函数时为0
+	Bytes int64
+}
+
+// DegradationStats Redis熔断与降级相关的计数
+type DegradationStats struct {
+	// 当前是否处于降级状态（熔断器打开）
+	Degraded bool `json:"degraded"`
+	// 熔断器打开的次数
+	CircuitOpens int64 `json:"circuit_opens"`
+	// 熔断期间被直接拒绝的Redis操作次数
+	Rejected int64 `json:"rejected"`
+	// 熔断期间排队的写操作次数
+	Queued int64 `json:"queued"`
+	// Redis恢复后重放成功的写操作次数
+	Replayed int64 `json:"replayed"`
+	// 因队列已满被丢弃的写操作次数
+	Dropped int64 `json:"dropped"`
+}
+
+// HitRatio 返回该层的命中率，没有访问时返回0
+func (s LevelStats) HitRatio() float64 {
+	return hitRatio(s.Hits, s.Misses)
+}
+
+// Stats 某一时刻的完整指标快照
+type Stats struct {
+	Time time.Time
+	// 多级缓存整体的计数
+	Hits     int64
+	Misses   int64
+	Sets     int64
+	Deletes  int64
+	HitRatio float64
+	// 命中空值标记（缓存中记录了数据不存在）的次数，Get计入Hits，MGet计入Misses
+	NegativeHits int64
+	// 启用写入合并时被合并、没有单独写入Redis的Set次数
+	CoalescedWrites int64
+	// 各层级的计数
+	Levels map[Level]LevelStats
+	// 各层级各操作的延迟分布
+	Latencies map[Level]map[string]HistogramSnapshot
+	// 熔断与降级计数，未启用熔断时为nil
+	Degradation *DegradationStats
+}
+
+// hitRatio 计算命中率，没有访问时返回0
+func hitRatio(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// BucketCount 直方图中的一个桶，UpperBound为0表示溢出桶（大于所有上界）
+type BucketCount struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+// HistogramSnapshot 延迟直方图快照
+type HistogramSnapshot struct {
+	Count   int64
+	Sum     time.Duration
+	Max     time.Duration
+	Buckets []BucketCount
+}
+
+// Mean 返回平均延迟
+func (h HistogramSnapshot) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile 按桶上界估算分位数延迟，例如Quantile(0.99)返回P99所在桶的上界
+// 桶上界大于观测到的最大延迟或落在溢出桶时返回最大延迟
+func (h HistogramSnapshot) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int64(float64(h.Count)*q + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, b := range h.Buckets {
+		seen += b.Count
+		if seen >= rank {
+			if b.UpperBound == 0 || b.UpperBound > h.Max {
+				return h.Max
+			}
+			return b.UpperBound
+		}
+	}
+	return h.Max
+}
+
+// histogram 固定桶的延迟直方图
+type histogram struct {
+	counts []int64 // 最后一个元素为溢出桶
+	count  int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return d <= latencyBuckets[i] })
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	buckets := make([]BucketCount, len(h.counts))
+	for i, c := range h.counts {
+		var upper time.Duration
+		if i < len(latencyBuckets) {
+			upper = latencyBuckets[i]
+		}
+		buckets[i] = BucketCount{UpperBound: upper, Count: c}
+	}
+	return HistogramSnapshot{Count: h.count, Sum: h.sum, Max: h.max, Buckets: buckets}
+}
+
+// levelMetrics 单个层级的指标
+type levelMetrics struct {
+	stats     LevelStats
+	latencies map[string]*histogram // 按操作名称统计的延迟
+	bytesFunc func() int64          // 读取当前占用字节数
+}
+
 // CacheMetrics 用于统计缓存命中、未命中等指标
+// 除多级缓存整体的计数外，还按层级（本地、Redis）分别统计命中、未命中、写入、错误次数以及各操作的延迟分布
 type CacheMetrics struct {
 	mu        sync.RWMutex
 	hitCount  int64 // 命中次数
 	missCount int64 // 未命中次数
 	setCount  int64 // set操作次数
 	delCount  int64 // delete操作次数
+	levels    map[Level]*levelMetrics
+
+	// 命中空值标记（数据不存在）的次数
+	negativeHitCount int64
+	// 被合并掉、没有单独写入Redis的Set次数
+	coalescedCount int64
+	// Redis熔断与降级计数，degradedFunc为nil表示未启用熔断
+	degradation  DegradationStats
+	degradedFunc func() bool
 }
 
 // NewCacheMetrics 创建新的指标统计实例
 func NewCacheMetrics() *CacheMetrics {
-	return &CacheMetrics{}
+	return &CacheMetrics{
+		levels: make(map[Level]*levelMetrics),
+	}
 }
 
 // IncHit 命中次数加一
@@ -34,6 +236,20 @@ func (m *CacheMetrics) IncMiss() {
 	m.missCount++
 }
 
+// IncNegativeHit 命中空值标记的次数加一
+func (m *CacheMetrics) IncNegativeHit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.negativeHitCount++
+}
+
+// IncCoalescedWrite 被合并的写入次数加一
+func (m *CacheMetrics) IncCoalescedWrite() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.coalescedCount++
+}
+
 // IncSet set操作次数加一
 func (m *CacheMetrics) IncSet() {
 	m.mu.Lock()
@@ -48,6 +264,117 @@ func (m *CacheMetrics) IncDel() {
 	m.delCount++
 }
 
+// AddLevelHits 增加指定层级的命中次数
+func (m *CacheMetrics) AddLevelHits(level Level, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.level(level).stats.Hits += n
+}
+
+// AddLevelMisses 增加指定层级的未命中次数
+func (m *CacheMetrics) AddLevelMisses(level Level, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.level(level).stats.Misses += n
+}
+
+// AddLevelSets 增加指定层级的写入次数
+func (m *CacheMetrics) AddLevelSets(level Level, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.level(level).stats.Sets += n
+}
+
+// IncLevelError 指定层级的错误次数加一
+func (m *CacheMetrics) IncLevelError(level Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.level(level).stats.Errors++
+}
+
+// RegisterLevelBytes 注册读取指定层级当前占用字节数的函数，在获取快照时调用
+func (m *CacheMetrics) RegisterLevelBytes(level Level, fn func() int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.level(level).bytesFunc = fn
+}
+
+// ObserveLatency 记录指定层级某个操作的一次耗时
+func (m *CacheMetrics) ObserveLatency(level Level, op string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lm := m.level(level)
+	h, ok := lm.latencies[op]
+	if !ok {
+		h = newHistogram()
+		lm.latencies[op] = h
+	}
+	h.observe(d)
+}
+
+// IncCircuitOpen 熔断器打开次数加一
+func (m *CacheMetrics) IncCircuitOpen() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.degradation.CircuitOpens++
+}
+
+// IncRejected 熔断期间被拒绝的Redis操作次数加一
+func (m *CacheMetrics) IncRejected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.degradation.Rejected++
+}
+
+// IncQueued 熔断期间排队的写操作次数加一
+func (m *CacheMetrics) IncQueued() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.degradation.Queued++
+}
+
+// IncReplayed 重放成功的写操作次数加一
+func (m *CacheMetrics) IncReplayed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.degradation.Replayed++
+}
+
+// AddDropped 增加因队列已满被丢弃的写操作次数
+func (m *CacheMetrics) AddDropped(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.degradation.Dropped += n
+}
+
+// RegisterDegraded 注册读取当前是否处于降级状态的函数，在获取快照时调用
+func (m *CacheMetrics) RegisterDegraded(fn func() bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.degradedFunc = fn
+}
+
+// DegradationSnapshot 返回熔断与降级计数快照
+func (m *CacheMetrics) DegradationSnapshot() DegradationStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := m.degradation
+	if m.degradedFunc != nil {
+		stats.Degraded = m.degradedFunc()
+	}
+	return stats
+}
+
+// level 返回指定层级的指标，不存在时创建，调用方需持有写锁
+func (m *CacheMetrics) level(level Level) *levelMetrics {
+	lm, ok := m.levels[level]
+	if !ok {
+		lm = &levelMetrics{latencies: make(map[string]*histogram)}
+		m.levels[level] = lm
+	}
+	return lm
+}
+
 // Snapshot 返回当前指标快照
 func (m *CacheMetrics) Snapshot() (hit, miss, set, del int64) {
 	m.mu.RLock()
@@ -55,9 +382,95 @@ func (m *CacheMetrics) Snapshot() (hit, miss, set, del int64) {
 	return m.hitCount, m.missCount, m.setCount, m.delCount
 }
 
+// LevelSnapshot 返回指定层级的计数指标快照
+func (m *CacheMetrics) LevelSnapshot(level Level) LevelStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	lm, ok := m.levels[level]
+	if !ok {
+		return LevelStats{}
+	}
+	stats := lm.stats
+	if lm.bytesFunc != nil {
+		stats.Bytes = lm.bytesFunc()
+	}
+	return stats
+}
+
+// LatencySnapshot 返回指定层级各操作的延迟直方图快照
+func (m *CacheMetrics) LatencySnapshot(level Level) map[string]HistogramSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make(map[string]HistogramSnapshot)
+	lm, ok := m.levels[level]
+	if !ok {
+		return result
+	}
+	for op, h := range lm.latencies {
+		result[op] = h.snapshot()
+	}
+	return result
+}
+
+// Stats 返回当前完整的指标快照
+func (m *CacheMetrics) Stats() Stats {
+	hit, miss, set, del := m.Snapshot()
+	m.mu.RLock()
+	negativeHits, coalesced := m.negativeHitCount, m.coalescedCount
+	m.mu.RUnlock()
+	stats := Stats{
+		Time:            time.Now(),
+		Hits:            hit,
+		Misses:          miss,
+		Sets:            set,
+		Deletes:         del,
+		HitRatio:        hitRatio(hit, miss),
+		NegativeHits:    negativeHits,
+		CoalescedWrites: coalesced,
+		Levels:          make(map[Level]LevelStats),
+		Latencies:       make(map[Level]map[string]HistogramSnapshot),
+	}
+	for _, level := range []Level{LevelLocal, LevelRedis} {
+		stats.Levels[level] = m.LevelSnapshot(level)
+		stats.Latencies[level] = m.LatencySnapshot(level)
+	}
+
+	m.mu.RLock()
+	breakerEnabled := m.degradedFunc != nil
+	m.mu.RUnlock()
+	if breakerEnabled {
+		d := m.DegradationSnapshot()
+		stats.Degradation = &d
+	}
+	return stats
+}
+
 // PrintMetrics 打印当前指标
 func (m *CacheMetrics) PrintMetrics() {
-	hit, miss, set, del := m.Snapshot()
-	fmt.Printf("[METRICS] %s | hit: %d | miss: %d | set: %d | del: %d\n",
-		time.Now().Format(time.RFC3339), hit, miss, set, del)
+	stats := m.Stats()
+	fmt.Printf("[METRICS] %s | hit: %d | miss: %d | negative hit: %d | set: %d | coalesced: %d | del: %d\n",
+		stats.Time.Format(time.RFC3339), stats.Hits, stats.Misses, stats.NegativeHits, stats.Sets, stats.CoalescedWrites, stats.Deletes)
+
+	for _, level := range []Level{LevelLocal, LevelRedis} {
+		ls := stats.Levels[level]
+		fmt.Printf("[METRICS] %-5s | hit: %d | miss: %d | set: %d | error: %d | bytes: %d\n",
+			level, ls.Hits, ls.Misses, ls.Sets, ls.Errors, ls.Bytes)
+
+		latencies := stats.Latencies[level]
+		ops := make([]string, 0, len(latencies))
+		for op := range latencies {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+		for _, op := range ops {
+			h := latencies[op]
+			fmt.Printf("[METRICS] %-5s %-6s | count: %d | avg: %v | p50: %v | p99: %v | max: %v\n",
+				level, op, h.Count, h.Mean(), h.Quantile(0.5), h.Quantile(0.99), h.Max)
+		}
+	}
+
+	if d := stats.Degradation; d != nil {
+		fmt.Printf("[METRICS] breaker | degraded: %v | opens: %d | rejected: %d | queued: %d | replayed: %d | dropped: %d\n",
+			d.Degraded, d.CircuitOpens, d.Rejected, d.Queued, d.Replayed, d.Dropped)
+	}
 }
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Sink 接收定期上报的指标快照，可以写日志、推送到监控系统等
+type Sink func(stats Stats)
+
+// LogSink 返回把指标快照格式化为一行日志的Sink，logf通常为log.Printf或utils.LogInfo
+func LogSink(logf func(format string, args ...interface{})) Sink {
+	return func(s Stats) {
+		local, redis := s.Levels[LevelLocal], s.Levels[LevelRedis]
+		logf("cache stats | hit: %d | miss: %d | hit ratio: %.2f%% | local hit ratio: %.2f%% | redis hit ratio: %.2f%% | local bytes: %d",
+			s.Hits, s.Misses, s.HitRatio*100, local.HitRatio()*100, redis.HitRatio()*100, local.Bytes)
+	}
+}
+
+// Reporter 定期把指标快照发送给Sink
+type Reporter struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// StartReporter 启动一个后台任务，每隔interval把指标快照发送给sink，直到调用Stop
+func (m *CacheMetrics) StartReporter(interval time.Duration, sink Sink) (*Reporter, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid report interval: %v", interval)
+	}
+	if sink == nil {
+		return nil, fmt.Errorf("sink must not be nil")
+	}
+	r := &Reporter{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				sink(m.Stats())
+			}
+		}
+	}()
+	return r, nil
+}
+
+// Stop 停止定期上报并等待后台任务退出，可以重复调用
+func (r *Reporter) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
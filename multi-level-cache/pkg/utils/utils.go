@@ -36,7 +36,12 @@ import (
 
 // LogError 记录错误日志，包含文件和行号
 func LogError(format string, v ...interface{}) {
-	_, file, line, _ := runtime.Caller(1)
+	logError(2, format, v...)
+}
+
+// logError 记录错误日志，skip为调用栈中需要跳过的层数，用于定位实际出错的文件和行号
+func logError(skip int, format string, v ...interface{}) {
+	_, file, line, _ := runtime.Caller(skip)
 	// 提取文件名（不包含路径）
 	parts := strings.Split(file, "/")
 	fileName := parts[len(parts)-1]
@@ -49,6 +54,26 @@ func LogInfo(format string, v ...interface{}) {
 	log.Printf("[INFO] "+format, v...)
 }
 
+// Logger 日志接口，缓存组件通过它输出日志，可以替换为应用自己的日志库
+type Logger interface {
+	Infof(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// DefaultLogger 与 LogInfo、LogError 输出格式相同的默认日志实现
+var DefaultLogger Logger = stdLogger{}
+
+// stdLogger 通过标准库log输出日志
+type stdLogger struct{}
+
+func (stdLogger) Infof(format string, v ...interface{}) {
+	log.Printf("[INFO] "+format, v...)
+}
+
+func (stdLogger) Errorf(format string, v ...interface{}) {
+	logError(3, format, v...)
+}
+
 //// TruncateDuration 确保持续时间不小于最小值且不大于最大值
 //func TruncateDuration(d, min, max time.Duration) time.Duration {
 //	if d < min {
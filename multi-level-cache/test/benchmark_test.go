@@ -2,6 +2,9 @@ package test
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"os"
 	"testing"
 	"time"
 
@@ -9,12 +12,26 @@ import (
 	"multi-level-cache/internal/config"
 )
 
+// benchmarkConfig 返回基准测试使用的配置：设置了REDIS_ADDR时连接该地址的Redis，
+// 否则使用进程内的miniredis，没有Redis服务器时也能运行完整的多级缓存路径
+func benchmarkConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		cfg.Redis.Addr = addr
+	} else {
+		cfg.Redis.Mode = config.RedisModeEmbedded
+	}
+	return cfg
+}
+
 // BenchmarkMultiLevelCache_Get 测试多级缓存Get性能
 func BenchmarkMultiLevelCache_Get(b *testing.B) {
 	ctx := context.Background()
-	local, _ := cache.NewLocalCache(&config.DefaultConfig().LocalCache)
-	redis, _ := cache.NewRedisCache(&config.DefaultConfig().Redis)
+	cfg := benchmarkConfig()
+	local, _ := cache.NewLocalCache(&cfg.LocalCache)
+	redis, _ := cache.NewRedisCache(&cfg.Redis)
 	mc := cache.NewMultiLevelCache(local, redis)
+	defer mc.Close()
 
 	key := "bench_key"
 	value := []byte("bench_value")
@@ -29,9 +46,11 @@ func BenchmarkMultiLevelCache_Get(b *testing.B) {
 // BenchmarkMultiLevelCache_Set 测试多级缓存Set性能
 func BenchmarkMultiLevelCache_Set(b *testing.B) {
 	ctx := context.Background()
-	local, _ := cache.NewLocalCache(&config.DefaultConfig().LocalCache)
-	redis, _ := cache.NewRedisCache(&config.DefaultConfig().Redis)
+	cfg := benchmarkConfig()
+	local, _ := cache.NewLocalCache(&cfg.LocalCache)
+	redis, _ := cache.NewRedisCache(&cfg.Redis)
 	mc := cache.NewMultiLevelCache(local, redis)
+	defer mc.Close()
 
 	key := "bench_key"
 	value := []byte("bench_value")
@@ -42,21 +61,76 @@ func BenchmarkMultiLevelCache_Set(b *testing.B) {
 	}
 }
 
-// BenchmarkMultiLevelCache_ParallelGet 并发测试多级缓存Get
+// benchmarkShards 对比的本地缓存分片数，1表示不分片
+var benchmarkShards = []int{1, 16}
+
+// benchmarkKeys 生成n个不同的key，并发测试时分布到不同的分片上
+func benchmarkKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("bench_key_%d", i)
+	}
+	return keys
+}
+
+// BenchmarkMultiLevelCache_ParallelGet 并发测试多级缓存Get，对比本地缓存分片前后的性能
 func BenchmarkMultiLevelCache_ParallelGet(b *testing.B) {
-	ctx := context.Background()
-	local, _ := cache.NewLocalCache(&config.DefaultConfig().LocalCache)
-	redis, _ := cache.NewRedisCache(&config.DefaultConfig().Redis)
-	mc := cache.NewMultiLevelCache(local, redis)
+	for _, shards := range benchmarkShards {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			ctx := context.Background()
+			cfg := benchmarkConfig()
+			cfg.LocalCache.Shards = shards
+			local, _ := cache.NewLocalCache(&cfg.LocalCache)
+			redis, _ := cache.NewRedisCache(&cfg.Redis)
+			mc := cache.NewMultiLevelCache(local, redis)
+			defer mc.Close()
 
-	key := "bench_key"
-	value := []byte("bench_value")
-	_ = mc.Set(ctx, key, value, 1*time.Minute)
+			keys := benchmarkKeys(1024)
+			value := []byte("bench_value")
+			for _, key := range keys {
+				_ = mc.Set(ctx, key, value, 1*time.Minute)
+			}
 
-	b.ResetTimer()
-	b.RunParallel(func(pb *testing.PB) {
-		for pb.Next() {
-			_, _ = mc.Get(ctx, key)
-		}
-	})
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(len(keys))
+				for pb.Next() {
+					_, _ = mc.Get(ctx, keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkLocalCache_ParallelGetSet 并发测试本地缓存读多写少（9:1）的场景，对比分片前后的锁竞争，不依赖Redis
+func BenchmarkLocalCache_ParallelGetSet(b *testing.B) {
+	for _, shards := range benchmarkShards {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			ctx := context.Background()
+			cfg := config.DefaultConfig()
+			cfg.LocalCache.Shards = shards
+			local, _ := cache.NewLocalCache(&cfg.LocalCache)
+
+			keys := benchmarkKeys(1024)
+			value := []byte("bench_value")
+			for _, key := range keys {
+				_ = local.Set(ctx, key, value, 1*time.Minute)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(len(keys))
+				for pb.Next() {
+					key := keys[i%len(keys)]
+					if i%10 == 0 {
+						_ = local.Set(ctx, key, value, 1*time.Minute)
+					} else {
+						_, _ = local.Get(ctx, key)
+					}
+					i++
+				}
+			})
+		})
+	}
 }
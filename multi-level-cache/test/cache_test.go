@@ -0,0 +1,200 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-level-cache/internal/cache"
+	"multi-level-cache/internal/config"
+)
+
+// newTestCache 创建使用embedded模式的多级缓存，configure用于在创建前修改配置
+// 测试总是使用进程内的miniredis，通过FastForward推进Redis中的时间，结果不依赖真实的Redis
+func newTestCache(t *testing.T, configure func(cfg *config.Config), opts ...cache.Option) (*cache.MultiLevelCache, *cache.LocalCache, *cache.RedisCache) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Redis.Mode = config.RedisModeEmbedded
+	if configure != nil {
+		configure(cfg)
+	}
+	local, err := cache.NewLocalCache(&cfg.LocalCache)
+	if err != nil {
+		t.Fatalf("failed to create local cache: %v", err)
+	}
+	redis, err := cache.NewRedisCache(&cfg.Redis)
+	if err != nil {
+		t.Fatalf("failed to create redis cache: %v", err)
+	}
+	opts = append([]cache.Option{cache.WithConfig(&cfg.MultiLevelCache)}, opts...)
+	mc := cache.NewMultiLevelCache(local, redis, opts...)
+	t.Cleanup(func() { _ = mc.Close() })
+	return mc, local, redis
+}
+
+// TestMultiLevelCache_GetBackfillsLocal Redis命中后回填本地缓存，本地过期时间为Redis剩余过期时间乘以LocalExpirationFactor
+func TestMultiLevelCache_GetBackfillsLocal(t *testing.T) {
+	ctx := context.Background()
+	mc, local, redis := newTestCache(t, func(cfg *config.Config) {
+		cfg.MultiLevelCache.LocalExpirationFactor = 0.5
+	})
+
+	if err := redis.Set(ctx, "user:1", []byte("alice"), time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	val, err := mc.Get(ctx, "user:1")
+	if err != nil || string(val) != "alice" {
+		t.Fatalf("Get() = %q, %v, want alice", val, err)
+	}
+
+	ttl, err := local.TTL(ctx, "user:1")
+	if err != nil {
+		t.Fatalf("local TTL() error = %v", err)
+	}
+	if ttl <= 29*time.Minute || ttl > 30*time.Minute {
+		t.Errorf("local TTL = %v, want about 30m", ttl)
+	}
+}
+
+// TestMultiLevelCache_RedisExpiry Redis中的key过期后，本地缓存失效的读取按未命中处理
+func TestMultiLevelCache_RedisExpiry(t *testing.T) {
+	ctx := context.Background()
+	mc, _, redis := newTestCache(t, nil)
+
+	if err := mc.Set(ctx, "session:1", []byte("token"), time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	redis.Embedded().FastForward(2 * time.Minute)
+	if err := mc.InvalidateLocal(ctx, "session:1"); err != nil {
+		t.Fatalf("InvalidateLocal() error = %v", err)
+	}
+
+	if _, err := mc.Get(ctx, "session:1"); !errors.Is(err, cache.ErrKeyNotFound) {
+		t.Errorf("Get() error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+// TestMultiLevelCache_GetOrLoadCachesNotFound 加载函数返回数据不存在时缓存空值标记，空值过期前不再调用加载函数
+func TestMultiLevelCache_GetOrLoadCachesNotFound(t *testing.T) {
+	ctx := context.Background()
+	mc, _, redis := newTestCache(t, nil)
+
+	loads := 0
+	loader := func(ctx context.Context, key string) ([]byte, error) {
+		loads++
+		return nil, cache.NotFound(time.Minute)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := mc.GetOrLoad(ctx, "user:404", time.Hour, loader); !errors.Is(err, cache.ErrKeyNotFound) {
+			t.Fatalf("GetOrLoad() error = %v, want ErrKeyNotFound", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loader called %d times, want 1", loads)
+	}
+
+	// 空值标记在Redis中过期后重新加载
+	redis.Embedded().FastForward(2 * time.Minute)
+	_ = mc.InvalidateLocal(ctx, "user:404")
+	_, _ = mc.GetOrLoad(ctx, "user:404", time.Hour, loader)
+	if loads != 2 {
+		t.Errorf("loader called %d times after the null value expired, want 2", loads)
+	}
+}
+
+// TestMultiLevelCache_HotKeyWithoutTTL Redis中永不过期的热点key同样回填到本地缓存
+func TestMultiLevelCache_HotKeyWithoutTTL(t *testing.T) {
+	ctx := context.Background()
+	mc, local, redis := newTestCache(t, func(cfg *config.Config) {
+		cfg.MultiLevelCache.EnableHotKeyDetection = true
+		cfg.MultiLevelCache.HotKeyThreshold = 2
+	})
+
+	if err := redis.Set(ctx, "config:site", []byte("v1"), time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	redis.Embedded().SetTTL("config:site", 0)
+	for i := 0; i < 3; i++ {
+		_ = mc.InvalidateLocal(ctx, "config:site")
+		if _, err := mc.Get(ctx, "config:site"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+
+	if _, err := local.Get(ctx, "config:site"); err != nil {
+		t.Errorf("hot key not in local cache: %v", err)
+	}
+}
+
+// TestMultiLevelCache_Pin 手动固定的key写入本地缓存，Redis中删除后刷新时取消固定
+func TestMultiLevelCache_Pin(t *testing.T) {
+	ctx := context.Background()
+	mc, local, redis := newTestCache(t, nil)
+
+	if err := redis.Set(ctx, "rank:top", []byte("1,2,3"), time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	redis.Embedded().SetTTL("rank:top", 0)
+	if err := mc.Pin(ctx, "rank:top"); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+
+	if _, err := local.Get(ctx, "rank:top"); err != nil {
+		t.Errorf("pinned key not in local cache: %v", err)
+	}
+	if pinned := mc.PinnedKeys(); len(pinned) != 1 || pinned[0].Key != "rank:top" || !pinned[0].Manual {
+		t.Errorf("PinnedKeys() = %v, want [rank:top manual]", pinned)
+	}
+	if err := mc.Pin(ctx, "missing"); !errors.Is(err, cache.ErrKeyNotFound) {
+		t.Errorf("Pin() of a missing key error = %v, want ErrKeyNotFound", err)
+	}
+}
+
+// TestMultiLevelCache_ChunkedValues 超过MaxValueSize的值分块存储，覆盖和删除时不留下旧的分块
+func TestMultiLevelCache_ChunkedValues(t *testing.T) {
+	ctx := context.Background()
+	mc, _, redis := newTestCache(t, func(cfg *config.Config) {
+		cfg.MultiLevelCache.MaxValueSize = 16
+		cfg.MultiLevelCache.ValueSizePolicy = config.ValueSizeChunk
+		cfg.MultiLevelCache.ValueChunkSize = 8
+	})
+	server := redis.Embedded()
+	large := []byte(strings.Repeat("x", 40))
+
+	if err := mc.Set(ctx, "doc:1", large, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := len(server.Keys()); got != 6 {
+		t.Errorf("Redis has %d keys after a chunked write, want manifest and 5 chunks", got)
+	}
+	val, err := mc.Get(ctx, "doc:1")
+	if err != nil || string(val) != string(large) {
+		t.Fatalf("Get() = %d bytes, %v, want %d bytes", len(val), err, len(large))
+	}
+
+	// 覆盖为新的分块值和较小的值都会删除旧的分块
+	if err := mc.Set(ctx, "doc:1", large, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := len(server.Keys()); got != 6 {
+		t.Errorf("Redis has %d keys after overwriting a chunked value, want 6", got)
+	}
+	if err := mc.Set(ctx, "doc:1", []byte("small"), 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := server.Keys(); len(got) != 1 {
+		t.Errorf("Redis keys after overwriting with a small value = %q, want only doc:1", got)
+	}
+
+	if err := mc.Set(ctx, "doc:1", large, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := mc.Delete(ctx, "doc:1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := server.Keys(); len(got) != 0 {
+		t.Errorf("Redis keys after Delete = %q, want none", got)
+	}
+}
@@ -0,0 +1,252 @@
+package bloom
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MaxBits Redis位图的最大长度（字符串最大512MB）
+	MaxBits = 1 << 32
+	// batchSize 批量操作时每个管道或每次脚本调用处理的元素数，避免单次请求过大或脚本长时间阻塞Redis
+	batchSize = 1000
+)
+
+// Config 布隆过滤器配置，位数组大小和哈希函数个数由预计元素数和误判率计算得到
+type Config struct {
+	// 预计加入的元素数，超出后误判率会升高
+	ExpectedItems uint64
+	// 期望的误判率，即不存在的元素被判断为"可能存在"的概率
+	FalsePositiveRate float64
+}
+
+// DefaultConfig 默认配置：100万个元素，1%误判率，位数组约1.1MB，7个哈希函数
+var DefaultConfig = Config{
+	ExpectedItems:     1_000_000,
+	FalsePositiveRate: 0.01,
+}
+
+// addNewScript 把元素加入过滤器，并返回每个元素加入前是否一定不存在
+// KEYS: 位图
+// ARGV: 哈希函数个数k, 之后每k个偏移量对应一个元素
+// SETBIT返回该位原来的值，只要有一位原来是0，元素就一定是第一次加入。脚本原子执行，
+// 同一个元素被并发加入时只有一个调用会得到"新元素"
+var addNewScript = redis.NewScript(`
+local k = tonumber(ARGV[1])
+local result = {}
+for i = 2, #ARGV, k do
+	local new = 0
+	for j = i, i + k - 1 do
+		if redis.call('SETBIT', KEYS[1], ARGV[j], 1) == 0 then
+			new = 1
+		end
+	end
+	result[#result + 1] = new
+end
+return result
+`)
+
+// Filter 基于Redis位图实现的布隆过滤器
+// 判断结果为"不存在"时一定不存在，为"可能存在"时存在一定的误判率；元素不能删除。
+// multi-level-cache 用它拦截一定不存在的key，uv-pv-collector 用它判断访客是否第一次访问
+//
+// 位图在第一次写入较大的偏移量时一次性分配到该长度，之后不会再扩容
+type Filter struct {
+	client redis.Cmdable
+	// 位图在Redis中的键名
+	key string
+	// 位数组大小（bit）
+	bits uint64
+	// 哈希函数个数
+	hashes uint
+}
+
+// NewFilter 按预计元素数和误判率创建布隆过滤器
+func NewFilter(client redis.Cmdable, key string, config Config) *Filter {
+	bits, hashes := OptimalParams(config.ExpectedItems, config.FalsePositiveRate)
+	return NewFilterWithSize(client, key, bits, hashes)
+}
+
+// NewDefaultFilter 使用默认配置创建布隆过滤器
+func NewDefaultFilter(client redis.Cmdable, key string) *Filter {
+	return NewFilter(client, key, DefaultConfig)
+}
+
+// NewFilterWithSize 直接指定位数组大小和哈希函数个数创建布隆过滤器
+// 位数组大小和哈希函数个数决定了元素对应的位置，同一个位图必须始终使用相同的参数
+func NewFilterWithSize(client redis.Cmdable, key string, bits uint64, hashes uint) *Filter {
+	if bits == 0 {
+		bits = 1 << 20
+	}
+	if hashes == 0 {
+		hashes = 3
+	}
+	return &Filter{
+		client: client,
+		key:    key,
+		bits:   min(bits, MaxBits),
+		hashes: hashes,
+	}
+}
+
+// OptimalParams 计算容纳n个元素、误判率为p时需要的位数组大小和哈希函数个数
+// m = -n·ln(p) / (ln2)²，k = m/n·ln2
+func OptimalParams(n uint64, p float64) (bits uint64, hashes uint) {
+	if n == 0 {
+		n = DefaultConfig.ExpectedItems
+	}
+	if p <= 0 || p >= 1 {
+		p = DefaultConfig.FalsePositiveRate
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+	return min(uint64(m), MaxBits), uint(max(k, 1))
+}
+
+// Key 返回位图在Redis中的键名
+func (f *Filter) Key() string {
+	return f.key
+}
+
+// Bits 返回位数组大小（bit）
+func (f *Filter) Bits() uint64 {
+	return f.bits
+}
+
+// Hashes 返回哈希函数个数
+func (f *Filter) Hashes() uint {
+	return f.hashes
+}
+
+// Add 将元素加入过滤器，多个元素的SETBIT通过管道批量发送
+func (f *Filter) Add(ctx context.Context, items ...string) error {
+	for start := 0; start < len(items); start += batchSize {
+		batch := items[start:min(start+batchSize, len(items))]
+		_, err := f.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, item := range batch {
+				for _, offset := range f.offsets(item) {
+					pipe.SetBit(ctx, f.key, int64(offset), 1)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add items to bloom filter: %w", err)
+		}
+	}
+	return nil
+}
+
+// AddNew 将元素加入过滤器，返回每个元素加入前是否一定不在过滤器中
+// 结果为true的元素一定是第一次加入；结果为false的元素可能已经加入过，也可能是误判的新元素
+func (f *Filter) AddNew(ctx context.Context, items ...string) ([]bool, error) {
+	result := make([]bool, 0, len(items))
+	for start := 0; start < len(items); start += batchSize {
+		batch := items[start:min(start+batchSize, len(items))]
+		args := make([]any, 0, 1+len(batch)*int(f.hashes))
+		args = append(args, f.hashes)
+		for _, item := range batch {
+			for _, offset := range f.offsets(item) {
+				args = append(args, offset)
+			}
+		}
+
+		flags, err := addNewScript.Run(ctx, f.client, []string{f.key}, args...).Int64Slice()
+		if err != nil {
+			return nil, fmt.Errorf("failed to add items to bloom filter: %w", err)
+		}
+		for _, flag := range flags {
+			result = append(result, flag == 1)
+		}
+	}
+	return result, nil
+}
+
+// MightContain 判断元素是否可能存在，返回false表示元素一定不存在
+func (f *Filter) MightContain(ctx context.Context, item string) (bool, error) {
+	result, err := f.MightContainMulti(ctx, item)
+	if err != nil {
+		return false, err
+	}
+	return result[0], nil
+}
+
+// MightContainMulti 批量判断元素是否可能存在，结果与items一一对应
+func (f *Filter) MightContainMulti(ctx context.Context, items ...string) ([]bool, error) {
+	result := make([]bool, 0, len(items))
+	for start := 0; start < len(items); start += batchSize {
+		batch := items[start:min(start+batchSize, len(items))]
+		cmds := make([][]*redis.IntCmd, len(batch))
+		_, err := f.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, item := range batch {
+				for _, offset := range f.offsets(item) {
+					cmds[i] = append(cmds[i], pipe.GetBit(ctx, f.key, int64(offset)))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query bloom filter: %w", err)
+		}
+
+		for _, bits := range cmds {
+			contained := true
+			for _, cmd := range bits {
+				if cmd.Val() == 0 {
+					contained = false
+					break
+				}
+			}
+			result = append(result, contained)
+		}
+	}
+	return result, nil
+}
+
+// ApproximateCount 根据位图中为1的位数估计已经加入的元素数
+// n ≈ -m/k · ln(1 - X/m)，X为为1的位数；位图接近写满时估计值不再可靠
+func (f *Filter) ApproximateCount(ctx context.Context) (uint64, error) {
+	set, err := f.client.BitCount(ctx, f.key, nil).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count bloom filter bits: %w", err)
+	}
+	m := float64(f.bits)
+	if float64(set) >= m {
+		return math.MaxUint64, nil
+	}
+	return uint64(math.Round(-m / float64(f.hashes) * math.Log(1-float64(set)/m))), nil
+}
+
+// Expire 设置过滤器的过期时间，适用于按天等周期重建的过滤器
+func (f *Filter) Expire(ctx context.Context, ttl time.Duration) error {
+	return f.client.Expire(ctx, f.key, ttl).Err()
+}
+
+// Clear 删除过滤器中的所有数据
+func (f *Filter) Clear(ctx context.Context) error {
+	return f.client.Del(ctx, f.key).Err()
+}
+
+// offsets 计算元素在位数组中对应的位置
+// 使用双重哈希 h1 + i*h2 模拟k个独立的哈希函数
+func (f *Filter) offsets(item string) []uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(item))
+	h1 := h.Sum64()
+
+	h = fnv.New64()
+	_, _ = h.Write([]byte(item))
+	// 保证h2为奇数，避免所有位置落在同一个位置上
+	h2 := h.Sum64() | 1
+
+	offsets := make([]uint64, f.hashes)
+	for i := uint(0); i < f.hashes; i++ {
+		offsets[i] = (h1 + uint64(i)*h2) % f.bits
+	}
+	return offsets
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"redis-learning/pkg/redisclient"
+)
+
+// ShutdownTimeout 收到中断信号后等待处理中的请求完成的时间
+const ShutdownTimeout = 5 * time.Second
+
+// RunFunc 演示程序的入口：解析args中的参数并运行
+// ctx在收到SIGINT或SIGTERM时取消，服务类的程序应在ctx取消后优雅地退出并返回nil
+type RunFunc func(ctx context.Context, args []string) error
+
+// Command 统一命令行中的一个子命令
+type Command struct {
+	Name    string
+	Summary string
+	Run     RunFunc
+}
+
+// Main 作为独立程序运行：设置日志、捕获中断信号，出错时以状态码1退出
+// 各模块的 cmd/main.go 只需要调用 cli.Main("模块名", app.Run)
+func Main(name string, run RunFunc) {
+	setupLogging(name)
+	os.Exit(execute(run, os.Args[1:]))
+}
+
+// Dispatch 以子命令的方式运行：第一个参数为子命令名，其余参数交给子命令解析
+func Dispatch(program string, commands []Command) {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		printUsage(program, commands)
+		if len(os.Args) < 2 {
+			os.Exit(2)
+		}
+		return
+	}
+
+	i := slices.IndexFunc(commands, func(c Command) bool { return c.Name == os.Args[1] })
+	if i < 0 {
+		fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", program, os.Args[1])
+		printUsage(program, commands)
+		os.Exit(2)
+	}
+	setupLogging(commands[i].Name)
+	os.Exit(execute(commands[i].Run, os.Args[2:]))
+}
+
+// execute 运行程序并返回退出状态码，-h 打印用法后正常退出
+func execute(run RunFunc, args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, args)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+		// 演示程序运行到一半被中断
+		return 130
+	default:
+		log.Printf("Error: %v", err)
+		return 1
+	}
+}
+
+// setupLogging 日志加上程序名前缀和毫秒，多个程序的输出混在一起时也能区分
+func setupLogging(name string) {
+	log.SetPrefix("[" + name + "] ")
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lmsgprefix)
+}
+
+// printUsage 打印所有子命令
+func printUsage(program string, commands []Command) {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", program)
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", c.Name, c.Summary)
+	}
+	w.Flush()
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", program)
+}
+
+// NewFlagSet 创建程序的参数集合，解析出错时返回错误而不是直接退出，-h 时返回 flag.ErrHelp
+func NewFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// RedisFlag 注册各程序共用的 -redis 参数
+func RedisFlag(fs *flag.FlagSet) *string {
+	return fs.String("redis", "localhost:6379", "Redis address")
+}
+
+// AddrFlag 注册HTTP服务共用的 -addr 参数
+func AddrFlag(fs *flag.FlagSet) *string {
+	return fs.String("addr", ":8080", "HTTP listen address")
+}
+
+// Connect 连接单节点Redis并检查连接，失败时关闭客户端
+func Connect(ctx context.Context, addr string) (*redis.Client, error) {
+	client := redisclient.NewClient(redisclient.Config{Addr: addr})
+	if err := redisclient.Ping(ctx, client); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", addr, err)
+	}
+	return client, nil
+}
+
+// ConnectAll 连接逗号分隔的多个Redis地址，任何一个失败时关闭已经建立的连接
+func ConnectAll(ctx context.Context, addrs string) ([]*redis.Client, error) {
+	var clients []*redis.Client
+	for _, addr := range strings.Split(addrs, ",") {
+		client, err := Connect(ctx, strings.TrimSpace(addr))
+		if err != nil {
+			for _, c := range clients {
+				c.Close()
+			}
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// ServeHTTP 启动HTTP服务并阻塞，ctx取消后停止接收新请求，最多等待 ShutdownTimeout 让处理中的请求完成
+// 正常关闭时返回nil，监听失败时返回错误
+func ServeHTTP(ctx context.Context, server *http.Server) error {
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on %s", server.Addr)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+	log.Println("Server exited")
+	return nil
+}
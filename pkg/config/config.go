@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"redis-learning/pkg/redisclient"
+)
+
+// RedisOptions 各模块共用的Redis连接配置，嵌入模块的配置结构体中，YAML字段和环境变量名在各模块中保持一致：
+//
+//	redis:
+//	  mode: standalone
+//	  addr: localhost:6379
+//	  pool_size: 20
+//
+// 对应的环境变量为 <前缀>_REDIS_MODE、<前缀>_REDIS_ADDR、<前缀>_REDIS_POOL_SIZE
+type RedisOptions = redisclient.Config
+
+// Validator 加载后需要检查的配置
+type Validator interface {
+	Validate() error
+}
+
+// Load 加载配置到cfg（结构体指针）：cfg中已有的值作为默认值，依次应用YAML配置文件和环境变量，后者优先
+// path为空时不读取配置文件；加载后如果cfg实现了 Validator，检查配置是否有效
+func Load(path, envPrefix string, cfg any) error {
+	if err := LoadFile(path, cfg); err != nil {
+		return err
+	}
+	if err := ApplyEnv(cfg, envPrefix); err != nil {
+		return err
+	}
+	if v, ok := cfg.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadFile 用YAML配置文件覆盖cfg中的值，文件中没有出现的配置项保持不变
+// path为空时什么都不做；配置文件中出现未知的配置项时返回错误，避免拼写错误被忽略
+func LoadFile(path string, cfg any) error {
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// ApplyEnv 用环境变量覆盖cfg（结构体指针）中带yaml标签的字段
+// 变量名由前缀和各级字段的yaml标签拼接而成，如 RATELIMIT_REDIS_ADDR、RATELIMIT_HOT_KEY_WINDOW；
+// 以 inline 方式嵌入的结构体不增加一级。时间间隔使用 time.ParseDuration 的格式，字符串列表以逗号分隔
+func ApplyEnv(cfg any, prefix string) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to struct, got %T", cfg)
+	}
+	return applyEnv(v.Elem(), prefix)
+}
+
+// applyEnv 递归处理结构体的每个字段
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		field := v.Field(i)
+
+		if name == "" && strings.Contains(opts, "inline") && field.Kind() == reflect.Struct {
+			if err := applyEnv(field, prefix); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" || name == "-" || !field.CanSet() {
+			continue
+		}
+		envName := prefix + "_" + strings.ToUpper(name)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, envName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("invalid environment variable %s: %w", envName, err)
+		}
+	}
+	return nil
+}
+
+// setField 把环境变量的值解析为字段的类型
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s, set it in the config file", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix 事件流键名的前缀
+const KeyPrefix = "eventbus:"
+
+// 事件在流中的字段
+const (
+	fieldData   = "data"
+	fieldSource = "source"
+	fieldTime   = "time"
+)
+
+// Config 事件总线配置
+type Config struct {
+	// 发布方的名称，写入每个事件，订阅方可以据此区分事件来自哪个服务
+	Source string
+	// 每个主题的流的最大长度，发布时以 MAXLEN ~ 近似裁剪旧事件，0表示不裁剪
+	// 裁剪不区分事件是否已经被所有订阅方确认，应远大于订阅方积压的事件数
+	MaxLen int64
+	// 每次读取的最多事件数
+	Count int64
+	// XREADGROUP 没有新事件时阻塞等待的时间
+	Block time.Duration
+	// 事件在待确认列表中空闲超过该时间时，视为领取它的订阅方已经崩溃，由同组的其他订阅方接管
+	ClaimIdle time.Duration
+	// 检查可以接管的事件的间隔
+	ReclaimInterval time.Duration
+	// 每个事件最多投递的次数，超过时确认并丢弃，避免一个无法处理的事件被反复投递
+	MaxDeliveries int64
+}
+
+// DefaultConfig 默认事件总线配置
+var DefaultConfig = Config{
+	MaxLen:          100000,
+	Count:           100,
+	Block:           2 * time.Second,
+	ClaimIdle:       30 * time.Second,
+	ReclaimInterval: 10 * time.Second,
+	MaxDeliveries:   3,
+}
+
+// Event 从事件总线收到的事件
+type Event struct {
+	// 事件在流中的ID
+	ID    string
+	Topic string
+	// 发布方的名称，见 Config.Source
+	Source string
+	// 发布时间
+	Time time.Time
+	// 事件数据，发布时的值序列化成的JSON
+	Data json.RawMessage
+	// 已经投递的次数，包括这一次
+	Deliveries int64
+}
+
+// Decode 把事件数据反序列化到v中
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Handler 处理一个事件，返回nil时确认事件；返回错误时事件留在待确认列表中，
+// 空闲超过 ClaimIdle 后重新投递，投递次数超过 MaxDeliveries 时丢弃
+type Handler func(ctx context.Context, event Event) error
+
+// Bus 基于Redis Streams的事件总线，连接仓库中的不同服务：
+// 每个主题一个流，发布方用 XADD 追加事件；订阅方以消费者组读取，
+// 同一个组内的订阅方分摊事件（每个事件只投递给组内的一个订阅方），不同的组各自收到全部事件
+//
+// 事件至少投递一次：订阅方处理后才确认，崩溃时没有确认的事件由同组的其他订阅方接管，处理逻辑应容忍重复的事件
+type Bus struct {
+	client redis.UniversalClient
+	name   string
+	config Config
+}
+
+// NewBus 创建名为name的事件总线，发布方和订阅方使用相同的名称和Redis才能互通
+func NewBus(client redis.UniversalClient, name string, config Config) *Bus {
+	return &Bus{client: client, name: name, config: config}
+}
+
+// NewDefaultBus 使用默认配置创建事件总线，source为发布方的名称
+func NewDefaultBus(client redis.UniversalClient, name, source string) *Bus {
+	config := DefaultConfig
+	config.Source = source
+	return NewBus(client, name, config)
+}
+
+// Stream 返回主题对应的流的键名，同一个总线的流使用相同的哈希标签，集群中位于同一个槽
+func (b *Bus) Stream(topic string) string {
+	return KeyPrefix + "{" + b.name + "}:" + topic
+}
+
+// Publish 把data序列化为JSON后作为一个事件发布到主题，返回事件的ID
+func (b *Bus) Publish(ctx context.Context, topic string, data any) (string, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("eventbus: failed to encode event: %w", err)
+	}
+	return b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: b.Stream(topic),
+		MaxLen: b.config.MaxLen,
+		Approx: b.config.MaxLen > 0,
+		Values: map[string]any{
+			fieldData:   payload,
+			fieldSource: b.config.Source,
+			fieldTime:   time.Now().UnixMilli(),
+		},
+	}).Result()
+}
+
+// Subscribe 以消费者组group中名为consumer的订阅方循环处理主题的事件，直到ctx结束，返回ctx的错误
+//
+// 消费者组不存在时创建，只接收之后发布的事件；开始时先处理自己之前领取但没有确认的事件，
+// 之后每隔 ReclaimInterval 接管同组中空闲超过 ClaimIdle 的事件。
+// 事件在同一个协程中依次处理；进程重启后应使用相同的consumer名称，才能接着处理崩溃前领取的事件
+func (b *Bus) Subscribe(ctx context.Context, topic, group, consumer string, handler Handler) error {
+	s := &subscription{bus: b, topic: topic, stream: b.Stream(topic), group: group, consumer: consumer, handler: handler}
+	if err := s.createGroup(ctx); err != nil {
+		return fmt.Errorf("eventbus: failed to create consumer group %s: %w", group, err)
+	}
+	if err := s.recoverPending(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Error recovering pending events of %s on %s: %v", consumer, s.stream, err)
+	}
+
+	lastReclaim := time.Now()
+	for {
+		if time.Since(lastReclaim) >= b.config.ReclaimInterval {
+			if err := s.reclaim(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error reclaiming events for %s on %s: %v", consumer, s.stream, err)
+			}
+			lastReclaim = time.Now()
+		}
+
+		streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: consumer,
+			Streams:  []string{s.stream, ">"},
+			Count:    b.config.Count,
+			Block:    b.config.Block,
+		}).Result()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error reading events from %s: %v", s.stream, err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		for _, msg := range streams[0].Messages {
+			s.handle(ctx, msg, 1)
+		}
+	}
+}
+
+// subscription 一个订阅方的状态
+type subscription struct {
+	bus      *Bus
+	topic    string
+	stream   string
+	group    string
+	consumer string
+	handler  Handler
+}
+
+// createGroup 创建消费者组，流不存在时一并创建；组已经存在时不做任何事
+func (s *subscription) createGroup(ctx context.Context) error {
+	err := s.bus.client.XGroupCreateMkStream(ctx, s.stream, s.group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// recoverPending 处理自己待确认列表中的事件，读取ID "0" 起的历史事件直到读完
+func (s *subscription) recoverPending(ctx context.Context) error {
+	start := "0"
+	for {
+		streams, err := s.bus.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, start},
+			Count:    s.bus.config.Count,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msgs := streams[0].Messages
+		if len(msgs) == 0 {
+			return nil
+		}
+		if err := s.handleAll(ctx, msgs); err != nil {
+			return err
+		}
+		start = msgs[len(msgs)-1].ID
+	}
+}
+
+// reclaim 用 XAUTOCLAIM 接管同组中空闲超过 ClaimIdle 的事件并处理
+func (s *subscription) reclaim(ctx context.Context) error {
+	start := "0-0"
+	for {
+		msgs, next, err := s.bus.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			MinIdle:  s.bus.config.ClaimIdle,
+			Start:    start,
+			Count:    s.bus.config.Count,
+			Consumer: s.consumer,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(msgs) > 0 {
+			log.Printf("Subscriber %s reclaimed %d idle events from %s", s.consumer, len(msgs), s.stream)
+			if err := s.handleAll(ctx, msgs); err != nil {
+				return err
+			}
+		}
+		// 扫描完整个待确认列表时返回的游标为0-0
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// handleAll 从待确认列表中查询事件的投递次数后依次处理
+func (s *subscription) handleAll(ctx context.Context, msgs []redis.XMessage) error {
+	cmds := make([]*redis.XPendingExtCmd, len(msgs))
+	_, err := s.bus.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, msg := range msgs {
+			cmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: s.stream,
+				Group:  s.group,
+				Start:  msg.ID,
+				End:    msg.ID,
+				Count:  1,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, msg := range msgs {
+		var deliveries int64
+		if pending := cmds[i].Val(); len(pending) > 0 {
+			deliveries = pending[0].RetryCount
+		}
+		s.handle(ctx, msg, deliveries)
+	}
+	return nil
+}
+
+// handle 处理一个事件：成功时确认，失败时留在待确认列表中；投递次数超过上限或事件已被裁剪时确认并丢弃
+func (s *subscription) handle(ctx context.Context, msg redis.XMessage, deliveries int64) {
+	switch {
+	case len(msg.Values) == 0:
+		// 事件已经被裁剪出流，只剩待确认列表中的ID
+	case deliveries > s.bus.config.MaxDeliveries:
+		log.Printf("Dropping event %s on %s after %d deliveries", msg.ID, s.stream, deliveries-1)
+	default:
+		if err := s.handler(ctx, s.event(msg, deliveries)); err != nil {
+			log.Printf("Subscriber %s failed to handle event %s on %s (delivery %d), left pending: %v",
+				s.consumer, msg.ID, s.stream, deliveries, err)
+			return
+		}
+	}
+	// 处理完成后即使ctx已经结束也要确认，避免事件被重复投递
+	if err := s.bus.client.XAck(context.WithoutCancel(ctx), s.stream, s.group, msg.ID).Err(); err != nil {
+		log.Printf("Error acknowledging event %s on %s: %v", msg.ID, s.stream, err)
+	}
+}
+
+// event 把流中的条目转换为事件
+func (s *subscription) event(msg redis.XMessage, deliveries int64) Event {
+	event := Event{ID: msg.ID, Topic: s.topic, Deliveries: deliveries}
+	if data, ok := msg.Values[fieldData].(string); ok {
+		event.Data = json.RawMessage(data)
+	}
+	event.Source, _ = msg.Values[fieldSource].(string)
+	if raw, ok := msg.Values[fieldTime].(string); ok {
+		if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			event.Time = time.UnixMilli(ms)
+		}
+	}
+	return event
+}
@@ -0,0 +1,18 @@
+package eventbus
+
+import "time"
+
+// SharedBus 仓库中各服务共用的事件总线名称，服务之间的集成事件都发布在这个总线上
+const SharedBus = "redis-learning"
+
+// TopicPageAccess 页面访问事件的主题：uv-pv-collector 每记录一次访问发布一个事件，数据为 PageAccess；
+// rate-limit 订阅后把被频繁访问的页面计入热点key检测
+const TopicPageAccess = "page-access"
+
+// PageAccess 一次页面访问
+type PageAccess struct {
+	Page      string    `json:"page"`
+	VisitorID string    `json:"visitor_id"`
+	Referrer  string    `json:"referrer,omitempty"`
+	Time      time.Time `json:"time"`
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+
+	"redis-learning/pkg/cli"
+	"redis-learning/pkg/redisclient"
+)
+
+// Path 指标接口的路径
+const Path = "/metrics"
+
+// Redis命令的结果，即 outcome 标签的取值
+const (
+	OutcomeOK      = "ok"
+	OutcomeError   = "error"
+	OutcomeTimeout = "timeout"
+)
+
+// redisLatencyBuckets Redis命令耗时直方图的桶上界，单位为秒，从0.1ms到1s
+var redisLatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// Registry 各服务共用的指标注册表，创建时注册了Go运行时和进程的指标
+// 每个服务使用自己的 Registry，同一进程中的多个服务互不影响
+type Registry struct {
+	*prometheus.Registry
+
+	redisOnce     sync.Once
+	redisCommands *prometheus.CounterVec
+	redisDuration *prometheus.HistogramVec
+}
+
+// NewRegistry 创建指标注册表
+func NewRegistry() *Registry {
+	r := &Registry{Registry: prometheus.NewRegistry()}
+	r.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// Handler 返回以Prometheus文本格式输出指标的HTTP处理函数，挂载在 Path 上
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.Registry, promhttp.HandlerOpts{})
+}
+
+// Serve 启动只提供 Path 的HTTP服务并阻塞，ctx取消后关闭，用于本身没有HTTP服务的演示程序
+func (r *Registry) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, r.Handler())
+	return cli.ServeHTTP(ctx, &http.Server{Addr: addr, Handler: mux})
+}
+
+// RedisHook 返回统计Redis命令的Hook，添加到模块的客户端上
+// 所有模块的命令记录在相同的指标中，以module标签区分：
+//
+//	redis_commands_total{module, op, outcome}             命令数
+//	redis_command_duration_seconds{module, op, outcome}   命令耗时
+//
+// op为小写的命令名，pipeline和事务整体记录一次，op为 redisclient.PipelineCommand；
+// outcome为 OutcomeOK、OutcomeError 或 OutcomeTimeout，key不存在不视为错误
+// 同一个模块的多个客户端（如主库和从库）可以使用同一个module
+func (r *Registry) RedisHook(module string) redis.Hook {
+	r.redisOnce.Do(r.registerRedis)
+	commands := r.redisCommands.MustCurryWith(prometheus.Labels{"module": module})
+	duration := r.redisDuration.MustCurryWith(prometheus.Labels{"module": module})
+	return redisclient.NewObserverHook(func(ctx context.Context, cmd string, d time.Duration, err error) {
+		outcome := Outcome(err)
+		commands.WithLabelValues(cmd, outcome).Inc()
+		duration.WithLabelValues(cmd, outcome).Observe(d.Seconds())
+	})
+}
+
+// registerRedis 创建并注册Redis命令的指标
+func (r *Registry) registerRedis() {
+	labels := []string{"module", "op", "outcome"}
+	r.redisCommands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_commands_total",
+		Help: "Redis commands by module, command (op) and outcome (ok, error, timeout).",
+	}, labels)
+	r.redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "Latency of Redis commands by module, command (op) and outcome (ok, error, timeout).",
+		Buckets: redisLatencyBuckets,
+	}, labels)
+	r.MustRegister(r.redisCommands, r.redisDuration)
+}
+
+// Outcome 返回命令错误对应的 outcome 标签，超时单独统计，便于和Redis返回的错误区分
+func Outcome(err error) string {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return OutcomeOK
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return OutcomeTimeout
+	}
+	return OutcomeError
+}
@@ -0,0 +1,211 @@
+package redisclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis部署模式
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+var (
+	// ErrUnsupportedMode 不支持的部署模式
+	ErrUnsupportedMode = errors.New("redisclient: unsupported mode")
+	// ErrMissingAddrs 部署模式需要的地址没有配置
+	ErrMissingAddrs = errors.New("redisclient: missing addresses")
+)
+
+// Config Redis连接配置，各个模块共用
+// 超时和重试字段为0时使用 DefaultConfig 中的值
+type Config struct {
+	// 部署模式：standalone（默认）、sentinel、cluster
+	Mode string `yaml:"mode"`
+	// Redis服务器地址，standalone模式使用
+	Addr string `yaml:"addr"`
+	// Redis密码，可为空
+	Password string `yaml:"password"`
+	// 数据库索引，集群模式不支持选择数据库，忽略该字段
+	DB int `yaml:"db"`
+
+	// 哨兵模式下的主节点名称
+	MasterName string `yaml:"master_name"`
+	// 哨兵节点地址列表
+	SentinelAddrs []string `yaml:"sentinel_addrs"`
+	// 哨兵节点的密码，可为空
+	SentinelPassword string `yaml:"sentinel_password"`
+	// 集群模式下的节点地址列表
+	ClusterAddrs []string `yaml:"cluster_addrs"`
+
+	// 连接池大小，为0时使用go-redis的默认值（每个CPU 10个连接）
+	PoolSize int `yaml:"pool_size"`
+	// 建立连接的超时时间
+	DialTimeout time.Duration `yaml:"dial_timeout"`
+	// 读超时
+	ReadTimeout time.Duration `yaml:"read_timeout"`
+	// 写超时
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// 命令失败（网络错误、超时）后的最大重试次数，-1表示不重试
+	MaxRetries int `yaml:"max_retries"`
+	// 重试间隔的范围，每次重试的间隔按指数增长，不超过最大值
+	MinRetryBackoff time.Duration `yaml:"min_retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+}
+
+// DefaultConfig 默认Redis连接配置
+var DefaultConfig = Config{
+	Mode:            ModeStandalone,
+	Addr:            "localhost:6379",
+	DialTimeout:     5 * time.Second,
+	ReadTimeout:     3 * time.Second,
+	WriteTimeout:    3 * time.Second,
+	MaxRetries:      3,
+	MinRetryBackoff: 8 * time.Millisecond,
+	MaxRetryBackoff: 512 * time.Millisecond,
+}
+
+// withDefaults 用默认值填充没有设置的字段
+func (c Config) withDefaults() Config {
+	if c.Mode == "" {
+		c.Mode = ModeStandalone
+	}
+	if c.Mode == ModeStandalone && c.Addr == "" {
+		c.Addr = DefaultConfig.Addr
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = DefaultConfig.DialTimeout
+	}
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = DefaultConfig.ReadTimeout
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = DefaultConfig.WriteTimeout
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultConfig.MaxRetries
+	}
+	if c.MinRetryBackoff == 0 {
+		c.MinRetryBackoff = DefaultConfig.MinRetryBackoff
+	}
+	if c.MaxRetryBackoff == 0 {
+		c.MaxRetryBackoff = DefaultConfig.MaxRetryBackoff
+	}
+	return c
+}
+
+// Validate 检查部署模式和对应的地址
+func (c Config) Validate() error {
+	c = c.withDefaults()
+	switch c.Mode {
+	case ModeStandalone:
+		return nil
+	case ModeSentinel:
+		if c.MasterName == "" || len(c.SentinelAddrs) == 0 {
+			return fmt.Errorf("%w: sentinel mode requires master name and sentinel addrs", ErrMissingAddrs)
+		}
+		return nil
+	case ModeCluster:
+		if len(c.ClusterAddrs) == 0 {
+			return fmt.Errorf("%w: cluster mode requires cluster addrs", ErrMissingAddrs)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedMode, c.Mode)
+	}
+}
+
+// Describe 返回用于日志的地址描述，不包含密码
+func (c Config) Describe() string {
+	c = c.withDefaults()
+	switch c.Mode {
+	case ModeSentinel:
+		return fmt.Sprintf("sentinel %s [%s]", c.MasterName, strings.Join(c.SentinelAddrs, " "))
+	case ModeCluster:
+		return fmt.Sprintf("cluster [%s]", strings.Join(c.ClusterAddrs, " "))
+	default:
+		return c.Addr
+	}
+}
+
+// Options 返回standalone模式的连接选项
+func (c Config) Options() *redis.Options {
+	c = c.withDefaults()
+	return &redis.Options{
+		Addr:            c.Addr,
+		Password:        c.Password,
+		DB:              c.DB,
+		PoolSize:        c.PoolSize,
+		DialTimeout:     c.DialTimeout,
+		ReadTimeout:     c.ReadTimeout,
+		WriteTimeout:    c.WriteTimeout,
+		MaxRetries:      c.MaxRetries,
+		MinRetryBackoff: c.MinRetryBackoff,
+		MaxRetryBackoff: c.MaxRetryBackoff,
+		// 使读写遵循ctx的截止时间，调用方可以用ctx控制单次操作的超时
+		ContextTimeoutEnabled: true,
+	}
+}
+
+// New 按部署模式创建客户端，hooks 依次添加到客户端上，例如 NewObserverHook、NewSlowLogHook
+// 只创建客户端，不检查连接，需要时调用 Ping 或 CheckHealth
+func New(config Config, hooks ...redis.Hook) (redis.UniversalClient, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config = config.withDefaults()
+
+	var client redis.UniversalClient
+	switch config.Mode {
+	case ModeSentinel:
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:            config.MasterName,
+			SentinelAddrs:         config.SentinelAddrs,
+			SentinelPassword:      config.SentinelPassword,
+			Password:              config.Password,
+			DB:                    config.DB,
+			PoolSize:              config.PoolSize,
+			DialTimeout:           config.DialTimeout,
+			ReadTimeout:           config.ReadTimeout,
+			WriteTimeout:          config.WriteTimeout,
+			MaxRetries:            config.MaxRetries,
+			MinRetryBackoff:       config.MinRetryBackoff,
+			MaxRetryBackoff:       config.MaxRetryBackoff,
+			ContextTimeoutEnabled: true,
+		})
+	case ModeCluster:
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:                 config.ClusterAddrs,
+			Password:              config.Password,
+			PoolSize:              config.PoolSize,
+			DialTimeout:           config.DialTimeout,
+			ReadTimeout:           config.ReadTimeout,
+			WriteTimeout:          config.WriteTimeout,
+			MaxRetries:            config.MaxRetries,
+			MinRetryBackoff:       config.MinRetryBackoff,
+			MaxRetryBackoff:       config.MaxRetryBackoff,
+			ContextTimeoutEnabled: true,
+		})
+	default:
+		client = redis.NewClient(config.Options())
+	}
+	for _, hook := range hooks {
+		client.AddHook(hook)
+	}
+	return client, nil
+}
+
+// NewClient 创建standalone模式的客户端，忽略 Mode，用于只支持单节点的组件（如读写分离代理的各个节点）
+func NewClient(config Config, hooks ...redis.Hook) *redis.Client {
+	client := redis.NewClient(config.Options())
+	for _, hook := range hooks {
+		client.AddHook(hook)
+	}
+	return client
+}
@@ -0,0 +1,49 @@
+package redisclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Health 连接的健康状态，可以直接作为健康检查接口的响应
+type Health struct {
+	Healthy bool          `json:"healthy"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+	// 连接池统计
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	// 等待空闲连接超时的次数，持续增长说明连接池太小
+	PoolTimeouts uint32 `json:"pool_timeouts"`
+}
+
+// Ping 检查连接，集群模式下检查每一个主节点
+func Ping(ctx context.Context, client redis.UniversalClient) error {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return node.Ping(ctx).Err()
+		})
+	}
+	return client.Ping(ctx).Err()
+}
+
+// CheckHealth 检查连接并返回健康状态和连接池统计
+func CheckHealth(ctx context.Context, client redis.UniversalClient) Health {
+	start := time.Now()
+	err := Ping(ctx, client)
+	health := Health{
+		Healthy: err == nil,
+		Latency: time.Since(start),
+	}
+	if err != nil {
+		health.Error = err.Error()
+	}
+	if stats := client.PoolStats(); stats != nil {
+		health.TotalConns = stats.TotalConns
+		health.IdleConns = stats.IdleConns
+		health.PoolTimeouts = stats.Timeouts
+	}
+	return health
+}
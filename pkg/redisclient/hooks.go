@@ -0,0 +1,71 @@
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PipelineCommand pipeline和事务在 Observer 中使用的命令名
+const PipelineCommand = "pipeline"
+
+// Observer 命令执行完成后的回调，用于统计耗时、错误数等指标
+// cmd为小写的命令名，pipeline整体回调一次，cmd为 PipelineCommand；key不存在（redis.Nil）不视为错误
+type Observer func(ctx context.Context, cmd string, duration time.Duration, err error)
+
+// observerHook 在每个命令执行完成后调用 Observer
+type observerHook struct {
+	observe Observer
+}
+
+// NewObserverHook 创建调用 observe 的Hook
+func NewObserverHook(observe Observer) redis.Hook {
+	return observerHook{observe: observe}
+}
+
+func (h observerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h observerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(ctx, cmd.Name(), time.Since(start), ignoreNil(err))
+		return err
+	}
+}
+
+func (h observerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.observe(ctx, PipelineCommand, time.Since(start), ignoreNil(err))
+		return err
+	}
+}
+
+// NewSlowLogHook 创建记录慢命令和失败命令的Hook，耗时超过threshold的命令输出到日志
+func NewSlowLogHook(threshold time.Duration) redis.Hook {
+	return NewObserverHook(func(ctx context.Context, cmd string, duration time.Duration, err error) {
+		if err != nil {
+			log.Printf("Redis %s failed after %v: %v", cmd, duration, err)
+		} else if duration >= threshold {
+			log.Printf("Slow Redis %s took %v", cmd, duration)
+		}
+	})
+}
+
+// ignoreNil key不存在不是错误
+func ignoreNil(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
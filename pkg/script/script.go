@@ -0,0 +1,204 @@
+package script
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Script 注册在脚本包中的一个Lua脚本
+// 执行时先用 EVALSHA 只发送脚本的SHA1；Redis中没有该脚本（第一次执行、重启、主从切换或 SCRIPT FLUSH 之后）
+// 返回 NOSCRIPT 时，用 SCRIPT LOAD 加载后重新执行一次，之后的调用都只发送SHA1
+type Script struct {
+	bundle string
+	name   string
+	src    string
+	hash   string
+}
+
+// Name 返回脚本的完整名称：脚本包名/脚本名
+func (s *Script) Name() string {
+	return s.bundle + "/" + s.name
+}
+
+// Hash 返回脚本的SHA1，与 SCRIPT LOAD 返回的值相同
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// Source 返回脚本的源码
+func (s *Script) Source() string {
+	return s.src
+}
+
+// Run 执行脚本，参数与 redis.Script.Run 相同
+// 在管道中执行时命令直到 Exec 才发送，无法在 NOSCRIPT 时重新加载，需要先用 Bundle.Load 加载脚本
+func (s *Script) Run(ctx context.Context, c redis.Scripter, keys []string, args ...any) *redis.Cmd {
+	return s.run(ctx, c, c.EvalSha, keys, args...)
+}
+
+// RunRO 以只读方式执行脚本（EVALSHA_RO），可以在从节点上执行，脚本中不能有写命令
+func (s *Script) RunRO(ctx context.Context, c redis.Scripter, keys []string, args ...any) *redis.Cmd {
+	return s.run(ctx, c, c.EvalShaRO, keys, args...)
+}
+
+// run 执行 EVALSHA，返回 NOSCRIPT 时加载脚本后重试一次
+func (s *Script) run(ctx context.Context, c redis.Scripter,
+	evalSha func(ctx context.Context, sha1 string, keys []string, args ...any) *redis.Cmd,
+	keys []string, args ...any) *redis.Cmd {
+	cmd := evalSha(ctx, s.hash, keys, args...)
+	if !redis.HasErrorPrefix(cmd.Err(), "NOSCRIPT") {
+		return cmd
+	}
+	if err := s.Load(ctx, c); err != nil {
+		cmd.SetErr(err)
+		return cmd
+	}
+	return evalSha(ctx, s.hash, keys, args...)
+}
+
+// Load 用 SCRIPT LOAD 加载脚本，集群客户端会加载到所有主节点
+func (s *Script) Load(ctx context.Context, c redis.Scripter) error {
+	hash, err := c.ScriptLoad(ctx, s.src).Result()
+	if err != nil {
+		return fmt.Errorf("script: failed to load %s: %w", s.Name(), err)
+	}
+	if hash != s.hash {
+		return fmt.Errorf("script: %s loaded with unexpected hash %s", s.Name(), hash)
+	}
+	return nil
+}
+
+// Bundle 一个模块的脚本包，模块在包级变量中注册自己用到的所有脚本
+type Bundle struct {
+	name string
+
+	mu      sync.RWMutex
+	scripts map[string]*Script
+}
+
+var (
+	bundlesMu sync.RWMutex
+	// bundles 所有创建过的脚本包，按名称索引
+	bundles = make(map[string]*Bundle)
+)
+
+// NewBundle 创建名为name的脚本包，通常以模块名命名；同名的脚本包只能创建一次
+func NewBundle(name string) *Bundle {
+	bundlesMu.Lock()
+	defer bundlesMu.Unlock()
+
+	if _, ok := bundles[name]; ok {
+		panic("script: duplicate bundle " + name)
+	}
+	b := &Bundle{name: name, scripts: make(map[string]*Script)}
+	bundles[name] = b
+	return b
+}
+
+// Register 在脚本包中注册一个脚本，同一个包中的脚本名不能重复
+// 注册时只计算SHA1，不访问Redis，脚本在第一次执行时才加载
+func (b *Bundle) Register(name, src string) *Script {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.scripts[name]; ok {
+		panic("script: duplicate script " + b.name + "/" + name)
+	}
+	sum := sha1.Sum([]byte(src))
+	s := &Script{bundle: b.name, name: name, src: src, hash: hex.EncodeToString(sum[:])}
+	b.scripts[name] = s
+	return s
+}
+
+// Name 返回脚本包的名称
+func (b *Bundle) Name() string {
+	return b.name
+}
+
+// Get 按名称查找脚本
+func (b *Bundle) Get(name string) (*Script, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	s, ok := b.scripts[name]
+	return s, ok
+}
+
+// Scripts 返回包中的所有脚本，按名称排序
+func (b *Bundle) Scripts() []*Script {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	scripts := make([]*Script, 0, len(b.scripts))
+	for _, s := range b.scripts {
+		scripts = append(scripts, s)
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].name < scripts[j].name })
+	return scripts
+}
+
+// Load 预先加载包中的所有脚本，在管道中执行脚本之前或服务启动时调用
+func (b *Bundle) Load(ctx context.Context, c redis.Scripter) error {
+	var errs []error
+	for _, s := range b.Scripts() {
+		if err := s.Load(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Missing 检查包中的脚本是否都已经加载，返回没有加载的脚本
+func (b *Bundle) Missing(ctx context.Context, c redis.Scripter) ([]*Script, error) {
+	scripts := b.Scripts()
+	if len(scripts) == 0 {
+		return nil, nil
+	}
+	hashes := make([]string, len(scripts))
+	for i, s := range scripts {
+		hashes[i] = s.hash
+	}
+	exists, err := c.ScriptExists(ctx, hashes...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("script: failed to check bundle %s: %w", b.name, err)
+	}
+
+	var missing []*Script
+	for i, ok := range exists {
+		if !ok {
+			missing = append(missing, scripts[i])
+		}
+	}
+	return missing, nil
+}
+
+// Bundles 返回所有脚本包，按名称排序
+func Bundles() []*Bundle {
+	bundlesMu.RLock()
+	defer bundlesMu.RUnlock()
+
+	list := make([]*Bundle, 0, len(bundles))
+	for _, b := range bundles {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// LoadAll 加载所有脚本包中的脚本
+func LoadAll(ctx context.Context, c redis.Scripter) error {
+	var errs []error
+	for _, b := range Bundles() {
+		if err := b.Load(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
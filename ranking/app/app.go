@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"ranking/internal/handlers"
+	"ranking/pkg/ranking"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 运行热度排名服务，后台定期重新计分，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("ranking")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "ranking name")
+	algorithm := fs.String("algorithm", ranking.DefaultConfig.Algorithm, "hot score algorithm: hacker_news or reddit")
+	gravity := fs.Float64("gravity", ranking.DefaultConfig.Gravity, "decay gravity of the hacker_news algorithm")
+	window := fs.Duration("window", ranking.DefaultConfig.Window, "only items submitted within this window appear in the hot feed")
+	interval := fs.Duration("rescore-interval", ranking.DefaultConfig.RescoreInterval, "interval between background rescoring passes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !slices.Contains(ranking.Algorithms, *algorithm) {
+		return fmt.Errorf("unknown algorithm %q, supported: %v", *algorithm, ranking.Algorithms)
+	}
+	if *interval <= 0 || *window <= 0 {
+		return fmt.Errorf("window and rescore-interval must be positive")
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	config := ranking.DefaultConfig
+	config.Algorithm = *algorithm
+	config.Gravity = *gravity
+	config.Window = *window
+	config.RescoreInterval = *interval
+	r := ranking.NewRanking(client, *name, config)
+
+	rescorer := ranking.NewRescorer(r)
+	defer rescorer.Close()
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	handlers.NewRankingHandler(r).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
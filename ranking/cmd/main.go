@@ -0,0 +1,11 @@
+package main
+
+import (
+	"ranking/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("ranking", app.Run)
+}
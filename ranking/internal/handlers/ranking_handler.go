@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"ranking/pkg/ranking"
+)
+
+// RankingHandler 处理排名相关的HTTP请求
+type RankingHandler struct {
+	ranking *ranking.Ranking
+}
+
+// NewRankingHandler 创建一个新的排名处理器
+func NewRankingHandler(r *ranking.Ranking) *RankingHandler {
+	return &RankingHandler{ranking: r}
+}
+
+// Setup 设置所有路由
+func (h *RankingHandler) Setup(router *gin.Engine) {
+	api := router.Group("/ranking")
+	{
+		// 热门、最新、最高票列表
+		api.GET("/hot", h.Hot)
+		api.GET("/new", h.New)
+		api.GET("/top", h.Top)
+		// 提交条目
+		api.POST("/items", h.Submit)
+		// 查询、删除条目
+		api.GET("/items/:id", h.Get)
+		api.DELETE("/items/:id", h.Remove)
+		// 投票
+		api.POST("/items/:id/vote", h.Vote)
+		// 立即重新计算热度分数
+		api.POST("/rescore", h.Rescore)
+	}
+}
+
+// Submit 提交条目
+func (h *RankingHandler) Submit(c *gin.Context) {
+	var req struct {
+		Title  string `json:"title" form:"title" binding:"required"`
+		URL    string `json:"url" form:"url"`
+		Author string `json:"author" form:"author" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	item, err := h.ranking.Submit(c.Request.Context(), req.Title, req.URL, req.Author)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit item: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+// Get 返回条目
+func (h *RankingHandler) Get(c *gin.Context) {
+	item, err := h.ranking.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ranking.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get item: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// Remove 删除条目
+func (h *RankingHandler) Remove(c *gin.Context) {
+	if err := h.ranking.Remove(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Vote 为条目投票，dir为1赞成、-1反对、0取消
+func (h *RankingHandler) Vote(c *gin.Context) {
+	var req struct {
+		User string `json:"user" form:"user" binding:"required"`
+		Dir  *int   `json:"dir" form:"dir" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	votes, err := h.ranking.Vote(c.Request.Context(), id, req.User, *req.Dir)
+	switch {
+	case errors.Is(err, ranking.ErrInvalidVote):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vote, dir must be 1, -1 or 0"})
+	case errors.Is(err, ranking.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to vote: " + err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"id": id, "votes": votes})
+	}
+}
+
+// Hot 返回热门列表
+func (h *RankingHandler) Hot(c *gin.Context) {
+	h.feed(c, h.ranking.Hot)
+}
+
+// New 返回最新列表
+func (h *RankingHandler) New(c *gin.Context) {
+	h.feed(c, h.ranking.New)
+}
+
+// Top 返回最高票列表
+func (h *RankingHandler) Top(c *gin.Context) {
+	h.feed(c, h.ranking.Top)
+}
+
+// Rescore 立即重新计算热度分数，返回重新计分和移出的条目数
+func (h *RankingHandler) Rescore(c *gin.Context) {
+	result, err := h.ranking.Rescore(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rescore: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// feed 按 offset 和 limit 参数返回一页列表，默认从头开始的30个
+func (h *RankingHandler) feed(c *gin.Context, list func(ctx context.Context, offset, limit int64) ([]ranking.Item, error)) {
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "30"), 10, 64)
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit, must be between 1 and 100"})
+		return
+	}
+
+	items, err := list(c.Request.Context(), offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list items: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"offset": offset, "items": items})
+}
@@ -0,0 +1,297 @@
+package ranking
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// AlgorithmHackerNews Hacker News的热度公式：票数 / (发布后的小时数 + 2)^Gravity
+	// 分数随时间衰减，需要定期重新计算
+	AlgorithmHackerNews = "hacker_news"
+	// AlgorithmReddit Reddit的热度公式：log10(票数) + 发布时间 / 45000秒
+	// 发布时间越晚基础分越高，相当于所有旧条目一起衰减，条目之间的先后只在投票时改变，不需要重新计算
+	AlgorithmReddit = "reddit"
+)
+
+// Algorithms 支持的全部热度算法
+var Algorithms = []string{AlgorithmHackerNews, AlgorithmReddit}
+
+const (
+	// redditEpoch Reddit公式中计算发布时间的起点（2005-12-08），减小分数的数值
+	redditEpoch = "1134028003"
+	// redditPeriod 发布时间每晚这么多秒，基础分加1，与票数多10倍的效果相同
+	redditPeriod = "45000"
+)
+
+var (
+	// ErrNotFound 条目不存在
+	ErrNotFound = errors.New("ranking: item not found")
+	// ErrInvalidItem 条目缺少标题或作者
+	ErrInvalidItem = errors.New("ranking: invalid item")
+	// ErrInvalidVote 用户ID为空或投票方向不是1、-1、0
+	ErrInvalidVote = errors.New("ranking: invalid vote")
+)
+
+// Config 排名配置
+type Config struct {
+	// Redis中各个key的前缀，完整的前缀为 KeyPrefix + "{排名名}:"
+	KeyPrefix string
+	// 热度算法，AlgorithmHackerNews 或 AlgorithmReddit
+	Algorithm string
+	// Hacker News公式中的重力，越大旧条目衰减得越快
+	Gravity float64
+	// 只有发布时间在这段时间之内的条目出现在热门列表中，更早的条目在重新计分时移出
+	Window time.Duration
+	// 后台重新计算热度分数的间隔
+	RescoreInterval time.Duration
+	// 重新计分时每次脚本调用处理的条目数，避免单个脚本长时间阻塞Redis
+	RescoreBatch int64
+}
+
+// DefaultConfig 默认排名配置
+var DefaultConfig = Config{
+	KeyPrefix:       "rank:",
+	Algorithm:       AlgorithmHackerNews,
+	Gravity:         1.8,
+	Window:          48 * time.Hour,
+	RescoreInterval: time.Minute,
+	RescoreBatch:    500,
+}
+
+// Item 一个参与排名的条目，如一篇文章或一个链接
+type Item struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	URL    string `json:"url,omitempty"`
+	Author string `json:"author"`
+	// 赞成票减去反对票
+	Votes     int64     `json:"votes"`
+	CreatedAt time.Time `json:"created_at"`
+	// 热度分数，只在热门列表中返回
+	Score float64 `json:"score,omitempty"`
+}
+
+// submitScript 提交条目：分配ID，保存条目，并加入最新、最高票和热门三个列表
+// KEYS: ID计数器, 最新列表, 最高票列表, 热门列表
+// ARGV: 标题, 链接, 作者, 当前时间（秒）, 算法, 重力, 条目key的前缀
+// 返回条目ID
+var submitScript = scripts.Register("submit", scoreFunction+`
+local id = redis.call('INCR', KEYS[1])
+local now = tonumber(ARGV[4])
+redis.call('HSET', ARGV[7] .. id, 'title', ARGV[1], 'url', ARGV[2], 'author', ARGV[3],
+	'votes', 0, 'created_at', now)
+redis.call('ZADD', KEYS[2], now, id)
+redis.call('ZADD', KEYS[3], 0, id)
+redis.call('ZADD', KEYS[4], hotScore(ARGV[5], tonumber(ARGV[6]), 0, now, now), id)
+return id
+`)
+
+// voteScript 记录用户的投票并更新票数和热度分数，用户改票时只计入差值，重复投同样的票不改变票数
+// KEYS: 条目, 条目的投票记录, 最高票列表, 热门列表
+// ARGV: 用户ID, 方向(1赞成/-1反对/0取消), 条目ID, 当前时间（秒）, 算法, 重力, 热门窗口（秒）
+// 返回投票后的票数，条目不存在时返回错误
+var voteScript = scripts.Register("vote", scoreFunction+`
+local created = tonumber(redis.call('HGET', KEYS[1], 'created_at'))
+if not created then
+	return redis.error_reply('item not found')
+end
+local old = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+local dir = tonumber(ARGV[2])
+if old == dir then
+	return tonumber(redis.call('HGET', KEYS[1], 'votes'))
+end
+if dir == 0 then
+	redis.call('HDEL', KEYS[2], ARGV[1])
+else
+	redis.call('HSET', KEYS[2], ARGV[1], dir)
+end
+
+local votes = redis.call('HINCRBY', KEYS[1], 'votes', dir - old)
+redis.call('ZADD', KEYS[3], votes, ARGV[3])
+local now = tonumber(ARGV[4])
+if now - created < tonumber(ARGV[7]) then
+	redis.call('ZADD', KEYS[4], hotScore(ARGV[5], tonumber(ARGV[6]), votes, created, now), ARGV[3])
+end
+return votes
+`)
+
+// Ranking 基于有序集合的时间衰减排名，提供热门、最新和最高票三种列表
+//
+// 排行榜（leaderboard 模块）的分数只在加分时改变；热度分数还取决于条目的发布时间，
+// Hacker News公式中同样的票数随时间推移分数越来越低，有序集合中保存的分数会过时，
+// 需要后台定期用同一个公式重新计算（见 Rescore 和 Rescorer）。投票时在Lua脚本中立即重新计算该条目的分数
+type Ranking struct {
+	client redis.UniversalClient
+	config Config
+	name   string
+
+	seqKey  string
+	newKey  string
+	topKey  string
+	hotKey  string
+	itemKey string
+	voteKey string
+}
+
+// NewRanking 创建名为name的排名，同一个排名的key使用相同的hash tag，在集群中位于同一个slot
+func NewRanking(client redis.UniversalClient, name string, config Config) *Ranking {
+	prefix := config.KeyPrefix + "{" + name + "}:"
+	return &Ranking{
+		client:  client,
+		config:  config,
+		name:    name,
+		seqKey:  prefix + "seq",
+		newKey:  prefix + "new",
+		topKey:  prefix + "top",
+		hotKey:  prefix + "hot",
+		itemKey: prefix + "item:",
+		voteKey: prefix + "votes:",
+	}
+}
+
+// NewDefaultRanking 使用默认配置创建排名
+func NewDefaultRanking(client redis.UniversalClient, name string) *Ranking {
+	return NewRanking(client, name, DefaultConfig)
+}
+
+// Name 返回排名名
+func (r *Ranking) Name() string {
+	return r.name
+}
+
+// Config 返回排名配置
+func (r *Ranking) Config() Config {
+	return r.config
+}
+
+// Submit 提交条目，返回分配了ID和发布时间的条目
+func (r *Ranking) Submit(ctx context.Context, title, url, author string) (Item, error) {
+	if title == "" || author == "" {
+		return Item{}, ErrInvalidItem
+	}
+	now := time.Now().Unix()
+	id, err := submitScript.Run(ctx, r.client, []string{r.seqKey, r.newKey, r.topKey, r.hotKey},
+		title, url, author, now, r.config.Algorithm, r.config.Gravity, r.itemKey).Int64()
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{
+		ID:        strconv.FormatInt(id, 10),
+		Title:     title,
+		URL:       url,
+		Author:    author,
+		CreatedAt: time.Unix(now, 0),
+	}, nil
+}
+
+// Vote 用户为条目投票，dir为1赞成、-1反对、0取消之前的投票，返回投票后的票数
+// 每个用户对一个条目只有一票，改票时票数按差值变化
+func (r *Ranking) Vote(ctx context.Context, id, userID string, dir int) (int64, error) {
+	if userID == "" || dir < -1 || dir > 1 {
+		return 0, ErrInvalidVote
+	}
+	votes, err := voteScript.Run(ctx, r.client,
+		[]string{r.itemKey + id, r.voteKey + id, r.topKey, r.hotKey},
+		userID, dir, id, time.Now().Unix(), r.config.Algorithm, r.config.Gravity,
+		int64(r.config.Window.Seconds())).Int64()
+	if err != nil && strings.Contains(err.Error(), "item not found") {
+		return 0, ErrNotFound
+	}
+	return votes, err
+}
+
+// Get 返回条目，不存在时返回 ErrNotFound
+func (r *Ranking) Get(ctx context.Context, id string) (Item, error) {
+	fields, err := r.client.HGetAll(ctx, r.itemKey+id).Result()
+	if err != nil {
+		return Item{}, err
+	}
+	if len(fields) == 0 {
+		return Item{}, ErrNotFound
+	}
+	return parseItem(id, fields), nil
+}
+
+// Remove 删除条目和它的投票记录，并从所有列表中移除
+func (r *Ranking) Remove(ctx context.Context, id string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.itemKey+id, r.voteKey+id)
+		pipe.ZRem(ctx, r.newKey, id)
+		pipe.ZRem(ctx, r.topKey, id)
+		pipe.ZRem(ctx, r.hotKey, id)
+		return nil
+	})
+	return err
+}
+
+// Hot 按热度分数从高到低返回热门列表，只包含 Window 之内发布的条目
+func (r *Ranking) Hot(ctx context.Context, offset, limit int64) ([]Item, error) {
+	return r.feed(ctx, r.hotKey, offset, limit, true)
+}
+
+// New 按发布时间从新到旧返回条目
+func (r *Ranking) New(ctx context.Context, offset, limit int64) ([]Item, error) {
+	return r.feed(ctx, r.newKey, offset, limit, false)
+}
+
+// Top 按票数从高到低返回所有条目，不考虑发布时间
+func (r *Ranking) Top(ctx context.Context, offset, limit int64) ([]Item, error) {
+	return r.feed(ctx, r.topKey, offset, limit, false)
+}
+
+// feed 从有序集合中按分数从高到低取出一页条目ID，再读取条目的内容
+// withScore 为true时在条目中返回有序集合中的分数；读取过程中被删除的条目会被跳过
+func (r *Ranking) feed(ctx context.Context, key string, offset, limit int64, withScore bool) ([]Item, error) {
+	if limit <= 0 {
+		return []Item{}, nil
+	}
+	members, err := r.client.ZRevRangeWithScores(ctx, key, offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(members))
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, member := range members {
+			cmds[i] = pipe.HGetAll(ctx, r.itemKey+member.Member.(string))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(members))
+	for i, member := range members {
+		fields := cmds[i].Val()
+		if len(fields) == 0 {
+			continue
+		}
+		item := parseItem(member.Member.(string), fields)
+		if withScore {
+			item.Score = member.Score
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseItem 从条目的hash中解析条目
+func parseItem(id string, fields map[string]string) Item {
+	votes, _ := strconv.ParseInt(fields["votes"], 10, 64)
+	created, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	return Item{
+		ID:        id,
+		Title:     fields["title"],
+		URL:       fields["url"],
+		Author:    fields["author"],
+		Votes:     votes,
+		CreatedAt: time.Unix(created, 0),
+	}
+}
@@ -0,0 +1,130 @@
+package ranking
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// rescoreScript 重新计算一批条目的热度分数，超过热门窗口或已经被删除的条目移出热门列表
+// KEYS: 热门列表
+// ARGV: 当前时间（秒）, 算法, 重力, 热门窗口（秒）, 条目key的前缀, 之后每个参数是一个条目ID
+// 返回 {重新计分的条目数, 移出的条目数}
+var rescoreScript = scripts.Register("rescore", scoreFunction+`
+local now = tonumber(ARGV[1])
+local gravity = tonumber(ARGV[3])
+local window = tonumber(ARGV[4])
+local updated, removed = 0, 0
+for i = 6, #ARGV do
+	local item = redis.call('HMGET', ARGV[5] .. ARGV[i], 'votes', 'created_at')
+	local created = tonumber(item[2])
+	if not created or now - created >= window then
+		redis.call('ZREM', KEYS[1], ARGV[i])
+		removed = removed + 1
+	else
+		redis.call('ZADD', KEYS[1], hotScore(ARGV[2], gravity, tonumber(item[1]), created, now), ARGV[i])
+		updated = updated + 1
+	end
+end
+return {updated, removed}
+`)
+
+// RescoreResult 一次重新计分的结果
+type RescoreResult struct {
+	// 重新计算了分数的条目数
+	Updated int64 `json:"updated"`
+	// 超过热门窗口被移出热门列表的条目数
+	Removed int64 `json:"removed"`
+}
+
+// Rescore 用当前时间重新计算热门列表中所有条目的热度分数，并移出发布时间超过 Window 的条目
+//
+// 用 ZSCAN 遍历热门列表，每批 RescoreBatch 个条目在一个脚本中计算，计分过程中分数改变不影响遍历；
+// 热门列表只包含窗口内的条目，每次重新计分的开销不随条目总数增长。
+// 多个实例同时重新计分时结果相同，只是重复计算
+func (r *Ranking) Rescore(ctx context.Context) (RescoreResult, error) {
+	var result RescoreResult
+	now := time.Now().Unix()
+	window := int64(r.config.Window.Seconds())
+
+	var cursor uint64
+	for {
+		members, next, err := r.client.ZScan(ctx, r.hotKey, cursor, "", r.config.RescoreBatch).Result()
+		if err != nil {
+			return result, err
+		}
+		// ZSCAN返回的是成员和分数交替的列表
+		if len(members) > 0 {
+			args := []any{now, r.config.Algorithm, r.config.Gravity, window, r.itemKey}
+			for i := 0; i < len(members); i += 2 {
+				args = append(args, members[i])
+			}
+			counts, err := rescoreScript.Run(ctx, r.client, []string{r.hotKey}, args...).Int64Slice()
+			if err != nil {
+				return result, err
+			}
+			result.Updated += counts[0]
+			result.Removed += counts[1]
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return result, nil
+		}
+	}
+}
+
+// Rescorer 每隔 RescoreInterval 在后台重新计算一次热度分数
+type Rescorer struct {
+	ranking *Ranking
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRescorer 创建并启动后台重新计分
+func NewRescorer(ranking *Ranking) *Rescorer {
+	ctx, cancel := context.WithCancel(context.Background())
+	rs := &Rescorer{
+		ranking: ranking,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go rs.run(ctx)
+
+	return rs
+}
+
+// Close 停止后台重新计分，等待正在进行的一次完成
+func (rs *Rescorer) Close() error {
+	rs.cancel()
+	<-rs.done
+	return nil
+}
+
+// run 定期重新计分直到关闭
+func (rs *Rescorer) run(ctx context.Context) {
+	defer close(rs.done)
+
+	ticker := time.NewTicker(rs.ranking.config.RescoreInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := rs.ranking.Rescore(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Error rescoring ranking %s: %v", rs.ranking.name, err)
+				}
+				continue
+			}
+			if result.Removed > 0 {
+				log.Printf("Rescored %d items of ranking %s, %d expired items removed from hot feed",
+					result.Updated, rs.ranking.name, result.Removed)
+			}
+		}
+	}
+}
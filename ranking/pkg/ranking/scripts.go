@@ -0,0 +1,24 @@
+package ranking
+
+import "redis-learning/pkg/script"
+
+// scripts 排名使用的Lua脚本，以 EVALSHA 执行，Redis中没有脚本时自动加载
+var scripts = script.NewBundle("ranking")
+
+// scoreFunction 计算热度分数的Lua函数，提交、投票和重新计分的脚本共用，保证各处的分数一致
+// 脚本返回的数字会被Redis截断为整数，分数只通过 ZADD 写入有序集合，不作为返回值
+const scoreFunction = `
+local function hotScore(algorithm, gravity, votes, created, now)
+	if algorithm == '` + AlgorithmReddit + `' then
+		local sign = 0
+		if votes > 0 then
+			sign = 1
+		elseif votes < 0 then
+			sign = -1
+		end
+		return sign * math.log10(math.max(math.abs(votes), 1)) + (created - ` + redditEpoch + `) / ` + redditPeriod + `
+	end
+	local hours = math.max(now - created, 0) / 3600
+	return votes / math.pow(hours + 2, gravity)
+end
+`
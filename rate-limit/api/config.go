@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"rate-limit/pkg/limiter"
+
+	sharedconfig "redis-learning/pkg/config"
+	"redis-learning/pkg/redisclient"
+)
+
+// EnvPrefix 环境变量的前缀，变量名由前缀和配置项的YAML路径组成，如 RATELIMIT_REDIS_ADDR、RATELIMIT_HOT_KEY_WINDOW
+const EnvPrefix = "RATELIMIT"
+
+// LoadConfig 加载服务器配置：以 DefaultServerConfig 为基础，依次应用YAML配置文件和环境变量，后者优先
+// path为空时不读取配置文件；配置文件中出现未知的配置项时返回错误，避免拼写错误被忽略
+func LoadConfig(path string) (ServerConfig, error) {
+	config := DefaultServerConfig
+	config.TrustedProxies = slices.Clone(DefaultServerConfig.TrustedProxies)
+
+	// 不在加载时检查，命令行参数还会覆盖部分配置，由 NewServerWithConfig 检查最终的配置
+	if err := sharedconfig.LoadFile(path, &config); err != nil {
+		return config, err
+	}
+	if err := sharedconfig.ApplyEnv(&config, EnvPrefix); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// Validate 检查配置是否有效，返回所有无效的配置项
+func (c ServerConfig) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Port != "", "port is required")
+	check(c.Storage == StorageRedis || c.Storage == StorageMemory, "storage must be one of %s, %s: %q", StorageRedis, StorageMemory, c.Storage)
+	check(c.Storage == StorageMemory || c.Redis.Addr != "", "redis.addr is required")
+	// 热点key从副本读取、限流脚本共用连接都依赖单节点客户端
+	check(c.Redis.Mode == "" || c.Redis.Mode == redisclient.ModeStandalone, "redis.mode must be %s: %q",
+		redisclient.ModeStandalone, c.Redis.Mode)
+	check(c.Redis.PoolSize >= 0, "redis.pool_size must not be negative: %d", c.Redis.PoolSize)
+	check(c.Redis.DB >= 0, "redis.db must not be negative: %d", c.Redis.DB)
+	check(c.Redis.Breaker.FailureThreshold >= 0, "redis.breaker.failure_threshold must not be negative: %d", c.Redis.Breaker.FailureThreshold)
+	check(c.Redis.Breaker.FailureThreshold == 0 || c.Redis.Breaker.OpenTimeout > 0,
+		"redis.breaker.open_timeout must be positive: %v", c.Redis.Breaker.OpenTimeout)
+	check(c.HotKey.Threshold > 0, "hot_key.threshold must be positive: %d", c.HotKey.Threshold)
+	check(c.HotKey.Window > 0, "hot_key.window must be positive: %v", c.HotKey.Window)
+	check(c.HotKey.HotKeyExpiration > 0, "hot_key.expiration must be positive: %v", c.HotKey.HotKeyExpiration)
+	check(c.HotKey.Buckets >= 0, "hot_key.buckets must not be negative: %d", c.HotKey.Buckets)
+	check(c.HotKey.TopK >= 0, "hot_key.top_k must not be negative: %d", c.HotKey.TopK)
+	for i, window := range c.HotKey.Windows {
+		check(window.Window > 0, "hot_key.windows[%d].window must be positive: %v", i, window.Window)
+		check(window.Threshold > 0, "hot_key.windows[%d].threshold must be positive: %d", i, window.Threshold)
+	}
+	check(c.HotKey.StatsMaxKeys >= 0, "hot_key.stats_max_keys must not be negative: %d", c.HotKey.StatsMaxKeys)
+	check(c.HotKey.StatsSampleRate >= 0 && c.HotKey.StatsSampleRate <= 1,
+		"hot_key.stats_sample_rate must be between 0 and 1: %v", c.HotKey.StatsSampleRate)
+	check(slices.Contains(limiter.Algorithms, c.LimiterAlgorithm), "limiter_algorithm must be one of %s: %q",
+		strings.Join(limiter.Algorithms, ", "), c.LimiterAlgorithm)
+	check(c.LimiterRate >= 0, "limiter_rate must not be negative: %v", c.LimiterRate)
+	check(c.LimiterRate == 0 || c.LimiterBurst > 0, "limiter_burst must be positive when limiter_rate is set: %d", c.LimiterBurst)
+	check(c.LimiterMaxWait >= 0, "limiter_max_wait must not be negative: %v", c.LimiterMaxWait)
+	check(c.ResponseCacheMaxAge >= 0, "response_cache_max_age must not be negative: %v", c.ResponseCacheMaxAge)
+	check(c.GlobalRate >= 0, "global_rate must not be negative: %v", c.GlobalRate)
+	check(c.GlobalRate == 0 || c.GlobalBurst > 0, "global_burst must be positive when global_rate is set: %d", c.GlobalBurst)
+	for i, limit := range c.PrefixLimits {
+		check(strings.TrimSuffix(limit.Prefix, "*") != "", "prefix_limits[%d].prefix is required", i)
+		check(limit.RatePerSecond > 0, "prefix_limits[%d].rate must be positive: %v", i, limit.RatePerSecond)
+		check(limit.Burst > 0, "prefix_limits[%d].burst must be positive: %d", i, limit.Burst)
+	}
+	for i, key := range c.PrewarmKeys {
+		check(key.Key != "", "prewarm_keys[%d].key is required", i)
+		check(key.RatePerSecond >= 0, "prewarm_keys[%d].rate must not be negative: %v", i, key.RatePerSecond)
+		check(key.RatePerSecond == 0 || key.Burst > 0, "prewarm_keys[%d].burst must be positive when rate is set: %d", i, key.Burst)
+	}
+	check(c.MaxInFlight >= 0, "max_in_flight must not be negative: %d", c.MaxInFlight)
+	if c.PenaltyBox {
+		check(c.PenaltyThreshold > 0, "penalty_threshold must be positive: %d", c.PenaltyThreshold)
+		check(c.PenaltyWindow > 0, "penalty_window must be positive: %v", c.PenaltyWindow)
+		check(c.PenaltyDuration > 0, "penalty_duration must be positive: %v", c.PenaltyDuration)
+	}
+	check(c.SetDefaultTTL >= minSetTTL, "set_default_ttl must be at least %v: %v", minSetTTL, c.SetDefaultTTL)
+	check(c.SetMaxTTL >= c.SetDefaultTTL, "set_max_ttl must not be less than set_default_ttl: %v", c.SetMaxTTL)
+	check(c.ShutdownTimeout >= 0, "shutdown_timeout must not be negative: %v", c.ShutdownTimeout)
+	check(c.CacheTTL > 0, "cache_ttl must be positive: %v", c.CacheTTL)
+	check(c.CacheTTLJitter >= 0 && c.CacheTTLJitter < 1, "cache_ttl_jitter must be at least 0 and less than 1: %v", c.CacheTTLJitter)
+	if c.CacheRefresh {
+		// 按热度调整过期时间时最短为cache_min_ttl，再减去抖动；提前刷新的时间需要小于它，否则每次检查都会刷新
+		minTTL := c.CacheTTL
+		if c.AdaptiveCacheTTL {
+			minTTL = c.CacheMinTTL
+		}
+		minTTL = time.Duration(float64(minTTL) * (1 - c.CacheTTLJitter))
+		check(c.CacheRefreshAhead > 0 && c.CacheRefreshAhead < minTTL,
+			"cache_refresh_ahead must be positive and less than the shortest cache ttl %v: %v", minTTL, c.CacheRefreshAhead)
+	}
+	if c.AdaptiveCacheTTL {
+		check(c.CacheMinTTL > 0 && c.CacheMinTTL <= c.CacheTTL, "cache_min_ttl must be positive and not greater than cache_ttl: %v", c.CacheMinTTL)
+		check(c.CacheMaxTTL >= c.CacheTTL, "cache_max_ttl must not be less than cache_ttl: %v", c.CacheMaxTTL)
+	}
+	for route, limit := range c.RouteLimits.byRoute() {
+		check(limit.Rate >= 0, "route_limits.%s.rate must not be negative: %v", route, limit.Rate)
+		check(limit.Rate == 0 || limit.Burst > 0, "route_limits.%s.burst must be positive when rate is set: %d", route, limit.Burst)
+	}
+	check(c.IPRatePerSecond >= 0, "ip_rate must not be negative: %v", c.IPRatePerSecond)
+	check(c.IPRatePerSecond == 0 || c.IPBurstSize > 0, "ip_burst must be positive when ip_rate is set: %d", c.IPBurstSize)
+	if c.Storage == StorageMemory {
+		// 这些功能在Redis中保存状态，使用内存存储时不可用
+		check(c.LimiterAlgorithm == limiter.AlgorithmTokenBucket || c.LimiterAlgorithm == limiter.AlgorithmLeakyBucket,
+			"limiter_algorithm %q requires redis storage", c.LimiterAlgorithm)
+		for name, enabled := range map[string]bool{
+			"dynamic_limits":          c.DynamicLimits,
+			"cluster_hot_keys":        c.ClusterHotKeys,
+			"hot_key_ranking":         c.HotKeyRanking,
+			"broadcast_hot_keys":      c.BroadcastHotKeys,
+			"page_access_events":      c.PageAccessEvents,
+			"distributed_concurrency": c.DistributedConcurrency,
+			"require_api_key":         c.RequireAPIKey,
+			"penalty_box":             c.PenaltyBox,
+		} {
+			check(!enabled, "%s requires redis storage", name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rate-limit/pkg/detector"
+)
+
+// 事件流的心跳间隔，避免空闲的连接被代理断开
+const streamHeartbeat = 15 * time.Second
+
+// eventHub 把检测器的热点key事件分发给所有订阅的事件流连接
+// 连接处理不过来时丢弃事件而不是阻塞检测器，仪表盘可以通过 GET /hot-keys 重新同步
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan detector.HotKeyEvent]struct{}
+}
+
+// newEventHub 创建事件分发器
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan detector.HotKeyEvent]struct{})}
+}
+
+// subscribe 订阅事件，返回事件通道和取消订阅的函数
+func (h *eventHub) subscribe() (<-chan detector.HotKeyEvent, func()) {
+	ch := make(chan detector.HotKeyEvent, 64)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		h.mu.Unlock()
+	}
+}
+
+// publish 把事件发给所有订阅者，订阅者的缓冲已满时丢弃
+func (h *eventHub) publish(event detector.HotKeyEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// handleHotKeyStream 以Server-Sent Events推送热点key的变化：连接后先推送当前所有热点，
+// 之后每当key变成热点（event: hot）或冷却（event: cool）时推送一个事件，数据为 detector.HotKeyEvent 的JSON
+func (s *Server) handleHotKeyStream(c *gin.Context) {
+	events, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// 先订阅再推送当前的热点，两者之间发生的变化可能重复推送，但不会遗漏
+	for _, hotKey := range s.hotKeyDet.GetHotKeys() {
+		c.SSEvent(detector.EventHot, detector.HotKeyEvent{
+			Type:  detector.EventHot,
+			Key:   hotKey.Key,
+			Score: hotKey.Score,
+			Time:  hotKey.DetectedAt,
+		})
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			// 以冒号开头的行是SSE注释，客户端会忽略
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-s.stop:
+			return false
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"rate-limit/pkg/storage"
+)
+
+// maxMGetKeys 一次批量读取的最大key数量
+const maxMGetKeys = 100
+
+// mgetKeys 返回批量读取请求中的key，keys参数以逗号分隔
+func mgetKeys(c *gin.Context) []string {
+	var keys []string
+	for _, key := range strings.Split(c.Query("keys"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// mgetCost 批量读取按key的数量消耗限流额度，读取50个key与50次单独读取消耗的额度相同
+func mgetCost(c *gin.Context) int {
+	return len(mgetKeys(c))
+}
+
+// requestCost 返回请求消耗的限流额度，路由在 Server.routeCosts 中声明，未声明的路由为1
+func (s *Server) requestCost(c *gin.Context) int {
+	if cost, ok := s.routeCosts[c.FullPath()]; ok {
+		return max(cost(c), 1)
+	}
+	return 1
+}
+
+// handleMGet 批量读取key：热点key优先从本地缓存读取，其余的key通过一次MGET从Redis读取
+// 请求按key的数量计入按IP、路由和等级的限流额度，不再经过按热点key的限流
+func (s *Server) handleMGet(c *gin.Context) {
+	keys := mgetKeys(c)
+	if len(keys) == 0 || len(keys) > maxMGetKeys {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "keys must contain 1 to 100 comma-separated keys"})
+		return
+	}
+
+	values := make(map[string]string, len(keys))
+	hot := make(map[string]bool, len(keys))
+	var missing []string
+	for _, key := range keys {
+		hot[key] = s.hotKeyDet.RecordAccess(key)
+		s.metrics.ObserveRequest(s.keyClass(key), true)
+		if hot[key] {
+			value, found := s.localCache.Get(key)
+			s.metrics.ObserveCacheLookup(found)
+			if found {
+				s.observeHotKeyRead(key, sourceLocalCache)
+				values[key] = value
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+
+	if len(missing) > 0 {
+		fetched, err := s.store.MGet(missing...)
+		if errors.Is(err, storage.ErrCircuitOpen) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Redis is unavailable"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting %d keys from Redis: %v", len(missing), err)
+			s.metrics.ObserveRedisError()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get values from Redis"})
+			return
+		}
+		for i, key := range missing {
+			if hot[key] {
+				s.observeHotKeyRead(key, sourceOrigin)
+			}
+			if fetched[i] == "" {
+				continue
+			}
+			values[key] = fetched[i]
+			if hot[key] {
+				s.localCache.Set(key, fetched[i], s.cacheTTL(key))
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"values": values})
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"rate-limit/pkg/metrics"
+)
+
+// penaltyCheck 惩罚区检查的中间件：惩罚区中的key直接返回429，不再经过热点检测和限流，未启用惩罚区时不检查
+func (s *Server) penaltyCheck() gin.HandlerFunc {
+	if s.penaltyBox == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		key := c.Param("key")
+		remaining, boxed := s.penaltyBox.Boxed(key)
+		if !boxed {
+			c.Next()
+			return
+		}
+		if s.shadowed(c, key) {
+			log.Printf("Shadow limited request for key: %s (penalty)", key)
+			s.metrics.ObserveShadowLimited(metrics.LimitPenalty)
+			c.Next()
+			return
+		}
+
+		retryAfter := int(math.Ceil(remaining.Seconds()))
+		s.metrics.ObserveLimited(metrics.LimitPenalty)
+		s.metrics.ObserveRequest(s.keyClass(key), false)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Key is in the penalty box",
+			"limit":       "penalty",
+			"retry_after": retryAfter,
+		})
+	}
+}
+
+// penalize 记录热点key被限流了一次，未启用惩罚区时不做任何事
+func (s *Server) penalize(c *gin.Context, key string) {
+	if s.penaltyBox == nil {
+		return
+	}
+	s.penaltyBox.Penalize(c.Request.Context(), key)
+}
+
+// handlePenaltyBox 列出惩罚区中的key及其释放时间
+func (s *Server) handlePenaltyBox(c *gin.Context) {
+	boxed, err := s.penaltyBox.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load penalty box"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": boxed})
+}
+
+// handleReleasePenaltyBox 把key从惩罚区释放，其他实例最多在一个同步间隔后生效
+func (s *Server) handleReleasePenaltyBox(c *gin.Context) {
+	if err := s.penaltyBox.Release(c.Request.Context(), c.Param("key")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrewarmKey 预先声明的热点key，如秒杀开始前的商品
+type PrewarmKey struct {
+	Key string `yaml:"key" json:"key"`
+	// 自定义限流速率，为0时使用默认速率
+	RatePerSecond float64 `yaml:"rate" json:"rate,omitempty"`
+	// 自定义突发请求数，设置了速率时必须为正数
+	Burst int `yaml:"burst" json:"burst,omitempty"`
+}
+
+// prewarmKeys 预热的热点key集合
+type prewarmKeys struct {
+	mu   sync.RWMutex
+	keys map[string]PrewarmKey
+}
+
+// newPrewarmKeys 创建预热的热点key集合
+func newPrewarmKeys() *prewarmKeys {
+	return &prewarmKeys{keys: make(map[string]PrewarmKey)}
+}
+
+// add 加入预热的key，已存在时替换它的配置，返回原来的配置
+func (p *prewarmKeys) add(key PrewarmKey) (PrewarmKey, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old, exists := p.keys[key.Key]
+	p.keys[key.Key] = key
+	return old, exists
+}
+
+// remove 移除预热的key，返回它的配置
+func (p *prewarmKeys) remove(key string) (PrewarmKey, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old, exists := p.keys[key]
+	delete(p.keys, key)
+	return old, exists
+}
+
+// list 返回所有预热的key，按字典序排序
+func (p *prewarmKeys) list() []PrewarmKey {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	keys := make([]PrewarmKey, 0, len(p.keys))
+	for _, key := range p.keys {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b PrewarmKey) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return keys
+}
+
+// prewarm 预热key：立即标记为不会过期的热点，设置自定义限流速率，并从Redis读取值放入本地缓存
+// 返回值是否已放入本地缓存；读取失败或key不存在时只记录日志，key仍是热点，之后的请求照常从Redis读取
+func (s *Server) prewarm(key PrewarmKey) bool {
+	if old, exists := s.prewarmed.add(key); exists && old.RatePerSecond > 0 && key.RatePerSecond == 0 {
+		s.rateLimiter.ClearLimit(key.Key)
+	}
+	s.hotKeyDet.PinHotKey(key.Key)
+	if key.RatePerSecond > 0 {
+		s.rateLimiter.SetLimit(key.Key, key.RatePerSecond, key.Burst)
+	}
+
+	value, err := s.store.Get(key.Key)
+	if err != nil {
+		log.Printf("Failed to pre-load hot key %s: %v", key.Key, err)
+		return false
+	}
+	if value == "" {
+		log.Printf("Pre-warmed hot key not found: %s", key.Key)
+		return false
+	}
+	s.localCache.Set(key.Key, value, s.cacheTTL(key.Key))
+	return true
+}
+
+// unprewarm 取消key的预热，清除预热时设置的限流速率，热点标记之后按访问次数正常过期
+// key没有预热时返回false
+func (s *Server) unprewarm(key string) bool {
+	old, exists := s.prewarmed.remove(key)
+	if !exists {
+		return false
+	}
+	s.hotKeyDet.UnpinHotKey(key)
+	if old.RatePerSecond > 0 {
+		s.rateLimiter.ClearLimit(key)
+	}
+	return true
+}
+
+// prewarmConfigured 预热配置中声明的热点key，在服务器启动时调用
+func (s *Server) prewarmConfigured() {
+	if len(s.config.PrewarmKeys) == 0 {
+		return
+	}
+	cached := 0
+	for _, key := range s.config.PrewarmKeys {
+		if s.prewarm(key) {
+			cached++
+		}
+	}
+	log.Printf("Pre-warmed %d hot keys, %d loaded into local cache", len(s.config.PrewarmKeys), cached)
+}
+
+// handlePrewarmKeys 返回预热的热点key
+func (s *Server) handlePrewarmKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"keys": s.prewarmed.list()})
+}
+
+// handlePrewarmKey 预热key，rate和burst可选，只在本实例生效
+func (s *Server) handlePrewarmKey(c *gin.Context) {
+	key := PrewarmKey{Key: c.Param("key")}
+	if rate := c.PostForm("rate"); rate != "" {
+		var err error
+		key.RatePerSecond, err = strconv.ParseFloat(rate, 64)
+		if err != nil || key.RatePerSecond <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rate"})
+			return
+		}
+		key.Burst, err = strconv.Atoi(c.PostForm("burst"))
+		if err != nil || key.Burst <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid burst"})
+			return
+		}
+	}
+
+	cached := s.prewarm(key)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "cached": cached})
+}
+
+// handleUnprewarmKey 取消key的预热
+func (s *Server) handleUnprewarmKey(c *gin.Context) {
+	if !s.unprewarm(c.Param("key")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Key is not pre-warmed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
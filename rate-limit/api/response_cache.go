@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+// cachedResponse 序列化好的热点key响应
+type cachedResponse struct {
+	// 生成响应时的值，本地缓存中的值变化后重新生成
+	value string
+	body  []byte
+	etag  string
+}
+
+// responseCache 热点key的HTTP响应缓存：本地缓存命中时直接写出序列化好的JSON，
+// 不再为每个请求重复序列化；值没有变化的客户端通过If-None-Match重新验证，得到不带响应体的304
+type responseCache struct {
+	responses *cache.Cache
+	// Cache-Control的max-age，为0时要求客户端每次重新验证
+	maxAge time.Duration
+}
+
+// newResponseCache 创建响应缓存，响应在ttl后过期，下次命中时重新生成
+func newResponseCache(ttl, maxAge time.Duration) *responseCache {
+	return &responseCache{
+		responses: cache.New(ttl, time.Minute),
+		maxAge:    maxAge,
+	}
+}
+
+// get 返回热点key在本地缓存中的值对应的响应，没有缓存或值已变化时重新生成
+func (rc *responseCache) get(key, value string) (*cachedResponse, error) {
+	if cached, found := rc.responses.Get(key); found {
+		if resp := cached.(*cachedResponse); resp.value == value {
+			return resp, nil
+		}
+	}
+
+	body, err := json.Marshal(gin.H{"value": value, "source": "local_cache"})
+	if err != nil {
+		return nil, err
+	}
+	resp := &cachedResponse{value: value, body: body, etag: valueETag(value)}
+	rc.responses.SetDefault(key, resp)
+	return resp, nil
+}
+
+// delete 删除key的响应
+func (rc *responseCache) delete(key string) {
+	rc.responses.Delete(key)
+}
+
+// revalidate 设置ETag和Cache-Control，请求的If-None-Match与etag一致时写出304并返回true
+func (rc *responseCache) revalidate(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	if rc.maxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("max-age=%d", int(rc.maxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+
+	if etagMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// valueETag 由值计算弱ETag：同一个值来自本地缓存和Redis时响应体的source不同，但表示的内容相同
+func valueETag(value string) string {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	return fmt.Sprintf(`W/"%016x"`, h.Sum64())
+}
+
+// etagMatch 判断If-None-Match是否包含etag，按弱比较忽略W/前缀
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"rate-limit/pkg/limiter"
+	"rate-limit/pkg/metrics"
+)
+
+// 限流的路由名称，与 RouteLimits 的字段对应
+const (
+	routeGet     = "get"
+	routeSet     = "set"
+	routeStats   = "stats"
+	routeHotKeys = "hot_keys"
+)
+
+// RouteLimit 单个路由的限流配置，Rate为0时不限流
+type RouteLimit struct {
+	// 每个客户端IP每秒允许的请求数
+	Rate float64 `yaml:"rate"`
+	// 每个客户端IP允许的突发请求数
+	Burst int `yaml:"burst"`
+}
+
+// RouteLimits 各路由的限流配置，每个客户端IP在每个路由上各有一份额度，
+// 例如写入可以比读取严格得多，而不是所有路由共用按IP限流的额度
+type RouteLimits struct {
+	Get     RouteLimit `yaml:"get"`
+	Set     RouteLimit `yaml:"set"`
+	Stats   RouteLimit `yaml:"stats"`
+	HotKeys RouteLimit `yaml:"hot_keys"`
+}
+
+// byRoute 返回按路由名称索引的限流配置
+func (r RouteLimits) byRoute() map[string]RouteLimit {
+	return map[string]RouteLimit{
+		routeGet:     r.Get,
+		routeSet:     r.Set,
+		routeStats:   r.Stats,
+		routeHotKeys: r.HotKeys,
+	}
+}
+
+// newRouteLimiters 为设置了速率的路由创建限流器
+func newRouteLimiters(limits RouteLimits) map[string]limiter.Limiter {
+	limiters := make(map[string]limiter.Limiter)
+	for route, limit := range limits.byRoute() {
+		if limit.Rate > 0 {
+			limiters[route] = limiter.NewRateLimiter(limiter.RateLimiterConfig{
+				RatePerSecond: limit.Rate,
+				BurstSize:     limit.Burst,
+			})
+		}
+	}
+	return limiters
+}
+
+// routeLimit 按路由限流的中间件，限流的key为"路由:客户端IP"，路由没有设置速率时不限流
+func (s *Server) routeLimit(route string) gin.HandlerFunc {
+	routeLimiter, ok := s.routeLimiters[route]
+	if !ok {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return limiter.GinMiddleware(limiter.MiddlewareOptions{
+		Limiter: routeLimiter,
+		KeyFunc: func(c *gin.Context) string {
+			return route + ":" + c.ClientIP()
+		},
+		Cost:            s.requestCost,
+		Shadow:          s.shadowed,
+		OnShadowLimited: s.observeShadow(metrics.LimitRoute),
+		OnLimited: func(c *gin.Context) {
+			s.metrics.ObserveLimited(metrics.LimitRoute)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests to this route", "limit": "route", "route": route})
+		},
+	})
+}
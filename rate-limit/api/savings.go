@@ -0,0 +1,159 @@
+package api
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 热点key请求的来源
+const (
+	// sourceLocalCache 由本地缓存返回
+	sourceLocalCache = "local_cache"
+	// sourceCoalesced 与同一个key并发的请求共享了一次Redis读取
+	sourceCoalesced = "coalesced"
+	// sourceOrigin 由这个请求访问Redis读取
+	sourceOrigin = "origin"
+	// sourceRefresh 后台提前刷新时访问Redis读取，不对应请求
+	sourceRefresh = "refresh"
+)
+
+// KeySavings 热点key被本地缓存和请求合并挡住的Redis读取
+type KeySavings struct {
+	Key string `json:"key,omitempty"`
+	// 作为热点处理的请求数
+	Requests int64 `json:"requests"`
+	// 由本地缓存返回的请求数
+	CacheHits int64 `json:"cache_hits"`
+	// 共享了其他请求的Redis读取的请求数
+	Coalesced int64 `json:"coalesced"`
+	// 实际访问Redis的次数，包括提前刷新等不是由请求触发的读取
+	OriginFetches int64 `json:"origin_fetches"`
+	// 没有访问Redis的请求数，等于 Requests - OriginFetches，不小于0
+	Saved int64 `json:"saved"`
+	// Saved占Requests的比例
+	SavedRatio float64 `json:"saved_ratio"`
+}
+
+// keySavings 单个热点key的计数
+type keySavings struct {
+	requests      atomic.Int64
+	cacheHits     atomic.Int64
+	coalesced     atomic.Int64
+	originFetches atomic.Int64
+}
+
+// savingsTracker 按热点key统计请求数和实际访问Redis的次数，量化本地缓存和请求合并减少的Redis读取
+// 热点标记过期时删除该key的计数，合计值一直保留
+type savingsTracker struct {
+	mu    sync.RWMutex
+	keys  map[string]*keySavings
+	total keySavings
+}
+
+// newSavingsTracker 创建热点key的节省统计
+func newSavingsTracker() *savingsTracker {
+	return &savingsTracker{keys: make(map[string]*keySavings)}
+}
+
+// served 记录一个热点key的请求，source为请求的来源
+func (t *savingsTracker) served(key, source string) {
+	for _, counts := range []*keySavings{t.get(key), &t.total} {
+		counts.requests.Add(1)
+		switch source {
+		case sourceLocalCache:
+			counts.cacheHits.Add(1)
+		case sourceCoalesced:
+			counts.coalesced.Add(1)
+		case sourceOrigin:
+			counts.originFetches.Add(1)
+		}
+	}
+}
+
+// fetched 记录一次不是由请求触发的Redis读取，如提前刷新
+func (t *savingsTracker) fetched(key string) {
+	t.get(key).originFetches.Add(1)
+	t.total.originFetches.Add(1)
+}
+
+// get 返回key的计数，不存在时创建
+func (t *savingsTracker) get(key string) *keySavings {
+	t.mu.RLock()
+	counts, exists := t.keys[key]
+	t.mu.RUnlock()
+	if exists {
+		return counts
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if counts, exists = t.keys[key]; !exists {
+		counts = &keySavings{}
+		t.keys[key] = counts
+	}
+	return counts
+}
+
+// remove 删除key的计数
+func (t *savingsTracker) remove(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.keys, key)
+}
+
+// list 返回各热点key的统计，按节省的Redis读取从多到少排序，n大于0时只返回前n个
+func (t *savingsTracker) list(n int) []KeySavings {
+	t.mu.RLock()
+	list := make([]KeySavings, 0, len(t.keys))
+	for key, counts := range t.keys {
+		list = append(list, counts.snapshot(key))
+	}
+	t.mu.RUnlock()
+
+	slices.SortFunc(list, func(a, b KeySavings) int {
+		if a.Saved != b.Saved {
+			return cmp.Compare(b.Saved, a.Saved)
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// snapshot 返回计数的快照
+func (k *keySavings) snapshot(key string) KeySavings {
+	s := KeySavings{
+		Key:           key,
+		Requests:      k.requests.Load(),
+		CacheHits:     k.cacheHits.Load(),
+		Coalesced:     k.coalesced.Load(),
+		OriginFetches: k.originFetches.Load(),
+	}
+	s.Saved = max(s.Requests-s.OriginFetches, 0)
+	if s.Requests > 0 {
+		s.SavedRatio = float64(s.Saved) / float64(s.Requests)
+	}
+	return s
+}
+
+// handleHotKeySavings 返回各热点key被本地缓存和请求合并挡住的Redis读取，?n=指定数量，默认返回全部
+func (s *Server) handleHotKeySavings(c *gin.Context) {
+	n, err := strconv.Atoi(c.DefaultQuery("n", "0"))
+	if err != nil || n < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid n"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  s.savings.list(n),
+		"total": s.savings.total.snapshot(""),
+	})
+}
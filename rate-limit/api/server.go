@@ -1,87 +1,595 @@
 package api
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
 	"log"
+	"math/rand/v2"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 
 	"rate-limit/pkg/cache"
 	"rate-limit/pkg/detector"
 	"rate-limit/pkg/limiter"
+	"rate-limit/pkg/metrics"
 	"rate-limit/pkg/storage"
+	"rate-limit/pkg/tenant"
 )
 
+// tierContextKey 通过API key认证的请求在gin.Context中保存调用方等级的键
+const tierContextKey = "tenant_tier"
+
+// key值的存储方式
+const (
+	// StorageRedis 值保存在Redis中，所有实例共享
+	StorageRedis = "redis"
+	// StorageMemory 值保存在进程内存中，不连接Redis，依赖Redis的功能不可用
+	StorageMemory = "memory"
+)
+
+// ServerConfig API服务器配置，可以从YAML文件和环境变量加载，见 LoadConfig
+type ServerConfig struct {
+	// 监听端口
+	Port string `yaml:"port"`
+	// key值的存储方式，StorageRedis 或 StorageMemory
+	Storage string `yaml:"storage"`
+	// Redis连接配置
+	Redis storage.RedisConfig `yaml:"redis"`
+	// 热点key检测配置
+	HotKey detector.HotKeyConfig `yaml:"hot_key"`
+	// 热点key使用的限流算法，见 limiter.AlgorithmTokenBucket 等常量
+	LimiterAlgorithm string `yaml:"limiter_algorithm"`
+	// 每个热点key每秒允许的请求数，为0时使用算法的默认配置，见 limiter.NewLimiterWithRate
+	LimiterRate float64 `yaml:"limiter_rate"`
+	// 每个热点key允许的突发请求数或排队长度
+	LimiterBurst int `yaml:"limiter_burst"`
+	// 热点key超出速率时排队等待的最长时间，等不到才返回429，为0时立即拒绝
+	LimiterMaxWait time.Duration `yaml:"limiter_max_wait"`
+	// 本实例所有热点key每秒共允许访问Redis的请求数，与按key限流同时生效，为0时不启用全局限流，见 limiter.HierarchicalLimiter
+	GlobalRate float64 `yaml:"global_rate"`
+	// 所有热点key共允许的突发请求数
+	GlobalBurst int `yaml:"global_burst"`
+	// 按key前缀限流，同一前缀下的所有热点key共享额度，key匹配多个前缀时使用最长的前缀
+	PrefixLimits []limiter.PrefixLimit `yaml:"prefix_limits"`
+	// 每个热点key同时访问Redis的最大请求数，与限流同时生效，为0时不限制并发
+	MaxInFlight int64 `yaml:"max_in_flight"`
+	// 是否在Redis中统计并发数，所有实例共享每个key的并发上限，为false时每个实例各自限制
+	DistributedConcurrency bool `yaml:"distributed_concurrency"`
+	// 热点key在本地缓存中的过期时间
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	// 是否按热度调整热点key的缓存过期时间，访问次数等于热点阈值时为CacheTTL，见 cache.AdaptiveTTLConfig
+	AdaptiveCacheTTL bool `yaml:"adaptive_cache_ttl"`
+	// 按热度调整时过期时间的下限
+	CacheMinTTL time.Duration `yaml:"cache_min_ttl"`
+	// 按热度调整时过期时间的上限
+	CacheMaxTTL time.Duration `yaml:"cache_max_ttl"`
+	// 是否在热点key的本地缓存过期前提前从Redis刷新，热点key不会因为缓存过期出现一波请求同时访问Redis
+	CacheRefresh bool `yaml:"cache_refresh"`
+	// 热点key本地缓存过期时间的随机抖动比例，如0.1表示在±10%之间浮动，各实例缓存的同一个key不会同时过期；为0时不抖动
+	CacheTTLJitter float64 `yaml:"cache_ttl_jitter"`
+	// 本地缓存剩余的过期时间不超过此值时刷新
+	CacheRefreshAhead time.Duration `yaml:"cache_refresh_ahead"`
+	// 是否缓存热点key序列化好的HTTP响应，并通过ETag支持客户端重新验证，见 responseCache
+	ResponseCache bool `yaml:"response_cache"`
+	// 热点key响应的Cache-Control max-age，为0时返回no-cache，要求客户端每次重新验证
+	ResponseCacheMaxAge time.Duration `yaml:"response_cache_max_age"`
+	// 是否汇总所有实例的访问计数检测全局热点，见 detector.ClusterAggregator
+	ClusterHotKeys bool `yaml:"cluster_hot_keys"`
+	// 是否把热点key的热度写入Redis有序集合，提供按时间范围查询的热点排行，见 detector.Ranking
+	HotKeyRanking bool `yaml:"hot_key_ranking"`
+	// 是否通过Redis Pub/Sub向其他实例广播热点key的值以预热本地缓存，见 cache.Broadcaster
+	BroadcastHotKeys bool `yaml:"broadcast_hot_keys"`
+	// 是否订阅事件总线上由uv-pv-collector发布的页面访问事件，把访问的页面（page:路径）计入热点检测，见 detector.EventFeed
+	PageAccessEvents bool `yaml:"page_access_events"`
+	// 预先声明的热点key：启动时直接标记为热点、从Redis读取值放入本地缓存并设置自定义限流速率，见 PrewarmKey
+	PrewarmKeys []PrewarmKey `yaml:"prewarm_keys"`
+	// 是否从Redis读取按key配置的限流规则，见 limiter.DynamicLimiter
+	DynamicLimits bool `yaml:"dynamic_limits"`
+	// 每个客户端IP每秒允许的请求数，与按key限流同时生效，为0时不按IP限流
+	IPRatePerSecond float64 `yaml:"ip_rate"`
+	// 每个客户端IP允许的突发请求数
+	IPBurstSize int `yaml:"ip_burst"`
+	// 按路由限流，与按IP限流同时生效，见 RouteLimits
+	RouteLimits RouteLimits `yaml:"route_limits"`
+	// 可信的反向代理地址（IP或CIDR），只有来自这些地址的请求才从X-Forwarded-For中解析客户端IP
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// 是否要求请求携带X-API-Key，为false时没有API key的请求按匿名用户处理
+	RequireAPIKey bool `yaml:"require_api_key"`
+	// 是否以影子模式限流所有请求：照常检查各维度的限流，本应被拒绝的请求只记录到日志和指标，不返回429
+	ShadowMode bool `yaml:"shadow_mode"`
+	// 以影子模式限流的key，可以通过管理接口增删
+	ShadowKeys []string `yaml:"shadow_keys"`
+	// 是否启用惩罚区：热点key在PenaltyWindow内被限流超过PenaltyThreshold次后，PenaltyDuration内的请求直接被拒绝，见 limiter.PenaltyBox
+	PenaltyBox bool `yaml:"penalty_box"`
+	// 关进惩罚区的被限流次数
+	PenaltyThreshold int64 `yaml:"penalty_threshold"`
+	// 统计被限流次数的窗口
+	PenaltyWindow time.Duration `yaml:"penalty_window"`
+	// 关进惩罚区的时长
+	PenaltyDuration time.Duration `yaml:"penalty_duration"`
+	// 管理接口的令牌，请求需携带X-Admin-Token，为空时不注册管理接口
+	AdminToken string `yaml:"admin_token"`
+	// POST /set/{key} 没有指定ttl时的过期时间
+	SetDefaultTTL time.Duration `yaml:"set_default_ttl"`
+	// POST /set/{key} 允许指定的最长过期时间
+	SetMaxTTL time.Duration `yaml:"set_max_ttl"`
+	// 优雅关闭时等待处理中的请求完成的最长时间，见 Server.Shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// DefaultServerConfig 默认API服务器配置
+var DefaultServerConfig = ServerConfig{
+	Port:              "8080",
+	Storage:           StorageRedis,
+	Redis:             storage.DefaultConfig,
+	HotKey:            detector.DefaultHotKeyConfig,
+	LimiterAlgorithm:  limiter.AlgorithmTokenBucket,
+	CacheTTL:          5 * time.Minute,
+	CacheMinTTL:       cache.DefaultAdaptiveTTLConfig.MinTTL,
+	CacheMaxTTL:       cache.DefaultAdaptiveTTLConfig.MaxTTL,
+	CacheTTLJitter:    0.1,
+	CacheRefreshAhead: 10 * time.Second,
+	DynamicLimits:     true,
+	IPRatePerSecond:   50,  // 每个IP每秒50个请求
+	IPBurstSize:       100, // 每个IP允许100个突发请求
+	TrustedProxies:    []string{"127.0.0.1", "::1"},
+	PenaltyThreshold:  limiter.DefaultPenaltyBoxConfig.Threshold,
+	PenaltyWindow:     limiter.DefaultPenaltyBoxConfig.Window,
+	PenaltyDuration:   limiter.DefaultPenaltyBoxConfig.Duration,
+	SetDefaultTTL:     time.Hour,
+	SetMaxTTL:         24 * time.Hour,
+	ShutdownTimeout:   10 * time.Second,
+}
+
 // Server API服务器
 type Server struct {
-	redisClient *storage.RedisClient
+	store       storage.Storage       // key值的存储
+	replicas    storage.ReplicaReader // 从只读副本读取热点key，没有配置副本时为nil
 	localCache  *cache.LocalCache
 	hotKeyDet   *detector.HotKeyDetector
-	rateLimiter *limiter.RateLimiter
-	router      *gin.Engine
-	port        string
+	cluster     *detector.ClusterAggregator // 集群热点聚合，未启用时为nil
+	ranking     *detector.Ranking           // 热点排行，未启用时为nil
+	broadcaster *cache.Broadcaster          // 热点key广播，未启用时为nil
+	pageEvents  *detector.EventFeed         // 页面访问事件订阅，未启用时为nil
+	rateLimiter limiter.Limiter
+	dynamic     *limiter.DynamicLimiter    // 动态限流规则，未启用时为nil
+	ipLimiter   limiter.Limiter            // 按客户端IP限流，未启用时为nil
+	concurrency limiter.ConcurrencyLimiter // 热点key的并发限制，未启用时为nil
+	penaltyBox  *limiter.PenaltyBox        // 惩罚区，未启用时为nil
+	responses   *responseCache             // 热点key的HTTP响应缓存，未启用时为nil
+	events      *eventHub                  // 热点key事件流的订阅者
+	shadowKeys  *shadowKeys                // 以影子模式限流的key
+	savings     *savingsTracker            // 热点key请求数与实际访问Redis的次数
+	prewarmed   *prewarmKeys               // 预热的热点key
+	// 按路由限流，只包含设置了速率的路由
+	routeLimiters map[string]limiter.Limiter
+	// 消耗多个限流额度的路由及其额度，见 requestCost
+	routeCosts map[string]limiter.CostFunc
+	tenants    *tenant.Store // 调用方等级，使用内存存储时为nil
+	metrics    *metrics.Metrics
+	fetches    singleflight.Group // 合并同一个热点key并发的Redis读取
+	config     ServerConfig
+	router     *gin.Engine
+	httpServer *http.Server
+	port       string
+	stop       chan struct{} // 关闭时通知后台协程和事件流退出
+	stopOnce   sync.Once     // 重复调用Shutdown或Close时只关闭一次stop
+	closeOnce  sync.Once     // 重复调用Shutdown或Close时只释放一次资源
 }
 
-// NewServer 创建一个新的API服务器
+// NewServer 使用默认配置在指定端口创建API服务器
 func NewServer(port string) *Server {
+	config := DefaultServerConfig
+	config.Port = port
+	server, err := NewServerWithConfig(config)
+	if err != nil {
+		// 默认配置使用令牌桶，不会出错
+		panic(err)
+	}
+	return server
+}
+
+// NewServerWithConfig 使用指定配置创建API服务器，配置无效时返回错误
+func NewServerWithConfig(config ServerConfig) (*Server, error) {
+	if config.Storage == StorageMemory {
+		return NewServerWithStorage(config, storage.NewMemoryStorage())
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	m := metrics.NewMetrics()
+	redisClient := storage.NewRedisClientWithConfig(config.Redis, m.RedisHook())
+	return newServer(config, m, redisClient, redisClient.Client())
+}
+
+// NewServerWithStorage 使用指定的存储创建API服务器，不连接Redis，用于测试或单机运行
+// 配置按 StorageMemory 检查，依赖Redis的功能需要关闭
+func NewServerWithStorage(config ServerConfig, store storage.Storage) (*Server, error) {
+	config.Storage = StorageMemory
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return newServer(config, metrics.NewMetrics(), store, nil)
+}
+
+// newServer 创建API服务器，redisClient为nil时不创建依赖Redis的组件，配置已经检查过
+// m中的Redis命令指标由创建redisClient时添加的Hook记录
+func newServer(config ServerConfig, m *metrics.Metrics, store storage.Storage, redisClient redis.UniversalClient) (*Server, error) {
 	gin.SetMode(gin.ReleaseMode)
 
+	rateLimiter, err := limiter.NewLimiterWithRate(config.LimiterAlgorithm, redisClient, config.LimiterRate, config.LimiterBurst)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	log.Printf("Using %s rate limiter for hot keys", config.LimiterAlgorithm)
+	if config.DynamicLimits {
+		dynamicConfig := limiter.DefaultDynamicLimiterConfig
+		dynamicConfig.DefaultAlgorithm = config.LimiterAlgorithm
+		rateLimiter = limiter.NewDynamicLimiter(redisClient, rateLimiter, dynamicConfig)
+	}
+	// 动态限流规则作用于按key的一层，全局和按前缀的额度在它之外
+	dynamic, _ := rateLimiter.(*limiter.DynamicLimiter)
+	if config.GlobalRate > 0 || len(config.PrefixLimits) > 0 {
+		rateLimiter = limiter.NewHierarchicalLimiter(rateLimiter, limiter.HierarchicalConfig{
+			Global:   limiter.RateLimiterConfig{RatePerSecond: config.GlobalRate, BurstSize: config.GlobalBurst},
+			Prefixes: config.PrefixLimits,
+		})
+		log.Printf("Using hierarchical rate limits: global %.2f req/s, %d prefixes", config.GlobalRate, len(config.PrefixLimits))
+	}
+
 	s := &Server{
-		redisClient: storage.NewRedisClient(),
-		localCache:  cache.NewLocalCache(5*time.Minute, time.Minute),
-		hotKeyDet:   detector.NewDefaultHotKeyDetector(),
-		rateLimiter: limiter.NewDefaultRateLimiter(),
-		router:      gin.Default(),
-		port:        port,
+		store:         store,
+		localCache:    cache.NewLocalCache(config.CacheTTL, time.Minute),
+		hotKeyDet:     detector.NewHotKeyDetector(config.HotKey),
+		rateLimiter:   rateLimiter,
+		dynamic:       dynamic,
+		routeLimiters: newRouteLimiters(config.RouteLimits),
+		events:        newEventHub(),
+		shadowKeys:    newShadowKeys(config.ShadowKeys),
+		prewarmed:     newPrewarmKeys(),
+		savings:       newSavingsTracker(),
+		metrics:       m,
+		config:        config,
+		router:        gin.Default(),
+		port:          config.Port,
+		stop:          make(chan struct{}),
+	}
+	s.httpServer = &http.Server{Addr: ":" + config.Port, Handler: s.router}
+	if replicas, ok := store.(storage.ReplicaReader); ok && redisClient != nil && len(config.Redis.Replicas) > 0 {
+		s.replicas = replicas
+		log.Printf("Reading hot keys from %d Redis replicas", len(config.Redis.Replicas))
+	}
+	s.hotKeyDet.OnEvent(s.events.publish)
+	s.hotKeyDet.OnHotKeyExpired(func(event detector.HotKeyEvent) {
+		s.savings.remove(event.Key)
+	})
+	if redisClient != nil {
+		s.tenants = tenant.NewDefaultStore(redisClient)
+	}
+	if config.ClusterHotKeys {
+		s.cluster = detector.NewDefaultClusterAggregator(redisClient, s.hotKeyDet)
+	}
+	if config.HotKeyRanking {
+		s.ranking = detector.NewDefaultRanking(redisClient, s.hotKeyDet)
+	}
+	if config.PageAccessEvents {
+		// 同一台主机上的实例监听不同的端口，主机名加端口既能区分实例，重启后也保持不变
+		feedConfig := detector.DefaultEventFeedConfig
+		hostname, _ := os.Hostname()
+		feedConfig.Consumer = hostname + ":" + config.Port
+		s.pageEvents = detector.NewEventFeed(redisClient, s.hotKeyDet, feedConfig)
+	}
+	if config.BroadcastHotKeys {
+		broadcastConfig := cache.DefaultBroadcastConfig
+		broadcastConfig.CacheTTL = config.CacheTTL
+		broadcastConfig.TTLJitter = config.CacheTTLJitter
+		// 收到广播的key同时在本地标记为热点，之后的请求直接读取本地缓存
+		broadcastConfig.OnReceive = s.hotKeyDet.MarkHotKey
+		s.broadcaster = cache.NewBroadcaster(redisClient, s.localCache, broadcastConfig)
+	}
+	if err := s.router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if config.ResponseCache {
+		s.responses = newResponseCache(max(config.CacheTTL, config.CacheMaxTTL), config.ResponseCacheMaxAge)
+		// 不再是热点的key不会再从本地缓存返回，及时释放它序列化好的响应
+		s.hotKeyDet.OnHotKeyExpired(func(event detector.HotKeyEvent) {
+			s.responses.delete(event.Key)
+		})
+	}
+	if config.MaxInFlight > 0 {
+		concurrencyConfig := limiter.DefaultConcurrencyConfig
+		concurrencyConfig.MaxInFlight = config.MaxInFlight
+		if config.DistributedConcurrency {
+			s.concurrency = limiter.NewRedisConcurrencyLimiter(redisClient, concurrencyConfig)
+		} else {
+			s.concurrency = limiter.NewLocalConcurrencyLimiter(concurrencyConfig)
+		}
+	}
+	if config.PenaltyBox {
+		penaltyConfig := limiter.DefaultPenaltyBoxConfig
+		penaltyConfig.Threshold = config.PenaltyThreshold
+		penaltyConfig.Window = config.PenaltyWindow
+		penaltyConfig.Duration = config.PenaltyDuration
+		s.penaltyBox = limiter.NewPenaltyBox(redisClient, penaltyConfig)
+	}
+	if config.IPRatePerSecond > 0 {
+		s.ipLimiter = limiter.NewRateLimiter(limiter.RateLimiterConfig{
+			RatePerSecond: config.IPRatePerSecond,
+			BurstSize:     config.IPBurstSize,
+		})
 	}
 
 	s.setupRoutes()
-	return s
+	s.registerGauges()
+	s.prewarmConfigured()
+	if config.AdaptiveCacheTTL {
+		go s.refreshCacheTTLs()
+	}
+	if config.CacheRefresh {
+		go s.refreshHotKeys()
+	}
+	return s, nil
+}
+
+// registerGauges 注册在抓取时计算的指标
+func (s *Server) registerGauges() {
+	s.metrics.GaugeFunc("hot_keys", "Hot keys currently marked on this instance.", nil, func() float64 {
+		return float64(len(s.hotKeyDet.GetHotKeys()))
+	})
+	if breaker, ok := s.store.(interface{ BreakerOpen() bool }); ok {
+		s.metrics.GaugeFunc("redis_breaker_open", "Whether the Redis circuit breaker is open (1) or closed (0).", nil, func() float64 {
+			if breaker.BreakerOpen() {
+				return 1
+			}
+			return 0
+		})
+	}
+	s.metrics.GaugeFunc("local_cache_entries", "Entries in the local cache.", nil, func() float64 {
+		return float64(s.localCache.Count())
+	})
+	if s.penaltyBox != nil {
+		s.metrics.GaugeFunc("penalty_box_keys", "Keys currently in the penalty box.", nil, func() float64 {
+			return float64(s.penaltyBox.Size())
+		})
+	}
+	for name, l := range s.limiters() {
+		s.metrics.GaugeFunc("limiter_size", "Per-key limiter states held in memory.", map[string]string{"limiter": name}, func() float64 {
+			return float64(l.Info().Size)
+		})
+	}
+}
+
+// limiters 返回服务器使用的各个限流器，键为指标中的limiter标签：hot_key、ip、route_{路由}
+func (s *Server) limiters() map[string]limiter.Limiter {
+	limiters := map[string]limiter.Limiter{"hot_key": s.rateLimiter}
+	if s.ipLimiter != nil {
+		limiters["ip"] = s.ipLimiter
+	}
+	for route, routeLimiter := range s.routeLimiters {
+		limiters["route_"+route] = routeLimiter
+	}
+	return limiters
 }
 
 // setupRoutes 设置路由
 func (s *Server) setupRoutes() {
-	s.router.GET("/get/:key", s.handleGetKey)
-	s.router.GET("/stats/:key", s.handleKeyStats)
-	s.router.GET("/hot-keys", s.handleHotKeys)
-	s.router.POST("/set/:key", s.handleSetKey)
+	// 按IP限流在热点检测之前执行，被限流的客户端不会抬高key的访问计数
+	if s.ipLimiter != nil {
+		s.router.Use(s.clientIPLimit())
+	}
+
+	// 批量读取按key的数量计入各维度的限流额度
+	s.routeCosts = map[string]limiter.CostFunc{"/mget": mgetCost}
+
+	s.router.GET("/get/:key", s.routeLimit(routeGet), s.tenantLimit(), s.penaltyCheck(), s.hotKeyLimit(), s.handleGetKey)
+	s.router.GET("/mget", s.routeLimit(routeGet), s.tenantLimit(), s.handleMGet)
+	s.router.GET("/stats/:key", s.routeLimit(routeStats), s.handleKeyStats)
+	s.router.GET("/hot-keys", s.routeLimit(routeHotKeys), s.handleHotKeys)
+	s.router.GET("/hot-keys/top", s.routeLimit(routeHotKeys), s.handleHotKeyRanking)
+	s.router.GET("/hot-keys/stream", s.routeLimit(routeHotKeys), s.handleHotKeyStream)
+	s.router.GET("/hot-keys/savings", s.routeLimit(routeHotKeys), s.handleHotKeySavings)
+	s.router.GET("/top-keys", s.handleTopKeys)
+	s.router.POST("/set/:key", s.routeLimit(routeSet), s.handleSetKey)
+	s.router.GET("/metrics", gin.WrapH(s.metrics.Handler()))
+
+	if s.config.AdminToken != "" {
+		admin := s.router.Group("/admin", s.adminAuth())
+		if s.tenants != nil {
+			admin.GET("/plans", s.handlePlans)
+			admin.PUT("/api-keys/:apiKey", s.handleAssignTier)
+			admin.DELETE("/api-keys/:apiKey", s.handleRevokeAPIKey)
+		}
+		admin.GET("/limits", s.handleLimits)
+		admin.PUT("/limits/:key", s.handleSetLimit)
+		admin.DELETE("/limits/:key", s.handleClearLimit)
+		admin.DELETE("/hot-keys", s.handleClearHotKeys)
+		admin.DELETE("/hot-keys/:key", s.handleClearHotKey)
+		admin.GET("/prewarm", s.handlePrewarmKeys)
+		admin.PUT("/prewarm/:key", s.handlePrewarmKey)
+		admin.DELETE("/prewarm/:key", s.handleUnprewarmKey)
+		admin.DELETE("/cache", s.handleFlushCache)
+		admin.DELETE("/cache/:key", s.handleDeleteCacheKey)
+		admin.GET("/shadow", s.handleShadow)
+		admin.PUT("/shadow/:key", s.handleSetShadowKey)
+		admin.DELETE("/shadow/:key", s.handleClearShadowKey)
+		if s.penaltyBox != nil {
+			admin.GET("/penalty-box", s.handlePenaltyBox)
+			admin.DELETE("/penalty-box/:key", s.handleReleasePenaltyBox)
+		}
+	}
 }
 
-// Start 启动服务器
+// Start 启动服务器，阻塞直到服务器出错或被关闭，通过 Shutdown 或 Close 关闭时返回nil
 func (s *Server) Start() error {
 	log.Printf("Starting API server on port %s", s.port)
-	return s.router.Run(":" + s.port)
+	if err := s.httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// clientIPLimit 按客户端IP限流的中间件，单个客户端请求过多时只限制该客户端，而不是让所有人都访问不到某个key
+func (s *Server) clientIPLimit() gin.HandlerFunc {
+	return limiter.GinMiddleware(limiter.MiddlewareOptions{
+		Limiter:         s.ipLimiter,
+		KeyFunc:         limiter.KeyFromClientIP(),
+		Cost:            s.requestCost,
+		Shadow:          s.shadowed,
+		OnShadowLimited: s.observeShadow(metrics.LimitIP),
+		OnLimited: func(c *gin.Context) {
+			s.metrics.ObserveLimited(metrics.LimitIP)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests from this client", "limit": "ip"})
+		},
+	})
+}
+
+// tenantLimit 按调用方等级限流的中间件：携带X-API-Key的请求按API key所属等级的额度限流
+func (s *Server) tenantLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader("X-API-Key")
+		if s.tenants == nil {
+			// 使用内存存储时没有套餐表，API key被忽略
+			c.Next()
+			return
+		}
+		if apiKey == "" {
+			if s.config.RequireAPIKey {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		tier, allowed, err := s.tenants.AllowN(c.Request.Context(), apiKey, c.Param("key"), s.requestCost(c))
+		if errors.Is(err, tenant.ErrUnknownAPIKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+		if err != nil {
+			// 无法读取等级时按匿名用户处理，仍受热点key限流的保护
+			log.Printf("Error checking tier limit: %v", err)
+			c.Next()
+			return
+		}
+		if !allowed {
+			if !s.shadowed(c, apiKey) {
+				s.metrics.ObserveLimited(metrics.LimitTier)
+				s.metrics.ObserveRequest(s.keyClass(c.Param("key")), false)
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests for your plan", "limit": "tier", "tier": tier})
+				return
+			}
+			// 影子模式下只记录，请求按通过等级限流处理
+			log.Printf("Shadow limited request for key: %s (tier %s)", c.Param("key"), tier)
+			s.metrics.ObserveShadowLimited(metrics.LimitTier)
+		}
+
+		c.Set(tierContextKey, tier)
+		c.Next()
+	}
 }
 
-// handleGetKey 处理获取key的请求
+// adminAuth 检查管理接口的令牌
+func (s *Server) adminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// hotKeyLimit 热点key限流中间件：记录访问并检测热点key，热点key不在本地缓存中时检查是否允许访问Redis
+func (s *Server) hotKeyLimit() gin.HandlerFunc {
+	return limiter.GinMiddleware(limiter.MiddlewareOptions{
+		Limiter: s.rateLimiter,
+		KeyFunc: limiter.KeyFromParam("key"),
+		HotKeys: s.hotKeyDet,
+		// 已按调用方等级限流的请求不再受所有人共享的额度限制；本地缓存命中时不会访问Redis，不需要限流
+		Bypass: func(c *gin.Context, key string) bool {
+			if c.GetString(tierContextKey) != "" {
+				return true
+			}
+			_, found := s.localCache.Get(key)
+			return found
+		},
+		Concurrency:     s.concurrency,
+		Shadow:          s.shadowed,
+		OnShadowLimited: s.hotKeyShadowLimit,
+		OnLimited: func(c *gin.Context) {
+			s.metrics.ObserveRequest(metrics.ClassHot, false)
+			if c.GetString(limiter.LimitReasonContextKey) == limiter.LimitReasonConcurrency {
+				s.metrics.ObserveLimited(metrics.LimitConcurrency)
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many concurrent requests for this hot key", "limit": "concurrency"})
+				return
+			}
+			s.metrics.ObserveLimited(metrics.LimitKey)
+			s.penalize(c, c.Param("key"))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests for this hot key", "limit": "key"})
+		},
+		MaxWait: s.config.LimiterMaxWait,
+		OnWait: func(c *gin.Context, key string, waited time.Duration, allowed bool) {
+			s.metrics.ObserveQueueWait(waited, allowed)
+		},
+	})
+}
+
+// handleGetKey 处理获取key的请求，热点检测和限流由 hotKeyLimit 中间件完成
 func (s *Server) handleGetKey(c *gin.Context) {
 	key := c.Param("key")
+	isHotKey := c.GetBool(limiter.HotKeyContextKey)
+	s.metrics.ObserveRequest(s.keyClass(key), true)
 
-	// 记录访问并检测是否为热点key
-	isHotKey := s.hotKeyDet.RecordAccess(key)
-
-	// 如果是热点key，应用限流
+	// 如果是热点key，尝试从本地缓存获取
 	if isHotKey {
-		// 尝试从本地缓存获取
-		if value, found := s.localCache.Get(key); found {
+		value, found := s.localCache.Get(key)
+		s.metrics.ObserveCacheLookup(found)
+		if found {
 			log.Printf("Hot key cache hit: %s", key)
-			c.JSON(http.StatusOK, gin.H{"value": value, "source": "local_cache"})
+			s.observeHotKeyRead(key, sourceLocalCache)
+			s.writeCachedResponse(c, key, value)
 			return
 		}
+	}
 
-		// 如果本地缓存没有，检查是否允许访问Redis
-		if !s.rateLimiter.Allow(key) {
-			log.Printf("Rate limited for hot key: %s", key)
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests for this hot key"})
+	// 从Redis获取数据，热点key由 fetchHotKey 读取并更新本地缓存
+	var value string
+	var err error
+	shared := false
+	if isHotKey {
+		value, shared, err = s.fetchHotKey(key)
+	} else {
+		value, err = s.store.Get(key)
+	}
+	if errors.Is(err, storage.ErrCircuitOpen) {
+		// Redis熔断期间不等待超时：本地缓存中有值时直接返回（可能不是最新的值），否则快速失败
+		if value, found := s.localCache.Get(key); found {
+			c.JSON(http.StatusOK, gin.H{"value": value, "source": "local_cache", "degraded": true})
 			return
 		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Redis is unavailable"})
+		return
 	}
-
-	// 从Redis获取数据
-	value, err := s.redisClient.Get(key)
 	if err != nil {
 		log.Printf("Error getting key from Redis: %s, %v", key, err)
+		if !shared {
+			s.metrics.ObserveRedisError()
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get value from Redis"})
 		return
 	}
@@ -92,28 +600,196 @@ func (s *Server) handleGetKey(c *gin.Context) {
 		return
 	}
 
-	// 如果是热点key，更新本地缓存
 	if isHotKey {
-		s.localCache.Set(key, value, 5*time.Minute)
+		source := sourceOrigin
+		if shared {
+			source = sourceCoalesced
+		}
+		s.observeHotKeyRead(key, source)
+	}
+	if isHotKey && s.responses != nil && s.responses.revalidate(c, valueETag(value)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"value": value, "source": "redis"})
+}
+
+// writeCachedResponse 写出本地缓存命中的热点key；启用响应缓存时直接写出序列化好的响应，客户端的副本仍有效时返回304
+func (s *Server) writeCachedResponse(c *gin.Context, key, value string) {
+	if s.responses == nil {
+		c.JSON(http.StatusOK, gin.H{"value": value, "source": "local_cache"})
+		return
+	}
+
+	resp, err := s.responses.get(key, value)
+	if err != nil {
+		log.Printf("Failed to build cached response for %s: %v", key, err)
+		c.JSON(http.StatusOK, gin.H{"value": value, "source": "local_cache"})
+		return
+	}
+	if s.responses.revalidate(c, resp.etag) {
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", resp.body)
+}
+
+// fetchHotKey 从Redis读取热点key，更新本地缓存并通知其他实例预热，返回值中的bool表示结果是否来自其他请求的读取
+// 热点key的本地缓存过期或未命中时会有大量并发请求同时通过限流，同一个key同时只有一个请求访问Redis，其余请求等待并共享结果
+func (s *Server) fetchHotKey(key string) (string, bool, error) {
+	// singleflight对所有参与的调用者都返回shared，只有执行了读取的调用者会设置fetched
+	fetched := false
+	value, err, _ := s.fetches.Do(key, func() (any, error) {
+		fetched = true
+		value, err := s.readHotKey(key)
+		if err != nil || value == "" {
+			return value, err
+		}
+		s.localCache.Set(key, value, s.cacheTTL(key))
 		log.Printf("Hot key cached: %s", key)
+		// 读取由所有等待的请求共享，不使用某一个请求的上下文
+		s.broadcastHotKey(context.Background(), key, value)
+		return value, nil
+	})
+	return value.(string), !fetched, err
+}
+
+// readHotKey 读取热点key，配置了只读副本时从副本读取，普通key仍从主节点读取
+// 只有副本出错（连接失败，或副本正在加载数据、与主节点断开时返回的LOADING、MASTERDOWN等错误）时才改为从主节点读取；
+// 副本上不存在的key按不存在返回，否则对不存在的热点key的每次读取都会同时访问副本和主节点
+func (s *Server) readHotKey(key string) (string, error) {
+	if s.replicas == nil {
+		return s.store.Get(key)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"value": value, "source": "redis"})
+	value, err := s.replicas.GetFromReplica(key)
+	if err == nil {
+		s.metrics.ObserveReplicaRead(false)
+		return value, nil
+	}
+	log.Printf("Error reading hot key %s from replica, falling back to primary: %v", key, err)
+	s.metrics.ObserveReplicaRead(true)
+	return s.store.Get(key)
+}
+
+// observeHotKeyRead 记录一次热点key请求的来源，用于统计本地缓存和请求合并减少的Redis读取
+func (s *Server) observeHotKeyRead(key, source string) {
+	s.savings.served(key, source)
+	s.metrics.ObserveHotKeyRead(source)
+}
+
+// cacheTTL 返回热点key在本地缓存中的过期时间，加上随机抖动
+func (s *Server) cacheTTL(key string) time.Duration {
+	ttl := s.config.CacheTTL
+	if s.config.AdaptiveCacheTTL {
+		policy := cache.AdaptiveTTLConfig{BaseTTL: s.config.CacheTTL, MinTTL: s.config.CacheMinTTL, MaxTTL: s.config.CacheMaxTTL}
+		ttl = policy.TTL(s.hotKeyDet.GetAccessCount(key), s.config.HotKey.Threshold)
+	}
+	return cache.Jitter(ttl, s.config.CacheTTLJitter)
+}
+
+// refreshCacheTTLs 每经过一个热点统计窗口，按最新的访问次数重新计算已缓存的热点key的过期时间
+func (s *Server) refreshCacheTTLs() {
+	ticker := time.NewTicker(s.config.HotKey.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, hotKey := range s.hotKeyDet.GetHotKeys() {
+				s.localCache.Touch(hotKey.Key, s.cacheTTL(hotKey.Key))
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// refreshHotKeys 定期检查已缓存的热点key，本地缓存剩余的过期时间不超过CacheRefreshAhead时从Redis重新读取
+// 检查间隔为CacheRefreshAhead的一半，key在两次检查之间不会过期；不再是热点的key不刷新，缓存到期后自然过期
+func (s *Server) refreshHotKeys() {
+	interval := max(s.config.CacheRefreshAhead/2, 100*time.Millisecond)
+	// 各实例的检查错开一段随机的时间，不会在同一时刻一起刷新
+	select {
+	case <-time.After(rand.N(interval)):
+	case <-s.stop:
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, hotKey := range s.hotKeyDet.GetHotKeys() {
+				_, expiresAt, found := s.localCache.GetWithExpiration(hotKey.Key)
+				if found && time.Until(expiresAt) <= s.config.CacheRefreshAhead {
+					s.refreshHotKey(hotKey.Key)
+				}
+			}
+		case <-s.stop:
+			return
+		}
+	}
 }
 
-// handleKeyStats 获取key的统计信息
+// refreshHotKey 从Redis重新读取热点key并更新本地缓存，与同一个key并发的请求共享读取
+// 读取失败时保留本地缓存中的值直到过期，之后的请求照常访问Redis
+func (s *Server) refreshHotKey(key string) {
+	_, shared, err := s.fetchHotKey(key)
+	if errors.Is(err, storage.ErrCircuitOpen) {
+		return
+	}
+	if !shared {
+		s.savings.fetched(key)
+		s.metrics.ObserveHotKeyRead(sourceRefresh)
+	}
+	if err != nil {
+		log.Printf("Error refreshing hot key %s: %v", key, err)
+		if !shared {
+			s.metrics.ObserveRedisError()
+		}
+	}
+	s.metrics.ObserveCacheRefresh(err == nil)
+}
+
+// broadcastHotKey 向其他实例广播热点key的值，未启用广播时不做任何事；广播失败不影响请求
+func (s *Server) broadcastHotKey(ctx context.Context, key, value string) {
+	if s.broadcaster == nil {
+		return
+	}
+	if err := s.broadcaster.Publish(ctx, key, value); err != nil {
+		log.Printf("Error broadcasting hot key %s: %v", key, err)
+	}
+}
+
+// keyClass 返回key在指标中的分类
+func (s *Server) keyClass(key string) string {
+	if s.hotKeyDet.IsHotKey(key) {
+		return metrics.ClassHot
+	}
+	return metrics.ClassNormal
+}
+
+// handleKeyStats 获取key的统计信息：是否为热点、是否在本地缓存中，以及最近的访问速率，见 detector.AccessStats
 func (s *Server) handleKeyStats(c *gin.Context) {
 	key := c.Param("key")
 
-	accessCount := s.hotKeyDet.GetAccessCount(key)
-	isHotKey := s.hotKeyDet.IsHotKey(key)
+	stats, tracked := s.hotKeyDet.GetAccessStats(key)
+	hotKey, isHotKey := s.hotKeyDet.GetHotKey(key)
 	inCache, _ := s.localCache.Get(key)
 
 	c.JSON(http.StatusOK, gin.H{
-		"key":          key,
-		"access_count": accessCount,
-		"is_hot_key":   isHotKey,
-		"in_cache":     inCache != "",
+		"key":                  key,
+		"is_hot_key":           isHotKey,
+		"hot_window":           hotKey.Window,
+		"in_cache":             inCache != "",
+		"tracked":              tracked,
+		"rate_1s":              stats.Rate1s,
+		"rate_10s":             stats.Rate10s,
+		"rate_60s":             stats.Rate60s,
+		"peak_rate":            stats.PeakRate,
+		"p95_inter_arrival_ms": float64(stats.P95InterArrival.Microseconds()) / 1000,
+		"samples":              stats.Samples,
 	})
 }
 
@@ -124,39 +800,322 @@ func (s *Server) handleHotKeys(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"hot_keys": hotKeys})
 }
 
-// handleSetKey 设置key的值
+// handleTopKeys 获取访问次数最多的key及其估计的访问次数，?n=指定数量，默认返回全部Top-K
+// ?scope=cluster 时返回所有实例汇总的全局访问次数
+func (s *Server) handleTopKeys(c *gin.Context) {
+	n, err := strconv.Atoi(c.DefaultQuery("n", "0"))
+	if err != nil || n < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid n"})
+		return
+	}
+
+	if c.Query("scope") == "cluster" {
+		if s.cluster == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cluster hot key aggregation is not enabled"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"top_keys": s.cluster.GlobalTopKeys(n)})
+		return
+	}
+
+	topKeys := s.hotKeyDet.GetTopKeys(n)
+	if topKeys == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Top-K detection is not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"top_keys": topKeys})
+}
+
+// handleHotKeyRanking 返回热点排行，?n=指定数量（默认20），?window=指定时间范围（默认5m）
+func (s *Server) handleHotKeyRanking(c *gin.Context) {
+	if s.ranking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hot key ranking is not enabled"})
+		return
+	}
+
+	n, err := strconv.Atoi(c.DefaultQuery("n", "20"))
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid n"})
+		return
+	}
+	window, err := time.ParseDuration(c.DefaultQuery("window", "5m"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window"})
+		return
+	}
+
+	ranking, err := s.ranking.Top(c.Request.Context(), n, window)
+	if errors.Is(err, detector.ErrInvalidRankingQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting hot key ranking: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get hot key ranking"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hot_keys": ranking, "window": window.String()})
+}
+
+// minSetTTL 写入key允许的最短过期时间
+const minSetTTL = time.Second
+
+// setRequest 写入key的请求，请求体为JSON，也兼容表单
+type setRequest struct {
+	Value string `json:"value" form:"value"`
+	// 过期时间，如 "30s"、"2h"，为空时使用 ServerConfig.SetDefaultTTL
+	TTL string `json:"ttl" form:"ttl"`
+	// 是否同时写入本实例的本地缓存，key不是热点时也写入
+	WarmLocal bool `json:"warm_local" form:"warm_local"`
+}
+
+// handleSetKey 写入key，过期时间在 minSetTTL 和 ServerConfig.SetMaxTTL 之间
 func (s *Server) handleSetKey(c *gin.Context) {
 	key := c.Param("key")
-	value := c.PostForm("value")
+	var req setRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
 
-	if value == "" {
+	if req.Value == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Value cannot be empty"})
 		return
 	}
+	ttl := s.config.SetDefaultTTL
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl < minSetTTL || ttl > s.config.SetMaxTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl", "min_ttl": minSetTTL.String(), "max_ttl": s.config.SetMaxTTL.String()})
+			return
+		}
+	}
 
 	// 设置到Redis
-	expiration := 1 * time.Hour // 默认过期时间1小时
-	err := s.redisClient.Set(key, value, expiration)
+	err := s.store.Set(key, req.Value, ttl)
+	if errors.Is(err, storage.ErrCircuitOpen) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Redis is unavailable"})
+		return
+	}
 	if err != nil {
+		s.metrics.ObserveRedisError()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set value in Redis"})
 		return
 	}
 
-	// 如果是热点key，也更新本地缓存
-	if s.hotKeyDet.IsHotKey(key) {
-		s.localCache.Set(key, value, 5*time.Minute)
-		log.Printf("Hot key cache updated: %s", key)
+	// 如果是热点key，也更新本地缓存，并让其他实例缓存的旧值一起更新；本地缓存不能比Redis中的值更晚过期
+	hot := s.hotKeyDet.IsHotKey(key)
+	if hot || req.WarmLocal {
+		s.localCache.Set(key, req.Value, min(s.cacheTTL(key), ttl))
+		log.Printf("Local cache updated: %s", key)
+	}
+	if hot {
+		s.broadcastHotKey(c.Request.Context(), key, req.Value)
 	}
 
+	c.JSON(http.StatusOK, gin.H{"status": "success", "ttl": ttl.String(), "cached": hot || req.WarmLocal})
+}
+
+// handlePlans 获取套餐表
+func (s *Server) handlePlans(c *gin.Context) {
+	plans, err := s.tenants.Plans(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load plans"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// handleAssignTier 把API key分配到指定等级
+func (s *Server) handleAssignTier(c *gin.Context) {
+	apiKey := c.Param("apiKey")
+	tier := c.PostForm("tier")
+
+	err := s.tenants.AssignTier(c.Request.Context(), apiKey, tier)
+	if errors.Is(err, tenant.ErrUnknownTier) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign tier"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "tier": tier})
+}
+
+// handleRevokeAPIKey 吊销API key
+func (s *Server) handleRevokeAPIKey(c *gin.Context) {
+	if err := s.tenants.RevokeAPIKey(c.Request.Context(), c.Param("apiKey")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke api key"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
-// Close 关闭服务器和相关资源
+// handleLimits 获取热点key限流器和IP限流器的默认配置及按key设置的自定义速率
+func (s *Server) handleLimits(c *gin.Context) {
+	limits := gin.H{"hot_key": s.rateLimiter.Info()}
+	if s.ipLimiter != nil {
+		limits["ip"] = s.ipLimiter.Info()
+	}
+	if len(s.routeLimiters) > 0 {
+		routes := make(map[string]limiter.LimiterInfo, len(s.routeLimiters))
+		for route, routeLimiter := range s.routeLimiters {
+			routes[route] = routeLimiter.Info()
+		}
+		limits["routes"] = routes
+	}
+	if s.config.GlobalRate > 0 {
+		limits["global"] = limiter.KeyLimit{RatePerSecond: s.config.GlobalRate, Burst: s.config.GlobalBurst}
+	}
+	if len(s.config.PrefixLimits) > 0 {
+		limits["prefixes"] = s.config.PrefixLimits
+	}
+	c.JSON(http.StatusOK, limits)
+}
+
+// handleSetLimit 设置key的限流速率；启用动态限流规则时写入Redis，在所有实例上生效，否则只在本实例生效
+func (s *Server) handleSetLimit(c *gin.Context) {
+	key := c.Param("key")
+	rate, err := strconv.ParseFloat(c.PostForm("rate"), 64)
+	if err != nil || rate <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rate"})
+		return
+	}
+	burst, err := strconv.Atoi(c.PostForm("burst"))
+	if err != nil || burst <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid burst"})
+		return
+	}
+	algorithm := c.PostForm("algorithm")
+
+	if s.dynamic == nil {
+		if algorithm != "" && algorithm != s.config.LimiterAlgorithm {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Algorithm can only be set when dynamic limits are enabled"})
+			return
+		}
+		s.rateLimiter.SetLimit(key, rate, burst)
+		c.JSON(http.StatusOK, gin.H{"status": "success", "scope": "local"})
+		return
+	}
+
+	rule := limiter.LimitRule{RatePerSecond: rate, Burst: burst, Algorithm: algorithm}
+	if err := s.dynamic.SetRule(c.Request.Context(), key, rule); err != nil {
+		if errors.Is(err, limiter.ErrUnknownAlgorithm) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error setting rate limit rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set rate limit rule"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "scope": "cluster"})
+}
+
+// handleClearLimit 清除key的自定义限流速率，启用动态限流规则时同时删除Redis中的规则
+func (s *Server) handleClearLimit(c *gin.Context) {
+	key := c.Param("key")
+
+	if s.dynamic != nil {
+		if err := s.dynamic.DeleteRule(c.Request.Context(), key); err != nil {
+			log.Printf("Error deleting rate limit rule: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rate limit rule"})
+			return
+		}
+	}
+	// 规则删除后key交回默认限流器，清除其中通过SetLimit设置的速率
+	s.rateLimiter.ClearLimit(key)
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// handleClearHotKeys 清除所有热点标记，预热的key同时取消预热
+func (s *Server) handleClearHotKeys(c *gin.Context) {
+	for _, key := range s.prewarmed.list() {
+		s.unprewarm(key.Key)
+	}
+	cleared := s.hotKeyDet.ClearHotKeys()
+	c.JSON(http.StatusOK, gin.H{"status": "success", "cleared": cleared})
+}
+
+// handleClearHotKey 清除key的热点标记并删除本地缓存中的值，key再次达到阈值时重新识别为热点；预热的key同时取消预热
+func (s *Server) handleClearHotKey(c *gin.Context) {
+	key := c.Param("key")
+	s.unprewarm(key)
+	s.hotKeyDet.ClearHotKey(key)
+	s.localCache.Delete(key)
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// handleFlushCache 清空本地缓存，之后的请求从Redis读取最新的值
+func (s *Server) handleFlushCache(c *gin.Context) {
+	flushed := s.localCache.Count()
+	s.localCache.Flush()
+	c.JSON(http.StatusOK, gin.H{"status": "success", "flushed": flushed})
+}
+
+// handleDeleteCacheKey 删除本地缓存中的key
+func (s *Server) handleDeleteCacheKey(c *gin.Context) {
+	s.localCache.Delete(c.Param("key"))
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// Shutdown 优雅关闭服务器：停止接收新连接，结束事件流，等待处理中的请求完成后关闭相关资源
+// ctx结束时仍未完成的连接被强制关闭，返回ctx的错误
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.httpServer.Close()
+	}
+	s.closeOnce.Do(s.closeResources)
+	return err
+}
+
+// Close 立即关闭服务器和相关资源，不等待处理中的请求，可以在Shutdown之后调用
 func (s *Server) Close() {
-	if s.redisClient != nil {
-		err := s.redisClient.Close()
+	s.stopOnce.Do(func() { close(s.stop) })
+	if err := s.httpServer.Close(); err != nil {
+		log.Printf("Error closing HTTP server: %v", err)
+	}
+	s.closeOnce.Do(s.closeResources)
+}
+
+// closeResources 停止后台协程并关闭限流器、检测器和存储
+// 先关闭依赖检测器的事件订阅、集群聚合和热点排行，后两者在关闭时还会推送最后一次数据
+func (s *Server) closeResources() {
+	if s.pageEvents != nil {
+		s.pageEvents.Close()
+	}
+	if s.cluster != nil {
+		s.cluster.Close()
+	}
+	if s.ranking != nil {
+		s.ranking.Close()
+	}
+	if s.broadcaster != nil {
+		if err := s.broadcaster.Close(); err != nil {
+			log.Printf("Error closing hot key broadcaster: %v", err)
+		}
+	}
+	s.hotKeyDet.Close()
+	if s.penaltyBox != nil {
+		s.penaltyBox.Close()
+	}
+	for name, l := range s.limiters() {
+		if closer, ok := l.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("Error closing %s limiter: %v", name, err)
+			}
+		}
+	}
+	if s.tenants != nil {
+		s.tenants.Close()
+	}
+	if s.store != nil {
+		err := s.store.Close()
 		if err != nil {
-			log.Printf("Error closing Redis client: %v", err)
+			log.Printf("Error closing storage: %v", err)
 		}
 	}
 }
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"rate-limit/pkg/detector"
+	"rate-limit/pkg/limiter"
+	"rate-limit/pkg/storage"
+)
+
+// newTestServer 使用内存存储创建API服务器，不连接Redis，也不监听端口
+func newTestServer(t *testing.T) (*Server, *storage.MemoryStorage) {
+	t.Helper()
+	config := DefaultServerConfig
+	config.DynamicLimits = false
+	store := storage.NewMemoryStorage()
+	server, err := NewServerWithStorage(config, store)
+	if err != nil {
+		t.Fatalf("NewServerWithStorage() error = %v", err)
+	}
+	return server, store
+}
+
+// fakeReplica 只读副本的替身，err不为nil时每次读取都返回该错误
+type fakeReplica struct {
+	values map[string]string
+	err    error
+	reads  int
+}
+
+func (r *fakeReplica) GetFromReplica(key string) (string, error) {
+	r.reads++
+	if r.err != nil {
+		return "", r.err
+	}
+	return r.values[key], nil
+}
+
+// TestServer_ShutdownThenClose Shutdown之后再调用Close或Shutdown不会因重复关闭通道而panic
+func TestServer_ShutdownThenClose(t *testing.T) {
+	server, _ := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	server.Close()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("second Shutdown() error = %v", err)
+	}
+}
+
+// TestComponents_CloseTwice 带后台协程的限流器和检测器可以重复调用Close
+func TestComponents_CloseTwice(t *testing.T) {
+	closers := map[string]io.Closer{
+		"token bucket":     limiter.NewDefaultRateLimiter(),
+		"leaky bucket":     limiter.NewDefaultLeakyBucketLimiter(),
+		"hot key detector": detector.NewDefaultHotKeyDetector(),
+	}
+	for name, closer := range closers {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				if err := closer.Close(); err != nil {
+					t.Errorf("Close() #%d error = %v", i+1, err)
+				}
+			}
+		})
+	}
+}
+
+// TestServer_ReadHotKeyReplicaFallback 只有副本出错时才从主节点读取，副本上不存在的key不回退
+func TestServer_ReadHotKeyReplicaFallback(t *testing.T) {
+	server, store := newTestServer(t)
+	defer server.Close()
+	if err := store.Set("hot", "primary", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := store.Set("missing-on-replica", "primary", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	replica := &fakeReplica{values: map[string]string{"hot": "replica"}}
+	server.replicas = replica
+
+	if value, err := server.readHotKey("hot"); err != nil || value != "replica" {
+		t.Errorf("readHotKey(hot) = %q, %v, want the replica value", value, err)
+	}
+	// 复制延迟或key不存在时按副本的结果返回，不再访问主节点
+	if value, err := server.readHotKey("missing-on-replica"); err != nil || value != "" {
+		t.Errorf("readHotKey(missing-on-replica) = %q, %v, want empty", value, err)
+	}
+
+	replica.err = errors.New("LOADING Redis is loading the dataset in memory")
+	if value, err := server.readHotKey("hot"); err != nil || value != "primary" {
+		t.Errorf("readHotKey(hot) with a failing replica = %q, %v, want the primary value", value, err)
+	}
+	if replica.reads != 3 {
+		t.Errorf("replica reads = %d, want 3", replica.reads)
+	}
+}
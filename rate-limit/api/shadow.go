@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"rate-limit/pkg/limiter"
+	"rate-limit/pkg/metrics"
+)
+
+// shadowKeys 以影子模式限流的key，这些key上本应被拒绝的请求只记录、不拒绝
+type shadowKeys struct {
+	mu   sync.RWMutex
+	keys map[string]struct{}
+}
+
+// newShadowKeys 创建影子模式的key集合
+func newShadowKeys(keys []string) *shadowKeys {
+	s := &shadowKeys{keys: make(map[string]struct{}, len(keys))}
+	for _, key := range keys {
+		s.keys[key] = struct{}{}
+	}
+	return s
+}
+
+// contains 检查key是否以影子模式限流
+func (s *shadowKeys) contains(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.keys[key]
+	return exists
+}
+
+// add 以影子模式限流key
+func (s *shadowKeys) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key] = struct{}{}
+}
+
+// remove 恢复对key的正常限流
+func (s *shadowKeys) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, key)
+}
+
+// list 返回所有以影子模式限流的key，按字典序排序
+func (s *shadowKeys) list() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// shadowed 判断请求是否以影子模式限流：全局启用影子模式，或请求的key在影子模式的key集合中
+// 各维度的限流都按请求路径中的key判断，没有key的路由只受全局开关控制
+func (s *Server) shadowed(c *gin.Context, _ string) bool {
+	if s.config.ShadowMode {
+		return true
+	}
+	key := c.Param("key")
+	return key != "" && s.shadowKeys.contains(key)
+}
+
+// observeShadow 返回影子模式下记录本应被拒绝的请求的回调，limit为限流维度
+func (s *Server) observeShadow(limit string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		s.metrics.ObserveShadowLimited(limit)
+	}
+}
+
+// handleShadow 返回影子模式的全局开关和以影子模式限流的key
+func (s *Server) handleShadow(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"global": s.config.ShadowMode, "keys": s.shadowKeys.list()})
+}
+
+// handleSetShadowKey 以影子模式限流key，只在本实例生效
+func (s *Server) handleSetShadowKey(c *gin.Context) {
+	s.shadowKeys.add(c.Param("key"))
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// handleClearShadowKey 恢复对key的正常限流
+func (s *Server) handleClearShadowKey(c *gin.Context) {
+	s.shadowKeys.remove(c.Param("key"))
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// hotKeyShadowLimit 热点key限流中间件在影子模式下的记录回调，按拒绝原因区分限流维度
+func (s *Server) hotKeyShadowLimit(c *gin.Context) {
+	if c.GetString(limiter.LimitReasonContextKey) == limiter.LimitReasonConcurrency {
+		s.metrics.ObserveShadowLimited(metrics.LimitConcurrency)
+		return
+	}
+	s.metrics.ObserveShadowLimited(metrics.LimitKey)
+}
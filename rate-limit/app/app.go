@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"rate-limit/api"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 运行热点Key检测和限流的API服务器，ctx取消后优雅地关闭
+// 第一个参数为 loadgen 时改为向运行中的服务器发送压测流量
+func Run(ctx context.Context, args []string) error {
+	// loadgen 子命令向运行中的服务器发送倾斜的流量，用于热点Key实验
+	if len(args) > 0 && args[0] == "loadgen" {
+		return runLoadgen(ctx, args[1:])
+	}
+
+	config := api.DefaultServerConfig
+	fs := cli.NewFlagSet("ratelimit")
+	configPath := fs.String("config", "", "path of the YAML config file")
+	fs.StringVar(&config.Port, "port", config.Port, "API server port")
+	fs.StringVar(&config.Storage, "storage", config.Storage,
+		"key value storage: redis, or memory to run without Redis (Redis-backed features must be disabled)")
+	fs.StringVar(&config.LimiterAlgorithm, "limiter", config.LimiterAlgorithm,
+		"rate limiter algorithm: token_bucket, leaky_bucket, fixed_window, sliding_window, sliding_log, redis_token_bucket")
+	fs.DurationVar(&config.LimiterMaxWait, "limiter-max-wait", config.LimiterMaxWait,
+		"how long a hot key request over the rate may wait in the limiter queue before 429, 0 rejects immediately")
+	fs.Float64Var(&config.GlobalRate, "global-rate", config.GlobalRate,
+		"requests per second allowed for all hot keys of this instance together, 0 disables the global limit")
+	fs.IntVar(&config.GlobalBurst, "global-burst", config.GlobalBurst, "burst size of the global limit")
+	fs.Int64Var(&config.MaxInFlight, "max-in-flight", config.MaxInFlight,
+		"maximum concurrent Redis reads of each hot key, 0 disables the concurrency limit")
+	fs.BoolVar(&config.DistributedConcurrency, "distributed-concurrency", config.DistributedConcurrency,
+		"count in-flight requests in Redis so the concurrency limit is shared by all instances")
+	fs.IntVar(&config.HotKey.TopK, "topk", config.HotKey.TopK,
+		"number of top keys tracked with a count-min sketch, 0 uses exact per-key counting")
+	fs.BoolVar(&config.HotKey.Decay, "decay-hot-keys", config.HotKey.Decay,
+		"score keys with an exponentially decayed access count, hot marks clear as traffic subsides")
+	fs.BoolVar(&config.ClusterHotKeys, "cluster-hot-keys", config.ClusterHotKeys,
+		"aggregate key accesses of all instances in Redis to detect cluster-wide hot keys")
+	fs.BoolVar(&config.HotKeyRanking, "hot-key-ranking", config.HotKeyRanking,
+		"persist hot key scores into Redis sorted sets for the /hot-keys/top leaderboard")
+	fs.BoolVar(&config.BroadcastHotKeys, "broadcast-hot-keys", config.BroadcastHotKeys,
+		"broadcast hot key values to other instances via Redis Pub/Sub to pre-warm their local caches")
+	fs.BoolVar(&config.PageAccessEvents, "page-access-events", config.PageAccessEvents,
+		"count page access events published by uv-pv-collector on the event bus in hot key detection")
+	fs.BoolVar(&config.AdaptiveCacheTTL, "adaptive-cache-ttl", config.AdaptiveCacheTTL,
+		"scale the local cache TTL of hot keys with their access rate")
+	fs.BoolVar(&config.CacheRefresh, "cache-refresh", config.CacheRefresh,
+		"re-fetch hot keys from Redis shortly before their local cache entries expire")
+	fs.BoolVar(&config.ResponseCache, "response-cache", config.ResponseCache,
+		"cache serialized hot key responses and answer revalidations with 304 Not Modified")
+	fs.BoolVar(&config.DynamicLimits, "dynamic-limits", config.DynamicLimits,
+		"load per-key rate limit rules from Redis")
+	fs.Float64Var(&config.IPRatePerSecond, "ip-rate", config.IPRatePerSecond,
+		"requests per second allowed for each client IP, 0 disables IP limiting")
+	fs.IntVar(&config.IPBurstSize, "ip-burst", config.IPBurstSize, "burst size allowed for each client IP")
+	fs.BoolVar(&config.RequireAPIKey, "require-api-key", config.RequireAPIKey, "reject requests without X-API-Key")
+	fs.BoolVar(&config.ShadowMode, "shadow", config.ShadowMode,
+		"evaluate all limits but only log and count requests that would be rejected, never return 429")
+	fs.BoolVar(&config.PenaltyBox, "penalty-box", config.PenaltyBox,
+		"reject hot keys that keep getting rate limited outright for a cool-down period")
+	fs.StringVar(&config.AdminToken, "admin-token", config.AdminToken, "token for the admin endpoints, empty disables them")
+	fs.DurationVar(&config.ShutdownTimeout, "shutdown-timeout", config.ShutdownTimeout,
+		"how long to wait for in-flight requests on shutdown before closing their connections")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// 配置优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
+	loaded, err := api.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	// 参数绑定在config的字段上，替换为加载的配置后再解析一次，只有显式传入的参数会覆盖
+	config = loaded
+	fs.Parse(args)
+
+	log.Printf("Starting hot key detection and rate limiting system...")
+
+	// 创建并启动API服务器
+	server, err := api.NewServerWithConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+
+	// 启动服务器（非阻塞）
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Start()
+	}()
+
+	log.Printf("Rate limiting server is running on port %s", config.Port)
+	log.Printf("Press Ctrl+C to shut down")
+
+	// 等待关闭信号，服务器启动失败时同样关闭后台协程和相关资源
+	var startErr error
+	select {
+	case <-ctx.Done():
+	case startErr = <-errCh:
+		if startErr != nil {
+			startErr = fmt.Errorf("failed to start server: %w", startErr)
+		}
+	}
+	log.Printf("Shutting down server...")
+
+	// 停止接收新请求，等待处理中的请求完成后关闭后台协程和相关资源
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown timed out, closed remaining connections: %v", err)
+	}
+	log.Printf("Server stopped")
+	return startErr
+}
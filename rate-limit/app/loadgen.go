@@ -0,0 +1,287 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"redis-learning/pkg/cli"
+)
+
+// loadgenConfig 压测命令的配置
+type loadgenConfig struct {
+	URL    string
+	APIKey string
+	// key的数量和前缀，key为 前缀+序号，序号越小访问越频繁
+	Keys   int
+	Prefix string
+	// Zipf分布的参数，s越大流量越集中在少数key上，必须大于1
+	ZipfS float64
+	ZipfV float64
+	// 每秒发出的请求数，0表示不限速
+	Rate        float64
+	Concurrency int
+	Duration    time.Duration
+	Interval    time.Duration
+	// 开始前是否先写入所有key的值
+	Seed bool
+}
+
+// loadgenPeriod 一段时间内的请求统计
+type loadgenPeriod struct {
+	latencies  []time.Duration
+	ok         int
+	limited    int
+	failed     int
+	localCache int
+}
+
+// add 把另一段时间的统计累加进来，用于最终汇总
+func (p *loadgenPeriod) add(other loadgenPeriod) {
+	p.latencies = append(p.latencies, other.latencies...)
+	p.ok += other.ok
+	p.limited += other.limited
+	p.failed += other.failed
+	p.localCache += other.localCache
+}
+
+// summary 格式化统计：请求数、延迟分位数、429比例和本地缓存命中率
+func (p *loadgenPeriod) summary(elapsed time.Duration) string {
+	total := len(p.latencies)
+	if total == 0 {
+		return "requests=0"
+	}
+	slices.Sort(p.latencies)
+	percentile := func(q float64) time.Duration {
+		return p.latencies[int(float64(total-1)*q)]
+	}
+
+	cacheRatio := 0.0
+	if p.ok > 0 {
+		cacheRatio = float64(p.localCache) / float64(p.ok)
+	}
+	return fmt.Sprintf("requests=%d rps=%.0f p50=%v p95=%v p99=%v 429=%.1f%% errors=%d local_cache=%.1f%%",
+		total, float64(total)/elapsed.Seconds(),
+		percentile(0.5), percentile(0.95), percentile(0.99),
+		float64(p.limited)*100/float64(total), p.failed, cacheRatio*100)
+}
+
+// loadgenStats 各worker共同记录的当前报告周期的统计
+type loadgenStats struct {
+	mu     sync.Mutex
+	period loadgenPeriod
+}
+
+// record 记录一次请求的结果，status为0表示请求失败
+func (s *loadgenStats) record(latency time.Duration, status int, source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.period.latencies = append(s.period.latencies, latency)
+	switch {
+	case status == http.StatusOK:
+		s.period.ok++
+		if source == "local_cache" {
+			s.period.localCache++
+		}
+	case status == http.StatusTooManyRequests:
+		s.period.limited++
+	default:
+		s.period.failed++
+	}
+}
+
+// reset 取出当前周期的统计并清空
+func (s *loadgenStats) reset() loadgenPeriod {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	period := s.period
+	s.period = loadgenPeriod{}
+	return period
+}
+
+// runLoadgen 向API服务器发送按Zipf分布倾斜的读请求，定期报告延迟、429比例、本地缓存命中率和检测到的热点key
+func runLoadgen(ctx context.Context, args []string) error {
+	var config loadgenConfig
+	fs := cli.NewFlagSet("loadgen")
+	fs.StringVar(&config.URL, "url", "http://localhost:8080", "base URL of the API server")
+	fs.StringVar(&config.APIKey, "api-key", "", "X-API-Key sent with every request")
+	fs.IntVar(&config.Keys, "keys", 1000, "number of distinct keys")
+	fs.StringVar(&config.Prefix, "prefix", "loadgen:", "prefix of the generated keys")
+	fs.Float64Var(&config.ZipfS, "zipf-s", 1.1, "Zipf skew, must be > 1; larger values concentrate traffic on fewer keys")
+	fs.Float64Var(&config.ZipfV, "zipf-v", 1, "Zipf v parameter, must be >= 1")
+	fs.Float64Var(&config.Rate, "rate", 500, "requests per second in total, 0 sends as fast as possible")
+	fs.IntVar(&config.Concurrency, "concurrency", 20, "number of concurrent workers")
+	fs.DurationVar(&config.Duration, "duration", 30*time.Second, "how long to generate load")
+	fs.DurationVar(&config.Interval, "interval", time.Second, "how often to report")
+	fs.BoolVar(&config.Seed, "seed", true, "set a value for every key before generating load")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if config.Keys <= 0 || config.Concurrency <= 0 || config.Duration <= 0 || config.Interval <= 0 {
+		return errors.New("keys, concurrency, duration and interval must be positive")
+	}
+	if config.ZipfS <= 1 || config.ZipfV < 1 {
+		return errors.New("zipf-s must be > 1 and zipf-v must be >= 1")
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: config.Concurrency},
+	}
+
+	if config.Seed {
+		log.Printf("Seeding %d keys...", config.Keys)
+		if err := seedKeys(client, config); err != nil {
+			return fmt.Errorf("failed to seed keys: %w", err)
+		}
+	}
+
+	// 到达压测时长或收到中断信号时停止
+	ctx, cancel := context.WithTimeout(ctx, config.Duration)
+	defer cancel()
+
+	limit := rate.Inf
+	if config.Rate > 0 {
+		limit = rate.Limit(config.Rate)
+	}
+	pacer := rate.NewLimiter(limit, config.Concurrency)
+
+	log.Printf("Generating load on %s: %d keys, zipf s=%.2f, rate=%.0f/s, concurrency=%d, duration=%v",
+		config.URL, config.Keys, config.ZipfS, config.Rate, config.Concurrency, config.Duration)
+
+	stats := &loadgenStats{}
+	var wg sync.WaitGroup
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 每个worker使用独立的随机数源，避免争用
+			zipf := rand.NewZipf(rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+				config.ZipfS, config.ZipfV, uint64(config.Keys-1))
+			for pacer.Wait(ctx) == nil {
+				key := fmt.Sprintf("%s%d", config.Prefix, zipf.Uint64())
+				start := time.Now()
+				status, source := getKey(ctx, client, config, key)
+				if ctx.Err() != nil {
+					return
+				}
+				stats.record(time.Since(start), status, source)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var total loadgenPeriod
+	started := time.Now()
+	last := started
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			period := stats.reset()
+			total.add(period)
+			log.Printf("[%v] %s hot_keys=%s", now.Sub(started).Round(time.Second),
+				period.summary(now.Sub(last)), hotKeys(client, config))
+			last = now
+		case <-done:
+			total.add(stats.reset())
+			log.Printf("Total: %s", total.summary(time.Since(started)))
+			log.Printf("Hot keys: %s", hotKeys(client, config))
+			return nil
+		}
+	}
+}
+
+// seedKeys 为每个key写入一个值，使读请求能命中
+func seedKeys(client *http.Client, config loadgenConfig) error {
+	for i := 0; i < config.Keys; i++ {
+		key := fmt.Sprintf("%s%d", config.Prefix, i)
+		req, err := http.NewRequest(http.MethodPost, config.URL+"/set/"+url.PathEscape(key),
+			strings.NewReader(url.Values{"value": {"value-" + key}}.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("set %s: unexpected status %d", key, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+// getKey 读取一个key，返回状态码和响应中的数据来源，请求失败时状态码为0
+func getKey(ctx context.Context, client *http.Client, config loadgenConfig, key string) (int, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.URL+"/get/"+url.PathEscape(key), nil)
+	if err != nil {
+		return 0, ""
+	}
+	if config.APIKey != "" {
+		req.Header.Set("X-API-Key", config.APIKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Source string `json:"source"`
+	}
+	if resp.StatusCode == http.StatusOK {
+		json.NewDecoder(resp.Body).Decode(&body)
+	}
+	// 读完响应体以复用连接
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, body.Source
+}
+
+// hotKeys 返回服务器当前检测到的热点key，以逗号分隔
+func hotKeys(client *http.Client, config loadgenConfig) string {
+	resp, err := client.Get(config.URL + "/hot-keys")
+	if err != nil {
+		return "unavailable"
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		HotKeys []struct {
+			Key string `json:"key"`
+		} `json:"hot_keys"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&body) != nil {
+		return "unavailable"
+	}
+	keys := make([]string, len(body.HotKeys))
+	for i, hotKey := range body.HotKeys {
+		keys[i] = hotKey.Key
+	}
+	return "[" + strings.Join(keys, ",") + "]"
+}
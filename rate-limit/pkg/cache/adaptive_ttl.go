@@ -0,0 +1,33 @@
+package cache
+
+import "time"
+
+// AdaptiveTTLConfig 按热度计算本地缓存过期时间的配置
+type AdaptiveTTLConfig struct {
+	// 统计窗口内的访问次数等于热点阈值时的过期时间
+	BaseTTL time.Duration
+	// 过期时间的下限，key冷却后缩短到此值
+	MinTTL time.Duration
+	// 过期时间的上限
+	MaxTTL time.Duration
+}
+
+// DefaultAdaptiveTTLConfig 默认的按热度计算过期时间配置
+var DefaultAdaptiveTTLConfig = AdaptiveTTLConfig{
+	BaseTTL: 5 * time.Minute,
+	MinTTL:  30 * time.Second,
+	MaxTTL:  30 * time.Minute,
+}
+
+// TTL 按访问次数与热点阈值的比例计算过期时间：TTL = BaseTTL × count / threshold，限制在[MinTTL, MaxTTL]之间
+// 访问越频繁的key缓存越久；访问减少后过期时间随之缩短，缓存的值更快地从Redis刷新
+func (c AdaptiveTTLConfig) TTL(count, threshold int64) time.Duration {
+	if threshold <= 0 {
+		return c.BaseTTL
+	}
+	ttl := float64(c.BaseTTL) * float64(count) / float64(threshold)
+	if ttl >= float64(c.MaxTTL) {
+		return c.MaxTTL
+	}
+	return max(time.Duration(ttl), c.MinTTL)
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BroadcastConfig 热点key广播配置
+type BroadcastConfig struct {
+	// 广播热点key的Redis频道
+	Channel string
+	// 收到的热点key在本地缓存中的过期时间
+	CacheTTL time.Duration
+	// 过期时间的随机抖动比例，见 Jitter；收到同一条广播的实例不会同时过期
+	TTLJitter float64
+	// 收到其他实例广播的热点key并写入本地缓存后调用，例如在本地把key标记为热点
+	OnReceive func(key string)
+}
+
+// DefaultBroadcastConfig 默认热点key广播配置
+var DefaultBroadcastConfig = BroadcastConfig{
+	Channel:  "hotkey:broadcast",
+	CacheTTL: 5 * time.Minute,
+}
+
+// hotKeyMessage 广播的热点key消息
+type hotKeyMessage struct {
+	// 发布消息的实例，实例忽略自己发布的消息
+	Source string `json:"source"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+}
+
+// Broadcaster 通过Redis Pub/Sub在实例之间广播热点key及其值：
+// 一个实例发现热点key并从Redis读取后发布，其他实例直接写入本地缓存预热，
+// 不需要各自重新识别热点，也不会在识别出热点之前一起访问Redis
+type Broadcaster struct {
+	config BroadcastConfig
+	client redis.UniversalClient
+	cache  *LocalCache
+	id     string
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+// NewBroadcaster 订阅广播频道并启动协程接收其他实例广播的热点key
+func NewBroadcaster(client redis.UniversalClient, cache *LocalCache, config BroadcastConfig) *Broadcaster {
+	b := &Broadcaster{
+		config: config,
+		client: client,
+		cache:  cache,
+		id:     strconv.FormatUint(rand.Uint64(), 36),
+		pubsub: client.Subscribe(context.Background(), config.Channel),
+		done:   make(chan struct{}),
+	}
+
+	// 等待订阅确认，之后发布的消息都能收到；订阅失败时接收协程会自动重连
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := b.pubsub.Receive(ctx); err != nil {
+		log.Printf("Failed to subscribe to hot key channel %s: %v", config.Channel, err)
+	}
+
+	go b.receive()
+	return b
+}
+
+// NewDefaultBroadcaster 使用默认配置创建热点key广播
+func NewDefaultBroadcaster(client redis.UniversalClient, cache *LocalCache) *Broadcaster {
+	return NewBroadcaster(client, cache, DefaultBroadcastConfig)
+}
+
+// Publish 向其他实例广播热点key及其当前值
+func (b *Broadcaster) Publish(ctx context.Context, key, value string) error {
+	data, err := json.Marshal(hotKeyMessage{Source: b.id, Key: key, Value: value})
+	if err != nil {
+		return fmt.Errorf("failed to marshal hot key message: %w", err)
+	}
+	if err := b.client.Publish(ctx, b.config.Channel, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish hot key: %w", err)
+	}
+	return nil
+}
+
+// Close 取消订阅并等待接收协程退出
+func (b *Broadcaster) Close() error {
+	err := b.pubsub.Close()
+	<-b.done
+	return err
+}
+
+// receive 接收其他实例广播的热点key并写入本地缓存
+func (b *Broadcaster) receive() {
+	defer close(b.done)
+
+	for msg := range b.pubsub.Channel() {
+		var hotKey hotKeyMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &hotKey); err != nil {
+			log.Printf("Invalid hot key message: %v", err)
+			continue
+		}
+		if hotKey.Source == b.id {
+			continue
+		}
+
+		b.cache.Set(hotKey.Key, hotKey.Value, Jitter(b.config.CacheTTL, b.config.TTLJitter))
+		if b.config.OnReceive != nil {
+			b.config.OnReceive(hotKey.Key)
+		}
+		log.Printf("Hot key pre-warmed from broadcast: %s", hotKey.Key)
+	}
+}
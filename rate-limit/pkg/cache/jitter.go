@@ -0,0 +1,15 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Jitter 在d上加减最多fraction比例的随机抖动，返回[d×(1-fraction), d×(1+fraction)]之间的时间
+// 多个实例以相同的过期时间缓存同一个热点key时，抖动让它们在不同的时刻过期，不会同时访问Redis
+func Jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d))
+}
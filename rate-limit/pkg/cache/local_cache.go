@@ -2,6 +2,7 @@ package cache
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -10,6 +11,8 @@ import (
 // LocalCache 使用patrickmn/go-cache库实现的本地缓存
 type LocalCache struct {
 	cache *cache.Cache
+	// 串行化写操作，Touch 读取后重新写入期间值不会被其他写操作修改
+	mu sync.Mutex
 }
 
 // NewLocalCache 创建一个新的本地缓存实例
@@ -31,13 +34,40 @@ func (lc *LocalCache) Get(key string) (string, bool) {
 	return "", false
 }
 
+// GetWithExpiration 获取缓存中的值及其过期时间，没有过期时间的值返回零值
+func (lc *LocalCache) GetWithExpiration(key string) (string, time.Time, bool) {
+	if value, expiration, found := lc.cache.GetWithExpiration(key); found {
+		return value.(string), expiration, true
+	}
+	return "", time.Time{}, false
+}
+
 // Set 设置缓存值，带过期时间
 func (lc *LocalCache) Set(key string, value string, duration time.Duration) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.cache.Set(key, value, duration)
+}
+
+// Touch 把已缓存的key的过期时间改为从现在起duration后，key不存在时返回false
+func (lc *LocalCache) Touch(key string, duration time.Duration) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	value, found := lc.cache.Get(key)
+	if !found {
+		return false
+	}
 	lc.cache.Set(key, value, duration)
+	return true
 }
 
 // Delete 删除缓存项
 func (lc *LocalCache) Delete(key string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
 	lc.cache.Delete(key)
 	log.Printf("Cache item deleted: %s", key)
 }
@@ -0,0 +1,127 @@
+package detector
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// accessStatsWindow 访问统计保留的秒数，也是最长的速率统计范围
+	accessStatsWindow = 60
+	// interArrivalSamples 每个key保留的访问间隔样本数，新样本覆盖最旧的样本
+	interArrivalSamples = 128
+)
+
+// AccessStats key最近的访问速率
+type AccessStats struct {
+	Key string
+	// 最近1秒、10秒、60秒内平均每秒的访问次数，只统计已经结束的整秒
+	Rate1s  float64
+	Rate10s float64
+	Rate60s float64
+	// 最近60秒内单秒最多的访问次数
+	PeakRate int64
+	// 采样的相邻两次访问间隔的p95，没有样本时为0
+	P95InterArrival time.Duration
+	// 访问间隔的样本数
+	Samples int
+}
+
+// keyAccess 单个key的访问统计
+type keyAccess struct {
+	// 每秒一个子窗口，多保留一个正在进行的秒
+	counter *windowCounter
+	// 最后一次访问的时间（Unix纳秒）
+	last int64
+	// 访问间隔样本的环形数组
+	gaps []time.Duration
+	next int
+}
+
+// accessTracker 按key统计最近60秒每秒的访问次数，并采样相邻两次访问的间隔
+// 每次访问都计入每秒的计数，速率是准确的；访问间隔按sampleRate的概率采样，每个key保留最近的样本
+type accessTracker struct {
+	mu         sync.Mutex
+	keys       map[string]*keyAccess
+	maxKeys    int
+	sampleRate float64
+}
+
+// newAccessTracker 创建访问统计，最多统计maxKeys个key，超出时新的key不统计，直到空闲的key被清理
+func newAccessTracker(maxKeys int, sampleRate float64) *accessTracker {
+	return &accessTracker{
+		keys:       make(map[string]*keyAccess),
+		maxKeys:    maxKeys,
+		sampleRate: sampleRate,
+	}
+}
+
+// record 记录key在now的一次访问
+func (t *accessTracker) record(key string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	access, exists := t.keys[key]
+	if !exists {
+		if len(t.keys) >= t.maxKeys {
+			return
+		}
+		access = &keyAccess{counter: newWindowCounter(accessStatsWindow + 1)}
+		t.keys[key] = access
+	}
+
+	nanos := now.UnixNano()
+	access.counter.add(now.Unix())
+	if access.last != 0 && rand.Float64() < t.sampleRate {
+		if len(access.gaps) < interArrivalSamples {
+			access.gaps = append(access.gaps, time.Duration(nanos-access.last))
+		} else {
+			access.gaps[access.next] = time.Duration(nanos - access.last)
+			access.next = (access.next + 1) % interArrivalSamples
+		}
+	}
+	access.last = nanos
+}
+
+// stats 返回key截至now的访问速率，key没有被统计时返回false
+func (t *accessTracker) stats(key string, now time.Time) (AccessStats, bool) {
+	t.mu.Lock()
+	access, exists := t.keys[key]
+	if !exists {
+		t.mu.Unlock()
+		return AccessStats{Key: key}, false
+	}
+	// 只统计已经结束的整秒
+	slot := now.Unix() - 1
+	stats := AccessStats{
+		Key:      key,
+		Rate1s:   float64(access.counter.countRange(slot, 1)),
+		Rate10s:  float64(access.counter.countRange(slot, 10)) / 10,
+		Rate60s:  float64(access.counter.countRange(slot, accessStatsWindow)) / accessStatsWindow,
+		PeakRate: access.counter.peak(slot, accessStatsWindow),
+		Samples:  len(access.gaps),
+	}
+	gaps := slices.Clone(access.gaps)
+	t.mu.Unlock()
+
+	if len(gaps) > 0 {
+		slices.Sort(gaps)
+		stats.P95InterArrival = gaps[(len(gaps)-1)*95/100]
+	}
+	return stats, true
+}
+
+// evictIdle 清理now之前60秒内没有访问的key
+func (t *accessTracker) evictIdle(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	idle := now.Add(-accessStatsWindow * time.Second).UnixNano()
+	for key, access := range t.keys {
+		if access.last < idle {
+			delete(t.keys, key)
+		}
+	}
+}
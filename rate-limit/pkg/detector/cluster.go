@@ -0,0 +1,212 @@
+package detector
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ClusterConfig 集群热点聚合配置
+type ClusterConfig struct {
+	// 保存全局访问计数的有序集合前缀，每个统计窗口一个有序集合
+	KeyPrefix string
+	// 统计窗口
+	Window time.Duration
+	// 推送本地访问增量、拉取全局热点的间隔
+	SyncInterval time.Duration
+	// 全局访问阈值，所有实例在统计窗口内的访问次数之和超过此值将被视为热点key
+	Threshold int64
+	// 每次拉取的全局热点数量
+	TopN int
+}
+
+// DefaultClusterConfig 默认集群热点聚合配置
+var DefaultClusterConfig = ClusterConfig{
+	KeyPrefix:    "hotkey:cluster:",
+	Window:       time.Second * 10, // 10秒内
+	SyncInterval: time.Second,      // 每秒同步一次
+	Threshold:    1000,             // 所有实例共1000次访问视为热点
+	TopN:         100,
+}
+
+// ClusterAggregator 汇总所有实例的访问计数检测全局热点：
+// 每个实例定期把各key的访问增量ZINCRBY到当前窗口的有序集合，再读取全局访问次数最多的key，
+// 超过全局阈值的key在本地标记为热点，这样分散在多个实例上、单个实例达不到阈值的热点也能被发现
+// 窗口按本地时钟划分，各实例的时钟需要大致同步
+type ClusterAggregator struct {
+	config   ClusterConfig
+	client   redis.UniversalClient
+	detector *HotKeyDetector
+
+	mu     sync.Mutex
+	deltas map[string]int64 // 上次同步后的本地访问增量
+	top    []KeyCount       // 上次同步得到的全局热点
+
+	stop     chan struct{}
+	stopOnce sync.Once // 重复调用Close时只关闭一次stop
+	done     chan struct{}
+}
+
+// NewClusterAggregator 创建集群热点聚合器并启动后台同步协程，之后detector记录的每次访问都会计入增量
+func NewClusterAggregator(client redis.UniversalClient, detector *HotKeyDetector, config ClusterConfig) *ClusterAggregator {
+	a := &ClusterAggregator{
+		config:   config,
+		client:   client,
+		detector: detector,
+		deltas:   make(map[string]int64),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	detector.cluster.Store(a)
+	go a.run()
+
+	return a
+}
+
+// NewDefaultClusterAggregator 使用默认配置创建集群热点聚合器
+func NewDefaultClusterAggregator(client redis.UniversalClient, detector *HotKeyDetector) *ClusterAggregator {
+	return NewClusterAggregator(client, detector, DefaultClusterConfig)
+}
+
+// record 记录一次本地访问
+func (a *ClusterAggregator) record(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.deltas[key]++
+}
+
+// GlobalTopKeys 返回上次同步得到的全局访问次数最多的n个key，按访问次数从高到低排序，n不大于0时返回全部
+func (a *ClusterAggregator) GlobalTopKeys(n int) []KeyCount {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if n <= 0 || n > len(a.top) {
+		n = len(a.top)
+	}
+	return append([]KeyCount(nil), a.top[:n]...)
+}
+
+// Close 停止同步，并推送最后一次的访问增量
+func (a *ClusterAggregator) Close() error {
+	a.detector.cluster.CompareAndSwap(a, nil)
+	a.stopOnce.Do(func() { close(a.stop) })
+	<-a.done
+	return nil
+}
+
+// run 定期同步访问计数
+func (a *ClusterAggregator) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.sync(context.Background())
+		case <-a.stop:
+			a.push(context.Background(), time.Now())
+			return
+		}
+	}
+}
+
+// sync 推送本地访问增量，拉取全局热点并在本地标记
+func (a *ClusterAggregator) sync(ctx context.Context) {
+	now := time.Now()
+	a.push(ctx, now)
+
+	top, err := a.pull(ctx, now)
+	if err != nil {
+		log.Printf("Failed to pull cluster hot keys: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	a.top = top
+	a.mu.Unlock()
+
+	for _, item := range top {
+		if item.Count < a.config.Threshold {
+			break
+		}
+		if a.detector.hotKeys.mark(item.Key, WindowCluster, item.Count, a.detector.config.HotKeyExpiration) {
+			log.Printf("Cluster hot key detected: %s with %d accesses in %v", item.Key, item.Count, a.config.Window)
+		}
+	}
+}
+
+// push 把本地访问增量累加到当前窗口的有序集合，失败时丢弃这部分增量，避免Redis不可用时增量无限增长
+func (a *ClusterAggregator) push(ctx context.Context, now time.Time) {
+	a.mu.Lock()
+	deltas := a.deltas
+	a.deltas = make(map[string]int64, len(deltas))
+	a.mu.Unlock()
+
+	if len(deltas) == 0 {
+		return
+	}
+
+	windowKey := a.windowKey(now, 0)
+	pipe := a.client.Pipeline()
+	for key, delta := range deltas {
+		pipe.ZIncrBy(ctx, windowKey, float64(delta), key)
+	}
+	// 保留上一个窗口用于滑动估计
+	pipe.PExpire(ctx, windowKey, a.config.Window*2)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to push %d hot key deltas: %v", len(deltas), err)
+	}
+}
+
+// pull 读取全局访问次数最多的key
+// 与 topKCounter 相同，估计值 = 当前窗口的计数 + 上一个窗口的计数 × 上一个窗口仍在滑动窗口内的比例
+func (a *ClusterAggregator) pull(ctx context.Context, now time.Time) ([]KeyCount, error) {
+	pipe := a.client.Pipeline()
+	curr := pipe.ZRevRangeWithScores(ctx, a.windowKey(now, 0), 0, int64(a.config.TopN-1))
+	prev := pipe.ZRevRangeWithScores(ctx, a.windowKey(now, -1), 0, int64(a.config.TopN-1))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	elapsed := now.UnixNano() % int64(a.config.Window)
+	weight := float64(int64(a.config.Window)-elapsed) / float64(a.config.Window)
+
+	counts := make(map[string]float64, a.config.TopN)
+	for _, z := range curr.Val() {
+		counts[z.Member.(string)] += z.Score
+	}
+	for _, z := range prev.Val() {
+		counts[z.Member.(string)] += z.Score * weight
+	}
+
+	top := make([]KeyCount, 0, len(counts))
+	for key, count := range counts {
+		if count >= 1 {
+			top = append(top, KeyCount{Key: key, Count: int64(count)})
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Key < top[j].Key
+	})
+	if len(top) > a.config.TopN {
+		top = top[:a.config.TopN]
+	}
+	return top, nil
+}
+
+// windowKey 返回now所在窗口向前偏移offset个窗口的有序集合
+func (a *ClusterAggregator) windowKey(now time.Time, offset int64) string {
+	window := now.UnixNano()/int64(a.config.Window) + offset
+	return a.config.KeyPrefix + strconv.FormatInt(window, 10)
+}
@@ -0,0 +1,42 @@
+package detector
+
+import (
+	"math"
+	"time"
+)
+
+// decayClearRatio 衰减计分模式下热点标记的清除线与阈值的比例
+// 计分降到阈值以下、但仍高于清除线时保持热点，避免访问量在阈值附近波动时热点标记反复出现和消失
+const decayClearRatio = 0.5
+
+// decayCounter 指数衰减计分：每次访问加一，没有访问时按时间常数tau连续衰减
+// 访问速率稳定为r时计分收敛到 r × tau，tau取统计窗口时，计分与窗口内的访问次数相当
+type decayCounter struct {
+	score float64
+	// 最后一次更新计分的时间戳（纳秒）
+	updatedAt int64
+}
+
+// add 把计分衰减到now并加一，返回新的计分
+func (c *decayCounter) add(now int64, tau time.Duration) float64 {
+	c.score = c.value(now, tau) + 1
+	c.updatedAt = now
+	return c.score
+}
+
+// value 返回衰减到now的计分
+func (c *decayCounter) value(now int64, tau time.Duration) float64 {
+	elapsed := now - c.updatedAt
+	if elapsed <= 0 {
+		return c.score
+	}
+	return c.score * math.Exp(-float64(elapsed)/float64(tau))
+}
+
+// decayDuration 返回计分从score衰减到target所需的时间，score不大于target时返回0
+func decayDuration(score, target float64, tau time.Duration) time.Duration {
+	if score <= target || target <= 0 {
+		return 0
+	}
+	return time.Duration(float64(tau) * math.Log(score/target))
+}
@@ -0,0 +1,97 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+
+	"redis-learning/pkg/eventbus"
+)
+
+// EventFeedConfig 页面访问事件订阅配置
+type EventFeedConfig struct {
+	// 订阅的事件总线名称，需要与发布方一致
+	Bus string
+	// 消费者组名称：同一个组的实例分摊事件，每次访问只计入其中一个实例的检测器，
+	// 配合 ClusterAggregator 汇总后得到全局的热度；每个实例都需要收到全部访问时为各实例设置不同的组
+	Group string
+	// 消费者名称，为空时使用主机名。名称需要在重启后保持不变：重启后先处理自己崩溃前领取但没有确认的事件，
+	// 也不会在消费者组中留下不再使用的消费者；同一台主机运行多个实例时需要为各实例设置不同的名称
+	Consumer string
+	// 页面路径转换为key时添加的前缀，如页面 /home 计为 page:/home
+	KeyPrefix string
+}
+
+// DefaultEventFeedConfig 默认页面访问事件订阅配置
+var DefaultEventFeedConfig = EventFeedConfig{
+	Bus:       eventbus.SharedBus,
+	Group:     "rate-limit",
+	KeyPrefix: "page:",
+}
+
+// EventFeed 从事件总线订阅其他服务发布的页面访问事件（如 uv-pv-collector 记录的访问），计入热点key检测：
+// 每个事件按 KeyPrefix+页面 调用一次 RecordAccess，被频繁访问的页面和直接请求的key一样被标记为热点
+type EventFeed struct {
+	config   EventFeedConfig
+	detector *HotKeyDetector
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEventFeed 创建页面访问事件订阅并启动后台订阅协程
+func NewEventFeed(client redis.UniversalClient, detector *HotKeyDetector, config EventFeedConfig) *EventFeed {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &EventFeed{
+		config:   config,
+		detector: detector,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go f.run(ctx, eventbus.NewDefaultBus(client, config.Bus, "rate-limit"))
+
+	return f
+}
+
+// NewDefaultEventFeed 使用默认配置创建页面访问事件订阅
+func NewDefaultEventFeed(client redis.UniversalClient, detector *HotKeyDetector) *EventFeed {
+	return NewEventFeed(client, detector, DefaultEventFeedConfig)
+}
+
+// Close 停止订阅，等待正在处理的事件完成
+func (f *EventFeed) Close() error {
+	f.cancel()
+	<-f.done
+	return nil
+}
+
+// run 订阅页面访问事件直到关闭
+// 实例没有重启时，崩溃前没有确认的事件由同组的其他实例接管
+func (f *EventFeed) run(ctx context.Context, bus *eventbus.Bus) {
+	defer close(f.done)
+
+	consumer := f.config.Consumer
+	if consumer == "" {
+		consumer, _ = os.Hostname()
+	}
+	log.Printf("Subscribing to %s as %s in group %s", bus.Stream(eventbus.TopicPageAccess), consumer, f.config.Group)
+
+	err := bus.Subscribe(ctx, eventbus.TopicPageAccess, f.config.Group, consumer, f.handle)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("Error subscribing to page access events: %v", err)
+	}
+}
+
+// handle 把一次页面访问计入检测器，无法解析的事件直接丢弃
+func (f *EventFeed) handle(ctx context.Context, event eventbus.Event) error {
+	var access eventbus.PageAccess
+	if err := event.Decode(&access); err != nil || access.Page == "" {
+		log.Printf("Dropping invalid page access event %s from %s", event.ID, event.Source)
+		return nil
+	}
+	f.detector.RecordAccess(f.config.KeyPrefix + access.Page)
+	return nil
+}
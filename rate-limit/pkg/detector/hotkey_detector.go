@@ -1,21 +1,54 @@
 package detector
 
 import (
+	"cmp"
 	"log"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"rate-limit/pkg/cache"
 )
 
 // HotKeyConfig 热点key检测器配置
 type HotKeyConfig struct {
 	// 访问阈值，超过此值将被视为热点key
-	Threshold int64
+	Threshold int64 `yaml:"threshold"`
 	// 统计窗口，在此时间范围内统计访问次数
-	Window time.Duration
-	// 热点key的过期时间
-	HotKeyExpiration time.Duration
+	Window time.Duration `yaml:"window"`
+	// 热点key的过期时间，启用衰减计分时不使用
+	HotKeyExpiration time.Duration `yaml:"expiration"`
+	// 统计窗口划分的子窗口数量，窗口每次滑动一个子窗口的长度
+	Buckets int `yaml:"buckets"`
+	// Top-K统计保留的key数量，大于0时改用count-min sketch估计访问次数，
+	// 内存占用与key的数量无关，并可通过 GetTopKeys 获取访问次数最多的key
+	TopK int `yaml:"top_k"`
+	// 启用指数衰减计分：每个key的计分以统计窗口为时间常数连续衰减，热度随访问量平滑升降，
+	// 计分降到阈值的一半以下时热点标记自动清除，而不是固定保持HotKeyExpiration
+	// 同时设置TopK时使用Top-K统计，不启用衰减计分
+	Decay bool `yaml:"decay"`
+	// 统计最近60秒访问速率的key数量上限，为0时不统计，见 GetAccessStats
+	StatsMaxKeys int `yaml:"stats_max_keys"`
+	// 访问间隔的采样比例，0到1之间
+	StatsSampleRate float64 `yaml:"stats_sample_rate"`
+	// 额外的检测窗口，与Window和Threshold同时生效，任何一个窗口内的访问次数达到该窗口的阈值都标记为热点：
+	// 短窗口配合较低的阈值发现突发流量，长窗口发现持续偏高的流量；每个窗口为每个key单独计数
+	Windows []DetectionWindow `yaml:"windows"`
+}
+
+// DetectionWindow 热点检测窗口及其阈值
+type DetectionWindow struct {
+	Window    time.Duration `yaml:"window"`
+	Threshold int64         `yaml:"threshold"`
+}
+
+// WindowCluster 集群热点聚合识别的热点在 HotKey.Window 中的值
+const WindowCluster = "cluster"
+
+// windowDetector 额外检测窗口的计数
+type windowDetector struct {
+	DetectionWindow
+	bucketSize time.Duration
+	counters   map[string]*windowCounter
 }
 
 // DefaultHotKeyConfig 默认热点key检测配置
@@ -23,28 +56,75 @@ var DefaultHotKeyConfig = HotKeyConfig{
 	Threshold:        100,              // 100次访问视为热点
 	Window:           time.Second * 10, // 10秒内
 	HotKeyExpiration: time.Minute * 5,  // 热点key标记5分钟后过期
+	Buckets:          10,               // 划分为10个1秒的子窗口
+	StatsMaxKeys:     10000,            // 最多统计10000个key的访问速率
+	StatsSampleRate:  0.1,              // 采样10%的访问间隔
 }
 
 // HotKeyDetector 热点key检测器
 type HotKeyDetector struct {
 	config      HotKeyConfig
-	localCache  *cache.LocalCache
+	counters    map[string]*windowCounter // 每个key的滑动窗口计数
+	scores      map[string]*decayCounter  // 启用衰减计分时每个key的计分
+	bucketSize  time.Duration             // 子窗口长度
 	counterLock sync.RWMutex
-	hotKeys     *cache.LocalCache // 用于存储热点key
+	hotKeys     *hotKeyRegistry   // 热点key登记表
+	topK        *topKCounter      // 启用Top-K统计时代替逐个key的计数
+	stats       *accessTracker    // 最近60秒的访问速率，未启用时为nil
+	windows     []*windowDetector // 额外的检测窗口，按窗口长度从短到长排列
+	// 启用集群热点聚合时，访问同时计入聚合器的增量，见 NewClusterAggregator
+	cluster atomic.Pointer[ClusterAggregator]
+	// 热点key状态变化的回调，见 OnEvent
+	hooks    []func(HotKeyEvent)
+	hookLock sync.RWMutex
+
+	stop     chan struct{}
+	stopOnce sync.Once      // 重复调用Close时只关闭一次stop
+	wg       sync.WaitGroup // 等待后台协程退出
 }
 
 // NewHotKeyDetector 创建一个新的热点key检测器
 func NewHotKeyDetector(config HotKeyConfig) *HotKeyDetector {
-	// 创建两个缓存：一个用于计数，一个用于存储热点key
-	counterCache := cache.NewLocalCache(config.Window, time.Minute)
-	hotKeysCache := cache.NewLocalCache(config.HotKeyExpiration, time.Minute)
+	if config.Buckets <= 0 {
+		config.Buckets = DefaultHotKeyConfig.Buckets
+	}
 
-	return &HotKeyDetector{
+	d := &HotKeyDetector{
 		config:      config,
-		localCache:  counterCache,
+		counters:    make(map[string]*windowCounter),
+		scores:      make(map[string]*decayCounter),
+		bucketSize:  max(config.Window/time.Duration(config.Buckets), time.Millisecond),
 		counterLock: sync.RWMutex{},
-		hotKeys:     hotKeysCache,
+		stop:        make(chan struct{}),
+	}
+	d.hotKeys = newHotKeyRegistry(d.emit)
+	for _, window := range config.Windows {
+		d.windows = append(d.windows, &windowDetector{
+			DetectionWindow: window,
+			bucketSize:      max(window.Window/time.Duration(config.Buckets), time.Millisecond),
+			counters:        make(map[string]*windowCounter),
+		})
+	}
+	slices.SortFunc(d.windows, func(a, b *windowDetector) int {
+		return cmp.Compare(a.Window, b.Window)
+	})
+	if config.TopK > 0 {
+		d.topK = newTopKCounter(config.TopK, config.Window)
 	}
+	if config.TopK <= 0 || len(d.windows) > 0 {
+		// 启动一个协程定期清理窗口内没有访问的计数器
+		d.wg.Add(1)
+		go d.cleanup()
+	}
+	if config.StatsMaxKeys > 0 {
+		d.stats = newAccessTracker(config.StatsMaxKeys, config.StatsSampleRate)
+		d.wg.Add(1)
+		go d.cleanupStats()
+	}
+	// 热点标记过期时没有访问也要及时发出冷却事件，每个子窗口清理一次过期的标记
+	d.wg.Add(1)
+	go d.expireHotKeys()
+	return d
 }
 
 // NewDefaultHotKeyDetector 使用默认配置创建热点key检测器
@@ -53,62 +133,341 @@ func NewDefaultHotKeyDetector() *HotKeyDetector {
 }
 
 // RecordAccess 记录key的访问并检测是否为热点key
+// 已是热点的key同样计数，用于更新它的热度；访问次数仍超过阈值时延长热点标记的过期时间
 func (d *HotKeyDetector) RecordAccess(key string) bool {
-	// 检查key是否已经是热点key
-	if _, isHot := d.hotKeys.Get(key); isHot {
+	// 更新访问计数
+	count := d.countAccess(key)
+	if d.stats != nil {
+		d.stats.record(key, time.Now())
+	}
+	if cluster := d.cluster.Load(); cluster != nil {
+		cluster.record(key)
+	}
+
+	// 检查各窗口是否超过阈值，多个窗口同时超过时记录最短的窗口
+	window, windowCount, hot := d.countWindows(key)
+	expiration := d.config.HotKeyExpiration
+	if d.decaying() {
+		if !hot {
+			return d.markDecayed(key, count)
+		}
+		// 衰减计分不使用固定的过期时间，额外窗口标记的热点保持一个窗口的长度，之后由衰减计分接管
+		expiration = window
+	} else if count >= d.config.Threshold && (!hot || d.config.Window <= window) {
+		window, windowCount, hot = d.config.Window, count, true
+	}
+
+	if hot {
+		if d.hotKeys.mark(key, window.String(), count, expiration) {
+			log.Printf("Hot key detected: %s with %d accesses in %v", key, windowCount, window)
+		}
 		return true
 	}
 
-	// 更新访问计数
+	return d.hotKeys.update(key, count)
+}
+
+// countWindows 在各个额外的检测窗口中计数，返回超过阈值的最短窗口及其中的访问次数
+func (d *HotKeyDetector) countWindows(key string) (time.Duration, int64, bool) {
+	if len(d.windows) == 0 {
+		return 0, 0, false
+	}
+
 	d.counterLock.Lock()
 	defer d.counterLock.Unlock()
 
-	count := int64(1)
-	if val, exists := d.localCache.Get(key); exists {
-		currentCount, _ := time.ParseDuration(val)
-		count = int64(currentCount) + 1
+	now := time.Now().UnixNano()
+	var window time.Duration
+	var windowCount int64
+	hot := false
+	for _, w := range d.windows {
+		counter, exists := w.counters[key]
+		if !exists {
+			counter = newWindowCounter(d.config.Buckets)
+			w.counters[key] = counter
+		}
+		count := counter.add(now / int64(w.bucketSize))
+		if !hot && count >= w.Threshold {
+			window, windowCount, hot = w.Window, count, true
+		}
 	}
+	return window, windowCount, hot
+}
+
+// decaying 判断是否使用衰减计分
+func (d *HotKeyDetector) decaying() bool {
+	return d.config.Decay && d.topK == nil
+}
 
-	// 将count转换为string存储
-	d.localCache.Set(key, (time.Duration(count)).String(), d.config.Window)
+// markDecayed 按衰减计分标记或刷新热点key
+// 热点标记的过期时间是计分在没有新访问时衰减到清除线所需的时间，每次访问都会重新计算，
+// 访问量下降后标记随计分衰减自动过期
+func (d *HotKeyDetector) markDecayed(key string, score int64) bool {
+	clearLine := float64(d.config.Threshold) * decayClearRatio
+	expiration := decayDuration(float64(score), clearLine, d.config.Window)
 
-	// 检查是否超过阈值
-	if count >= d.config.Threshold {
-		log.Printf("Hot key detected: %s with %d accesses in %v", key, count, d.config.Window)
-		d.hotKeys.Set(key, "true", d.config.HotKeyExpiration)
+	if score >= d.config.Threshold {
+		if !d.hotKeys.extend(key, score, expiration) {
+			d.hotKeys.mark(key, d.config.Window.String(), score, expiration)
+			log.Printf("Hot key detected: %s with decayed score %d", key, score)
+		}
 		return true
 	}
 
-	return false
+	return d.hotKeys.extend(key, score, expiration)
+}
+
+// countAccess 访问计数加一，返回统计窗口内的访问次数，启用衰减计分时返回衰减后的计分
+func (d *HotKeyDetector) countAccess(key string) int64 {
+	if d.topK != nil {
+		return d.topK.record(key)
+	}
+
+	d.counterLock.Lock()
+	defer d.counterLock.Unlock()
+
+	if d.config.Decay {
+		counter, exists := d.scores[key]
+		if !exists {
+			counter = &decayCounter{}
+			d.scores[key] = counter
+		}
+		return int64(counter.add(time.Now().UnixNano(), d.config.Window))
+	}
+
+	counter, exists := d.counters[key]
+	if !exists {
+		counter = newWindowCounter(d.config.Buckets)
+		d.counters[key] = counter
+	}
+	return counter.add(d.currentSlot())
+}
+
+// currentSlot 返回当前时间所在的子窗口序号
+func (d *HotKeyDetector) currentSlot() int64 {
+	return time.Now().UnixNano() / int64(d.bucketSize)
+}
+
+// cleanup 每经过一个统计窗口，清理窗口内没有访问的计数器（包括额外的检测窗口）和已衰减到不足一次访问的计分
+func (d *HotKeyDetector) cleanup() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.evictIdle()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// evictIdle 清理各自窗口内没有访问的计数器和衰减到1以下的计分
+func (d *HotKeyDetector) evictIdle() {
+	d.counterLock.Lock()
+	defer d.counterLock.Unlock()
+
+	slot := d.currentSlot()
+	for key, counter := range d.counters {
+		if counter.count(slot) == 0 {
+			delete(d.counters, key)
+		}
+	}
+	now := time.Now().UnixNano()
+	for key, counter := range d.scores {
+		if counter.value(now, d.config.Window) < 1 {
+			delete(d.scores, key)
+		}
+	}
+	for _, w := range d.windows {
+		slot := now / int64(w.bucketSize)
+		for key, counter := range w.counters {
+			if counter.count(slot) == 0 {
+				delete(w.counters, key)
+			}
+		}
+	}
+}
+
+// cleanupStats 每10秒清理一次60秒内没有访问的访问速率统计
+func (d *HotKeyDetector) cleanupStats() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.stats.evictIdle(time.Now())
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// expireHotKeys 定期清理过期的热点标记
+func (d *HotKeyDetector) expireHotKeys() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.bucketSize)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.hotKeys.expire()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// Close 停止后台的清理协程并等待退出，之后检测器仍然可用，但过期的计数和热点标记不再被主动清理
+// 启用了集群热点聚合或热点排行时，应先关闭它们
+func (d *HotKeyDetector) Close() error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	d.wg.Wait()
+	return nil
+}
+
+// OnEvent 注册热点key状态变化的回调：key被识别为热点时收到 EventHot，标记过期或被清除时收到 EventCool
+// 过期的冷却事件最多延迟一个子窗口；回调在发生变化的协程中同步调用，不应阻塞
+func (d *HotKeyDetector) OnEvent(hook func(HotKeyEvent)) {
+	d.hookLock.Lock()
+	defer d.hookLock.Unlock()
+
+	d.hooks = append(d.hooks, hook)
+}
+
+// OnHotKeyDetected 注册key被识别为热点时的回调，包括通过 MarkHotKey、PinHotKey 标记的热点
+func (d *HotKeyDetector) OnHotKeyDetected(hook func(HotKeyEvent)) {
+	d.OnEvent(func(event HotKeyEvent) {
+		if event.Type == EventHot {
+			hook(event)
+		}
+	})
+}
+
+// OnHotKeyExpired 注册热点标记过期或被清除时的回调，事件中的热度为最后一次更新的值
+func (d *HotKeyDetector) OnHotKeyExpired(hook func(HotKeyEvent)) {
+	d.OnEvent(func(event HotKeyEvent) {
+		if event.Type == EventCool {
+			hook(event)
+		}
+	})
+}
+
+// emit 把事件依次交给已注册的回调
+func (d *HotKeyDetector) emit(events []HotKeyEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	d.hookLock.RLock()
+	defer d.hookLock.RUnlock()
+
+	for _, event := range events {
+		for _, hook := range d.hooks {
+			hook(event)
+		}
+	}
 }
 
 // IsHotKey 检查key是否是热点key
 func (d *HotKeyDetector) IsHotKey(key string) bool {
-	_, isHot := d.hotKeys.Get(key)
-	return isHot
+	return d.hotKeys.contains(key)
 }
 
 // GetAccessCount 获取key的访问次数
+// 启用Top-K统计时返回估计值，启用衰减计分时返回当前的计分
 func (d *HotKeyDetector) GetAccessCount(key string) int64 {
+	if d.topK != nil {
+		return d.topK.estimate(key)
+	}
+
 	d.counterLock.RLock()
 	defer d.counterLock.RUnlock()
 
-	if val, exists := d.localCache.Get(key); exists {
-		currentCount, _ := time.ParseDuration(val)
-		return int64(currentCount)
+	if d.config.Decay {
+		if counter, exists := d.scores[key]; exists {
+			return int64(counter.value(time.Now().UnixNano(), d.config.Window))
+		}
+		return 0
+	}
+
+	if counter, exists := d.counters[key]; exists {
+		return counter.count(d.currentSlot())
 	}
 	return 0
 }
 
-// GetHotKeys 获取所有热点key
-func (d *HotKeyDetector) GetHotKeys() []string {
-	// 这里实现一个简单版本，实际上go-cache没有提供直接获取所有键的方法
-	// 在实际应用中，我们可能需要另外维护一个热点key的列表
-	return []string{}
+// GetAccessStats 返回key最近1秒、10秒、60秒的访问速率、峰值和访问间隔的p95
+// key在60秒内没有访问、超出StatsMaxKeys或未启用统计时返回false
+func (d *HotKeyDetector) GetAccessStats(key string) (AccessStats, bool) {
+	if d.stats == nil {
+		return AccessStats{Key: key}, false
+	}
+	return d.stats.stats(key, time.Now())
+}
+
+// GetHotKey 返回key的热点标记，包括触发标记的检测窗口；key不是热点时返回false
+func (d *HotKeyDetector) GetHotKey(key string) (HotKey, bool) {
+	return d.hotKeys.get(key)
+}
+
+// GetHotKeys 获取所有未过期的热点key，按热度从高到低排序
+func (d *HotKeyDetector) GetHotKeys() []HotKey {
+	return d.hotKeys.list()
+}
+
+// GetTopKeys 返回统计窗口内访问次数最多的n个key及其估计的访问次数，按访问次数从高到低排序
+// n不大于0时返回全部Top-K；未启用Top-K统计时返回nil
+func (d *HotKeyDetector) GetTopKeys(n int) []KeyCount {
+	if d.topK == nil {
+		return nil
+	}
+	return d.topK.topN(n)
+}
+
+// MarkHotKey 直接把key标记为热点，用于其他实例已识别出的热点，热度为本地的访问次数
+func (d *HotKeyDetector) MarkHotKey(key string) {
+	if d.hotKeys.mark(key, "", d.GetAccessCount(key), d.config.HotKeyExpiration) {
+		log.Printf("Hot key marked: %s", key)
+	}
+}
+
+// PinHotKey 把key标记为不会过期的热点，用于预先知道的热点（如秒杀开始前的商品），不必等访问次数达到阈值
+// 标记一直有效，直到 UnpinHotKey 或 ClearHotKey
+func (d *HotKeyDetector) PinHotKey(key string) {
+	if d.hotKeys.pin(key, d.GetAccessCount(key), d.config.HotKeyExpiration) {
+		log.Printf("Hot key pinned: %s", key)
+	}
+}
+
+// UnpinHotKey 取消key的预热，热点标记保留一个过期时间后按访问次数正常过期；启用衰减计分时按当前计分过期
+func (d *HotKeyDetector) UnpinHotKey(key string) {
+	expiration := d.config.HotKeyExpiration
+	if d.decaying() {
+		// 衰减计分时过期时间随每次访问重新计算，不额外保留
+		expiration = 0
+	}
+	d.hotKeys.unpin(key, expiration)
+	log.Printf("Hot key unpinned: %s", key)
 }
 
 // ClearHotKey 清除指定key的热点标记
 func (d *HotKeyDetector) ClearHotKey(key string) {
-	d.hotKeys.Delete(key)
+	d.hotKeys.remove(key)
 	log.Printf("Hot key mark removed: %s", key)
 }
+
+// ClearHotKeys 清除所有热点标记，返回清除的数量
+func (d *HotKeyDetector) ClearHotKeys() int {
+	n := d.hotKeys.removeAll()
+	log.Printf("All hot key marks removed: %d", n)
+	return n
+}
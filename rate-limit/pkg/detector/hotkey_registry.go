@@ -0,0 +1,254 @@
+package detector
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// HotKey 热点key及其热度
+type HotKey struct {
+	Key string `json:"key"`
+	// 首次被识别为热点的时间
+	DetectedAt time.Time `json:"detected_at"`
+	// 热点标记的过期时间，持续高频访问时会延长；预热的key在取消预热前不会过期
+	ExpiresAt time.Time `json:"expires_at"`
+	// 当前统计窗口内的访问次数，启用衰减计分时为衰减后的计分
+	Score int64 `json:"score"`
+	// 是否为预先声明的热点，见 HotKeyDetector.PinHotKey
+	Pinned bool `json:"pinned,omitempty"`
+	// 最近一次触发热点标记的检测窗口，如 "1s"、"10s"；集群聚合识别的热点为 "cluster"，直接标记或预热的热点为空
+	Window string `json:"window,omitempty"`
+}
+
+// active 判断热点标记在now时是否有效
+func (h *HotKey) active(now time.Time) bool {
+	return h.Pinned || now.Before(h.ExpiresAt)
+}
+
+// 热点key事件的类型
+const (
+	// EventHot key被识别为热点
+	EventHot = "hot"
+	// EventCool 热点标记过期或被清除
+	EventCool = "cool"
+)
+
+// HotKeyEvent 热点key的状态变化事件，每个 EventHot 之后都有一个对应的 EventCool
+type HotKeyEvent struct {
+	Type  string    `json:"type"`
+	Key   string    `json:"key"`
+	Score int64     `json:"score"`
+	Time  time.Time `json:"time"`
+}
+
+// hotKeyRegistry 热点key登记表，记录每个热点key的识别时间、过期时间和热度
+// 登记和移除热点key时通过notify发出事件，事件在释放锁之后发出，回调可以再次访问登记表
+type hotKeyRegistry struct {
+	mu      sync.RWMutex
+	entries map[string]*HotKey
+	notify  func(events []HotKeyEvent)
+}
+
+// newHotKeyRegistry 创建一个新的热点key登记表，notify在热点key登记或移除时调用
+func newHotKeyRegistry(notify func(events []HotKeyEvent)) *hotKeyRegistry {
+	return &hotKeyRegistry{
+		entries: make(map[string]*HotKey),
+		notify:  notify,
+	}
+}
+
+// mark 标记或刷新热点key，window为触发标记的检测窗口，已是热点时保留首次识别时间，只延长过期时间
+// 返回true表示key是新识别出的热点
+func (r *hotKeyRegistry) mark(key, window string, score int64, expiration time.Duration) bool {
+	r.mu.Lock()
+	now := time.Now()
+	entry, exists := r.entries[key]
+	if exists && entry.active(now) {
+		entry.ExpiresAt = now.Add(expiration)
+		entry.Score = score
+		if window != "" {
+			entry.Window = window
+		}
+		r.mu.Unlock()
+		return false
+	}
+
+	var events []HotKeyEvent
+	if exists {
+		// 过期但还没有被清理的登记，先发出它的冷却事件
+		events = append(events, coolEvent(entry, now))
+	}
+	r.entries[key] = &HotKey{Key: key, DetectedAt: now, ExpiresAt: now.Add(expiration), Score: score, Window: window}
+	events = append(events, HotKeyEvent{Type: EventHot, Key: key, Score: score, Time: now})
+	r.mu.Unlock()
+
+	r.notify(events)
+	return true
+}
+
+// update 更新热点key的热度，key不是热点时返回false
+func (r *hotKeyRegistry) update(key string, score int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.entries[key]
+	if !exists || !entry.active(time.Now()) {
+		return false
+	}
+	entry.Score = score
+	return true
+}
+
+// extend 更新热点key的热度，过期时间早于从现在起的expiration时延长到该时间，key不是热点时返回false
+// 过期时间只延长不缩短，集群聚合或广播标记的热点不会因为本地访问少而提前过期
+func (r *hotKeyRegistry) extend(key string, score int64, expiration time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	entry, exists := r.entries[key]
+	if !exists || !entry.active(now) {
+		return false
+	}
+	entry.Score = score
+	if expiresAt := now.Add(expiration); expiresAt.After(entry.ExpiresAt) {
+		entry.ExpiresAt = expiresAt
+	}
+	return true
+}
+
+// contains 检查key是否是未过期的热点key
+func (r *hotKeyRegistry) contains(key string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, exists := r.entries[key]
+	return exists && entry.active(time.Now())
+}
+
+// get 返回未过期的热点key
+func (r *hotKeyRegistry) get(key string) (HotKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, exists := r.entries[key]
+	if !exists || !entry.active(time.Now()) {
+		return HotKey{}, false
+	}
+	return *entry, true
+}
+
+// pin 把key标记为不会过期的热点，已是热点时保留首次识别时间
+// 返回true表示key是新识别出的热点
+func (r *hotKeyRegistry) pin(key string, score int64, expiration time.Duration) bool {
+	r.mu.Lock()
+	now := time.Now()
+	entry, exists := r.entries[key]
+	if exists && entry.active(now) {
+		entry.Pinned = true
+		r.mu.Unlock()
+		return false
+	}
+
+	var events []HotKeyEvent
+	if exists {
+		events = append(events, coolEvent(entry, now))
+	}
+	r.entries[key] = &HotKey{Key: key, DetectedAt: now, ExpiresAt: now.Add(expiration), Score: score, Pinned: true}
+	events = append(events, HotKeyEvent{Type: EventHot, Key: key, Score: score, Time: now})
+	r.mu.Unlock()
+
+	r.notify(events)
+	return true
+}
+
+// unpin 取消key的预热，热点标记最早在从现在起的expiration后过期，之后按访问次数正常延长或过期
+func (r *hotKeyRegistry) unpin(key string, expiration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.entries[key]
+	if !exists || !entry.Pinned {
+		return
+	}
+	entry.Pinned = false
+	if expiresAt := time.Now().Add(expiration); expiresAt.After(entry.ExpiresAt) {
+		entry.ExpiresAt = expiresAt
+	}
+}
+
+// remove 移除热点key
+func (r *hotKeyRegistry) remove(key string) {
+	r.mu.Lock()
+	entry, exists := r.entries[key]
+	if !exists {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.entries, key)
+	r.mu.Unlock()
+
+	r.notify([]HotKeyEvent{coolEvent(entry, time.Now())})
+}
+
+// removeAll 移除所有热点key，返回移除的数量
+func (r *hotKeyRegistry) removeAll() int {
+	r.mu.Lock()
+	now := time.Now()
+	events := make([]HotKeyEvent, 0, len(r.entries))
+	for _, entry := range r.entries {
+		events = append(events, coolEvent(entry, now))
+	}
+	clear(r.entries)
+	r.mu.Unlock()
+
+	r.notify(events)
+	return len(events)
+}
+
+// expire 清理已过期的登记
+func (r *hotKeyRegistry) expire() {
+	r.mu.Lock()
+	events := r.removeExpired(time.Now())
+	r.mu.Unlock()
+
+	r.notify(events)
+}
+
+// removeExpired 删除now之前过期的登记并返回它们的冷却事件，调用方需持有写锁
+func (r *hotKeyRegistry) removeExpired(now time.Time) []HotKeyEvent {
+	var events []HotKeyEvent
+	for key, entry := range r.entries {
+		if !entry.active(now) {
+			delete(r.entries, key)
+			events = append(events, coolEvent(entry, now))
+		}
+	}
+	return events
+}
+
+// list 返回所有未过期的热点key，按热度从高到低排序，同时清理已过期的登记
+func (r *hotKeyRegistry) list() []HotKey {
+	r.mu.Lock()
+	events := r.removeExpired(time.Now())
+	hotKeys := make([]HotKey, 0, len(r.entries))
+	for _, entry := range r.entries {
+		hotKeys = append(hotKeys, *entry)
+	}
+	r.mu.Unlock()
+	r.notify(events)
+
+	sort.Slice(hotKeys, func(i, j int) bool {
+		if hotKeys[i].Score != hotKeys[j].Score {
+			return hotKeys[i].Score > hotKeys[j].Score
+		}
+		return hotKeys[i].Key < hotKeys[j].Key
+	})
+	return hotKeys
+}
+
+// coolEvent 返回登记被移除时的冷却事件，热度为最后一次更新的值
+func coolEvent(entry *HotKey, now time.Time) HotKeyEvent {
+	return HotKeyEvent{Type: EventCool, Key: entry.Key, Score: entry.Score, Time: now}
+}
@@ -0,0 +1,204 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrInvalidRankingQuery 排行查询的数量或时间范围无效
+var ErrInvalidRankingQuery = errors.New("invalid ranking query")
+
+// RankingConfig 热点排行配置
+type RankingConfig struct {
+	// 排行数据在Redis中的key前缀，每个时间桶一个有序集合，另有一个有序集合保存首次识别时间
+	KeyPrefix string
+	// 写入热点热度的间隔
+	Interval time.Duration
+	// 时间桶的长度，查询的时间范围按桶对齐
+	Bucket time.Duration
+	// 排行保留的时长，也是可查询的最大时间范围
+	Retention time.Duration
+	// 每个时间桶保留的key数量，超出的低热度key被裁剪
+	MaxKeys int
+}
+
+// DefaultRankingConfig 默认热点排行配置
+var DefaultRankingConfig = RankingConfig{
+	KeyPrefix: "hotkey:ranking:",
+	Interval:  10 * time.Second, // 每10秒写入一次
+	Bucket:    time.Minute,      // 按分钟划分时间桶
+	Retention: time.Hour,        // 保留1小时
+	MaxKeys:   1000,
+}
+
+// RankedKey 排行中的热点key
+type RankedKey struct {
+	Key string `json:"key"`
+	// 查询时间范围内的最高热度
+	Score int64 `json:"score"`
+	// 所有实例中最早识别为热点的时间，超过保留时长且已不再是热点的key没有此时间
+	FirstDetected *time.Time `json:"first_detected,omitempty"`
+}
+
+// Ranking 把各实例识别出的热点key及其热度写入Redis有序集合，形成可按时间范围查询的排行榜
+// 每个时间桶一个有序集合，成员的分数是该桶内观察到的最高热度（ZADD GT）；
+// 首次识别时间保存在单独的有序集合中，多个实例写入时保留最早的时间（ZADD LT）
+type Ranking struct {
+	config   RankingConfig
+	client   redis.UniversalClient
+	detector *HotKeyDetector
+
+	stop     chan struct{}
+	stopOnce sync.Once // 重复调用Close时只关闭一次stop
+	done     chan struct{}
+}
+
+// NewRanking 创建热点排行并启动后台协程定期写入本实例的热点key
+func NewRanking(client redis.UniversalClient, detector *HotKeyDetector, config RankingConfig) *Ranking {
+	r := &Ranking{
+		config:   config,
+		client:   client,
+		detector: detector,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+
+	return r
+}
+
+// NewDefaultRanking 使用默认配置创建热点排行
+func NewDefaultRanking(client redis.UniversalClient, detector *HotKeyDetector) *Ranking {
+	return NewRanking(client, detector, DefaultRankingConfig)
+}
+
+// Close 停止写入
+func (r *Ranking) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+	return nil
+}
+
+// run 定期写入热点key
+func (r *Ranking) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.persist(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to persist hot key ranking: %v", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// persist 把当前的热点key写入now所在的时间桶，并裁剪时间桶和过期的首次识别时间
+func (r *Ranking) persist(ctx context.Context, now time.Time) error {
+	hotKeys := r.detector.GetHotKeys()
+	bucketKey := r.bucketKey(now, 0)
+	firstKey := r.config.KeyPrefix + "first"
+
+	pipe := r.client.Pipeline()
+	// 先清理超过保留时长的首次识别时间，仍是热点的key随后以原来的识别时间重新写入
+	pipe.ZRemRangeByScore(ctx, firstKey, "-inf", strconv.FormatInt(now.Add(-r.config.Retention).UnixMilli(), 10))
+	if len(hotKeys) > 0 {
+		scores := make([]redis.Z, len(hotKeys))
+		detected := make([]redis.Z, len(hotKeys))
+		for i, hotKey := range hotKeys {
+			scores[i] = redis.Z{Score: float64(hotKey.Score), Member: hotKey.Key}
+			detected[i] = redis.Z{Score: float64(hotKey.DetectedAt.UnixMilli()), Member: hotKey.Key}
+		}
+		pipe.ZAddGT(ctx, bucketKey, scores...)
+		pipe.ZAddLT(ctx, firstKey, detected...)
+		// 只保留热度最高的MaxKeys个key
+		pipe.ZRemRangeByRank(ctx, bucketKey, 0, int64(-r.config.MaxKeys-1))
+		pipe.PExpire(ctx, bucketKey, r.config.Retention+r.config.Bucket)
+		pipe.PExpire(ctx, firstKey, r.config.Retention+r.config.Bucket)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Top 返回最近window内热度最高的n个key，按热度从高到低排序
+// window向上对齐到时间桶，不能超过保留时长
+func (r *Ranking) Top(ctx context.Context, n int, window time.Duration) ([]RankedKey, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: n must be positive: %d", ErrInvalidRankingQuery, n)
+	}
+	if window <= 0 || window > r.config.Retention {
+		return nil, fmt.Errorf("%w: window must be positive and not longer than %v: %v", ErrInvalidRankingQuery, r.config.Retention, window)
+	}
+
+	now := time.Now()
+	buckets := int64((window + r.config.Bucket - 1) / r.config.Bucket)
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.ZSliceCmd, buckets)
+	for i := range cmds {
+		cmds[i] = pipe.ZRevRangeWithScores(ctx, r.bucketKey(now, -int64(i)), 0, int64(n-1))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	// 每个桶的前n名中取各key的最高热度，全局前n名的key一定在它热度最高的那个桶的前n名中
+	scores := make(map[string]float64)
+	for _, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			key := z.Member.(string)
+			scores[key] = max(scores[key], z.Score)
+		}
+	}
+
+	top := make([]RankedKey, 0, len(scores))
+	for key, score := range scores {
+		top = append(top, RankedKey{Key: key, Score: int64(score)})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Score != top[j].Score {
+			return top[i].Score > top[j].Score
+		}
+		return top[i].Key < top[j].Key
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	if len(top) == 0 {
+		return top, nil
+	}
+
+	pipe = r.client.Pipeline()
+	detected := make([]*redis.FloatCmd, len(top))
+	for i := range top {
+		detected[i] = pipe.ZScore(ctx, r.config.KeyPrefix+"first", top[i].Key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	for i, cmd := range detected {
+		if ms, err := cmd.Result(); err == nil {
+			detectedAt := time.UnixMilli(int64(ms))
+			top[i].FirstDetected = &detectedAt
+		}
+	}
+	return top, nil
+}
+
+// bucketKey 返回now所在时间桶向前偏移offset个桶的有序集合
+func (r *Ranking) bucketKey(now time.Time, offset int64) string {
+	bucket := now.UnixNano()/int64(r.config.Bucket) + offset
+	return r.config.KeyPrefix + strconv.FormatInt(bucket, 10)
+}
@@ -0,0 +1,210 @@
+package detector
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// count-min sketch的大小：4行、每行2048个计数器，每个sketch 32KB
+const (
+	sketchDepth = 4
+	sketchWidth = 2048
+)
+
+// KeyCount key及其估计的访问次数
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// countMinSketch 以固定的内存估计每个key的访问次数，估计值只会偏大不会偏小
+type countMinSketch struct {
+	rows [sketchDepth][sketchWidth]uint32
+}
+
+// sketchIndexes 计算key在每一行中的下标
+func sketchIndexes(key string) [sketchDepth]uint32 {
+	// FNV-1a，内联计算避免每次访问分配哈希对象
+	sum := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		sum ^= uint64(key[i])
+		sum *= 1099511628211
+	}
+	// 由一个64位哈希值派生出各行的下标（Kirsch-Mitzenmacher）
+	h1, h2 := uint32(sum), uint32(sum>>32)
+	var indexes [sketchDepth]uint32
+	for i := range indexes {
+		indexes[i] = (h1 + uint32(i)*h2) % sketchWidth
+	}
+	return indexes
+}
+
+// add 把key的计数加一
+func (s *countMinSketch) add(indexes [sketchDepth]uint32) {
+	for i, idx := range indexes {
+		if s.rows[i][idx] < ^uint32(0) {
+			s.rows[i][idx]++
+		}
+	}
+}
+
+// estimate 返回key的估计访问次数，即各行计数的最小值
+func (s *countMinSketch) estimate(indexes [sketchDepth]uint32) int64 {
+	minCount := s.rows[0][indexes[0]]
+	for i := 1; i < sketchDepth; i++ {
+		minCount = min(minCount, s.rows[i][indexes[i]])
+	}
+	return int64(minCount)
+}
+
+// keyHeap 按访问次数排序的最小堆，index记录每个key在堆中的位置
+type keyHeap struct {
+	items []KeyCount
+	index map[string]int
+}
+
+func (h *keyHeap) Len() int           { return len(h.items) }
+func (h *keyHeap) Less(i, j int) bool { return h.items[i].Count < h.items[j].Count }
+func (h *keyHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i].Key] = i
+	h.index[h.items[j].Key] = j
+}
+func (h *keyHeap) Push(x any) {
+	item := x.(KeyCount)
+	h.index[item.Key] = len(h.items)
+	h.items = append(h.items, item)
+}
+func (h *keyHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, item.Key)
+	return item
+}
+
+// topKCounter 用count-min sketch估计每个key在滑动窗口内的访问次数，最小堆只保留估计值最大的k个key，
+// 内存占用与key的数量无关
+// 当前窗口和上一个窗口各一个sketch，估计值 = 当前窗口的计数 + 上一个窗口的计数 × 上一个窗口仍在滑动窗口内的比例
+type topKCounter struct {
+	mu          sync.Mutex
+	curr        *countMinSketch
+	prev        *countMinSketch
+	window      time.Duration
+	windowStart time.Time
+	k           int
+	top         keyHeap
+}
+
+// newTopKCounter 创建Top-K计数器
+func newTopKCounter(k int, window time.Duration) *topKCounter {
+	return &topKCounter{
+		curr:        &countMinSketch{},
+		prev:        &countMinSketch{},
+		window:      window,
+		windowStart: time.Now(),
+		k:           k,
+		top:         keyHeap{index: make(map[string]int)},
+	}
+}
+
+// record 记录一次访问，返回key在滑动窗口内的估计访问次数
+func (t *topKCounter) record(key string) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.rotateLocked(now)
+	indexes := sketchIndexes(key)
+	t.curr.add(indexes)
+	count := t.estimateLocked(indexes, now)
+
+	if i, ok := t.top.index[key]; ok {
+		t.top.items[i].Count = count
+		heap.Fix(&t.top, i)
+		return count
+	}
+	if t.top.Len() < t.k {
+		heap.Push(&t.top, KeyCount{Key: key, Count: count})
+		return count
+	}
+	if t.top.Len() > 0 && count > t.top.items[0].Count {
+		delete(t.top.index, t.top.items[0].Key)
+		t.top.items[0] = KeyCount{Key: key, Count: count}
+		t.top.index[key] = 0
+		heap.Fix(&t.top, 0)
+	}
+	return count
+}
+
+// estimate 返回key在滑动窗口内的估计访问次数
+func (t *topKCounter) estimate(key string) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.rotateLocked(now)
+	return t.estimateLocked(sketchIndexes(key), now)
+}
+
+// topN 返回估计访问次数最多的n个key，按访问次数从高到低排序，n不大于0时返回全部
+func (t *topKCounter) topN(n int) []KeyCount {
+	t.mu.Lock()
+	now := time.Now()
+	t.rotateLocked(now)
+	result := make([]KeyCount, 0, len(t.top.items))
+	for _, item := range t.top.items {
+		// 堆中的计数是最后一次访问时的估计值，返回前按当前时间重新估计
+		if count := t.estimateLocked(sketchIndexes(item.Key), now); count > 0 {
+			result = append(result, KeyCount{Key: item.Key, Count: count})
+		}
+	}
+	t.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// estimateLocked 按滑动窗口加权两个sketch的估计值，调用方需持有锁
+func (t *topKCounter) estimateLocked(indexes [sketchDepth]uint32, now time.Time) int64 {
+	weight := float64(t.window-now.Sub(t.windowStart)) / float64(t.window)
+	return t.curr.estimate(indexes) + int64(float64(t.prev.estimate(indexes))*weight)
+}
+
+// rotateLocked 进入新的窗口时轮换sketch，并按新的估计值调整堆，调用方需持有锁
+func (t *topKCounter) rotateLocked(now time.Time) {
+	periods := now.Sub(t.windowStart) / t.window
+	if periods <= 0 {
+		return
+	}
+	t.windowStart = t.windowStart.Add(periods * t.window)
+	if periods == 1 {
+		t.prev, t.curr = t.curr, t.prev
+	} else {
+		// 超过一个窗口没有访问，上一个窗口的计数也已失效
+		*t.prev = countMinSketch{}
+	}
+	*t.curr = countMinSketch{}
+
+	items := t.top.items[:0]
+	for _, item := range t.top.items {
+		delete(t.top.index, item.Key)
+		if item.Count = t.estimateLocked(sketchIndexes(item.Key), now); item.Count > 0 {
+			items = append(items, item)
+		}
+	}
+	t.top.items = items
+	for i, item := range t.top.items {
+		t.top.index[item.Key] = i
+	}
+	heap.Init(&t.top)
+}
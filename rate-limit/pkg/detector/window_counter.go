@@ -0,0 +1,65 @@
+package detector
+
+// windowCounter 滑动窗口计数器：统计窗口划分为若干个子窗口，环形数组的每个元素是一个子窗口的计数，
+// 窗口随时间逐个子窗口向前滑动，过期子窗口的计数被清零，而不是整个窗口一起过期
+type windowCounter struct {
+	buckets []int64
+	// 最后一次计数的子窗口序号（时间戳除以子窗口长度）
+	lastSlot int64
+}
+
+// newWindowCounter 创建一个有n个子窗口的计数器
+func newWindowCounter(n int) *windowCounter {
+	return &windowCounter{buckets: make([]int64, n)}
+}
+
+// add 在slot所在的子窗口计数加一，返回整个窗口内的计数
+func (c *windowCounter) add(slot int64) int64 {
+	n := int64(len(c.buckets))
+	if slot-c.lastSlot >= n {
+		clear(c.buckets)
+	} else {
+		// 清零从上次计数到现在之间已滑出窗口的子窗口
+		for s := c.lastSlot + 1; s <= slot; s++ {
+			c.buckets[s%n] = 0
+		}
+	}
+	if slot > c.lastSlot {
+		c.lastSlot = slot
+	}
+	c.buckets[c.lastSlot%n]++
+	return c.count(slot)
+}
+
+// count 返回截至slot所在子窗口的整个窗口内的计数
+func (c *windowCounter) count(slot int64) int64 {
+	return c.countRange(slot, len(c.buckets))
+}
+
+// countRange 返回截至slot所在子窗口、最近k个子窗口内的计数
+func (c *windowCounter) countRange(slot int64, k int) int64 {
+	var total int64
+	c.each(slot, k, func(count int64) {
+		total += count
+	})
+	return total
+}
+
+// peak 返回截至slot所在子窗口、最近k个子窗口中计数最多的子窗口的计数
+func (c *windowCounter) peak(slot int64, k int) int64 {
+	var peak int64
+	c.each(slot, k, func(count int64) {
+		peak = max(peak, count)
+	})
+	return peak
+}
+
+// each 依次访问截至slot所在子窗口、最近k个子窗口的计数，已滑出窗口的子窗口不访问
+// slot可以早于最后一次计数的子窗口，用于只统计已经结束的子窗口
+func (c *windowCounter) each(slot int64, k int, fn func(count int64)) {
+	n := int64(len(c.buckets))
+	// 环形数组中保存的是 lastSlot-n+1 到 lastSlot 的子窗口
+	for s := min(slot, c.lastSlot); s > slot-int64(k) && s > c.lastSlot-n; s-- {
+		fn(c.buckets[s%n])
+	}
+}
@@ -0,0 +1,164 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ConcurrencyLimiter 并发限制器，限制每个key同时在处理中的请求数
+// 与限流器不同，它不关心请求到达的速率，而是在后端变慢、请求处理时间变长时限制堆积的请求数，
+// 可以与 Limiter 同时使用
+type ConcurrencyLimiter interface {
+	// Acquire 尝试为key占用一个并发名额，成功时返回释放名额的函数，请求处理完后必须调用；
+	// 已达上限时立即返回false，不排队等待
+	Acquire(ctx context.Context, key string) (release func(), ok bool)
+}
+
+// 编译期检查各并发限制器实现了 ConcurrencyLimiter 接口
+var (
+	_ ConcurrencyLimiter = (*LocalConcurrencyLimiter)(nil)
+	_ ConcurrencyLimiter = (*RedisConcurrencyLimiter)(nil)
+)
+
+// ConcurrencyConfig 并发限制器配置
+type ConcurrencyConfig struct {
+	// 每个key同时处理的最大请求数
+	MaxInFlight int64
+	// Redis中并发计数的key前缀，只用于 RedisConcurrencyLimiter
+	KeyPrefix string
+	// Redis中并发计数的过期时间，每次占用名额时刷新，只用于 RedisConcurrencyLimiter
+	// 实例崩溃时没有释放的名额最多在TTL后归还，应大于请求的最长处理时间
+	TTL time.Duration
+}
+
+// DefaultConcurrencyConfig 默认并发限制配置
+var DefaultConcurrencyConfig = ConcurrencyConfig{
+	MaxInFlight: 10, // 每个key最多10个请求同时处理
+	KeyPrefix:   "ratelimit:cc:",
+	TTL:         30 * time.Second,
+}
+
+// LocalConcurrencyLimiter 进程内的并发限制器，每个key一个计数信号量，计数归零时删除
+type LocalConcurrencyLimiter struct {
+	config   ConcurrencyConfig
+	mu       sync.Mutex
+	inFlight map[string]int64
+}
+
+// NewLocalConcurrencyLimiter 创建一个新的进程内并发限制器
+func NewLocalConcurrencyLimiter(config ConcurrencyConfig) *LocalConcurrencyLimiter {
+	return &LocalConcurrencyLimiter{
+		config:   config,
+		inFlight: make(map[string]int64),
+	}
+}
+
+// NewDefaultLocalConcurrencyLimiter 使用默认配置创建进程内并发限制器
+func NewDefaultLocalConcurrencyLimiter() *LocalConcurrencyLimiter {
+	return NewLocalConcurrencyLimiter(DefaultConcurrencyConfig)
+}
+
+// Acquire 尝试为key占用一个并发名额
+func (cl *LocalConcurrencyLimiter) Acquire(ctx context.Context, key string) (func(), bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.inFlight[key] >= cl.config.MaxInFlight {
+		log.Printf("Concurrency limited: %s (%d in flight)", key, cl.inFlight[key])
+		return nil, false
+	}
+	cl.inFlight[key]++
+
+	var once sync.Once
+	return func() { once.Do(func() { cl.release(key) }) }, true
+}
+
+// InFlight 返回key当前正在处理的请求数
+func (cl *LocalConcurrencyLimiter) InFlight(key string) int64 {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return cl.inFlight[key]
+}
+
+// release 归还key的一个并发名额
+func (cl *LocalConcurrencyLimiter) release(key string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.inFlight[key] <= 1 {
+		delete(cl.inFlight, key)
+		return
+	}
+	cl.inFlight[key]--
+}
+
+// acquireScript 并发计数加一并刷新过期时间，超过上限时撤销，返回是否占用成功
+var acquireScript = scripts.Register("concurrency-acquire", `
+local n = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if n > tonumber(ARGV[1]) then
+	redis.call('DECR', KEYS[1])
+	return 0
+end
+return 1
+`)
+
+// releaseScript 并发计数减一，归零时删除；计数已过期时不会减为负数
+var releaseScript = scripts.Register("concurrency-release", `
+local n = tonumber(redis.call('GET', KEYS[1]) or '0')
+if n <= 1 then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+return redis.call('DECR', KEYS[1])
+`)
+
+// RedisConcurrencyLimiter 基于Redis的并发限制器，所有实例共享每个key的并发计数
+// 占用名额时INCR，释放时DECR，计数带有过期时间，实例崩溃时没有释放的名额不会永久占用
+type RedisConcurrencyLimiter struct {
+	config ConcurrencyConfig
+	client redis.UniversalClient
+}
+
+// NewRedisConcurrencyLimiter 创建一个新的分布式并发限制器
+func NewRedisConcurrencyLimiter(client redis.UniversalClient, config ConcurrencyConfig) *RedisConcurrencyLimiter {
+	return &RedisConcurrencyLimiter{
+		config: config,
+		client: client,
+	}
+}
+
+// NewDefaultRedisConcurrencyLimiter 使用默认配置创建分布式并发限制器
+func NewDefaultRedisConcurrencyLimiter(client redis.UniversalClient) *RedisConcurrencyLimiter {
+	return NewRedisConcurrencyLimiter(client, DefaultConcurrencyConfig)
+}
+
+// Acquire 尝试为key占用一个并发名额
+// Redis不可用时放行请求，见 allowOnError
+func (cl *RedisConcurrencyLimiter) Acquire(ctx context.Context, key string) (func(), bool) {
+	redisKey := cl.config.KeyPrefix + key
+	acquired, err := acquireScript.Run(ctx, cl.client, []string{redisKey},
+		cl.config.MaxInFlight, cl.config.TTL.Milliseconds()).Int()
+	if err != nil {
+		return func() {}, allowOnError("concurrency", key, err)
+	}
+	if acquired == 0 {
+		log.Printf("Concurrency limited: %s", key)
+		return nil, false
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// 请求的ctx可能已经结束，释放名额不能因此失败
+			if err := releaseScript.Run(context.Background(), cl.client, []string{redisKey}).Err(); err != nil {
+				log.Printf("Error releasing concurrency slot for %s: %v", key, err)
+			}
+		})
+	}, true
+}
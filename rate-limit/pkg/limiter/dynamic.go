@@ -0,0 +1,285 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LimitRule 单个key的限流规则，以JSON保存在Redis的hash中
+type LimitRule struct {
+	// 每秒允许的请求数
+	RatePerSecond float64 `json:"rate"`
+	// 桶容量或队列长度，见 Limiter.SetLimit
+	Burst int `json:"burst"`
+	// 限流算法，为空时使用 DynamicLimiterConfig.DefaultAlgorithm
+	Algorithm string `json:"algorithm,omitempty"`
+}
+
+// DynamicLimiterConfig 动态限流配置
+type DynamicLimiterConfig struct {
+	// 保存限流规则的Redis hash，field为key，value为JSON格式的 LimitRule
+	Key string
+	// 从Redis拉取规则的间隔
+	PollInterval time.Duration
+	// 规则没有指定算法时使用的算法
+	DefaultAlgorithm string
+}
+
+// DefaultDynamicLimiterConfig 默认动态限流配置
+var DefaultDynamicLimiterConfig = DynamicLimiterConfig{
+	Key:              "ratelimit:limits",
+	PollInterval:     5 * time.Second, // 规则修改后最多5秒在所有实例上生效
+	DefaultAlgorithm: AlgorithmTokenBucket,
+}
+
+// DynamicLimiter 从Redis读取按key配置的限流规则，运维人员修改规则后所有实例定期拉取并生效，不需要重新部署
+// 有规则的key交给对应算法的限流器按规则限流，其他key交给fallback；规则删除后key重新交给fallback
+type DynamicLimiter struct {
+	config   DynamicLimiterConfig
+	client   redis.UniversalClient
+	fallback Limiter
+
+	mu       sync.RWMutex
+	rules    map[string]LimitRule
+	limiters map[string]Limiter // 算法 → 执行规则的限流器
+
+	stop     chan struct{}
+	stopOnce sync.Once // 重复调用Close时只关闭一次stop
+	done     chan struct{}
+}
+
+// NewDynamicLimiter 创建动态限流器，立即拉取一次规则并启动后台协程定期拉取
+func NewDynamicLimiter(client redis.UniversalClient, fallback Limiter, config DynamicLimiterConfig) *DynamicLimiter {
+	d := &DynamicLimiter{
+		config:   config,
+		client:   client,
+		fallback: fallback,
+		rules:    make(map[string]LimitRule),
+		limiters: make(map[string]Limiter),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if err := d.Reload(context.Background()); err != nil {
+		log.Printf("Failed to load rate limit rules: %v", err)
+	}
+	go d.watch()
+
+	return d
+}
+
+// Allow 检查指定key的访问是否被允许
+func (d *DynamicLimiter) Allow(key string) bool {
+	return d.limiterFor(key).Allow(key)
+}
+
+// AllowN 检查指定key的n次访问是否被允许
+func (d *DynamicLimiter) AllowN(key string, n int) bool {
+	return d.limiterFor(key).AllowN(key, n)
+}
+
+// Wait 等待直到指定key的访问被允许
+func (d *DynamicLimiter) Wait(ctx context.Context, key string) error {
+	return d.limiterFor(key).Wait(ctx, key)
+}
+
+// WaitN 等待直到指定key的n次访问被允许
+func (d *DynamicLimiter) WaitN(ctx context.Context, key string, n int) error {
+	return d.limiterFor(key).WaitN(ctx, key, n)
+}
+
+// SetLimit 只在本实例为特定key设置自定义限流速率，需要所有实例生效时使用 SetRule
+func (d *DynamicLimiter) SetLimit(key string, ratePerSecond float64, burst int) {
+	d.limiterFor(key).SetLimit(key, ratePerSecond, burst)
+}
+
+// ClearLimit 只在本实例清除特定key的自定义限流速率，Redis中的规则不受影响，需要删除规则时使用 DeleteRule
+func (d *DynamicLimiter) ClearLimit(key string) {
+	d.limiterFor(key).ClearLimit(key)
+}
+
+// Info 返回默认限流器的配置，Custom中包含本实例的自定义速率和Redis中的规则
+func (d *DynamicLimiter) Info() LimiterInfo {
+	info := d.fallback.Info()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for key, rule := range d.rules {
+		limit := KeyLimit{RatePerSecond: rule.RatePerSecond, Burst: rule.Burst}
+		if algorithm := d.algorithm(rule.Algorithm); algorithm != info.Algorithm {
+			limit.Algorithm = algorithm
+		}
+		info.Custom[key] = limit
+	}
+	for _, limiter := range d.limiters {
+		info.Size += limiter.Info().Size
+	}
+	return info
+}
+
+// SetRule 把key的限流规则写入Redis并立即在本实例生效，其他实例在下次拉取时生效
+func (d *DynamicLimiter) SetRule(ctx context.Context, key string, rule LimitRule) error {
+	if _, err := d.ruleLimiter(rule.Algorithm); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rate limit rule: %w", err)
+	}
+	if err := d.client.HSet(ctx, d.config.Key, key, data).Err(); err != nil {
+		return fmt.Errorf("failed to save rate limit rule: %w", err)
+	}
+	return d.Reload(ctx)
+}
+
+// DeleteRule 从Redis删除key的限流规则，key恢复使用默认的限流器
+func (d *DynamicLimiter) DeleteRule(ctx context.Context, key string) error {
+	if err := d.client.HDel(ctx, d.config.Key, key).Err(); err != nil {
+		return fmt.Errorf("failed to delete rate limit rule: %w", err)
+	}
+	return d.Reload(ctx)
+}
+
+// Rules 返回当前生效的限流规则
+func (d *DynamicLimiter) Rules() map[string]LimitRule {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rules := make(map[string]LimitRule, len(d.rules))
+	for key, rule := range d.rules {
+		rules[key] = rule
+	}
+	return rules
+}
+
+// Reload 从Redis拉取所有规则，应用新增和修改的规则，移除已删除的规则
+func (d *DynamicLimiter) Reload(ctx context.Context) error {
+	values, err := d.client.HGetAll(ctx, d.config.Key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to load rate limit rules: %w", err)
+	}
+
+	rules := make(map[string]LimitRule, len(values))
+	for key, value := range values {
+		var rule LimitRule
+		if err := json.Unmarshal([]byte(value), &rule); err != nil {
+			log.Printf("Invalid rate limit rule for %s: %v", key, err)
+			continue
+		}
+		rules[key] = rule
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, rule := range rules {
+		if old, exists := d.rules[key]; exists && old == rule {
+			continue
+		}
+		limiter, err := d.ruleLimiterLocked(rule.Algorithm)
+		if err != nil {
+			log.Printf("Invalid rate limit rule for %s: %v", key, err)
+			delete(rules, key)
+			continue
+		}
+		limiter.SetLimit(key, rule.RatePerSecond, rule.Burst)
+	}
+	for key, old := range d.rules {
+		rule, exists := rules[key]
+		if exists && d.algorithm(rule.Algorithm) == d.algorithm(old.Algorithm) {
+			continue
+		}
+		// 规则删除或改用其他算法后，清除原限流器中该key的速率
+		d.limiters[d.algorithm(old.Algorithm)].ClearLimit(key)
+		if !exists {
+			log.Printf("Rate limit rule removed: %s", key)
+		}
+	}
+	d.rules = rules
+	return nil
+}
+
+// Close 停止拉取规则，并关闭默认限流器和执行规则的限流器
+func (d *DynamicLimiter) Close() error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	<-d.done
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	errs := []error{closeLimiter(d.fallback)}
+	for _, limiter := range d.limiters {
+		errs = append(errs, closeLimiter(limiter))
+	}
+	return errors.Join(errs...)
+}
+
+// watch 定期从Redis拉取规则
+func (d *DynamicLimiter) watch() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Reload(context.Background()); err != nil {
+				log.Printf("Failed to reload rate limit rules: %v", err)
+			}
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// limiterFor 返回处理指定key的限流器
+func (d *DynamicLimiter) limiterFor(key string) Limiter {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rule, exists := d.rules[key]
+	if !exists {
+		return d.fallback
+	}
+	return d.limiters[d.algorithm(rule.Algorithm)]
+}
+
+// ruleLimiter 返回执行指定算法规则的限流器，不存在时创建
+func (d *DynamicLimiter) ruleLimiter(algorithm string) (Limiter, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.ruleLimiterLocked(algorithm)
+}
+
+// ruleLimiterLocked 同 ruleLimiter，调用方需持有锁
+// 有规则的key使用单独的限流器实例，规则删除后fallback中不会残留自定义的速率
+func (d *DynamicLimiter) ruleLimiterLocked(algorithm string) (Limiter, error) {
+	algorithm = d.algorithm(algorithm)
+	if limiter, exists := d.limiters[algorithm]; exists {
+		return limiter, nil
+	}
+	limiter, err := NewLimiter(algorithm, d.client)
+	if err != nil {
+		return nil, err
+	}
+	d.limiters[algorithm] = limiter
+	return limiter, nil
+}
+
+// algorithm 返回规则实际使用的算法
+func (d *DynamicLimiter) algorithm(algorithm string) string {
+	if algorithm == "" {
+		return d.config.DefaultAlgorithm
+	}
+	return algorithm
+}
@@ -0,0 +1,184 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FixedWindowConfig 固定窗口限流器配置
+type FixedWindowConfig struct {
+	// 每个窗口内允许的请求数
+	Limit int64
+	// 窗口长度，精度为毫秒
+	Window time.Duration
+	// Redis中限流计数的key前缀
+	KeyPrefix string
+}
+
+// DefaultFixedWindowConfig 默认固定窗口限流配置
+var DefaultFixedWindowConfig = FixedWindowConfig{
+	Limit:     10,          // 每个窗口10个请求
+	Window:    time.Second, // 窗口长度1秒
+	KeyPrefix: "ratelimit:fw:",
+}
+
+// FixedWindowStats 固定窗口限流器的统计信息
+type FixedWindowStats struct {
+	// 放行的请求数
+	Allowed int64
+	// 拒绝的请求数
+	Rejected int64
+	// 边界突发：固定窗口放行、但按滑动窗口估计已超过限额的请求数
+	// 上一个窗口末尾和当前窗口开头的请求各自没有超过限额，合在一起却可能接近限额的两倍
+	BoundaryBursts int64
+}
+
+// fixedWindowScript 当前窗口的计数加n，未超过限额时才计数，计数的key在第一次计数时设置过期时间
+// KEYS[1]为当前窗口的计数，KEYS[2]为上一个窗口的计数，只用于统计边界突发
+// 返回 {是否允许, 当前窗口的计数, 上一个窗口的计数}
+var fixedWindowScript = scripts.Register("fixed-window", `
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local curr = tonumber(redis.call('GET', KEYS[1]) or 0)
+local prev = tonumber(redis.call('GET', KEYS[2]) or 0)
+if curr + n > limit then
+	return {0, curr, prev}
+end
+curr = redis.call('INCRBY', KEYS[1], n)
+if curr == n then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return {1, curr, prev}
+`)
+
+// FixedWindowLimiter 基于Redis的固定窗口计数限流器
+// 每个窗口一个计数（INCR + EXPIRE），实现简单、内存占用小，但窗口边界前后的突发请求可能达到限额的两倍，
+// 放行的此类请求计入 FixedWindowStats.BoundaryBursts，便于与滑动窗口对比
+// 窗口由本机时间划分，多个实例之间需要保持时钟同步
+type FixedWindowLimiter struct {
+	config FixedWindowConfig
+	client redis.UniversalClient
+	custom keyLimits
+
+	allowed        atomic.Int64
+	rejected       atomic.Int64
+	boundaryBursts atomic.Int64
+}
+
+// NewFixedWindowLimiter 创建一个新的固定窗口限流器
+func NewFixedWindowLimiter(client redis.UniversalClient, config FixedWindowConfig) *FixedWindowLimiter {
+	return &FixedWindowLimiter{
+		config: config,
+		client: client,
+	}
+}
+
+// NewDefaultFixedWindowLimiter 使用默认配置创建固定窗口限流器
+func NewDefaultFixedWindowLimiter(client redis.UniversalClient) *FixedWindowLimiter {
+	return NewFixedWindowLimiter(client, DefaultFixedWindowConfig)
+}
+
+// Allow 检查指定key的访问是否被允许
+func (fw *FixedWindowLimiter) Allow(key string) bool {
+	return fw.AllowN(key, 1)
+}
+
+// AllowN 检查指定key的n次访问是否被允许，允许时一次计入n次
+// Redis不可用时放行请求，见 allowOnError
+func (fw *FixedWindowLimiter) AllowN(key string, n int) bool {
+	allowed, count, err := fw.run(key, n)
+	if err != nil {
+		return allowOnError("fixed window", key, err)
+	}
+	if !allowed {
+		fw.rejected.Add(1)
+		log.Printf("Rate limited: %s (%d requests in window)", key, count)
+	}
+	return allowed
+}
+
+// Wait 等待直到指定key的访问被允许，按平均请求间隔轮询Redis，等待期间的轮询不计入拒绝次数
+func (fw *FixedWindowLimiter) Wait(ctx context.Context, key string) error {
+	return fw.WaitN(ctx, key, 1)
+}
+
+// WaitN 轮询直到n次访问被允许，n超过窗口限额时立即返回 ErrExceedsLimit
+func (fw *FixedWindowLimiter) WaitN(ctx context.Context, key string, n int) error {
+	limit := fw.custom.get(key, fw.config.Limit)
+	if int64(n) > limit {
+		return ErrExceedsLimit
+	}
+	return pollUntilAllowed(ctx, fw.config.Window/time.Duration(max(limit, 1)), func() bool {
+		allowed, _, err := fw.run(key, n)
+		if err != nil {
+			return allowOnError("fixed window", key, err)
+		}
+		return allowed
+	})
+}
+
+// SetLimit 为特定key设置自定义限流速率，每个窗口允许的请求数为 ratePerSecond × Window，忽略burst
+func (fw *FixedWindowLimiter) SetLimit(key string, ratePerSecond float64, burst int) {
+	limit := windowLimit(ratePerSecond, fw.config.Window)
+	fw.custom.set(key, limit)
+	log.Printf("Set custom limit for %s: %d requests per %v", key, limit, fw.config.Window)
+}
+
+// ClearLimit 清除特定key的自定义限额
+func (fw *FixedWindowLimiter) ClearLimit(key string) {
+	fw.custom.remove(key)
+	log.Printf("Cleared custom limit for %s", key)
+}
+
+// Info 返回限流器的默认配置和按key设置的自定义限额
+func (fw *FixedWindowLimiter) Info() LimiterInfo {
+	return fw.custom.windowInfo(AlgorithmFixedWindow, fw.config.Limit, fw.config.Window)
+}
+
+// run 执行限流脚本，返回是否允许以及当前窗口的计数，放行时更新放行和边界突发的统计
+func (fw *FixedWindowLimiter) run(key string, n int) (bool, int64, error) {
+	window := fw.config.Window.Milliseconds()
+	if window <= 0 {
+		window = 1
+	}
+	now := time.Now().UnixMilli()
+	start := now - now%window
+	// 用hash tag把同一个key的各窗口计数放到同一个slot，集群模式下脚本才能同时访问
+	base := fw.config.KeyPrefix + "{" + key + "}:"
+	keys := []string{
+		base + strconv.FormatInt(start, 10),
+		base + strconv.FormatInt(start-window, 10),
+	}
+	limit := fw.custom.get(key, fw.config.Limit)
+
+	// 计数的key保留两个窗口，下一个窗口仍能读取到它用于统计
+	result, err := fixedWindowScript.Run(context.Background(), fw.client, keys, limit, n, window*2).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if result[0] != 1 {
+		return false, result[1], nil
+	}
+	fw.allowed.Add(1)
+
+	// 按滑动窗口估计最近一个窗口长度内的请求数，超过限额说明本次放行发生在窗口边界的突发中
+	elapsed := float64(now-start) / float64(window)
+	if float64(result[2])*(1-elapsed)+float64(result[1]) > float64(limit) {
+		fw.boundaryBursts.Add(1)
+	}
+	return true, result[1], nil
+}
+
+// Stats 返回限流器的统计信息
+func (fw *FixedWindowLimiter) Stats() FixedWindowStats {
+	return FixedWindowStats{
+		Allowed:        fw.allowed.Load(),
+		Rejected:       fw.rejected.Load(),
+		BoundaryBursts: fw.boundaryBursts.Load(),
+	}
+}
@@ -0,0 +1,163 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rate-limit/pkg/detector"
+)
+
+// HotKeyContextKey 中间件在gin.Context中保存"是否为热点key"的键，处理函数可以通过 c.GetBool 读取
+const HotKeyContextKey = "rate_limit_hot_key"
+
+// LimitReasonContextKey 请求被拒绝时中间件在gin.Context中保存拒绝原因的键，OnLimited可以通过 c.GetString 读取
+const LimitReasonContextKey = "rate_limit_reason"
+
+// 请求被拒绝的原因
+const (
+	// LimitReasonRate 超出限流速率
+	LimitReasonRate = "rate"
+	// LimitReasonConcurrency 同时处理的请求数已达上限
+	LimitReasonConcurrency = "concurrency"
+)
+
+// KeyFunc 从请求中提取限流的key，返回空字符串时不限流
+type KeyFunc func(c *gin.Context) string
+
+// CostFunc 返回请求消耗的额度，如一次读取50个key的批量请求消耗50，小于1时按1计算
+type CostFunc func(c *gin.Context) int
+
+// KeyFromParam 以路径参数作为限流的key，如 /get/:key
+func KeyFromParam(name string) KeyFunc {
+	return func(c *gin.Context) string {
+		return c.Param(name)
+	}
+}
+
+// KeyFromHeader 以请求头作为限流的key，如 X-API-Key
+func KeyFromHeader(name string) KeyFunc {
+	return func(c *gin.Context) string {
+		return c.GetHeader(name)
+	}
+}
+
+// KeyFromClientIP 以客户端IP作为限流的key，IP的解析方式由gin的可信代理配置决定
+func KeyFromClientIP() KeyFunc {
+	return func(c *gin.Context) string {
+		return c.ClientIP()
+	}
+}
+
+// MiddlewareOptions 限流中间件配置
+type MiddlewareOptions struct {
+	// 限流器，与Concurrency至少设置一个
+	Limiter Limiter
+	// 并发限制器，非nil时通过限流的请求还需占用一个并发名额，处理完成后释放
+	Concurrency ConcurrencyLimiter
+	// 提取限流的key，默认为客户端IP
+	KeyFunc KeyFunc
+	// 请求消耗的额度，通过 Limiter.AllowN 和 Limiter.WaitN 一次计入，默认每个请求为1
+	Cost CostFunc
+	// 热点key检测器，非nil时记录每次访问，只对热点key限流
+	HotKeys *detector.HotKeyDetector
+	// 返回true时跳过限流和并发限制，例如热点key已在本地缓存中、不会访问后端
+	Bypass func(c *gin.Context, key string) bool
+	// 被限流时的处理函数，默认返回429；拒绝原因见 LimitReasonContextKey
+	OnLimited gin.HandlerFunc
+	// 大于0时启用排队：超出速率的请求调用 Limiter.Wait 最多等待MaxWait，等不到才被限流，
+	// 短时间的突发请求被延迟处理而不是直接拒绝
+	MaxWait time.Duration
+	// 启用排队时，每个经过限流器的请求在等待结束后调用，waited为等待的时间
+	OnWait func(c *gin.Context, key string, waited time.Duration, allowed bool)
+	// 返回true时以影子模式处理请求：照常检查限流和并发限制，但本应被拒绝的请求只记录、不拒绝，
+	// 用于在真实流量下调整阈值后再启用限流；影子模式下不排队等待
+	Shadow func(c *gin.Context, key string) bool
+	// 影子模式下本应被拒绝的请求调用，之后请求继续处理；拒绝原因见 LimitReasonContextKey
+	OnShadowLimited func(c *gin.Context)
+}
+
+// GinMiddleware 创建限流中间件，被限流的请求调用OnLimited后终止，不再执行后续的处理函数
+func GinMiddleware(opts MiddlewareOptions) gin.HandlerFunc {
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = KeyFromClientIP()
+	}
+	if opts.OnLimited == nil {
+		opts.OnLimited = func(c *gin.Context) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+		}
+	}
+
+	return func(c *gin.Context) {
+		key := opts.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		// 记录访问并检测是否为热点key，未配置检测器时所有key都限流
+		isHotKey := true
+		if opts.HotKeys != nil {
+			isHotKey = opts.HotKeys.RecordAccess(key)
+			c.Set(HotKeyContextKey, isHotKey)
+		}
+
+		if isHotKey && (opts.Bypass == nil || !opts.Bypass(c, key)) {
+			shadow := opts.Shadow != nil && opts.Shadow(c, key)
+			if opts.Limiter != nil && !allow(c, key, shadow, opts) && reject(c, key, LimitReasonRate, shadow, opts) {
+				return
+			}
+			if opts.Concurrency != nil {
+				release, ok := opts.Concurrency.Acquire(c.Request.Context(), key)
+				if ok {
+					defer release()
+				} else if reject(c, key, LimitReasonConcurrency, shadow, opts) {
+					return
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
+// reject 记录拒绝原因，调用OnLimited后终止请求，返回true
+// 影子模式下只记录并调用OnShadowLimited，返回false，请求继续处理
+func reject(c *gin.Context, key, reason string, shadow bool, opts MiddlewareOptions) bool {
+	c.Set(LimitReasonContextKey, reason)
+	if shadow {
+		log.Printf("Shadow limited request for key: %s (%s)", key, reason)
+		if opts.OnShadowLimited != nil {
+			opts.OnShadowLimited(c)
+		}
+		return false
+	}
+
+	log.Printf("Limited request for key: %s (%s)", key, reason)
+	opts.OnLimited(c)
+	c.Abort()
+	return true
+}
+
+// allow 检查请求是否被允许，启用排队时等待至多MaxWait，客户端断开时同样放弃等待
+func allow(c *gin.Context, key string, shadow bool, opts MiddlewareOptions) bool {
+	cost := 1
+	if opts.Cost != nil {
+		cost = max(opts.Cost(c), 1)
+	}
+	if opts.MaxWait <= 0 || shadow {
+		return opts.Limiter.AllowN(key, cost)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), opts.MaxWait)
+	defer cancel()
+
+	start := time.Now()
+	allowed := opts.Limiter.WaitN(ctx, key, cost) == nil
+	if opts.OnWait != nil {
+		opts.OnWait(c, key, time.Since(start), allowed)
+	}
+	return allowed
+}
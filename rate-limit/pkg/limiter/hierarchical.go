@@ -0,0 +1,149 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+)
+
+// globalKey 全局层在令牌桶中使用的key
+const globalKey = "*"
+
+// PrefixLimit 按key前缀的限流配置，同一前缀下的所有key共享一个令牌桶
+type PrefixLimit struct {
+	// key前缀，如 "product:"；末尾的"*"会被忽略，"product:*" 与 "product:" 相同
+	Prefix string `yaml:"prefix" json:"prefix"`
+	// 该前缀下所有key每秒共允许的请求数
+	RatePerSecond float64 `yaml:"rate" json:"rate"`
+	// 该前缀下所有key共允许的突发请求数
+	Burst int `yaml:"burst" json:"burst"`
+}
+
+// HierarchicalConfig 分层限流配置
+type HierarchicalConfig struct {
+	// 全局层：本实例所有key共享的额度，RatePerSecond为0时不启用
+	Global RateLimiterConfig
+	// 前缀层：key匹配多个前缀时使用最长的前缀
+	Prefixes []PrefixLimit
+}
+
+// HierarchicalLimiter 分层限流器：每次访问依次经过按key、按前缀和全局三层限流，任何一层拒绝即拒绝，结果取最严格的一层
+// 同一个命名空间下的热点key再多，也只能用完该前缀的额度，不会挤占其他命名空间需要的全局额度
+// 从最具体的一层开始检查，被内层拒绝的请求不消耗外层共享的额度，单个被刷的key不会拖垮整个前缀或实例；
+// 被外层拒绝时内层已计入的额度不归还
+type HierarchicalLimiter struct {
+	keys     Limiter
+	prefixes *RateLimiter // 以前缀为key，每个前缀设置了自定义速率，未配置前缀时为nil
+	global   *RateLimiter // 未启用全局层时为nil
+	// 按长度从长到短排列的前缀
+	prefixList []string
+}
+
+// NewHierarchicalLimiter 创建分层限流器，keys为按key限流的一层，SetLimit等按key的配置都交给它
+func NewHierarchicalLimiter(keys Limiter, config HierarchicalConfig) *HierarchicalLimiter {
+	h := &HierarchicalLimiter{keys: keys}
+
+	if config.Global.RatePerSecond > 0 {
+		h.global = NewRateLimiter(config.Global)
+	}
+	if len(config.Prefixes) > 0 {
+		h.prefixes = NewDefaultRateLimiter()
+		for _, limit := range config.Prefixes {
+			prefix := strings.TrimSuffix(limit.Prefix, "*")
+			h.prefixes.SetLimit(prefix, limit.RatePerSecond, limit.Burst)
+			h.prefixList = append(h.prefixList, prefix)
+		}
+		sort.SliceStable(h.prefixList, func(i, j int) bool {
+			return len(h.prefixList[i]) > len(h.prefixList[j])
+		})
+	}
+	return h
+}
+
+// Allow 检查指定key的访问是否被各层允许
+func (h *HierarchicalLimiter) Allow(key string) bool {
+	return h.AllowN(key, 1)
+}
+
+// AllowN 检查指定key的n次访问是否被各层允许，依次检查按key、按前缀和全局的额度
+func (h *HierarchicalLimiter) AllowN(key string, n int) bool {
+	if !h.keys.AllowN(key, n) {
+		return false
+	}
+	if prefix, ok := h.prefix(key); ok && !h.prefixes.AllowN(prefix, n) {
+		log.Printf("Rate limited by prefix %s: %s", prefix, key)
+		return false
+	}
+	if h.global != nil && !h.global.AllowN(globalKey, n) {
+		log.Printf("Rate limited by global limit: %s", key)
+		return false
+	}
+	return true
+}
+
+// Wait 等待直到指定key的访问被各层允许
+func (h *HierarchicalLimiter) Wait(ctx context.Context, key string) error {
+	return h.WaitN(ctx, key, 1)
+}
+
+// WaitN 依次等待各层允许指定key的n次访问，所有层共用ctx的截止时间
+func (h *HierarchicalLimiter) WaitN(ctx context.Context, key string, n int) error {
+	if err := h.keys.WaitN(ctx, key, n); err != nil {
+		return err
+	}
+	if prefix, ok := h.prefix(key); ok {
+		if err := h.prefixes.WaitN(ctx, prefix, n); err != nil {
+			return err
+		}
+	}
+	if h.global != nil {
+		return h.global.WaitN(ctx, globalKey, n)
+	}
+	return nil
+}
+
+// SetLimit 为特定key设置自定义限流速率，只影响按key的一层
+func (h *HierarchicalLimiter) SetLimit(key string, ratePerSecond float64, burst int) {
+	h.keys.SetLimit(key, ratePerSecond, burst)
+}
+
+// ClearLimit 清除特定key的自定义限流速率，只影响按key的一层
+func (h *HierarchicalLimiter) ClearLimit(key string) {
+	h.keys.ClearLimit(key)
+}
+
+// Info 返回按key一层的配置，Size包含各层的限流状态
+func (h *HierarchicalLimiter) Info() LimiterInfo {
+	info := h.keys.Info()
+	if h.prefixes != nil {
+		info.Size += h.prefixes.Info().Size
+	}
+	if h.global != nil {
+		info.Size += h.global.Info().Size
+	}
+	return info
+}
+
+// Close 关闭各层的限流器
+func (h *HierarchicalLimiter) Close() error {
+	errs := []error{closeLimiter(h.keys)}
+	if h.prefixes != nil {
+		errs = append(errs, h.prefixes.Close())
+	}
+	if h.global != nil {
+		errs = append(errs, h.global.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// prefix 返回key匹配的最长前缀
+func (h *HierarchicalLimiter) prefix(key string) (string, bool) {
+	for _, prefix := range h.prefixList {
+		if strings.HasPrefix(key, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,274 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull 漏桶的等待队列已满
+var ErrQueueFull = errors.New("leaky bucket queue is full")
+
+// LeakyBucketConfig 漏桶限流器配置
+type LeakyBucketConfig struct {
+	// 每秒漏出（放行）的请求数
+	RatePerSecond float64
+	// 桶容量（最多排队等待的请求数）
+	Capacity int
+}
+
+// DefaultLeakyBucketConfig 默认漏桶限流配置
+var DefaultLeakyBucketConfig = LeakyBucketConfig{
+	RatePerSecond: 10.0, // 每秒放行10个请求
+	Capacity:      20,   // 最多20个请求排队
+}
+
+// leakyBucket 单个key的漏桶，drainAt为队列中最后一个请求被放行的时间
+type leakyBucket struct {
+	drainAt  time.Time
+	interval time.Duration
+	capacity int
+}
+
+// LeakyBucketLimiter 基于漏桶算法的限流器
+// 与令牌桶允许突发不同，漏桶以恒定速率放行请求：突发请求进入队列排队，队列满时拒绝
+type LeakyBucketLimiter struct {
+	config      LeakyBucketConfig
+	buckets     map[string]*leakyBucket
+	custom      map[string]LeakyBucketConfig // 通过SetLimit设置了自定义速率的key
+	bucketMutex sync.Mutex
+	cleanupTime time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once // 重复调用Close时只关闭一次stop
+	done     chan struct{}
+}
+
+// NewLeakyBucketLimiter 创建一个新的漏桶限流器
+// RatePerSecond不大于0时使用默认速率，Capacity小于1时按1处理
+func NewLeakyBucketLimiter(config LeakyBucketConfig) *LeakyBucketLimiter {
+	config = config.normalize()
+	lb := &LeakyBucketLimiter{
+		config:      config,
+		buckets:     make(map[string]*leakyBucket),
+		custom:      make(map[string]LeakyBucketConfig),
+		cleanupTime: time.Minute, // 默认1分钟清理一次已排空的漏桶
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	// 启动一个协程定期清理已排空的漏桶
+	go lb.cleanup()
+
+	return lb
+}
+
+// NewDefaultLeakyBucketLimiter 使用默认配置创建漏桶限流器
+func NewDefaultLeakyBucketLimiter() *LeakyBucketLimiter {
+	return NewLeakyBucketLimiter(DefaultLeakyBucketConfig)
+}
+
+// Allow 检查指定key的访问是否可以立即放行
+// 请求不等待，但与Wait一样占用队列中的位置：排在前面的请求达到Capacity时拒绝，
+// 因此最多连续放行Capacity个请求，之后按 RatePerSecond 恢复
+func (lb *LeakyBucketLimiter) Allow(key string) bool {
+	return lb.AllowN(key, 1)
+}
+
+// AllowN 检查指定key的n次访问是否可以立即放行，放行时在队列中占用n个间隔
+func (lb *LeakyBucketLimiter) AllowN(key string, n int) bool {
+	lb.bucketMutex.Lock()
+	defer lb.bucketMutex.Unlock()
+
+	if _, ok := lb.getBucket(key).enqueue(time.Now(), n); !ok {
+		log.Printf("Rate limited: %s", key)
+		return false
+	}
+	return true
+}
+
+// Wait 把请求加入指定key的队列，等到轮到该请求时返回
+// 队列已满时立即返回 ErrQueueFull；ctx的截止时间早于轮到该请求的时间时立即放弃排队，
+// 返回 context.DeadlineExceeded；ctx结束时放弃排队并返回ctx的错误
+func (lb *LeakyBucketLimiter) Wait(ctx context.Context, key string) error {
+	return lb.WaitN(ctx, key, 1)
+}
+
+// WaitN 把n次访问作为一个请求加入指定key的队列，等到轮到该请求时返回，之后的n个间隔内不再放行其他请求
+func (lb *LeakyBucketLimiter) WaitN(ctx context.Context, key string, n int) error {
+	slot, err := lb.reserve(key, n)
+	if err != nil {
+		log.Printf("Rate limited: %s", key)
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && slot.After(deadline) {
+		lb.cancel(key, slot, n)
+		return context.DeadlineExceeded
+	}
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		lb.cancel(key, slot, n)
+		return ctx.Err()
+	}
+}
+
+// reserve 为请求在队列中预留n个间隔，返回该请求被放行的时间
+func (lb *LeakyBucketLimiter) reserve(key string, n int) (time.Time, error) {
+	lb.bucketMutex.Lock()
+	defer lb.bucketMutex.Unlock()
+
+	start, ok := lb.getBucket(key).enqueue(time.Now(), n)
+	if !ok {
+		return time.Time{}, ErrQueueFull
+	}
+	return start, nil
+}
+
+// enqueue 把请求加入队尾并占用n个间隔，返回该请求被放行的时间；排在前面的请求达到容量时返回false
+func (b *leakyBucket) enqueue(now time.Time, n int) (time.Time, bool) {
+	start := now
+	if b.drainAt.After(now) {
+		start = b.drainAt
+	}
+	// 排在前面的请求数 = 需要等待的时间 / 放行间隔，向上取整，避免已经过去的不足一个间隔的时间多放进一个请求
+	if int((start.Sub(now)+b.interval-1)/b.interval) >= b.capacity {
+		return time.Time{}, false
+	}
+	b.drainAt = start.Add(time.Duration(n) * b.interval)
+	return start, true
+}
+
+// cancel 放弃排队，请求仍在队尾时归还它占用的n个间隔，否则这些间隔空转
+func (lb *LeakyBucketLimiter) cancel(key string, slot time.Time, n int) {
+	lb.bucketMutex.Lock()
+	defer lb.bucketMutex.Unlock()
+
+	if bucket, exists := lb.buckets[key]; exists && bucket.drainAt.Equal(slot.Add(time.Duration(n)*bucket.interval)) {
+		bucket.drainAt = slot
+	}
+}
+
+// SetLimit 为特定key设置自定义的放行速率和队列长度，已在排队的请求不受影响
+// 与 NewLeakyBucketLimiter 相同，速率不大于0时使用默认速率，队列长度小于1时按1处理
+func (lb *LeakyBucketLimiter) SetLimit(key string, ratePerSecond float64, burst int) {
+	lb.bucketMutex.Lock()
+	defer lb.bucketMutex.Unlock()
+
+	config := LeakyBucketConfig{RatePerSecond: ratePerSecond, Capacity: burst}.normalize()
+	lb.custom[key] = config
+	if bucket, exists := lb.buckets[key]; exists {
+		bucket.interval, bucket.capacity = drainInterval(config), config.Capacity
+	}
+	log.Printf("Set custom rate for %s: %.2f req/s, capacity: %d", key, config.RatePerSecond, config.Capacity)
+}
+
+// ClearLimit 清除特定key的自定义速率，恢复默认的放行速率和队列长度
+func (lb *LeakyBucketLimiter) ClearLimit(key string) {
+	lb.bucketMutex.Lock()
+	defer lb.bucketMutex.Unlock()
+
+	delete(lb.custom, key)
+	if bucket, exists := lb.buckets[key]; exists {
+		bucket.interval, bucket.capacity = drainInterval(lb.config), lb.config.Capacity
+	}
+	log.Printf("Cleared custom rate for %s", key)
+}
+
+// Info 返回限流器的默认配置和按key设置的自定义速率
+func (lb *LeakyBucketLimiter) Info() LimiterInfo {
+	lb.bucketMutex.Lock()
+	defer lb.bucketMutex.Unlock()
+
+	info := LimiterInfo{
+		Algorithm: AlgorithmLeakyBucket,
+		Default:   KeyLimit{RatePerSecond: lb.config.RatePerSecond, Burst: lb.config.Capacity},
+		Custom:    make(map[string]KeyLimit, len(lb.custom)),
+		Size:      len(lb.buckets),
+	}
+	for key, config := range lb.custom {
+		info.Custom[key] = KeyLimit{RatePerSecond: config.RatePerSecond, Burst: config.Capacity}
+	}
+	return info
+}
+
+// getBucket 获取指定key的漏桶，如果不存在则创建，调用方需持有锁
+func (lb *LeakyBucketLimiter) getBucket(key string) *leakyBucket {
+	bucket, exists := lb.buckets[key]
+	if !exists {
+		config, custom := lb.custom[key]
+		if !custom {
+			config = lb.config
+		}
+		bucket = &leakyBucket{interval: drainInterval(config), capacity: config.Capacity}
+		lb.buckets[key] = bucket
+	}
+	return bucket
+}
+
+// normalize 速率不大于0时使用默认速率；容量小于1时按1处理，否则队列为空时也会拒绝请求
+func (config LeakyBucketConfig) normalize() LeakyBucketConfig {
+	if config.RatePerSecond <= 0 {
+		config.RatePerSecond = DefaultLeakyBucketConfig.RatePerSecond
+	}
+	if config.Capacity < 1 {
+		config.Capacity = 1
+	}
+	return config
+}
+
+// drainInterval 返回两次放行之间的间隔
+func drainInterval(config LeakyBucketConfig) time.Duration {
+	return time.Duration(float64(time.Second) / config.RatePerSecond)
+}
+
+// cleanup 定期清理已排空的漏桶
+func (lb *LeakyBucketLimiter) cleanup() {
+	defer close(lb.done)
+
+	ticker := time.NewTicker(lb.cleanupTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			lb.evictDrained(time.Now())
+		case <-lb.stop:
+			return
+		}
+	}
+}
+
+// evictDrained 清理在now之前已排空的漏桶
+func (lb *LeakyBucketLimiter) evictDrained(now time.Time) {
+	lb.bucketMutex.Lock()
+	count := 0
+	for key, bucket := range lb.buckets {
+		if !bucket.drainAt.After(now) {
+			delete(lb.buckets, key)
+			count++
+		}
+	}
+	lb.bucketMutex.Unlock()
+
+	if count > 0 {
+		log.Printf("Cleaned up %d leaky buckets", count)
+	}
+}
+
+// Close 停止清理协程，之后限流器仍然可用，但已排空的漏桶不再被清理
+func (lb *LeakyBucketLimiter) Close() error {
+	lb.stopOnce.Do(func() { close(lb.stop) })
+	<-lb.done
+	return nil
+}
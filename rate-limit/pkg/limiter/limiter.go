@@ -0,0 +1,251 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrUnknownAlgorithm 不支持的限流算法
+var ErrUnknownAlgorithm = errors.New("unknown limiter algorithm")
+
+// ErrExceedsLimit 一次请求的访问次数超过窗口限额，永远不会被允许
+var ErrExceedsLimit = errors.New("requested cost exceeds the window limit")
+
+// Limiter 限流器接口，各种限流算法都实现该接口
+type Limiter interface {
+	// Allow 检查指定key的访问是否被允许
+	Allow(key string) bool
+	// AllowN 检查指定key的n次访问是否被允许，允许时一次计入n次
+	AllowN(key string, n int) bool
+	// Wait 等待直到指定key的访问被允许，ctx结束或无法等到时返回错误
+	Wait(ctx context.Context, key string) error
+	// WaitN 等待直到指定key的n次访问被允许，允许时一次计入n次；n超过桶容量或窗口限额时永远等不到，返回错误
+	WaitN(ctx context.Context, key string, n int) error
+	// SetLimit 为特定key设置自定义限流速率
+	// ratePerSecond为每秒允许的请求数；burst为令牌桶的容量或漏桶的队列长度，窗口类限流器忽略burst，
+	// 每个窗口允许的请求数为 ratePerSecond × 窗口长度
+	SetLimit(key string, ratePerSecond float64, burst int)
+	// ClearLimit 清除特定key的自定义限流速率，恢复使用默认配置
+	ClearLimit(key string)
+	// Info 返回限流器的默认配置和按key设置的自定义速率
+	Info() LimiterInfo
+}
+
+// allowOnError 基于Redis的限流器访问Redis出错时调用：记录错误并放行请求
+// 各个基于Redis的限流器在Redis不可用时都放行请求（fail-open），限流失效不应导致服务不可用
+func allowOnError(algorithm, key string, err error) bool {
+	log.Printf("Error running %s limiter for %s: %v", algorithm, key, err)
+	return true
+}
+
+// KeyLimit 限流速率；令牌桶和漏桶使用RatePerSecond和Burst，窗口类限流器使用Limit和Window
+type KeyLimit struct {
+	RatePerSecond float64       `json:"rate,omitempty"`
+	Burst         int           `json:"burst,omitempty"`
+	Limit         int64         `json:"limit,omitempty"`
+	Window        time.Duration `json:"window,omitempty"`
+	// 使用的算法，只在与限流器默认算法不同时设置
+	Algorithm string `json:"algorithm,omitempty"`
+}
+
+// LimiterInfo 限流器的配置快照
+type LimiterInfo struct {
+	Algorithm string              `json:"algorithm"`
+	Default   KeyLimit            `json:"default"`
+	Custom    map[string]KeyLimit `json:"custom"`
+	// 内存中保存的限流状态（令牌桶、漏桶）数量，基于Redis的限流器为0
+	Size int `json:"size"`
+}
+
+// 限流算法
+const (
+	// AlgorithmTokenBucket 令牌桶，进程内限流
+	AlgorithmTokenBucket = "token_bucket"
+	// AlgorithmLeakyBucket 漏桶，进程内限流
+	AlgorithmLeakyBucket = "leaky_bucket"
+	// AlgorithmFixedWindow 基于Redis的固定窗口计数
+	AlgorithmFixedWindow = "fixed_window"
+	// AlgorithmSlidingWindow 基于Redis的滑动窗口计数
+	AlgorithmSlidingWindow = "sliding_window"
+	// AlgorithmSlidingLog 基于Redis的滑动日志
+	AlgorithmSlidingLog = "sliding_log"
+	// AlgorithmRedisTokenBucket 基于Redis的令牌桶，所有实例共享同一个桶
+	AlgorithmRedisTokenBucket = "redis_token_bucket"
+)
+
+// Algorithms 支持的全部限流算法
+var Algorithms = []string{
+	AlgorithmTokenBucket,
+	AlgorithmLeakyBucket,
+	AlgorithmFixedWindow,
+	AlgorithmSlidingWindow,
+	AlgorithmSlidingLog,
+	AlgorithmRedisTokenBucket,
+}
+
+// 编译期检查各限流器实现了 Limiter 接口
+var (
+	_ Limiter = (*RateLimiter)(nil)
+	_ Limiter = (*LeakyBucketLimiter)(nil)
+	_ Limiter = (*FixedWindowLimiter)(nil)
+	_ Limiter = (*SlidingWindowLimiter)(nil)
+	_ Limiter = (*SlidingLogLimiter)(nil)
+	_ Limiter = (*RedisTokenBucketLimiter)(nil)
+)
+
+// NewLimiter 使用默认配置创建指定算法的限流器，基于Redis的算法使用client保存计数
+func NewLimiter(algorithm string, client redis.UniversalClient) (Limiter, error) {
+	switch algorithm {
+	case AlgorithmTokenBucket:
+		return NewDefaultRateLimiter(), nil
+	case AlgorithmLeakyBucket:
+		return NewDefaultLeakyBucketLimiter(), nil
+	}
+
+	if client == nil {
+		return nil, fmt.Errorf("limiter algorithm %s requires a redis client", algorithm)
+	}
+	switch algorithm {
+	case AlgorithmFixedWindow:
+		return NewDefaultFixedWindowLimiter(client), nil
+	case AlgorithmSlidingWindow:
+		return NewDefaultSlidingWindowLimiter(client), nil
+	case AlgorithmSlidingLog:
+		return NewDefaultSlidingLogLimiter(client), nil
+	case AlgorithmRedisTokenBucket:
+		return NewDefaultRedisTokenBucketLimiter(client), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+// NewLimiterWithRate 创建指定算法的限流器，ratePerSecond和burst是所有key的默认速率，含义与 Limiter.SetLimit 相同
+// ratePerSecond不大于0时使用该算法的默认配置
+func NewLimiterWithRate(algorithm string, client redis.UniversalClient, ratePerSecond float64, burst int) (Limiter, error) {
+	if ratePerSecond <= 0 {
+		return NewLimiter(algorithm, client)
+	}
+
+	switch algorithm {
+	case AlgorithmTokenBucket:
+		return NewRateLimiter(RateLimiterConfig{RatePerSecond: ratePerSecond, BurstSize: burst}), nil
+	case AlgorithmLeakyBucket:
+		return NewLeakyBucketLimiter(LeakyBucketConfig{RatePerSecond: ratePerSecond, Capacity: burst}), nil
+	}
+
+	if client == nil {
+		return nil, fmt.Errorf("limiter algorithm %s requires a redis client", algorithm)
+	}
+	switch algorithm {
+	case AlgorithmFixedWindow:
+		config := DefaultFixedWindowConfig
+		config.Limit = windowLimit(ratePerSecond, config.Window)
+		return NewFixedWindowLimiter(client, config), nil
+	case AlgorithmSlidingWindow:
+		config := DefaultSlidingWindowConfig
+		config.Limit = windowLimit(ratePerSecond, config.Window)
+		return NewSlidingWindowLimiter(client, config), nil
+	case AlgorithmSlidingLog:
+		config := DefaultSlidingLogConfig
+		config.Limit = windowLimit(ratePerSecond, config.Window)
+		return NewSlidingLogLimiter(client, config), nil
+	case AlgorithmRedisTokenBucket:
+		config := DefaultRedisTokenBucketConfig
+		config.RatePerSecond, config.BurstSize = ratePerSecond, burst
+		return NewRedisTokenBucketLimiter(client, config), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+// keyLimits 基于Redis的限流器中按key设置的自定义限额，零值可直接使用
+type keyLimits struct {
+	mu     sync.RWMutex
+	limits map[string]int64
+}
+
+// get 返回key的自定义限额，没有设置时返回def
+func (kl *keyLimits) get(key string, def int64) int64 {
+	kl.mu.RLock()
+	defer kl.mu.RUnlock()
+
+	if limit, exists := kl.limits[key]; exists {
+		return limit
+	}
+	return def
+}
+
+// set 设置key的自定义限额
+func (kl *keyLimits) set(key string, limit int64) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	if kl.limits == nil {
+		kl.limits = make(map[string]int64)
+	}
+	kl.limits[key] = limit
+}
+
+// remove 删除key的自定义限额
+func (kl *keyLimits) remove(key string) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	delete(kl.limits, key)
+}
+
+// windowInfo 返回窗口类限流器的配置快照
+func (kl *keyLimits) windowInfo(algorithm string, limit int64, window time.Duration) LimiterInfo {
+	kl.mu.RLock()
+	defer kl.mu.RUnlock()
+
+	info := LimiterInfo{
+		Algorithm: algorithm,
+		Default:   KeyLimit{Limit: limit, Window: window},
+		Custom:    make(map[string]KeyLimit, len(kl.limits)),
+	}
+	for key, custom := range kl.limits {
+		info.Custom[key] = KeyLimit{Limit: custom, Window: window}
+	}
+	return info
+}
+
+// windowLimit 把每秒的速率换算为每个窗口允许的请求数，至少为1
+func windowLimit(ratePerSecond float64, window time.Duration) int64 {
+	return max(int64(math.Round(ratePerSecond*window.Seconds())), 1)
+}
+
+// closeLimiter 关闭带有后台协程的限流器，其他限流器不需要关闭
+func closeLimiter(limiter Limiter) error {
+	if closer, ok := limiter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// pollUntilAllowed 每隔interval调用一次allow，直到返回true或ctx结束
+// 基于Redis的限流器无法预知何时会有空余的额度，只能轮询
+func pollUntilAllowed(ctx context.Context, interval time.Duration, allow func() bool) error {
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for !allow() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
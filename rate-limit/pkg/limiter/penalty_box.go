@@ -0,0 +1,260 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PenaltyBoxConfig 惩罚区配置
+type PenaltyBoxConfig struct {
+	// 惩罚区在Redis中的key前缀，每个key有一个被限流次数的计数和一个带过期时间的惩罚标记，另有一个有序集合索引被关进惩罚区的key
+	KeyPrefix string
+	// 统计窗口内被限流超过此次数的key被关进惩罚区
+	Threshold int64
+	// 统计被限流次数的窗口
+	Window time.Duration
+	// 关进惩罚区的时长，期间key的请求直接被拒绝
+	Duration time.Duration
+	// 从Redis同步惩罚区的间隔，其他实例关进惩罚区或释放的key最多延迟这么久生效
+	SyncInterval time.Duration
+}
+
+// DefaultPenaltyBoxConfig 默认惩罚区配置
+var DefaultPenaltyBoxConfig = PenaltyBoxConfig{
+	KeyPrefix:    "ratelimit:penalty:",
+	Threshold:    100,              // 被限流超过100次
+	Window:       10 * time.Second, // 10秒内
+	Duration:     time.Minute,      // 关1分钟
+	SyncInterval: time.Second,
+}
+
+// BoxedKey 惩罚区中的key
+type BoxedKey struct {
+	Key string `json:"key"`
+	// 释放的时间
+	ReleaseAt time.Time `json:"release_at"`
+}
+
+// penalizeScript 记录一次限流，被限流次数超过阈值时关进惩罚区
+// KEYS[1]: 计数，KEYS[2]: 惩罚标记，KEYS[3]: 索引
+// ARGV[1]: 窗口（毫秒），ARGV[2]: 阈值，ARGV[3]: 惩罚时长（毫秒），ARGV[4]: key
+// 返回释放时间的Unix毫秒数，没有关进惩罚区时返回0
+var penalizeScript = scripts.Register("penalize", `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if count <= tonumber(ARGV[2]) then
+	return 0
+end
+
+redis.call('DEL', KEYS[1])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local release = now + tonumber(ARGV[3])
+redis.call('SET', KEYS[2], release, 'PX', ARGV[3])
+redis.call('ZADD', KEYS[3], release, ARGV[4])
+redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', now)
+return release
+`)
+
+// PenaltyBox 惩罚区：统计窗口内被限流次数过多的key被关进惩罚区，冷却期间的请求直接拒绝，不再经过限流器
+// 惩罚标记是Redis中带过期时间的key，到期自动释放；各实例定期同步惩罚区到本地，检查key时不访问Redis
+type PenaltyBox struct {
+	config PenaltyBoxConfig
+	client redis.UniversalClient
+
+	mu    sync.RWMutex
+	boxed map[string]time.Time // key → 释放时间
+
+	stop     chan struct{}
+	stopOnce sync.Once // 重复调用Close时只关闭一次stop
+	done     chan struct{}
+}
+
+// NewPenaltyBox 创建惩罚区，立即同步一次并启动后台协程定期同步
+func NewPenaltyBox(client redis.UniversalClient, config PenaltyBoxConfig) *PenaltyBox {
+	p := &PenaltyBox{
+		config: config,
+		client: client,
+		boxed:  make(map[string]time.Time),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := p.Sync(context.Background()); err != nil {
+		log.Printf("Failed to load penalty box: %v", err)
+	}
+	go p.watch()
+
+	return p
+}
+
+// NewDefaultPenaltyBox 使用默认配置创建惩罚区
+func NewDefaultPenaltyBox(client redis.UniversalClient) *PenaltyBox {
+	return NewPenaltyBox(client, DefaultPenaltyBoxConfig)
+}
+
+// Boxed 判断key是否在惩罚区中，返回距离释放的剩余时间，只读取本地同步的状态
+func (p *PenaltyBox) Boxed(key string) (time.Duration, bool) {
+	p.mu.RLock()
+	releaseAt, exists := p.boxed[key]
+	p.mu.RUnlock()
+
+	if !exists {
+		return 0, false
+	}
+	remaining := time.Until(releaseAt)
+	return remaining, remaining > 0
+}
+
+// Penalize 记录key被限流了一次，被限流次数超过阈值时把key关进惩罚区并返回true
+// Redis不可用时只记录日志，不关进惩罚区
+func (p *PenaltyBox) Penalize(ctx context.Context, key string) bool {
+	release, err := penalizeScript.Run(ctx, p.client,
+		[]string{p.countKey(key), p.boxKey(key), p.indexKey()},
+		p.config.Window.Milliseconds(), p.config.Threshold, p.config.Duration.Milliseconds(), key).Int64()
+	if err != nil {
+		log.Printf("Error recording penalty for %s: %v", key, err)
+		return false
+	}
+	if release == 0 {
+		return false
+	}
+
+	p.mu.Lock()
+	p.boxed[key] = time.UnixMilli(release)
+	p.mu.Unlock()
+
+	log.Printf("Key placed in penalty box: %s for %v", key, p.config.Duration)
+	return true
+}
+
+// Release 把key从惩罚区释放，并清空它的被限流次数；其他实例在下一次同步后生效
+func (p *PenaltyBox) Release(ctx context.Context, key string) error {
+	pipe := p.client.TxPipeline()
+	pipe.Del(ctx, p.boxKey(key), p.countKey(key))
+	pipe.ZRem(ctx, p.indexKey(), key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	delete(p.boxed, key)
+	p.mu.Unlock()
+
+	log.Printf("Key released from penalty box: %s", key)
+	return nil
+}
+
+// List 从Redis读取惩罚区中的key，按释放时间排序；释放时间取自惩罚标记的剩余过期时间
+func (p *PenaltyBox) List(ctx context.Context) ([]BoxedKey, error) {
+	keys, err := p.client.ZRange(ctx, p.indexKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return []BoxedKey{}, nil
+	}
+
+	pipe := p.client.Pipeline()
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		ttls[i] = pipe.PTTL(ctx, p.boxKey(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	boxed := make([]BoxedKey, 0, len(keys))
+	for i, key := range keys {
+		// 惩罚标记已过期或被删除时PTTL返回负数
+		if ttl := ttls[i].Val(); ttl > 0 {
+			boxed = append(boxed, BoxedKey{Key: key, ReleaseAt: now.Add(ttl)})
+		}
+	}
+	sort.Slice(boxed, func(i, j int) bool {
+		return boxed[i].ReleaseAt.Before(boxed[j].ReleaseAt)
+	})
+	return boxed, nil
+}
+
+// Sync 从Redis同步惩罚区到本地
+func (p *PenaltyBox) Sync(ctx context.Context) error {
+	boxed, err := p.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	local := make(map[string]time.Time, len(boxed))
+	for _, b := range boxed {
+		local[b.Key] = b.ReleaseAt
+	}
+
+	p.mu.Lock()
+	p.boxed = local
+	p.mu.Unlock()
+	return nil
+}
+
+// Size 返回本地同步的惩罚区中尚未释放的key数量
+func (p *PenaltyBox) Size() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, releaseAt := range p.boxed {
+		if releaseAt.After(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// Close 停止同步
+func (p *PenaltyBox) Close() error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+	return nil
+}
+
+// watch 定期从Redis同步惩罚区，同步失败时继续使用本地的状态
+func (p *PenaltyBox) watch() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Sync(context.Background()); err != nil {
+				log.Printf("Failed to sync penalty box: %v", err)
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// countKey 返回key被限流次数的计数
+func (p *PenaltyBox) countKey(key string) string {
+	return p.config.KeyPrefix + "count:" + key
+}
+
+// boxKey 返回key的惩罚标记
+func (p *PenaltyBox) boxKey(key string) string {
+	return p.config.KeyPrefix + "box:" + key
+}
+
+// indexKey 返回索引惩罚区中各key的有序集合，分数为释放时间的Unix毫秒数
+func (p *PenaltyBox) indexKey() string {
+	return p.config.KeyPrefix + "boxed"
+}
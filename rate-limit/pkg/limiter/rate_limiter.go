@@ -1,8 +1,10 @@
 package limiter
 
 import (
+	"context"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -14,32 +16,52 @@ type RateLimiterConfig struct {
 	RatePerSecond float64
 	// 桶容量（允许的突发请求数）
 	BurstSize int
+	// 限流器空闲超过此时间后被清理，为0时使用默认值
+	// 空闲足够久的令牌桶已经装满，清理后重新创建的限流器与原来的状态相同
+	IdleTimeout time.Duration
 }
 
 // DefaultRateLimiterConfig 默认限流配置
 var DefaultRateLimiterConfig = RateLimiterConfig{
-	RatePerSecond: 10.0, // 每秒10个请求
-	BurstSize:     20,   // 允许20个突发请求
+	RatePerSecond: 10.0,             // 每秒10个请求
+	BurstSize:     20,               // 允许20个突发请求
+	IdleTimeout:   10 * time.Minute, // 10分钟没有访问的限流器被清理
+}
+
+// limiterEntry 单个key的令牌桶及其最后访问时间
+type limiterEntry struct {
+	limiter *rate.Limiter
+	// 最后访问时间（UnixNano），在读锁下原子更新
+	lastAccess atomic.Int64
 }
 
 // RateLimiter 基于令牌桶算法的限流器
 type RateLimiter struct {
 	config       RateLimiterConfig
-	limiters     map[string]*rate.Limiter
+	limiters     map[string]*limiterEntry
+	custom       map[string]RateLimiterConfig // 通过SetRateForKey设置了自定义速率的key，清理限流器后仍然生效
 	limiterMutex sync.RWMutex
-	cleanupTime  time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once // 重复调用Close时只关闭一次stop
+	done     chan struct{}
 }
 
 // NewRateLimiter 创建一个新的限流器
 func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DefaultRateLimiterConfig.IdleTimeout
+	}
 	rl := &RateLimiter{
 		config:       config,
-		limiters:     make(map[string]*rate.Limiter),
+		limiters:     make(map[string]*limiterEntry),
+		custom:       make(map[string]RateLimiterConfig),
 		limiterMutex: sync.RWMutex{},
-		cleanupTime:  time.Hour, // 默认1小时清理一次不再使用的限流器
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
 	}
 
-	// 启动一个协程定期清理不再使用的限流器
+	// 启动一个协程定期清理空闲的限流器
 	go rl.cleanup()
 
 	return rl
@@ -52,22 +74,43 @@ func NewDefaultRateLimiter() *RateLimiter {
 
 // Allow 检查指定key的访问是否被允许
 func (rl *RateLimiter) Allow(key string) bool {
+	return rl.AllowN(key, 1)
+}
+
+// AllowN 检查指定key的n次访问是否被允许，允许时一次消耗n个令牌
+func (rl *RateLimiter) AllowN(key string, n int) bool {
 	limiter := rl.getLimiter(key)
-	allowed := limiter.Allow()
+	allowed := limiter.AllowN(time.Now(), n)
 	if !allowed {
 		log.Printf("Rate limited: %s", key)
 	}
 	return allowed
 }
 
+// Wait 等待直到令牌桶中有可用的令牌
+// ctx在等到令牌之前结束时立即返回错误，不会白白等待
+func (rl *RateLimiter) Wait(ctx context.Context, key string) error {
+	return rl.WaitN(ctx, key, 1)
+}
+
+// WaitN 等待直到令牌桶中有n个可用的令牌，n超过桶容量时立即返回错误
+func (rl *RateLimiter) WaitN(ctx context.Context, key string, n int) error {
+	return rl.getLimiter(key).WaitN(ctx, n)
+}
+
 // getLimiter 获取指定key的限流器，如果不存在则创建
 func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
+	now := time.Now().UnixNano()
+
 	rl.limiterMutex.RLock()
-	limiter, exists := rl.limiters[key]
+	entry, exists := rl.limiters[key]
+	if exists {
+		entry.lastAccess.Store(now)
+	}
 	rl.limiterMutex.RUnlock()
 
 	if exists {
-		return limiter
+		return entry.limiter
 	}
 
 	// 如果不存在，创建一个新的限流器
@@ -75,35 +118,70 @@ func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
 	defer rl.limiterMutex.Unlock()
 
 	// 再次检查，可能在获取写锁的过程中已经被其他协程创建
-	if limiter, exists = rl.limiters[key]; exists {
-		return limiter
+	if entry, exists = rl.limiters[key]; exists {
+		entry.lastAccess.Store(now)
+		return entry.limiter
 	}
 
-	// 创建一个新的限流器
-	limiter = rate.NewLimiter(rate.Limit(rl.config.RatePerSecond), rl.config.BurstSize)
-	rl.limiters[key] = limiter
+	// 创建一个新的限流器，设置过自定义速率的key使用自定义速率
+	config, custom := rl.custom[key]
+	if !custom {
+		config = rl.config
+	}
+	entry = rl.newEntry(config)
+	entry.lastAccess.Store(now)
+	rl.limiters[key] = entry
 	log.Printf("Created new rate limiter for: %s", key)
 
-	return limiter
+	return entry.limiter
 }
 
-// cleanup 定期清理不再使用的限流器
-// 这是一个简化版，实际上我们可能需要记录最后使用时间来决定是否清理
+// newEntry 按配置创建令牌桶
+func (rl *RateLimiter) newEntry(config RateLimiterConfig) *limiterEntry {
+	return &limiterEntry{limiter: rate.NewLimiter(rate.Limit(config.RatePerSecond), config.BurstSize)}
+}
+
+// cleanup 定期清理空闲超过IdleTimeout的限流器，仍在访问的key保留令牌桶的状态
 func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(rl.cleanupTime)
+	defer close(rl.done)
+
+	ticker := time.NewTicker(rl.config.IdleTimeout)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		rl.limiterMutex.Lock()
-		// 简单实现，实际生产环境可能需要更复杂的清理逻辑
-		count := len(rl.limiters)
-		// 这里简单粗暴地定期重置所有限流器
-		// 实际应用中可能需要更精细的策略
-		rl.limiters = make(map[string]*rate.Limiter)
-		rl.limiterMutex.Unlock()
+	for {
+		select {
+		case <-ticker.C:
+			if count := rl.evictIdle(time.Now()); count > 0 {
+				log.Printf("Cleaned up %d idle rate limiters", count)
+			}
+		case <-rl.stop:
+			return
+		}
+	}
+}
+
+// Close 停止清理协程，之后限流器仍然可用，但空闲的令牌桶不再被清理
+func (rl *RateLimiter) Close() error {
+	rl.stopOnce.Do(func() { close(rl.stop) })
+	<-rl.done
+	return nil
+}
+
+// evictIdle 清理在now之前空闲超过IdleTimeout的限流器，返回清理的数量
+func (rl *RateLimiter) evictIdle(now time.Time) int {
+	deadline := now.Add(-rl.config.IdleTimeout).UnixNano()
+
+	rl.limiterMutex.Lock()
+	defer rl.limiterMutex.Unlock()
 
-		log.Printf("Cleaned up %d rate limiters", count)
+	count := 0
+	for key, entry := range rl.limiters {
+		if entry.lastAccess.Load() < deadline {
+			delete(rl.limiters, key)
+			count++
+		}
 	}
+	return count
 }
 
 // SetRateForKey 为特定key设置自定义限流速率
@@ -112,6 +190,42 @@ func (rl *RateLimiter) SetRateForKey(key string, ratePerSecond float64, burstSiz
 	defer rl.limiterMutex.Unlock()
 
 	// 创建或更新限流器
-	rl.limiters[key] = rate.NewLimiter(rate.Limit(ratePerSecond), burstSize)
+	config := RateLimiterConfig{RatePerSecond: ratePerSecond, BurstSize: burstSize}
+	rl.custom[key] = config
+	entry := rl.newEntry(config)
+	entry.lastAccess.Store(time.Now().UnixNano())
+	rl.limiters[key] = entry
 	log.Printf("Set custom rate for %s: %.2f req/s, burst: %d", key, ratePerSecond, burstSize)
 }
+
+// SetLimit 为特定key设置自定义限流速率，同 SetRateForKey
+func (rl *RateLimiter) SetLimit(key string, ratePerSecond float64, burst int) {
+	rl.SetRateForKey(key, ratePerSecond, burst)
+}
+
+// ClearLimit 清除特定key的自定义限流速率，该key下次访问时按默认配置创建限流器
+func (rl *RateLimiter) ClearLimit(key string) {
+	rl.limiterMutex.Lock()
+	defer rl.limiterMutex.Unlock()
+
+	delete(rl.custom, key)
+	delete(rl.limiters, key)
+	log.Printf("Cleared custom rate for %s", key)
+}
+
+// Info 返回限流器的默认配置和按key设置的自定义速率
+func (rl *RateLimiter) Info() LimiterInfo {
+	rl.limiterMutex.RLock()
+	defer rl.limiterMutex.RUnlock()
+
+	info := LimiterInfo{
+		Algorithm: AlgorithmTokenBucket,
+		Default:   KeyLimit{RatePerSecond: rl.config.RatePerSecond, Burst: rl.config.BurstSize},
+		Custom:    make(map[string]KeyLimit, len(rl.custom)),
+		Size:      len(rl.limiters),
+	}
+	for key, config := range rl.custom {
+		info.Custom[key] = KeyLimit{RatePerSecond: config.RatePerSecond, Burst: config.BurstSize}
+	}
+	return info
+}
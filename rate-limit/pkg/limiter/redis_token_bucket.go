@@ -0,0 +1,210 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrExceedsBurst 一次请求的令牌数超过桶容量，永远不会被允许
+var ErrExceedsBurst = errors.New("requested tokens exceed the bucket capacity")
+
+// RedisTokenBucketConfig 分布式令牌桶限流器配置
+type RedisTokenBucketConfig struct {
+	// 每秒补充的令牌数
+	RatePerSecond float64
+	// 桶容量（允许的突发请求数）
+	BurstSize int
+	// Redis中令牌桶的key前缀
+	KeyPrefix string
+}
+
+// DefaultRedisTokenBucketConfig 默认分布式令牌桶限流配置
+var DefaultRedisTokenBucketConfig = RedisTokenBucketConfig{
+	RatePerSecond: 10.0, // 每秒10个请求
+	BurstSize:     20,   // 允许20个突发请求
+	KeyPrefix:     "ratelimit:tb:",
+}
+
+// redisTokenBucketScript 令牌桶：每个key用一个hash保存剩余令牌数和上次补充的时间（毫秒），
+// 每次请求先按经过的时间补充令牌，再尝试取走n个
+// 时间取自Redis服务器，多个实例之间的时钟偏差不影响限流；桶装满所需的时间之后没有访问的key自动过期
+// 返回 {是否允许, 剩余令牌数（取整）, 不允许时距离令牌足够还需等待的毫秒数，n超过桶容量时为-1}
+var redisTokenBucketScript = scripts.Register("redis-token-bucket", `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+else
+	now = ts
+end
+
+local allowed = 0
+local wait = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+elseif n > burst then
+	wait = -1
+else
+	wait = math.ceil((n - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), wait}
+`)
+
+// RedisTokenBucketLimiter 基于Redis的令牌桶限流器
+// 令牌桶的状态保存在Redis中并由Lua脚本原子地更新，所有API服务实例共享同一个桶：
+// 与进程内的 RateLimiter 相同，允许突发到桶容量、长期平均不超过补充速率，但扩容实例不会让总限额成倍增加
+type RedisTokenBucketLimiter struct {
+	config RedisTokenBucketConfig
+	client redis.UniversalClient
+
+	mu     sync.RWMutex
+	custom map[string]KeyLimit // 按key设置的自定义速率和桶容量
+}
+
+// NewRedisTokenBucketLimiter 创建一个新的分布式令牌桶限流器
+func NewRedisTokenBucketLimiter(client redis.UniversalClient, config RedisTokenBucketConfig) *RedisTokenBucketLimiter {
+	return &RedisTokenBucketLimiter{
+		config: config,
+		client: client,
+		custom: make(map[string]KeyLimit),
+	}
+}
+
+// NewDefaultRedisTokenBucketLimiter 使用默认配置创建分布式令牌桶限流器
+func NewDefaultRedisTokenBucketLimiter(client redis.UniversalClient) *RedisTokenBucketLimiter {
+	return NewRedisTokenBucketLimiter(client, DefaultRedisTokenBucketConfig)
+}
+
+// Allow 检查指定key的访问是否被允许
+func (tb *RedisTokenBucketLimiter) Allow(key string) bool {
+	return tb.AllowN(key, 1)
+}
+
+// AllowN 检查指定key的n次访问是否被允许，允许时一次消耗n个令牌
+// Redis不可用时放行请求，见 allowOnError
+func (tb *RedisTokenBucketLimiter) AllowN(key string, n int) bool {
+	allowed, tokens, _, err := tb.run(context.Background(), key, n)
+	if err != nil {
+		return allowOnError("redis token bucket", key, err)
+	}
+	if !allowed {
+		log.Printf("Rate limited: %s (%d tokens left)", key, tokens)
+	}
+	return allowed
+}
+
+// Wait 等待直到令牌桶中有可用的令牌
+// 脚本返回令牌足够所需的时间，按该时间等待后重试，不需要轮询；
+// ctx的截止时间早于令牌足够的时间时立即返回 context.DeadlineExceeded，不会白白等待
+func (tb *RedisTokenBucketLimiter) Wait(ctx context.Context, key string) error {
+	return tb.WaitN(ctx, key, 1)
+}
+
+// WaitN 等待直到令牌桶中有n个可用的令牌，n超过桶容量时立即返回 ErrExceedsBurst
+func (tb *RedisTokenBucketLimiter) WaitN(ctx context.Context, key string, n int) error {
+	for {
+		allowed, _, wait, err := tb.run(ctx, key, n)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			log.Printf("Error running redis token bucket limiter for %s: %v", key, err)
+			return nil
+		}
+		if allowed {
+			return nil
+		}
+		if wait < 0 {
+			return ErrExceedsBurst
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return context.DeadlineExceeded
+		}
+
+		// 等待期间令牌可能被其他实例取走，醒来后重新检查
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// SetLimit 为特定key设置自定义的补充速率和桶容量，桶中已有的令牌数保留，超过新容量的部分在下次请求时截断
+func (tb *RedisTokenBucketLimiter) SetLimit(key string, ratePerSecond float64, burst int) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.custom[key] = KeyLimit{RatePerSecond: ratePerSecond, Burst: burst}
+	log.Printf("Set custom rate for %s: %.2f req/s, burst: %d", key, ratePerSecond, burst)
+}
+
+// ClearLimit 清除特定key的自定义速率，恢复使用默认配置
+func (tb *RedisTokenBucketLimiter) ClearLimit(key string) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	delete(tb.custom, key)
+	log.Printf("Cleared custom rate for %s", key)
+}
+
+// Info 返回限流器的默认配置和按key设置的自定义速率
+func (tb *RedisTokenBucketLimiter) Info() LimiterInfo {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+
+	info := LimiterInfo{
+		Algorithm: AlgorithmRedisTokenBucket,
+		Default:   KeyLimit{RatePerSecond: tb.config.RatePerSecond, Burst: tb.config.BurstSize},
+		Custom:    make(map[string]KeyLimit, len(tb.custom)),
+	}
+	for key, limit := range tb.custom {
+		info.Custom[key] = limit
+	}
+	return info
+}
+
+// limit 返回key的补充速率和桶容量
+func (tb *RedisTokenBucketLimiter) limit(key string) (float64, int) {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+
+	if custom, exists := tb.custom[key]; exists {
+		return custom.RatePerSecond, custom.Burst
+	}
+	return tb.config.RatePerSecond, tb.config.BurstSize
+}
+
+// run 执行令牌桶脚本，返回是否允许、剩余令牌数以及令牌足够还需等待的时间（n超过桶容量时为负数）
+func (tb *RedisTokenBucketLimiter) run(ctx context.Context, key string, n int) (bool, int64, time.Duration, error) {
+	ratePerSecond, burst := tb.limit(key)
+	if ratePerSecond <= 0 {
+		return false, 0, -1, nil
+	}
+
+	result, err := redisTokenBucketScript.Run(ctx, tb.client,
+		[]string{tb.config.KeyPrefix + key}, ratePerSecond, burst, n).Int64Slice()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return result[0] == 1, result[1], time.Duration(result[2]) * time.Millisecond, nil
+}
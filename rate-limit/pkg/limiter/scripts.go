@@ -0,0 +1,6 @@
+package limiter
+
+import "redis-learning/pkg/script"
+
+// scripts 限流器使用的Lua脚本，以 EVALSHA 执行，Redis中没有脚本时自动加载
+var scripts = script.NewBundle("rate-limit")
@@ -0,0 +1,148 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SlidingLogConfig 滑动日志限流器配置
+type SlidingLogConfig struct {
+	// 任意一个窗口长度内允许的请求数，每个请求在Redis中占用一条记录，不宜过大
+	Limit int64
+	// 窗口长度，精度为毫秒
+	Window time.Duration
+	// Redis中请求日志的key前缀
+	KeyPrefix string
+}
+
+// DefaultSlidingLogConfig 默认滑动日志限流配置
+var DefaultSlidingLogConfig = SlidingLogConfig{
+	Limit:     10,          // 每分钟10个请求
+	Window:    time.Minute, // 窗口长度1分钟
+	KeyPrefix: "ratelimit:sl:",
+}
+
+// slidingLogScript 每个key用一个ZSET记录请求的时间戳（毫秒），先删除窗口之外的记录，
+// 剩余记录数加上本次请求数不超过限额时写入n条记录
+// ARGV[4]为调用方生成的随机串，保证同一毫秒内的不同请求写入不同的成员
+// 返回 {是否允许, 窗口内的请求数（允许时包含本次）}
+var slidingLogScript = scripts.Register("sliding-log", `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count + n > limit then
+	return {0, count}
+end
+
+for i = 1, n do
+	redis.call('ZADD', KEYS[1], now, now .. ':' .. ARGV[4] .. ':' .. i)
+end
+redis.call('PEXPIRE', KEYS[1], window)
+return {1, count + n}
+`)
+
+// SlidingLogLimiter 基于Redis的滑动日志限流器
+// 记录窗口内每个请求的时间戳，任意一个窗口长度内的请求数都不会超过限额，没有估计误差；
+// 代价是每个请求占用一条记录，适用于限额小、需要精确限流的key（如短信验证码、支付接口）
+type SlidingLogLimiter struct {
+	config SlidingLogConfig
+	client redis.UniversalClient
+	custom keyLimits
+}
+
+// NewSlidingLogLimiter 创建一个新的滑动日志限流器
+func NewSlidingLogLimiter(client redis.UniversalClient, config SlidingLogConfig) *SlidingLogLimiter {
+	return &SlidingLogLimiter{
+		config: config,
+		client: client,
+	}
+}
+
+// NewDefaultSlidingLogLimiter 使用默认配置创建滑动日志限流器
+func NewDefaultSlidingLogLimiter(client redis.UniversalClient) *SlidingLogLimiter {
+	return NewSlidingLogLimiter(client, DefaultSlidingLogConfig)
+}
+
+// Allow 检查指定key的访问是否被允许
+func (sl *SlidingLogLimiter) Allow(key string) bool {
+	return sl.AllowN(key, 1)
+}
+
+// AllowN 检查指定key的n次访问是否被允许，允许时一次计入n次
+// Redis不可用时放行请求，见 allowOnError
+func (sl *SlidingLogLimiter) AllowN(key string, n int) bool {
+	allowed, count, err := sl.run(key, n)
+	if err != nil {
+		return allowOnError("sliding log", key, err)
+	}
+	if !allowed {
+		log.Printf("Rate limited: %s (%d requests in window)", key, count)
+	}
+	return allowed
+}
+
+// Wait 等待直到指定key的访问被允许，按平均请求间隔轮询Redis
+func (sl *SlidingLogLimiter) Wait(ctx context.Context, key string) error {
+	return sl.WaitN(ctx, key, 1)
+}
+
+// WaitN 轮询直到n次访问被允许，n超过窗口限额时立即返回 ErrExceedsLimit
+func (sl *SlidingLogLimiter) WaitN(ctx context.Context, key string, n int) error {
+	limit := sl.custom.get(key, sl.config.Limit)
+	if int64(n) > limit {
+		return ErrExceedsLimit
+	}
+	return pollUntilAllowed(ctx, sl.config.Window/time.Duration(max(limit, 1)), func() bool {
+		allowed, _, err := sl.run(key, n)
+		if err != nil {
+			return allowOnError("sliding log", key, err)
+		}
+		return allowed
+	})
+}
+
+// SetLimit 为特定key设置自定义限流速率，每个窗口允许的请求数为 ratePerSecond × Window，忽略burst
+func (sl *SlidingLogLimiter) SetLimit(key string, ratePerSecond float64, burst int) {
+	limit := windowLimit(ratePerSecond, sl.config.Window)
+	sl.custom.set(key, limit)
+	log.Printf("Set custom limit for %s: %d requests per %v", key, limit, sl.config.Window)
+}
+
+// ClearLimit 清除特定key的自定义限额
+func (sl *SlidingLogLimiter) ClearLimit(key string) {
+	sl.custom.remove(key)
+	log.Printf("Cleared custom limit for %s", key)
+}
+
+// Info 返回限流器的默认配置和按key设置的自定义限额
+func (sl *SlidingLogLimiter) Info() LimiterInfo {
+	return sl.custom.windowInfo(AlgorithmSlidingLog, sl.config.Limit, sl.config.Window)
+}
+
+// run 执行限流脚本，返回是否允许以及窗口内的请求数
+func (sl *SlidingLogLimiter) run(key string, n int) (bool, int64, error) {
+	window := sl.config.Window.Milliseconds()
+	if window <= 0 {
+		window = 1
+	}
+	nonce := strconv.FormatUint(rand.Uint64(), 36)
+	limit := sl.custom.get(key, sl.config.Limit)
+
+	result, err := slidingLogScript.Run(context.Background(), sl.client,
+		[]string{sl.config.KeyPrefix + key}, limit, window, n, nonce).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, result[1], nil
+}
@@ -0,0 +1,156 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SlidingWindowConfig 滑动窗口限流器配置
+type SlidingWindowConfig struct {
+	// 每个窗口内允许的请求数
+	Limit int64
+	// 窗口长度，精度为毫秒
+	Window time.Duration
+	// Redis中限流计数的key前缀
+	KeyPrefix string
+}
+
+// DefaultSlidingWindowConfig 默认滑动窗口限流配置
+var DefaultSlidingWindowConfig = SlidingWindowConfig{
+	Limit:     10,          // 每个窗口10个请求
+	Window:    time.Second, // 窗口长度1秒
+	KeyPrefix: "ratelimit:sw:",
+}
+
+// slidingWindowScript 滑动窗口计数：每个key用一个hash保存当前窗口的起始时间、当前窗口和上一个窗口的计数，
+// 估计值 = 上一个窗口的计数 × 上一个窗口仍在滑动窗口内的比例 + 当前窗口的计数
+// 时间取自Redis服务器，多个实例之间的时钟偏差不影响限流
+// 返回 {是否允许, 本次之后的估计计数}
+var slidingWindowScript = scripts.Register("sliding-window", `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local start = now - now % window
+
+local data = redis.call('HMGET', KEYS[1], 'start', 'curr', 'prev')
+local curStart = tonumber(data[1]) or start
+local curr = tonumber(data[2]) or 0
+local prev = tonumber(data[3]) or 0
+if curStart < start then
+	if curStart == start - window then
+		prev = curr
+	else
+		prev = 0
+	end
+	curr = 0
+end
+
+local count = prev * (window - (now - start)) / window + curr
+if count + n > limit then
+	return {0, math.ceil(count)}
+end
+
+curr = curr + n
+redis.call('HSET', KEYS[1], 'start', start, 'curr', curr, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {1, math.ceil(count + n)}
+`)
+
+// SlidingWindowLimiter 基于Redis的滑动窗口限流器
+// 计数保存在Redis中，多个API服务实例共享同一个限额，而不是每个进程各自限流
+type SlidingWindowLimiter struct {
+	config SlidingWindowConfig
+	client redis.UniversalClient
+	custom keyLimits
+}
+
+// NewSlidingWindowLimiter 创建一个新的滑动窗口限流器
+func NewSlidingWindowLimiter(client redis.UniversalClient, config SlidingWindowConfig) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		config: config,
+		client: client,
+	}
+}
+
+// NewDefaultSlidingWindowLimiter 使用默认配置创建滑动窗口限流器
+func NewDefaultSlidingWindowLimiter(client redis.UniversalClient) *SlidingWindowLimiter {
+	return NewSlidingWindowLimiter(client, DefaultSlidingWindowConfig)
+}
+
+// Allow 检查指定key的访问是否被允许
+func (sw *SlidingWindowLimiter) Allow(key string) bool {
+	return sw.AllowN(key, 1)
+}
+
+// AllowN 检查指定key的n次访问是否被允许，允许时一次计入n次
+// Redis不可用时放行请求，见 allowOnError
+func (sw *SlidingWindowLimiter) AllowN(key string, n int) bool {
+	allowed, count, err := sw.run(key, n)
+	if err != nil {
+		return allowOnError("sliding window", key, err)
+	}
+	if !allowed {
+		log.Printf("Rate limited: %s (%d requests in window)", key, count)
+	}
+	return allowed
+}
+
+// Wait 等待直到指定key的访问被允许，按平均请求间隔轮询Redis
+func (sw *SlidingWindowLimiter) Wait(ctx context.Context, key string) error {
+	return sw.WaitN(ctx, key, 1)
+}
+
+// WaitN 轮询直到n次访问被允许，n超过窗口限额时立即返回 ErrExceedsLimit
+func (sw *SlidingWindowLimiter) WaitN(ctx context.Context, key string, n int) error {
+	limit := sw.custom.get(key, sw.config.Limit)
+	if int64(n) > limit {
+		return ErrExceedsLimit
+	}
+	return pollUntilAllowed(ctx, sw.config.Window/time.Duration(max(limit, 1)), func() bool {
+		allowed, _, err := sw.run(key, n)
+		if err != nil {
+			return allowOnError("sliding window", key, err)
+		}
+		return allowed
+	})
+}
+
+// SetLimit 为特定key设置自定义限流速率，每个窗口允许的请求数为 ratePerSecond × Window，忽略burst
+func (sw *SlidingWindowLimiter) SetLimit(key string, ratePerSecond float64, burst int) {
+	limit := windowLimit(ratePerSecond, sw.config.Window)
+	sw.custom.set(key, limit)
+	log.Printf("Set custom limit for %s: %d requests per %v", key, limit, sw.config.Window)
+}
+
+// ClearLimit 清除特定key的自定义限额
+func (sw *SlidingWindowLimiter) ClearLimit(key string) {
+	sw.custom.remove(key)
+	log.Printf("Cleared custom limit for %s", key)
+}
+
+// Info 返回限流器的默认配置和按key设置的自定义限额
+func (sw *SlidingWindowLimiter) Info() LimiterInfo {
+	return sw.custom.windowInfo(AlgorithmSlidingWindow, sw.config.Limit, sw.config.Window)
+}
+
+// run 执行限流脚本，返回是否允许以及窗口内的请求数
+func (sw *SlidingWindowLimiter) run(key string, n int) (bool, int64, error) {
+	window := sw.config.Window.Milliseconds()
+	if window <= 0 {
+		window = 1
+	}
+	limit := sw.custom.get(key, sw.config.Limit)
+
+	result, err := slidingWindowScript.Run(context.Background(), sw.client,
+		[]string{sw.config.KeyPrefix + key}, limit, window, n).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, result[1], nil
+}
@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+
+	sharedmetrics "redis-learning/pkg/metrics"
+)
+
+// 指标名称的前缀
+const namespace = "ratelimit"
+
+// Redis命令指标中的模块名
+const module = "rate-limit"
+
+// key的分类
+const (
+	ClassHot    = "hot"
+	ClassNormal = "normal"
+)
+
+// 拒绝请求的限流维度，与429响应中的limit字段一致
+const (
+	LimitIP          = "ip"
+	LimitTier        = "tier"
+	LimitKey         = "key"
+	LimitConcurrency = "concurrency"
+	LimitRoute       = "route"
+	LimitPenalty     = "penalty"
+)
+
+// Metrics 限流服务的Prometheus指标，使用独立的注册表，同一进程中的多个服务器互不影响
+type Metrics struct {
+	registry    *sharedmetrics.Registry
+	requests    *prometheus.CounterVec
+	limited     *prometheus.CounterVec
+	shadow      *prometheus.CounterVec
+	cacheLookup *prometheus.CounterVec
+	redisErrors prometheus.Counter
+	queueWait   *prometheus.HistogramVec
+	refreshes   *prometheus.CounterVec
+	replicas    *prometheus.CounterVec
+	hotReads    *prometheus.CounterVec
+}
+
+// NewMetrics 创建指标，注册表中已经包含Go运行时和进程的指标
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: sharedmetrics.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Key requests by key class (hot, normal) and result (allowed, limited).",
+		}, []string{"class", "result"}),
+		limited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "limited_total",
+			Help:      "Rejected requests by the limit that rejected them (ip, route, tier, penalty, key, concurrency).",
+		}, []string{"limit"}),
+		shadow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "shadow_limited_total",
+			Help:      "Requests that would have been rejected in shadow mode, by limit (ip, route, tier, penalty, key, concurrency).",
+		}, []string{"limit"}),
+		cacheLookup: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "local_cache_lookups_total",
+			Help:      "Local cache lookups of hot keys by result (hit, miss).",
+		}, []string{"result"}),
+		redisErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redis_errors_total",
+			Help:      "Failed Redis reads and writes of key values.",
+		}),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "queue_wait_seconds",
+			Help:      "Time hot key requests waited in the rate limiter queue by result (allowed, limited).",
+			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.2, 0.5, 1},
+		}, []string{"result"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_refreshes_total",
+			Help:      "Proactive refreshes of hot key local cache entries by result (refreshed, failed).",
+		}, []string{"result"}),
+		replicas: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "replica_reads_total",
+			Help:      "Hot key reads sent to Redis replicas by result (replica, fallback to the primary).",
+		}, []string{"result"}),
+		hotReads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "hot_key_reads_total",
+			Help:      "Hot key reads by source (local_cache, coalesced, origin for Redis reads made by a request, refresh for background Redis reads).",
+		}, []string{"source"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.limited,
+		m.shadow,
+		m.cacheLookup,
+		m.redisErrors,
+		m.queueWait,
+		m.refreshes,
+		m.replicas,
+		m.hotReads,
+	)
+	return m
+}
+
+// ObserveRequest 记录一次key请求的结果
+func (m *Metrics) ObserveRequest(class string, allowed bool) {
+	result := "allowed"
+	if !allowed {
+		result = "limited"
+	}
+	m.requests.WithLabelValues(class, result).Inc()
+}
+
+// ObserveLimited 记录一次被指定维度拒绝的请求
+func (m *Metrics) ObserveLimited(limit string) {
+	m.limited.WithLabelValues(limit).Inc()
+}
+
+// ObserveShadowLimited 记录一次影子模式下本应被指定维度拒绝的请求
+func (m *Metrics) ObserveShadowLimited(limit string) {
+	m.shadow.WithLabelValues(limit).Inc()
+}
+
+// ObserveCacheLookup 记录一次热点key的本地缓存查找
+func (m *Metrics) ObserveCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookup.WithLabelValues(result).Inc()
+}
+
+// ObserveRedisError 记录一次Redis错误
+func (m *Metrics) ObserveRedisError() {
+	m.redisErrors.Inc()
+}
+
+// ObserveCacheRefresh 记录一次热点key本地缓存的提前刷新
+func (m *Metrics) ObserveCacheRefresh(ok bool) {
+	result := "refreshed"
+	if !ok {
+		result = "failed"
+	}
+	m.refreshes.WithLabelValues(result).Inc()
+}
+
+// ObserveReplicaRead 记录一次从只读副本读取热点key，fallback表示改为从主节点读取
+func (m *Metrics) ObserveReplicaRead(fallback bool) {
+	result := "replica"
+	if fallback {
+		result = "fallback"
+	}
+	m.replicas.WithLabelValues(result).Inc()
+}
+
+// ObserveHotKeyRead 记录一次热点key的读取，source为读取的来源
+func (m *Metrics) ObserveHotKeyRead(source string) {
+	m.hotReads.WithLabelValues(source).Inc()
+}
+
+// ObserveQueueWait 记录一次请求在限流器中排队等待的时间
+func (m *Metrics) ObserveQueueWait(waited time.Duration, allowed bool) {
+	result := "allowed"
+	if !allowed {
+		result = "limited"
+	}
+	m.queueWait.WithLabelValues(result).Observe(waited.Seconds())
+}
+
+// GaugeFunc 注册一个在抓取时通过fn计算的指标，如当前的热点key数量
+func (m *Metrics) GaugeFunc(name, help string, labels prometheus.Labels, fn func() float64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        name,
+		Help:        help,
+		ConstLabels: labels,
+	}, fn))
+}
+
+// RedisHook 返回统计Redis命令的Hook，与其他模块的服务使用相同的指标名称
+func (m *Metrics) RedisHook() redis.Hook {
+	return m.registry.RedisHook(module)
+}
+
+// Handler 返回以Prometheus文本格式输出指标的HTTP处理函数
+func (m *Metrics) Handler() http.Handler {
+	return m.registry.Handler()
+}
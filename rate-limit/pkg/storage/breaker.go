@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen 熔断器打开，没有访问Redis
+var ErrCircuitOpen = errors.New("redis circuit breaker is open")
+
+// BreakerConfig 熔断器配置
+type BreakerConfig struct {
+	// 连续失败多少次后熔断，为0时不启用熔断
+	FailureThreshold int `yaml:"failure_threshold"`
+	// 熔断持续的时间，之后放行一个探测请求
+	OpenTimeout time.Duration `yaml:"open_timeout"`
+}
+
+// DefaultBreakerConfig 默认熔断器配置
+var DefaultBreakerConfig = BreakerConfig{
+	FailureThreshold: 5,               // 连续失败5次后熔断
+	OpenTimeout:      5 * time.Second, // 5秒后探测Redis是否恢复
+}
+
+// breakerState 熔断器状态
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // 正常访问Redis
+	breakerOpen                         // 熔断，直接拒绝访问Redis
+	breakerHalfOpen                     // 熔断超时后放行一个探测请求
+)
+
+// circuitBreaker 连续失败达到阈值后打开，经过OpenTimeout后放行一个探测请求，
+// 探测成功则关闭，失败则重新打开
+// Redis故障时请求不再逐个等待连接或读取超时，而是立即失败，避免请求和连接堆积
+type circuitBreaker struct {
+	config   BreakerConfig
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// newCircuitBreaker 创建熔断器
+func newCircuitBreaker(config BreakerConfig) *circuitBreaker {
+	return &circuitBreaker{config: config}
+}
+
+// allow 判断本次请求是否可以访问Redis
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			return false
+		}
+		b.state, b.probing = breakerHalfOpen, true
+		log.Printf("Redis circuit breaker half-open, probing Redis")
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record 记录一次请求的结果，key不存在和调用方取消不算作失败
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if errors.Is(err, context.Canceled) {
+		b.probing = false
+		return
+	}
+	failed := err != nil && !errors.Is(err, redis.Nil)
+
+	switch b.state {
+	case breakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.state, b.openedAt = breakerOpen, time.Now()
+			log.Printf("Redis circuit breaker opened after %d consecutive failures: %v", b.failures, err)
+		}
+	case breakerHalfOpen:
+		b.probing, b.failures = false, 0
+		if failed {
+			b.state, b.openedAt = breakerOpen, time.Now()
+			log.Printf("Redis circuit breaker reopened, probe failed: %v", err)
+			return
+		}
+		b.state = breakerClosed
+		log.Printf("Redis circuit breaker closed, Redis recovered")
+	}
+}
+
+// isOpen 判断当前是否处于熔断（含半开）状态
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state != breakerClosed
+}
+
+// do 在熔断器允许时执行fn并记录结果，熔断时返回 ErrCircuitOpen
+func (b *circuitBreaker) do(fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
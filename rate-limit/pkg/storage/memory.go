@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryEntry 内存存储中的一个键值
+type memoryEntry struct {
+	value string
+	// 过期时间，零值表示不过期
+	expiresAt time.Time
+}
+
+// expired 判断键值在now时是否已过期
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// MemoryStorage 进程内的键值存储，语义与 RedisClient 相同：值按Redis的方式格式化为字符串，过期的键在访问时删除
+// 用于测试和不需要多实例共享数据的单机运行，不依赖Redis
+type MemoryStorage struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryStorage 创建一个空的内存存储
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{entries: make(map[string]memoryEntry)}
+}
+
+// Get 获取键值
+func (m *MemoryStorage) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.lookup(key, time.Now())
+	if !exists {
+		return "", nil // 键不存在返回空字符串
+	}
+	return entry.value, nil
+}
+
+// MGet 一次获取多个键值
+func (m *MemoryStorage) MGet(keys ...string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		if entry, exists := m.lookup(key, now); exists {
+			values[i] = entry.value
+		}
+	}
+	return values, nil
+}
+
+// Set 设置键值
+func (m *MemoryStorage) Set(key string, value interface{}, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = newMemoryEntry(value, expiration)
+	return nil
+}
+
+// Incr 递增键的值，保留原有的过期时间
+func (m *MemoryStorage) Incr(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, _ := m.lookup(key, time.Now())
+	var n int64
+	if entry.value != "" {
+		var err error
+		if n, err = strconv.ParseInt(entry.value, 10, 64); err != nil {
+			return 0, fmt.Errorf("value of %s is not an integer", key)
+		}
+	}
+	n++
+	entry.value = strconv.FormatInt(n, 10)
+	m.entries[key] = entry
+	return n, nil
+}
+
+// SetNX 当key不存在时设置键值
+func (m *MemoryStorage) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.lookup(key, time.Now()); exists {
+		return false, nil
+	}
+	m.entries[key] = newMemoryEntry(value, expiration)
+	return true, nil
+}
+
+// Del 删除键
+func (m *MemoryStorage) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+// Close 清空存储
+func (m *MemoryStorage) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clear(m.entries)
+	return nil
+}
+
+// lookup 查找未过期的键值，过期的键值被删除，调用方需持有锁
+func (m *MemoryStorage) lookup(key string, now time.Time) (memoryEntry, bool) {
+	entry, exists := m.entries[key]
+	if exists && entry.expired(now) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, exists
+}
+
+// newMemoryEntry 创建键值，expiration不大于0时不过期
+func newMemoryEntry(value interface{}, expiration time.Duration) memoryEntry {
+	entry := memoryEntry{value: fmt.Sprint(value)}
+	if expiration > 0 {
+		entry.expiresAt = time.Now().Add(expiration)
+	}
+	return entry
+}
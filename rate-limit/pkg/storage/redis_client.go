@@ -4,29 +4,44 @@ import (
 	"context"
 	"errors"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"redis-learning/pkg/config"
+	"redis-learning/pkg/redisclient"
 )
 
 // RedisConfig Redis配置参数
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
+	// 地址、密码、数据库、连接池和超时重试配置，只支持standalone模式
+	config.RedisOptions `yaml:",inline"`
+	// 熔断器配置
+	Breaker BreakerConfig `yaml:"breaker"`
+	// 只读副本的地址，使用与主节点相同的密码、数据库和连接配置，见 RedisClient.GetFromReplica
+	Replicas []string `yaml:"replicas"`
 }
 
 // DefaultConfig 默认Redis配置
 var DefaultConfig = RedisConfig{
-	Addr:     "localhost:6379",
-	Password: "",
-	DB:       0,
+	RedisOptions: config.RedisOptions{
+		Addr:     "localhost:6379",
+		Password: "",
+		DB:       0,
+	},
+	Breaker: DefaultBreakerConfig,
 }
 
 // RedisClient Redis客户端封装
+// 键值操作经过熔断器，Redis连续出错后直接返回 ErrCircuitOpen；Client 返回的底层客户端不经过熔断器
 type RedisClient struct {
-	client *redis.Client
-	ctx    context.Context
+	client  *redis.Client
+	ctx     context.Context
+	breaker *circuitBreaker // 未启用熔断时为nil
+	// 只读副本，轮流使用
+	replicas []*redis.Client
+	next     atomic.Uint64
 }
 
 // NewRedisClient 创建一个新的Redis客户端
@@ -34,35 +49,52 @@ func NewRedisClient() *RedisClient {
 	return NewRedisClientWithConfig(DefaultConfig)
 }
 
-// NewRedisClientWithConfig 使用指定配置创建Redis客户端
-func NewRedisClientWithConfig(config RedisConfig) *RedisClient {
-	client := redis.NewClient(&redis.Options{
-		Addr:     config.Addr,
-		Password: config.Password,
-		DB:       config.DB,
-	})
+// NewRedisClientWithConfig 使用指定配置创建Redis客户端，hooks 添加到主节点和所有只读副本的客户端上
+func NewRedisClientWithConfig(config RedisConfig, hooks ...redis.Hook) *RedisClient {
+	client := redisclient.NewClient(config.RedisOptions, hooks...)
 
 	// 创建上下文
 	ctx := context.Background()
 
 	// 测试连接
-	if err := client.Ping(ctx).Err(); err != nil {
+	if err := redisclient.Ping(ctx, client); err != nil {
 		log.Printf("Failed to connect to Redis: %v", err)
 	} else {
 		log.Printf("Successfully connected to Redis at %s", config.Addr)
 	}
 
-	return &RedisClient{
+	r := &RedisClient{
 		client: client,
 		ctx:    ctx,
 	}
+	if config.Breaker.FailureThreshold > 0 {
+		r.breaker = newCircuitBreaker(config.Breaker)
+	}
+	for _, addr := range config.Replicas {
+		replicaOptions := config.RedisOptions
+		replicaOptions.Addr = addr
+		replica := redisclient.NewClient(replicaOptions, hooks...)
+		if err := redisclient.Ping(ctx, replica); err != nil {
+			log.Printf("Failed to connect to Redis replica %s: %v", addr, err)
+		} else {
+			log.Printf("Successfully connected to Redis replica at %s", addr)
+		}
+		r.replicas = append(r.replicas, replica)
+	}
+	return r
 }
 
 // Get 获取键值
 func (r *RedisClient) Get(key string) (string, error) {
-	val, err := r.client.Get(r.ctx, key).Result()
+	var val string
+	err := r.breaker.do(func() (err error) {
+		val, err = r.client.Get(r.ctx, key).Result()
+		return err
+	})
 	if err != nil && !errors.Is(err, redis.Nil) {
-		log.Printf("Error getting key %s: %v", key, err)
+		if !errors.Is(err, ErrCircuitOpen) {
+			log.Printf("Error getting key %s: %v", key, err)
+		}
 		return "", err
 	}
 	if errors.Is(err, redis.Nil) {
@@ -71,10 +103,50 @@ func (r *RedisClient) Get(key string) (string, error) {
 	return val, nil
 }
 
+// GetFromReplica 从只读副本获取键值，多个副本轮流使用；没有配置副本时从主节点读取
+// 副本的复制有延迟，读到的可能是旧值；副本不经过熔断器，出错时由调用方决定是否改为从主节点读取
+func (r *RedisClient) GetFromReplica(key string) (string, error) {
+	if len(r.replicas) == 0 {
+		return r.Get(key)
+	}
+
+	replica := r.replicas[r.next.Add(1)%uint64(len(r.replicas))]
+	val, err := replica.Get(r.ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return val, err
+}
+
+// MGet 一次获取多个键值，不存在的键返回空字符串
+func (r *RedisClient) MGet(keys ...string) ([]string, error) {
+	var vals []interface{}
+	err := r.breaker.do(func() (err error) {
+		vals, err = r.client.MGet(r.ctx, keys...).Result()
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrCircuitOpen) {
+			log.Printf("Error getting %d keys: %v", len(keys), err)
+		}
+		return nil, err
+	}
+
+	values := make([]string, len(vals))
+	for i, val := range vals {
+		if s, ok := val.(string); ok {
+			values[i] = s
+		}
+	}
+	return values, nil
+}
+
 // Set 设置键值
 func (r *RedisClient) Set(key string, value interface{}, expiration time.Duration) error {
-	err := r.client.Set(r.ctx, key, value, expiration).Err()
-	if err != nil {
+	err := r.breaker.do(func() error {
+		return r.client.Set(r.ctx, key, value, expiration).Err()
+	})
+	if err != nil && !errors.Is(err, ErrCircuitOpen) {
 		log.Printf("Error setting key %s: %v", key, err)
 	}
 	return err
@@ -82,8 +154,12 @@ func (r *RedisClient) Set(key string, value interface{}, expiration time.Duratio
 
 // Incr 递增键的值
 func (r *RedisClient) Incr(key string) (int64, error) {
-	val, err := r.client.Incr(r.ctx, key).Result()
-	if err != nil {
+	var val int64
+	err := r.breaker.do(func() (err error) {
+		val, err = r.client.Incr(r.ctx, key).Result()
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrCircuitOpen) {
 		log.Printf("Error incrementing key %s: %v", key, err)
 	}
 	return val, err
@@ -91,15 +167,36 @@ func (r *RedisClient) Incr(key string) (int64, error) {
 
 // SetNX 当key不存在时设置键值
 func (r *RedisClient) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
-	return r.client.SetNX(r.ctx, key, value, expiration).Result()
+	var ok bool
+	err := r.breaker.do(func() (err error) {
+		ok, err = r.client.SetNX(r.ctx, key, value, expiration).Result()
+		return err
+	})
+	return ok, err
 }
 
 // Del 删除键
 func (r *RedisClient) Del(key string) error {
-	return r.client.Del(r.ctx, key).Err()
+	return r.breaker.do(func() error {
+		return r.client.Del(r.ctx, key).Err()
+	})
+}
+
+// BreakerOpen 熔断器是否处于打开或半开状态，未启用熔断时返回false
+func (r *RedisClient) BreakerOpen() bool {
+	return r.breaker != nil && r.breaker.isOpen()
 }
 
-// Close 关闭Redis连接
+// Client 返回底层的go-redis客户端，供需要直接执行命令或脚本的组件（如基于Redis的限流器）共用连接
+func (r *RedisClient) Client() *redis.Client {
+	return r.client
+}
+
+// Close 关闭Redis连接，包括只读副本
 func (r *RedisClient) Close() error {
-	return r.client.Close()
+	errs := []error{r.client.Close()}
+	for _, replica := range r.replicas {
+		errs = append(errs, replica.Close())
+	}
+	return errors.Join(errs...)
 }
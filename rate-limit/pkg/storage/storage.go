@@ -0,0 +1,35 @@
+package storage
+
+import "time"
+
+// Storage 键值存储接口，API服务通过它读写key的值，不直接依赖Redis
+// 生产环境使用 RedisClient，测试和单机运行可以使用 MemoryStorage
+type Storage interface {
+	// Get 获取键值，键不存在时返回空字符串和nil
+	Get(key string) (string, error)
+	// MGet 一次获取多个键值，结果与keys一一对应，不存在的键为空字符串
+	MGet(keys ...string) ([]string, error)
+	// Set 设置键值，expiration为0时不过期
+	Set(key string, value interface{}, expiration time.Duration) error
+	// Incr 递增键的值，键不存在时从0开始
+	Incr(key string) (int64, error)
+	// SetNX 当key不存在时设置键值，返回是否设置成功
+	SetNX(key string, value interface{}, expiration time.Duration) (bool, error)
+	// Del 删除键
+	Del(key string) error
+	// Close 释放存储占用的资源
+	Close() error
+}
+
+// ReplicaReader 可以从只读副本读取的存储，API服务用它把热点key的读取分摊到副本上
+type ReplicaReader interface {
+	// GetFromReplica 从只读副本获取键值，语义与 Storage.Get 相同，读到的值可能落后于主节点
+	GetFromReplica(key string) (string, error)
+}
+
+// 编译期检查各存储实现了 Storage 接口
+var (
+	_ Storage       = (*RedisClient)(nil)
+	_ Storage       = (*MemoryStorage)(nil)
+	_ ReplicaReader = (*RedisClient)(nil)
+)
@@ -0,0 +1,267 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"rate-limit/pkg/cache"
+	"rate-limit/pkg/limiter"
+)
+
+// 套餐等级
+const (
+	TierFree       = "free"
+	TierPro        = "pro"
+	TierEnterprise = "enterprise"
+)
+
+var (
+	// ErrUnknownAPIKey API key不存在或已被吊销
+	ErrUnknownAPIKey = errors.New("unknown api key")
+	// ErrUnknownTier 套餐表中没有该等级
+	ErrUnknownTier = errors.New("unknown tier")
+)
+
+// Plan 套餐的限流额度，以JSON保存在Redis的套餐表中
+type Plan struct {
+	// 每秒允许的请求数
+	RatePerSecond float64 `json:"rate"`
+	// 允许的突发请求数
+	Burst int `json:"burst"`
+}
+
+// DefaultPlans 默认套餐，套餐表中没有对应等级时写入
+var DefaultPlans = map[string]Plan{
+	TierFree:       {RatePerSecond: 5, Burst: 10},
+	TierPro:        {RatePerSecond: 50, Burst: 100},
+	TierEnterprise: {RatePerSecond: 500, Burst: 1000},
+}
+
+// StoreConfig 租户存储配置
+type StoreConfig struct {
+	// 套餐表，hash的field为等级，value为JSON格式的 Plan
+	PlansKey string
+	// API key表，hash的field为API key，value为等级
+	APIKeysKey string
+	// API key和套餐在本地缓存的时间，修改后最多经过该时间在所有实例上生效
+	CacheTTL time.Duration
+}
+
+// DefaultStoreConfig 默认租户存储配置
+var DefaultStoreConfig = StoreConfig{
+	PlansKey:   "ratelimit:plans",
+	APIKeysKey: "ratelimit:apikeys",
+	CacheTTL:   30 * time.Second,
+}
+
+// Store 保存在Redis中的API key、套餐等级和套餐额度，按调用方的等级限流
+// 同一个key对不同调用方有不同的额度：限流的粒度是"API key + key"，额度由API key所属的等级决定
+type Store struct {
+	config StoreConfig
+	client redis.UniversalClient
+	// API key → 等级，不存在的API key缓存为空字符串，避免无效的API key每次都访问Redis
+	apiKeys *cache.LocalCache
+
+	mu       sync.Mutex
+	plans    map[string]Plan
+	loadedAt time.Time
+	limiters map[string]*limiter.RateLimiter // 等级 → 该等级的令牌桶限流器
+}
+
+// NewStore 创建租户存储，套餐表中缺少的默认套餐会被写入
+func NewStore(client redis.UniversalClient, config StoreConfig) *Store {
+	s := &Store{
+		config:   config,
+		client:   client,
+		apiKeys:  cache.NewLocalCache(config.CacheTTL, time.Minute),
+		limiters: make(map[string]*limiter.RateLimiter),
+	}
+
+	ctx := context.Background()
+	for tier, plan := range DefaultPlans {
+		data, _ := json.Marshal(plan)
+		if err := client.HSetNX(ctx, config.PlansKey, tier, data).Err(); err != nil {
+			log.Printf("Failed to initialize plan %s: %v", tier, err)
+		}
+	}
+	return s
+}
+
+// NewDefaultStore 使用默认配置创建租户存储
+func NewDefaultStore(client redis.UniversalClient) *Store {
+	return NewStore(client, DefaultStoreConfig)
+}
+
+// Allow 按API key所属等级的额度检查该调用方对key的访问是否被允许，返回调用方的等级
+// API key不存在时返回 ErrUnknownAPIKey
+func (s *Store) Allow(ctx context.Context, apiKey, key string) (string, bool, error) {
+	return s.AllowN(ctx, apiKey, key, 1)
+}
+
+// AllowN 与 Allow 相同，但一次消耗n个令牌，用于批量读取等代价更高的请求
+func (s *Store) AllowN(ctx context.Context, apiKey, key string, n int) (string, bool, error) {
+	tier, err := s.Tier(ctx, apiKey)
+	if err != nil {
+		return "", false, err
+	}
+	rl, err := s.tierLimiter(ctx, tier)
+	if err != nil {
+		return tier, false, err
+	}
+	return tier, rl.AllowN(apiKey+":"+key, n), nil
+}
+
+// Tier 返回API key所属的等级
+func (s *Store) Tier(ctx context.Context, apiKey string) (string, error) {
+	if tier, found := s.apiKeys.Get(apiKey); found {
+		if tier == "" {
+			return "", ErrUnknownAPIKey
+		}
+		return tier, nil
+	}
+
+	tier, err := s.client.HGet(ctx, s.config.APIKeysKey, apiKey).Result()
+	if errors.Is(err, redis.Nil) {
+		s.apiKeys.Set(apiKey, "", s.config.CacheTTL)
+		return "", ErrUnknownAPIKey
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get tier of api key: %w", err)
+	}
+	s.apiKeys.Set(apiKey, tier, s.config.CacheTTL)
+	return tier, nil
+}
+
+// AssignTier 把API key分配到指定等级，API key不存在时创建，等级必须在套餐表中
+func (s *Store) AssignTier(ctx context.Context, apiKey, tier string) error {
+	plans, err := s.loadPlans(ctx)
+	if err != nil {
+		return err
+	}
+	if _, exists := plans[tier]; !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownTier, tier)
+	}
+	if err := s.client.HSet(ctx, s.config.APIKeysKey, apiKey, tier).Err(); err != nil {
+		return fmt.Errorf("failed to assign tier: %w", err)
+	}
+	s.apiKeys.Set(apiKey, tier, s.config.CacheTTL)
+	log.Printf("Assigned api key to tier %s", tier)
+	return nil
+}
+
+// RevokeAPIKey 吊销API key
+func (s *Store) RevokeAPIKey(ctx context.Context, apiKey string) error {
+	if err := s.client.HDel(ctx, s.config.APIKeysKey, apiKey).Err(); err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	s.apiKeys.Set(apiKey, "", s.config.CacheTTL)
+	return nil
+}
+
+// Plans 返回套餐表
+func (s *Store) Plans(ctx context.Context) (map[string]Plan, error) {
+	plans, err := s.loadPlans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]Plan, len(plans))
+	for tier, plan := range plans {
+		result[tier] = plan
+	}
+	return result, nil
+}
+
+// tierLimiter 返回指定等级的限流器，套餐额度变化时重新创建
+func (s *Store) tierLimiter(ctx context.Context, tier string) (*limiter.RateLimiter, error) {
+	plans, err := s.loadPlans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan, exists := plans[tier]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTier, tier)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rl, exists := s.limiters[tier]; exists {
+		return rl, nil
+	}
+	rl := limiter.NewRateLimiter(limiter.RateLimiterConfig{RatePerSecond: plan.RatePerSecond, BurstSize: plan.Burst})
+	s.limiters[tier] = rl
+	return rl, nil
+}
+
+// loadPlans 返回套餐表，超过CacheTTL时从Redis重新读取；额度变化的等级丢弃原有的限流器
+// 读取失败时如果有旧的套餐表则继续使用
+func (s *Store) loadPlans(ctx context.Context) (map[string]Plan, error) {
+	s.mu.Lock()
+	if s.plans != nil && time.Since(s.loadedAt) < s.config.CacheTTL {
+		plans := s.plans
+		s.mu.Unlock()
+		return plans, nil
+	}
+	s.mu.Unlock()
+
+	values, err := s.client.HGetAll(ctx, s.config.PlansKey).Result()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		if s.plans != nil {
+			log.Printf("Failed to reload plans, using cached plans: %v", err)
+			return s.plans, nil
+		}
+		return nil, fmt.Errorf("failed to load plans: %w", err)
+	}
+
+	plans := make(map[string]Plan, len(values))
+	for tier, value := range values {
+		var plan Plan
+		if err := json.Unmarshal([]byte(value), &plan); err != nil {
+			log.Printf("Invalid plan for tier %s: %v", tier, err)
+			continue
+		}
+		plans[tier] = plan
+		if old, exists := s.plans[tier]; exists && old != plan {
+			s.dropLimiter(tier)
+			log.Printf("Plan of tier %s changed: %.2f req/s, burst: %d", tier, plan.RatePerSecond, plan.Burst)
+		}
+	}
+	for tier := range s.limiters {
+		if _, exists := plans[tier]; !exists {
+			s.dropLimiter(tier)
+		}
+	}
+	s.plans, s.loadedAt = plans, time.Now()
+	return plans, nil
+}
+
+// dropLimiter 丢弃等级的限流器并停止它的清理协程，调用方需持有锁
+// 正在使用该限流器的请求不受影响
+func (s *Store) dropLimiter(tier string) {
+	if rl, exists := s.limiters[tier]; exists {
+		rl.Close()
+		delete(s.limiters, tier)
+	}
+}
+
+// Close 关闭各等级的限流器
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for tier := range s.limiters {
+		s.dropLimiter(tier)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"read-write-splitting/internal/config"
+	"read-write-splitting/proxy"
+
+	"redis-learning/pkg/cli"
+	sharedconfig "redis-learning/pkg/config"
+	"redis-learning/pkg/metrics"
+)
+
+// Run 运行读写分离代理的演示，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	// 演示使用同一个Redis实例作为主库和从库，可以通过配置文件或环境变量改为真实的主从部署
+	fs := cli.NewFlagSet("proxy")
+	configPath := fs.String("config", "", "path of the YAML config file")
+	metricsAddr := fs.String("metrics-addr", ":2112", "address to serve Prometheus metrics on, empty to disable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg := &config.RedisClusterConfig{
+		Master: config.RedisConfig{RedisOptions: sharedconfig.RedisOptions{Addr: "localhost:6379"}},
+		Slaves: []config.RedisConfig{
+			{RedisOptions: sharedconfig.RedisOptions{Addr: "localhost:6379"}},
+			{RedisOptions: sharedconfig.RedisOptions{Addr: "localhost:6379"}},
+		},
+		PoolSize: 10,
+	}
+	if err := sharedconfig.Load(*configPath, config.EnvPrefix, cfg); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// 初始化Redis读写分离代理，主库和从库的命令记录在同一组指标中
+	registry := metrics.NewRegistry()
+	redisProxy := proxy.NewRedisProxy(cfg, registry.RedisHook("read-write-splitting"))
+	defer redisProxy.Close()
+
+	// 启动健康检查
+	redisProxy.StartHealthCheck(10 * time.Second)
+
+	// 演示写操作 - 将路由到主库
+	fmt.Println("===== Write Operation Examples =====")
+	_, err := redisProxy.Process(ctx, "set", "user:1", "John Doe")
+	if err != nil {
+		fmt.Printf("Failed to set key: %v\n", err)
+	} else {
+		fmt.Println("Successfully set key 'user:1'")
+	}
+
+	_, err = redisProxy.Process(ctx, "set", "counter", "1")
+	if err != nil {
+		fmt.Printf("Failed to set counter: %v\n", err)
+	} else {
+		fmt.Println("Successfully set key 'counter'")
+	}
+
+	// 演示读操作 - 将路由到从库
+	fmt.Println("\n===== Read Operation Examples =====")
+	val, err := redisProxy.Process(ctx, "get", "user:1")
+	if err != nil {
+		fmt.Printf("Failed to get key: %v\n", err)
+	} else {
+		fmt.Printf("Value for 'user:1': %v\n", val)
+	}
+
+	// 演示计数器递增 - 写操作，路由到主库
+	fmt.Println("\n===== Increment Operation Examples =====")
+	_, err = redisProxy.Process(ctx, "incr", "counter")
+	if err != nil {
+		fmt.Printf("Failed to increment counter: %v\n", err)
+	} else {
+		fmt.Println("Successfully incremented 'counter'")
+	}
+
+	// 读取递增后的计数器值 - 读操作，路由到从库
+	val, err = redisProxy.Process(ctx, "get", "counter")
+	if err != nil {
+		fmt.Printf("Failed to get counter: %v\n", err)
+	} else {
+		fmt.Printf("Value for 'counter': %v\n", val)
+	}
+
+	// 演示哈希表操作
+	fmt.Println("\n===== Hash Operation Examples =====")
+	_, err = redisProxy.Process(ctx, "hset", "user:profile:1", "name", "John", "age", "30", "city", "New York")
+	if err != nil {
+		fmt.Printf("Failed to set hash: %v\n", err)
+	} else {
+		fmt.Println("Successfully set hash 'user:profile:1'")
+	}
+
+	val, err = redisProxy.Process(ctx, "hget", "user:profile:1", "name")
+	if err != nil {
+		fmt.Printf("Failed to get hash field: %v\n", err)
+	} else {
+		fmt.Printf("Name from hash: %v\n", val)
+	}
+
+	val, err = redisProxy.Process(ctx, "hgetall", "user:profile:1")
+	if err != nil {
+		fmt.Printf("Failed to get entire hash: %v\n", err)
+	} else {
+		fmt.Printf("All hash fields: %v\n", val)
+	}
+
+	// 优雅退出，等待期间通过 /metrics 输出命令的指标
+	fmt.Println("\nPress Ctrl+C to exit...")
+	if *metricsAddr != "" {
+		if err := registry.Serve(ctx, *metricsAddr); err != nil {
+			return err
+		}
+	} else {
+		<-ctx.Done()
+	}
+	fmt.Println("Shutting down Redis connections...")
+	return nil
+}
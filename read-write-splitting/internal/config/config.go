@@ -1,47 +1,76 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+
+	sharedconfig "redis-learning/pkg/config"
+	"redis-learning/pkg/redisclient"
+)
+
+// EnvPrefix 环境变量的前缀，变量名由前缀和配置项的YAML路径组成，如 RWPROXY_MASTER_ADDR、RWPROXY_POOL_SIZE
+// 从库列表只能在配置文件中设置
+const EnvPrefix = "RWPROXY"
+
 // RedisConfig 定义单个Redis实例的配置
+// 地址、密码、数据库和超时重试使用与其他模块共用的 RedisOptions，只支持standalone模式
 type RedisConfig struct {
-	Host     string
-	Port     int
-	Password string
-	DB       int
+	sharedconfig.RedisOptions `yaml:",inline"`
 }
 
 // RedisClusterConfig 定义Redis读写分离集群的配置
 type RedisClusterConfig struct {
-	Master   RedisConfig   // 主库配置
-	Slaves   []RedisConfig // 从库配置列表
-	PoolSize int           // 连接池大小
+	Master   RedisConfig   `yaml:"master"`    // 主库配置
+	Slaves   []RedisConfig `yaml:"slaves"`    // 从库配置列表
+	PoolSize int           `yaml:"pool_size"` // 连接池大小，节点没有单独设置pool_size时使用
 }
 
 // DefaultConfig 返回默认的Redis集群配置
 func DefaultConfig() *RedisClusterConfig {
 	return &RedisClusterConfig{
-		Master: RedisConfig{
-			Host:     "localhost",
-			Port:     6379,
-			Password: "",
-			DB:       0,
-		},
+		Master: newRedisConfig("localhost:6379"),
 		Slaves: []RedisConfig{
-			{
-				Host:     "localhost",
-				Port:     6380,
-				Password: "",
-				DB:       0,
-			},
-			{
-				Host:     "localhost",
-				Port:     6381,
-				Password: "",
-				DB:       0,
-			},
+			newRedisConfig("localhost:6380"),
+			newRedisConfig("localhost:6381"),
 		},
 		PoolSize: 10,
 	}
 }
 
+// newRedisConfig 创建指定地址、无密码、0号数据库的实例配置
+func newRedisConfig(addr string) RedisConfig {
+	return RedisConfig{RedisOptions: sharedconfig.RedisOptions{Addr: addr}}
+}
+
+// LoadConfig 加载配置：以 DefaultConfig 为基础，依次应用YAML配置文件和环境变量，后者优先，最后检查配置
+func LoadConfig(path string) (*RedisClusterConfig, error) {
+	cfg := DefaultConfig()
+	if err := sharedconfig.Load(path, EnvPrefix, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 检查配置是否有效，返回所有无效的配置项
+func (c *RedisClusterConfig) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Master.Addr != "", "master.addr is required")
+	check(c.Master.Mode == "" || c.Master.Mode == redisclient.ModeStandalone, "master.mode must be standalone: %q", c.Master.Mode)
+	for i, slave := range c.Slaves {
+		check(slave.Addr != "", "slaves[%d].addr is required", i)
+		check(slave.Mode == "" || slave.Mode == redisclient.ModeStandalone, "slaves[%d].mode must be standalone: %q", i, slave.Mode)
+	}
+	check(c.PoolSize >= 0, "pool_size must not be negative: %d", c.PoolSize)
+
+	return errors.Join(errs...)
+}
+
 // GetMasterAddress 获取主库地址
 func (c *RedisClusterConfig) GetMasterAddress() string {
 	return c.Master.GetAddress()
@@ -58,5 +87,5 @@ func (c *RedisClusterConfig) GetSlaveAddresses() []string {
 
 // GetAddress 获取Redis实例的地址
 func (c *RedisConfig) GetAddress() string {
-	return c.Host + ":" + string(rune(c.Port+'0'))
+	return c.Addr
 }
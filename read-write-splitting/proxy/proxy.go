@@ -5,10 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
-	"strconv"
 	"time"
 
 	"read-write-splitting/internal/config"
+
+	"redis-learning/pkg/redisclient"
 )
 
 var (
@@ -25,25 +26,23 @@ type RedisProxy struct {
 	config      *config.RedisClusterConfig // Redis集群配置
 }
 
-// NewRedisProxy 创建一个新的Redis读写分离代理
-func NewRedisProxy(cfg *config.RedisClusterConfig) *RedisProxy {
+// NewRedisProxy 创建一个新的Redis读写分离代理，hooks 添加到主库和所有从库的连接上，例如统计命令的指标
+func NewRedisProxy(cfg *config.RedisClusterConfig, hooks ...redis.Hook) *RedisProxy {
 	// 初始化主库连接
-	master := redis.NewClient(&redis.Options{
-		Addr:     cfg.GetMasterAddress(),
-		Password: cfg.Master.Password,
-		DB:       cfg.Master.DB,
-		PoolSize: cfg.PoolSize,
-	})
+	masterOptions := cfg.Master.RedisOptions
+	if masterOptions.PoolSize == 0 {
+		masterOptions.PoolSize = cfg.PoolSize
+	}
+	master := redisclient.NewClient(masterOptions, hooks...)
 
 	// 初始化从库连接列表
 	slaves := make([]*redis.Client, len(cfg.Slaves))
 	for i, slaveCfg := range cfg.Slaves {
-		slaves[i] = redis.NewClient(&redis.Options{
-			Addr:     slaveCfg.Host + ":" + strconv.Itoa(slaveCfg.Port),
-			Password: slaveCfg.Password,
-			DB:       slaveCfg.DB,
-			PoolSize: cfg.PoolSize / len(cfg.Slaves), // 将连接池均匀分配给从库
-		})
+		slaveOptions := slaveCfg.RedisOptions
+		if slaveOptions.PoolSize == 0 {
+			slaveOptions.PoolSize = cfg.PoolSize / len(cfg.Slaves) // 将连接池均匀分配给从库
+		}
+		slaves[i] = redisclient.NewClient(slaveOptions, hooks...)
 	}
 
 	// 初始化负载均衡器
@@ -173,7 +172,7 @@ func (p *RedisProxy) HealthCheck() {
 
 	for i, slave := range p.slaves {
 		// 尝试ping从库
-		_, err := slave.Ping(ctx).Result()
+		err := redisclient.Ping(ctx, slave)
 		if err == nil {
 			// 从库可用，标记为可用
 			p.balancer.MarkUp(i)
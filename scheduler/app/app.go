@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"scheduler/pkg/scheduler"
+
+	"redis-learning/pkg/cli"
+	"redis-learning/pkg/script"
+)
+
+// Run 运行调度器的演示：注册几个示例任务并参与主节点选举，ctx取消后停止调度，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("scheduler")
+	redisAddr := cli.RedisFlag(fs)
+	name := fs.String("name", "demo", "scheduler name, instances with the same name share jobs")
+	id := fs.String("id", "", "instance id, defaults to hostname-pid-random")
+	status := fs.Bool("status", false, "print job status and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	// 启动时预先加载Lua脚本包，之后的调用只发送SHA1
+	if err := script.LoadAll(ctx, client); err != nil {
+		return err
+	}
+
+	config := scheduler.DefaultConfig
+	config.InstanceID = *id
+	s := scheduler.NewScheduler(client, *name, config)
+
+	jobs := []scheduler.Job{
+		{
+			Name:     "heartbeat",
+			Schedule: "@every 5s",
+			Run: func(ctx context.Context) error {
+				log.Printf("[%s] heartbeat", s.InstanceID())
+				return nil
+			},
+		},
+		{
+			// 每分钟执行，停机期间错过的执行在恢复后补执行一次
+			Name:      "report",
+			Schedule:  "* * * * *",
+			RunMissed: true,
+			Run: func(ctx context.Context) error {
+				log.Printf("[%s] generating report", s.InstanceID())
+				return nil
+			},
+		},
+		{
+			// 执行时间超过间隔，上一次没有结束时跳过这一次
+			Name:     "slow",
+			Schedule: "@every 10s",
+			Run: func(ctx context.Context) error {
+				log.Printf("[%s] slow job started", s.InstanceID())
+				select {
+				case <-time.After(15 * time.Second):
+					log.Printf("[%s] slow job finished", s.InstanceID())
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		},
+	}
+	for _, job := range jobs {
+		if err := s.Register(job); err != nil {
+			return fmt.Errorf("failed to register job: %w", err)
+		}
+	}
+
+	if *status {
+		return printStatus(ctx, s)
+	}
+
+	s.Start()
+	log.Printf("Scheduler instance %s started", s.InstanceID())
+
+	// 等待中断信号后停止调度，其他实例会接管主节点
+	<-ctx.Done()
+	log.Println("Stopping scheduler...")
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), cli.ShutdownTimeout)
+	defer cancel()
+	if err := s.Stop(stopCtx); err != nil {
+		log.Printf("Scheduler stopped with error: %v", err)
+	}
+	log.Println("Scheduler stopped")
+	return nil
+}
+
+// printStatus 打印主节点和任务的执行记录
+func printStatus(ctx context.Context, s *scheduler.Scheduler) error {
+	leader, err := s.Leader(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get leader: %w", err)
+	}
+	statuses, err := s.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get job status: %w", err)
+	}
+	fmt.Printf("leader: %q\n", leader)
+	for _, status := range statuses {
+		data, _ := json.MarshalIndent(status, "", "  ")
+		fmt.Println(string(data))
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"scheduler/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("scheduler", app.Run)
+}
@@ -0,0 +1,552 @@
+package scheduler
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrDuplicateJob 同名任务已经注册
+	ErrDuplicateJob = errors.New("scheduler: duplicate job")
+	// ErrStarted 调度器已经启动，不能再注册任务
+	ErrStarted = errors.New("scheduler: already started")
+)
+
+// maxMissedCount 统计错过的执行次数的上限，避免长时间停机后逐个计算执行时间
+const maxMissedCount = 10000
+
+// Config 调度器配置
+type Config struct {
+	// Redis中各个key的前缀，完整的前缀为 KeyPrefix + "{调度器名称}:"
+	KeyPrefix string
+	// 主节点租约的有效期，主节点崩溃后最多经过这么长时间由其他实例接管
+	LeaseTTL time.Duration
+	// 检查到期任务、续约或竞争租约的间隔，决定了任务执行时间的精度
+	PollInterval time.Duration
+	// 执行时间已经过去超过该时长的任务视为错过，按任务的 RunMissed 决定是否补执行
+	MisfireThreshold time.Duration
+	// 计算执行时间使用的时区
+	Location *time.Location
+	// 实例标识，为空时使用 主机名-进程号-随机数
+	InstanceID string
+}
+
+// DefaultConfig 默认调度器配置
+var DefaultConfig = Config{
+	KeyPrefix:        "sched:",
+	LeaseTTL:         15 * time.Second,
+	PollInterval:     time.Second,
+	MisfireThreshold: time.Minute,
+	Location:         time.Local,
+}
+
+// Job 定时任务
+type Job struct {
+	// 任务名称，同一个调度器中唯一
+	Name string
+	// 执行计划，格式见 ParseSchedule
+	Schedule string
+	// 任务函数，失去主节点租约或 Stop 等待超时时ctx被取消
+	Run func(ctx context.Context) error
+	// 单次执行的超时时间，0表示不限制
+	Timeout time.Duration
+	// 错过执行时间（调度器停机、没有主节点、上一次执行还没有结束）后是否补执行一次
+	// 多次错过只补执行一次；为false时只记录错过的次数，等待下一个执行时间
+	RunMissed bool
+}
+
+// JobStatus 任务在Redis中的执行记录
+type JobStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	NextRun  time.Time `json:"next_run"`
+	// 最近一次执行对应的计划时间和实际开始、结束时间
+	LastScheduled time.Time     `json:"last_scheduled,omitempty"`
+	LastStart     time.Time     `json:"last_start,omitempty"`
+	LastFinish    time.Time     `json:"last_finish,omitempty"`
+	LastDuration  time.Duration `json:"last_duration"`
+	LastError     string        `json:"last_error,omitempty"`
+	// 最近一次执行的实例
+	LastInstance string `json:"last_instance,omitempty"`
+	Runs         int64  `json:"runs"`
+	Failures     int64  `json:"failures"`
+	// 错过、没有执行的次数
+	Missed int64 `json:"missed"`
+}
+
+// acquireLeaseScript 获取或续约主节点租约
+// KEYS: 租约key
+// ARGV: 实例标识, 租约有效期（毫秒）
+// 返回1表示持有租约
+var acquireLeaseScript = scripts.Register("acquire-lease", `
+local holder = redis.call('GET', KEYS[1])
+if holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if not holder then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseLeaseScript 释放自己持有的租约
+// KEYS: 租约key
+// ARGV: 实例标识
+var releaseLeaseScript = scripts.Register("release-lease", `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// claimTickScript 认领一次执行：确认仍然持有租约，并把任务的下一次执行时间从expected改为next
+// 只有一个实例能认领成功，租约在两次检查之间易主时也不会重复执行
+// KEYS: 租约key, 任务记录
+// ARGV: 实例标识, 当前的下一次执行时间（毫秒，空字符串表示没有记录）, 新的下一次执行时间（毫秒）
+// 返回1表示认领成功，0表示已被其他实例认领，-1表示已经失去租约
+var claimTickScript = scripts.Register("claim-tick", `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return -1
+end
+if (redis.call('HGET', KEYS[2], 'next_run') or '') ~= ARGV[2] then
+	return 0
+end
+redis.call('HSET', KEYS[2], 'next_run', ARGV[3])
+return 1
+`)
+
+// job 注册的任务
+type job struct {
+	Job
+	schedule Schedule
+	// 正在执行
+	running bool
+}
+
+// Scheduler 分布式定时任务调度器
+//
+// 多个实例注册相同的任务，通过Redis租约选出一个主节点，只有主节点执行任务。
+// 每个任务的下一次执行时间保存在Redis中，主节点用Lua脚本比较并修改它来认领一次执行，
+// 主节点切换后新主节点从Redis中的记录继续，不会重复执行，也能发现停机期间错过的执行
+type Scheduler struct {
+	client redis.UniversalClient
+	config Config
+	prefix string
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	started bool
+	leader  bool
+	// 主节点期间执行的任务使用的ctx，失去租约时取消
+	leaderCtx    context.Context
+	cancelLeader context.CancelFunc
+
+	running sync.WaitGroup
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewScheduler 创建名为name的调度器，同名的调度器实例共同竞争主节点
+func NewScheduler(client redis.UniversalClient, name string, config Config) *Scheduler {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.InstanceID == "" {
+		config.InstanceID = defaultInstanceID()
+	}
+	return &Scheduler{
+		client: client,
+		config: config,
+		prefix: config.KeyPrefix + "{" + name + "}:",
+		jobs:   make(map[string]*job),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// NewDefaultScheduler 使用默认配置创建调度器
+func NewDefaultScheduler(client redis.UniversalClient, name string) *Scheduler {
+	return NewScheduler(client, name, DefaultConfig)
+}
+
+// leaseKey 主节点租约
+func (s *Scheduler) leaseKey() string {
+	return s.prefix + "leader"
+}
+
+// jobKey 任务的执行记录
+func (s *Scheduler) jobKey(name string) string {
+	return s.prefix + "job:" + name
+}
+
+// InstanceID 返回实例标识
+func (s *Scheduler) InstanceID() string {
+	return s.config.InstanceID
+}
+
+// Register 注册任务，必须在 Start 之前调用
+func (s *Scheduler) Register(j Job) error {
+	if j.Name == "" || j.Run == nil {
+		return fmt.Errorf("scheduler: job name and run function are required")
+	}
+	schedule, err := ParseSchedule(j.Schedule)
+	if err != nil {
+		return err
+	}
+	if schedule.Next(time.Now().In(s.config.Location)).IsZero() {
+		return fmt.Errorf("scheduler: schedule %q of job %s never fires", j.Schedule, j.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return ErrStarted
+	}
+	if _, ok := s.jobs[j.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, j.Name)
+	}
+	s.jobs[j.Name] = &job{Job: j, schedule: schedule}
+	return nil
+}
+
+// Start 在后台开始竞争主节点并调度任务
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	go s.loop()
+}
+
+// Stop 停止调度，等待正在执行的任务结束，然后释放主节点租约
+// ctx结束时取消正在执行的任务，不再等待
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	select {
+	case <-s.stop:
+		s.mu.Unlock()
+		return nil
+	default:
+	}
+	close(s.stop)
+	s.mu.Unlock()
+	<-s.done
+
+	finished := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(finished)
+	}()
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// 即使等待任务超时也要释放租约，让其他实例尽快接管，不必等租约过期
+	s.setLeader(false)
+	if releaseErr := releaseLeaseScript.Run(context.WithoutCancel(ctx), s.client, []string{s.leaseKey()}, s.config.InstanceID).Err(); releaseErr != nil {
+		err = errors.Join(err, releaseErr)
+	}
+	return err
+}
+
+// IsLeader 当前实例是否是主节点
+func (s *Scheduler) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// Leader 返回当前主节点的实例标识，没有主节点时返回空字符串
+func (s *Scheduler) Leader(ctx context.Context) (string, error) {
+	leader, err := s.client.Get(ctx, s.leaseKey()).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return leader, err
+}
+
+// loop 每个 PollInterval 竞争或续约租约，主节点检查到期的任务
+func (s *Scheduler) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.poll()
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll 执行一次调度
+func (s *Scheduler) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.PollInterval)
+	defer cancel()
+
+	held, err := acquireLeaseScript.Run(ctx, s.client, []string{s.leaseKey()},
+		s.config.InstanceID, s.config.LeaseTTL.Milliseconds()).Int()
+	if err != nil {
+		// 无法确认租约，停止执行新任务；租约在Redis中过期后其他实例会接管
+		log.Printf("Error renewing scheduler lease: %v", err)
+		s.setLeader(false)
+		return
+	}
+	s.setLeader(held == 1)
+	if held != 1 {
+		return
+	}
+
+	now := time.Now().In(s.config.Location)
+	for _, j := range s.jobList() {
+		if err := s.schedule(ctx, j, now); err != nil {
+			log.Printf("Error scheduling job %s: %v", j.Name, err)
+		}
+	}
+}
+
+// setLeader 更新主节点状态，失去主节点时取消正在执行的任务
+func (s *Scheduler) setLeader(leader bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if leader == s.leader {
+		return
+	}
+	s.leader = leader
+	if leader {
+		log.Printf("Scheduler instance %s became leader", s.config.InstanceID)
+		s.leaderCtx, s.cancelLeader = context.WithCancel(context.Background())
+		return
+	}
+	log.Printf("Scheduler instance %s is no longer leader", s.config.InstanceID)
+	s.cancelLeader()
+}
+
+// jobList 返回所有注册的任务
+func (s *Scheduler) jobList() []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
+
+// schedule 检查任务是否到期，到期时认领这次执行
+func (s *Scheduler) schedule(ctx context.Context, j *job, now time.Time) error {
+	key := s.jobKey(j.Name)
+	nextMs, err := s.client.HGet(ctx, key, "next_run").Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	if nextMs == "" {
+		// 第一次调度，只记录下一次执行时间
+		next := j.schedule.Next(now)
+		_, err := s.claim(ctx, key, "", next)
+		return err
+	}
+
+	ms, err := strconv.ParseInt(nextMs, 10, 64)
+	if err != nil {
+		return err
+	}
+	due := time.UnixMilli(ms).In(s.config.Location)
+	if now.Before(due) {
+		return nil
+	}
+
+	// 计算due之后、现在之前还有多少次执行时间，这些执行合并为一次
+	latest, missed := due, int64(0)
+	for t := j.schedule.Next(due); !t.IsZero() && !t.After(now) && missed < maxMissedCount; t = j.schedule.Next(t) {
+		latest = t
+		missed++
+	}
+
+	ok, err := s.claim(ctx, key, nextMs, j.schedule.Next(now))
+	if err != nil || !ok {
+		return err
+	}
+
+	late := now.Sub(latest) > s.config.MisfireThreshold
+	s.mu.Lock()
+	busy := j.running
+	runCtx := s.leaderCtx
+	if !busy && (!late || j.RunMissed) {
+		j.running = true
+		s.running.Add(1)
+	}
+	s.mu.Unlock()
+
+	switch {
+	case busy:
+		// 上一次执行还没有结束，跳过这一次
+		log.Printf("Job %s skipped: previous run still in progress", j.Name)
+		missed++
+	case late && !j.RunMissed:
+		log.Printf("Job %s missed %d run(s), next run at %s", j.Name, missed+1, j.schedule.Next(now).Format(time.RFC3339))
+		missed++
+	default:
+		if missed > 0 || late {
+			log.Printf("Job %s is %s behind schedule, running once to catch up (%d run(s) skipped)",
+				j.Name, now.Sub(latest).Round(time.Second), missed)
+		}
+		go s.run(runCtx, j, latest)
+	}
+	if missed > 0 {
+		return s.client.HIncrBy(ctx, key, "missed", missed).Err()
+	}
+	return nil
+}
+
+// claim 把任务的下一次执行时间从expected改为next，返回是否认领成功
+func (s *Scheduler) claim(ctx context.Context, key, expected string, next time.Time) (bool, error) {
+	result, err := claimTickScript.Run(ctx, s.client, []string{s.leaseKey(), key},
+		s.config.InstanceID, expected, next.UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	if result < 0 {
+		s.setLeader(false)
+	}
+	return result == 1, nil
+}
+
+// run 执行任务并记录结果
+func (s *Scheduler) run(ctx context.Context, j *job, scheduled time.Time) {
+	defer func() {
+		s.mu.Lock()
+		j.running = false
+		s.mu.Unlock()
+		s.running.Done()
+	}()
+
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := runJob(ctx, j)
+	finish := time.Now()
+
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+		log.Printf("Job %s failed: %v", j.Name, err)
+	}
+	// 任务的ctx可能已经取消，记录结果使用独立的ctx
+	recordCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, recordErr := s.client.TxPipelined(recordCtx, func(pipe redis.Pipeliner) error {
+		key := s.jobKey(j.Name)
+		pipe.HSet(recordCtx, key,
+			"last_scheduled", scheduled.UnixMilli(),
+			"last_start", start.UnixMilli(),
+			"last_finish", finish.UnixMilli(),
+			"last_duration_ms", finish.Sub(start).Milliseconds(),
+			"last_error", lastError,
+			"last_instance", s.config.InstanceID,
+		)
+		pipe.HIncrBy(recordCtx, key, "runs", 1)
+		if err != nil {
+			pipe.HIncrBy(recordCtx, key, "failures", 1)
+		}
+		return nil
+	})
+	if recordErr != nil {
+		log.Printf("Error recording job %s result: %v", j.Name, recordErr)
+	}
+}
+
+// runJob 执行任务函数，把panic转换为错误
+func runJob(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.Run(ctx)
+}
+
+// Status 返回所有注册的任务在Redis中的执行记录
+func (s *Scheduler) Status(ctx context.Context) ([]JobStatus, error) {
+	jobs := s.jobList()
+	cmds := make([]*redis.MapStringStringCmd, len(jobs))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, j := range jobs {
+			cmds[i] = pipe.HGetAll(ctx, s.jobKey(j.Name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]JobStatus, len(jobs))
+	for i, j := range jobs {
+		record := cmds[i].Val()
+		statuses[i] = JobStatus{
+			Name:          j.Name,
+			Schedule:      j.Schedule,
+			NextRun:       parseMillis(record["next_run"]),
+			LastScheduled: parseMillis(record["last_scheduled"]),
+			LastStart:     parseMillis(record["last_start"]),
+			LastFinish:    parseMillis(record["last_finish"]),
+			LastDuration:  time.Duration(parseInt(record["last_duration_ms"])) * time.Millisecond,
+			LastError:     record["last_error"],
+			LastInstance:  record["last_instance"],
+			Runs:          parseInt(record["runs"]),
+			Failures:      parseInt(record["failures"]),
+			Missed:        parseInt(record["missed"]),
+		}
+	}
+	return statuses, nil
+}
+
+// parseMillis 把毫秒时间戳转换为时间，为空时返回零值
+func parseMillis(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	return time.UnixMilli(parseInt(s))
+}
+
+// parseInt 解析整数，格式错误时返回0
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// defaultInstanceID 返回 主机名-进程号-随机数 作为实例标识
+func defaultInstanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	crand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
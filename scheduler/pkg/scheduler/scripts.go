@@ -0,0 +1,6 @@
+package scheduler
+
+import "redis-learning/pkg/script"
+
+// scripts 调度器的租约和触发使用的Lua脚本，以 EVALSHA 执行，Redis中没有脚本时自动加载
+var scripts = script.NewBundle("scheduler")
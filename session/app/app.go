@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"session/internal/handlers"
+	"session/pkg/session"
+
+	"redis-learning/pkg/cli"
+)
+
+// Run 运行会话服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	fs := cli.NewFlagSet("session")
+	addr := cli.AddrFlag(fs)
+	redisAddr := cli.RedisFlag(fs)
+	codec := fs.String("codec", "json", "session encoding: json or msgpack")
+	ttl := fs.Duration("ttl", session.DefaultConfig.TTL, "session idle timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cli.Connect(ctx, *redisAddr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	config := session.DefaultConfig
+	config.TTL = *ttl
+	switch *codec {
+	case "json":
+		config.Codec = session.JSONCodec
+	case "msgpack":
+		config.Codec = session.MsgpackCodec
+	default:
+		return fmt.Errorf("unknown codec: %s", *codec)
+	}
+	store := session.NewStore(client, config)
+
+	// 初始化Gin路由器
+	router := gin.Default()
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+	router.Use(session.Middleware(store, session.DefaultCookieOptions))
+	handlers.NewSessionHandler(*addr).Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	return cli.ServeHTTP(ctx, &http.Server{
+		Addr:    *addr,
+		Handler: router,
+	})
+}
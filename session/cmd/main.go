@@ -0,0 +1,11 @@
+package main
+
+import (
+	"session/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("session", app.Run)
+}
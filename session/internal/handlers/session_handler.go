@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"session/pkg/session"
+)
+
+// SessionHandler 演示登录、访问和退出，登录状态全部保存在Redis会话中
+type SessionHandler struct {
+	// 当前实例的名称，用于展示同一会话可以由不同实例处理
+	instance string
+}
+
+// NewSessionHandler 创建一个新的会话处理器
+func NewSessionHandler(instance string) *SessionHandler {
+	return &SessionHandler{instance: instance}
+}
+
+// Setup 设置所有路由，router需要已经使用 session.Middleware
+func (h *SessionHandler) Setup(router *gin.Engine) {
+	api := router.Group("/session")
+	{
+		// 登录
+		api.POST("/login", h.Login)
+		// 当前会话
+		api.GET("/me", h.Me)
+		// 退出
+		api.POST("/logout", h.Logout)
+	}
+}
+
+// Login 登录：更换会话ID，并在会话中记录用户
+func (h *SessionHandler) Login(c *gin.Context) {
+	var req struct {
+		User string `json:"user" form:"user" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	s := session.Default(c)
+	s.Set("user", req.User)
+	s.Set("login_at", time.Now().Unix())
+	s.Set("visits", 0)
+	// 登录后更换会话ID，防止会话固定攻击
+	if err := session.Regenerate(c); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": req.User, "instance": h.instance})
+}
+
+// Me 返回当前登录的用户，并记录访问次数
+func (h *SessionHandler) Me(c *gin.Context) {
+	s := session.Default(c)
+	user, ok := s.GetString("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not logged in"})
+		return
+	}
+
+	visits, _ := s.GetInt64("visits")
+	visits++
+	s.Set("visits", visits)
+	err := session.Save(c)
+	if errors.Is(err, session.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session: " + err.Error()})
+		return
+	}
+
+	loginAt, _ := s.GetInt64("login_at")
+	c.JSON(http.StatusOK, gin.H{
+		"user":       user,
+		"visits":     visits,
+		"login_at":   time.Unix(loginAt, 0),
+		"created_at": s.CreatedAt,
+		"instance":   h.instance,
+	})
+}
+
+// Logout 退出：删除会话并清除cookie
+func (h *SessionHandler) Logout(c *gin.Context) {
+	if err := session.Destroy(c); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to destroy session: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
@@ -0,0 +1,40 @@
+package session
+
+import (
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec 会话数据的编码方式
+type Codec interface {
+	// Name 编码方式的名称
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSONCodec 使用JSON编码，Redis中的数据便于直接查看；
+	// 解码后数字统一为float64，嵌套对象为map[string]any
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec 使用msgpack编码，数据更小、编解码更快；
+	// 解码后整数仍为整数类型（如int8、uint16、int64，按数值大小选择）
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) { return msgpack.Marshal(v) }
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
@@ -0,0 +1,136 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginKey 会话在gin.Context中保存的key
+const ginKey = "session"
+
+// contextKey 会话在context.Context中保存的key
+type contextKey struct{}
+
+// CookieOptions 保存会话ID的cookie
+type CookieOptions struct {
+	Name   string
+	Path   string
+	Domain string
+	Secure bool
+	// 禁止JavaScript读取cookie，防止会话ID被XSS窃取
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// DefaultCookieOptions 默认cookie配置
+// 不设置MaxAge，cookie在浏览器关闭时失效，会话在服务端按 Config.TTL 过期
+var DefaultCookieOptions = CookieOptions{
+	Name:     "session_id",
+	Path:     "/",
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// state 一次请求中的会话状态
+type state struct {
+	store   *Store
+	options CookieOptions
+	session *Session
+}
+
+// Middleware 返回会话中间件
+// 请求携带有效的会话cookie时从Redis读取会话（同时重新开始空闲超时计时），否则创建一个新会话；
+// 会话保存在gin.Context和请求的context.Context中，处理函数通过 Default 或 FromContext 获取。
+// 修改会话后需要调用 Save 才会写入Redis并设置cookie，没有保存的新会话不会占用Redis
+func Middleware(store *Store, options CookieOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var s *Session
+		if id, err := c.Cookie(options.Name); err == nil && id != "" {
+			loaded, err := store.Get(c.Request.Context(), id)
+			switch {
+			case err == nil:
+				s = loaded
+			case errors.Is(err, ErrNotFound):
+				// 会话已经过期或ID无效，使用新会话
+			default:
+				log.Printf("Error loading session: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load session: " + err.Error()})
+				return
+			}
+		}
+		if s == nil {
+			s = store.New()
+		}
+
+		st := &state{store: store, options: options, session: s}
+		c.Set(ginKey, st)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, st))
+		c.Next()
+	}
+}
+
+// Default 返回当前请求的会话，没有使用 Middleware 时panic
+func Default(c *gin.Context) *Session {
+	return mustState(c).session
+}
+
+// FromContext 从请求的context.Context中取出会话，供不依赖gin的代码使用
+func FromContext(ctx context.Context) (*Session, bool) {
+	st, ok := ctx.Value(contextKey{}).(*state)
+	if !ok {
+		return nil, false
+	}
+	return st.session, true
+}
+
+// Save 把当前请求的会话保存到Redis并设置cookie，必须在写入响应之前调用
+func Save(c *gin.Context) error {
+	st := mustState(c)
+	if err := st.store.Save(c.Request.Context(), st.session); err != nil {
+		return err
+	}
+	st.setCookie(c, st.session.ID, 0)
+	return nil
+}
+
+// Regenerate 为当前请求的会话更换ID并保存，登录等权限变化时调用，必须在写入响应之前调用
+func Regenerate(c *gin.Context) error {
+	st := mustState(c)
+	if err := st.store.Regenerate(c.Request.Context(), st.session); err != nil {
+		return err
+	}
+	st.setCookie(c, st.session.ID, 0)
+	return nil
+}
+
+// Destroy 删除当前请求的会话并清除cookie，之后 Default 返回一个新的空会话
+func Destroy(c *gin.Context) error {
+	st := mustState(c)
+	if !st.session.IsNew() {
+		if err := st.store.Destroy(c.Request.Context(), st.session.ID); err != nil {
+			return err
+		}
+	}
+	st.setCookie(c, "", -1)
+	st.session = st.store.New()
+	return nil
+}
+
+// setCookie 设置会话cookie，maxAge小于0时删除cookie
+func (st *state) setCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(st.options.SameSite)
+	c.SetCookie(st.options.Name, value, maxAge, st.options.Path, st.options.Domain, st.options.Secure, st.options.HttpOnly)
+}
+
+// mustState 返回当前请求的会话状态
+func mustState(c *gin.Context) *state {
+	st, ok := c.MustGet(ginKey).(*state)
+	if !ok {
+		panic("session: middleware not installed")
+	}
+	return st
+}
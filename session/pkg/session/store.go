@@ -0,0 +1,266 @@
+package session
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// idBytes 会话ID的随机字节数，256位随机数无法被猜测
+const idBytes = 32
+
+// ErrNotFound 会话不存在、已经过期或ID格式错误
+var ErrNotFound = errors.New("session: not found")
+
+// Config 会话存储配置
+type Config struct {
+	// Redis中会话key的前缀，完整的key为 KeyPrefix + 会话ID
+	KeyPrefix string
+	// 空闲超时：会话在TTL内没有被访问就会过期，每次读取或保存都会重新计时（滑动过期）
+	TTL time.Duration
+	// 最长有效期：从创建开始计算，无论是否活跃，超过后会话都会过期，0表示不限制
+	MaxLifetime time.Duration
+	// 会话数据的编码方式
+	Codec Codec
+}
+
+// DefaultConfig 默认会话存储配置
+var DefaultConfig = Config{
+	KeyPrefix:   "sess:",
+	TTL:         30 * time.Minute,
+	MaxLifetime: 24 * time.Hour,
+	Codec:       JSONCodec,
+}
+
+// Session 一个会话
+// 会话数据整体编码后保存在一个key中，同一会话的并发请求各自保存时，后保存的会覆盖先保存的
+type Session struct {
+	ID        string
+	CreatedAt time.Time
+	Values    map[string]any
+	// 还没有保存到Redis
+	isNew bool
+}
+
+// record 会话在Redis中保存的内容
+type record struct {
+	CreatedAt int64          `json:"created_at" msgpack:"created_at"`
+	Values    map[string]any `json:"values" msgpack:"values"`
+}
+
+// Get 返回会话中key对应的值
+func (s *Session) Get(key string) (any, bool) {
+	value, ok := s.Values[key]
+	return value, ok
+}
+
+// GetString 返回会话中key对应的字符串
+func (s *Session) GetString(key string) (string, bool) {
+	value, ok := s.Values[key].(string)
+	return value, ok
+}
+
+// GetInt64 返回会话中key对应的整数
+// JSON解码后数字为float64，msgpack解码后整数类型随数值大小变化，这里统一转换为int64
+func (s *Session) GetInt64(key string) (int64, bool) {
+	switch value := s.Values[key].(type) {
+	case int:
+		return int64(value), true
+	case int8:
+		return int64(value), true
+	case int16:
+		return int64(value), true
+	case int32:
+		return int64(value), true
+	case int64:
+		return value, true
+	case uint8:
+		return int64(value), true
+	case uint16:
+		return int64(value), true
+	case uint32:
+		return int64(value), true
+	case uint64:
+		return int64(value), true
+	case float64:
+		return int64(value), true
+	}
+	return 0, false
+}
+
+// Set 设置会话中key的值，需要调用 Store.Save 才会保存到Redis
+func (s *Session) Set(key string, value any) {
+	s.Values[key] = value
+}
+
+// Delete 删除会话中的key，需要调用 Store.Save 才会保存到Redis
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+}
+
+// IsNew 会话是否还没有保存到Redis
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// Store 基于Redis的会话存储
+//
+// 会话状态全部保存在Redis中，Web服务本身是无状态的：任意实例都可以处理同一会话的请求，
+// 实例可以随时扩缩容或重启而不会丢失登录状态
+type Store struct {
+	client redis.UniversalClient
+	config Config
+}
+
+// NewStore 创建会话存储
+func NewStore(client redis.UniversalClient, config Config) *Store {
+	return &Store{client: client, config: config}
+}
+
+// NewDefaultStore 使用默认配置创建会话存储
+func NewDefaultStore(client redis.UniversalClient) *Store {
+	return NewStore(client, DefaultConfig)
+}
+
+// New 创建一个新会话，在调用 Save 之前不会写入Redis
+func (st *Store) New() *Session {
+	return &Session{
+		ID:        newID(),
+		CreatedAt: time.Now(),
+		Values:    make(map[string]any),
+		isNew:     true,
+	}
+}
+
+// Get 读取会话，并重新开始空闲超时计时；会话不存在或已经过期时返回 ErrNotFound
+func (st *Store) Get(ctx context.Context, id string) (*Session, error) {
+	if !validID(id) {
+		// 格式错误的ID不可能存在，不需要查询Redis
+		return nil, ErrNotFound
+	}
+
+	// GETEX在读取的同时重置过期时间，一次往返完成读取和滑动过期
+	data, err := st.client.GetEx(ctx, st.key(id), st.config.TTL).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec record
+	if err := st.config.Codec.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	s := &Session{
+		ID:        id,
+		CreatedAt: time.UnixMilli(rec.CreatedAt),
+		Values:    rec.Values,
+	}
+	if s.Values == nil {
+		s.Values = make(map[string]any)
+	}
+
+	// GETEX按空闲超时续期，快到最长有效期时要缩短过期时间
+	ttl := st.ttl(s)
+	if ttl <= 0 {
+		st.client.Del(ctx, st.key(id))
+		return nil, ErrNotFound
+	}
+	if ttl < st.config.TTL {
+		if err := st.client.PExpire(ctx, st.key(id), ttl).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Save 保存会话，并重新开始空闲超时计时；会话已经超过最长有效期时删除会话并返回 ErrNotFound
+func (st *Store) Save(ctx context.Context, s *Session) error {
+	ttl := st.ttl(s)
+	if ttl <= 0 {
+		st.client.Del(ctx, st.key(s.ID))
+		return ErrNotFound
+	}
+	data, err := st.config.Codec.Marshal(record{CreatedAt: s.CreatedAt.UnixMilli(), Values: s.Values})
+	if err != nil {
+		return err
+	}
+	if err := st.client.Set(ctx, st.key(s.ID), data, ttl).Err(); err != nil {
+		return err
+	}
+	s.isNew = false
+	return nil
+}
+
+// Refresh 不读取会话数据，只重新开始空闲超时计时；会话不存在时返回 ErrNotFound
+func (st *Store) Refresh(ctx context.Context, s *Session) error {
+	ttl := st.ttl(s)
+	if ttl <= 0 {
+		st.client.Del(ctx, st.key(s.ID))
+		return ErrNotFound
+	}
+	ok, err := st.client.PExpire(ctx, st.key(s.ID), ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Destroy 删除会话
+func (st *Store) Destroy(ctx context.Context, id string) error {
+	return st.client.Del(ctx, st.key(id)).Err()
+}
+
+// Regenerate 为会话更换新的ID并保存，删除旧ID对应的数据
+// 登录等权限变化时应该更换会话ID，防止攻击者预先设置的会话ID在用户登录后被使用（会话固定攻击）
+func (st *Store) Regenerate(ctx context.Context, s *Session) error {
+	oldID, wasNew := s.ID, s.isNew
+	s.ID = newID()
+	if err := st.Save(ctx, s); err != nil {
+		s.ID = oldID
+		return err
+	}
+	if wasNew {
+		return nil
+	}
+	return st.Destroy(ctx, oldID)
+}
+
+// ttl 返回会话的过期时间：空闲超时和剩余有效期中较短的一个
+func (st *Store) ttl(s *Session) time.Duration {
+	if st.config.MaxLifetime <= 0 {
+		return st.config.TTL
+	}
+	return min(st.config.TTL, time.Until(s.CreatedAt.Add(st.config.MaxLifetime)))
+}
+
+// key 返回会话在Redis中的key
+func (st *Store) key(id string) string {
+	return st.config.KeyPrefix + id
+}
+
+// newID 生成随机的会话ID
+func newID() string {
+	b := make([]byte, idBytes)
+	if _, err := crand.Read(b); err != nil {
+		panic("session: failed to generate id: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// validID 检查会话ID的格式
+func validID(id string) bool {
+	if len(id) != base64.RawURLEncoding.EncodedLen(idBytes) {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(id)
+	return err == nil
+}
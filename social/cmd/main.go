@@ -0,0 +1,11 @@
+package main
+
+import (
+	"social/app"
+
+	"redis-learning/pkg/cli"
+)
+
+func main() {
+	cli.Main("social", app.Run)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"social/pkg/social"
+)
+
+// maxBatchIDs 批量查询接口一次最多查询的ID数
+const maxBatchIDs = 100
+
+// SocialHandler 处理点赞和关注相关的HTTP请求
+type SocialHandler struct {
+	service *social.Service
+}
+
+// NewSocialHandler 创建一个新的社交计数处理器
+func NewSocialHandler(service *social.Service) *SocialHandler {
+	return &SocialHandler{service: service}
+}
+
+// Setup 设置所有路由
+func (h *SocialHandler) Setup(router *gin.Engine) {
+	likes := router.Group("/likes")
+	{
+		// 批量查询点赞数
+		likes.GET("/counts", h.GetLikeCounts)
+		// 批量查询用户是否点过赞
+		likes.GET("/status", h.GetLikeStatus)
+		// 点赞
+		likes.POST("/:target", h.Like)
+		// 取消点赞
+		likes.DELETE("/:target", h.Unlike)
+		// 点赞的用户
+		likes.GET("/:target/users", h.GetLikers)
+	}
+
+	users := router.Group("/users")
+	{
+		// 批量查询关注数和粉丝数
+		users.GET("/counts", h.GetFollowCounts)
+		// 关注
+		users.POST("/:id/following", h.Follow)
+		// 取消关注
+		users.DELETE("/:id/following/:target", h.Unfollow)
+		// 关注的人
+		users.GET("/:id/following", h.GetFollowing)
+		// 粉丝
+		users.GET("/:id/followers", h.GetFollowers)
+		// 互相关注的人
+		users.GET("/:id/mutual", h.GetMutualFollows)
+		// 两个用户之间的关注关系
+		users.GET("/:id/relation/:other", h.GetRelation)
+		// 两个用户共同关注的人
+		users.GET("/:id/common/:other", h.GetCommonFollowing)
+	}
+}
+
+// Like 点赞，参数user_id
+func (h *SocialHandler) Like(c *gin.Context) {
+	h.like(c, h.service.Like, "like")
+}
+
+// Unlike 取消点赞，参数user_id
+func (h *SocialHandler) Unlike(c *gin.Context) {
+	h.like(c, h.service.Unlike, "unlike")
+}
+
+// like 执行点赞或取消点赞，返回本次是否改变了状态和当前的点赞数
+func (h *SocialHandler) like(c *gin.Context, op func(ctx context.Context, userID, target string) (bool, int64, error), action string) {
+	var req struct {
+		UserID string `json:"user_id" form:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	target := c.Param("target")
+	changed, count, err := op(c.Request.Context(), req.UserID, target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + ": " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"target": target, "changed": changed, "count": count})
+}
+
+// GetLikeCounts 批量查询点赞数，参数targets为逗号分隔的点赞对象
+func (h *SocialHandler) GetLikeCounts(c *gin.Context) {
+	targets, ok := idList(c, "targets")
+	if !ok {
+		return
+	}
+	counts, err := h.service.LikeCounts(c.Request.Context(), targets...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get like counts: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"counts": counts})
+}
+
+// GetLikeStatus 批量查询用户是否点过赞，参数user_id和逗号分隔的targets
+func (h *SocialHandler) GetLikeStatus(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter user_id is required"})
+		return
+	}
+	targets, ok := idList(c, "targets")
+	if !ok {
+		return
+	}
+	liked, err := h.service.HasLiked(c.Request.Context(), userID, targets...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get like status: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "liked": liked})
+}
+
+// GetLikers 分页查询点赞的用户，参数cursor（默认0）、count（默认20）
+func (h *SocialHandler) GetLikers(c *gin.Context) {
+	h.page(c, h.service.Likers, c.Param("target"), "likers")
+}
+
+// Follow 关注，参数target为被关注的用户
+func (h *SocialHandler) Follow(c *gin.Context) {
+	var req struct {
+		Target string `json:"target" form:"target" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters: " + err.Error()})
+		return
+	}
+
+	changed, err := h.service.Follow(c.Request.Context(), c.Param("id"), req.Target)
+	if errors.Is(err, social.ErrSelfFollow) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot follow yourself"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changed": changed})
+}
+
+// Unfollow 取消关注
+func (h *SocialHandler) Unfollow(c *gin.Context) {
+	changed, err := h.service.Unfollow(c.Request.Context(), c.Param("id"), c.Param("target"))
+	if errors.Is(err, social.ErrSelfFollow) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot unfollow yourself"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changed": changed})
+}
+
+// GetFollowing 分页查询关注的人，参数cursor（默认0）、count（默认20）
+func (h *SocialHandler) GetFollowing(c *gin.Context) {
+	h.page(c, h.service.Following, c.Param("id"), "following")
+}
+
+// GetFollowers 分页查询粉丝，参数cursor（默认0）、count（默认20）
+func (h *SocialHandler) GetFollowers(c *gin.Context) {
+	h.page(c, h.service.Followers, c.Param("id"), "followers")
+}
+
+// GetMutualFollows 查询互相关注的人
+func (h *SocialHandler) GetMutualFollows(c *gin.Context) {
+	users, err := h.service.MutualFollows(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get mutual follows: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// GetRelation 查询两个用户之间的关注关系
+func (h *SocialHandler) GetRelation(c *gin.Context) {
+	relation, err := h.service.Relation(c.Request.Context(), c.Param("id"), c.Param("other"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get relation: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, relation)
+}
+
+// GetCommonFollowing 查询两个用户共同关注的人
+func (h *SocialHandler) GetCommonFollowing(c *gin.Context) {
+	users, err := h.service.CommonFollowing(c.Request.Context(), c.Param("id"), c.Param("other"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get common following: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// GetFollowCounts 批量查询关注数和粉丝数，参数ids为逗号分隔的用户ID
+func (h *SocialHandler) GetFollowCounts(c *gin.Context) {
+	ids, ok := idList(c, "ids")
+	if !ok {
+		return
+	}
+	counts, err := h.service.FollowCounts(c.Request.Context(), ids...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get follow counts: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"counts": counts})
+}
+
+// page 读取cursor、count参数，分页遍历集合
+func (h *SocialHandler) page(c *gin.Context, scan func(ctx context.Context, id string, cursor uint64, count int64) (social.Page, error), id, name string) {
+	cursor, err := strconv.ParseUint(c.DefaultQuery("cursor", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	count, err := strconv.ParseInt(c.DefaultQuery("count", "20"), 10, 64)
+	if err != nil || count <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count"})
+		return
+	}
+
+	page, err := scan(c.Request.Context(), id, cursor, count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get " + name + ": " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// idList 读取逗号分隔的ID列表，为空或超过 maxBatchIDs 时返回400
+func idList(c *gin.Context, param string) ([]string, bool) {
+	var ids []string
+	for _, id := range strings.Split(c.Query(param), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter " + param + " is required"})
+		return nil, false
+	}
+	if len(ids) > maxBatchIDs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many ids", "max": maxBatchIDs})
+		return nil, false
+	}
+	return ids, true
+}
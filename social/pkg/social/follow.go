@@ -0,0 +1,165 @@
+package social
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// followScript 关注，已经关注时不重复计数
+// KEYS: 关注者的关注集合, 被关注者的粉丝集合, 关注数哈希, 粉丝数哈希
+// ARGV: 关注者, 被关注者
+// 返回是否新关注(1/0)
+var followScript = redis.NewScript(`
+if redis.call('SADD', KEYS[1], ARGV[2]) == 0 then
+	return 0
+end
+redis.call('SADD', KEYS[2], ARGV[1])
+redis.call('HINCRBY', KEYS[3], ARGV[1], 1)
+redis.call('HINCRBY', KEYS[4], ARGV[2], 1)
+return 1
+`)
+
+// unfollowScript 取消关注，没有关注时不改变计数，计数减到0时删除字段
+// KEYS: 关注者的关注集合, 被关注者的粉丝集合, 关注数哈希, 粉丝数哈希
+// ARGV: 关注者, 被关注者
+// 返回是否取消了关注(1/0)
+var unfollowScript = redis.NewScript(`
+if redis.call('SREM', KEYS[1], ARGV[2]) == 0 then
+	return 0
+end
+redis.call('SREM', KEYS[2], ARGV[1])
+if redis.call('HINCRBY', KEYS[3], ARGV[1], -1) <= 0 then
+	redis.call('HDEL', KEYS[3], ARGV[1])
+end
+if redis.call('HINCRBY', KEYS[4], ARGV[2], -1) <= 0 then
+	redis.call('HDEL', KEYS[4], ARGV[2])
+end
+return 1
+`)
+
+// FollowCount 用户的关注数和粉丝数
+type FollowCount struct {
+	Following int64 `json:"following"`
+	Followers int64 `json:"followers"`
+}
+
+// Relation 两个用户之间的关注关系
+type Relation struct {
+	// 用户是否关注了对方
+	Following bool `json:"following"`
+	// 对方是否关注了用户
+	FollowedBy bool `json:"followed_by"`
+	// 是否互相关注
+	Mutual bool `json:"mutual"`
+}
+
+// followingKey 用户关注的人
+func (s *Service) followingKey(userID string) string {
+	return s.prefix + "following:" + userID
+}
+
+// followersKey 用户的粉丝
+func (s *Service) followersKey(userID string) string {
+	return s.prefix + "followers:" + userID
+}
+
+// followingCountsKey 所有用户的关注数
+func (s *Service) followingCountsKey() string {
+	return s.prefix + "following_counts"
+}
+
+// followerCountsKey 所有用户的粉丝数
+func (s *Service) followerCountsKey() string {
+	return s.prefix + "follower_counts"
+}
+
+// followKeys 关注和取消关注脚本使用的key
+func (s *Service) followKeys(userID, target string) []string {
+	return []string{
+		s.followingKey(userID),
+		s.followersKey(target),
+		s.followingCountsKey(),
+		s.followerCountsKey(),
+	}
+}
+
+// Follow 用户关注target，重复关注是幂等的，返回本次是否新关注
+func (s *Service) Follow(ctx context.Context, userID, target string) (bool, error) {
+	return s.runFollow(ctx, followScript, userID, target)
+}
+
+// Unfollow 用户取消关注target，返回本次是否取消了关注
+func (s *Service) Unfollow(ctx context.Context, userID, target string) (bool, error) {
+	return s.runFollow(ctx, unfollowScript, userID, target)
+}
+
+// runFollow 执行关注或取消关注脚本
+func (s *Service) runFollow(ctx context.Context, script *redis.Script, userID, target string) (bool, error) {
+	if userID == "" || target == "" {
+		return false, ErrInvalidID
+	}
+	if userID == target {
+		return false, ErrSelfFollow
+	}
+	changed, err := script.Run(ctx, s.client, s.followKeys(userID, target), userID, target).Int64()
+	if err != nil {
+		return false, err
+	}
+	return changed == 1, nil
+}
+
+// Relation 查询用户与other之间的关注关系
+func (s *Service) Relation(ctx context.Context, userID, other string) (Relation, error) {
+	var following, followedBy *redis.BoolCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		following = pipe.SIsMember(ctx, s.followingKey(userID), other)
+		followedBy = pipe.SIsMember(ctx, s.followingKey(other), userID)
+		return nil
+	})
+	if err != nil {
+		return Relation{}, err
+	}
+	return Relation{
+		Following:  following.Val(),
+		FollowedBy: followedBy.Val(),
+		Mutual:     following.Val() && followedBy.Val(),
+	}, nil
+}
+
+// Following 分页遍历用户关注的人
+func (s *Service) Following(ctx context.Context, userID string, cursor uint64, count int64) (Page, error) {
+	return s.scanSet(ctx, s.followingKey(userID), cursor, count)
+}
+
+// Followers 分页遍历用户的粉丝
+func (s *Service) Followers(ctx context.Context, userID string, cursor uint64, count int64) (Page, error) {
+	return s.scanSet(ctx, s.followersKey(userID), cursor, count)
+}
+
+// MutualFollows 与用户互相关注的人，即关注集合与粉丝集合的交集
+func (s *Service) MutualFollows(ctx context.Context, userID string) ([]string, error) {
+	return s.client.SInter(ctx, s.followingKey(userID), s.followersKey(userID)).Result()
+}
+
+// CommonFollowing 两个用户共同关注的人
+func (s *Service) CommonFollowing(ctx context.Context, userID, other string) ([]string, error) {
+	return s.client.SInter(ctx, s.followingKey(userID), s.followingKey(other)).Result()
+}
+
+// FollowCounts 批量读取用户的关注数和粉丝数
+func (s *Service) FollowCounts(ctx context.Context, userIDs ...string) (map[string]FollowCount, error) {
+	following, err := s.hmgetCounts(ctx, s.followingCountsKey(), userIDs)
+	if err != nil {
+		return nil, err
+	}
+	followers, err := s.hmgetCounts(ctx, s.followerCountsKey(), userIDs)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]FollowCount, len(userIDs))
+	for _, id := range userIDs {
+		counts[id] = FollowCount{Following: following[id], Followers: followers[id]}
+	}
+	return counts, nil
+}
@@ -0,0 +1,110 @@
+package social
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// likeScript 点赞，用户已经点过赞时不重复计数
+// KEYS: 点赞用户集合, 点赞计数哈希
+// ARGV: 用户ID, 点赞对象
+// 返回 {是否新点赞(1/0), 当前点赞数}
+var likeScript = redis.NewScript(`
+if redis.call('SADD', KEYS[1], ARGV[1]) == 1 then
+	return {1, redis.call('HINCRBY', KEYS[2], ARGV[2], 1)}
+end
+return {0, tonumber(redis.call('HGET', KEYS[2], ARGV[2]) or '0')}
+`)
+
+// unlikeScript 取消点赞，用户没有点过赞时不改变计数，计数减到0时删除字段
+// KEYS: 点赞用户集合, 点赞计数哈希
+// ARGV: 用户ID, 点赞对象
+// 返回 {是否取消了点赞(1/0), 当前点赞数}
+var unlikeScript = redis.NewScript(`
+if redis.call('SREM', KEYS[1], ARGV[1]) == 1 then
+	local count = redis.call('HINCRBY', KEYS[2], ARGV[2], -1)
+	if count <= 0 then
+		redis.call('HDEL', KEYS[2], ARGV[2])
+		count = 0
+	end
+	return {1, count}
+end
+return {0, tonumber(redis.call('HGET', KEYS[2], ARGV[2]) or '0')}
+`)
+
+// likersKey 点赞对象的点赞用户集合
+func (s *Service) likersKey(target string) string {
+	return s.prefix + "like:" + target
+}
+
+// likeCountsKey 所有点赞对象的点赞数
+func (s *Service) likeCountsKey() string {
+	return s.prefix + "like_counts"
+}
+
+// Like 用户为对象点赞，重复点赞是幂等的
+// 返回本次是否新点赞和当前的点赞数
+func (s *Service) Like(ctx context.Context, userID, target string) (bool, int64, error) {
+	return s.runLike(ctx, likeScript, userID, target)
+}
+
+// Unlike 用户取消点赞，没有点过赞时什么也不做
+// 返回本次是否取消了点赞和当前的点赞数
+func (s *Service) Unlike(ctx context.Context, userID, target string) (bool, int64, error) {
+	return s.runLike(ctx, unlikeScript, userID, target)
+}
+
+// runLike 执行点赞或取消点赞脚本
+func (s *Service) runLike(ctx context.Context, script *redis.Script, userID, target string) (bool, int64, error) {
+	if userID == "" || target == "" {
+		return false, 0, ErrInvalidID
+	}
+	keys := []string{s.likersKey(target), s.likeCountsKey()}
+	result, err := script.Run(ctx, s.client, keys, userID, target).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, result[1], nil
+}
+
+// HasLiked 用户是否为这些对象点过赞
+func (s *Service) HasLiked(ctx context.Context, userID string, targets ...string) (map[string]bool, error) {
+	liked := make(map[string]bool, len(targets))
+	for start := 0; start < len(targets); start += s.config.MaxBatchSize {
+		batch := targets[start:min(start+s.config.MaxBatchSize, len(targets))]
+		cmds := make([]*redis.BoolCmd, len(batch))
+		_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, target := range batch {
+				cmds[i] = pipe.SIsMember(ctx, s.likersKey(target), userID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i, cmd := range cmds {
+			liked[batch[i]] = cmd.Val()
+		}
+	}
+	return liked, nil
+}
+
+// LikeCount 对象的点赞数
+func (s *Service) LikeCount(ctx context.Context, target string) (int64, error) {
+	counts, err := s.LikeCounts(ctx, target)
+	if err != nil {
+		return 0, err
+	}
+	return counts[target], nil
+}
+
+// LikeCounts 批量读取对象的点赞数，用于列表页，每批只需要一次HMGET
+func (s *Service) LikeCounts(ctx context.Context, targets ...string) (map[string]int64, error) {
+	return s.hmgetCounts(ctx, s.likeCountsKey(), targets)
+}
+
+// Likers 分页遍历为对象点过赞的用户
+func (s *Service) Likers(ctx context.Context, target string, cursor uint64, count int64) (Page, error) {
+	return s.scanSet(ctx, s.likersKey(target), cursor, count)
+}
@@ -0,0 +1,102 @@
+package social
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrSelfFollow 不能关注自己
+	ErrSelfFollow = errors.New("social: cannot follow yourself")
+	// ErrInvalidID 用户ID或点赞对象为空
+	ErrInvalidID = errors.New("social: empty id")
+)
+
+// Config 社交计数服务配置
+type Config struct {
+	// Redis中各个key的前缀，完整的前缀为 KeyPrefix + "{名称}:"
+	KeyPrefix string
+	// 批量查询时一次最多查询的对象数
+	MaxBatchSize int
+}
+
+// DefaultConfig 默认社交计数服务配置
+var DefaultConfig = Config{
+	KeyPrefix:    "social:",
+	MaxBatchSize: 500,
+}
+
+// Service 点赞与关注计数服务
+//
+// 每个关系用一个SET保存成员，保证重复点赞、重复关注不会重复计数；计数单独保存在HASH中，
+// 与SET的修改在同一个Lua脚本中完成，批量读取计数只需要一次HMGET。
+// 所有key使用同一个hash tag，在Redis集群中位于同一个slot，脚本可以同时修改多个key
+type Service struct {
+	client redis.UniversalClient
+	config Config
+	prefix string
+}
+
+// NewService 创建名为name的社交计数服务
+func NewService(client redis.UniversalClient, name string, config Config) *Service {
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = DefaultConfig.MaxBatchSize
+	}
+	return &Service{
+		client: client,
+		config: config,
+		prefix: config.KeyPrefix + "{" + name + "}:",
+	}
+}
+
+// NewDefaultService 使用默认配置创建社交计数服务
+func NewDefaultService(client redis.UniversalClient, name string) *Service {
+	return NewService(client, name, DefaultConfig)
+}
+
+// Page 一页成员，Cursor为0表示已经遍历完
+type Page struct {
+	Members []string `json:"members"`
+	Cursor  uint64   `json:"cursor"`
+}
+
+// scanSet 用SSCAN分页遍历集合，SET没有顺序，只能按游标遍历
+func (s *Service) scanSet(ctx context.Context, key string, cursor uint64, count int64) (Page, error) {
+	members, next, err := s.client.SScan(ctx, key, cursor, "", count).Result()
+	if err != nil {
+		return Page{}, err
+	}
+	if members == nil {
+		members = []string{}
+	}
+	return Page{Members: members, Cursor: next}, nil
+}
+
+// hmgetCounts 批量读取计数哈希中的字段，不存在的字段计为0
+func (s *Service) hmgetCounts(ctx context.Context, key string, fields []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(fields))
+	for start := 0; start < len(fields); start += s.config.MaxBatchSize {
+		batch := fields[start:min(start+s.config.MaxBatchSize, len(fields))]
+		values, err := s.client.HMGet(ctx, key, batch...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			counts[batch[i]] = parseCount(v)
+		}
+	}
+	return counts, nil
+}
+
+// parseCount 把HMGET返回的值转换为计数
+func parseCount(v interface{}) int64 {
+	str, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(str, 10, 64)
+	return n
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"uv-pv-collector/internal/config"
+	"uv-pv-collector/internal/handlers"
+	"uv-pv-collector/internal/stats"
+
+	"redis-learning/pkg/cli"
+	"redis-learning/pkg/metrics"
+	"redis-learning/pkg/redisclient"
+	"scheduler/pkg/scheduler"
+)
+
+// Run 运行UV/PV统计服务，ctx取消后优雅地关闭，args为命令行参数
+func Run(ctx context.Context, args []string) error {
+	// 加载配置，优先级：环境变量 > 配置文件 > 默认值
+	fs := cli.NewFlagSet("uvpv")
+	configPath := fs.String("config", "", "path of the YAML config file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// 初始化StatsService，Redis命令的指标通过 /metrics 输出
+	registry := metrics.NewRegistry()
+	statsService, err := stats.NewStatsService(cfg, registry.RedisHook("uv-pv-collector"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize stats service: %w", err)
+	}
+	defer statsService.Close()
+
+	// 初始化StatsCollector
+	collector := stats.NewStatsCollector(statsService, cfg)
+
+	// 启动定时任务调度器，多个实例部署时只有持有租约的主节点执行压缩任务
+	sched := scheduler.NewDefaultScheduler(statsService.Client(), "uv-pv-collector")
+	if err := collector.RegisterJobs(sched); err != nil {
+		return fmt.Errorf("failed to register background jobs: %w", err)
+	}
+	sched.Start()
+
+	// 初始化Gin路由器
+	router := gin.Default()
+
+	// 添加一个简单的健康检查路由
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "pong",
+		})
+	})
+
+	// Redis健康检查，连接异常时返回503
+	router.GET("/health", func(c *gin.Context) {
+		health := redisclient.CheckHealth(c.Request.Context(), statsService.Client())
+		status := http.StatusOK
+		if !health.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, health)
+	})
+
+	// Prometheus指标
+	router.GET(metrics.Path, gin.WrapH(registry.Handler()))
+
+	// 设置统计处理器路由
+	statsHandler := handlers.NewStatsHandler(collector)
+	statsHandler.Setup(router)
+
+	// 启动HTTP服务器，收到中断信号后优雅地关闭
+	serveErr := cli.ServeHTTP(ctx, &http.Server{
+		Addr:    cfg.ServerAddr,
+		Handler: router,
+	})
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cli.ShutdownTimeout)
+	defer cancel()
+
+	// 停止调度器，等待正在执行的任务结束并释放租约，让其他实例接管
+	if err := sched.Stop(shutdownCtx); err != nil {
+		log.Printf("Failed to stop scheduler: %v", err)
+	}
+
+	// 服务器不再接收请求后，把缓冲队列中的访问写入Redis，再关闭Redis连接
+	// 使用单独的超时，不与停止调度器共用剩余的时间
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), cli.ShutdownTimeout)
+	defer cancelFlush()
+	if err := collector.Flush(flushCtx); err != nil {
+		log.Printf("Failed to flush buffered visits: %v", err)
+	}
+	return serveErr
+}
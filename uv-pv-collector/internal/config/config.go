@@ -1,24 +1,150 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	sharedconfig "redis-learning/pkg/config"
+	"redis-learning/pkg/redisclient"
+	"scheduler/pkg/scheduler"
+)
+
+// EnvPrefix 环境变量的前缀，变量名由前缀和配置项的YAML路径组成，如 UVPV_REDIS_ADDR、UVPV_COMPACTION_SCHEDULE
+const EnvPrefix = "UVPV"
+
 // Config 存储Redis连接的配置信息
 type Config struct {
-	// Redis连接地址
-	RedisAddr string
-	// Redis密码，没有则为空
-	RedisPassword string
-	// Redis数据库索引
-	RedisDB int
+	// Redis连接配置，只支持standalone模式
+	Redis sharedconfig.RedisOptions `yaml:"redis"`
 	// 应用服务器监听地址
-	ServerAddr string
+	ServerAddr string `yaml:"server_addr"`
+	// 是否启用滚动窗口统计（按小时分桶记录PV和UV）
+	EnableRollingStats bool `yaml:"enable_rolling_stats"`
+	// 小时桶的保留时长，同时也是滚动窗口允许的最大长度
+	RollingRetention time.Duration `yaml:"rolling_retention"`
+	// 是否记录访客画像（首次/最近访问时间、累计访问次数、最近访问页面）
+	EnableVisitorProfiles bool `yaml:"enable_visitor_profiles"`
+	// 访客画像的过期时间，每次访问时刷新，为0表示永不过期
+	VisitorProfileTTL time.Duration `yaml:"visitor_profile_ttl"`
+	// PV采样率N：每N次访问只累加一次PV计数器，读取时乘以N还原，UV不受影响始终精确记录
+	// 小于等于1表示不采样。修改采样率后，已有的计数会按新的倍数放大，应避免在运行中调整
+	PageViewSampleRate int `yaml:"page_view_sample_rate"`
+	// 精确UV阈值：页面当天访客数不超过该值时使用SET精确计数，超过后自动转换为HyperLogLog
+	// 为0表示始终使用HyperLogLog
+	ExactUVThreshold int64 `yaml:"exact_uv_threshold"`
+	// 重复访问去重窗口：同一访客在窗口内重复访问同一页面时不累加PV，为0表示不去重
+	DedupWindow time.Duration `yaml:"dedup_window"`
+	// 每日数据的压缩年龄：早于该时长的页面每日PV/UV会被折叠到月度汇总中并删除，为0表示不压缩（默认）
+	// 这些日期的按天查询返回 stats.ErrCompacted，日期范围查询须覆盖所在月份中被压缩的每一天
+	// 全站的每日PV/UV（site:前缀）、来源和目标计数体积很小，不参与压缩，始终可以按天查询
+	CompactionAge time.Duration `yaml:"compaction_age"`
+	// 压缩任务的执行计划（cron表达式），多个实例部署时只有调度器的主节点执行
+	CompactionSchedule string `yaml:"compaction_schedule"`
+	// 是否将每次访问的原始事件归档到Redis Stream中，供后续分析或重新处理
+	EnableEventArchive bool `yaml:"enable_event_archive"`
+	// 归档使用的Stream键名
+	EventStreamKey string `yaml:"event_stream_key"`
+	// Stream的近似最大长度，超出后最旧的事件会被裁剪
+	EventStreamMaxLen int64 `yaml:"event_stream_max_len"`
+	// 是否把每次访问作为页面访问事件发布到共用的事件总线（eventbus.SharedBus），供rate-limit等服务订阅
+	PublishPageAccess bool `yaml:"publish_page_access"`
+	// 是否异步记录访问：请求只把访问放入内存队列，由后台协程写入Redis
+	// 关闭服务时会调用Flush等待队列中的访问写完
+	AsyncRecording bool `yaml:"async_recording"`
+	// 异步记录队列的容量，队列满时记录请求会阻塞等待
+	RecordBufferSize int `yaml:"record_buffer_size"`
+	// 是否检测全站新访客：用布隆过滤器记录出现过的访客，第一次访问全站的访客计入当天的新访客数
+	EnableNewVisitorDetection bool `yaml:"enable_new_visitor_detection"`
+	// 新访客布隆过滤器的键名
+	NewVisitorFilterKey string `yaml:"new_visitor_filter_key"`
+	// 布隆过滤器预计容纳的访客数，超出后误判率会升高
+	NewVisitorExpected uint64 `yaml:"new_visitor_expected"`
+	// 布隆过滤器的误判率，即新访客被误判为老访客、没有计入新访客数的概率
+	NewVisitorFalsePositiveRate float64 `yaml:"new_visitor_false_positive_rate"`
 }
 
 // DefaultConfig 返回默认配置
 // 默认使用本地Redis，无密码，0号数据库
 func DefaultConfig() *Config {
 	return &Config{
-		RedisAddr:     "localhost:6379",
-		RedisPassword: "",
-		RedisDB:       0,
-		ServerAddr:    ":8080",
+		Redis: sharedconfig.RedisOptions{
+			Addr:     "localhost:6379",
+			Password: "",
+			DB:       0,
+		},
+		ServerAddr: ":8080",
+
+		EnableRollingStats: true,
+		RollingRetention:   48 * time.Hour,
+
+		EnableVisitorProfiles: true,
+		VisitorProfileTTL:     0,
+
+		PageViewSampleRate: 1,
+		ExactUVThreshold:   1000,
+
+		DedupWindow: 0,
+
+		CompactionAge:      0,
+		CompactionSchedule: "0 3 * * *",
+
+		EnableEventArchive: false,
+		EventStreamKey:     "events:visits",
+		EventStreamMaxLen:  100000,
+		PublishPageAccess:  false,
+
+		AsyncRecording:   false,
+		RecordBufferSize: 1024,
+
+		EnableNewVisitorDetection:   false,
+		NewVisitorFilterKey:         "bloom:visitors",
+		NewVisitorExpected:          10_000_000,
+		NewVisitorFalsePositiveRate: 0.001,
 	}
 }
+
+// LoadConfig 加载配置：以 DefaultConfig 为基础，依次应用YAML配置文件和环境变量，后者优先，最后检查配置
+// path为空时不读取配置文件
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if err := sharedconfig.Load(path, EnvPrefix, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 检查配置是否有效，返回所有无效的配置项
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Redis.Mode == "" || c.Redis.Mode == redisclient.ModeStandalone, "redis.mode must be %s: %q",
+		redisclient.ModeStandalone, c.Redis.Mode)
+	check(c.Redis.DB >= 0, "redis.db must not be negative: %d", c.Redis.DB)
+	check(c.ServerAddr != "", "server_addr is required")
+	check(!c.EnableRollingStats || c.RollingRetention > 0, "rolling_retention must be positive: %v", c.RollingRetention)
+	check(c.VisitorProfileTTL >= 0, "visitor_profile_ttl must not be negative: %v", c.VisitorProfileTTL)
+	check(c.ExactUVThreshold >= 0, "exact_uv_threshold must not be negative: %d", c.ExactUVThreshold)
+	check(c.DedupWindow >= 0, "dedup_window must not be negative: %v", c.DedupWindow)
+	check(c.CompactionAge >= 0, "compaction_age must not be negative: %v", c.CompactionAge)
+	if c.CompactionAge > 0 && c.CompactionSchedule != "" {
+		_, err := scheduler.ParseSchedule(c.CompactionSchedule)
+		check(err == nil, "compaction_schedule is invalid: %v", err)
+	}
+	check(!c.EnableEventArchive || c.EventStreamKey != "", "event_stream_key is required when enable_event_archive is set")
+	check(c.EventStreamMaxLen >= 0, "event_stream_max_len must not be negative: %d", c.EventStreamMaxLen)
+	check(!c.AsyncRecording || c.RecordBufferSize > 0, "record_buffer_size must be positive: %d", c.RecordBufferSize)
+	if c.EnableNewVisitorDetection {
+		check(c.NewVisitorFilterKey != "", "new_visitor_filter_key is required when enable_new_visitor_detection is set")
+		check(c.NewVisitorExpected > 0, "new_visitor_expected must be positive: %d", c.NewVisitorExpected)
+		check(c.NewVisitorFalsePositiveRate > 0 && c.NewVisitorFalsePositiveRate < 1,
+			"new_visitor_false_positive_rate must be between 0 and 1: %v", c.NewVisitorFalsePositiveRate)
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,13 +1,24 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"uv-pv-collector/internal/stats"
 )
 
+// transparentGIF 1x1透明GIF图片，用于追踪像素
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
 // StatsHandler 处理与PV和UV统计相关的HTTP请求
 type StatsHandler struct {
 	collector *stats.StatsCollector
@@ -24,6 +35,8 @@ func NewStatsHandler(collector *stats.StatsCollector) *StatsHandler {
 func (h *StatsHandler) Setup(router *gin.Engine) {
 	// 记录访问
 	router.POST("/record", h.RecordVisit)
+	// 追踪像素，静态页面可通过<img>标签直接上报访问
+	router.GET("/t.gif", h.TrackPixel)
 
 	// 获取统计数据的路由
 	statsApi := router.Group("/stats")
@@ -34,6 +47,37 @@ func (h *StatsHandler) Setup(router *gin.Engine) {
 		statsApi.GET("/today", h.GetTodayStats)
 		// 获取日期范围内的统计数据
 		statsApi.GET("/range", h.GetStatsForDateRange)
+		// 获取最近一段时间（滚动窗口）内的统计数据
+		statsApi.GET("/rolling", h.GetRollingStats)
+		// 获取全站汇总统计数据
+		statsApi.GET("/site", h.GetSiteStats)
+		// 页面排行榜
+		statsApi.GET("/top", h.GetTopPages)
+		// 主要来源域名
+		statsApi.GET("/referrers", h.GetTopReferrers)
+		// 获取月度统计数据（包含已压缩的历史数据）
+		statsApi.GET("/monthly", h.GetMonthlyStats)
+		// 全站新访客数
+		statsApi.GET("/new-visitors", h.GetNewVisitors)
+	}
+
+	// 页面列表
+	router.GET("/pages", h.ListPages)
+
+	// 获取访客画像
+	router.GET("/visitors/:id", h.GetVisitorProfile)
+
+	// 读取归档的原始访问事件
+	router.GET("/events", h.GetEvents)
+
+	// 目标转化
+	router.POST("/goal", h.RecordGoal)
+	goalsApi := router.Group("/goals")
+	{
+		// 获取所有目标
+		goalsApi.GET("", h.ListGoals)
+		// 获取目标的转化数据
+		goalsApi.GET("/stats", h.GetGoalStats)
 	}
 }
 
@@ -41,8 +85,10 @@ func (h *StatsHandler) Setup(router *gin.Engine) {
 func (h *StatsHandler) RecordVisit(c *gin.Context) {
 	// 定义请求体结构
 	var req struct {
-		Page      string `json:"page" binding:"required"`
-		VisitorID string `json:"visitor_id" binding:"required"`
+		Page      string            `json:"page" binding:"required"`
+		VisitorID string            `json:"visitor_id" binding:"required"`
+		Referrer  string            `json:"referrer"`
+		Dims      map[string]string `json:"dims"`
 	}
 
 	// 解析请求体
@@ -54,7 +100,13 @@ func (h *StatsHandler) RecordVisit(c *gin.Context) {
 	}
 
 	// 记录访问
-	if err := h.collector.RecordVisit(c.Request.Context(), req.Page, req.VisitorID); err != nil {
+	visit := stats.Visit{
+		Page:      req.Page,
+		VisitorID: req.VisitorID,
+		Referrer:  req.Referrer,
+		Dims:      req.Dims,
+	}
+	if err := h.collector.Record(c.Request.Context(), visit); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to record visit: " + err.Error(),
 		})
@@ -67,6 +119,36 @@ func (h *StatsHandler) RecordVisit(c *gin.Context) {
 	})
 }
 
+// TrackPixel 处理追踪像素请求
+// 记录访问后返回1x1透明GIF，并禁止浏览器和代理缓存，保证每次页面加载都会发起请求
+// 图片请求的Referer是嵌入像素的页面本身，因此来源需要由页面通过ref参数传入（例如document.referrer）
+func (h *StatsHandler) TrackPixel(c *gin.Context) {
+	page := c.Query("page")
+	visitorID := c.Query("vid")
+
+	if page == "" || visitorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Page and vid parameters are required",
+		})
+		return
+	}
+
+	// 记录失败时仍然返回图片，避免页面上出现破损的图片
+	visit := stats.Visit{
+		Page:      page,
+		VisitorID: visitorID,
+		Referrer:  c.Query("ref"),
+	}
+	if err := h.collector.Record(c.Request.Context(), visit); err != nil {
+		log.Printf("Failed to record pixel visit for page %s: %v", page, err)
+	}
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate, private")
+	c.Header("Pragma", "no-cache")
+	c.Header("Expires", "0")
+	c.Data(http.StatusOK, "image/gif", transparentGIF)
+}
+
 // GetDailyStats 处理获取特定日期统计数据的请求
 func (h *StatsHandler) GetDailyStats(c *gin.Context) {
 	page := c.Query("page")
@@ -80,6 +162,12 @@ func (h *StatsHandler) GetDailyStats(c *gin.Context) {
 	}
 
 	pv, uv, err := h.collector.GetDailyStats(c.Request.Context(), page, date)
+	if errors.Is(err, stats.ErrCompacted) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get stats: " + err.Error(),
@@ -135,6 +223,12 @@ func (h *StatsHandler) GetStatsForDateRange(c *gin.Context) {
 	}
 
 	pv, uv, err := h.collector.GetStatsForDateRange(c.Request.Context(), page, startDate, endDate)
+	if errors.Is(err, stats.ErrCompacted) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get stats for date range: " + err.Error(),
@@ -151,3 +245,377 @@ func (h *StatsHandler) GetStatsForDateRange(c *gin.Context) {
 		"note":                  "UV count across multiple days may count some visitors multiple times",
 	})
 }
+
+// GetRollingStats 处理获取滚动窗口统计数据的请求
+// window参数为Go时长格式，例如24h、90m，默认为24h
+func (h *StatsHandler) GetRollingStats(c *gin.Context) {
+	page := c.Query("page")
+	if page == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Page parameter is required",
+		})
+		return
+	}
+
+	window, err := time.ParseDuration(c.DefaultQuery("window", "24h"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid window parameter: " + err.Error(),
+		})
+		return
+	}
+
+	pv, uv, err := h.collector.GetRollingStats(c.Request.Context(), page, window)
+	if errors.Is(err, stats.ErrRollingDisabled) || errors.Is(err, stats.ErrInvalidWindow) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get rolling stats: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"page":            page,
+		"window":          window.String(),
+		"page_views":      pv,
+		"unique_visitors": uv,
+	})
+}
+
+// GetSiteStats 处理获取全站统计数据的请求
+// 支持date查询单日，或start_date和end_date查询日期范围，均未提供时返回今天的数据
+func (h *StatsHandler) GetSiteStats(c *gin.Context) {
+	startDate := c.Query("start_date")
+	endDate := c.Query("end_date")
+	if date := c.Query("date"); date != "" {
+		startDate, endDate = date, date
+	}
+	if startDate == "" && endDate == "" {
+		today := time.Now().Format("2006-01-02")
+		startDate, endDate = today, today
+	}
+	if startDate == "" || endDate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Both start_date and end_date parameters are required for a range",
+		})
+		return
+	}
+
+	days, pv, uv, err := h.collector.GetSiteStats(c.Request.Context(), startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get site stats: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date":            startDate,
+		"end_date":              endDate,
+		"days":                  days,
+		"total_page_views":      pv,
+		"total_unique_visitors": uv,
+	})
+}
+
+// GetVisitorProfile 处理获取访客画像的请求
+func (h *StatsHandler) GetVisitorProfile(c *gin.Context) {
+	visitorID := c.Param("id")
+
+	profile, err := h.collector.GetVisitorProfile(c.Request.Context(), visitorID)
+	if errors.Is(err, stats.ErrVisitorNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Visitor not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get visitor profile: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// ListPages 处理页面列表请求
+// 支持sort（name、pv、uv，默认name）、limit、offset参数，按名称排序时还可以使用cursor翻页
+func (h *StatsHandler) ListPages(c *gin.Context) {
+	h.listPages(c, c.DefaultQuery("sort", stats.SortByName))
+}
+
+// GetTopPages 处理页面排行榜请求
+// by参数指定按pv或uv排序，默认按pv
+func (h *StatsHandler) GetTopPages(c *gin.Context) {
+	h.listPages(c, c.DefaultQuery("by", stats.SortByPageViews))
+}
+
+// listPages 解析分页参数并返回页面列表
+func (h *StatsHandler) listPages(c *gin.Context, sort string) {
+	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(stats.DefaultPageLimit)), 10, 64)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit parameter",
+		})
+		return
+	}
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid offset parameter",
+		})
+		return
+	}
+
+	list, err := h.collector.ListPages(c.Request.Context(), date, stats.PageQuery{
+		Sort:   sort,
+		Offset: offset,
+		Limit:  limit,
+		Cursor: c.Query("cursor"),
+	})
+	if errors.Is(err, stats.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, stats.ErrCompacted) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list pages: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":        date,
+		"sort":        sort,
+		"limit":       limit,
+		"offset":      offset,
+		"total":       list.Total,
+		"pages":       list.Pages,
+		"next_cursor": list.NextCursor,
+	})
+}
+
+// GetTopReferrers 处理获取主要来源域名的请求
+// 不传page时返回全站来源，不传date时返回今天的数据
+func (h *StatsHandler) GetTopReferrers(c *gin.Context) {
+	page := c.Query("page")
+	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "10"), 10, 64)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit parameter",
+		})
+		return
+	}
+
+	referrers, err := h.collector.GetTopReferrers(c.Request.Context(), page, date, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get top referrers: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"page":      page,
+		"date":      date,
+		"referrers": referrers,
+	})
+}
+
+// GetMonthlyStats 处理获取月度统计数据的请求，month格式为2006-01
+func (h *StatsHandler) GetMonthlyStats(c *gin.Context) {
+	page := c.Query("page")
+	month := c.Query("month")
+
+	if page == "" || month == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Page and month parameters are required",
+		})
+		return
+	}
+
+	pv, uv, err := h.collector.GetMonthlyStats(c.Request.Context(), page, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get monthly stats: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"page":            page,
+		"month":           month,
+		"page_views":      pv,
+		"unique_visitors": uv,
+	})
+}
+
+// GetNewVisitors 处理获取全站新访客数的请求，不传date时返回今天的数据
+func (h *StatsHandler) GetNewVisitors(c *gin.Context) {
+	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+
+	newVisitors, known, err := h.collector.GetNewVisitors(c.Request.Context(), date)
+	if errors.Is(err, stats.ErrNewVisitorDisabled) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get new visitors: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":           date,
+		"new_visitors":   newVisitors,
+		"known_visitors": known,
+	})
+}
+
+// GetEvents 处理读取原始访问事件的请求
+// start和end为RFC3339格式，默认读取最近一小时，count限制返回条数
+func (h *StatsHandler) GetEvents(c *gin.Context) {
+	end := time.Now()
+	if v := c.Query("end"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid end parameter: " + err.Error(),
+			})
+			return
+		}
+		end = t
+	}
+	start := end.Add(-time.Hour)
+	if v := c.Query("start"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid start parameter: " + err.Error(),
+			})
+			return
+		}
+		start = t
+	}
+
+	count, err := strconv.ParseInt(c.DefaultQuery("count", "100"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid count parameter",
+		})
+		return
+	}
+
+	events, err := h.collector.GetEvents(c.Request.Context(), start, end, count)
+	if errors.Is(err, stats.ErrArchiveDisabled) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read events: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start":  start.Format(time.RFC3339),
+		"end":    end.Format(time.RFC3339),
+		"events": events,
+	})
+}
+
+// RecordGoal 处理记录目标完成的请求
+func (h *StatsHandler) RecordGoal(c *gin.Context) {
+	var req struct {
+		Goal      string `json:"goal" binding:"required"`
+		VisitorID string `json:"visitor_id" binding:"required"`
+		Page      string `json:"page"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request parameters: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.collector.RecordGoal(c.Request.Context(), req.Goal, req.Page, req.VisitorID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record goal: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Goal recorded successfully",
+	})
+}
+
+// ListGoals 处理获取所有目标名称的请求
+func (h *StatsHandler) ListGoals(c *gin.Context) {
+	goals, err := h.collector.ListGoals(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list goals: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goals": goals,
+	})
+}
+
+// GetGoalStats 处理获取目标转化数据的请求
+// 不传page时统计全站转化率，不传date时返回今天的数据
+func (h *StatsHandler) GetGoalStats(c *gin.Context) {
+	goal := c.Query("goal")
+	if goal == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Goal parameter is required",
+		})
+		return
+	}
+	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+
+	goalStats, err := h.collector.GetGoalStats(c.Request.Context(), goal, c.Query("page"), date)
+	if errors.Is(err, stats.ErrCompacted) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get goal stats: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, goalStats)
+}
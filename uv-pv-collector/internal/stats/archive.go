@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dimFieldPrefix 维度字段在Stream条目中的前缀
+const dimFieldPrefix = "dim."
+
+// MaxEventCount 单次读取原始事件的最大条数
+const MaxEventCount = 1000
+
+// VisitEvent 归档在Stream中的一次原始访问事件
+type VisitEvent struct {
+	ID        string            `json:"id"`
+	Page      string            `json:"page"`
+	VisitorID string            `json:"visitor_id"`
+	Referrer  string            `json:"referrer,omitempty"`
+	Dims      map[string]string `json:"dims,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// ArchiveVisit 将原始访问事件追加到Stream中
+// 使用 MAXLEN ~ 近似裁剪，Redis可以按整个节点删除旧条目，开销远小于精确裁剪
+func (s *StatsService) ArchiveVisit(ctx context.Context, visit Visit, at time.Time) error {
+	values := map[string]interface{}{
+		"page":       visit.Page,
+		"visitor_id": visit.VisitorID,
+		"ts":         at.UnixMilli(),
+	}
+	if visit.Referrer != "" {
+		values["referrer"] = visit.Referrer
+	}
+	for name, value := range visit.Dims {
+		values[dimFieldPrefix+name] = value
+	}
+
+	err := s.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.eventStreamKey,
+		MaxLen: s.eventStreamMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to archive visit: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvents 读取[start, end]时间范围内的原始访问事件，最多返回count条
+// Stream条目ID以毫秒时间戳开头，因此可以直接用时间戳作为XRANGE的起止ID
+func (s *StatsService) GetEvents(ctx context.Context, start, end time.Time, count int64) ([]VisitEvent, error) {
+	messages, err := s.redisClient.XRangeN(ctx, s.eventStreamKey,
+		strconv.FormatInt(start.UnixMilli(), 10),
+		strconv.FormatInt(end.UnixMilli(), 10),
+		count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	events := make([]VisitEvent, 0, len(messages))
+	for _, msg := range messages {
+		events = append(events, parseVisitEvent(msg))
+	}
+
+	return events, nil
+}
+
+// parseVisitEvent 将Stream条目转换为访问事件
+func parseVisitEvent(msg redis.XMessage) VisitEvent {
+	event := VisitEvent{ID: msg.ID}
+	for field, raw := range msg.Values {
+		value, _ := raw.(string)
+		switch {
+		case field == "page":
+			event.Page = value
+		case field == "visitor_id":
+			event.VisitorID = value
+		case field == "referrer":
+			event.Referrer = value
+		case field == "ts":
+			ms, _ := strconv.ParseInt(value, 10, 64)
+			event.Timestamp = time.UnixMilli(ms)
+		case strings.HasPrefix(field, dimFieldPrefix):
+			if event.Dims == nil {
+				event.Dims = make(map[string]string)
+			}
+			event.Dims[strings.TrimPrefix(field, dimFieldPrefix)] = value
+		}
+	}
+	return event
+}
@@ -2,42 +2,276 @@ package stats
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
 	"time"
+
+	"uv-pv-collector/internal/config"
+
+	"redis-learning/pkg/eventbus"
+	"scheduler/pkg/scheduler"
+)
+
+var (
+	// ErrRollingDisabled 未启用滚动窗口统计时返回此错误
+	ErrRollingDisabled = errors.New("rolling stats are disabled")
+	// ErrInvalidWindow 滚动窗口长度不合法时返回此错误
+	ErrInvalidWindow = errors.New("invalid rolling window")
+	// ErrArchiveDisabled 未启用原始事件归档时返回此错误
+	ErrArchiveDisabled = errors.New("event archive is disabled")
+	// ErrCollectorClosed 收集器已Flush关闭后继续记录时返回此错误
+	ErrCollectorClosed = errors.New("stats collector is closed")
+	// ErrCompacted 查询的日期早于压缩年龄，每日数据已经或即将被折叠到月度汇总中，无法再按天查询
+	ErrCompacted = errors.New("daily stats have been compacted into monthly stats")
 )
 
 // StatsCollector 统计数据收集器
 // 提供了记录和查询网页访问数据的便捷方法
 type StatsCollector struct {
 	service *StatsService
+	config  *config.Config
+	// 发布页面访问事件的事件总线，未启用时为nil
+	bus *eventbus.Bus
+
+	// 异步记录使用的队列，为nil表示同步记录
+	queue chan Visit
+	// 后台写入协程退出时关闭
+	done chan struct{}
+	// Flush开始时关闭，唤醒因队列已满而阻塞的enqueue，使其释放读锁
+	stopping chan struct{}
+	stopOnce sync.Once
+	// 保护closed，防止向已关闭的队列发送数据
+	mu     sync.RWMutex
+	closed bool
 }
 
 // NewStatsCollector 创建一个新的统计收集器实例
-func NewStatsCollector(service *StatsService) *StatsCollector {
-	return &StatsCollector{
+// 启用异步记录时会同时启动后台写入协程
+func NewStatsCollector(service *StatsService, cfg *config.Config) *StatsCollector {
+	c := &StatsCollector{
 		service: service,
+		config:  cfg,
+	}
+	if cfg.PublishPageAccess {
+		c.bus = eventbus.NewDefaultBus(service.Client(), eventbus.SharedBus, "uv-pv-collector")
 	}
+
+	if cfg.AsyncRecording {
+		size := cfg.RecordBufferSize
+		if size <= 0 {
+			size = 1024
+		}
+		c.queue = make(chan Visit, size)
+		c.done = make(chan struct{})
+		c.stopping = make(chan struct{})
+		go c.runWorker()
+	}
+
+	return c
+}
+
+// Visit 一次页面访问
+type Visit struct {
+	// 页面路径
+	Page string
+	// 访客唯一标识(可以是IP, 用户ID等)
+	VisitorID string
+	// 来源地址，可为空
+	Referrer string
+	// 自定义维度（如设备、国家），只写入原始事件归档
+	Dims map[string]string
 }
 
 // RecordVisit 同时记录一次页面访问的PV和UV
 // page: 页面路径
 // visitorID: 访客唯一标识(可以是IP, 用户ID等)
 func (c *StatsCollector) RecordVisit(ctx context.Context, page, visitorID string) error {
+	return c.Record(ctx, Visit{Page: page, VisitorID: visitorID})
+}
+
+// Record 记录一次页面访问，包括PV、UV以及来源等附加信息
+// 启用异步记录时只把访问放入队列，实际写入由后台协程完成
+func (c *StatsCollector) Record(ctx context.Context, visit Visit) error {
+	if c.queue != nil {
+		return c.enqueue(ctx, visit)
+	}
+	return c.record(ctx, visit)
+}
+
+// enqueue 将访问放入异步记录队列，队列已满时阻塞直到有空位、ctx结束或开始Flush
+func (c *StatsCollector) enqueue(ctx context.Context, visit Visit) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return ErrCollectorClosed
+	}
+
+	select {
+	case c.queue <- visit:
+		return nil
+	case <-c.stopping:
+		return ErrCollectorClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runWorker 后台写入协程，逐个消费队列中的访问直到队列被关闭
+func (c *StatsCollector) runWorker() {
+	defer close(c.done)
+
+	for visit := range c.queue {
+		// 请求的上下文在入队后就可能结束，写入时使用独立的上下文
+		if err := c.record(context.Background(), visit); err != nil {
+			log.Printf("Failed to record buffered visit for page %s: %v", visit.Page, err)
+		}
+	}
+}
+
+// Flush 停止接收新的访问，并等待队列中已有的访问全部写入Redis
+// ctx到期时返回错误，此时仍未写入的访问会丢失。同步记录模式下直接返回
+func (c *StatsCollector) Flush(ctx context.Context) error {
+	if c.queue == nil {
+		return nil
+	}
+
+	// 先唤醒阻塞在已满队列上的enqueue，否则它们持有的读锁会让下面的加锁一直等待
+	c.stopOnce.Do(func() { close(c.stopping) })
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flush interrupted with %d visits pending: %w", len(c.queue), ctx.Err())
+	}
+}
+
+// record 同步记录一次页面访问
+func (c *StatsCollector) record(ctx context.Context, visit Visit) error {
+	page, visitorID := visit.Page, visit.VisitorID
+
+	countPV, err := c.shouldCountPageView(ctx, page, visitorID)
+	if err != nil {
+		return err
+	}
+
 	// 记录PV
-	if err := c.service.RecordPageView(ctx, page); err != nil {
-		return fmt.Errorf("failed to record page view: %w", err)
+	if countPV {
+		if err := c.service.RecordPageView(ctx, page); err != nil {
+			return fmt.Errorf("failed to record page view: %w", err)
+		}
 	}
 
-	// 记录UV
-	if err := c.service.RecordUniqueVisitor(ctx, page, visitorID); err != nil {
+	// 记录UV，重复访问和未被采样的访问同样需要记录，保证UV准确
+	isNewVisitor, err := c.service.RecordUniqueVisitor(ctx, page, visitorID)
+	if err != nil {
 		return fmt.Errorf("failed to record unique visitor: %w", err)
 	}
 
+	// 更新页面索引，供页面列表和排行榜查询使用
+	if err := c.service.RecordPageIndex(ctx, page, countPV, isNewVisitor); err != nil {
+		return fmt.Errorf("failed to update page index: %w", err)
+	}
+
+	// 记录来源域名
+	if domain := NormalizeReferrer(visit.Referrer); domain != "" {
+		if err := c.service.RecordReferrer(ctx, page, domain); err != nil {
+			return fmt.Errorf("failed to record referrer: %w", err)
+		}
+	}
+
+	// 记录全站汇总数据
+	if err := c.service.RecordSiteVisit(ctx, visitorID, countPV); err != nil {
+		return fmt.Errorf("failed to record site visit: %w", err)
+	}
+
+	// 检测全站新访客
+	if c.config.EnableNewVisitorDetection {
+		if _, err := c.service.RecordNewVisitor(ctx, visitorID); err != nil {
+			return fmt.Errorf("failed to record new visitor: %w", err)
+		}
+	}
+
+	// 记录小时桶，供滚动窗口统计使用
+	if c.config.EnableRollingStats {
+		if err := c.service.RecordHourlyVisit(ctx, page, visitorID, countPV); err != nil {
+			return fmt.Errorf("failed to record hourly visit: %w", err)
+		}
+	}
+
+	// 更新访客画像
+	if c.config.EnableVisitorProfiles {
+		if err := c.service.UpdateVisitorProfile(ctx, visitorID, page); err != nil {
+			return fmt.Errorf("failed to update visitor profile: %w", err)
+		}
+	}
+
+	// 归档原始事件
+	if c.config.EnableEventArchive {
+		if err := c.service.ArchiveVisit(ctx, visit, time.Now()); err != nil {
+			return fmt.Errorf("failed to archive visit: %w", err)
+		}
+	}
+
+	// 发布页面访问事件。统计已经写入，发布失败时只记录日志，不让调用方重试导致重复计数
+	if c.bus != nil {
+		_, err := c.bus.Publish(ctx, eventbus.TopicPageAccess, eventbus.PageAccess{
+			Page:      page,
+			VisitorID: visitorID,
+			Referrer:  visit.Referrer,
+			Time:      time.Now(),
+		})
+		if err != nil {
+			log.Printf("Failed to publish page access event for page %s: %v", page, err)
+		}
+	}
+
 	return nil
 }
 
+// shouldCountPageView 判断本次访问是否需要累加PV计数器
+// 去重窗口内的重复访问不计入PV；启用采样时只有被采中的访问才会累加，读取时再按采样率放大
+func (c *StatsCollector) shouldCountPageView(ctx context.Context, page, visitorID string) (bool, error) {
+	if c.config.DedupWindow > 0 {
+		first, err := c.service.AcquireDedupGate(ctx, page, visitorID, c.config.DedupWindow)
+		if err != nil {
+			return false, fmt.Errorf("failed to check duplicate visit: %w", err)
+		}
+		if !first {
+			return false, nil
+		}
+	}
+
+	return c.shouldSamplePageView(), nil
+}
+
+// shouldSamplePageView 判断本次访问是否需要累加PV计数器
+// 采样率为N时每次访问以1/N的概率被采中，UV始终精确记录
+func (c *StatsCollector) shouldSamplePageView() bool {
+	rate := c.config.PageViewSampleRate
+	if rate <= 1 {
+		return true
+	}
+	return rand.IntN(rate) == 0
+}
+
 // GetDailyStats 获取指定页面某一天的PV和UV统计数据
+// 日期早于压缩年龄时返回 ErrCompacted，应改用 GetMonthlyStats 查询
 func (c *StatsCollector) GetDailyStats(ctx context.Context, page, date string) (pv, uv int64, err error) {
+	if c.isCompacted(date) {
+		return 0, 0, fmt.Errorf("%w: %s", ErrCompacted, date)
+	}
+
 	pv, err = c.service.GetPageViews(ctx, page, date)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get page views: %w", err)
@@ -59,6 +293,7 @@ func (c *StatsCollector) GetTodayStats(ctx context.Context, page string) (pv, uv
 
 // GetStatsForDateRange 获取指定页面在日期范围内的累计PV和UV
 // startDate和endDate格式为"2006-01-02"
+// 早于压缩年龄的日期按月读取月度汇总，范围必须包含这些月份中早于压缩年龄的每一天，否则返回 ErrCompacted
 func (c *StatsCollector) GetStatsForDateRange(ctx context.Context, page, startDate, endDate string) (totalPV, totalUV int64, err error) {
 	start, err := time.Parse("2006-01-02", startDate)
 	if err != nil {
@@ -70,6 +305,26 @@ func (c *StatsCollector) GetStatsForDateRange(ctx context.Context, page, startDa
 		return 0, 0, fmt.Errorf("invalid end date format: %w", err)
 	}
 
+	months, err := c.compactedMonths(start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// 月度汇总只包含已折叠的日期，尚未折叠的日期仍在每日键中，两者相加不会重复
+	for _, month := range months {
+		pv, err := c.service.GetPageViews(ctx, page, month)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get page views for %s: %w", month, err)
+		}
+		totalPV += pv
+
+		uv, err := c.service.GetUniqueVisitors(ctx, page, month)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get unique visitors for %s: %w", month, err)
+		}
+		totalUV += uv
+	}
+
 	// 收集日期范围内的PV总和
 	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
 		date := d.Format("2006-01-02")
@@ -93,3 +348,214 @@ func (c *StatsCollector) GetStatsForDateRange(ctx context.Context, page, startDa
 
 	return totalPV, totalUV, nil
 }
+
+// compactionCutoff 返回压缩的截止时间，早于该时间的每日数据会被折叠到月度汇总中；未启用压缩时ok为false
+func (c *StatsCollector) compactionCutoff() (cutoff time.Time, ok bool) {
+	if c.config.CompactionAge <= 0 {
+		return time.Time{}, false
+	}
+
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	return today.Add(-c.config.CompactionAge), true
+}
+
+// isCompacted 判断日期是否早于压缩年龄，格式不合法时返回false，由后续的查询报告
+func (c *StatsCollector) isCompacted(date string) bool {
+	cutoff, ok := c.compactionCutoff()
+	if !ok {
+		return false
+	}
+	day, err := time.Parse("2006-01-02", date)
+	return err == nil && day.Before(cutoff)
+}
+
+// compactedMonths 返回[start, end]中含有早于压缩年龄的日期的月份
+// 月度汇总无法拆分到天，这些月份中早于压缩年龄的日期必须全部落在范围内，否则返回 ErrCompacted
+func (c *StatsCollector) compactedMonths(start, end time.Time) ([]string, error) {
+	cutoff, ok := c.compactionCutoff()
+	if !ok || !start.Before(cutoff) {
+		return nil, nil
+	}
+
+	// 最后一个被压缩的日期
+	lastCompacted := cutoff.Add(-time.Nanosecond).Truncate(24 * time.Hour)
+	var months []string
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for ; !first.After(end) && first.Before(cutoff); first = first.AddDate(0, 1, 0) {
+		last := first.AddDate(0, 1, -1)
+		if last.After(lastCompacted) {
+			last = lastCompacted
+		}
+		if start.After(first) || end.Before(last) {
+			return nil, fmt.Errorf("%w: range must cover %s to %s", ErrCompacted,
+				first.Format("2006-01-02"), last.Format("2006-01-02"))
+		}
+		months = append(months, first.Format(monthLayout))
+	}
+
+	return months, nil
+}
+
+// GetSiteStats 获取全站在日期范围内每天的PV和UV，以及范围内的总PV和去重后的总UV
+// startDate和endDate格式为"2006-01-02"
+func (c *StatsCollector) GetSiteStats(ctx context.Context, startDate, endDate string) (days []DailyStats, totalPV, totalUV int64, err error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid start date format: %w", err)
+	}
+
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid end date format: %w", err)
+	}
+
+	var dates []string
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		pv, uv, err := c.service.GetSiteDailyStats(ctx, date)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to get site stats for %s: %w", date, err)
+		}
+		days = append(days, DailyStats{Date: date, PageViews: pv, UniqueVisitors: uv})
+		dates = append(dates, date)
+		totalPV += pv
+	}
+
+	// 全站UV通过合并各天的HyperLogLog计算，不会重复计数跨天访客
+	totalUV, err = c.service.GetSiteUniqueVisitorsUnion(ctx, dates)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return days, totalPV, totalUV, nil
+}
+
+// GetVisitorProfile 获取访客画像，不存在时返回 ErrVisitorNotFound
+func (c *StatsCollector) GetVisitorProfile(ctx context.Context, visitorID string) (*VisitorProfile, error) {
+	return c.service.GetVisitorProfile(ctx, visitorID)
+}
+
+// GetNewVisitors 获取全站在指定日期的新访客数，以及布隆过滤器中已记录访客总数的估计值
+func (c *StatsCollector) GetNewVisitors(ctx context.Context, date string) (newVisitors int64, known uint64, err error) {
+	if !c.config.EnableNewVisitorDetection {
+		return 0, 0, ErrNewVisitorDisabled
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return 0, 0, fmt.Errorf("invalid date format: %w", err)
+	}
+
+	newVisitors, err = c.service.GetNewVisitors(ctx, date)
+	if err != nil {
+		return 0, 0, err
+	}
+	known, err = c.service.GetKnownVisitors(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return newVisitors, known, nil
+}
+
+// ListPages 分页获取页面列表及其在指定日期的PV和UV
+func (c *StatsCollector) ListPages(ctx context.Context, date string, query PageQuery) (*PageList, error) {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, fmt.Errorf("invalid date format: %w", err)
+	}
+	if c.isCompacted(date) {
+		return nil, fmt.Errorf("%w: %s", ErrCompacted, date)
+	}
+	return c.service.ListPages(ctx, date, query)
+}
+
+// GetTopReferrers 获取页面在指定日期的主要来源域名，page为空时返回全站来源
+func (c *StatsCollector) GetTopReferrers(ctx context.Context, page, date string, limit int64) ([]ReferrerStats, error) {
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+	return c.service.GetTopReferrers(ctx, page, date, limit)
+}
+
+// GetMonthlyStats 获取指定页面某个月的PV和UV，month格式为"2006-01"
+// 已被压缩的日期通过月度汇总键查询，未压缩的日期直接读取每日键
+func (c *StatsCollector) GetMonthlyStats(ctx context.Context, page, month string) (pv, uv int64, err error) {
+	return c.service.GetMonthlyStats(ctx, page, month)
+}
+
+// Compact 执行一次压缩，将早于CompactionAge的每日数据折叠到月度汇总中
+func (c *StatsCollector) Compact(ctx context.Context) (int, error) {
+	cutoff, ok := c.compactionCutoff()
+	if !ok {
+		return 0, nil
+	}
+	return c.service.CompactBefore(ctx, cutoff)
+}
+
+// RegisterJobs 向调度器注册后台任务：按CompactionSchedule执行压缩
+// 多个实例部署时只有调度器的主节点执行，停机错过的压缩在恢复后补执行一次
+func (c *StatsCollector) RegisterJobs(s *scheduler.Scheduler) error {
+	if c.config.CompactionAge <= 0 || c.config.CompactionSchedule == "" {
+		return nil
+	}
+
+	return s.Register(scheduler.Job{
+		Name:      "compaction",
+		Schedule:  c.config.CompactionSchedule,
+		RunMissed: true,
+		Run: func(ctx context.Context) error {
+			n, err := c.Compact(ctx)
+			if err != nil {
+				return fmt.Errorf("compaction failed after %d days: %w", n, err)
+			}
+			if n > 0 {
+				log.Printf("Compacted %d daily stats into monthly rollups", n)
+			}
+			return nil
+		},
+	})
+}
+
+// GetEvents 读取一段时间内归档的原始访问事件
+func (c *StatsCollector) GetEvents(ctx context.Context, start, end time.Time, count int64) ([]VisitEvent, error) {
+	if !c.config.EnableEventArchive {
+		return nil, ErrArchiveDisabled
+	}
+	if count <= 0 || count > MaxEventCount {
+		count = MaxEventCount
+	}
+	return c.service.GetEvents(ctx, start, end, count)
+}
+
+// RecordGoal 记录访客完成了一次目标（例如注册、下单），page可为空
+func (c *StatsCollector) RecordGoal(ctx context.Context, goal, page, visitorID string) error {
+	return c.service.RecordGoal(ctx, goal, page, visitorID, c.config.EnableVisitorProfiles)
+}
+
+// GetGoalStats 获取目标在指定日期的转化数据，page为空时统计全站
+// 指定页面且日期早于压缩年龄时返回 ErrCompacted，页面UV已无法按天读取
+func (c *StatsCollector) GetGoalStats(ctx context.Context, goal, page, date string) (*GoalStats, error) {
+	if page != "" && c.isCompacted(date) {
+		return nil, fmt.Errorf("%w: %s", ErrCompacted, date)
+	}
+	return c.service.GetGoalStats(ctx, goal, page, date)
+}
+
+// ListGoals 返回所有记录过的目标名称
+func (c *StatsCollector) ListGoals(ctx context.Context) ([]string, error) {
+	return c.service.ListGoals(ctx)
+}
+
+// GetRollingStats 获取指定页面最近一段时间内的PV和UV
+// 窗口按小时向上取整，包含当前尚未结束的小时
+func (c *StatsCollector) GetRollingStats(ctx context.Context, page string, window time.Duration) (pv, uv int64, err error) {
+	if !c.config.EnableRollingStats {
+		return 0, 0, ErrRollingDisabled
+	}
+	if window <= 0 || window > c.config.RollingRetention {
+		return 0, 0, fmt.Errorf("%w: %v must be positive and at most %v", ErrInvalidWindow, window, c.config.RollingRetention)
+	}
+
+	hours := int((window + time.Hour - 1) / time.Hour)
+	return c.service.GetRollingStats(ctx, page, time.Now(), hours)
+}
@@ -0,0 +1,160 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// monthLayout 月度汇总键名中使用的时间格式
+const monthLayout = "2006-01"
+
+// compactDayScript 将某页面一天的数据折叠到月度汇总中
+// PV累加到月度计数器，HyperLogLog和精确UV集合合并到月度HyperLogLog，最后UNLINK每日键
+// 整个过程在脚本中原子执行，同一天的数据不会被重复折叠
+// KEYS[1]: pv:{page}:{date}
+// KEYS[2]: uv:{page}:{date}
+// KEYS[3]: uvset:{page}:{date}
+// KEYS[4]: pv:{page}:{month}
+// KEYS[5]: uv:{page}:{month}
+var compactDayScript = scripts.Register("compact-day", `
+local pv = redis.call('GET', KEYS[1])
+if pv then
+	redis.call('INCRBY', KEYS[4], pv)
+end
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	redis.call('PFMERGE', KEYS[5], KEYS[2])
+end
+local members = redis.call('SMEMBERS', KEYS[3])
+for i = 1, #members, 1000 do
+	redis.call('PFADD', KEYS[5], unpack(members, i, math.min(i + 999, #members)))
+end
+redis.call('UNLINK', KEYS[1], KEYS[2], KEYS[3])
+return 1
+`)
+
+// countUVUnionScript 计算多个HyperLogLog与精确UV集合并集的基数
+// KEYS[1]: 合并使用的临时键
+// KEYS[2..ARGV[1]+1]: HyperLogLog键
+// 其余KEYS: 精确UV集合键
+var countUVUnionScript = scripts.Register("count-uv-union", `
+local hllCount = tonumber(ARGV[1])
+for i = 2, hllCount + 1 do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		redis.call('PFMERGE', KEYS[1], KEYS[i])
+	end
+end
+for i = hllCount + 2, #KEYS do
+	local members = redis.call('SMEMBERS', KEYS[i])
+	for j = 1, #members, 1000 do
+		redis.call('PFADD', KEYS[1], unpack(members, j, math.min(j + 999, #members)))
+	end
+end
+local count = redis.call('PFCOUNT', KEYS[1])
+redis.call('DEL', KEYS[1])
+return count
+`)
+
+// CompactBefore 折叠所有早于cutoff日期的每日数据，返回折叠的(页面, 日期)数量
+// 通过SCAN遍历pv、uv和uvset前缀的键，从键名中解析出页面和日期
+func (s *StatsService) CompactBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	type pageDay struct {
+		page string
+		date string
+	}
+
+	days := make(map[pageDay]struct{})
+	for _, prefix := range []string{"pv:", "uv:", "uvset:"} {
+		iter := s.redisClient.Scan(ctx, 0, prefix+"*", 1000).Iterator()
+		for iter.Next(ctx) {
+			page, date, ok := parseDailyKey(iter.Val(), prefix)
+			if !ok {
+				continue
+			}
+			day, err := time.Parse("2006-01-02", date)
+			if err != nil || !day.Before(cutoff) {
+				continue
+			}
+			days[pageDay{page: page, date: date}] = struct{}{}
+		}
+		if err := iter.Err(); err != nil {
+			return 0, fmt.Errorf("failed to scan daily keys: %w", err)
+		}
+	}
+
+	compacted := 0
+	for d := range days {
+		if err := s.compactDay(ctx, d.page, d.date); err != nil {
+			return compacted, err
+		}
+		compacted++
+	}
+
+	return compacted, nil
+}
+
+// compactDay 将页面一天的数据折叠到所属月份的汇总键中
+func (s *StatsService) compactDay(ctx context.Context, page, date string) error {
+	month := date[:len(monthLayout)]
+	keys := []string{
+		fmt.Sprintf("pv:%s:%s", page, date),
+		fmt.Sprintf("uv:%s:%s", page, date),
+		fmt.Sprintf("uvset:%s:%s", page, date),
+		fmt.Sprintf("pv:%s:%s", page, month),
+		fmt.Sprintf("uv:%s:%s", page, month),
+	}
+
+	if err := compactDayScript.Run(ctx, s.redisClient, keys).Err(); err != nil {
+		return fmt.Errorf("failed to compact %s on %s: %w", page, date, err)
+	}
+
+	return nil
+}
+
+// GetMonthlyStats 获取页面某个月的PV和UV
+// 结果由月度汇总键与该月尚未折叠的每日键共同组成，因此无论数据是否已被压缩都能查询
+func (s *StatsService) GetMonthlyStats(ctx context.Context, page, month string) (pv, uv int64, err error) {
+	start, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid month format: %w", err)
+	}
+
+	// 月度汇总中的PV
+	pv, err = s.GetPageViews(ctx, page, month)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	hllKeys := []string{fmt.Sprintf("uv:%s:%s", page, month)}
+	var setKeys []string
+	for d := start; d.Month() == start.Month(); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		dailyPV, err := s.GetPageViews(ctx, page, date)
+		if err != nil {
+			return 0, 0, err
+		}
+		pv += dailyPV
+		hllKeys = append(hllKeys, fmt.Sprintf("uv:%s:%s", page, date))
+		setKeys = append(setKeys, fmt.Sprintf("uvset:%s:%s", page, date))
+	}
+
+	keys := append([]string{fmt.Sprintf("uv:tmp:%s:%s", page, month)}, hllKeys...)
+	keys = append(keys, setKeys...)
+	uv, err = countUVUnionScript.Run(ctx, s.redisClient, keys, len(hllKeys)).Int64()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count monthly unique visitors: %w", err)
+	}
+
+	return pv, uv, nil
+}
+
+// parseDailyKey 从形如 {prefix}{page}:{date} 的每日键中解析出页面和日期
+func parseDailyKey(key, prefix string) (page, date string, ok bool) {
+	const dateLen = len("2006-01-02")
+	rest := strings.TrimPrefix(key, prefix)
+	if len(rest) < dateLen+2 || rest[len(rest)-dateLen-1] != ':' {
+		return "", "", false
+	}
+	return rest[:len(rest)-dateLen-1], rest[len(rest)-dateLen:], true
+}
@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+)
+
+// recordExactUVScript 记录访客，访客数不超过阈值时使用SET精确计数
+// 集合基数超过阈值后，将所有成员写入HyperLogLog并删除集合，此后该页面当天只写HyperLogLog
+// KEYS[1]: 精确UV集合 uvset:{page}:{date}
+// KEYS[2]: HyperLogLog uv:{page}:{date}
+// ARGV[1]: 访客ID
+// ARGV[2]: 转换阈值
+var recordExactUVScript = scripts.Register("record-exact-uv", `
+if redis.call('EXISTS', KEYS[1]) == 0 and redis.call('EXISTS', KEYS[2]) == 1 then
+	return redis.call('PFADD', KEYS[2], ARGV[1])
+end
+local added = redis.call('SADD', KEYS[1], ARGV[1])
+if redis.call('SCARD', KEYS[1]) > tonumber(ARGV[2]) then
+	local members = redis.call('SMEMBERS', KEYS[1])
+	for i = 1, #members, 1000 do
+		redis.call('PFADD', KEYS[2], unpack(members, i, math.min(i + 999, #members)))
+	end
+	redis.call('DEL', KEYS[1])
+end
+return added
+`)
+
+// countUVScript 统计访客数
+// 只有集合时返回精确值，只有HyperLogLog时返回估计值
+// 两者同时存在（例如运行中关闭了精确计数）时，合并到临时键后计数
+// KEYS[1]: 精确UV集合
+// KEYS[2]: HyperLogLog
+// KEYS[3]: 合并使用的临时键
+var countUVScript = scripts.Register("count-uv", `
+local n = redis.call('SCARD', KEYS[1])
+if n == 0 then
+	return redis.call('PFCOUNT', KEYS[2])
+end
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	return n
+end
+redis.call('PFMERGE', KEYS[3], KEYS[2])
+local members = redis.call('SMEMBERS', KEYS[1])
+for i = 1, #members, 1000 do
+	redis.call('PFADD', KEYS[3], unpack(members, i, math.min(i + 999, #members)))
+end
+local count = redis.call('PFCOUNT', KEYS[3])
+redis.call('DEL', KEYS[3])
+return count
+`)
+
+// recordExactUniqueVisitor 以精确优先的方式记录唯一访客，返回是否为新访客
+func (s *StatsService) recordExactUniqueVisitor(ctx context.Context, page, date, visitorID string) (bool, error) {
+	setKey := fmt.Sprintf("uvset:%s:%s", page, date)
+	hllKey := fmt.Sprintf("uv:%s:%s", page, date)
+
+	added, err := recordExactUVScript.Run(ctx, s.redisClient, []string{setKey, hllKey}, visitorID, s.exactUVThreshold).Int64()
+	if err != nil {
+		return false, fmt.Errorf("failed to record exact unique visitor: %w", err)
+	}
+
+	return added == 1, nil
+}
+
+// countUniqueVisitors 统计页面某天的访客数，兼容精确集合与HyperLogLog两种存储
+func (s *StatsService) countUniqueVisitors(ctx context.Context, page, date string) (int64, error) {
+	setKey := fmt.Sprintf("uvset:%s:%s", page, date)
+	hllKey := fmt.Sprintf("uv:%s:%s", page, date)
+	tmpKey := fmt.Sprintf("uv:tmp:%s:%s", page, date)
+
+	val, err := countUVScript.Run(ctx, s.redisClient, []string{setKey, hllKey, tmpKey}).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unique visitors: %w", err)
+	}
+
+	return val, nil
+}
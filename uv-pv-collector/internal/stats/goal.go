@@ -0,0 +1,134 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// GoalStats 某个目标在某天的转化数据
+type GoalStats struct {
+	Goal string `json:"goal"`
+	Page string `json:"page,omitempty"`
+	Date string `json:"date"`
+	// 目标完成次数
+	Completions int64 `json:"completions"`
+	// 完成目标的去重访客数
+	Converters int64 `json:"converters"`
+	// 同一范围（页面或全站）当天的UV
+	UniqueVisitors int64 `json:"unique_visitors"`
+	// 转化率 = 完成目标的访客数 / UV
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// incrProfileGoalScript 只在访客画像已存在时累加目标完成次数
+// 直接HINCRBY会为没有访问记录的访客创建只有goal字段的画像，且不会设置过期时间
+// 在管道中以 EVALSHA 执行，依赖服务启动时加载的脚本包
+// KEYS[1]: 访客画像 visitor:{id}
+// ARGV[1]: 目标字段 goal:{goal}
+var incrProfileGoalScript = scripts.Register("incr-profile-goal", `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+end
+return 0
+`)
+
+// goalKeys 返回目标完成次数计数器和完成访客HyperLogLog的键名，page为空表示全站
+func goalKeys(goal, page, date string) (countKey, visitorsKey string) {
+	if page == "" {
+		return fmt.Sprintf("goal:%s:%s", goal, date), fmt.Sprintf("goalv:%s:%s", goal, date)
+	}
+	return fmt.Sprintf("goal:%s:%s:%s", goal, page, date), fmt.Sprintf("goalv:%s:%s:%s", goal, page, date)
+}
+
+// RecordGoal 记录一次目标完成
+// 全站维度总是记录，提供page时额外记录页面维度；启用访客画像时同时累加访客的目标完成次数，画像不存在时不创建
+func (s *StatsService) RecordGoal(ctx context.Context, goal, page, visitorID string, updateProfile bool) error {
+	date := time.Now().Format("2006-01-02")
+	profileKeys := []string{fmt.Sprintf("visitor:%s", visitorID)}
+
+	var profileCmd *redis.Cmd
+	cmds, _ := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, "goals", goal)
+
+		countKey, visitorsKey := goalKeys(goal, "", date)
+		pipe.Incr(ctx, countKey)
+		pipe.PFAdd(ctx, visitorsKey, visitorID)
+
+		if page != "" {
+			countKey, visitorsKey = goalKeys(goal, page, date)
+			pipe.Incr(ctx, countKey)
+			pipe.PFAdd(ctx, visitorsKey, visitorID)
+		}
+
+		if updateProfile {
+			profileCmd = incrProfileGoalScript.Run(ctx, pipe, profileKeys, "goal:"+goal)
+		}
+		return nil
+	})
+	for _, cmd := range cmds {
+		// Redis重启或执行了 SCRIPT FLUSH 后管道中的脚本返回 NOSCRIPT，其余命令已经执行，只单独重试脚本
+		if cmd == profileCmd && redis.HasErrorPrefix(cmd.Err(), "NOSCRIPT") {
+			if err := incrProfileGoalScript.Run(ctx, s.redisClient, profileKeys, "goal:"+goal).Err(); err != nil {
+				return fmt.Errorf("failed to record goal on visitor profile: %w", err)
+			}
+			continue
+		}
+		if err := cmd.Err(); err != nil {
+			return fmt.Errorf("failed to record goal: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetGoalStats 获取目标在指定日期的完成次数、完成访客数和转化率
+// page为空时以全站UV为分母，否则以该页面的UV为分母
+func (s *StatsService) GetGoalStats(ctx context.Context, goal, page, date string) (*GoalStats, error) {
+	countKey, visitorsKey := goalKeys(goal, page, date)
+
+	completions, err := s.redisClient.Get(ctx, countKey).Int64()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get goal completions: %w", err)
+	}
+
+	converters, err := s.redisClient.PFCount(ctx, visitorsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goal converters: %w", err)
+	}
+
+	var uv int64
+	if page == "" {
+		_, uv, err = s.GetSiteDailyStats(ctx, date)
+	} else {
+		uv, err = s.GetUniqueVisitors(ctx, page, date)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &GoalStats{
+		Goal:           goal,
+		Page:           page,
+		Date:           date,
+		Completions:    completions,
+		Converters:     converters,
+		UniqueVisitors: uv,
+	}
+	if uv > 0 {
+		stats.ConversionRate = float64(converters) / float64(uv)
+	}
+
+	return stats, nil
+}
+
+// ListGoals 返回所有记录过的目标名称
+func (s *StatsService) ListGoals(ctx context.Context) ([]string, error) {
+	goals, err := s.redisClient.SMembers(ctx, "goals").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	return goals, nil
+}
@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNewVisitorDisabled 未启用新访客检测时返回此错误
+var ErrNewVisitorDisabled = errors.New("new visitor detection is disabled")
+
+// RecordNewVisitor 判断访客是否第一次访问全站，是则累加当天的新访客数 newvisitors:{date}
+// 出现过的访客记录在布隆过滤器中，只占用固定的空间，不需要像访客画像那样为每个访客保存一个key。
+// 布隆过滤器不会漏判老访客，新访客按误判率被当作老访客，因此新访客数略微偏低但不会重复计数
+func (s *StatsService) RecordNewVisitor(ctx context.Context, visitorID string) (bool, error) {
+	if s.visitorFilter == nil {
+		return false, ErrNewVisitorDisabled
+	}
+
+	isNew, err := s.visitorFilter.AddNew(ctx, visitorID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check new visitor: %w", err)
+	}
+	if !isNew[0] {
+		return false, nil
+	}
+
+	key := fmt.Sprintf("newvisitors:%s", time.Now().Format("2006-01-02"))
+	if err := s.redisClient.Incr(ctx, key).Err(); err != nil {
+		return true, fmt.Errorf("failed to record new visitor: %w", err)
+	}
+	return true, nil
+}
+
+// GetNewVisitors 获取全站在指定日期的新访客数
+func (s *StatsService) GetNewVisitors(ctx context.Context, date string) (int64, error) {
+	if s.visitorFilter == nil {
+		return 0, ErrNewVisitorDisabled
+	}
+
+	val, err := s.redisClient.Get(ctx, fmt.Sprintf("newvisitors:%s", date)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get new visitors: %w", err)
+	}
+	return val, nil
+}
+
+// GetKnownVisitors 估计布隆过滤器中已经记录的访客总数
+func (s *StatsService) GetKnownVisitors(ctx context.Context) (uint64, error) {
+	if s.visitorFilter == nil {
+		return 0, ErrNewVisitorDisabled
+	}
+	return s.visitorFilter.ApproximateCount(ctx)
+}
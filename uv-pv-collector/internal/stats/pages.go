@@ -0,0 +1,148 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 页面列表的排序方式
+const (
+	SortByPageViews      = "pv"
+	SortByUniqueVisitors = "uv"
+	SortByName           = "name"
+)
+
+// 分页参数的默认值和上限
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// ErrInvalidSort 排序方式不合法时返回此错误
+var ErrInvalidSort = errors.New("invalid sort option")
+
+// PageQuery 页面列表查询参数
+type PageQuery struct {
+	// 排序方式：pv、uv或name
+	Sort string
+	// 跳过的条目数
+	Offset int64
+	// 返回的最大条目数
+	Limit int64
+	// 按名称排序时的游标，返回名称严格大于该值的页面，优先于Offset
+	Cursor string
+}
+
+// PageStats 单个页面在某天的统计数据
+type PageStats struct {
+	Page           string `json:"page"`
+	PageViews      int64  `json:"page_views"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// PageList 页面列表的分页结果
+type PageList struct {
+	Pages []PageStats `json:"pages"`
+	// 可排序的页面总数
+	Total int64 `json:"total"`
+	// 按名称排序时下一页的游标，为空表示没有更多数据
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// RecordPageIndex 更新页面索引
+// pages:all 保存所有出现过的页面（分值均为0，用于按字典序分页）
+// pages:pv:{date} 和 pages:uv:{date} 分别以当天的PV和新访客数为分值，用于排行
+func (s *StatsService) RecordPageIndex(ctx context.Context, page string, countPV, isNewVisitor bool) error {
+	date := time.Now().Format("2006-01-02")
+
+	_, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAddNX(ctx, "pages:all", redis.Z{Score: 0, Member: page})
+		if countPV {
+			pipe.ZIncrBy(ctx, fmt.Sprintf("pages:pv:%s", date), 1, page)
+		}
+		if isNewVisitor {
+			pipe.ZIncrBy(ctx, fmt.Sprintf("pages:uv:%s", date), 1, page)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record page index: %w", err)
+	}
+
+	return nil
+}
+
+// ListPages 按指定方式排序并分页返回页面在某天的统计数据
+// 按PV或UV排序时使用ZREVRANGEBYSCORE的LIMIT按分值倒序取一页；按名称排序时所有分值都为0，
+// 使用ZRANGEBYLEX按字典序取一页，支持游标翻页。ZSCAN不保证顺序，不用于分页
+func (s *StatsService) ListPages(ctx context.Context, date string, query PageQuery) (*PageList, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultPageLimit
+	}
+	if query.Limit > MaxPageLimit {
+		query.Limit = MaxPageLimit
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	var (
+		pages []string
+		total int64
+		list  = &PageList{}
+		err   error
+	)
+
+	switch query.Sort {
+	case SortByPageViews, SortByUniqueVisitors:
+		key := fmt.Sprintf("pages:%s:%s", query.Sort, date)
+		total, err = s.redisClient.ZCard(ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count pages: %w", err)
+		}
+		rangeBy := &redis.ZRangeBy{Min: "-inf", Max: "+inf", Offset: query.Offset, Count: query.Limit}
+		pages, err = s.redisClient.ZRevRangeByScore(ctx, key, rangeBy).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pages: %w", err)
+		}
+	case SortByName, "":
+		total, err = s.redisClient.ZCard(ctx, "pages:all").Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count pages: %w", err)
+		}
+		rangeBy := &redis.ZRangeBy{Min: "-", Max: "+", Offset: query.Offset, Count: query.Limit}
+		if query.Cursor != "" {
+			rangeBy.Min = "(" + query.Cursor
+			rangeBy.Offset = 0
+		}
+		pages, err = s.redisClient.ZRangeByLex(ctx, "pages:all", rangeBy).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pages: %w", err)
+		}
+		if int64(len(pages)) == query.Limit {
+			list.NextCursor = pages[len(pages)-1]
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSort, query.Sort)
+	}
+
+	list.Total = total
+	list.Pages = make([]PageStats, 0, len(pages))
+	for _, page := range pages {
+		pv, err := s.GetPageViews(ctx, page, date)
+		if err != nil {
+			return nil, err
+		}
+		uv, err := s.GetUniqueVisitors(ctx, page, date)
+		if err != nil {
+			return nil, err
+		}
+		list.Pages = append(list.Pages, PageStats{Page: page, PageViews: pv, UniqueVisitors: uv})
+	}
+
+	return list, nil
+}
@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ReferrerStats 来源域名及其带来的访问次数
+type ReferrerStats struct {
+	Domain string `json:"domain"`
+	Visits int64  `json:"visits"`
+}
+
+// NormalizeReferrer 将来源地址规范化为域名
+// 去掉协议、路径、端口和www前缀并转为小写，无法解析时返回空字符串
+func NormalizeReferrer(referrer string) string {
+	referrer = strings.TrimSpace(referrer)
+	if referrer == "" {
+		return ""
+	}
+	if !strings.Contains(referrer, "://") {
+		referrer = "http://" + referrer
+	}
+
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	return strings.TrimPrefix(host, "www.")
+}
+
+// RecordReferrer 记录一次来自指定域名的访问
+// 页面和全站的来源分别保存在有序集合 ref:{page}:{date} 和 site:ref:{date} 中
+func (s *StatsService) RecordReferrer(ctx context.Context, page, domain string) error {
+	date := time.Now().Format("2006-01-02")
+
+	_, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZIncrBy(ctx, fmt.Sprintf("ref:%s:%s", page, date), 1, domain)
+		pipe.ZIncrBy(ctx, siteReferrerKey(date), 1, domain)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record referrer: %w", err)
+	}
+
+	return nil
+}
+
+// GetTopReferrers 获取页面在指定日期带来访问最多的来源域名，page为空时返回全站来源
+func (s *StatsService) GetTopReferrers(ctx context.Context, page, date string, limit int64) ([]ReferrerStats, error) {
+	key := siteReferrerKey(date)
+	if page != "" {
+		key = fmt.Sprintf("ref:%s:%s", page, date)
+	}
+
+	members, err := s.redisClient.ZRevRangeWithScores(ctx, key, 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top referrers: %w", err)
+	}
+
+	referrers := make([]ReferrerStats, 0, len(members))
+	for _, m := range members {
+		domain, _ := m.Member.(string)
+		referrers = append(referrers, ReferrerStats{Domain: domain, Visits: int64(m.Score)})
+	}
+
+	return referrers, nil
+}
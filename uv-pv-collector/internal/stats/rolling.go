@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// hourLayout 小时桶键名中使用的时间格式
+const hourLayout = "2006-01-02-15"
+
+// RecordHourlyVisit 在当前小时桶中记录一次访问的PV和UV
+// 小时桶带有过期时间，超过保留时长后由Redis自动清理
+// countPV为false时只记录UV（该次访问未被PV采样命中）
+func (s *StatsService) RecordHourlyVisit(ctx context.Context, page, visitorID string, countPV bool) error {
+	hour := time.Now().Format(hourLayout)
+	pvKey := fmt.Sprintf("pvh:%s:%s", page, hour)
+	uvKey := fmt.Sprintf("uvh:%s:%s", page, hour)
+
+	// 使用事务管道，保证计数和过期时间一起写入
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if countPV {
+			pipe.Incr(ctx, pvKey)
+			pipe.Expire(ctx, pvKey, s.rollingRetention)
+		}
+		pipe.PFAdd(ctx, uvKey, visitorID)
+		pipe.Expire(ctx, uvKey, s.rollingRetention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record hourly visit: %w", err)
+	}
+
+	return nil
+}
+
+// GetRollingStats 获取截至end的最近hours个小时桶的PV和UV
+// PV为各小时计数之和，UV通过PFMERGE合并各小时的HyperLogLog后计算，不会重复计数
+func (s *StatsService) GetRollingStats(ctx context.Context, page string, end time.Time, hours int) (pv, uv int64, err error) {
+	pvKeys := make([]string, 0, hours)
+	uvKeys := make([]string, 0, hours)
+	for i := 0; i < hours; i++ {
+		hour := end.Add(-time.Duration(i) * time.Hour).Format(hourLayout)
+		pvKeys = append(pvKeys, fmt.Sprintf("pvh:%s:%s", page, hour))
+		uvKeys = append(uvKeys, fmt.Sprintf("uvh:%s:%s", page, hour))
+	}
+
+	// 汇总各小时的PV
+	vals, err := s.redisClient.MGet(ctx, pvKeys...).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get hourly page views: %w", err)
+	}
+	for _, val := range vals {
+		str, ok := val.(string)
+		if !ok {
+			// 键不存在
+			continue
+		}
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid hourly page view value %q: %w", str, err)
+		}
+		pv += n
+	}
+	pv = s.scalePageViews(pv)
+
+	// 合并各小时的HLL到临时键后计数，事务执行完毕即删除临时键
+	mergeKey := fmt.Sprintf("uv:rolling:%s", page)
+	var countCmd *redis.IntCmd
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.PFMerge(ctx, mergeKey, uvKeys...)
+		countCmd = pipe.PFCount(ctx, mergeKey)
+		pipe.Del(ctx, mergeKey)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to merge hourly unique visitors: %w", err)
+	}
+
+	return pv, countCmd.Val(), nil
+}
@@ -0,0 +1,6 @@
+package stats
+
+import "redis-learning/pkg/script"
+
+// scripts 统计服务使用的Lua脚本，以 EVALSHA 执行，Redis中没有脚本时自动加载
+var scripts = script.NewBundle("uv-pv-collector")
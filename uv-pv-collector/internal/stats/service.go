@@ -7,29 +7,62 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"uv-pv-collector/internal/config"
+
+	"redis-learning/pkg/bloom"
+	"redis-learning/pkg/redisclient"
 )
 
 // StatsService 提供UV和PV统计的服务
 type StatsService struct {
 	redisClient *redis.Client
+	// 小时桶的保留时长
+	rollingRetention time.Duration
+	// 访客画像的过期时间
+	visitorProfileTTL time.Duration
+	// PV采样率，读取PV时按此倍数放大
+	pvSampleRate int64
+	// 精确UV阈值，为0表示直接使用HyperLogLog
+	exactUVThreshold int64
+	// 原始事件归档使用的Stream键名和近似最大长度
+	eventStreamKey    string
+	eventStreamMaxLen int64
+	// 记录出现过的访客的布隆过滤器，为nil表示不检测新访客
+	visitorFilter *bloom.Filter
 }
 
-// NewStatsService 创建一个新的统计服务实例
-func NewStatsService(cfg *config.Config) (*StatsService, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisAddr,
-		Password: cfg.RedisPassword,
-		DB:       cfg.RedisDB,
-	})
+// NewStatsService 创建一个新的统计服务实例，hooks 添加到Redis客户端上，例如统计命令的指标
+func NewStatsService(cfg *config.Config, hooks ...redis.Hook) (*StatsService, error) {
+	client := redisclient.NewClient(cfg.Redis, hooks...)
 
 	// 测试Redis连接
 	ctx := context.Background()
-	if err := client.Ping(ctx).Err(); err != nil {
+	if err := redisclient.Ping(ctx, client); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
+	// 预先加载脚本包，管道中以 EVALSHA 执行的脚本不会因为脚本未加载而失败
+	if err := scripts.Load(ctx, client); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to load scripts: %w", err)
+	}
+
+	var visitorFilter *bloom.Filter
+	if cfg.EnableNewVisitorDetection {
+		visitorFilter = bloom.NewFilter(client, cfg.NewVisitorFilterKey, bloom.Config{
+			ExpectedItems:     cfg.NewVisitorExpected,
+			FalsePositiveRate: cfg.NewVisitorFalsePositiveRate,
+		})
+	}
 
 	return &StatsService{
-		redisClient: client,
+		redisClient:       client,
+		rollingRetention:  cfg.RollingRetention,
+		visitorProfileTTL: cfg.VisitorProfileTTL,
+		pvSampleRate:      int64(cfg.PageViewSampleRate),
+		exactUVThreshold:  cfg.ExactUVThreshold,
+		eventStreamKey:    cfg.EventStreamKey,
+		eventStreamMaxLen: cfg.EventStreamMaxLen,
+		visitorFilter:     visitorFilter,
 	}, nil
 }
 
@@ -47,16 +80,37 @@ func (s *StatsService) RecordPageView(ctx context.Context, page string) error {
 }
 
 // RecordUniqueVisitor 记录唯一访客(UV)
-func (s *StatsService) RecordUniqueVisitor(ctx context.Context, page, visitorID string) error {
+// 返回值表示该访客是否是页面当天的新访客（HyperLogLog模式下为估计结果）
+func (s *StatsService) RecordUniqueVisitor(ctx context.Context, page, visitorID string) (bool, error) {
 	date := time.Now().Format("2006-01-02")
+
+	// 访客较少时先用SET精确计数，超过阈值后自动转换为HyperLogLog
+	if s.exactUVThreshold > 0 {
+		return s.recordExactUniqueVisitor(ctx, page, date, visitorID)
+	}
+
 	key := fmt.Sprintf("uv:%s:%s", page, date)
 
 	// 使用HyperLogLog记录唯一访客
-	if err := s.redisClient.PFAdd(ctx, key, visitorID).Err(); err != nil {
-		return fmt.Errorf("failed to record unique visitor: %w", err)
+	changed, err := s.redisClient.PFAdd(ctx, key, visitorID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to record unique visitor: %w", err)
 	}
 
-	return nil
+	return changed == 1, nil
+}
+
+// AcquireDedupGate 尝试获取访客在页面上的去重门闩
+// 使用SET NX EX实现，窗口内首次访问返回true，重复访问返回false
+func (s *StatsService) AcquireDedupGate(ctx context.Context, page, visitorID string, window time.Duration) (bool, error) {
+	key := fmt.Sprintf("dedup:%s:%s", page, visitorID)
+
+	ok, err := s.redisClient.SetNX(ctx, key, 1, window).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire dedup gate: %w", err)
+	}
+
+	return ok, nil
 }
 
 // GetPageViews 获取特定页面在指定日期的PV数
@@ -71,22 +125,26 @@ func (s *StatsService) GetPageViews(ctx context.Context, page, date string) (int
 		return 0, fmt.Errorf("failed to get page views: %w", err)
 	}
 
-	return val, nil
+	return s.scalePageViews(val), nil
+}
+
+// scalePageViews 将采样记录的PV计数按采样率还原为估计值
+func (s *StatsService) scalePageViews(count int64) int64 {
+	if s.pvSampleRate <= 1 {
+		return count
+	}
+	return count * s.pvSampleRate
 }
 
 // GetUniqueVisitors 获取特定页面在指定日期的UV数
+// 页面当天访客数未超过精确阈值时返回精确值，否则返回HyperLogLog的估计值
 func (s *StatsService) GetUniqueVisitors(ctx context.Context, page, date string) (int64, error) {
-	key := fmt.Sprintf("uv:%s:%s", page, date)
-
-	val, err := s.redisClient.PFCount(ctx, key).Result()
-	if err == redis.Nil {
-		// 键不存在，返回0
-		return 0, nil
-	} else if err != nil {
-		return 0, fmt.Errorf("failed to get unique visitors: %w", err)
-	}
+	return s.countUniqueVisitors(ctx, page, date)
+}
 
-	return val, nil
+// Client 返回底层的Redis客户端，供调度器等其他组件共用连接
+func (s *StatsService) Client() *redis.Client {
+	return s.redisClient
 }
 
 // Close 关闭Redis连接
@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 全站汇总使用独立的site:前缀，与按页面保存的 pv:{page}:{date} 等键分开，任何页面名都不会与全站数据混在一起
+
+// sitePVKey 全站某天的PV计数器
+func sitePVKey(date string) string {
+	return "site:pv:" + date
+}
+
+// siteUVKey 全站某天的UV HyperLogLog
+func siteUVKey(date string) string {
+	return "site:uv:" + date
+}
+
+// siteReferrerKey 全站某天的来源域名有序集合
+func siteReferrerKey(date string) string {
+	return "site:ref:" + date
+}
+
+// DailyStats 某一天的PV和UV统计数据
+type DailyStats struct {
+	Date           string `json:"date"`
+	PageViews      int64  `json:"page_views"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// RecordSiteVisit 在全站计数器和全站HyperLogLog中记录一次访问
+// countPV为false时只记录UV（该次访问未被PV采样命中）
+func (s *StatsService) RecordSiteVisit(ctx context.Context, visitorID string, countPV bool) error {
+	date := time.Now().Format("2006-01-02")
+
+	_, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if countPV {
+			pipe.Incr(ctx, sitePVKey(date))
+		}
+		pipe.PFAdd(ctx, siteUVKey(date), visitorID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record site visit: %w", err)
+	}
+
+	return nil
+}
+
+// GetSiteDailyStats 获取全站某一天的PV和UV
+func (s *StatsService) GetSiteDailyStats(ctx context.Context, date string) (pv, uv int64, err error) {
+	var pvCmd *redis.StringCmd
+	var uvCmd *redis.IntCmd
+	_, err = s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pvCmd = pipe.Get(ctx, sitePVKey(date))
+		uvCmd = pipe.PFCount(ctx, siteUVKey(date))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return 0, 0, fmt.Errorf("failed to get site stats: %w", err)
+	}
+
+	pv, err = pvCmd.Int64()
+	if err != nil && err != redis.Nil {
+		return 0, 0, fmt.Errorf("failed to get site page views: %w", err)
+	}
+
+	return pv, uvCmd.Val(), nil
+}
+
+// GetSiteUniqueVisitorsUnion 获取全站在多个日期内去重后的UV数
+// PFCOUNT作用于多个键时返回它们并集的基数估计，同一访客在不同日期只计一次
+func (s *StatsService) GetSiteUniqueVisitorsUnion(ctx context.Context, dates []string) (int64, error) {
+	if len(dates) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, len(dates))
+	for i, date := range dates {
+		keys[i] = siteUVKey(date)
+	}
+
+	val, err := s.redisClient.PFCount(ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unique visitors union: %w", err)
+	}
+
+	return val, nil
+}
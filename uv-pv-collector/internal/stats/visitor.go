@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrVisitorNotFound 访客画像不存在时返回此错误
+var ErrVisitorNotFound = errors.New("visitor not found")
+
+// VisitorProfile 访客画像，记录访客首次、最近访问时间和累计访问次数
+type VisitorProfile struct {
+	VisitorID   string    `json:"visitor_id"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	TotalVisits int64     `json:"total_visits"`
+	LastPage    string    `json:"last_page"`
+}
+
+// UpdateVisitorProfile 更新访客画像
+// 画像保存在哈希 visitor:{id} 中，first_seen只在首次访问时写入
+func (s *StatsService) UpdateVisitorProfile(ctx context.Context, visitorID, page string) error {
+	key := fmt.Sprintf("visitor:%s", visitorID)
+	now := time.Now().Unix()
+
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, key, "first_seen", now)
+		pipe.HSet(ctx, key, "last_seen", now, "last_page", page)
+		pipe.HIncrBy(ctx, key, "total_visits", 1)
+		// 设置了TTL时，每次访问都会刷新过期时间，长期不活跃的访客画像会被自动清理
+		if s.visitorProfileTTL > 0 {
+			pipe.Expire(ctx, key, s.visitorProfileTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update visitor profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetVisitorProfile 获取访客画像，不存在时返回 ErrVisitorNotFound
+func (s *StatsService) GetVisitorProfile(ctx context.Context, visitorID string) (*VisitorProfile, error) {
+	key := fmt.Sprintf("visitor:%s", visitorID)
+
+	fields, err := s.redisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visitor profile: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, ErrVisitorNotFound
+	}
+
+	firstSeen, _ := strconv.ParseInt(fields["first_seen"], 10, 64)
+	lastSeen, _ := strconv.ParseInt(fields["last_seen"], 10, 64)
+	totalVisits, _ := strconv.ParseInt(fields["total_visits"], 10, 64)
+
+	return &VisitorProfile{
+		VisitorID:   visitorID,
+		FirstSeen:   time.Unix(firstSeen, 0),
+		LastSeen:    time.Unix(lastSeen, 0),
+		TotalVisits: totalVisits,
+		LastPage:    fields["last_page"],
+	}, nil
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"uv-pv-collector/internal/config"
+	"uv-pv-collector/internal/stats"
+)
+
+// newTestCollector 创建连接进程内miniredis的统计收集器，configure用于在创建前修改配置
+func newTestCollector(t *testing.T, configure func(cfg *config.Config)) (*stats.StatsCollector, *stats.StatsService) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg := config.DefaultConfig()
+	cfg.Redis.Addr = mr.Addr()
+	if configure != nil {
+		configure(cfg)
+	}
+	service, err := stats.NewStatsService(cfg)
+	if err != nil {
+		t.Fatalf("NewStatsService() error = %v", err)
+	}
+	t.Cleanup(func() { _ = service.Close() })
+	return stats.NewStatsCollector(service, cfg), service
+}
+
+// seedDays 为页面在[start, start+days)的每一天写入pv次PV，以及当天独有的一个精确UV访客
+func seedDays(t *testing.T, service *stats.StatsService, page string, start time.Time, days int, pv int64) {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; i < days; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		if err := service.Client().IncrBy(ctx, fmt.Sprintf("pv:%s:%s", page, date), pv).Err(); err != nil {
+			t.Fatalf("IncrBy() error = %v", err)
+		}
+		if err := service.Client().SAdd(ctx, fmt.Sprintf("uvset:%s:%s", page, date), "visitor-"+date).Err(); err != nil {
+			t.Fatalf("SAdd() error = %v", err)
+		}
+	}
+}
+
+// TestCompaction_RangeTotals 压缩前后按整月的日期范围查询得到相同的PV和UV，按天和不完整的范围返回 ErrCompacted
+func TestCompaction_RangeTotals(t *testing.T) {
+	ctx := context.Background()
+	collector, service := newTestCollector(t, func(cfg *config.Config) {
+		cfg.CompactionAge = 24 * time.Hour
+	})
+
+	jan := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	seedDays(t, service, "/home", jan, 31, 10)
+	seedDays(t, service, "/home", jan.AddDate(0, 1, 0), 3, 5)
+
+	wantPV, wantUV := int64(31*10+3*5), int64(31+3)
+	pv, uv, err := collector.GetStatsForDateRange(ctx, "/home", "2024-01-01", "2024-02-29")
+	if err != nil || pv != wantPV || uv != wantUV {
+		t.Fatalf("GetStatsForDateRange() before compaction = %d, %d, %v, want %d, %d", pv, uv, err, wantPV, wantUV)
+	}
+
+	compacted, err := collector.Compact(ctx)
+	if err != nil || compacted != 34 {
+		t.Fatalf("Compact() = %d, %v, want 34", compacted, err)
+	}
+
+	pv, uv, err = collector.GetStatsForDateRange(ctx, "/home", "2024-01-01", "2024-02-29")
+	if err != nil || pv != wantPV || uv != wantUV {
+		t.Errorf("GetStatsForDateRange() after compaction = %d, %d, %v, want %d, %d", pv, uv, err, wantPV, wantUV)
+	}
+	pv, uv, err = collector.GetMonthlyStats(ctx, "/home", "2024-01")
+	if err != nil || pv != 310 || uv != 31 {
+		t.Errorf("GetMonthlyStats(2024-01) = %d, %d, %v, want 310, 31", pv, uv, err)
+	}
+
+	if _, _, err := collector.GetDailyStats(ctx, "/home", "2024-01-05"); !errors.Is(err, stats.ErrCompacted) {
+		t.Errorf("GetDailyStats() on a compacted date error = %v, want ErrCompacted", err)
+	}
+	if _, _, err := collector.GetStatsForDateRange(ctx, "/home", "2024-01-05", "2024-01-10"); !errors.Is(err, stats.ErrCompacted) {
+		t.Errorf("GetStatsForDateRange() over part of a compacted month error = %v, want ErrCompacted", err)
+	}
+}
+
+// TestCompaction_RecentDaysUntouched 未早于压缩年龄的日期仍按天查询，不受压缩影响
+func TestCompaction_RecentDaysUntouched(t *testing.T) {
+	ctx := context.Background()
+	collector, service := newTestCollector(t, func(cfg *config.Config) {
+		cfg.CompactionAge = 30 * 24 * time.Hour
+	})
+
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	seedDays(t, service, "/home", today.AddDate(0, 0, -2), 3, 4)
+
+	if compacted, err := collector.Compact(ctx); err != nil || compacted != 0 {
+		t.Fatalf("Compact() = %d, %v, want 0", compacted, err)
+	}
+	date := today.Format("2006-01-02")
+	pv, uv, err := collector.GetDailyStats(ctx, "/home", date)
+	if err != nil || pv != 4 || uv != 1 {
+		t.Errorf("GetDailyStats(%s) = %d, %d, %v, want 4, 1", date, pv, uv, err)
+	}
+	start := today.AddDate(0, 0, -2).Format("2006-01-02")
+	pv, uv, err = collector.GetStatsForDateRange(ctx, "/home", start, date)
+	if err != nil || pv != 12 || uv != 3 {
+		t.Errorf("GetStatsForDateRange(%s, %s) = %d, %d, %v, want 12, 3", start, date, pv, uv, err)
+	}
+}
@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"uv-pv-collector/internal/config"
+)
+
+// TestGoal_ProfileCountAfterScriptFlush 服务启动时加载脚本包，SCRIPT FLUSH 之后管道中的脚本返回NOSCRIPT时单独重试，
+// 画像不存在时不创建
+func TestGoal_ProfileCountAfterScriptFlush(t *testing.T) {
+	ctx := context.Background()
+	collector, service := newTestCollector(t, func(cfg *config.Config) {
+		cfg.EnableVisitorProfiles = true
+	})
+	client := service.Client()
+
+	if err := client.HSet(ctx, "visitor:v1", "visits", 1).Err(); err != nil {
+		t.Fatalf("HSet() error = %v", err)
+	}
+	if err := collector.RecordGoal(ctx, "signup", "/home", "v1"); err != nil {
+		t.Fatalf("RecordGoal() error = %v", err)
+	}
+	if err := client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("ScriptFlush() error = %v", err)
+	}
+	if err := collector.RecordGoal(ctx, "signup", "/home", "v1"); err != nil {
+		t.Fatalf("RecordGoal() after SCRIPT FLUSH error = %v", err)
+	}
+
+	if got, err := client.HGet(ctx, "visitor:v1", "goal:signup").Int64(); err != nil || got != 2 {
+		t.Errorf("profile goal count = %d, %v, want 2", got, err)
+	}
+	if err := collector.RecordGoal(ctx, "signup", "", "v2"); err != nil {
+		t.Fatalf("RecordGoal() for a visitor without profile error = %v", err)
+	}
+	if n, err := client.Exists(ctx, "visitor:v2").Result(); err != nil || n != 0 {
+		t.Errorf("Exists(visitor:v2) = %d, %v, want 0", n, err)
+	}
+}